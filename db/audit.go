@@ -5,19 +5,53 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/alwitt/haven/models"
 	"github.com/oklog/ulid/v2"
 	"gorm.io/datatypes"
 )
 
+// DefaultActor the actor recorded in system events when the context carries none
+const DefaultActor = "system"
+
+// actorContextKey context key for the acting principal
+type actorContextKey struct{}
+
+/*
+WithActor attach the acting principal to a context. System events recorded with this
+context are attributed to the actor.
+
+	@param ctx context.Context - parent context
+	@param actor string - the acting principal
+	@returns the new context
+*/
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+/*
+ActorFromContext read the acting principal from a context
+
+	@param ctx context.Context - the context
+	@returns the actor, or DefaultActor if the context carries none
+*/
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorContextKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return DefaultActor
+}
+
 // defineNewSystemEvent record a new system event
 func (d *databaseImpl) defineNewSystemEvent(
-	eventType models.SystemEventTypeENUMType, metadata interface{},
+	ctx context.Context, eventType models.SystemEventTypeENUMType, metadata interface{},
 ) (models.SystemEventAudit, error) {
 
 	newEntry := SystemEventAuditDBEntry{
-		SystemEventAudit: models.SystemEventAudit{ID: ulid.Make().String(), EventType: eventType},
+		SystemEventAudit: models.SystemEventAudit{
+			ID: ulid.Make().String(), EventType: eventType, Actor: ActorFromContext(ctx),
+		},
 	}
 
 	if metadata != nil {
@@ -37,7 +71,22 @@ func (d *databaseImpl) defineNewSystemEvent(
 		)
 	}
 
-	if tmp := d.db.Create(&newEntry); tmp.Error != nil {
+	if d.auditWriter != nil {
+		if d.closed.Load() {
+			return models.SystemEventAudit{}, ErrSessionClosed
+		}
+		// Stamp the event now, rather than when the writer inserts it
+		newEntry.CreatedAt = time.Now()
+		newEntry.UpdatedAt = newEntry.CreatedAt
+		if err := d.auditWriter.enqueue(ctx, newEntry); err != nil {
+			return models.SystemEventAudit{}, fmt.Errorf(
+				"new system event '%s' buffering failed [%w]", eventType, err,
+			)
+		}
+		return newEntry.SystemEventAudit, nil
+	}
+
+	if tmp := d.session(ctx).Create(&newEntry); tmp.Error != nil {
 		return models.SystemEventAudit{}, fmt.Errorf(
 			"new system event '%s' insert failed [%w]", eventType, tmp.Error,
 		)
@@ -54,14 +103,18 @@ ListSystemEvents list captured system events
 	@return list of system events
 */
 func (d *databaseImpl) ListSystemEvents(
-	_ context.Context, filters SystemEventQueryFilter,
+	ctx context.Context, filters SystemEventQueryFilter,
 ) ([]models.SystemEventAudit, error) {
-	query := d.db.Model(&SystemEventAuditDBEntry{})
+	query := d.session(ctx).Model(&SystemEventAuditDBEntry{})
 
 	if len(filters.EventTypes) > 0 {
 		query = query.Where("type in ?", filters.EventTypes)
 	}
 
+	if len(filters.Actors) > 0 {
+		query = query.Where("actor in ?", filters.Actors)
+	}
+
 	if filters.EventsAfter != nil {
 		query = query.Where("created_at >= ?", *filters.EventsAfter)
 	}
@@ -69,14 +122,17 @@ func (d *databaseImpl) ListSystemEvents(
 		query = query.Where("created_at <= ?", *filters.EventsBefore)
 	}
 
-	if filters.Limit != nil {
-		query = query.Limit(*filters.Limit)
+	query, err := d.applyListFilter(
+		ctx,
+		query,
+		&SystemEventAuditDBEntry{},
+		filters.CommonListEntryQueryFilter,
+		"created_at",
+		false,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid system event list filter [%w]", err)
 	}
-	if filters.Offset != nil {
-		query = query.Offset(*filters.Offset)
-	}
-
-	query = query.Order("created_at")
 
 	var entries []SystemEventAuditDBEntry
 	if tmp := query.Find(&entries); tmp.Error != nil {
@@ -0,0 +1,407 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/alwitt/goutils"
+	"github.com/apex/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrAuditWriterClosed the asynchronous audit writer has stopped, so it can not take events
+var ErrAuditWriterClosed = errors.New("asynchronous audit writer closed")
+
+// AsyncAuditOptions asynchronous audit mode settings
+//
+// In asynchronous audit mode, system events are not inserted in the transaction which
+// records them. They are buffered instead, and inserted in batches by a background writer.
+// This removes the audit inserts from the write path, at these costs:
+//
+//   - An event is persisted even if the transaction which recorded it rolls back.
+//   - Events are only visible to queries once inserted. An event waits up to
+//     FlushInterval, or longer while the database is refusing the inserts; Client.Flush
+//     and Client.Close insert the waiting events immediately.
+//   - Without a SpoolFile, the waiting events are lost if the process exits without
+//     Client.Flush or Client.Close. With one, an event is lost only if the spool file is.
+//   - An event the database keeps refusing while it is reachable is dropped, so it can not
+//     hold back the events recorded after it.
+//
+// An event keeps the timestamp of when it was recorded, not when it was inserted.
+type AsyncAuditOptions struct {
+	// FlushInterval how often buffered events are inserted. Defaults to 100 ms.
+	FlushInterval time.Duration
+	// BatchSize buffered events are inserted as soon as this many are waiting. Defaults
+	// to 100.
+	BatchSize int
+	// BufferSize how many events can wait to be inserted before recording an event blocks.
+	// While the database refuses the inserts, at most twice this many events are held in
+	// memory. Defaults to 1000.
+	BufferSize int
+	// MaxRefusals how many times the database may refuse to insert an event while it is
+	// reachable before the event is dropped. Defaults to 3.
+	MaxRefusals int
+	// SpoolFile file each event is appended to, and synced, before it is buffered. The file
+	// is truncated once every event in it was inserted. Events left in it by a process
+	// which exited before inserting them are inserted when the next writer starts. Empty
+	// to only buffer the events in memory.
+	SpoolFile string
+}
+
+// pendingAuditEvent a buffered event the writer has taken
+type pendingAuditEvent struct {
+	entry SystemEventAuditDBEntry
+	// refusals how many times the database refused the event while it was reachable
+	refusals int
+}
+
+// asyncAuditWriter insert system events in batches in the background
+type asyncAuditWriter struct {
+	goutils.Component
+	db      *gorm.DB
+	options AsyncAuditOptions
+	// spool the spool file. Nil if events are only buffered in memory.
+	spool *auditSpool
+
+	events  chan SystemEventAuditDBEntry
+	flushes chan chan error
+	stop    chan struct{}
+	stopped chan struct{}
+
+	stopOnce sync.Once
+	// pending the events taken by the writer, but not yet inserted. Owned by the writer
+	// loop until it stops.
+	pending []pendingAuditEvent
+	// finalErr why the last insert of the writer loop failed, if it did
+	finalErr error
+}
+
+// newAsyncAuditWriter define and start a new asynchronous audit writer
+func newAsyncAuditWriter(db *gorm.DB, options AsyncAuditOptions) (*asyncAuditWriter, error) {
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = 100 * time.Millisecond
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = 100
+	}
+	if options.BufferSize <= 0 {
+		options.BufferSize = 1000
+	}
+	if options.MaxRefusals <= 0 {
+		options.MaxRefusals = 3
+	}
+
+	writer := &asyncAuditWriter{
+		Component: goutils.Component{
+			LogTags: log.Fields{"package": "haven", "module": "db", "component": "audit-writer"},
+		},
+		db:      db,
+		options: options,
+		events:  make(chan SystemEventAuditDBEntry, options.BufferSize),
+		flushes: make(chan chan error),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	if options.SpoolFile != "" {
+		spool, leftover, err := openAuditSpool(options.SpoolFile)
+		if err != nil {
+			return nil, err
+		}
+		writer.spool = spool
+		for _, entry := range leftover {
+			writer.pending = append(writer.pending, pendingAuditEvent{entry: entry})
+		}
+		if len(leftover) > 0 {
+			log.WithFields(writer.LogTags).
+				WithField("events", len(leftover)).
+				Info("Inserting system events left in the spool file")
+		}
+	}
+
+	go writer.run()
+	return writer, nil
+}
+
+// enqueue hand an event to the writer, waiting while its buffer is full
+func (w *asyncAuditWriter) enqueue(ctx context.Context, entry SystemEventAuditDBEntry) error {
+	select {
+	case <-w.stop:
+		return ErrAuditWriterClosed
+	default:
+	}
+	if w.spool != nil {
+		if err := w.spool.append(entry); err != nil {
+			return err
+		}
+	}
+	select {
+	case w.events <- entry:
+		return nil
+	case <-w.stop:
+		w.settle(1)
+		return ErrAuditWriterClosed
+	case <-ctx.Done():
+		w.settle(1)
+		return ctx.Err()
+	}
+}
+
+// flush insert every event handed to the writer so far
+func (w *asyncAuditWriter) flush(ctx context.Context) error {
+	reply := make(chan error, 1)
+	select {
+	case w.flushes <- reply:
+	case <-w.stopped:
+		return ErrAuditWriterClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// close stop the writer, after inserting every event handed to it
+func (w *asyncAuditWriter) close() error {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	<-w.stopped
+	// Events handed over while the writer loop was stopping
+	w.drain()
+	err := errors.Join(w.finalErr, w.insertPending())
+	if w.spool != nil {
+		err = errors.Join(err, w.spool.close())
+	}
+	return err
+}
+
+// drain take every event waiting in the buffer
+func (w *asyncAuditWriter) drain() {
+	for {
+		select {
+		case entry := <-w.events:
+			w.pending = append(w.pending, pendingAuditEvent{entry: entry})
+		default:
+			return
+		}
+	}
+}
+
+// insert insert the events together. Events already inserted are skipped, as events
+// replayed from the spool file may have been inserted before the process exited.
+func (w *asyncAuditWriter) insert(entries []SystemEventAuditDBEntry) error {
+	return w.db.Transaction(func(tx *gorm.DB) error {
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).
+			CreateInBatches(&entries, w.options.BatchSize).Error
+	})
+}
+
+/*
+insertPending insert the pending events. When inserting them together fails while the
+database is reachable, they are inserted one at a time, so an event the database refuses
+does not hold back the others. The events which fail remain pending, except those refused
+MaxRefusals times, which are dropped.
+*/
+func (w *asyncAuditWriter) insertPending() error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+
+	entries := make([]SystemEventAuditDBEntry, len(w.pending))
+	for idx, event := range w.pending {
+		entries[idx] = event.entry
+	}
+	batchErr := w.insert(entries)
+	if batchErr == nil {
+		w.settle(len(w.pending))
+		w.pending = w.pending[:0]
+		return nil
+	}
+
+	// While the database is unreachable, no event is to blame
+	if sqlDB, err := w.db.DB(); err != nil || sqlDB.Ping() != nil {
+		log.WithError(batchErr).WithFields(w.LogTags).
+			WithField("events", len(w.pending)).
+			Error("Failed to insert buffered system events")
+		return fmt.Errorf(
+			"failed to insert %d buffered system events [%w]", len(w.pending), batchErr,
+		)
+	}
+
+	remaining := []pendingAuditEvent{}
+	failed, dropped := 0, 0
+	for _, event := range w.pending {
+		if err := w.insert([]SystemEventAuditDBEntry{event.entry}); err == nil {
+			continue
+		}
+		failed++
+		event.refusals++
+		if event.refusals < w.options.MaxRefusals {
+			remaining = append(remaining, event)
+			continue
+		}
+		log.WithFields(w.LogTags).
+			WithField("event-id", event.entry.ID).
+			WithField("event-type", event.entry.EventType).
+			Error("Dropping system event the DB keeps refusing")
+		dropped++
+	}
+	w.settle(len(w.pending) - len(remaining))
+	w.pending = remaining
+	if failed == 0 {
+		return nil
+	}
+
+	log.WithError(batchErr).WithFields(w.LogTags).
+		WithField("events", failed).
+		WithField("dropped", dropped).
+		Error("Failed to insert buffered system events")
+	return fmt.Errorf(
+		"failed to insert %d buffered system events, %d of which were dropped [%w]",
+		failed, dropped, batchErr,
+	)
+}
+
+// settle mark events handed to the writer as no longer pending, whether inserted or not
+func (w *asyncAuditWriter) settle(count int) {
+	if w.spool == nil || count == 0 {
+		return
+	}
+	if err := w.spool.settle(count); err != nil {
+		log.WithError(err).WithFields(w.LogTags).Error("Failed to truncate audit spool file")
+	}
+}
+
+// run the writer loop
+func (w *asyncAuditWriter) run() {
+	defer close(w.stopped)
+
+	ticker := time.NewTicker(w.options.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		// Stop taking events while too many are pending, so recording events blocks
+		events := w.events
+		if len(w.pending) >= w.options.BufferSize {
+			events = nil
+		}
+
+		select {
+		case entry := <-events:
+			w.pending = append(w.pending, pendingAuditEvent{entry: entry})
+			if len(w.pending) >= w.options.BatchSize {
+				_ = w.insertPending()
+			}
+
+		case <-ticker.C:
+			_ = w.insertPending()
+
+		case reply := <-w.flushes:
+			w.drain()
+			reply <- w.insertPending()
+
+		case <-w.stop:
+			w.drain()
+			w.finalErr = w.insertPending()
+			return
+		}
+	}
+}
+
+// auditSpool file holding the events handed to the asynchronous audit writer, so they
+// survive the process exiting before they are inserted
+type auditSpool struct {
+	lock sync.Mutex
+	file *os.File
+	// appended number of events appended since the file was last truncated
+	appended int
+	// settled number of the appended events which are no longer pending
+	settled int
+}
+
+/*
+openAuditSpool open the spool file, creating it if needed
+
+	@param path string - spool file path
+	@returns the spool, and the events left in the file
+*/
+func openAuditSpool(path string) (*auditSpool, []SystemEventAuditDBEntry, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open audit spool file '%s' [%w]", path, err)
+	}
+
+	leftover := []SystemEventAuditDBEntry{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		var entry SystemEventAuditDBEntry
+		// The last line is incomplete if the process exited while appending it
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			log.WithError(err).
+				WithField("spool-file", path).
+				Warn("Skipping unreadable entry of audit spool file")
+			continue
+		}
+		leftover = append(leftover, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		_ = file.Close()
+		return nil, nil, fmt.Errorf("failed to read audit spool file '%s' [%w]", path, err)
+	}
+
+	return &auditSpool{file: file, appended: len(leftover)}, leftover, nil
+}
+
+// append append an event to the file, and sync it
+func (s *auditSpool) append(entry SystemEventAuditDBEntry) error {
+	line, err := json.Marshal(&entry)
+	if err != nil {
+		return fmt.Errorf("failed to serialize system event %s [%w]", entry.ID, err)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to spool system event %s [%w]", entry.ID, err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync audit spool file [%w]", err)
+	}
+	s.appended++
+	return nil
+}
+
+// settle mark appended events as no longer pending. The file is truncated once none are.
+func (s *auditSpool) settle(count int) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.settled += count
+	if s.settled < s.appended {
+		return nil
+	}
+	if err := s.file.Truncate(0); err != nil {
+		return err
+	}
+	s.appended, s.settled = 0, 0
+	return s.file.Sync()
+}
+
+// close close the file
+func (s *auditSpool) close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.file.Close()
+}
@@ -3,9 +3,11 @@ package db
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/alwitt/goutils"
 	"github.com/apex/log"
+	"github.com/oklog/ulid/v2"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -21,6 +23,20 @@ func GetSqliteDialector(dbFile string) gorm.Dialector {
 	return sqlite.Open(fmt.Sprintf("%s?_foreign_keys=on", dbFile))
 }
 
+/*
+GetInMemorySqliteDialector define GORM dialector for a new in-memory Sqlite DB
+
+Each call defines a separate DB, which is shared by all connections opened through the
+returned dialector. The DB is discarded once its last connection is closed.
+
+	@return GORM sqlite dialector
+*/
+func GetInMemorySqliteDialector() gorm.Dialector {
+	return sqlite.Open(
+		fmt.Sprintf("file:haven_%s?mode=memory&cache=shared&_foreign_keys=on", ulid.Make()),
+	)
+}
+
 // Client manages connections and transactions with a DB
 type Client interface {
 	/*
@@ -36,6 +52,8 @@ type Client interface {
 	/*
 		UseDatabase utilize a `Database` instance
 
+		The `Database` instance must not be used after the callback returns.
+
 			@param ctx context.Context - execution context
 			@param coreLogic func(ctx context.Context, dbClient Database) error - the callback to execute
 	*/
@@ -46,18 +64,103 @@ type Client interface {
 	/*
 		RunSQLInTransaction utilize a `Database` instance in a transaction
 
+		The `Database` instance must not be used after the callback returns.
+
 			@param ctx context.Context - execution context
 			@param coreLogic func(ctx context.Context, dbClient Database) error - the callback to execute
 	*/
 	UseDatabaseInTransaction(
 		ctx context.Context, coreLogic func(ctx context.Context, dbClient Database) error,
 	) error
+
+	/*
+		Ping verify the database connection is alive
+
+			@param ctx context.Context - execution context
+	*/
+	Ping(ctx context.Context) error
+
+	/*
+		RawDB the GORM instance the client runs its queries with
+
+		This is an escape hatch for queries the `Database` interface does not offer. Queries
+		made through it bypass the audit log, the session checks, and the storage encoding of
+		the client, and are not part of any transaction the client starts. Writes made through
+		it must preserve the invariants the client otherwise maintains.
+
+			@return the GORM instance
+	*/
+	RawDB() *gorm.DB
+
+	/*
+		Flush insert the system events buffered in asynchronous audit mode. Once it returns,
+		every event recorded before the call is visible. Does nothing otherwise.
+
+		Must not be called within a transaction of the client, as the insert may need the
+		connection the transaction holds.
+
+			@param ctx context.Context - execution context
+	*/
+	Flush(ctx context.Context) error
+
+	/*
+		Close close the underlying database connection. In asynchronous audit mode, the
+		buffered system events are inserted first.
+	*/
+	Close() error
 }
 
 // clientImpl implements Client
 type clientImpl struct {
 	goutils.Component
-	db *gorm.DB
+	db              *gorm.DB
+	storageEncoding StorageEncodingENUMType
+	// auditWriter inserts the system events in asynchronous audit mode
+	auditWriter *asyncAuditWriter
+}
+
+// StorageEncodingENUMType how the encrypted data of record versions is persisted
+type StorageEncodingENUMType string
+
+const (
+	// StorageEncodingBinary persist the encrypted data as raw bytes
+	StorageEncodingBinary StorageEncodingENUMType = "binary"
+	// StorageEncodingBase64 persist the encrypted data as unpadded base64 text
+	StorageEncodingBase64 StorageEncodingENUMType = "base64"
+)
+
+// ConnectionConfig SQL client configuration
+type ConnectionConfig struct {
+	// Dialector GORM dialector
+	Dialector gorm.Dialector
+	// LogLevel SQL log level
+	LogLevel logger.LogLevel
+	// GORMPlugins GORM plugins to register with the connection
+	GORMPlugins []gorm.Plugin
+	// StorageEncoding how the encrypted value and nonce of record versions are persisted.
+	// Defaults to StorageEncodingBinary. Every connection to a database must use the same
+	// encoding; the stored data is not converted when the encoding changes.
+	StorageEncoding StorageEncodingENUMType
+	// Pool connection pool settings. If nil, the defaults of ConnectionOptions apply.
+	Pool *ConnectionOptions
+	// AsyncAudit enables asynchronous audit mode with these settings. If nil, system events
+	// are inserted in the transaction which records them. See AsyncAuditOptions for what
+	// the mode gives up.
+	AsyncAudit *AsyncAuditOptions
+}
+
+// ConnectionOptions SQL connection pool settings. A zero value keeps the driver default,
+// except for MaxOpenConns with Sqlite.
+type ConnectionOptions struct {
+	// MaxOpenConns max number of open connections. A zero value is 1 for Sqlite, to avoid
+	// lock contention between connections.
+	MaxOpenConns int
+	// MaxIdleConns max number of idle connections
+	MaxIdleConns int
+	// ConnMaxLifetime max amount of time a connection may be reused
+	ConnMaxLifetime time.Duration
+	// ConnMaxIdleTime max amount of time a connection may be idle
+	ConnMaxIdleTime time.Duration
 }
 
 /*
@@ -68,16 +171,125 @@ NewConnection define a new SQL client
 	@return new client
 */
 func NewConnection(dbDialector gorm.Dialector, dbLogLevel logger.LogLevel) (Client, error) {
+	return NewConnectionWithConfig(ConnectionConfig{Dialector: dbDialector, LogLevel: dbLogLevel})
+}
+
+// connectionRetryInitialBackoff and connectionRetryMaxBackoff bound the wait between
+// attempts of NewConnectionWithRetry
+const (
+	connectionRetryInitialBackoff = 100 * time.Millisecond
+	connectionRetryMaxBackoff     = 5 * time.Second
+)
+
+/*
+NewConnectionWithRetry define a new SQL client, retrying with exponential backoff while
+the DB is unreachable, such as while it is still starting up. At least one attempt is
+made; no attempt is started once maxWait has passed or the context is cancelled.
+
+	@param ctx context.Context - execution context
+	@param dbDialector gorm.Dialector - GORM dialector
+	@param dbLogLevel logger.LogLevel - SQL log level
+	@param maxWait time.Duration - how long to keep retrying
+	@return new client
+*/
+func NewConnectionWithRetry(
+	ctx context.Context,
+	dbDialector gorm.Dialector,
+	dbLogLevel logger.LogLevel,
+	maxWait time.Duration,
+) (Client, error) {
+	logTags := log.Fields{"package": "haven", "module": "db", "component": "sql-client"}
+
+	deadline := time.Now().Add(maxWait)
+	backoff := connectionRetryInitialBackoff
+	for attempt := 1; ; attempt++ {
+		client, err := NewConnection(dbDialector, dbLogLevel)
+		if err == nil {
+			return client, nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, fmt.Errorf("DB unreachable after %d attempts [%w]", attempt, err)
+		}
+		wait := min(backoff, remaining)
+		log.WithError(err).
+			WithFields(logTags).
+			WithField("attempt", attempt).
+			WithField("retry-in", wait.String()).
+			Warn("Failed to connect with DB")
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf(
+				"gave up connecting with DB after %d attempts [%w]", attempt, ctx.Err(),
+			)
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, connectionRetryMaxBackoff)
+	}
+}
+
+/*
+NewConnectionWithOptions define a new SQL client with connection pool settings
+
+	@param dbDialector gorm.Dialector - GORM dialector
+	@param dbLogLevel logger.LogLevel - SQL log level
+	@param opts ConnectionOptions - connection pool settings
+	@return new client
+*/
+func NewConnectionWithOptions(
+	dbDialector gorm.Dialector, dbLogLevel logger.LogLevel, opts ConnectionOptions,
+) (Client, error) {
+	return NewConnectionWithConfig(
+		ConnectionConfig{Dialector: dbDialector, LogLevel: dbLogLevel, Pool: &opts},
+	)
+}
+
+/*
+NewConnectionWithConfig define a new SQL client
+
+	@param config ConnectionConfig - client configuration
+	@return new client
+*/
+func NewConnectionWithConfig(config ConnectionConfig) (Client, error) {
 	logTags := log.Fields{"package": "haven", "module": "db", "component": "sql-client"}
 
-	db, err := gorm.Open(dbDialector, &gorm.Config{
-		Logger:                 logger.Default.LogMode(dbLogLevel),
+	storageEncoding := config.StorageEncoding
+	switch storageEncoding {
+	case "":
+		storageEncoding = StorageEncodingBinary
+	case StorageEncodingBinary, StorageEncodingBase64:
+	default:
+		return nil, fmt.Errorf("unknown storage encoding '%s'", storageEncoding)
+	}
+
+	db, err := gorm.Open(config.Dialector, &gorm.Config{
+		Logger:                 logger.Default.LogMode(config.LogLevel),
 		SkipDefaultTransaction: true,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect with DB [%w]", err)
 	}
 
+	for _, plugin := range config.GORMPlugins {
+		if err := db.Use(plugin); err != nil {
+			closeGORMDB(db)
+			return nil, fmt.Errorf("failed to register GORM plugin '%s' [%w]", plugin.Name(), err)
+		}
+	}
+
+	pool := ConnectionOptions{}
+	if config.Pool != nil {
+		pool = *config.Pool
+	}
+	if err := applyConnectionOptions(db, pool); err != nil {
+		closeGORMDB(db)
+		return nil, err
+	}
+
 	instance := &clientImpl{
 		Component: goutils.Component{
 			LogTags: logTags,
@@ -85,12 +297,54 @@ func NewConnection(dbDialector gorm.Dialector, dbLogLevel logger.LogLevel) (Clie
 				goutils.ModifyLogMetadataByRestRequestParam,
 			},
 		},
-		db: db,
+		db:              db,
+		storageEncoding: storageEncoding,
+	}
+	if config.AsyncAudit != nil {
+		auditWriter, err := newAsyncAuditWriter(db, *config.AsyncAudit)
+		if err != nil {
+			closeGORMDB(db)
+			return nil, fmt.Errorf("failed to start asynchronous audit writer [%w]", err)
+		}
+		instance.auditWriter = auditWriter
 	}
 
 	return instance, nil
 }
 
+// closeGORMDB close the connections of a DB handle which could not be set up
+func closeGORMDB(db *gorm.DB) {
+	if sqlDB, err := db.DB(); err == nil {
+		_ = sqlDB.Close()
+	}
+}
+
+// applyConnectionOptions apply the connection pool settings to the underlying sql.DB
+func applyConnectionOptions(db *gorm.DB, opts ConnectionOptions) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to access SQL connection pool [%w]", err)
+	}
+
+	maxOpenConns := opts.MaxOpenConns
+	if maxOpenConns == 0 && db.Dialector.Name() == "sqlite" {
+		maxOpenConns = 1
+	}
+	if maxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(maxOpenConns)
+	}
+	if opts.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(opts.MaxIdleConns)
+	}
+	if opts.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	}
+	if opts.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
+	}
+	return nil
+}
+
 /*
 RunSQLInTransaction execute SQL calls within a transaction
 
@@ -100,7 +354,7 @@ RunSQLInTransaction execute SQL calls within a transaction
 func (c *clientImpl) RunSQLInTransaction(
 	ctx context.Context, coreLogic func(ctx context.Context, tx *gorm.DB) error,
 ) error {
-	return c.db.Transaction(func(tx *gorm.DB) error {
+	return c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		return coreLogic(ctx, tx)
 	})
 }
@@ -114,10 +368,11 @@ UseDatabase utilize a `Database` instance
 func (c *clientImpl) UseDatabase(
 	ctx context.Context, coreLogic func(ctx context.Context, dbClient Database) error,
 ) error {
-	dbClient, err := newDatabase(ctx, c.db)
+	dbClient, err := newDatabase(ctx, c.db.WithContext(ctx), c.storageEncoding, c.auditWriter)
 	if err != nil {
 		return fmt.Errorf("failed to define `Database` instance: [%w]", err)
 	}
+	defer dbClient.endSession()
 	return coreLogic(ctx, dbClient)
 }
 
@@ -130,13 +385,88 @@ RunSQLInTransaction utilize a `Database` instance in a transaction
 func (c *clientImpl) UseDatabaseInTransaction(
 	ctx context.Context, coreLogic func(ctx context.Context, dbClient Database) error,
 ) error {
-	return c.RunSQLInTransaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
-		dbClient, err := newDatabase(ctx, tx)
+	txHooks := &commitHooks{}
+	if err := c.RunSQLInTransaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		dbClient, err := newDatabase(ctx, tx, c.storageEncoding, c.auditWriter)
 		if err != nil {
 			return fmt.Errorf("failed to define `Database` instance: [%w]", err)
 		}
+		dbClient.commitHooks = txHooks
+		defer dbClient.endSession()
 		return coreLogic(ctx, dbClient)
-	})
+	}); err != nil {
+		return err
+	}
+	txHooks.run()
+	return nil
+}
+
+/*
+Ping verify the database connection is alive
+
+	@param ctx context.Context - execution context
+*/
+func (c *clientImpl) Ping(ctx context.Context) error {
+	sqlDB, err := c.db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to fetch SQL DB handle [%w]", err)
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping database [%w]", err)
+	}
+	return nil
+}
+
+/*
+RawDB the GORM instance the client runs its queries with
+
+This is an escape hatch for queries the `Database` interface does not offer. Queries made
+through it bypass the audit log, the session checks, and the storage encoding of the
+client, and are not part of any transaction the client starts. Writes made through it must
+preserve the invariants the client otherwise maintains.
+
+	@return the GORM instance
+*/
+func (c *clientImpl) RawDB() *gorm.DB {
+	return c.db
+}
+
+/*
+Flush insert the system events buffered in asynchronous audit mode. Once it returns, every
+event recorded before the call is visible. Does nothing otherwise.
+
+Must not be called within a transaction of the client, as the insert may need the
+connection the transaction holds.
+
+	@param ctx context.Context - execution context
+*/
+func (c *clientImpl) Flush(ctx context.Context) error {
+	if c.auditWriter == nil {
+		return nil
+	}
+	return c.auditWriter.flush(ctx)
+}
+
+/*
+Close close the underlying database connection. In asynchronous audit mode, the buffered
+system events are inserted first.
+*/
+func (c *clientImpl) Close() error {
+	var auditErr error
+	if c.auditWriter != nil {
+		if err := c.auditWriter.close(); err != nil {
+			auditErr = fmt.Errorf("failed to insert buffered system events [%w]", err)
+		}
+	}
+
+	sqlDB, err := c.db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to fetch SQL DB handle [%w]", err)
+	}
+	if err := sqlDB.Close(); err != nil {
+		return fmt.Errorf("failed to close DB connection [%w]", err)
+	}
+	return auditErr
 }
 
 /*
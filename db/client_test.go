@@ -0,0 +1,562 @@
+package db_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alwitt/haven/db"
+	"github.com/alwitt/haven/models"
+	"github.com/apex/log"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestDBClientClose(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	assert.Nil(uut.Close())
+
+	// The connection is no longer usable
+	assert.Error(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		_, err := dbClient.GetSystemParamEntry(ctx)
+		return err
+	}))
+}
+
+// queryCounterPlugin GORM plugin which counts the executed queries
+type queryCounterPlugin struct {
+	queries int
+}
+
+func (p *queryCounterPlugin) Name() string {
+	return "query-counter"
+}
+
+func (p *queryCounterPlugin) Initialize(gormDB *gorm.DB) error {
+	return gormDB.Callback().Query().After("gorm:query").Register(
+		"query-counter:after_query", func(*gorm.DB) { p.queries++ },
+	)
+}
+
+func TestDBClientGORMPlugins(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	plugin := &queryCounterPlugin{}
+	uut, err := db.NewConnectionWithConfig(db.ConnectionConfig{
+		Dialector:   db.GetSqliteDialector(testDB),
+		LogLevel:    logger.Error,
+		GORMPlugins: []gorm.Plugin{plugin},
+	})
+	assert.Nil(err)
+
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+	assert.Equal(0, plugin.queries)
+
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		_, err := dbClient.ListRecords(ctx, db.RecordQueryFilter{})
+		return err
+	}))
+	assert.Equal(1, plugin.queries)
+}
+
+// failingPlugin GORM plugin which fails to initialize
+type failingPlugin struct {
+	gormDB *gorm.DB
+}
+
+func (p *failingPlugin) Name() string {
+	return "failing"
+}
+
+func (p *failingPlugin) Initialize(gormDB *gorm.DB) error {
+	p.gormDB = gormDB
+	return fmt.Errorf("plugin refused")
+}
+
+func TestDBClientGORMPluginFailure(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	plugin := &failingPlugin{}
+	_, err := db.NewConnectionWithConfig(db.ConnectionConfig{
+		Dialector:   db.GetSqliteDialector(testDB),
+		LogLevel:    logger.Error,
+		GORMPlugins: []gorm.Plugin{plugin},
+	})
+	assert.Error(err)
+
+	// The opened DB is closed
+	if assert.NotNil(plugin.gormDB) {
+		sqlDB, err := plugin.gormDB.DB()
+		assert.Nil(err)
+		assert.Error(sqlDB.Ping())
+	}
+}
+
+func TestDBClientSessionClosed(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	// Capture the handle of a committed transaction
+	var escaped db.Database
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			escaped = dbClient
+			_, err := dbClient.ListEncryptionKeys(ctx, db.EncryptionKeyQueryFilter{})
+			return err
+		},
+	))
+
+	_, err = escaped.ListEncryptionKeys(utCtx, db.EncryptionKeyQueryFilter{})
+	assert.ErrorIs(err, db.ErrSessionClosed)
+	_, err = escaped.RecordEncryptionKey(
+		utCtx, []byte(ulid.Make().String()), "", "", models.AEADTypeXChaCha20Poly1305,
+	)
+	assert.ErrorIs(err, db.ErrSessionClosed)
+
+	// Capture the handle of a non-transactional session
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		escaped = dbClient
+		return nil
+	}))
+
+	_, err = escaped.CountEncryptionKeysByState(utCtx)
+	assert.ErrorIs(err, db.ErrSessionClosed)
+
+	// Nothing was written through the closed handles
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		keys, err := dbClient.ListEncryptionKeys(ctx, db.EncryptionKeyQueryFilter{})
+		assert.Nil(err)
+		assert.Empty(keys)
+		return err
+	}))
+}
+
+func TestDBClientInMemory(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	dialector := db.GetInMemorySqliteDialector()
+	uut1, err := db.NewConnection(dialector, logger.Error)
+	assert.Nil(err)
+
+	assert.Nil(uut1.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	assert.Nil(uut1.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			_, err := dbClient.RecordEncryptionKey(
+				ctx, []byte(ulid.Make().String()), "", "", models.AEADTypeXChaCha20Poly1305,
+			)
+			return err
+		},
+	))
+
+	countKeys := func(uut db.Client) int {
+		count := 0
+		assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+			keys, err := dbClient.ListEncryptionKeys(ctx, db.EncryptionKeyQueryFilter{})
+			count = len(keys)
+			return err
+		}))
+		return count
+	}
+
+	// Connections through the same dialector share the DB
+	uut2, err := db.NewConnection(dialector, logger.Error)
+	assert.Nil(err)
+	assert.Equal(1, countKeys(uut1))
+	assert.Equal(1, countKeys(uut2))
+
+	// A new dialector defines a separate DB
+	uut3, err := db.NewConnection(db.GetInMemorySqliteDialector(), logger.Error)
+	assert.Nil(err)
+	assert.Nil(uut3.RunSQLInTransaction(utCtx, db.DefineTables))
+	assert.Equal(0, countKeys(uut3))
+}
+
+func TestDBClientRawDB(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	uut, err := db.NewConnection(db.GetInMemorySqliteDialector(), logger.Error)
+	assert.Nil(err)
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		for itr := 0; itr < 3; itr++ {
+			if _, err := dbClient.DefineNewRecord(ctx, "", ulid.Make().String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	var records []models.Record
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		records, err = dbClient.ListRecords(ctx, db.RecordQueryFilter{})
+		return err
+	}))
+
+	// A custom query through the raw GORM instance sees the same data
+	var count int64
+	assert.Nil(uut.RawDB().WithContext(utCtx).Raw(
+		fmt.Sprintf("SELECT COUNT(*) FROM %s", db.RecordDBEntry{}.TableName()),
+	).Scan(&count).Error)
+	assert.Equal(int64(len(records)), count)
+	assert.Equal(int64(3), count)
+}
+
+func TestDBClientPing(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+	assert.Nil(uut.Ping(utCtx))
+
+	// The connection is down once closed
+	assert.Nil(uut.Close())
+	assert.Error(uut.Ping(utCtx))
+}
+
+func TestDBClientConnectionOptions(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	// Sqlite defaults to a single open connection
+	uut, err := db.NewConnectionWithOptions(
+		db.GetSqliteDialector(testDB), logger.Error, db.ConnectionOptions{},
+	)
+	assert.Nil(err)
+	sqlDB, err := uut.RawDB().DB()
+	assert.Nil(err)
+	assert.Equal(1, sqlDB.Stats().MaxOpenConnections)
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+	assert.Nil(uut.Close())
+
+	// Including without any connection pool settings
+	uut, err = db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+	sqlDB, err = uut.RawDB().DB()
+	assert.Nil(err)
+	assert.Equal(1, sqlDB.Stats().MaxOpenConnections)
+	assert.Nil(uut.Close())
+
+	// Explicit settings are applied
+	uut, err = db.NewConnectionWithOptions(
+		db.GetSqliteDialector(testDB), logger.Error, db.ConnectionOptions{
+			MaxOpenConns:    4,
+			MaxIdleConns:    2,
+			ConnMaxLifetime: time.Minute,
+			ConnMaxIdleTime: time.Second,
+		},
+	)
+	assert.Nil(err)
+	sqlDB, err = uut.RawDB().DB()
+	assert.Nil(err)
+	assert.Equal(4, sqlDB.Stats().MaxOpenConnections)
+	assert.Nil(uut.Ping(utCtx))
+	assert.Nil(uut.Close())
+}
+
+func TestDBClientAfterCommit(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	uut, err := db.NewConnection(db.GetInMemorySqliteDialector(), logger.Error)
+	assert.Nil(err)
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	// Callbacks run once the transaction commits
+	called := 0
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			dbClient.AfterCommit(func() { called++ })
+			_, err := dbClient.DefineNewRecord(ctx, "", ulid.Make().String())
+			assert.Equal(0, called)
+			return err
+		},
+	))
+	assert.Equal(1, called)
+
+	// Callbacks never run if the transaction rolls back
+	errRollback := fmt.Errorf("roll back")
+	assert.ErrorIs(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			dbClient.AfterCommit(func() { called++ })
+			return errRollback
+		},
+	), errRollback)
+	assert.Equal(1, called)
+
+	// Outside a transaction, callbacks run immediately
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		dbClient.AfterCommit(func() { called++ })
+		assert.Equal(2, called)
+		return nil
+	}))
+	assert.Nil(uut.Close())
+}
+
+func TestDBClientAsyncAudit(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	countEvents := func(uut db.Client) int {
+		var events []models.SystemEventAudit
+		assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+			var err error
+			events, err = dbClient.ListSystemEvents(ctx, db.SystemEventQueryFilter{
+				EventTypes: []models.SystemEventTypeENUMType{models.SystemEventTypeAddNewRecord},
+			})
+			return err
+		}))
+		return len(events)
+	}
+	defineRecords := func(uut db.Client, count int) {
+		assert.Nil(uut.UseDatabaseInTransaction(
+			utCtx, func(ctx context.Context, dbClient db.Database) error {
+				for itr := 0; itr < count; itr++ {
+					_, err := dbClient.DefineNewRecord(ctx, "", ulid.Make().String())
+					assert.Nil(err)
+				}
+				return nil
+			},
+		))
+	}
+
+	// Events are persisted in the background
+	uut, err := db.NewConnectionWithConfig(db.ConnectionConfig{
+		Dialector:  db.GetSqliteDialector(testDB),
+		LogLevel:   logger.Error,
+		Pool:       &db.ConnectionOptions{},
+		AsyncAudit: &db.AsyncAuditOptions{FlushInterval: 10 * time.Millisecond},
+	})
+	assert.Nil(err)
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	defineRecords(uut, 5)
+	assert.Eventually(func() bool {
+		return countEvents(uut) == 5
+	}, time.Second, 10*time.Millisecond)
+	assert.Nil(uut.Close())
+
+	// Flush makes buffered events visible
+	uut, err = db.NewConnectionWithConfig(db.ConnectionConfig{
+		Dialector: db.GetSqliteDialector(testDB),
+		LogLevel:  logger.Error,
+		Pool:      &db.ConnectionOptions{},
+		AsyncAudit: &db.AsyncAuditOptions{
+			FlushInterval: time.Hour, BatchSize: 1000,
+		},
+	})
+	assert.Nil(err)
+
+	defineRecords(uut, 3)
+	assert.Equal(5, countEvents(uut))
+	assert.Nil(uut.Flush(utCtx))
+	assert.Equal(8, countEvents(uut))
+
+	// Close drains the buffer
+	defineRecords(uut, 2)
+	assert.Nil(uut.Close())
+
+	uut, err = db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+	assert.Equal(10, countEvents(uut))
+
+	// Flush does nothing without asynchronous audit mode
+	assert.Nil(uut.Flush(utCtx))
+	assert.Nil(uut.Close())
+}
+
+func TestDBClientAsyncAuditRecovery(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	spoolFile := fmt.Sprintf("/tmp/haven_ut_%s.spool", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	listEvents := func(uut db.Client) []models.SystemEventAudit {
+		var events []models.SystemEventAudit
+		assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+			var err error
+			events, err = dbClient.ListSystemEvents(ctx, db.SystemEventQueryFilter{
+				EventTypes: []models.SystemEventTypeENUMType{models.SystemEventTypeAddNewRecord},
+			})
+			return err
+		}))
+		return events
+	}
+	defineRecord := func(ctx context.Context, uut db.Client) {
+		assert.Nil(uut.UseDatabaseInTransaction(
+			ctx, func(ctx context.Context, dbClient db.Database) error {
+				_, err := dbClient.DefineNewRecord(ctx, "", ulid.Make().String())
+				return err
+			},
+		))
+	}
+	asyncConfig := db.ConnectionConfig{
+		Dialector: db.GetSqliteDialector(testDB),
+		LogLevel:  logger.Error,
+		AsyncAudit: &db.AsyncAuditOptions{
+			FlushInterval: time.Hour, MaxRefusals: 2, SpoolFile: spoolFile,
+		},
+	}
+
+	crashed, err := db.NewConnectionWithConfig(asyncConfig)
+	assert.Nil(err)
+	assert.Nil(crashed.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	// Events keep the timestamp of when they were recorded
+	defineRecord(utCtx, crashed)
+	recordedBy := time.Now()
+	time.Sleep(time.Millisecond * 50)
+	assert.Nil(crashed.Flush(utCtx))
+	events := listEvents(crashed)
+	assert.Len(events, 1)
+	assert.False(events[0].CreatedAt.After(recordedBy))
+
+	// Events left in the spool file by a writer which never inserted them are inserted by
+	// the next writer
+	for itr := 0; itr < 3; itr++ {
+		defineRecord(utCtx, crashed)
+	}
+	uut, err := db.NewConnectionWithConfig(asyncConfig)
+	assert.Nil(err)
+	assert.Nil(uut.Flush(utCtx))
+	assert.Len(listEvents(uut), 4)
+	spooled, err := os.ReadFile(spoolFile)
+	assert.Nil(err)
+	assert.Empty(spooled)
+
+	// An event the DB refuses does not hold back the others, and is eventually dropped
+	assert.Nil(uut.RunSQLInTransaction(utCtx, func(ctx context.Context, tx *gorm.DB) error {
+		return tx.Exec(
+			`CREATE TRIGGER reject_actor BEFORE INSERT ON system_audit_events
+			WHEN NEW.actor = 'rejected' BEGIN SELECT RAISE(ABORT, 'rejected'); END`,
+		).Error
+	}))
+	defineRecord(utCtx, uut)
+	defineRecord(db.WithActor(utCtx, "rejected"), uut)
+	defineRecord(utCtx, uut)
+	assert.Error(uut.Flush(utCtx))
+	assert.Len(listEvents(uut), 6)
+	assert.Error(uut.Flush(utCtx))
+	assert.Nil(uut.Flush(utCtx))
+	assert.Len(listEvents(uut), 6)
+	spooled, err = os.ReadFile(spoolFile)
+	assert.Nil(err)
+	assert.Empty(spooled)
+	assert.Nil(uut.Close())
+
+	// Closing the other writer inserts its events again, which are skipped
+	assert.Nil(crashed.Close())
+	uut, err = db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+	assert.Len(listEvents(uut), 6)
+	assert.Nil(uut.Close())
+}
+
+// flakyDialector fails to initialize a set number of times before deferring to the
+// wrapped dialector
+type flakyDialector struct {
+	gorm.Dialector
+	failures int
+	attempts int
+}
+
+func (d *flakyDialector) Initialize(gormDB *gorm.DB) error {
+	d.attempts++
+	if d.attempts <= d.failures {
+		return fmt.Errorf("DB not yet reachable")
+	}
+	return d.Dialector.Initialize(gormDB)
+}
+
+func TestDBClientConnectionRetry(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// The connection is made once the DB becomes reachable
+	dialector := &flakyDialector{Dialector: db.GetInMemorySqliteDialector(), failures: 3}
+	uut, err := db.NewConnectionWithRetry(utCtx, dialector, logger.Error, time.Second*10)
+	assert.Nil(err)
+	assert.Equal(4, dialector.attempts)
+	assert.Nil(uut.Ping(utCtx))
+	assert.Nil(uut.Close())
+
+	// Give up once the deadline passes
+	dialector = &flakyDialector{Dialector: db.GetInMemorySqliteDialector(), failures: 1000}
+	_, err = db.NewConnectionWithRetry(utCtx, dialector, logger.Error, time.Millisecond*250)
+	assert.Error(err)
+	assert.Greater(dialector.attempts, 1)
+
+	// Give up once the context is cancelled
+	cancelCtx, cancel := context.WithTimeout(utCtx, time.Millisecond*250)
+	defer cancel()
+	dialector = &flakyDialector{Dialector: db.GetInMemorySqliteDialector(), failures: 1000}
+	_, err = db.NewConnectionWithRetry(cancelCtx, dialector, logger.Error, time.Minute)
+	assert.ErrorIs(err, context.DeadlineExceeded)
+}
@@ -13,16 +13,27 @@ RecordEncryptionKey record an encrypted symmetric encryption key
 
 	@param ctx context.Context - execution context
 	@param encKeyMaterial string - encrypted key material
+	@param rsaFingerprint string - fingerprint of the RSA public key which encrypted the
+	    key material
+	@param materialFingerprint string - fingerprint of the plain text key material
+	@param aeadType models.AEADTypeENUMType - the AEAD algorithm the key is used with
 	@returns the key entry
 */
 func (d *databaseImpl) RecordEncryptionKey(
-	_ context.Context, encKeyMaterial []byte,
+	ctx context.Context,
+	encKeyMaterial []byte,
+	rsaFingerprint string,
+	materialFingerprint string,
+	aeadType models.AEADTypeENUMType,
 ) (models.EncryptionKey, error) {
 	newEntry := EncryptionKeyDBEntry{
 		EncryptionKey: models.EncryptionKey{
-			ID:             uuid.NewString(),
-			EncKeyMaterial: encKeyMaterial,
-			State:          models.EncryptionKeyStateActive,
+			ID:                  uuid.NewString(),
+			EncKeyMaterial:      encKeyMaterial,
+			RSAFingerprint:      rsaFingerprint,
+			MaterialFingerprint: materialFingerprint,
+			AEADType:            aeadType,
+			State:               models.EncryptionKeyStateActive,
 		},
 	}
 
@@ -30,7 +41,7 @@ func (d *databaseImpl) RecordEncryptionKey(
 		return models.EncryptionKey{}, fmt.Errorf("new encryption key entry is invalid [%w]", err)
 	}
 
-	if tmp := d.db.Create(&newEntry); tmp.Error != nil {
+	if tmp := d.session(ctx).Create(&newEntry); tmp.Error != nil {
 		return models.EncryptionKey{}, fmt.Errorf(
 			"new encryption key entry insert failed [%w]", tmp.Error,
 		)
@@ -38,7 +49,9 @@ func (d *databaseImpl) RecordEncryptionKey(
 
 	// Record this event
 	if _, err := d.defineNewSystemEvent(
-		models.SystemEventTypeNewEncryptionKey, models.SystemEventEncKeyRelated{KeyID: newEntry.ID},
+		ctx,
+		models.SystemEventTypeNewEncryptionKey,
+		models.SystemEventEncKeyRelated{KeyID: newEntry.ID},
 	); err != nil {
 		return models.EncryptionKey{}, fmt.Errorf(
 			"failed to log add new encryption key audit event [%w]", err,
@@ -49,10 +62,12 @@ func (d *databaseImpl) RecordEncryptionKey(
 }
 
 // getEncryptionKey fetch one encryption key
-func (d *databaseImpl) getEncryptionKey(keyID string) (EncryptionKeyDBEntry, error) {
+func (d *databaseImpl) getEncryptionKey(
+	ctx context.Context, keyID string,
+) (EncryptionKeyDBEntry, error) {
 	var entry EncryptionKeyDBEntry
-	err := d.db.Where("id = ?", keyID).First(&entry).Error
-	return entry, err
+	err := d.session(ctx).Where("id = ?", keyID).First(&entry).Error
+	return entry, notFoundAs(err, ErrEncryptionKeyNotFound)
 }
 
 /*
@@ -63,9 +78,9 @@ GetEncryptionKey fetch one encryption key
 	@return key entry
 */
 func (d *databaseImpl) GetEncryptionKey(
-	_ context.Context, keyID string,
+	ctx context.Context, keyID string,
 ) (models.EncryptionKey, error) {
-	entry, err := d.getEncryptionKey(keyID)
+	entry, err := d.getEncryptionKey(ctx, keyID)
 	if err != nil {
 		return models.EncryptionKey{}, fmt.Errorf("failed to fetch encryption key %s [%w]", keyID, err)
 	}
@@ -80,22 +95,26 @@ ListEncryptionKeys list encryption keys
 	@return list of keys
 */
 func (d *databaseImpl) ListEncryptionKeys(
-	_ context.Context, filters EncryptionKeyQueryFilter,
+	ctx context.Context, filters EncryptionKeyQueryFilter,
 ) ([]models.EncryptionKey, error) {
-	query := d.db.Model(&EncryptionKeyDBEntry{})
+	query := d.session(ctx).Model(&EncryptionKeyDBEntry{})
 
 	if len(filters.TargetState) > 0 {
 		query = query.Where("state in ?", filters.TargetState)
 	}
-
-	if filters.Limit != nil {
-		query = query.Limit(*filters.Limit)
+	if filters.CreatedAfter != nil {
+		query = query.Where("created_at >= ?", *filters.CreatedAfter)
 	}
-	if filters.Offset != nil {
-		query = query.Offset(*filters.Offset)
+	if filters.CreatedBefore != nil {
+		query = query.Where("created_at <= ?", *filters.CreatedBefore)
 	}
 
-	query = query.Order("created_at desc")
+	query, err := d.applyListFilter(
+		ctx, query, &EncryptionKeyDBEntry{}, filters.CommonListEntryQueryFilter, "created_at", true,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key list filter [%w]", err)
+	}
 
 	var entries []EncryptionKeyDBEntry
 	if tmp := query.Find(&entries); tmp.Error != nil {
@@ -110,11 +129,39 @@ func (d *databaseImpl) ListEncryptionKeys(
 	return result, nil
 }
 
+/*
+CountEncryptionKeysByState count encryption keys in each state
+
+	@param ctx context.Context - execution context
+	@return number of keys per key state. States without keys are omitted.
+*/
+func (d *databaseImpl) CountEncryptionKeysByState(
+	ctx context.Context,
+) (map[models.EncryptionKeyStateENUMType]int64, error) {
+	var rows []struct {
+		State models.EncryptionKeyStateENUMType
+		Count int64
+	}
+	if tmp := d.session(ctx).
+		Model(&EncryptionKeyDBEntry{}).
+		Select("state, count(*) as count").
+		Group("state").
+		Scan(&rows); tmp.Error != nil {
+		return nil, fmt.Errorf("failed to count encryption keys by state [%w]", tmp.Error)
+	}
+
+	result := map[models.EncryptionKeyStateENUMType]int64{}
+	for _, row := range rows {
+		result[row.State] = row.Count
+	}
+	return result, nil
+}
+
 // updateEncKeyState update the encryption key entry state
 func (d *databaseImpl) updateEncKeyState(
-	keyID string, newState models.EncryptionKeyStateENUMType,
+	ctx context.Context, keyID string, newState models.EncryptionKeyStateENUMType,
 ) error {
-	entry, err := d.getEncryptionKey(keyID)
+	entry, err := d.getEncryptionKey(ctx, keyID)
 	if err != nil {
 		return fmt.Errorf("failed to fetch encryption key %s [%w]", keyID, err)
 	}
@@ -129,7 +176,7 @@ func (d *databaseImpl) updateEncKeyState(
 	}
 
 	entry.State = newState
-	if tmp := d.db.Updates(&entry); tmp.Error != nil {
+	if tmp := d.session(ctx).Updates(&entry); tmp.Error != nil {
 		return fmt.Errorf("encryption key state change update failed [%w]", err)
 	}
 
@@ -140,11 +187,13 @@ func (d *databaseImpl) updateEncKeyState(
 		systemEventType = models.SystemEventTypeActivateEncryptionKey
 	case models.EncryptionKeyStateInactive:
 		systemEventType = models.SystemEventTypeDeactivateEncryptionKey
+	case models.EncryptionKeyStateRetired:
+		systemEventType = models.SystemEventTypeRetireEncryptionKey
 	}
 
 	// Record this event
 	if _, err := d.defineNewSystemEvent(
-		systemEventType, models.SystemEventEncKeyRelated{KeyID: keyID},
+		ctx, systemEventType, models.SystemEventEncKeyRelated{KeyID: keyID},
 	); err != nil {
 		return fmt.Errorf(
 			"failed to log encryption key state change audit event [%w]", err,
@@ -160,8 +209,8 @@ MarkEncryptionKeyActive mark encryption key is active
 	@param ctx context.Context - execution context
 	@param keyID string - the encryption key ID
 */
-func (d *databaseImpl) MarkEncryptionKeyActive(_ context.Context, keyID string) error {
-	return d.updateEncKeyState(keyID, models.EncryptionKeyStateActive)
+func (d *databaseImpl) MarkEncryptionKeyActive(ctx context.Context, keyID string) error {
+	return d.updateEncKeyState(ctx, keyID, models.EncryptionKeyStateActive)
 }
 
 /*
@@ -170,8 +219,60 @@ MarkEncryptionKeyInactive mark encryption key is inactive
 	@param ctx context.Context - execution context
 	@param keyID string - the encryption key ID
 */
-func (d *databaseImpl) MarkEncryptionKeyInactive(_ context.Context, keyID string) error {
-	return d.updateEncKeyState(keyID, models.EncryptionKeyStateInactive)
+func (d *databaseImpl) MarkEncryptionKeyInactive(ctx context.Context, keyID string) error {
+	return d.updateEncKeyState(ctx, keyID, models.EncryptionKeyStateInactive)
+}
+
+/*
+MarkEncryptionKeyRetired mark encryption key is retired. Only an inactive key can be
+retired, and a retired key can not change state again.
+
+	@param ctx context.Context - execution context
+	@param keyID string - the encryption key ID
+*/
+func (d *databaseImpl) MarkEncryptionKeyRetired(ctx context.Context, keyID string) error {
+	return d.updateEncKeyState(ctx, keyID, models.EncryptionKeyStateRetired)
+}
+
+/*
+UpdateEncryptionKeyMaterial replace the encrypted key material of an encryption key
+
+	@param ctx context.Context - execution context
+	@param keyID string - the encryption key ID
+	@param encKeyMaterial []byte - new encrypted key material
+	@param rsaFingerprint string - fingerprint of the RSA public key which encrypted the
+	    new key material
+*/
+func (d *databaseImpl) UpdateEncryptionKeyMaterial(
+	ctx context.Context, keyID string, encKeyMaterial []byte, rsaFingerprint string,
+) error {
+	entry, err := d.getEncryptionKey(ctx, keyID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch encryption key %s [%w]", keyID, err)
+	}
+
+	entry.EncKeyMaterial = encKeyMaterial
+	entry.RSAFingerprint = rsaFingerprint
+	if err := d.validator.Struct(&entry); err != nil {
+		return fmt.Errorf("updated encryption key %s entry is invalid [%w]", keyID, err)
+	}
+
+	if tmp := d.session(ctx).Updates(&entry); tmp.Error != nil {
+		return fmt.Errorf("encryption key %s material update failed [%w]", keyID, tmp.Error)
+	}
+
+	// Record this event
+	if _, err := d.defineNewSystemEvent(
+		ctx,
+		models.SystemEventTypeRewrapEncryptionKey,
+		models.SystemEventEncKeyRelated{KeyID: keyID},
+	); err != nil {
+		return fmt.Errorf(
+			"failed to log encryption key material update audit event [%w]", err,
+		)
+	}
+
+	return nil
 }
 
 /*
@@ -180,19 +281,21 @@ DeleteEncryptionKey delete encryption key
 	@param ctx context.Context - execution context
 	@param keyID string - the encryption key ID
 */
-func (d *databaseImpl) DeleteEncryptionKey(_ context.Context, keyID string) error {
-	entry, err := d.getEncryptionKey(keyID)
+func (d *databaseImpl) DeleteEncryptionKey(ctx context.Context, keyID string) error {
+	entry, err := d.getEncryptionKey(ctx, keyID)
 	if err != nil {
 		return fmt.Errorf("failed to fetch encryption key %s [%w]", keyID, err)
 	}
 
-	if tmp := d.db.Delete(&entry); tmp.Error != nil {
+	if tmp := d.session(ctx).Delete(&entry); tmp.Error != nil {
 		return fmt.Errorf("failed to delete encryption key %s [%w]", keyID, err)
 	}
 
 	// Record this event
 	if _, err := d.defineNewSystemEvent(
-		models.SystemEventTypeDeleteEncryptionKey, models.SystemEventEncKeyRelated{KeyID: keyID},
+		ctx,
+		models.SystemEventTypeDeleteEncryptionKey,
+		models.SystemEventEncKeyRelated{KeyID: keyID},
 	); err != nil {
 		return fmt.Errorf(
 			"failed to log encryption key state change audit event [%w]", err,
@@ -201,3 +304,21 @@ func (d *databaseImpl) DeleteEncryptionKey(_ context.Context, keyID string) erro
 
 	return nil
 }
+
+/*
+RestoreEncryptionKey insert an encryption key entry from a backup as is
+
+	@param ctx context.Context - execution context
+	@param entry models.EncryptionKey - the backed up entry
+*/
+func (d *databaseImpl) RestoreEncryptionKey(ctx context.Context, entry models.EncryptionKey) error {
+	newEntry := EncryptionKeyDBEntry{EncryptionKey: entry}
+	if err := d.validator.Struct(&newEntry); err != nil {
+		return fmt.Errorf("backed up encryption key %s is invalid [%w]", entry.ID, err)
+	}
+
+	if tmp := d.session(ctx).Create(&newEntry); tmp.Error != nil {
+		return fmt.Errorf("encryption key %s restore failed [%w]", entry.ID, tmp.Error)
+	}
+	return nil
+}
@@ -2,8 +2,11 @@ package db_test
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/alwitt/haven/db"
 	"github.com/alwitt/haven/models"
@@ -50,7 +53,9 @@ func TestDBEncryptionKeyRecord(t *testing.T) {
 	var key1 models.EncryptionKey
 	keyMaterial1 := []byte(uuid.NewString())
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
-		ek, err := dbClient.RecordEncryptionKey(ctx, keyMaterial1)
+		ek, err := dbClient.RecordEncryptionKey(
+			ctx, keyMaterial1, "", "", models.AEADTypeXChaCha20Poly1305,
+		)
 		if err != nil {
 			return err
 		}
@@ -74,7 +79,9 @@ func TestDBEncryptionKeyRecord(t *testing.T) {
 	var key2 models.EncryptionKey
 	keyMaterial2 := []byte(uuid.NewString())
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
-		ek, err := dbClient.RecordEncryptionKey(ctx, keyMaterial2)
+		ek, err := dbClient.RecordEncryptionKey(
+			ctx, keyMaterial2, "", "", models.AEADTypeXChaCha20Poly1305,
+		)
 		if err != nil {
 			return err
 		}
@@ -196,7 +203,9 @@ func TestDBEncryptionKeyStateChange(t *testing.T) {
 	var key1 models.EncryptionKey
 	keyMaterial1 := []byte(uuid.NewString())
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
-		ek, err := dbClient.RecordEncryptionKey(ctx, keyMaterial1)
+		ek, err := dbClient.RecordEncryptionKey(
+			ctx, keyMaterial1, "", "", models.AEADTypeXChaCha20Poly1305,
+		)
 		if err != nil {
 			return err
 		}
@@ -345,7 +354,9 @@ func TestDBEncryptionKeyListing(t *testing.T) {
 	var key1 models.EncryptionKey
 	keyMaterial1 := []byte(uuid.NewString())
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
-		ek, err := dbClient.RecordEncryptionKey(ctx, keyMaterial1)
+		ek, err := dbClient.RecordEncryptionKey(
+			ctx, keyMaterial1, "", "", models.AEADTypeXChaCha20Poly1305,
+		)
 		if err != nil {
 			return err
 		}
@@ -371,7 +382,9 @@ func TestDBEncryptionKeyListing(t *testing.T) {
 	var key2 models.EncryptionKey
 	keyMaterial2 := []byte(uuid.NewString())
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
-		ek, err := dbClient.RecordEncryptionKey(ctx, keyMaterial2)
+		ek, err := dbClient.RecordEncryptionKey(
+			ctx, keyMaterial2, "", "", models.AEADTypeXChaCha20Poly1305,
+		)
 		if err != nil {
 			return err
 		}
@@ -397,7 +410,9 @@ func TestDBEncryptionKeyListing(t *testing.T) {
 	var key3 models.EncryptionKey
 	keyMaterial3 := []byte(uuid.NewString())
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
-		ek, err := dbClient.RecordEncryptionKey(ctx, keyMaterial3)
+		ek, err := dbClient.RecordEncryptionKey(
+			ctx, keyMaterial3, "", "", models.AEADTypeXChaCha20Poly1305,
+		)
 		if err != nil {
 			return err
 		}
@@ -518,3 +533,370 @@ func TestDBEncryptionKeyListing(t *testing.T) {
 	assert.Equal(3, newKeyEvents)
 	assert.Equal(1, deactivateEvents)
 }
+
+// TestDBEncryptionKeyUpdateMaterial verifies that `Database.UpdateEncryptionKeyMaterial`
+// replaces the stored key material and records an audit event.
+func TestDBEncryptionKeyUpdateMaterial(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// Create a unique temporary DB file for this test
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	// Create database tables
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	// Record test key
+	var key1 models.EncryptionKey
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			key1, err = dbClient.RecordEncryptionKey(
+				ctx,
+				[]byte(uuid.NewString()),
+				"fingerprint-1",
+				"",
+				models.AEADTypeXChaCha20Poly1305,
+			)
+			return err
+		},
+	))
+
+	// Replace the key material
+	newMaterial := []byte(uuid.NewString())
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			return dbClient.UpdateEncryptionKeyMaterial(ctx, key1.ID, newMaterial, "fingerprint-2")
+		},
+	))
+
+	// Verify the key material changed
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		ek, err := dbClient.GetEncryptionKey(ctx, key1.ID)
+		assert.Nil(err)
+		assert.Equal(newMaterial, ek.EncKeyMaterial)
+		assert.Equal("fingerprint-2", ek.RSAFingerprint)
+		assert.Equal(models.EncryptionKeyStateActive, ek.State)
+		return err
+	}))
+
+	// Unknown key
+	assert.Error(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			return dbClient.UpdateEncryptionKeyMaterial(ctx, uuid.NewString(), newMaterial, "")
+		},
+	))
+
+	// Verify the audit event
+	validate := validator.New()
+	assert.Nil(models.RegisterWithValidator(validate))
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		events, err := dbClient.ListSystemEvents(ctx, db.SystemEventQueryFilter{
+			EventTypes: []models.SystemEventTypeENUMType{models.SystemEventTypeRewrapEncryptionKey},
+		})
+		assert.Nil(err)
+		assert.Len(events, 1)
+		meta, err := events[0].ParseMetadata(validate)
+		assert.Nil(err)
+		keyMeta, ok := meta.(models.SystemEventEncKeyRelated)
+		assert.True(ok)
+		assert.Equal(key1.ID, keyMeta.KeyID)
+		return err
+	}))
+}
+
+// TestDBEncryptionKeyCountByState verifies `Database.CountEncryptionKeysByState` with keys in
+// both states.
+func TestDBEncryptionKeyCountByState(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// Create a unique temporary DB file for this test
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	// Create database tables
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	// No keys
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		counts, err := dbClient.CountEncryptionKeysByState(ctx)
+		assert.Nil(err)
+		assert.Empty(counts)
+		return err
+	}))
+
+	// Define three keys, and deactivate two of them
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			for itr := 0; itr < 3; itr++ {
+				key, err := dbClient.RecordEncryptionKey(
+					ctx, []byte(uuid.NewString()), "", "", models.AEADTypeXChaCha20Poly1305,
+				)
+				assert.Nil(err)
+				if itr > 0 {
+					assert.Nil(dbClient.MarkEncryptionKeyInactive(ctx, key.ID))
+				}
+			}
+			return nil
+		},
+	))
+
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		counts, err := dbClient.CountEncryptionKeysByState(ctx)
+		assert.Nil(err)
+		assert.Equal(map[models.EncryptionKeyStateENUMType]int64{
+			models.EncryptionKeyStateActive:   1,
+			models.EncryptionKeyStateInactive: 2,
+		}, counts)
+		return err
+	}))
+}
+
+// TestDBEncryptionKeyMaterialFingerprint verifies that recording the same key material
+// twice is rejected by the key material fingerprint.
+func TestDBEncryptionKeyMaterialFingerprint(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// Create a unique temporary DB file for this test
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	// Create database tables
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	recordKey := func(materialFingerprint string) error {
+		return uut.UseDatabaseInTransaction(
+			utCtx, func(ctx context.Context, dbClient db.Database) error {
+				_, err := dbClient.RecordEncryptionKey(
+					ctx,
+					[]byte(uuid.NewString()),
+					"",
+					materialFingerprint,
+					models.AEADTypeXChaCha20Poly1305,
+				)
+				return err
+			},
+		)
+	}
+
+	// Import the same raw key twice
+	digest := sha256.Sum256([]byte(uuid.NewString()))
+	fingerprint := hex.EncodeToString(digest[:])
+	assert.Nil(recordKey(fingerprint))
+	assert.Error(recordKey(fingerprint))
+
+	// Keys without a fingerprint do not collide
+	assert.Nil(recordKey(""))
+	assert.Nil(recordKey(""))
+
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		keys, err := dbClient.ListEncryptionKeys(ctx, db.EncryptionKeyQueryFilter{})
+		assert.Nil(err)
+		assert.Len(keys, 3)
+		fingerprinted := 0
+		for _, key := range keys {
+			if key.MaterialFingerprint != "" {
+				assert.Equal(fingerprint, key.MaterialFingerprint)
+				fingerprinted++
+			}
+		}
+		assert.Equal(1, fingerprinted)
+		return err
+	}))
+}
+
+// TestDBEncryptionKeyListingCreatedWindow verifies listing encryption keys created within a
+// time window.
+func TestDBEncryptionKeyListingCreatedWindow(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// Create a unique temporary DB file for this test
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	// Create database tables
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	// Define keys created a day apart
+	baseTime := time.Now().UTC().Add(-time.Hour * 24 * 7)
+	timestamps := []time.Time{}
+	keyIDs := []string{}
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			for idx := 0; idx < 4; idx++ {
+				key, err := dbClient.RecordEncryptionKey(
+					ctx, []byte(uuid.NewString()), "", "", models.AEADTypeXChaCha20Poly1305,
+				)
+				assert.Nil(err)
+				keyIDs = append(keyIDs, key.ID)
+			}
+			return nil
+		},
+	))
+	for idx, keyID := range keyIDs {
+		timestamp := baseTime.Add(time.Duration(idx) * time.Hour * 24)
+		assert.Nil(uut.RawDB().WithContext(utCtx).
+			Model(&db.EncryptionKeyDBEntry{}).
+			Where("id = ?", keyID).
+			Update("created_at", timestamp).Error)
+		timestamps = append(timestamps, timestamp)
+	}
+
+	hour := time.Hour
+	afterStart := timestamps[0].Add(hour)
+	beforeEnd := timestamps[3].Add(-hour)
+	testCases := []struct {
+		after    *time.Time
+		before   *time.Time
+		expected []string
+	}{
+		{after: &timestamps[2], expected: keyIDs[2:]},
+		{before: &timestamps[1], expected: keyIDs[:2]},
+		{after: &timestamps[1], before: &timestamps[1], expected: keyIDs[1:2]},
+		{after: &afterStart, before: &beforeEnd, expected: keyIDs[1:3]},
+		{after: &timestamps[0], before: &timestamps[3], expected: keyIDs},
+	}
+
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		for _, testCase := range testCases {
+			keys, err := dbClient.ListEncryptionKeys(ctx, db.EncryptionKeyQueryFilter{
+				CreatedAfter:  testCase.after,
+				CreatedBefore: testCase.before,
+			})
+			assert.Nil(err)
+			// Keys are listed newest first
+			listed := []string{}
+			for idx := len(keys) - 1; idx >= 0; idx-- {
+				listed = append(listed, keys[idx].ID)
+			}
+			assert.Equal(testCase.expected, listed)
+		}
+		return nil
+	}))
+}
+
+// TestDBEncryptionKeyRetire verifies that only an inactive encryption key can be retired,
+// that retirement is terminal, and that a retired key can not encrypt new versions.
+func TestDBEncryptionKeyRetire(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// Create a unique temporary DB file for this test
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	// Create database tables
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	var key1 models.EncryptionKey
+	var record1 models.Record
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			key1, err = dbClient.RecordEncryptionKey(
+				ctx, []byte(uuid.NewString()), "", "", models.AEADTypeXChaCha20Poly1305,
+			)
+			assert.Nil(err)
+			record1, err = dbClient.DefineNewRecord(ctx, "", uuid.NewString())
+			return err
+		},
+	))
+
+	changeState := func(change func(ctx context.Context, dbClient db.Database) error) error {
+		return uut.UseDatabaseInTransaction(utCtx, change)
+	}
+	keyState := func() models.EncryptionKeyStateENUMType {
+		var state models.EncryptionKeyStateENUMType
+		assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+			key, err := dbClient.GetEncryptionKey(ctx, key1.ID)
+			state = key.State
+			return err
+		}))
+		return state
+	}
+	retire := func(ctx context.Context, dbClient db.Database) error {
+		return dbClient.MarkEncryptionKeyRetired(ctx, key1.ID)
+	}
+
+	// An active key can not be retired
+	assert.Error(changeState(retire))
+	assert.Equal(models.EncryptionKeyStateActive, keyState())
+
+	// An inactive key can be retired
+	assert.Nil(changeState(func(ctx context.Context, dbClient db.Database) error {
+		return dbClient.MarkEncryptionKeyInactive(ctx, key1.ID)
+	}))
+	assert.Nil(changeState(retire))
+	assert.Equal(models.EncryptionKeyStateRetired, keyState())
+
+	// Retiring again is a NOOP
+	assert.Nil(changeState(retire))
+
+	// A retired key can not change state again
+	assert.Error(changeState(func(ctx context.Context, dbClient db.Database) error {
+		return dbClient.MarkEncryptionKeyActive(ctx, key1.ID)
+	}))
+	assert.Error(changeState(func(ctx context.Context, dbClient db.Database) error {
+		return dbClient.MarkEncryptionKeyInactive(ctx, key1.ID)
+	}))
+	assert.Equal(models.EncryptionKeyStateRetired, keyState())
+
+	// A retired key can not encrypt new versions, even through a stale copy of the key
+	err = changeState(func(ctx context.Context, dbClient db.Database) error {
+		_, err := dbClient.DefineNewVersionForRecord(
+			ctx,
+			record1,
+			key1,
+			[]byte(uuid.NewString()),
+			[]byte(uuid.NewString()),
+			time.Now().UTC(),
+			db.NewVersionOptions{},
+		)
+		return err
+	})
+	assert.ErrorIs(err, db.ErrEncryptionKeyRetired)
+
+	// The retirement is audited
+	validate := validator.New()
+	assert.Nil(models.RegisterWithValidator(validate))
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		events, err := dbClient.ListSystemEvents(ctx, db.SystemEventQueryFilter{
+			EventTypes: []models.SystemEventTypeENUMType{models.SystemEventTypeRetireEncryptionKey},
+		})
+		assert.Nil(err)
+		assert.Len(events, 1)
+		for _, event := range events {
+			metadata, err := event.ParseMetadata(validate)
+			assert.Nil(err)
+			assert.Equal(models.SystemEventEncKeyRelated{KeyID: key1.ID}, metadata)
+		}
+		return err
+	}))
+}
@@ -2,7 +2,10 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/alwitt/goutils"
@@ -13,9 +16,15 @@ import (
 )
 
 // CommonListEntryQueryFilter common query filter when listing data entries
+//
+// AfterID and Offset are mutually exclusive. AfterID pages through the entries by
+// returning only the entries which follow the entry with that ID in the listing order,
+// which avoids the OFFSET scans on large tables.
 type CommonListEntryQueryFilter struct {
 	Limit  *int
 	Offset *int
+	// AfterID return only entries after this entry ID in the listing order
+	AfterID *string
 }
 
 // SystemEventQueryFilter audit event query filter conditions
@@ -23,6 +32,8 @@ type SystemEventQueryFilter struct {
 	CommonListEntryQueryFilter
 	// EventTypes the specific event types to query for
 	EventTypes []models.SystemEventTypeENUMType
+	// Actors the specific actors to query for
+	Actors []string
 	// EventsAfter filter for events after this timestamp
 	EventsAfter *time.Time
 	// EventsBefore filter for events before this timestamp
@@ -34,24 +45,144 @@ type EncryptionKeyQueryFilter struct {
 	CommonListEntryQueryFilter
 	// TargetState the specific states to query for
 	TargetState []models.EncryptionKeyStateENUMType
+	// CreatedAfter fetch only keys created at or after this timestamp
+	CreatedAfter *time.Time
+	// CreatedBefore fetch only keys created at or before this timestamp
+	CreatedBefore *time.Time
 }
 
+// SortByENUMType list query sort column ENUM
+type SortByENUMType string
+
+const (
+	// SortByCreatedAt sort by entry creation timestamp
+	SortByCreatedAt SortByENUMType = "created_at"
+	// SortByUpdatedAt sort by entry update timestamp
+	SortByUpdatedAt SortByENUMType = "updated_at"
+	// SortByName sort by entry name
+	SortByName SortByENUMType = "name"
+)
+
 // RecordQueryFilter data record query filter conditions
 type RecordQueryFilter struct {
 	CommonListEntryQueryFilter
+	// State the specific states to query for. Only active records are listed if empty.
+	State []models.RecordStateENUMType
+	// Namespace fetch only records of this namespace. Nil to fetch records of every
+	// namespace.
+	Namespace *string
+	// NamePrefix fetch only records whose name starts with this prefix
+	NamePrefix string
+	// MetadataFilter fetch only records whose metadata holds every one of these key value
+	// pairs
+	MetadataFilter map[string]string
+	// SortBy the column to sort by: created_at (default), updated_at, or name
+	SortBy SortByENUMType
+	// SortAscending whether to sort in ascending order. Defaults to descending.
+	SortAscending bool
 }
 
 // RecordVersionQueryFilter data record version query filter conditions
+//
+// AfterVersionID pages through the versions in the order they were committed, ignoring
+// the sort parameters. The transaction recording a version assigns it the next version
+// sequence, which stays locked until the transaction ends, so no version can commit behind
+// one already read. Paging thus sees every version committed before or while it runs
+// exactly once. The cursor version must still exist; once deleted, paging from it fails
+// with ErrVersionNotFound. AfterVersionID is mutually exclusive with AfterID and Offset.
 type RecordVersionQueryFilter struct {
 	CommonListEntryQueryFilter
+	// AfterVersionID return only versions committed after this version. Empty to start
+	// from the first version.
+	AfterVersionID *string
 	// TargetRecordID fetch only record versions related to this record
 	TargetRecordID *string
 	// TargetEncKeyID fetch versions related to this encryption key
 	TargetEncKeyID *string
+	// CreatedAfter fetch only versions created at or after this timestamp
+	CreatedAfter *time.Time
+	// CreatedBefore fetch only versions created at or before this timestamp
+	CreatedBefore *time.Time
+	// HasNote fetch only versions with a note
+	HasNote bool
+	// SortBy the column to sort by: created_at (default), or updated_at
+	SortBy SortByENUMType
+	// SortAscending whether to sort in ascending order. Defaults to descending.
+	SortAscending bool
+}
+
+// RecordWithVersionStats a data record, with statistics of its versions
+type RecordWithVersionStats struct {
+	models.Record
+	// LatestVersionAt creation timestamp of the newest version. Nil if the record has no
+	// versions.
+	LatestVersionAt *time.Time
+	// VersionCount number of versions of the record
+	VersionCount int64
+}
+
+// VersionWithName a data record version, with the name of its data record attached
+type VersionWithName struct {
+	models.RecordVersion
+	// Namespace the namespace of the data record
+	Namespace string
+	// Name the name of the data record
+	Name string
+}
+
+// NewVersionOptions optional parameters of a new data record version
+type NewVersionOptions struct {
+	// ExpiresAt when the version expires. Nil if it does not expire.
+	ExpiresAt *time.Time
+	// AADBound whether the encrypted data is bound to the record ID as associated data
+	AADBound bool
+	// KeyDerivation how the AEAD key which encrypted the data is derived from the
+	// encryption key. Defaults to KeyDerivationNone.
+	KeyDerivation models.KeyDerivationENUMType
+	// Note plain text reason for the change. Empty for none.
+	Note string
 }
 
+// ErrSessionClosed the `Database` handle was used after its session ended
+var ErrSessionClosed = errors.New("database session is closed")
+
+// ErrRecordNotFound the data record does not exist
+var ErrRecordNotFound = errors.New("data record not found")
+
+// ErrVersionNotFound the data record version does not exist
+var ErrVersionNotFound = errors.New("data record version not found")
+
+// ErrEncryptionKeyNotFound the encryption key does not exist
+var ErrEncryptionKeyNotFound = errors.New("encryption key not found")
+
+// ErrEncryptionKeyRetired the encryption key is retired, so it can not encrypt new data
+var ErrEncryptionKeyRetired = errors.New("encryption key is retired")
+
+// ErrDuplicateRecordName another data record already uses the name
+var ErrDuplicateRecordName = errors.New("data record name already in use")
+
 // Database the database handle to interacting with the data base
+//
+// A handle is only valid within the `Client` callback which provided it. Once the
+// callback returns (and its transaction commits or rolls back), all calls fail with
+// ErrSessionClosed.
+//
+// Lookups of missing entries fail with ErrRecordNotFound, ErrVersionNotFound, or
+// ErrEncryptionKeyNotFound, and defining a data record with a name already in use fails
+// with ErrDuplicateRecordName.
 type Database interface {
+	// ------------------------------------------------------------------------------------
+	// Session
+
+	/*
+		AfterCommit call fn once the transaction of this session commits. fn is never called
+		if the transaction rolls back. Outside a transaction, every query commits on its
+		own, so fn is called immediately.
+
+			@param fn func() - the callback
+	*/
+	AfterCommit(fn func())
+
 	// ------------------------------------------------------------------------------------
 	// System audit events
 
@@ -77,6 +208,19 @@ type Database interface {
 	*/
 	GetSystemParamEntry(ctx context.Context) (models.SystemParams, error)
 
+	/*
+		LockSystemParams lock the global singleton system parameter entry until the
+		transaction ends, defining the entry if it does not exist. Transactions which lock
+		the entry run one at a time.
+
+		On Sqlite, which does not lock rows, the whole database is locked for writing
+		instead. To hold the lock before any concurrent writer, call this before anything
+		else in the transaction.
+
+			@param ctx context.Context - execution context
+	*/
+	LockSystemParams(ctx context.Context) error
+
 	/*
 		MarkSystemInitializing mark system is initializing
 
@@ -91,6 +235,22 @@ type Database interface {
 	*/
 	MarkSystemInitialized(ctx context.Context) error
 
+	/*
+		GetSystemStateHistory fetch the system state transitions, oldest first
+
+			@param ctx context.Context - execution context
+			@returns the state transitions
+	*/
+	GetSystemStateHistory(ctx context.Context) ([]models.SystemStateEvent, error)
+
+	/*
+		RestoreSystemParams replace the system parameter entry with one from a backup
+
+			@param ctx context.Context - execution context
+			@param entry models.SystemParams - the backed up entry
+	*/
+	RestoreSystemParams(ctx context.Context, entry models.SystemParams) error
+
 	// ------------------------------------------------------------------------------------
 	// Encryption keys
 
@@ -99,9 +259,19 @@ type Database interface {
 
 			@param ctx context.Context - execution context
 			@param encKeyMaterial string - encrypted key material
+			@param rsaFingerprint string - fingerprint of the RSA public key which encrypted the
+			    key material
+			@param materialFingerprint string - fingerprint of the plain text key material
+			@param aeadType models.AEADTypeENUMType - the AEAD algorithm the key is used with
 			@returns the key entry
 	*/
-	RecordEncryptionKey(ctx context.Context, encKeyMaterial []byte) (models.EncryptionKey, error)
+	RecordEncryptionKey(
+		ctx context.Context,
+		encKeyMaterial []byte,
+		rsaFingerprint string,
+		materialFingerprint string,
+		aeadType models.AEADTypeENUMType,
+	) (models.EncryptionKey, error)
 
 	/*
 		GetEncryptionKey fetch one encryption key
@@ -123,6 +293,16 @@ type Database interface {
 		ctx context.Context, filters EncryptionKeyQueryFilter,
 	) ([]models.EncryptionKey, error)
 
+	/*
+		CountEncryptionKeysByState count encryption keys in each state
+
+			@param ctx context.Context - execution context
+			@return number of keys per key state. States without keys are omitted.
+	*/
+	CountEncryptionKeysByState(
+		ctx context.Context,
+	) (map[models.EncryptionKeyStateENUMType]int64, error)
+
 	/*
 		MarkEncryptionKeyActive mark encryption key is active
 
@@ -139,6 +319,28 @@ type Database interface {
 	*/
 	MarkEncryptionKeyInactive(ctx context.Context, keyID string) error
 
+	/*
+		MarkEncryptionKeyRetired mark encryption key is retired. Only an inactive key can be
+		retired, and a retired key can not change state again.
+
+			@param ctx context.Context - execution context
+			@param keyID string - the encryption key ID
+	*/
+	MarkEncryptionKeyRetired(ctx context.Context, keyID string) error
+
+	/*
+		UpdateEncryptionKeyMaterial replace the encrypted key material of an encryption key
+
+			@param ctx context.Context - execution context
+			@param keyID string - the encryption key ID
+			@param encKeyMaterial []byte - new encrypted key material
+			@param rsaFingerprint string - fingerprint of the RSA public key which encrypted the
+			    new key material
+	*/
+	UpdateEncryptionKeyMaterial(
+		ctx context.Context, keyID string, encKeyMaterial []byte, rsaFingerprint string,
+	) error
+
 	/*
 		DeleteEncryptionKey delete encryption key
 
@@ -147,6 +349,14 @@ type Database interface {
 	*/
 	DeleteEncryptionKey(ctx context.Context, keyID string) error
 
+	/*
+		RestoreEncryptionKey insert an encryption key entry from a backup as is
+
+			@param ctx context.Context - execution context
+			@param entry models.EncryptionKey - the backed up entry
+	*/
+	RestoreEncryptionKey(ctx context.Context, entry models.EncryptionKey) error
+
 	// ------------------------------------------------------------------------------------
 	// Data records
 
@@ -154,10 +364,11 @@ type Database interface {
 		DefineNewRecord define new data record
 
 			@param ctx context.Context - execution context
+			@param namespace string - record namespace. Empty for the default namespace.
 			@param name string - record name
 			@returns record entry
 	*/
-	DefineNewRecord(ctx context.Context, name string) (models.Record, error)
+	DefineNewRecord(ctx context.Context, namespace string, name string) (models.Record, error)
 
 	/*
 		GetRecord fetch a data record by ID
@@ -174,13 +385,27 @@ type Database interface {
 		GetRecordByName fetch a data record by name
 
 			@param ctx context.Context - execution context
+			@param namespace string - data record namespace. Empty for the default namespace.
 			@param recordName string - data record name
 			@returns record entry
 	*/
 	GetRecordByName(
-		ctx context.Context, recordName string,
+		ctx context.Context, namespace string, recordName string,
 	) (models.Record, error)
 
+	/*
+		GetRecordsByName fetch the data records with the given names. Names without a data
+		record are skipped.
+
+			@param ctx context.Context - execution context
+			@param namespace string - data record namespace. Empty for the default namespace.
+			@param recordNames []string - data record names
+			@returns the record entries found
+	*/
+	GetRecordsByName(
+		ctx context.Context, namespace string, recordNames []string,
+	) ([]models.Record, error)
+
 	/*
 		ListRecords list data records
 
@@ -192,6 +417,46 @@ type Database interface {
 		ctx context.Context, filters RecordQueryFilter,
 	) ([]models.Record, error)
 
+	/*
+		CountRecords count data records
+
+		The pagination and ordering parameters of the filter are ignored.
+
+			@param ctx context.Context - execution context
+			@param filters RecordQueryFilter - entry listing filter
+			@return number of records
+	*/
+	CountRecords(ctx context.Context, filters RecordQueryFilter) (int64, error)
+
+	/*
+		ListRecordsWithVersionStats list data records, each with the creation timestamp of
+		its newest version and its number of versions, read in a single query
+
+			@param ctx context.Context - execution context
+			@param filters RecordQueryFilter - entry listing filter
+			@return list of records with their version statistics
+	*/
+	ListRecordsWithVersionStats(
+		ctx context.Context, filters RecordQueryFilter,
+	) ([]RecordWithVersionStats, error)
+
+	/*
+		ScanRecords call handler with each data record matching the filter, in name order.
+		The records are read in pages of bounded size, so they are never all held in
+		memory. The pagination and ordering parameters of the filter are ignored.
+
+		Each page is fully read before handler is called with its records, so handler may
+		run other queries on the same database session. An error from handler stops the
+		scan.
+
+			@param ctx context.Context - execution context
+			@param filters RecordQueryFilter - entry listing filter
+			@param handler func(record models.Record) error - called with each record
+	*/
+	ScanRecords(
+		ctx context.Context, filters RecordQueryFilter, handler func(record models.Record) error,
+	) error
+
 	/*
 		DeleteRecord delete a data record
 
@@ -200,11 +465,99 @@ type Database interface {
 	*/
 	DeleteRecord(ctx context.Context, recordID string) error
 
+	/*
+		SetRecordsState change the state of a set of data records
+
+			@param ctx context.Context - execution context
+			@param recordIDs []string - data record IDs
+			@param newState models.RecordStateENUMType - the new state
+	*/
+	SetRecordsState(
+		ctx context.Context, recordIDs []string, newState models.RecordStateENUMType,
+	) error
+
+	/*
+		FindDuplicateRecordNames find record names which are shared by more than one data
+		record of the same namespace. This is meant to support data repair.
+
+			@param ctx context.Context - execution context
+			@return qualified record names mapped to the IDs of the data records sharing that
+			    name
+	*/
+	FindDuplicateRecordNames(ctx context.Context) (map[string][]string, error)
+
+	/*
+		SetRecordMetadata replace the metadata of a data record
+
+			@param ctx context.Context - execution context
+			@param recordID string - the data record ID
+			@param metadata map[string]string - the new metadata. Empty to clear the metadata.
+	*/
+	SetRecordMetadata(ctx context.Context, recordID string, metadata map[string]string) error
+
+	/*
+		RenameRecord change the name of a data record. The record keeps its ID, namespace,
+		and versions.
+
+			@param ctx context.Context - execution context
+			@param recordID string - the data record ID
+			@param newName string - the new record name. ErrDuplicateRecordName if another
+			    record of the namespace already uses it.
+			@returns the renamed record entry
+	*/
+	RenameRecord(ctx context.Context, recordID string, newName string) (models.Record, error)
+
+	/*
+		GetRecordMetadata read the metadata of a data record
+
+			@param ctx context.Context - execution context
+			@param recordID string - the data record ID
+			@return the metadata. Empty if the record has none.
+	*/
+	GetRecordMetadata(ctx context.Context, recordID string) (map[string]string, error)
+
+	/*
+		MarkRecordRead record when a value of the data record was read
+
+			@param ctx context.Context - execution context
+			@param recordID string - the data record ID
+			@param readAt time.Time - when the value was read
+	*/
+	MarkRecordRead(ctx context.Context, recordID string, readAt time.Time) error
+
+	/*
+		ListRecordsNotReadSince list data records which have not been read since the cutoff,
+		including records which were never read, oldest read first
+
+			@param ctx context.Context - execution context
+			@param cutoff time.Time - the cutoff
+			@return list of records
+	*/
+	ListRecordsNotReadSince(ctx context.Context, cutoff time.Time) ([]models.Record, error)
+
+	/*
+		ListRecordsWithNoVersions list data records which have no versions, such as after all
+		their versions were pruned. Expired versions which are not yet purged still count.
+
+			@param ctx context.Context - execution context
+			@return list of records, oldest first
+	*/
+	ListRecordsWithNoVersions(ctx context.Context) ([]models.Record, error)
+
+	/*
+		RestoreRecord insert a data record entry from a backup as is
+
+			@param ctx context.Context - execution context
+			@param entry models.Record - the backed up entry
+	*/
+	RestoreRecord(ctx context.Context, entry models.Record) error
+
 	// ------------------------------------------------------------------------------------
 	// Data record versions
 
 	/*
-		DefineNewVersionForRecord define new data record version
+		DefineNewVersionForRecord define new data record version. Transactions recording
+		versions run one at a time, as each holds the version sequence until it ends.
 
 			@param ctx context.Context - execution context
 			@param record models.Record - the parent data record
@@ -213,6 +566,7 @@ type Database interface {
 			@param value []byte - the encrypted data of this record version
 			@param nonce []byte - the encryption nonce
 			@param timestamp time.Time - the timestamp of the version
+			@param opts NewVersionOptions - optional parameters of the version
 			@returns record version entry
 	*/
 	DefineNewVersionForRecord(
@@ -222,6 +576,29 @@ type Database interface {
 		value []byte,
 		nonce []byte,
 		timestamp time.Time,
+		opts NewVersionOptions,
+	) (models.RecordVersion, error)
+
+	/*
+		ReencryptRecordVersion replace the encrypted data of a data record version with the
+		same data encrypted with another encryption key
+
+			@param ctx context.Context - execution context
+			@param versionID string - data record version ID
+			@param encKey models.EncryptionKey - the encryption key that encrypted the new data
+			@param value []byte - the encrypted data of this record version
+			@param nonce []byte - the encryption nonce
+			@param keyDerivation models.KeyDerivationENUMType - how the AEAD key which encrypted
+			    the new data is derived from the encryption key
+			@returns updated record version entry
+	*/
+	ReencryptRecordVersion(
+		ctx context.Context,
+		versionID string,
+		encKey models.EncryptionKey,
+		value []byte,
+		nonce []byte,
+		keyDerivation models.KeyDerivationENUMType,
 	) (models.RecordVersion, error)
 
 	/*
@@ -246,6 +623,30 @@ type Database interface {
 		ctx context.Context, filters RecordVersionQueryFilter,
 	) ([]models.RecordVersion, error)
 
+	/*
+		ListGlobalVersionTimeline list data record versions across all data records, newest
+		first, with the names of their data records attached. The sort parameters of the
+		filter are ignored, and AfterVersionID is not supported.
+
+			@param ctx context.Context - execution context
+			@param filters RecordVersionQueryFilter - entry listing filter
+			@return list of record versions with their record names
+	*/
+	ListGlobalVersionTimeline(
+		ctx context.Context, filters RecordVersionQueryFilter,
+	) ([]VersionWithName, error)
+
+	/*
+		CountRecordVersions count data record versions
+
+		The pagination and ordering parameters of the filter are ignored.
+
+			@param ctx context.Context - execution context
+			@param filters RecordVersionQueryFilter - entry listing filter
+			@return number of record versions
+	*/
+	CountRecordVersions(ctx context.Context, filters RecordVersionQueryFilter) (int64, error)
+
 	/*
 		ListVersionsOfOneRecord list data record versions of a specific record
 
@@ -258,6 +659,57 @@ type Database interface {
 		ctx context.Context, record models.Record, filters RecordVersionQueryFilter,
 	) ([]models.RecordVersion, error)
 
+	/*
+		GetLatestVersionsOfRecords fetch the newest version of each of the data records.
+		Records without versions are skipped.
+
+			@param ctx context.Context - execution context
+			@param recordIDs []string - data record IDs
+			@return the newest version of each record, by record ID
+	*/
+	GetLatestVersionsOfRecords(
+		ctx context.Context, recordIDs []string,
+	) (map[string]models.RecordVersion, error)
+
+	/*
+		GetLatestVersionKey fetch the encryption key of the newest version of a data record
+
+			@param ctx context.Context - execution context
+			@param recordID string - data record ID
+			@return the encryption key. ErrVersionNotFound if the record has no versions.
+	*/
+	GetLatestVersionKey(ctx context.Context, recordID string) (models.EncryptionKey, error)
+
+	/*
+		ListRecordsOnInactiveKeys list the data records, of every namespace and state, whose
+		newest version is encrypted with an encryption key which is no longer active. These
+		values are at risk should the key be deleted, so should be re-encrypted.
+
+			@param ctx context.Context - execution context
+			@return the data records, ordered by namespace then name
+	*/
+	ListRecordsOnInactiveKeys(ctx context.Context) ([]models.Record, error)
+
+	/*
+		GetNthVersionOfRecord fetch one version of a specific record by its position in the
+		order the versions were created
+
+		The pagination and ordering parameters of the filter are ignored.
+
+			@param ctx context.Context - execution context
+			@param record models.Record - parent data record
+			@param index int - position of the version, starting from 0 for the oldest version.
+			    A negative index counts from the newest version, which is -1.
+			@param filters RecordVersionQueryFilter - entry listing filter
+			@return the record version. ErrVersionNotFound if the index is out of range.
+	*/
+	GetNthVersionOfRecord(
+		ctx context.Context,
+		record models.Record,
+		index int,
+		filters RecordVersionQueryFilter,
+	) (models.RecordVersion, error)
+
 	/*
 		ListVersionsEncryptedByKey list data record versions encrypted with a specific
 		encryption key
@@ -270,6 +722,53 @@ type Database interface {
 	ListVersionsEncryptedByKey(
 		ctx context.Context, encKey models.EncryptionKey, filters RecordVersionQueryFilter,
 	) ([]models.RecordVersion, error)
+
+	/*
+		SumRecordVersionSizes total size of the encrypted data of the versions of a data record
+
+			@param ctx context.Context - execution context
+			@param recordID string - data record ID
+			@return total size in bytes
+	*/
+	SumRecordVersionSizes(ctx context.Context, recordID string) (int64, error)
+
+	/*
+		DeleteRecordVersion delete a data record version
+
+			@param ctx context.Context - execution context
+			@param versionID string - data record version ID
+	*/
+	DeleteRecordVersion(ctx context.Context, versionID string) error
+
+	/*
+		FindVersionsWithoutCreationEvent list data record versions which have no version
+		creation audit event. The result is not proof of tampering, as some versions
+		legitimately have none: versions restored through RestoreRecordVersion (the audit log
+		is not part of the backup), versions recorded before version creation events were
+		introduced, and versions whose event is still buffered by asynchronous auditing.
+
+			@param ctx context.Context - execution context
+			@return IDs of the versions, in the order they were recorded
+	*/
+	FindVersionsWithoutCreationEvent(ctx context.Context) ([]string, error)
+
+	/*
+		PurgeExpiredVersions delete data record versions which have expired
+		and record a delete record version audit event for each
+
+			@param ctx context.Context - execution context
+			@param now time.Time - the current time
+			@return number of versions deleted
+	*/
+	PurgeExpiredVersions(ctx context.Context, now time.Time) (int, error)
+
+	/*
+		RestoreRecordVersion insert a data record version entry from a backup as is
+
+			@param ctx context.Context - execution context
+			@param entry models.RecordVersion - the backed up entry
+	*/
+	RestoreRecordVersion(ctx context.Context, entry models.RecordVersion) error
 }
 
 // databaseImpl implements Database
@@ -277,10 +776,49 @@ type databaseImpl struct {
 	goutils.Component
 	db        *gorm.DB
 	validator *validator.Validate
+	// storageEncoding how the encrypted data of record versions is persisted
+	storageEncoding StorageEncodingENUMType
+	// closed whether the session of this handle has ended
+	closed atomic.Bool
+	// auditWriter inserts the system events in asynchronous audit mode. Nil to insert them
+	// in the session.
+	auditWriter *asyncAuditWriter
+	// commitHooks the callbacks to call once the transaction of the session commits. Nil
+	// outside a transaction.
+	commitHooks *commitHooks
+}
+
+// commitHooks callbacks to call once a transaction commits
+type commitHooks struct {
+	lock  sync.Mutex
+	hooks []func()
+}
+
+// add add a callback
+func (h *commitHooks) add(fn func()) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.hooks = append(h.hooks, fn)
+}
+
+// run call the callbacks, in the order they were added
+func (h *commitHooks) run() {
+	h.lock.Lock()
+	hooks := h.hooks
+	h.hooks = nil
+	h.lock.Unlock()
+	for _, fn := range hooks {
+		fn()
+	}
 }
 
 // newDatabase define a new database client
-func newDatabase(_ context.Context, sqlClient *gorm.DB) (Database, error) {
+func newDatabase(
+	_ context.Context,
+	sqlClient *gorm.DB,
+	storageEncoding StorageEncodingENUMType,
+	auditWriter *asyncAuditWriter,
+) (*databaseImpl, error) {
 	logTags := log.Fields{"package": "haven", "module": "db", "component": "db-client"}
 
 	instance := &databaseImpl{
@@ -290,8 +828,10 @@ func newDatabase(_ context.Context, sqlClient *gorm.DB) (Database, error) {
 				goutils.ModifyLogMetadataByRestRequestParam,
 			},
 		},
-		db:        sqlClient,
-		validator: validator.New(),
+		db:              sqlClient,
+		validator:       validator.New(),
+		storageEncoding: storageEncoding,
+		auditWriter:     auditWriter,
 	}
 
 	if err := models.RegisterWithValidator(instance.validator); err != nil {
@@ -300,3 +840,165 @@ func newDatabase(_ context.Context, sqlClient *gorm.DB) (Database, error) {
 
 	return instance, nil
 }
+
+// session the DB session to query with, bound to the context so its cancellation and
+// deadline abort the queries. Queries fail with ErrSessionClosed once the session of this
+// handle has ended.
+func (d *databaseImpl) session(ctx context.Context) *gorm.DB {
+	if d.closed.Load() {
+		tx := d.db.Session(&gorm.Session{NewDB: true, Context: ctx})
+		_ = tx.AddError(ErrSessionClosed)
+		return tx
+	}
+	return d.db.WithContext(ctx)
+}
+
+// inTransaction run fn with a handle whose queries are applied together or not at all.
+// Within an existing transaction, a savepoint is used, so a failure only rolls back the
+// queries of fn.
+func (d *databaseImpl) inTransaction(
+	ctx context.Context, fn func(txClient *databaseImpl) error,
+) error {
+	if d.closed.Load() {
+		return ErrSessionClosed
+	}
+	txHooks := &commitHooks{}
+	if err := d.session(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&databaseImpl{
+			Component:       d.Component,
+			db:              tx,
+			validator:       d.validator,
+			storageEncoding: d.storageEncoding,
+			auditWriter:     d.auditWriter,
+			commitHooks:     txHooks,
+		})
+	}); err != nil {
+		return err
+	}
+	// The callbacks of a savepoint wait for the enclosing transaction
+	for _, fn := range txHooks.hooks {
+		d.AfterCommit(fn)
+	}
+	return nil
+}
+
+/*
+AfterCommit call fn once the transaction of this session commits. fn is never called if
+the transaction rolls back. Outside a transaction, every query commits on its own, so fn
+is called immediately.
+
+	@param fn func() - the callback
+*/
+func (d *databaseImpl) AfterCommit(fn func()) {
+	if d.commitHooks == nil {
+		fn()
+		return
+	}
+	d.commitHooks.add(fn)
+}
+
+// endSession mark the session of this handle as ended
+func (d *databaseImpl) endSession() {
+	d.closed.Store(true)
+}
+
+// notFoundAs report a query which found no entry as the sentinel error instead
+func notFoundAs(err error, sentinel error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sentinel
+	}
+	return err
+}
+
+// isDuplicateKey whether the error is a unique constraint violation
+func (d *databaseImpl) isDuplicateKey(err error) bool {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return true
+	}
+	if translator, ok := d.db.Dialector.(gorm.ErrorTranslator); ok {
+		return errors.Is(translator.Translate(err), gorm.ErrDuplicatedKey)
+	}
+	return false
+}
+
+/*
+resolveSortBy verify a list query sort column against an allow list
+
+	@param sortBy SortByENUMType - requested sort column. Defaults to created_at if empty.
+	@param allowed []SortByENUMType - the allowed sort columns
+	@returns the sort column
+*/
+func resolveSortBy(sortBy SortByENUMType, allowed []SortByENUMType) (string, error) {
+	if sortBy == "" {
+		return string(SortByCreatedAt), nil
+	}
+	for _, column := range allowed {
+		if sortBy == column {
+			return string(column), nil
+		}
+	}
+	return "", fmt.Errorf("unsupported sort column '%s'", sortBy)
+}
+
+/*
+applyListFilter apply the common listing filter and the listing order to a query
+
+Entries are ordered by the sort column, with the entry ID breaking ties.
+
+	@param query *gorm.DB - the listing query
+	@param model interface{} - the DB entry model being listed
+	@param filters CommonListEntryQueryFilter - the common listing filter
+	@param sortColumn string - the sort column. Must be an allow listed column name.
+	@param descending bool - whether the entries are listed in descending order
+	@returns the updated query
+*/
+func (d *databaseImpl) applyListFilter(
+	ctx context.Context,
+	query *gorm.DB,
+	model interface{},
+	filters CommonListEntryQueryFilter,
+	sortColumn string,
+	descending bool,
+) (*gorm.DB, error) {
+	if filters.AfterID != nil && filters.Offset != nil {
+		return nil, fmt.Errorf("list filter can not specify both AfterID and Offset")
+	}
+
+	compare := ">"
+	direction := "asc"
+	if descending {
+		compare = "<"
+		direction = "desc"
+	}
+
+	if filters.AfterID != nil {
+		var count int64
+		tmp := d.session(ctx).Model(model).Where("id = ?", *filters.AfterID).Count(&count)
+		if tmp.Error != nil {
+			return nil, fmt.Errorf("failed to find list cursor %s [%w]", *filters.AfterID, tmp.Error)
+		} else if count == 0 {
+			return nil, fmt.Errorf("list cursor %s unknown", *filters.AfterID)
+		}
+
+		cursorValue := d.session(ctx).Model(model).Select(sortColumn).Where("id = ?", *filters.AfterID)
+		query = query.Where(
+			fmt.Sprintf(
+				"(%s %s (?) OR (%s = (?) AND id %s ?))", sortColumn, compare, sortColumn, compare,
+			),
+			cursorValue, cursorValue, *filters.AfterID,
+		)
+	}
+
+	if filters.Limit != nil {
+		query = query.Limit(*filters.Limit)
+	}
+	if filters.Offset != nil {
+		query = query.Offset(*filters.Offset)
+	}
+
+	query = query.
+		Order(fmt.Sprintf("%s %s", sortColumn, direction)).
+		Order(fmt.Sprintf("id %s", direction))
+
+	return query, nil
+}
@@ -2,12 +2,22 @@ package db
 
 import (
 	"context"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/alwitt/haven/models"
 	"github.com/google/uuid"
 	"github.com/oklog/ulid/v2"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // ======================================================================================
@@ -17,14 +27,19 @@ import (
 DefineNewRecord define new data record
 
 	@param ctx context.Context - execution context
+	@param namespace string - record namespace. Empty for the default namespace.
 	@param name string - record name
 	@returns record entry
 */
-func (d *databaseImpl) DefineNewRecord(_ context.Context, name string) (models.Record, error) {
+func (d *databaseImpl) DefineNewRecord(
+	ctx context.Context, namespace string, name string,
+) (models.Record, error) {
 	newEntry := RecordDBEntry{
 		Record: models.Record{
-			ID:   uuid.NewString(),
-			Name: name,
+			ID:        uuid.NewString(),
+			Namespace: namespace,
+			Name:      name,
+			State:     models.RecordStateActive,
 		},
 	}
 
@@ -32,14 +47,26 @@ func (d *databaseImpl) DefineNewRecord(_ context.Context, name string) (models.R
 		return models.Record{}, fmt.Errorf("new record '%s' is not valid [%w]", name, err)
 	}
 
-	if tmp := d.db.Create(&newEntry); tmp.Error != nil {
-		return models.Record{}, fmt.Errorf("new record '%s' failed insert [%w]", name, tmp.Error)
+	// Insert in a nested transaction, which is a savepoint if already in a transaction, so
+	// a failed insert does not abort the surrounding transaction. The caller can then still
+	// look up the record which holds the name.
+	if err := d.session(ctx).Transaction(func(tx *gorm.DB) error {
+		return tx.Create(&newEntry).Error
+	}); err != nil {
+		if d.isDuplicateKey(err) {
+			return models.Record{}, fmt.Errorf(
+				"new record '%s' failed insert [%w]", name, ErrDuplicateRecordName,
+			)
+		}
+		return models.Record{}, fmt.Errorf("new record '%s' failed insert [%w]", name, err)
 	}
 
 	// Record this event
 	if _, err := d.defineNewSystemEvent(
-		models.SystemEventTypeAddNewRecord,
-		models.SystemEventDataRecordRelated{RecordID: newEntry.ID, RecordName: name},
+		ctx, models.SystemEventTypeAddNewRecord,
+		models.SystemEventDataRecordRelated{
+			RecordID: newEntry.ID, RecordNamespace: namespace, RecordName: name,
+		},
 	); err != nil {
 		return models.Record{}, fmt.Errorf(
 			"failed to log add new record '%s' audit event [%w]", name, err,
@@ -50,10 +77,12 @@ func (d *databaseImpl) DefineNewRecord(_ context.Context, name string) (models.R
 }
 
 // getRecordEntry find a data record by ID
-func (d *databaseImpl) getRecordEntry(recordID string) (RecordDBEntry, error) {
+func (d *databaseImpl) getRecordEntry(
+	ctx context.Context, recordID string,
+) (RecordDBEntry, error) {
 	var entry RecordDBEntry
-	err := d.db.Where("id = ?", recordID).First(&entry).Error
-	return entry, err
+	err := d.session(ctx).Where("id = ?", recordID).First(&entry).Error
+	return entry, notFoundAs(err, ErrRecordNotFound)
 }
 
 /*
@@ -64,9 +93,9 @@ GetRecord fetch a data record by ID
 	@returns record entry
 */
 func (d *databaseImpl) GetRecord(
-	_ context.Context, recordID string,
+	ctx context.Context, recordID string,
 ) (models.Record, error) {
-	entry, err := d.getRecordEntry(recordID)
+	entry, err := d.getRecordEntry(ctx, recordID)
 	if err != nil {
 		return models.Record{}, fmt.Errorf("failed to fetch record %s [%w]", recordID, err)
 	}
@@ -78,20 +107,84 @@ func (d *databaseImpl) GetRecord(
 GetRecordByName fetch a data record by name
 
 	@param ctx context.Context - execution context
+	@param namespace string - data record namespace. Empty for the default namespace.
 	@param recordName string - data record name
 	@returns record entry
 */
 func (d *databaseImpl) GetRecordByName(
-	_ context.Context, recordName string,
+	ctx context.Context, namespace string, recordName string,
 ) (models.Record, error) {
 	var entry RecordDBEntry
-	if tmp := d.db.Where("name = ?", recordName).First(&entry); tmp.Error != nil {
-		return models.Record{}, fmt.Errorf("failed to fetch record '%s' [%w]", recordName, tmp.Error)
+	if tmp := d.session(ctx).
+		Where("namespace = ? AND name = ?", namespace, recordName).
+		First(&entry); tmp.Error != nil {
+		return models.Record{}, fmt.Errorf(
+			"failed to fetch record '%s' [%w]", recordName, notFoundAs(tmp.Error, ErrRecordNotFound),
+		)
 	}
 
 	return entry.Record, nil
 }
 
+/*
+GetRecordsByName fetch the data records with the given names. Names without a data record
+are skipped.
+
+	@param ctx context.Context - execution context
+	@param namespace string - data record namespace. Empty for the default namespace.
+	@param recordNames []string - data record names
+	@returns the record entries found
+*/
+func (d *databaseImpl) GetRecordsByName(
+	ctx context.Context, namespace string, recordNames []string,
+) ([]models.Record, error) {
+	result := []models.Record{}
+	if len(recordNames) == 0 {
+		return result, nil
+	}
+
+	var entries []RecordDBEntry
+	if tmp := d.session(ctx).
+		Where("namespace = ? AND name IN ?", namespace, recordNames).
+		Find(&entries); tmp.Error != nil {
+		return nil, fmt.Errorf("failed to fetch records by name [%w]", tmp.Error)
+	}
+
+	for _, entry := range entries {
+		result = append(result, entry.Record)
+	}
+	return result, nil
+}
+
+// recordFilterQuery prepare a data record query with the WHERE clauses of the filter
+func (d *databaseImpl) recordFilterQuery(
+	ctx context.Context, filters RecordQueryFilter,
+) *gorm.DB {
+	query := d.session(ctx).Model(&RecordDBEntry{})
+
+	if len(filters.State) > 0 {
+		query = query.Where("state in ?", filters.State)
+	} else {
+		query = query.Where("state = ?", models.RecordStateActive)
+	}
+
+	if filters.Namespace != nil {
+		query = query.Where("namespace = ?", *filters.Namespace)
+	}
+
+	if filters.NamePrefix != "" {
+		query = query.Where(
+			"substr(name, 1, ?) = ?", utf8.RuneCountInString(filters.NamePrefix), filters.NamePrefix,
+		)
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(filters.MetadataFilter)) {
+		query = query.Where(datatypes.JSONQuery("metadata").Equals(filters.MetadataFilter[key], key))
+	}
+
+	return query
+}
+
 /*
 ListRecords list data records
 
@@ -100,19 +193,28 @@ ListRecords list data records
 	@return list of records
 */
 func (d *databaseImpl) ListRecords(
-	_ context.Context, filters RecordQueryFilter,
+	ctx context.Context, filters RecordQueryFilter,
 ) ([]models.Record, error) {
-	query := d.db.Model(&RecordDBEntry{})
+	query := d.recordFilterQuery(ctx, filters)
 
-	if filters.Limit != nil {
-		query = query.Limit(*filters.Limit)
+	sortColumn, err := resolveSortBy(
+		filters.SortBy, []SortByENUMType{SortByCreatedAt, SortByUpdatedAt, SortByName},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data record list filter [%w]", err)
 	}
-	if filters.Offset != nil {
-		query = query.Offset(*filters.Offset)
+	query, err = d.applyListFilter(
+		ctx,
+		query,
+		&RecordDBEntry{},
+		filters.CommonListEntryQueryFilter,
+		sortColumn,
+		!filters.SortAscending,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data record list filter [%w]", err)
 	}
 
-	query = query.Order("created_at desc")
-
 	var entries []RecordDBEntry
 	if tmp := query.Find(&entries); tmp.Error != nil {
 		return nil, fmt.Errorf("failed to list data records [%w]", tmp.Error)
@@ -126,26 +228,211 @@ func (d *databaseImpl) ListRecords(
 	return result, nil
 }
 
+/*
+ListRecordsWithVersionStats list data records, each with the creation timestamp of its
+newest version and its number of versions, read in a single query
+
+	@param ctx context.Context - execution context
+	@param filters RecordQueryFilter - entry listing filter
+	@return list of records with their version statistics
+*/
+func (d *databaseImpl) ListRecordsWithVersionStats(
+	ctx context.Context, filters RecordQueryFilter,
+) ([]RecordWithVersionStats, error) {
+	query := d.recordFilterQuery(ctx, filters)
+
+	sortColumn, err := resolveSortBy(
+		filters.SortBy, []SortByENUMType{SortByCreatedAt, SortByUpdatedAt, SortByName},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data record list filter [%w]", err)
+	}
+	query, err = d.applyListFilter(
+		ctx,
+		query,
+		&RecordDBEntry{},
+		filters.CommonListEntryQueryFilter,
+		sortColumn,
+		!filters.SortAscending,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data record list filter [%w]", err)
+	}
+
+	// The statistics columns do not share names with the record columns, so the filter
+	// and order clauses remain unambiguous
+	var entries []recordWithVersionStatsEntry
+	if tmp := query.
+		Select(
+			"records.*, version_stats.latest_version_at, " +
+				"COALESCE(version_stats.version_count, 0) AS version_count",
+		).
+		Joins(
+			"LEFT JOIN (SELECT record_id, MAX(created_at) AS latest_version_at, " +
+				"COUNT(*) AS version_count FROM record_versions GROUP BY record_id) " +
+				"AS version_stats ON version_stats.record_id = records.id",
+		).
+		Scan(&entries); tmp.Error != nil {
+		return nil, fmt.Errorf("failed to list data records with version stats [%w]", tmp.Error)
+	}
+
+	result := []RecordWithVersionStats{}
+	for _, entry := range entries {
+		result = append(result, RecordWithVersionStats{
+			Record:          entry.Record,
+			LatestVersionAt: entry.LatestVersionAt.Time,
+			VersionCount:    entry.VersionCount,
+		})
+	}
+
+	return result, nil
+}
+
+// recordWithVersionStatsEntry a data record DB entry, with statistics of its versions
+type recordWithVersionStatsEntry struct {
+	RecordDBEntry
+	LatestVersionAt aggregateTime
+	VersionCount    int64
+}
+
+// aggregateTimeLayouts the layouts Sqlite timestamps are written with
+var aggregateTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// aggregateTime a timestamp computed by an aggregate function. Sqlite reports these as
+// text, as an aggregate has no declared column type.
+type aggregateTime struct {
+	// Time the timestamp. Nil for NULL.
+	Time *time.Time
+}
+
+// Value implements driver.Valuer
+func (t aggregateTime) Value() (driver.Value, error) {
+	if t.Time == nil {
+		return nil, nil
+	}
+	return *t.Time, nil
+}
+
+// Scan implements sql.Scanner
+func (t *aggregateTime) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		t.Time = nil
+		return nil
+	case time.Time:
+		t.Time = &v
+		return nil
+	case []byte:
+		return t.Scan(string(v))
+	case string:
+		text := strings.TrimSuffix(v, "Z")
+		for _, layout := range aggregateTimeLayouts {
+			if parsed, err := time.ParseInLocation(layout, text, time.UTC); err == nil {
+				t.Time = &parsed
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown timestamp format '%s'", v)
+	}
+	return fmt.Errorf("unsupported timestamp type %T", value)
+}
+
+/*
+CountRecords count data records
+
+The pagination and ordering parameters of the filter are ignored.
+
+	@param ctx context.Context - execution context
+	@param filters RecordQueryFilter - entry listing filter
+	@return number of records
+*/
+func (d *databaseImpl) CountRecords(ctx context.Context, filters RecordQueryFilter) (int64, error) {
+	var count int64
+	if tmp := d.recordFilterQuery(ctx, filters).Count(&count); tmp.Error != nil {
+		return 0, fmt.Errorf("failed to count data records [%w]", tmp.Error)
+	}
+	return count, nil
+}
+
+// scanRecordsPageSize number of data records ScanRecords reads per page
+const scanRecordsPageSize = 100
+
+/*
+ScanRecords call handler with each data record matching the filter, in name order. The
+records are read in pages of bounded size, so they are never all held in memory. The
+pagination and ordering parameters of the filter are ignored.
+
+Each page is fully read before handler is called with its records, so handler may run
+other queries on the same database session. An error from handler stops the scan.
+
+	@param ctx context.Context - execution context
+	@param filters RecordQueryFilter - entry listing filter
+	@param handler func(record models.Record) error - called with each record
+*/
+func (d *databaseImpl) ScanRecords(
+	ctx context.Context, filters RecordQueryFilter, handler func(record models.Record) error,
+) error {
+	var lastRecord *models.Record
+	for {
+		query := d.recordFilterQuery(ctx, filters)
+		if lastRecord != nil {
+			query = query.Where(
+				"(name > ? OR (name = ? AND id > ?))",
+				lastRecord.Name, lastRecord.Name, lastRecord.ID,
+			)
+		}
+
+		var entries []RecordDBEntry
+		if tmp := query.
+			Order("name asc").
+			Order("id asc").
+			Limit(scanRecordsPageSize).
+			Find(&entries); tmp.Error != nil {
+			return fmt.Errorf("failed to scan data records [%w]", tmp.Error)
+		}
+
+		for _, entry := range entries {
+			if err := handler(entry.Record); err != nil {
+				return err
+			}
+		}
+		if len(entries) < scanRecordsPageSize {
+			return nil
+		}
+		lastRecord = &entries[len(entries)-1].Record
+	}
+}
+
 /*
 DeleteRecord delete a data record
 
 	@param ctx context.Context - execution context
 	@param recordID string - data record ID
 */
-func (d *databaseImpl) DeleteRecord(_ context.Context, recordID string) error {
-	entry, err := d.getRecordEntry(recordID)
+func (d *databaseImpl) DeleteRecord(ctx context.Context, recordID string) error {
+	entry, err := d.getRecordEntry(ctx, recordID)
 	if err != nil {
 		return fmt.Errorf("failed to fetch record %s [%w]", recordID, err)
 	}
 
-	if tmp := d.db.Delete(&entry); tmp.Error != nil {
+	if tmp := d.session(ctx).Delete(&entry); tmp.Error != nil {
 		return fmt.Errorf("failed to delete record %s [%w]", recordID, tmp.Error)
 	}
 
 	// Record this event
 	if _, err := d.defineNewSystemEvent(
-		models.SystemEventTypeDeleteRecord,
-		models.SystemEventDataRecordRelated{RecordID: entry.ID, RecordName: entry.Name},
+		ctx, models.SystemEventTypeDeleteRecord,
+		models.SystemEventDataRecordRelated{
+			RecordID: entry.ID, RecordNamespace: entry.Namespace, RecordName: entry.Name,
+		},
 	); err != nil {
 		return fmt.Errorf(
 			"failed to log delete record '%s' audit event [%w]", entry.Name, err,
@@ -155,11 +442,353 @@ func (d *databaseImpl) DeleteRecord(_ context.Context, recordID string) error {
 	return nil
 }
 
+/*
+SetRecordsState change the state of a set of data records
+
+	@param ctx context.Context - execution context
+	@param recordIDs []string - data record IDs
+	@param newState models.RecordStateENUMType - the new state
+*/
+func (d *databaseImpl) SetRecordsState(
+	ctx context.Context, recordIDs []string, newState models.RecordStateENUMType,
+) error {
+	var systemEventType models.SystemEventTypeENUMType
+	switch newState {
+	case models.RecordStateActive:
+		systemEventType = models.SystemEventTypeActivateRecord
+	case models.RecordStateArchived:
+		systemEventType = models.SystemEventTypeArchiveRecord
+	default:
+		return fmt.Errorf("unknown record state '%s'", newState)
+	}
+
+	for _, recordID := range recordIDs {
+		entry, err := d.getRecordEntry(ctx, recordID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch record %s [%w]", recordID, err)
+		}
+
+		if entry.State == newState {
+			// NOOP
+			continue
+		}
+
+		if err := entry.ValidateNextState(newState); err != nil {
+			return fmt.Errorf("record %s state change to %s not allowed [%w]", recordID, newState, err)
+		}
+
+		entry.State = newState
+		if tmp := d.session(ctx).Updates(&entry); tmp.Error != nil {
+			return fmt.Errorf("record %s state change update failed [%w]", recordID, tmp.Error)
+		}
+
+		// Record this event
+		if _, err := d.defineNewSystemEvent(
+			ctx, systemEventType,
+			models.SystemEventDataRecordRelated{
+				RecordID: entry.ID, RecordNamespace: entry.Namespace, RecordName: entry.Name,
+			},
+		); err != nil {
+			return fmt.Errorf(
+				"failed to log record '%s' state change audit event [%w]", entry.Name, err,
+			)
+		}
+	}
+
+	return nil
+}
+
+/*
+FindDuplicateRecordNames find record names which are shared by more than one data
+record of the same namespace. This is meant to support data repair.
+
+	@param ctx context.Context - execution context
+	@return qualified record names mapped to the IDs of the data records sharing that name
+*/
+func (d *databaseImpl) FindDuplicateRecordNames(ctx context.Context) (map[string][]string, error) {
+	var duplicates []struct {
+		Namespace string
+		Name      string
+	}
+	if tmp := d.session(ctx).
+		Model(&RecordDBEntry{}).
+		Select("namespace, name").
+		Group("namespace, name").
+		Having("COUNT(*) > ?", 1).
+		Scan(&duplicates); tmp.Error != nil {
+		return nil, fmt.Errorf("failed to find duplicate record names [%w]", tmp.Error)
+	}
+
+	result := map[string][]string{}
+	if len(duplicates) == 0 {
+		return result, nil
+	}
+
+	duplicateNames := []string{}
+	for _, duplicate := range duplicates {
+		duplicateNames = append(duplicateNames, duplicate.Name)
+	}
+
+	var entries []RecordDBEntry
+	if tmp := d.session(ctx).
+		Where("name in ?", duplicateNames).
+		Order("created_at").
+		Find(&entries); tmp.Error != nil {
+		return nil, fmt.Errorf("failed to fetch records with duplicate names [%w]", tmp.Error)
+	}
+
+	isDuplicate := map[[2]string]bool{}
+	for _, duplicate := range duplicates {
+		isDuplicate[[2]string{duplicate.Namespace, duplicate.Name}] = true
+	}
+	for _, entry := range entries {
+		if isDuplicate[[2]string{entry.Namespace, entry.Name}] {
+			name := entry.QualifiedName()
+			result[name] = append(result[name], entry.ID)
+		}
+	}
+
+	return result, nil
+}
+
+/*
+SetRecordMetadata replace the metadata of a data record
+
+	@param ctx context.Context - execution context
+	@param recordID string - the data record ID
+	@param metadata map[string]string - the new metadata. Empty to clear the metadata.
+*/
+func (d *databaseImpl) SetRecordMetadata(
+	ctx context.Context, recordID string, metadata map[string]string,
+) error {
+	entry, err := d.getRecordEntry(ctx, recordID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch record %s [%w]", recordID, err)
+	}
+
+	var encoded datatypes.JSON
+	if len(metadata) > 0 {
+		if encoded, err = json.Marshal(metadata); err != nil {
+			return fmt.Errorf("failed to encode record %s metadata [%w]", recordID, err)
+		}
+	}
+
+	if tmp := d.session(ctx).
+		Model(&entry).
+		Update("metadata", encoded); tmp.Error != nil {
+		return fmt.Errorf("record %s metadata update failed [%w]", recordID, tmp.Error)
+	}
+
+	// Record this event
+	if _, err := d.defineNewSystemEvent(
+		ctx, models.SystemEventTypeUpdateRecordMetadata,
+		models.SystemEventDataRecordRelated{
+			RecordID: entry.ID, RecordNamespace: entry.Namespace, RecordName: entry.Name,
+		},
+	); err != nil {
+		return fmt.Errorf(
+			"failed to log record '%s' metadata change audit event [%w]", entry.Name, err,
+		)
+	}
+
+	return nil
+}
+
+/*
+RenameRecord change the name of a data record. The record keeps its ID, namespace, and
+versions.
+
+	@param ctx context.Context - execution context
+	@param recordID string - the data record ID
+	@param newName string - the new record name. ErrDuplicateRecordName if another record of
+	    the namespace already uses it.
+	@returns the renamed record entry
+*/
+func (d *databaseImpl) RenameRecord(
+	ctx context.Context, recordID string, newName string,
+) (models.Record, error) {
+	entry, err := d.getRecordEntry(ctx, recordID)
+	if err != nil {
+		return models.Record{}, fmt.Errorf("failed to fetch record %s [%w]", recordID, err)
+	}
+	previousName := entry.Name
+
+	entry.Name = newName
+	if err := d.validator.Struct(&entry); err != nil {
+		return models.Record{}, fmt.Errorf("new record name '%s' is not valid [%w]", newName, err)
+	}
+
+	// Update in a nested transaction, so a name collision does not abort the surrounding
+	// transaction
+	if err := d.session(ctx).Transaction(func(tx *gorm.DB) error {
+		return tx.Model(&RecordDBEntry{}).
+			Where("id = ?", recordID).
+			Update("name", newName).Error
+	}); err != nil {
+		if d.isDuplicateKey(err) {
+			return models.Record{}, fmt.Errorf(
+				"record %s rename to '%s' failed [%w]", recordID, newName, ErrDuplicateRecordName,
+			)
+		}
+		return models.Record{}, fmt.Errorf(
+			"record %s rename to '%s' failed [%w]", recordID, newName, err,
+		)
+	}
+
+	// Record this event
+	if _, err := d.defineNewSystemEvent(
+		ctx, models.SystemEventTypeRenameRecord,
+		models.SystemEventDataRecordRelated{
+			RecordID:           entry.ID,
+			RecordNamespace:    entry.Namespace,
+			RecordName:         newName,
+			PreviousRecordName: previousName,
+		},
+	); err != nil {
+		return models.Record{}, fmt.Errorf(
+			"failed to log record '%s' rename audit event [%w]", previousName, err,
+		)
+	}
+
+	return d.GetRecord(ctx, recordID)
+}
+
+/*
+GetRecordMetadata read the metadata of a data record
+
+	@param ctx context.Context - execution context
+	@param recordID string - the data record ID
+	@return the metadata. Empty if the record has none.
+*/
+func (d *databaseImpl) GetRecordMetadata(
+	ctx context.Context, recordID string,
+) (map[string]string, error) {
+	entry, err := d.getRecordEntry(ctx, recordID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch record %s [%w]", recordID, err)
+	}
+
+	metadata := map[string]string{}
+	if len(entry.Metadata) > 0 {
+		if err := json.Unmarshal(entry.Metadata, &metadata); err != nil {
+			return nil, fmt.Errorf("failed to parse record %s metadata [%w]", recordID, err)
+		}
+	}
+	return metadata, nil
+}
+
+/*
+MarkRecordRead record when a value of the data record was read
+
+	@param ctx context.Context - execution context
+	@param recordID string - the data record ID
+	@param readAt time.Time - when the value was read
+*/
+func (d *databaseImpl) MarkRecordRead(
+	ctx context.Context, recordID string, readAt time.Time,
+) error {
+	// Reading a value does not modify the record, so leave updated_at alone
+	tmp := d.session(ctx).
+		Model(&RecordDBEntry{}).
+		Where("id = ?", recordID).
+		UpdateColumn("last_read_at", readAt)
+	if tmp.Error != nil {
+		return fmt.Errorf("failed to mark data record %s read [%w]", recordID, tmp.Error)
+	}
+	if tmp.RowsAffected == 0 {
+		return fmt.Errorf("failed to mark data record %s read [%w]", recordID, ErrRecordNotFound)
+	}
+	return nil
+}
+
+/*
+ListRecordsNotReadSince list data records which have not been read since the cutoff,
+including records which were never read, oldest read first
+
+	@param ctx context.Context - execution context
+	@param cutoff time.Time - the cutoff
+	@return list of records
+*/
+func (d *databaseImpl) ListRecordsNotReadSince(
+	ctx context.Context, cutoff time.Time,
+) ([]models.Record, error) {
+	var entries []RecordDBEntry
+	if tmp := d.session(ctx).
+		Where("last_read_at IS NULL OR last_read_at < ?", cutoff).
+		Order("last_read_at IS NOT NULL").
+		Order("last_read_at asc").
+		Order("id asc").
+		Find(&entries); tmp.Error != nil {
+		return nil, fmt.Errorf("failed to list data records not read since %s [%w]", cutoff, tmp.Error)
+	}
+
+	result := []models.Record{}
+	for _, entry := range entries {
+		result = append(result, entry.Record)
+	}
+	return result, nil
+}
+
+/*
+ListRecordsWithNoVersions list data records which have no versions, such as after all their
+versions were pruned. Expired versions which are not yet purged still count.
+
+	@param ctx context.Context - execution context
+	@return list of records, oldest first
+*/
+func (d *databaseImpl) ListRecordsWithNoVersions(ctx context.Context) ([]models.Record, error) {
+	var entries []RecordDBEntry
+	if tmp := d.session(ctx).
+		Joins("LEFT JOIN record_versions ON record_versions.record_id = records.id").
+		Where("record_versions.id IS NULL").
+		Order("records.created_at asc").
+		Order("records.id asc").
+		Find(&entries); tmp.Error != nil {
+		return nil, fmt.Errorf("failed to list data records with no versions [%w]", tmp.Error)
+	}
+
+	result := []models.Record{}
+	for _, entry := range entries {
+		result = append(result, entry.Record)
+	}
+	return result, nil
+}
+
 // ======================================================================================
 // Data record versions
 
+// recordVersionSequence the sequence ordering data record versions by commit
+const recordVersionSequence = "record_versions"
+
+// nextSequence advance a sequence, returning its new value
+//
+// The sequence stays locked until the transaction ends, so transactions taking values run
+// one after another, and a value is only handed out once every smaller one is committed or
+// rolled back. Must be called within a transaction.
+func (d *databaseImpl) nextSequence(ctx context.Context, name string) (int64, error) {
+	if tmp := d.session(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&SequenceDBEntry{Name: name}); tmp.Error != nil {
+		return 0, fmt.Errorf("failed to define sequence '%s' [%w]", name, tmp.Error)
+	}
+
+	if tmp := d.session(ctx).
+		Model(&SequenceDBEntry{}).
+		Where("name = ?", name).
+		UpdateColumn("value", gorm.Expr("value + 1")); tmp.Error != nil {
+		return 0, fmt.Errorf("failed to advance sequence '%s' [%w]", name, tmp.Error)
+	}
+
+	var entry SequenceDBEntry
+	if tmp := d.session(ctx).Where("name = ?", name).First(&entry); tmp.Error != nil {
+		return 0, fmt.Errorf("failed to read sequence '%s' [%w]", name, tmp.Error)
+	}
+	return entry.Value, nil
+}
+
 /*
-DefineNewVersionForRecord define new data record version
+DefineNewVersionForRecord define new data record version. Transactions recording
+versions run one at a time, as each holds the version sequence until it ends.
 
 	@param ctx context.Context - execution context
 	@param record models.Record - the parent data record
@@ -168,25 +797,31 @@ DefineNewVersionForRecord define new data record version
 	@param value []byte - the encrypted data of this record version
 	@param nonce []byte - the encryption nonce
 	@param timestamp time.Time - the timestamp of the version
+	@param opts NewVersionOptions - optional parameters of the version
 	@returns record version entry
 */
 func (d *databaseImpl) DefineNewVersionForRecord(
-	_ context.Context,
+	ctx context.Context,
 	record models.Record,
 	encKey models.EncryptionKey,
 	value []byte,
 	nonce []byte,
 	timestamp time.Time,
+	opts NewVersionOptions,
 ) (models.RecordVersion, error) {
 	newEntry := RecordVersionDBEntry{
 		RecordVersion: models.RecordVersion{
-			ID:        ulid.Make().String(),
-			RecordID:  record.ID,
-			EncKeyID:  encKey.ID,
-			EncValue:  value,
-			EncNonce:  nonce,
-			CreatedAt: timestamp,
-			UpdatedAt: timestamp,
+			ID:            ulid.Make().String(),
+			RecordID:      record.ID,
+			EncKeyID:      encKey.ID,
+			EncValue:      value,
+			EncNonce:      nonce,
+			AADBound:      opts.AADBound,
+			KeyDerivation: opts.KeyDerivation,
+			Note:          opts.Note,
+			ExpiresAt:     opts.ExpiresAt,
+			CreatedAt:     timestamp,
+			UpdatedAt:     timestamp,
 		},
 	}
 
@@ -196,46 +831,182 @@ func (d *databaseImpl) DefineNewVersionForRecord(
 		)
 	}
 
-	if tmp := d.db.Create(&newEntry); tmp.Error != nil {
-		return models.RecordVersion{}, fmt.Errorf(
-			"new version for record %s insert failed [%w]", record.ID, tmp.Error,
-		)
+	// The version is only kept if its audit event is recorded as well
+	storedEntry := d.encodeForStorage(newEntry.RecordVersion)
+	if err := d.inTransaction(ctx, func(txClient *databaseImpl) error {
+		// The caller's copy of the key may predate its retirement
+		currentKey, err := txClient.getEncryptionKey(ctx, encKey.ID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch encryption key %s [%w]", encKey.ID, err)
+		}
+		if currentKey.State == models.EncryptionKeyStateRetired {
+			return fmt.Errorf(
+				"new version for record %s can not use encryption key %s [%w]",
+				record.ID,
+				encKey.ID,
+				ErrEncryptionKeyRetired,
+			)
+		}
+
+		sequence, err := txClient.nextSequence(ctx, recordVersionSequence)
+		if err != nil {
+			return fmt.Errorf("failed to sequence new version for record %s [%w]", record.ID, err)
+		}
+		newEntry.Sequence = sequence
+		storedEntry.Sequence = sequence
+
+		if tmp := txClient.session(ctx).Create(&storedEntry); tmp.Error != nil {
+			return fmt.Errorf("new version for record %s insert failed [%w]", record.ID, tmp.Error)
+		}
+
+		// Record this event
+		if _, err := txClient.defineNewSystemEvent(
+			ctx, models.SystemEventTypeNewRecordVersion,
+			models.SystemEventRecordVersionRelated{
+				RecordID: record.ID, VersionID: newEntry.ID, EncKeyID: encKey.ID,
+			},
+		); err != nil {
+			return fmt.Errorf("failed to log add new record version audit event [%w]", err)
+		}
+		return nil
+	}); err != nil {
+		return models.RecordVersion{}, err
 	}
 
 	return newEntry.RecordVersion, nil
 }
 
 /*
-GetRecordVersion fetch a record version by ID
+ReencryptRecordVersion replace the encrypted data of a data record version with the
+same data encrypted with another encryption key
 
 	@param ctx context.Context - execution context
 	@param versionID string - data record version ID
-	@returns record version entry
+	@param encKey models.EncryptionKey - the encryption key that encrypted the new data
+	@param value []byte - the encrypted data of this record version
+	@param nonce []byte - the encryption nonce
+	@param keyDerivation models.KeyDerivationENUMType - how the AEAD key which encrypted
+	    the new data is derived from the encryption key
+	@returns updated record version entry
 */
-func (d *databaseImpl) GetRecordVersion(
-	_ context.Context, versionID string,
+func (d *databaseImpl) ReencryptRecordVersion(
+	ctx context.Context,
+	versionID string,
+	encKey models.EncryptionKey,
+	value []byte,
+	nonce []byte,
+	keyDerivation models.KeyDerivationENUMType,
 ) (models.RecordVersion, error) {
-	var entry RecordVersionDBEntry
-	if tmp := d.db.Where("id = ?", versionID).First(&entry); tmp.Error != nil {
+	var storedEntry RecordVersionDBEntry
+	if tmp := d.session(ctx).Where("id = ?", versionID).First(&storedEntry); tmp.Error != nil {
+		return models.RecordVersion{}, fmt.Errorf(
+			"failed to fetch record version %s [%w]",
+			versionID,
+			notFoundAs(tmp.Error, ErrVersionNotFound),
+		)
+	}
+
+	entry := RecordVersionDBEntry{RecordVersion: storedEntry.RecordVersion}
+	entry.EncKeyID = encKey.ID
+	entry.EncValue = value
+	entry.EncNonce = nonce
+	entry.KeyDerivation = keyDerivation
+	if err := d.validator.Struct(&entry); err != nil {
+		return models.RecordVersion{}, fmt.Errorf(
+			"re-encrypted record version %s is invalid [%w]", versionID, err,
+		)
+	}
+
+	// Select the columns, so a cleared key derivation is written as well
+	storedEntry = d.encodeForStorage(entry.RecordVersion)
+	if tmp := d.session(ctx).
+		Select("enc_key_id", "enc_value", "enc_nonce", "key_derivation", "updated_at").
+		Updates(&storedEntry); tmp.Error != nil {
 		return models.RecordVersion{}, fmt.Errorf(
-			"failed to fetch record version %s [%w]", versionID, tmp.Error,
+			"record version %s re-encryption update failed [%w]", versionID, tmp.Error,
 		)
 	}
 
+	// Record this event
+	if _, err := d.defineNewSystemEvent(
+		ctx, models.SystemEventTypeReencryptRecordVersion,
+		models.SystemEventRecordVersionRelated{
+			RecordID: entry.RecordID, VersionID: entry.ID, EncKeyID: encKey.ID,
+		},
+	); err != nil {
+		return models.RecordVersion{}, fmt.Errorf(
+			"failed to log re-encrypt record version audit event [%w]", err,
+		)
+	}
+
+	entry.UpdatedAt = storedEntry.UpdatedAt
 	return entry.RecordVersion, nil
 }
 
+// encodeForStorage prepare the DB entry of a record version, with the encrypted data
+// encoded as the storage encoding requires
+func (d *databaseImpl) encodeForStorage(version models.RecordVersion) RecordVersionDBEntry {
+	if d.storageEncoding == StorageEncodingBase64 {
+		version.EncValue = []byte(base64.RawStdEncoding.EncodeToString(version.EncValue))
+		version.EncNonce = []byte(base64.RawStdEncoding.EncodeToString(version.EncNonce))
+	}
+	return RecordVersionDBEntry{RecordVersion: version}
+}
+
+// decodeFromStorage read the record version of a DB entry, with the encrypted data
+// decoded from the storage encoding
+func (d *databaseImpl) decodeFromStorage(entry RecordVersionDBEntry) (models.RecordVersion, error) {
+	version := entry.RecordVersion
+	if d.storageEncoding != StorageEncodingBase64 {
+		return version, nil
+	}
+
+	var err error
+	if version.EncValue, err = base64.RawStdEncoding.DecodeString(
+		string(entry.EncValue),
+	); err != nil {
+		return models.RecordVersion{}, fmt.Errorf(
+			"record version %s value is not base64 encoded [%w]", entry.ID, err,
+		)
+	}
+	if version.EncNonce, err = base64.RawStdEncoding.DecodeString(
+		string(entry.EncNonce),
+	); err != nil {
+		return models.RecordVersion{}, fmt.Errorf(
+			"record version %s nonce is not base64 encoded [%w]", entry.ID, err,
+		)
+	}
+	return version, nil
+}
+
 /*
-ListAllRecordVersions list data record versions
+GetRecordVersion fetch a record version by ID
 
 	@param ctx context.Context - execution context
-	@param filters RecordVersionQueryFilter - entry listing filter
-	@return list of record versions
+	@param versionID string - data record version ID
+	@returns record version entry
 */
-func (d *databaseImpl) ListAllRecordVersions(
-	_ context.Context, filters RecordVersionQueryFilter,
-) ([]models.RecordVersion, error) {
-	query := d.db.Model(&RecordVersionDBEntry{})
+func (d *databaseImpl) GetRecordVersion(
+	ctx context.Context, versionID string,
+) (models.RecordVersion, error) {
+	var entry RecordVersionDBEntry
+	if tmp := d.session(ctx).Where("id = ?", versionID).First(&entry); tmp.Error != nil {
+		return models.RecordVersion{}, fmt.Errorf(
+			"failed to fetch record version %s [%w]",
+			versionID,
+			notFoundAs(tmp.Error, ErrVersionNotFound),
+		)
+	}
+
+	return d.decodeFromStorage(entry)
+}
+
+// recordVersionFilterQuery prepare a data record version query with the WHERE clauses of
+// the filter
+func (d *databaseImpl) recordVersionFilterQuery(
+	ctx context.Context, filters RecordVersionQueryFilter,
+) *gorm.DB {
+	query := d.session(ctx).Model(&RecordVersionDBEntry{})
 
 	if filters.TargetRecordID != nil {
 		query = query.Where("record_id = ?", *filters.TargetRecordID)
@@ -245,14 +1016,79 @@ func (d *databaseImpl) ListAllRecordVersions(
 		query = query.Where("enc_key_id = ?", *filters.TargetEncKeyID)
 	}
 
-	if filters.Limit != nil {
-		query = query.Limit(*filters.Limit)
+	if filters.CreatedAfter != nil {
+		query = query.Where("created_at >= ?", *filters.CreatedAfter)
 	}
-	if filters.Offset != nil {
-		query = query.Offset(*filters.Offset)
+	if filters.CreatedBefore != nil {
+		query = query.Where("created_at <= ?", *filters.CreatedBefore)
 	}
 
-	query = query.Order("created_at desc")
+	if filters.HasNote {
+		query = query.Where("note <> ''")
+	}
+
+	return query
+}
+
+/*
+ListAllRecordVersions list data record versions
+
+	@param ctx context.Context - execution context
+	@param filters RecordVersionQueryFilter - entry listing filter
+	@return list of record versions
+*/
+func (d *databaseImpl) ListAllRecordVersions(
+	ctx context.Context, filters RecordVersionQueryFilter,
+) ([]models.RecordVersion, error) {
+	query := d.recordVersionFilterQuery(ctx, filters)
+
+	if filters.AfterVersionID != nil {
+		if filters.AfterID != nil || filters.Offset != nil {
+			return nil, fmt.Errorf("list filter can not specify AfterVersionID with AfterID or Offset")
+		}
+		if *filters.AfterVersionID != "" {
+			var cursor RecordVersionDBEntry
+			if tmp := d.session(ctx).
+				Select("id", "sequence").
+				Where("id = ?", *filters.AfterVersionID).
+				First(&cursor); tmp.Error != nil {
+				return nil, fmt.Errorf(
+					"failed to fetch cursor record version %s [%w]",
+					*filters.AfterVersionID,
+					notFoundAs(tmp.Error, ErrVersionNotFound),
+				)
+			}
+			query = query.Where(
+				"sequence > ? OR (sequence = ? AND id > ?)",
+				cursor.Sequence,
+				cursor.Sequence,
+				cursor.ID,
+			)
+		}
+		// Versions recorded before sequences were introduced share sequence 0
+		query = query.Order("sequence asc").Order("id asc")
+		if filters.Limit != nil {
+			query = query.Limit(*filters.Limit)
+		}
+	} else {
+		sortColumn, err := resolveSortBy(
+			filters.SortBy, []SortByENUMType{SortByCreatedAt, SortByUpdatedAt},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data record version list filter [%w]", err)
+		}
+		query, err = d.applyListFilter(
+			ctx,
+			query,
+			&RecordVersionDBEntry{},
+			filters.CommonListEntryQueryFilter,
+			sortColumn,
+			!filters.SortAscending,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data record version list filter [%w]", err)
+		}
+	}
 
 	var entries []RecordVersionDBEntry
 	if tmp := query.Find(&entries); tmp.Error != nil {
@@ -261,12 +1097,84 @@ func (d *databaseImpl) ListAllRecordVersions(
 
 	result := []models.RecordVersion{}
 	for _, entry := range entries {
-		result = append(result, entry.RecordVersion)
+		version, err := d.decodeFromStorage(entry)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, version)
 	}
 
 	return result, nil
 }
 
+/*
+ListGlobalVersionTimeline list data record versions across all data records, newest
+first, with the names of their data records attached. The sort parameters of the filter
+are ignored, and AfterVersionID is not supported.
+
+	@param ctx context.Context - execution context
+	@param filters RecordVersionQueryFilter - entry listing filter
+	@return list of record versions with their record names
+*/
+func (d *databaseImpl) ListGlobalVersionTimeline(
+	ctx context.Context, filters RecordVersionQueryFilter,
+) ([]VersionWithName, error) {
+	if filters.AfterVersionID != nil {
+		return nil, fmt.Errorf("version timeline does not support AfterVersionID")
+	}
+	filters.SortBy = SortByCreatedAt
+	filters.SortAscending = false
+
+	versions, err := d.ListAllRecordVersions(ctx, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	recordIDs := []string{}
+	for _, version := range versions {
+		recordIDs = append(recordIDs, version.RecordID)
+	}
+	var records []RecordDBEntry
+	if len(recordIDs) > 0 {
+		if tmp := d.session(ctx).Where("id IN ?", recordIDs).Find(&records); tmp.Error != nil {
+			return nil, fmt.Errorf("failed to fetch data records of versions [%w]", tmp.Error)
+		}
+	}
+	recordByID := map[string]models.Record{}
+	for _, record := range records {
+		recordByID[record.ID] = record.Record
+	}
+
+	result := []VersionWithName{}
+	for _, version := range versions {
+		record := recordByID[version.RecordID]
+		result = append(result, VersionWithName{
+			RecordVersion: version, Namespace: record.Namespace, Name: record.Name,
+		})
+	}
+
+	return result, nil
+}
+
+/*
+CountRecordVersions count data record versions
+
+The pagination and ordering parameters of the filter are ignored.
+
+	@param ctx context.Context - execution context
+	@param filters RecordVersionQueryFilter - entry listing filter
+	@return number of record versions
+*/
+func (d *databaseImpl) CountRecordVersions(
+	ctx context.Context, filters RecordVersionQueryFilter,
+) (int64, error) {
+	var count int64
+	if tmp := d.recordVersionFilterQuery(ctx, filters).Count(&count); tmp.Error != nil {
+		return 0, fmt.Errorf("failed to count data record versions [%w]", tmp.Error)
+	}
+	return count, nil
+}
+
 /*
 ListVersionsOfOneRecord list data record versions of a specific record
 
@@ -282,6 +1190,161 @@ func (d *databaseImpl) ListVersionsOfOneRecord(
 	return d.ListAllRecordVersions(ctx, filters)
 }
 
+/*
+GetLatestVersionsOfRecords fetch the newest version of each of the data records. Records
+without versions are skipped.
+
+	@param ctx context.Context - execution context
+	@param recordIDs []string - data record IDs
+	@return the newest version of each record, by record ID
+*/
+func (d *databaseImpl) GetLatestVersionsOfRecords(
+	ctx context.Context, recordIDs []string,
+) (map[string]models.RecordVersion, error) {
+	result := map[string]models.RecordVersion{}
+	if len(recordIDs) == 0 {
+		return result, nil
+	}
+
+	// Version IDs break ties between versions created at the same time
+	var entries []RecordVersionDBEntry
+	if tmp := d.session(ctx).
+		Where("record_id IN ?", recordIDs).
+		Where(
+			"id = (SELECT newest.id FROM record_versions AS newest " +
+				"WHERE newest.record_id = record_versions.record_id " +
+				"ORDER BY newest.created_at DESC, newest.id DESC LIMIT 1)",
+		).
+		Find(&entries); tmp.Error != nil {
+		return nil, fmt.Errorf("failed to fetch newest record versions [%w]", tmp.Error)
+	}
+
+	for _, entry := range entries {
+		version, err := d.decodeFromStorage(entry)
+		if err != nil {
+			return nil, err
+		}
+		result[version.RecordID] = version
+	}
+	return result, nil
+}
+
+/*
+GetLatestVersionKey fetch the encryption key of the newest version of a data record
+
+	@param ctx context.Context - execution context
+	@param recordID string - data record ID
+	@return the encryption key. ErrVersionNotFound if the record has no versions.
+*/
+func (d *databaseImpl) GetLatestVersionKey(
+	ctx context.Context, recordID string,
+) (models.EncryptionKey, error) {
+	// Version IDs break ties between versions created at the same time
+	var entries []EncryptionKeyDBEntry
+	if tmp := d.session(ctx).
+		Model(&EncryptionKeyDBEntry{}).
+		Select("encryption_keys.*").
+		Joins("JOIN record_versions ON record_versions.enc_key_id = encryption_keys.id").
+		Where("record_versions.record_id = ?", recordID).
+		Order("record_versions.created_at desc").
+		Order("record_versions.id desc").
+		Limit(1).
+		Find(&entries); tmp.Error != nil {
+		return models.EncryptionKey{}, fmt.Errorf(
+			"failed to fetch record %s newest version encryption key [%w]", recordID, tmp.Error,
+		)
+	}
+	if len(entries) == 0 {
+		if _, err := d.getRecordEntry(ctx, recordID); err != nil {
+			return models.EncryptionKey{}, fmt.Errorf("failed to fetch record %s [%w]", recordID, err)
+		}
+		return models.EncryptionKey{}, fmt.Errorf(
+			"record %s has no versions [%w]", recordID, ErrVersionNotFound,
+		)
+	}
+
+	return entries[0].EncryptionKey, nil
+}
+
+/*
+ListRecordsOnInactiveKeys list the data records, of every namespace and state, whose newest
+version is encrypted with an encryption key which is no longer active. These values are at
+risk should the key be deleted, so should be re-encrypted.
+
+	@param ctx context.Context - execution context
+	@return the data records, ordered by namespace then name
+*/
+func (d *databaseImpl) ListRecordsOnInactiveKeys(ctx context.Context) ([]models.Record, error) {
+	// Version IDs break ties between versions created at the same time
+	var entries []RecordDBEntry
+	if tmp := d.session(ctx).
+		Model(&RecordDBEntry{}).
+		Select("records.*").
+		Joins("JOIN record_versions ON record_versions.record_id = records.id").
+		Joins("JOIN encryption_keys ON encryption_keys.id = record_versions.enc_key_id").
+		Where(
+			"record_versions.id = (SELECT newest.id FROM record_versions AS newest "+
+				"WHERE newest.record_id = records.id "+
+				"ORDER BY newest.created_at DESC, newest.id DESC LIMIT 1)",
+		).
+		Where("encryption_keys.state <> ?", models.EncryptionKeyStateActive).
+		Order("records.namespace asc").
+		Order("records.name asc").
+		Find(&entries); tmp.Error != nil {
+		return nil, fmt.Errorf("failed to list records on inactive encryption keys [%w]", tmp.Error)
+	}
+
+	result := []models.Record{}
+	for _, entry := range entries {
+		result = append(result, entry.Record)
+	}
+	return result, nil
+}
+
+/*
+GetNthVersionOfRecord fetch one version of a specific record by its position in the
+order the versions were created
+
+The pagination and ordering parameters of the filter are ignored.
+
+	@param ctx context.Context - execution context
+	@param record models.Record - parent data record
+	@param index int - position of the version, starting from 0 for the oldest version.
+	    A negative index counts from the newest version, which is -1.
+	@param filters RecordVersionQueryFilter - entry listing filter
+	@return the record version. ErrVersionNotFound if the index is out of range.
+*/
+func (d *databaseImpl) GetNthVersionOfRecord(
+	ctx context.Context,
+	record models.Record,
+	index int,
+	filters RecordVersionQueryFilter,
+) (models.RecordVersion, error) {
+	filters.TargetRecordID = &record.ID
+	query := d.recordVersionFilterQuery(ctx, filters)
+
+	// Version IDs break ties between versions created at the same time
+	if index >= 0 {
+		query = query.Order("created_at asc").Order("id asc").Offset(index)
+	} else {
+		query = query.Order("created_at desc").Order("id desc").Offset(-index - 1)
+	}
+
+	var entries []RecordVersionDBEntry
+	if tmp := query.Limit(1).Find(&entries); tmp.Error != nil {
+		return models.RecordVersion{}, fmt.Errorf(
+			"failed to fetch record %s version %d [%w]", record.ID, index, tmp.Error,
+		)
+	}
+	if len(entries) == 0 {
+		return models.RecordVersion{}, fmt.Errorf(
+			"record %s has no version %d [%w]", record.ID, index, ErrVersionNotFound,
+		)
+	}
+
+	return d.decodeFromStorage(entries[0])
+}
+
 /*
 ListVersionsEncryptedByKey list data record versions encrypted with a specific
 encryption key
@@ -297,3 +1360,201 @@ func (d *databaseImpl) ListVersionsEncryptedByKey(
 	filters.TargetEncKeyID = &encKey.ID
 	return d.ListAllRecordVersions(ctx, filters)
 }
+
+/*
+SumRecordVersionSizes total size of the encrypted data of the versions of a data record
+
+	@param ctx context.Context - execution context
+	@param recordID string - data record ID
+	@return total size in bytes
+*/
+func (d *databaseImpl) SumRecordVersionSizes(ctx context.Context, recordID string) (int64, error) {
+	// Unpadded base64 text of N bytes decodes to exactly floor(N * 3 / 4) bytes
+	sizeExpr := "LENGTH(enc_value)"
+	if d.storageEncoding == StorageEncodingBase64 {
+		sizeExpr = "LENGTH(enc_value) * 3 / 4"
+	}
+
+	var total int64
+	tmp := d.session(ctx).
+		Model(&RecordVersionDBEntry{}).
+		Where("record_id = ?", recordID).
+		Select(fmt.Sprintf("COALESCE(SUM(%s), 0)", sizeExpr)).
+		Scan(&total)
+	if tmp.Error != nil {
+		return 0, fmt.Errorf("failed to sum record %s version sizes [%w]", recordID, tmp.Error)
+	}
+	return total, nil
+}
+
+/*
+FindVersionsWithoutCreationEvent list data record versions which have no version creation
+audit event. The result is not proof of tampering, as some versions legitimately have
+none: versions restored through RestoreRecordVersion (the audit log is not part of the
+backup), versions recorded before version creation events were introduced, and versions
+whose event is still buffered by asynchronous auditing.
+
+	@param ctx context.Context - execution context
+	@return IDs of the versions, in the order they were recorded
+*/
+func (d *databaseImpl) FindVersionsWithoutCreationEvent(ctx context.Context) ([]string, error) {
+	creationEvents := d.session(ctx).
+		Model(&SystemEventAuditDBEntry{}).
+		Select("1").
+		Where("type = ?", models.SystemEventTypeNewRecordVersion).
+		Where(
+			"? = ?",
+			datatypes.JSONQuery("metadata").Extract("version_id"),
+			clause.Column{Table: RecordVersionDBEntry{}.TableName(), Name: "id"},
+		)
+
+	versionIDs := []string{}
+	if tmp := d.session(ctx).
+		Model(&RecordVersionDBEntry{}).
+		Where("NOT EXISTS (?)", creationEvents).
+		Order("id asc").
+		Pluck("id", &versionIDs); tmp.Error != nil {
+		return nil, fmt.Errorf(
+			"failed to list versions without a creation audit event [%w]", tmp.Error,
+		)
+	}
+	return versionIDs, nil
+}
+
+/*
+DeleteRecordVersion delete a data record version
+
+	@param ctx context.Context - execution context
+	@param versionID string - data record version ID
+*/
+func (d *databaseImpl) DeleteRecordVersion(ctx context.Context, versionID string) error {
+	var entry RecordVersionDBEntry
+	if tmp := d.session(ctx).Where("id = ?", versionID).First(&entry); tmp.Error != nil {
+		return fmt.Errorf(
+			"failed to fetch record version %s [%w]",
+			versionID,
+			notFoundAs(tmp.Error, ErrVersionNotFound),
+		)
+	}
+
+	if tmp := d.session(ctx).Delete(&entry); tmp.Error != nil {
+		return fmt.Errorf("failed to delete record version %s [%w]", versionID, tmp.Error)
+	}
+
+	// Record this event
+	if _, err := d.defineNewSystemEvent(
+		ctx, models.SystemEventTypeDeleteRecordVersion,
+		models.SystemEventRecordVersionRelated{
+			RecordID: entry.RecordID, VersionID: entry.ID, EncKeyID: entry.EncKeyID,
+		},
+	); err != nil {
+		return fmt.Errorf("failed to log delete record version audit event [%w]", err)
+	}
+
+	return nil
+}
+
+// purgeVersionsBatchSize number of expired versions deleted by one query, keeping the
+// query within the bound parameter limits of the DB
+const purgeVersionsBatchSize = 500
+
+/*
+PurgeExpiredVersions delete data record versions which have expired
+and record a delete record version audit event for each
+
+	@param ctx context.Context - execution context
+	@param now time.Time - the current time
+	@return number of versions deleted
+*/
+func (d *databaseImpl) PurgeExpiredVersions(ctx context.Context, now time.Time) (int, error) {
+	purged := 0
+	// The versions are only deleted if their audit events are recorded as well
+	if err := d.inTransaction(ctx, func(txClient *databaseImpl) error {
+		var expired []RecordVersionDBEntry
+		if tmp := txClient.session(ctx).
+			Select("id", "record_id", "enc_key_id").
+			Where("expires_at IS NOT NULL AND expires_at <= ?", now).
+			Order("id asc").
+			Find(&expired); tmp.Error != nil {
+			return fmt.Errorf("failed to list expired record versions [%w]", tmp.Error)
+		}
+		if len(expired) == 0 {
+			return nil
+		}
+
+		expiredIDs := make([]string, len(expired))
+		for idx, entry := range expired {
+			expiredIDs[idx] = entry.ID
+		}
+		for batch := range slices.Chunk(expiredIDs, purgeVersionsBatchSize) {
+			if tmp := txClient.session(ctx).
+				Where("id IN ?", batch).
+				Delete(&RecordVersionDBEntry{}); tmp.Error != nil {
+				return fmt.Errorf("failed to delete expired record versions [%w]", tmp.Error)
+			}
+		}
+
+		// Record these events
+		for _, entry := range expired {
+			if _, err := txClient.defineNewSystemEvent(
+				ctx, models.SystemEventTypeDeleteRecordVersion,
+				models.SystemEventRecordVersionRelated{
+					RecordID: entry.RecordID, VersionID: entry.ID, EncKeyID: entry.EncKeyID,
+				},
+			); err != nil {
+				return fmt.Errorf("failed to log delete record version audit event [%w]", err)
+			}
+		}
+		purged = len(expired)
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+/*
+RestoreRecord insert a data record entry from a backup as is
+
+	@param ctx context.Context - execution context
+	@param entry models.Record - the backed up entry
+*/
+func (d *databaseImpl) RestoreRecord(ctx context.Context, entry models.Record) error {
+	newEntry := RecordDBEntry{Record: entry}
+	if err := d.validator.Struct(&newEntry); err != nil {
+		return fmt.Errorf("backed up data record %s is invalid [%w]", entry.ID, err)
+	}
+
+	if tmp := d.session(ctx).Create(&newEntry); tmp.Error != nil {
+		return fmt.Errorf("data record %s restore failed [%w]", entry.ID, tmp.Error)
+	}
+	return nil
+}
+
+/*
+RestoreRecordVersion insert a data record version entry from a backup as is
+
+	@param ctx context.Context - execution context
+	@param entry models.RecordVersion - the backed up entry
+*/
+func (d *databaseImpl) RestoreRecordVersion(ctx context.Context, entry models.RecordVersion) error {
+	newEntry := RecordVersionDBEntry{RecordVersion: entry}
+	if err := d.validator.Struct(&newEntry); err != nil {
+		return fmt.Errorf("backed up record version %s is invalid [%w]", entry.ID, err)
+	}
+
+	// The version is sequenced as of its restore, like a newly recorded one
+	storedEntry := d.encodeForStorage(entry)
+	return d.inTransaction(ctx, func(txClient *databaseImpl) error {
+		sequence, err := txClient.nextSequence(ctx, recordVersionSequence)
+		if err != nil {
+			return fmt.Errorf("failed to sequence restored record version %s [%w]", entry.ID, err)
+		}
+		storedEntry.Sequence = sequence
+
+		if tmp := txClient.session(ctx).Create(&storedEntry); tmp.Error != nil {
+			return fmt.Errorf("record version %s restore failed [%w]", entry.ID, tmp.Error)
+		}
+		return nil
+	})
+}
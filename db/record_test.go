@@ -3,7 +3,9 @@ package db_test
 import (
 	"context"
 	"fmt"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/alwitt/haven/db"
 	"github.com/alwitt/haven/models"
@@ -12,6 +14,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/oklog/ulid/v2"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
@@ -39,7 +42,7 @@ func TestDBCreateDataRecord(t *testing.T) {
 	var rec1 models.Record
 	rec1Name := uuid.NewString()
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
-		r, err := dbClient.DefineNewRecord(ctx, rec1Name)
+		r, err := dbClient.DefineNewRecord(ctx, "", rec1Name)
 		if err != nil {
 			return err
 		}
@@ -64,7 +67,7 @@ func TestDBCreateDataRecord(t *testing.T) {
 	var rec2 models.Record
 	rec2Name := uuid.NewString()
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
-		r, err := dbClient.DefineNewRecord(ctx, rec2Name)
+		r, err := dbClient.DefineNewRecord(ctx, "", rec2Name)
 		if err != nil {
 			return err
 		}
@@ -87,7 +90,7 @@ func TestDBCreateDataRecord(t *testing.T) {
 	// -------------------------------------------------------------------------
 	// 5 – Define a new data record using the same name as test record 1 (should fail)
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
-		_, err := dbClient.DefineNewRecord(ctx, rec1Name)
+		_, err := dbClient.DefineNewRecord(ctx, "", rec1Name)
 		return err
 	})
 	assert.Error(err) // duplicate name should trigger an error
@@ -111,7 +114,7 @@ func TestDBCreateDataRecord(t *testing.T) {
 	var rec3 models.Record
 	rec3Name := rec1Name
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
-		r, err := dbClient.DefineNewRecord(ctx, rec3Name)
+		r, err := dbClient.DefineNewRecord(ctx, "", rec3Name)
 		if err != nil {
 			return err
 		}
@@ -204,7 +207,7 @@ func TestDBFindRecordByName(t *testing.T) {
 	var rec1 models.Record
 	rec1Name := uuid.NewString()
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
-		r, err := dbClient.DefineNewRecord(ctx, rec1Name)
+		r, err := dbClient.DefineNewRecord(ctx, "", rec1Name)
 		if err != nil {
 			return err
 		}
@@ -228,7 +231,7 @@ func TestDBFindRecordByName(t *testing.T) {
 	var rec2 models.Record
 	rec2Name := uuid.NewString()
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
-		r, err := dbClient.DefineNewRecord(ctx, rec2Name)
+		r, err := dbClient.DefineNewRecord(ctx, "", rec2Name)
 		if err != nil {
 			return err
 		}
@@ -250,7 +253,7 @@ func TestDBFindRecordByName(t *testing.T) {
 
 	// ---------- Fetch record 1 by name ----------
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
-		r, err := dbClient.GetRecordByName(ctx, rec1Name)
+		r, err := dbClient.GetRecordByName(ctx, "", rec1Name)
 		if err != nil {
 			return err
 		}
@@ -262,7 +265,7 @@ func TestDBFindRecordByName(t *testing.T) {
 
 	// ---------- Fetch record 2 by name ----------
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
-		r, err := dbClient.GetRecordByName(ctx, rec2Name)
+		r, err := dbClient.GetRecordByName(ctx, "", rec2Name)
 		if err != nil {
 			return err
 		}
@@ -304,7 +307,7 @@ func TestDBListRecords(t *testing.T) {
 
 	// Record 1
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
-		r, err := dbClient.DefineNewRecord(ctx, rec1Name)
+		r, err := dbClient.DefineNewRecord(ctx, "", rec1Name)
 		if err != nil {
 			return err
 		}
@@ -315,7 +318,7 @@ func TestDBListRecords(t *testing.T) {
 
 	// Record 2
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
-		r, err := dbClient.DefineNewRecord(ctx, rec2Name)
+		r, err := dbClient.DefineNewRecord(ctx, "", rec2Name)
 		if err != nil {
 			return err
 		}
@@ -326,7 +329,7 @@ func TestDBListRecords(t *testing.T) {
 
 	// Record 3
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
-		r, err := dbClient.DefineNewRecord(ctx, rec3Name)
+		r, err := dbClient.DefineNewRecord(ctx, "", rec3Name)
 		if err != nil {
 			return err
 		}
@@ -359,3 +362,681 @@ func TestDBListRecords(t *testing.T) {
 	assert.Equal(rec2Name, nameMap[rec2.ID])
 	assert.Equal(rec3Name, nameMap[rec3.ID])
 }
+
+// TestDBFindDuplicateRecordNames verifies that Database.FindDuplicateRecordNames reports
+// record names shared by multiple records.
+func TestDBFindDuplicateRecordNames(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// Create a unique temporary DB file for this test
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	// Create a new DB connection
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	// Create database tables
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	// Case 0: no duplicates
+	rec0Name := uuid.NewString()
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			_, err := dbClient.DefineNewRecord(ctx, "", rec0Name)
+			return err
+		},
+	))
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		dups, err := dbClient.FindDuplicateRecordNames(ctx)
+		assert.Nil(err)
+		assert.Empty(dups)
+		return err
+	}))
+
+	// Disable the unique record name constraint
+	assert.Nil(uut.RunSQLInTransaction(utCtx, func(_ context.Context, tx *gorm.DB) error {
+		return tx.Migrator().DropIndex(&db.RecordDBEntry{}, "idx_records_namespace_name")
+	}))
+
+	// Case 1: insert records with duplicate names
+	rec1Name := uuid.NewString()
+	rec1IDs := []string{}
+	rec2Name := uuid.NewString()
+	rec2IDs := []string{}
+	for itr := 0; itr < 3; itr++ {
+		assert.Nil(uut.UseDatabaseInTransaction(
+			utCtx, func(ctx context.Context, dbClient db.Database) error {
+				r, err := dbClient.DefineNewRecord(ctx, "", rec1Name)
+				if err != nil {
+					return err
+				}
+				rec1IDs = append(rec1IDs, r.ID)
+				if itr < 2 {
+					r, err = dbClient.DefineNewRecord(ctx, "", rec2Name)
+					if err != nil {
+						return err
+					}
+					rec2IDs = append(rec2IDs, r.ID)
+				}
+				return nil
+			},
+		))
+	}
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		dups, err := dbClient.FindDuplicateRecordNames(ctx)
+		assert.Nil(err)
+		assert.Len(dups, 2)
+		assert.ElementsMatch(rec1IDs, dups[rec1Name])
+		assert.ElementsMatch(rec2IDs, dups[rec2Name])
+		assert.NotContains(dups, rec0Name)
+		return err
+	}))
+}
+
+// TestDBArchiveRecords verifies that archived records are hidden from the default
+// listing, and can be listed explicitly.
+func TestDBArchiveRecords(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// Create a unique temporary DB file for this test
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	// Create database tables
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	// Define test records
+	records := []models.Record{}
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			for itr := 0; itr < 3; itr++ {
+				rec, err := dbClient.DefineNewRecord(ctx, "", uuid.NewString())
+				assert.Nil(err)
+				assert.Equal(models.RecordStateActive, rec.State)
+				records = append(records, rec)
+			}
+			return nil
+		},
+	))
+
+	listRecordIDs := func(filters db.RecordQueryFilter) map[string]models.RecordStateENUMType {
+		result := map[string]models.RecordStateENUMType{}
+		assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+			entries, err := dbClient.ListRecords(ctx, filters)
+			assert.Nil(err)
+			for _, entry := range entries {
+				result[entry.ID] = entry.State
+			}
+			return err
+		}))
+		return result
+	}
+
+	// Archive two records
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			return dbClient.SetRecordsState(
+				ctx, []string{records[0].ID, records[1].ID}, models.RecordStateArchived,
+			)
+		},
+	))
+
+	// Archived records are hidden by default
+	assert.Equal(
+		map[string]models.RecordStateENUMType{records[2].ID: models.RecordStateActive},
+		listRecordIDs(db.RecordQueryFilter{}),
+	)
+
+	// List the archived records
+	assert.Equal(
+		map[string]models.RecordStateENUMType{
+			records[0].ID: models.RecordStateArchived,
+			records[1].ID: models.RecordStateArchived,
+		},
+		listRecordIDs(db.RecordQueryFilter{
+			State: []models.RecordStateENUMType{models.RecordStateArchived},
+		}),
+	)
+
+	// List all records
+	assert.Len(listRecordIDs(db.RecordQueryFilter{
+		State: []models.RecordStateENUMType{models.RecordStateActive, models.RecordStateArchived},
+	}), 3)
+
+	// Re-activate one record
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			return dbClient.SetRecordsState(ctx, []string{records[0].ID}, models.RecordStateActive)
+		},
+	))
+	assert.Len(listRecordIDs(db.RecordQueryFilter{}), 2)
+
+	// Unknown record
+	assert.Error(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			return dbClient.SetRecordsState(ctx, []string{uuid.NewString()}, models.RecordStateArchived)
+		},
+	))
+
+	// Verify the audit events
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		events, err := dbClient.ListSystemEvents(ctx, db.SystemEventQueryFilter{
+			EventTypes: []models.SystemEventTypeENUMType{
+				models.SystemEventTypeArchiveRecord, models.SystemEventTypeActivateRecord,
+			},
+		})
+		assert.Nil(err)
+		assert.Len(events, 3)
+		return err
+	}))
+}
+
+// TestDBListRecordsAfterID verifies paging through data records with `AfterID`.
+func TestDBListRecordsAfterID(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// Create a unique temporary DB file for this test
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	// Create database tables
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	// Define test records
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			for itr := 0; itr < 5; itr++ {
+				_, err := dbClient.DefineNewRecord(ctx, "", uuid.NewString())
+				assert.Nil(err)
+			}
+			return nil
+		},
+	))
+
+	// Reference listing
+	var allRecords []models.Record
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		allRecords, err = dbClient.ListRecords(ctx, db.RecordQueryFilter{})
+		return err
+	}))
+	assert.Len(allRecords, 5)
+
+	// Page through the records
+	pageSize := 2
+	pagedRecords := []models.Record{}
+	var lastID *string
+	for {
+		var page []models.Record
+		assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+			page, err = dbClient.ListRecords(ctx, db.RecordQueryFilter{
+				CommonListEntryQueryFilter: db.CommonListEntryQueryFilter{
+					Limit: &pageSize, AfterID: lastID,
+				},
+			})
+			return err
+		}))
+		if len(page) == 0 {
+			break
+		}
+		assert.LessOrEqual(len(page), pageSize)
+		pagedRecords = append(pagedRecords, page...)
+		lastID = &page[len(page)-1].ID
+	}
+	assert.Equal(allRecords, pagedRecords)
+
+	// AfterID and Offset are mutually exclusive
+	offset := 1
+	assert.Error(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		_, err := dbClient.ListRecords(ctx, db.RecordQueryFilter{
+			CommonListEntryQueryFilter: db.CommonListEntryQueryFilter{
+				Offset: &offset, AfterID: &allRecords[0].ID,
+			},
+		})
+		return err
+	}))
+
+	// Unknown cursor
+	unknownID := uuid.NewString()
+	assert.Error(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		_, err := dbClient.ListRecords(ctx, db.RecordQueryFilter{
+			CommonListEntryQueryFilter: db.CommonListEntryQueryFilter{AfterID: &unknownID},
+		})
+		return err
+	}))
+}
+
+// TestDBListRecordsSorted verifies the data record listing sort options.
+func TestDBListRecordsSorted(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// Create a unique temporary DB file for this test
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	// Create database tables
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	// Define test records out of name order
+	testNames := []string{"charlie", "alpha", "delta", "bravo"}
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			for _, name := range testNames {
+				_, err := dbClient.DefineNewRecord(ctx, "", name)
+				assert.Nil(err)
+			}
+			return nil
+		},
+	))
+
+	listNames := func(filters db.RecordQueryFilter) ([]string, error) {
+		names := []string{}
+		return names, uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+			entries, err := dbClient.ListRecords(ctx, filters)
+			for _, entry := range entries {
+				names = append(names, entry.Name)
+			}
+			return err
+		})
+	}
+
+	// Default is newest first
+	names, err := listNames(db.RecordQueryFilter{})
+	assert.Nil(err)
+	assert.Equal([]string{"bravo", "delta", "alpha", "charlie"}, names)
+
+	// Oldest first
+	names, err = listNames(db.RecordQueryFilter{SortAscending: true})
+	assert.Nil(err)
+	assert.Equal(testNames, names)
+
+	// By name
+	names, err = listNames(db.RecordQueryFilter{SortBy: db.SortByName, SortAscending: true})
+	assert.Nil(err)
+	assert.Equal([]string{"alpha", "bravo", "charlie", "delta"}, names)
+	names, err = listNames(db.RecordQueryFilter{SortBy: db.SortByName})
+	assert.Nil(err)
+	assert.Equal([]string{"delta", "charlie", "bravo", "alpha"}, names)
+
+	// Page by name
+	limit := 2
+	names, err = listNames(db.RecordQueryFilter{
+		CommonListEntryQueryFilter: db.CommonListEntryQueryFilter{Limit: &limit},
+		SortBy:                     db.SortByName,
+		SortAscending:              true,
+	})
+	assert.Nil(err)
+	assert.Equal([]string{"alpha", "bravo"}, names)
+
+	// Only allow listed columns are accepted
+	_, err = listNames(db.RecordQueryFilter{SortBy: "name; DROP TABLE records"})
+	assert.Error(err)
+}
+
+// TestDBNotFoundAndConflictErrors verifies that missing entries and duplicate record names
+// are reported with the package sentinel errors.
+func TestDBNotFoundAndConflictErrors(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// Create a unique temporary DB file for this test
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	// Create database tables
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		_, err := dbClient.GetRecord(ctx, uuid.NewString())
+		assert.ErrorIs(err, db.ErrRecordNotFound)
+
+		_, err = dbClient.GetRecordByName(ctx, "", uuid.NewString())
+		assert.ErrorIs(err, db.ErrRecordNotFound)
+
+		assert.ErrorIs(dbClient.DeleteRecord(ctx, uuid.NewString()), db.ErrRecordNotFound)
+		assert.ErrorIs(
+			dbClient.MarkRecordRead(ctx, uuid.NewString(), time.Now()), db.ErrRecordNotFound,
+		)
+
+		_, err = dbClient.GetRecordVersion(ctx, ulid.Make().String())
+		assert.ErrorIs(err, db.ErrVersionNotFound)
+
+		_, err = dbClient.GetEncryptionKey(ctx, uuid.NewString())
+		assert.ErrorIs(err, db.ErrEncryptionKeyNotFound)
+		return nil
+	}))
+
+	// Duplicate record name
+	recordName := uuid.NewString()
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			_, err := dbClient.DefineNewRecord(ctx, "", recordName)
+			return err
+		},
+	))
+	err = uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			_, err := dbClient.DefineNewRecord(ctx, "", recordName)
+			return err
+		},
+	)
+	assert.ErrorIs(err, db.ErrDuplicateRecordName)
+
+	// The transaction remains usable after the failed insert
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			_, err := dbClient.DefineNewRecord(ctx, "", recordName)
+			assert.ErrorIs(err, db.ErrDuplicateRecordName)
+			_, err = dbClient.GetRecordByName(ctx, "", recordName)
+			assert.Nil(err)
+			_, err = dbClient.DefineNewRecord(ctx, "", uuid.NewString())
+			return err
+		},
+	))
+}
+
+// TestDBRecordNamespaces verifies that record names are unique within a namespace, and that
+// records can be listed by namespace.
+func TestDBRecordNamespaces(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// Create a unique temporary DB file for this test
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	// Create database tables
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	// The same name in different namespaces
+	recordName := "db/password"
+	recordIDs := map[string]string{}
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			for _, namespace := range []string{"", "app1", "app2"} {
+				record, err := dbClient.DefineNewRecord(ctx, namespace, recordName)
+				assert.Nil(err)
+				assert.Equal(namespace, record.Namespace)
+				recordIDs[namespace] = record.ID
+			}
+			_, err := dbClient.DefineNewRecord(ctx, "app1", "db/user")
+			assert.Nil(err)
+			return nil
+		},
+	))
+
+	// The same name within a namespace
+	err = uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			_, err := dbClient.DefineNewRecord(ctx, "app1", recordName)
+			return err
+		},
+	)
+	assert.ErrorIs(err, db.ErrDuplicateRecordName)
+
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		for namespace, recordID := range recordIDs {
+			record, err := dbClient.GetRecordByName(ctx, namespace, recordName)
+			assert.Nil(err)
+			assert.Equal(recordID, record.ID)
+		}
+		_, err := dbClient.GetRecordByName(ctx, "app3", recordName)
+		assert.ErrorIs(err, db.ErrRecordNotFound)
+
+		// List by namespace
+		namespace := "app1"
+		records, err := dbClient.ListRecords(ctx, db.RecordQueryFilter{
+			Namespace: &namespace, SortBy: db.SortByName, SortAscending: true,
+		})
+		assert.Nil(err)
+		assert.Len(records, 2)
+		assert.Equal(recordName, records[0].Name)
+		assert.Equal("db/user", records[1].Name)
+
+		namespace = ""
+		count, err := dbClient.CountRecords(ctx, db.RecordQueryFilter{Namespace: &namespace})
+		assert.Nil(err)
+		assert.Equal(int64(1), count)
+
+		// Every namespace
+		count, err = dbClient.CountRecords(ctx, db.RecordQueryFilter{})
+		assert.Nil(err)
+		assert.Equal(int64(4), count)
+		return nil
+	}))
+}
+
+// TestDBRecordMetadata verifies setting, overwriting, and filtering by record metadata.
+func TestDBRecordMetadata(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// Create a unique temporary DB file for this test
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	// Create database tables
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	records := []models.Record{}
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			for idx := 0; idx < 3; idx++ {
+				record, err := dbClient.DefineNewRecord(ctx, "", uuid.NewString())
+				assert.Nil(err)
+				records = append(records, record)
+			}
+			return nil
+		},
+	))
+
+	// Records start with no metadata
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		metadata, err := dbClient.GetRecordMetadata(ctx, records[0].ID)
+		assert.Nil(err)
+		assert.Empty(metadata)
+		return nil
+	}))
+
+	// Set metadata
+	labels := []map[string]string{
+		{"environment": "prod", "owner": "team-a"},
+		{"environment": "prod", "owner": "team-b"},
+		{"environment": "dev", "owner": "team-a"},
+	}
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			for idx, record := range records {
+				assert.Nil(dbClient.SetRecordMetadata(ctx, record.ID, labels[idx]))
+			}
+			return nil
+		},
+	))
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		for idx, record := range records {
+			metadata, err := dbClient.GetRecordMetadata(ctx, record.ID)
+			assert.Nil(err)
+			assert.Equal(labels[idx], metadata)
+		}
+		return nil
+	}))
+
+	// Filter by metadata
+	listIDs := func(filter map[string]string) []string {
+		ids := []string{}
+		assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+			listed, err := dbClient.ListRecords(ctx, db.RecordQueryFilter{MetadataFilter: filter})
+			assert.Nil(err)
+			for _, record := range listed {
+				ids = append(ids, record.ID)
+			}
+			return nil
+		}))
+		return ids
+	}
+	assert.ElementsMatch(
+		[]string{records[0].ID, records[1].ID}, listIDs(map[string]string{"environment": "prod"}),
+	)
+	assert.ElementsMatch(
+		[]string{records[0].ID},
+		listIDs(map[string]string{"environment": "prod", "owner": "team-a"}),
+	)
+	assert.Empty(listIDs(map[string]string{"rotation-policy": "90d"}))
+
+	// Overwrite replaces all the metadata
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			return dbClient.SetRecordMetadata(
+				ctx, records[0].ID, map[string]string{"rotation-policy": "90d"},
+			)
+		},
+	))
+	assert.ElementsMatch([]string{records[1].ID}, listIDs(map[string]string{"environment": "prod"}))
+	assert.ElementsMatch(
+		[]string{records[0].ID}, listIDs(map[string]string{"rotation-policy": "90d"}),
+	)
+
+	// Clear the metadata
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			assert.ErrorIs(
+				dbClient.SetRecordMetadata(ctx, uuid.NewString(), nil), db.ErrRecordNotFound,
+			)
+			return dbClient.SetRecordMetadata(ctx, records[0].ID, nil)
+		},
+	))
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		metadata, err := dbClient.GetRecordMetadata(ctx, records[0].ID)
+		assert.Nil(err)
+		assert.Empty(metadata)
+
+		// Every change is audited
+		events, err := dbClient.ListSystemEvents(ctx, db.SystemEventQueryFilter{
+			EventTypes: []models.SystemEventTypeENUMType{
+				models.SystemEventTypeUpdateRecordMetadata,
+			},
+		})
+		assert.Nil(err)
+		assert.Len(events, 5)
+		validate := validator.New()
+		assert.Nil(models.RegisterWithValidator(validate))
+		meta, err := events[0].ParseMetadata(validate)
+		assert.Nil(err)
+		assert.Equal(records[0].ID, meta.(models.SystemEventDataRecordRelated).RecordID)
+		return nil
+	}))
+}
+
+// TestDBScanRecords verifies `Database.ScanRecords` delivers every record across pages in
+// name order, and releases the connection between pages so the handler can run queries.
+func TestDBScanRecords(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	// A single connection; a query run while a cursor is open would wait forever
+	uut, err := db.NewConnectionWithOptions(
+		db.GetSqliteDialector(testDB), logger.Error, db.ConnectionOptions{MaxOpenConns: 1},
+	)
+	assert.Nil(err)
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	// Records sharing a name in different namespaces are ordered by ID
+	expected := []string{}
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			for itr := 0; itr < 120; itr++ {
+				name := fmt.Sprintf("scan-%03d", itr)
+				for _, namespace := range []string{"", "team-a"} {
+					record, err := dbClient.DefineNewRecord(ctx, namespace, name)
+					if err != nil {
+						return err
+					}
+					expected = append(expected, record.ID)
+				}
+			}
+			_, err := dbClient.DefineNewRecord(ctx, "", "other")
+			return err
+		},
+	))
+
+	scanCtx, cancel := context.WithTimeout(utCtx, time.Second*10)
+	defer cancel()
+	scanned := []string{}
+	names := []string{}
+	assert.Nil(uut.UseDatabase(scanCtx, func(ctx context.Context, dbClient db.Database) error {
+		return dbClient.ScanRecords(
+			ctx, db.RecordQueryFilter{NamePrefix: "scan-"}, func(record models.Record) error {
+				readBack, err := dbClient.GetRecord(ctx, record.ID)
+				if err != nil {
+					return err
+				}
+				scanned = append(scanned, readBack.ID)
+				names = append(names, readBack.Name)
+				return nil
+			},
+		)
+	}))
+	assert.ElementsMatch(expected, scanned)
+	assert.True(slices.IsSorted(names))
+	for itr := 0; itr+1 < len(scanned); itr += 2 {
+		assert.Equal(names[itr], names[itr+1])
+		assert.Less(scanned[itr], scanned[itr+1])
+	}
+
+	// An error from the handler stops the scan
+	handled := 0
+	assert.ErrorIs(
+		uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+			return dbClient.ScanRecords(ctx, db.RecordQueryFilter{}, func(models.Record) error {
+				handled++
+				if handled == 5 {
+					return context.Canceled
+				}
+				return nil
+			})
+		}),
+		context.Canceled,
+	)
+	assert.Equal(5, handled)
+}
@@ -2,6 +2,7 @@ package db_test
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"testing"
 	"time"
@@ -9,9 +10,11 @@ import (
 	"github.com/alwitt/haven/db"
 	"github.com/alwitt/haven/models"
 	"github.com/apex/log"
+	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"github.com/oklog/ulid/v2"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
@@ -25,6 +28,7 @@ import (
 //   - Get back test version 1 and verify its content.
 //   - Define a new data record version for `test record 1` using `test key 1` (test version 2).
 //   - Get back test version 2 and verify its content.
+//   - Verify an audit event is recorded for each version.
 func TestDBCreateDataRecordVersion(t *testing.T) {
 	assert := assert.New(t)
 	log.SetLevel(log.DebugLevel)
@@ -47,7 +51,7 @@ func TestDBCreateDataRecordVersion(t *testing.T) {
 	var rec1 models.Record
 	rec1Name := uuid.NewString()
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
-		r, err := dbClient.DefineNewRecord(ctx, rec1Name)
+		r, err := dbClient.DefineNewRecord(ctx, "", rec1Name)
 		if err != nil {
 			return err
 		}
@@ -61,7 +65,9 @@ func TestDBCreateDataRecordVersion(t *testing.T) {
 	var key1 models.EncryptionKey
 	keyMaterial1 := []byte(uuid.NewString())
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
-		ek, err := dbClient.RecordEncryptionKey(ctx, keyMaterial1)
+		ek, err := dbClient.RecordEncryptionKey(
+			ctx, keyMaterial1, "", "", models.AEADTypeXChaCha20Poly1305,
+		)
 		if err != nil {
 			return err
 		}
@@ -79,6 +85,7 @@ func TestDBCreateDataRecordVersion(t *testing.T) {
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
 		v, err := dbClient.DefineNewVersionForRecord(
 			ctx, rec1, key1, version1Value, version1Nonce, version1Timestamp,
+			db.NewVersionOptions{},
 		)
 		if err != nil {
 			return err
@@ -112,6 +119,7 @@ func TestDBCreateDataRecordVersion(t *testing.T) {
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
 		v, err := dbClient.DefineNewVersionForRecord(
 			ctx, rec1, key1, version2Value, version2Nonce, version2Timestamp,
+			db.NewVersionOptions{},
 		)
 		if err != nil {
 			return err
@@ -135,6 +143,33 @@ func TestDBCreateDataRecordVersion(t *testing.T) {
 		return nil
 	})
 	assert.Nil(err)
+
+	// --------------------------------------------------
+	// 7 – Verify each version write is audited
+	validate := validator.New()
+	assert.Nil(models.RegisterWithValidator(validate))
+	err = uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		events, err := dbClient.ListSystemEvents(ctx, db.SystemEventQueryFilter{
+			EventTypes: []models.SystemEventTypeENUMType{models.SystemEventTypeNewRecordVersion},
+		})
+		if err != nil {
+			return err
+		}
+		assert.Len(events, 2)
+		versionIDs := map[string]bool{}
+		for _, e := range events {
+			meta, err := e.ParseMetadata(validate)
+			assert.Nil(err)
+			versionMeta, ok := meta.(models.SystemEventRecordVersionRelated)
+			assert.True(ok)
+			assert.Equal(rec1.ID, versionMeta.RecordID)
+			assert.Equal(key1.ID, versionMeta.EncKeyID)
+			versionIDs[versionMeta.VersionID] = true
+		}
+		assert.Equal(map[string]bool{ver1.ID: true, ver2.ID: true}, versionIDs)
+		return nil
+	})
+	assert.Nil(err)
 }
 
 // TestDBCreateDataRecordVersionDelete verifies that record versions are deleted
@@ -175,7 +210,7 @@ func TestDBCreateDataRecordVersionDelete(t *testing.T) {
 	var rec1 models.Record
 	rec1Name := uuid.NewString()
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
-		r, err := dbClient.DefineNewRecord(ctx, rec1Name)
+		r, err := dbClient.DefineNewRecord(ctx, "", rec1Name)
 		if err != nil {
 			return err
 		}
@@ -188,7 +223,7 @@ func TestDBCreateDataRecordVersionDelete(t *testing.T) {
 	var rec2 models.Record
 	rec2Name := uuid.NewString()
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
-		r, err := dbClient.DefineNewRecord(ctx, rec2Name)
+		r, err := dbClient.DefineNewRecord(ctx, "", rec2Name)
 		if err != nil {
 			return err
 		}
@@ -201,7 +236,9 @@ func TestDBCreateDataRecordVersionDelete(t *testing.T) {
 	var key1 models.EncryptionKey
 	keyMaterial1 := []byte(uuid.NewString())
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
-		ek, err := dbClient.RecordEncryptionKey(ctx, keyMaterial1)
+		ek, err := dbClient.RecordEncryptionKey(
+			ctx, keyMaterial1, "", "", models.AEADTypeXChaCha20Poly1305,
+		)
 		if err != nil {
 			return err
 		}
@@ -218,6 +255,7 @@ func TestDBCreateDataRecordVersionDelete(t *testing.T) {
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
 		v, err := dbClient.DefineNewVersionForRecord(
 			ctx, rec1, key1, version1Value, version1Nonce, version1Timestamp,
+			db.NewVersionOptions{},
 		)
 		if err != nil {
 			return err
@@ -249,6 +287,7 @@ func TestDBCreateDataRecordVersionDelete(t *testing.T) {
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
 		v, err := dbClient.DefineNewVersionForRecord(
 			ctx, rec2, key1, version2Value, version2Nonce, version2Timestamp,
+			db.NewVersionOptions{},
 		)
 		if err != nil {
 			return err
@@ -322,7 +361,7 @@ func TestDBListDataRecordVersion(t *testing.T) {
 	rec2Name := uuid.NewString()
 
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
-		r, err := dbClient.DefineNewRecord(ctx, rec1Name)
+		r, err := dbClient.DefineNewRecord(ctx, "", rec1Name)
 		if err != nil {
 			return err
 		}
@@ -332,7 +371,7 @@ func TestDBListDataRecordVersion(t *testing.T) {
 	assert.Nil(err)
 
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
-		r, err := dbClient.DefineNewRecord(ctx, rec2Name)
+		r, err := dbClient.DefineNewRecord(ctx, "", rec2Name)
 		if err != nil {
 			return err
 		}
@@ -347,7 +386,9 @@ func TestDBListDataRecordVersion(t *testing.T) {
 	key2Mat := []byte(uuid.NewString())
 
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
-		ek, err := dbClient.RecordEncryptionKey(ctx, key1Mat)
+		ek, err := dbClient.RecordEncryptionKey(
+			ctx, key1Mat, "", "", models.AEADTypeXChaCha20Poly1305,
+		)
 		if err != nil {
 			return err
 		}
@@ -357,7 +398,9 @@ func TestDBListDataRecordVersion(t *testing.T) {
 	assert.Nil(err)
 
 	err = uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
-		ek, err := dbClient.RecordEncryptionKey(ctx, key2Mat)
+		ek, err := dbClient.RecordEncryptionKey(
+			ctx, key2Mat, "", "", models.AEADTypeXChaCha20Poly1305,
+		)
 		if err != nil {
 			return err
 		}
@@ -377,7 +420,10 @@ func TestDBListDataRecordVersion(t *testing.T) {
 		return newVersion, uut.UseDatabaseInTransaction(
 			utCtx, func(ctx context.Context, dbClient db.Database) error {
 				var err error
-				newVersion, err = dbClient.DefineNewVersionForRecord(ctx, rec, key, value, nonce, now)
+				newVersion, err = dbClient.DefineNewVersionForRecord(
+					ctx, rec, key, value, nonce, now,
+					db.NewVersionOptions{},
+				)
 				return err
 			},
 		)
@@ -509,3 +555,1289 @@ func TestDBListDataRecordVersion(t *testing.T) {
 	})
 	assert.Nil(err)
 }
+
+// TestDBPurgeExpiredRecordVersions verifies that `Database.PurgeExpiredVersions` only
+// deletes versions past their expiry.
+func TestDBPurgeExpiredRecordVersions(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// Create a unique temporary DB file for this test
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	// Create database tables
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	now := time.Now().UTC()
+	expired := now.Add(-time.Minute)
+	notExpired := now.Add(time.Hour)
+
+	var permanentVer, expiredVer, liveVer models.RecordVersion
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			rec, err := dbClient.DefineNewRecord(ctx, "", uuid.NewString())
+			assert.Nil(err)
+			key, err := dbClient.RecordEncryptionKey(
+				ctx, []byte(uuid.NewString()), "", "", models.AEADTypeXChaCha20Poly1305,
+			)
+			assert.Nil(err)
+
+			defineVersion := func(expiresAt *time.Time) models.RecordVersion {
+				version, err := dbClient.DefineNewVersionForRecord(
+					ctx,
+					rec,
+					key,
+					[]byte(uuid.NewString()),
+					[]byte(uuid.NewString()),
+					now,
+					db.NewVersionOptions{ExpiresAt: expiresAt},
+				)
+				assert.Nil(err)
+				return version
+			}
+
+			permanentVer = defineVersion(nil)
+			expiredVer = defineVersion(&expired)
+			liveVer = defineVersion(&notExpired)
+			return nil
+		},
+	))
+
+	// Purge
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			purged, err := dbClient.PurgeExpiredVersions(ctx, now)
+			assert.Nil(err)
+			assert.Equal(1, purged)
+			return err
+		},
+	))
+
+	// Verify
+	validate := validator.New()
+	assert.Nil(models.RegisterWithValidator(validate))
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		_, err := dbClient.GetRecordVersion(ctx, permanentVer.ID)
+		assert.Nil(err)
+		_, err = dbClient.GetRecordVersion(ctx, expiredVer.ID)
+		assert.Error(err)
+		version, err := dbClient.GetRecordVersion(ctx, liveVer.ID)
+		assert.Nil(err)
+		assert.NotNil(version.ExpiresAt)
+		assert.True(notExpired.Equal(*version.ExpiresAt))
+
+		// The purge is audited
+		events, err := dbClient.ListSystemEvents(ctx, db.SystemEventQueryFilter{
+			EventTypes: []models.SystemEventTypeENUMType{models.SystemEventTypeDeleteRecordVersion},
+		})
+		assert.Nil(err)
+		assert.Len(events, 1)
+		meta, err := events[0].ParseMetadata(validate)
+		assert.Nil(err)
+		versionMeta, ok := meta.(models.SystemEventRecordVersionRelated)
+		assert.True(ok)
+		assert.Equal(expiredVer.ID, versionMeta.VersionID)
+		assert.Equal(expiredVer.RecordID, versionMeta.RecordID)
+		return nil
+	}))
+
+	// Nothing left to purge
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		purged, err := dbClient.PurgeExpiredVersions(ctx, now)
+		assert.Nil(err)
+		assert.Equal(0, purged)
+		return nil
+	}))
+}
+
+// TestDBCountRecordsAndVersions verifies `Database.CountRecords` and
+// `Database.CountRecordVersions` apply the listing filters but ignore pagination.
+func TestDBCountRecordsAndVersions(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// Create a unique temporary DB file for this test
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	// Create database tables
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	now := time.Now().UTC()
+
+	// Define three records, with two versions under the first record
+	var records []models.Record
+	var key models.EncryptionKey
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			for itr := 0; itr < 3; itr++ {
+				rec, err := dbClient.DefineNewRecord(ctx, "", uuid.NewString())
+				assert.Nil(err)
+				records = append(records, rec)
+			}
+			key, err = dbClient.RecordEncryptionKey(
+				ctx, []byte(uuid.NewString()), "", "", models.AEADTypeXChaCha20Poly1305,
+			)
+			assert.Nil(err)
+			for itr := 0; itr < 2; itr++ {
+				_, err := dbClient.DefineNewVersionForRecord(
+					ctx,
+					records[0],
+					key,
+					[]byte(uuid.NewString()),
+					[]byte(uuid.NewString()),
+					now,
+					db.NewVersionOptions{},
+				)
+				assert.Nil(err)
+			}
+			return dbClient.SetRecordsState(
+				ctx, []string{records[2].ID}, models.RecordStateArchived,
+			)
+		},
+	))
+
+	limit := 1
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		// Only active records are counted by default
+		count, err := dbClient.CountRecords(ctx, db.RecordQueryFilter{
+			CommonListEntryQueryFilter: db.CommonListEntryQueryFilter{Limit: &limit},
+		})
+		assert.Nil(err)
+		assert.Equal(int64(2), count)
+
+		count, err = dbClient.CountRecords(ctx, db.RecordQueryFilter{
+			State: []models.RecordStateENUMType{
+				models.RecordStateActive, models.RecordStateArchived,
+			},
+		})
+		assert.Nil(err)
+		assert.Equal(int64(3), count)
+
+		count, err = dbClient.CountRecordVersions(ctx, db.RecordVersionQueryFilter{
+			CommonListEntryQueryFilter: db.CommonListEntryQueryFilter{Limit: &limit},
+		})
+		assert.Nil(err)
+		assert.Equal(int64(2), count)
+
+		count, err = dbClient.CountRecordVersions(ctx, db.RecordVersionQueryFilter{
+			TargetRecordID: &records[1].ID,
+		})
+		assert.Nil(err)
+		assert.Equal(int64(0), count)
+
+		count, err = dbClient.CountRecordVersions(ctx, db.RecordVersionQueryFilter{
+			TargetEncKeyID: &key.ID,
+		})
+		assert.Nil(err)
+		assert.Equal(int64(2), count)
+		return nil
+	}))
+}
+
+// TestDBListRecordsWithVersionStats verifies `Database.ListRecordsWithVersionStats` reports
+// the newest version timestamp and the version count of each record.
+func TestDBListRecordsWithVersionStats(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// Create a unique temporary DB file for this test
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	// Create database tables
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	// Record N has N versions, the newest recorded N hours after now
+	records := []models.Record{}
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			key, err := dbClient.RecordEncryptionKey(
+				ctx, []byte(uuid.NewString()), "", "", models.AEADTypeXChaCha20Poly1305,
+			)
+			assert.Nil(err)
+			for itr := 0; itr < 4; itr++ {
+				record, err := dbClient.DefineNewRecord(ctx, "", fmt.Sprintf("key-%d", itr))
+				assert.Nil(err)
+				records = append(records, record)
+				for version := 1; version <= itr; version++ {
+					_, err := dbClient.DefineNewVersionForRecord(
+						ctx,
+						record,
+						key,
+						[]byte(uuid.NewString()),
+						[]byte(uuid.NewString()),
+						now.Add(time.Duration(version)*time.Hour),
+						db.NewVersionOptions{},
+					)
+					assert.Nil(err)
+				}
+			}
+			return nil
+		},
+	))
+
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		listed, err := dbClient.ListRecordsWithVersionStats(ctx, db.RecordQueryFilter{
+			SortBy: db.SortByName, SortAscending: true,
+		})
+		assert.Nil(err)
+		assert.Len(listed, len(records))
+		for idx, entry := range listed {
+			assert.Equal(records[idx].ID, entry.ID)
+			assert.Equal(records[idx].Name, entry.Name)
+			assert.Equal(int64(idx), entry.VersionCount)
+			if idx == 0 {
+				assert.Nil(entry.LatestVersionAt)
+				continue
+			}
+			if assert.NotNil(entry.LatestVersionAt) {
+				assert.True(now.Add(time.Duration(idx) * time.Hour).Equal(*entry.LatestVersionAt))
+			}
+		}
+
+		// The listing filters apply
+		limit := 1
+		listed, err = dbClient.ListRecordsWithVersionStats(ctx, db.RecordQueryFilter{
+			CommonListEntryQueryFilter: db.CommonListEntryQueryFilter{Limit: &limit},
+			NamePrefix:                 "key-2",
+		})
+		assert.Nil(err)
+		assert.Len(listed, 1)
+		assert.Equal(records[2].ID, listed[0].ID)
+		assert.Equal(int64(2), listed[0].VersionCount)
+		return nil
+	}))
+}
+
+// TestDBListRecordVersionsTimeWindow verifies the creation time window of
+// `RecordVersionQueryFilter` includes its boundaries.
+func TestDBListRecordVersionsTimeWindow(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// Create a unique temporary DB file for this test
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	// Create database tables
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	baseTime := time.Now().UTC().Add(-time.Hour)
+	timestamps := []time.Time{}
+	versionIDs := []string{}
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			rec, err := dbClient.DefineNewRecord(ctx, "", uuid.NewString())
+			assert.Nil(err)
+			key, err := dbClient.RecordEncryptionKey(
+				ctx, []byte(uuid.NewString()), "", "", models.AEADTypeXChaCha20Poly1305,
+			)
+			assert.Nil(err)
+
+			for idx := 0; idx < 3; idx++ {
+				timestamp := baseTime.Add(time.Duration(idx) * time.Minute)
+				version, err := dbClient.DefineNewVersionForRecord(
+					ctx,
+					rec,
+					key,
+					[]byte(uuid.NewString()),
+					[]byte(uuid.NewString()),
+					timestamp,
+					db.NewVersionOptions{},
+				)
+				assert.Nil(err)
+				timestamps = append(timestamps, timestamp)
+				versionIDs = append(versionIDs, version.ID)
+			}
+			return nil
+		},
+	))
+
+	second := time.Second
+	afterStart := timestamps[0].Add(second)
+	beforeEnd := timestamps[2].Add(-second)
+	testCases := []struct {
+		after    *time.Time
+		before   *time.Time
+		expected []string
+	}{
+		{after: &timestamps[1], expected: versionIDs[1:]},
+		{before: &timestamps[1], expected: versionIDs[:2]},
+		{after: &timestamps[1], before: &timestamps[1], expected: versionIDs[1:2]},
+		{after: &afterStart, before: &beforeEnd, expected: versionIDs[1:2]},
+		{after: &timestamps[0], before: &timestamps[2], expected: versionIDs},
+	}
+
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		for _, testCase := range testCases {
+			versions, err := dbClient.ListAllRecordVersions(ctx, db.RecordVersionQueryFilter{
+				CreatedAfter:  testCase.after,
+				CreatedBefore: testCase.before,
+				SortAscending: true,
+			})
+			assert.Nil(err)
+			listed := []string{}
+			for _, version := range versions {
+				listed = append(listed, version.ID)
+			}
+			assert.Equal(testCase.expected, listed)
+		}
+		return nil
+	}))
+}
+
+// TestDBListRecordVersionsAfterVersionID verifies that paging through the versions of a
+// record with `RecordVersionQueryFilter.AfterVersionID` sees every version exactly once,
+// including versions committed behind the cursor's version ID.
+func TestDBListRecordVersionsAfterVersionID(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// Create a unique temporary DB file for this test
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	// Create database tables
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	now := time.Now().UTC()
+
+	var record models.Record
+	var key models.EncryptionKey
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			record, err = dbClient.DefineNewRecord(ctx, "", uuid.NewString())
+			assert.Nil(err)
+			key, err = dbClient.RecordEncryptionKey(
+				ctx, []byte(uuid.NewString()), "", "", models.AEADTypeXChaCha20Poly1305,
+			)
+			return err
+		},
+	))
+
+	defineVersion := func(timestamp time.Time) models.RecordVersion {
+		var version models.RecordVersion
+		assert.Nil(uut.UseDatabaseInTransaction(
+			utCtx, func(ctx context.Context, dbClient db.Database) error {
+				version, err = dbClient.DefineNewVersionForRecord(
+					ctx,
+					record,
+					key,
+					[]byte(uuid.NewString()),
+					[]byte(uuid.NewString()),
+					timestamp,
+					db.NewVersionOptions{},
+				)
+				return err
+			},
+		))
+		return version
+	}
+
+	listPage := func(cursor string, limit int) ([]models.RecordVersion, error) {
+		var versions []models.RecordVersion
+		err := uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+			var err error
+			versions, err = dbClient.ListVersionsOfOneRecord(ctx, record, db.RecordVersionQueryFilter{
+				CommonListEntryQueryFilter: db.CommonListEntryQueryFilter{Limit: &limit},
+				AfterVersionID:             &cursor,
+			})
+			return err
+		})
+		return versions, err
+	}
+
+	// A version whose ID is minted first, but which only commits once paging passed it
+	lateVersion := defineVersion(now)
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		return dbClient.DeleteRecordVersion(ctx, lateVersion.ID)
+	}))
+
+	recordedIDs := []string{}
+	for itr := 0; itr < 5; itr++ {
+		recordedIDs = append(recordedIDs, defineVersion(now.Add(time.Duration(itr)*time.Second)).ID)
+	}
+
+	// Page through the versions, recording new versions between page fetches
+	limit := 2
+	cursor := ""
+	pagedIDs := []string{}
+	for page := 0; ; page++ {
+		versions, err := listPage(cursor, limit)
+		assert.Nil(err)
+		for _, version := range versions {
+			pagedIDs = append(pagedIDs, version.ID)
+		}
+		if len(versions) < limit {
+			break
+		}
+		cursor = versions[len(versions)-1].ID
+
+		switch page {
+		case 0:
+			// The new version has an older timestamp than all others, but it still follows
+			// the cursor
+			recordedIDs = append(recordedIDs, defineVersion(now.Add(-time.Hour)).ID)
+		case 1:
+			// A version ID minted before the cursor's, committed after the cursor was read
+			assert.Less(lateVersion.ID, cursor)
+			assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+				return dbClient.RestoreRecordVersion(ctx, lateVersion)
+			}))
+			recordedIDs = append(recordedIDs, lateVersion.ID)
+		}
+	}
+	assert.Equal(recordedIDs, pagedIDs)
+
+	// Concurrent writers mint version IDs in a different order than they commit. A reader
+	// paging alongside them sees each version once.
+	writers := 4
+	versionsPerWriter := 10
+	written := make(chan string, writers*versionsPerWriter)
+	writersDone := make(chan struct{})
+	go func() {
+		defer close(writersDone)
+		done := make(chan struct{}, writers)
+		for writer := 0; writer < writers; writer++ {
+			go func() {
+				for itr := 0; itr < versionsPerWriter; itr++ {
+					written <- defineVersion(now).ID
+				}
+				done <- struct{}{}
+			}()
+		}
+		for writer := 0; writer < writers; writer++ {
+			<-done
+		}
+	}()
+	seen := map[string]int{}
+	for _, versionID := range pagedIDs {
+		seen[versionID]++
+	}
+	finished := false
+	for !finished {
+		select {
+		case <-writersDone:
+			finished = true
+		default:
+		}
+		for {
+			versions, err := listPage(cursor, limit)
+			assert.Nil(err)
+			for _, version := range versions {
+				seen[version.ID]++
+			}
+			if len(versions) == 0 {
+				break
+			}
+			cursor = versions[len(versions)-1].ID
+		}
+	}
+	close(written)
+	for versionID := range written {
+		assert.Equal(1, seen[versionID], versionID)
+	}
+	assert.Len(seen, len(pagedIDs)+writers*versionsPerWriter)
+
+	// Paging from a deleted version fails
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		return dbClient.DeleteRecordVersion(ctx, cursor)
+	}))
+	_, err = listPage(cursor, limit)
+	assert.ErrorIs(err, db.ErrVersionNotFound)
+
+	// The cursor can not be combined with other paging
+	offset := 1
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		_, err := dbClient.ListVersionsOfOneRecord(ctx, record, db.RecordVersionQueryFilter{
+			CommonListEntryQueryFilter: db.CommonListEntryQueryFilter{Offset: &offset},
+			AfterVersionID:             &cursor,
+		})
+		assert.Error(err)
+		return nil
+	}))
+}
+
+// TestDBListGlobalVersionTimeline verifies `Database.ListGlobalVersionTimeline` interleaves
+// the versions of several records by time, and attaches the record names.
+func TestDBListGlobalVersionTimeline(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// Create a unique temporary DB file for this test
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	// Create database tables
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	now := time.Now().UTC()
+
+	// Three records, whose versions are recorded round robin
+	type expectedEntry struct {
+		versionID string
+		name      string
+	}
+	expected := []expectedEntry{}
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			key, err := dbClient.RecordEncryptionKey(
+				ctx, []byte(uuid.NewString()), "", "", models.AEADTypeXChaCha20Poly1305,
+			)
+			assert.Nil(err)
+
+			records := []models.Record{}
+			for itr := 0; itr < 3; itr++ {
+				record, err := dbClient.DefineNewRecord(ctx, "", uuid.NewString())
+				assert.Nil(err)
+				records = append(records, record)
+			}
+
+			for itr := 0; itr < 6; itr++ {
+				record := records[itr%len(records)]
+				version, err := dbClient.DefineNewVersionForRecord(
+					ctx,
+					record,
+					key,
+					[]byte(uuid.NewString()),
+					[]byte(uuid.NewString()),
+					now.Add(time.Duration(itr)*time.Second),
+					db.NewVersionOptions{},
+				)
+				assert.Nil(err)
+				expected = append(
+					[]expectedEntry{{versionID: version.ID, name: record.Name}}, expected...,
+				)
+			}
+			return nil
+		},
+	))
+
+	// Newest first, across all records
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		timeline, err := dbClient.ListGlobalVersionTimeline(ctx, db.RecordVersionQueryFilter{})
+		assert.Nil(err)
+		assert.Len(timeline, len(expected))
+		for idx, entry := range timeline {
+			assert.Equal(expected[idx].versionID, entry.ID)
+			assert.Equal(expected[idx].name, entry.Name)
+			assert.Equal("", entry.Namespace)
+		}
+
+		// Paging applies to the timeline
+		limit := 2
+		offset := 1
+		timeline, err = dbClient.ListGlobalVersionTimeline(ctx, db.RecordVersionQueryFilter{
+			CommonListEntryQueryFilter: db.CommonListEntryQueryFilter{Limit: &limit, Offset: &offset},
+		})
+		assert.Nil(err)
+		assert.Len(timeline, 2)
+		assert.Equal(expected[1].versionID, timeline[0].ID)
+		assert.Equal(expected[2].versionID, timeline[1].ID)
+
+		// The version cursor is not supported
+		cursor := ""
+		_, err = dbClient.ListGlobalVersionTimeline(ctx, db.RecordVersionQueryFilter{
+			AfterVersionID: &cursor,
+		})
+		assert.Error(err)
+		return nil
+	}))
+}
+
+// TestDBDeleteRecordVersion verifies `Database.DeleteRecordVersion` and
+// `Database.SumRecordVersionSizes`.
+func TestDBDeleteRecordVersion(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// Create a unique temporary DB file for this test
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	// Create database tables
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	var record models.Record
+	var versions []models.RecordVersion
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			record, err = dbClient.DefineNewRecord(ctx, "", uuid.NewString())
+			assert.Nil(err)
+			key, err := dbClient.RecordEncryptionKey(
+				ctx, []byte(uuid.NewString()), "", "", models.AEADTypeXChaCha20Poly1305,
+			)
+			assert.Nil(err)
+			for itr := 1; itr <= 3; itr++ {
+				version, err := dbClient.DefineNewVersionForRecord(
+					ctx,
+					record,
+					key,
+					make([]byte, itr*10),
+					[]byte(uuid.NewString()),
+					time.Now().UTC(),
+					db.NewVersionOptions{},
+				)
+				assert.Nil(err)
+				versions = append(versions, version)
+			}
+			return nil
+		},
+	))
+
+	// Size of all versions
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		total, err := dbClient.SumRecordVersionSizes(ctx, record.ID)
+		assert.Nil(err)
+		assert.Equal(int64(60), total)
+
+		// Unknown record
+		total, err = dbClient.SumRecordVersionSizes(ctx, uuid.NewString())
+		assert.Nil(err)
+		assert.Equal(int64(0), total)
+		return nil
+	}))
+
+	// Delete the second version
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			return dbClient.DeleteRecordVersion(ctx, versions[1].ID)
+		},
+	))
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		_, err := dbClient.GetRecordVersion(ctx, versions[1].ID)
+		assert.ErrorIs(err, db.ErrVersionNotFound)
+		total, err := dbClient.SumRecordVersionSizes(ctx, record.ID)
+		assert.Nil(err)
+		assert.Equal(int64(40), total)
+
+		events, err := dbClient.ListSystemEvents(ctx, db.SystemEventQueryFilter{
+			EventTypes: []models.SystemEventTypeENUMType{models.SystemEventTypeDeleteRecordVersion},
+		})
+		assert.Nil(err)
+		assert.Len(events, 1)
+		return nil
+	}))
+
+	// Unknown version
+	assert.ErrorIs(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			return dbClient.DeleteRecordVersion(ctx, versions[1].ID)
+		},
+	), db.ErrVersionNotFound)
+}
+
+func TestDBListRecordsWithNoVersions(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// Create a unique temporary DB file for this test
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	// Create database tables
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	// Two records, each with two versions
+	records := []models.Record{}
+	versions := map[string][]models.RecordVersion{}
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			key, err := dbClient.RecordEncryptionKey(
+				ctx, []byte(uuid.NewString()), "", "", models.AEADTypeXChaCha20Poly1305,
+			)
+			assert.Nil(err)
+			for idx := 0; idx < 2; idx++ {
+				record, err := dbClient.DefineNewRecord(ctx, "", uuid.NewString())
+				assert.Nil(err)
+				records = append(records, record)
+				for itr := 0; itr < 2; itr++ {
+					version, err := dbClient.DefineNewVersionForRecord(
+						ctx,
+						record,
+						key,
+						[]byte(uuid.NewString()),
+						[]byte(uuid.NewString()),
+						time.Now().UTC(),
+						db.NewVersionOptions{},
+					)
+					assert.Nil(err)
+					versions[record.ID] = append(versions[record.ID], version)
+				}
+			}
+			return nil
+		},
+	))
+
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		empty, err := dbClient.ListRecordsWithNoVersions(ctx)
+		assert.Nil(err)
+		assert.Empty(empty)
+		return nil
+	}))
+
+	// Delete every version of the first record, and one version of the second
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			for _, version := range versions[records[0].ID] {
+				assert.Nil(dbClient.DeleteRecordVersion(ctx, version.ID))
+			}
+			return dbClient.DeleteRecordVersion(ctx, versions[records[1].ID][0].ID)
+		},
+	))
+
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		empty, err := dbClient.ListRecordsWithNoVersions(ctx)
+		assert.Nil(err)
+		assert.Len(empty, 1)
+		assert.Equal(records[0].ID, empty[0].ID)
+		return nil
+	}))
+}
+
+// TestDBGetLatestVersionKey verifies `Database.GetLatestVersionKey` returns the encryption
+// key of the record's newest version.
+func TestDBGetLatestVersionKey(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// Create a unique temporary DB file for this test
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	// Create database tables
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	now := time.Now().UTC()
+
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			record, err := dbClient.DefineNewRecord(ctx, "", uuid.NewString())
+			assert.Nil(err)
+			keys := []models.EncryptionKey{}
+			for itr := 0; itr < 2; itr++ {
+				key, err := dbClient.RecordEncryptionKey(
+					ctx, []byte(uuid.NewString()), "", "", models.AEADTypeXChaCha20Poly1305,
+				)
+				assert.Nil(err)
+				keys = append(keys, key)
+			}
+			defineVersion := func(key models.EncryptionKey, timestamp time.Time) {
+				_, err := dbClient.DefineNewVersionForRecord(
+					ctx,
+					record,
+					key,
+					[]byte(uuid.NewString()),
+					[]byte(uuid.NewString()),
+					timestamp,
+					db.NewVersionOptions{},
+				)
+				assert.Nil(err)
+			}
+
+			// A record without versions has no key
+			_, err = dbClient.GetLatestVersionKey(ctx, record.ID)
+			assert.ErrorIs(err, db.ErrVersionNotFound)
+
+			// The newest version is the one with the latest timestamp, not the last recorded
+			defineVersion(keys[0], now)
+			defineVersion(keys[1], now.Add(time.Second))
+			defineVersion(keys[0], now.Add(-time.Hour))
+			latestKey, err := dbClient.GetLatestVersionKey(ctx, record.ID)
+			assert.Nil(err)
+			assert.Equal(keys[1].ID, latestKey.ID)
+			assert.Equal(keys[1].EncKeyMaterial, latestKey.EncKeyMaterial)
+
+			defineVersion(keys[0], now.Add(2*time.Second))
+			latestKey, err = dbClient.GetLatestVersionKey(ctx, record.ID)
+			assert.Nil(err)
+			assert.Equal(keys[0].ID, latestKey.ID)
+
+			_, err = dbClient.GetLatestVersionKey(ctx, uuid.NewString())
+			assert.ErrorIs(err, db.ErrRecordNotFound)
+			return nil
+		},
+	))
+}
+
+func TestDBListRecordsOnInactiveKeys(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// Create a unique temporary DB file for this test
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	// Create database tables
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	now := time.Now().UTC()
+
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			keys := []models.EncryptionKey{}
+			for itr := 0; itr < 2; itr++ {
+				key, err := dbClient.RecordEncryptionKey(
+					ctx, []byte(uuid.NewString()), "", "", models.AEADTypeXChaCha20Poly1305,
+				)
+				assert.Nil(err)
+				keys = append(keys, key)
+			}
+			records := []models.Record{}
+			for _, namespace := range []string{"", "", "", "other"} {
+				record, err := dbClient.DefineNewRecord(ctx, namespace, uuid.NewString())
+				assert.Nil(err)
+				records = append(records, record)
+			}
+			defineVersion := func(
+				record models.Record, key models.EncryptionKey, timestamp time.Time,
+			) {
+				_, err := dbClient.DefineNewVersionForRecord(
+					ctx,
+					record,
+					key,
+					[]byte(uuid.NewString()),
+					[]byte(uuid.NewString()),
+					timestamp,
+					db.NewVersionOptions{},
+				)
+				assert.Nil(err)
+			}
+
+			// Record 0 is newest on key 0, record 1 and 3 on key 1. Record 2 has no versions.
+			defineVersion(records[0], keys[1], now)
+			defineVersion(records[0], keys[0], now.Add(time.Second))
+			defineVersion(records[1], keys[0], now)
+			defineVersion(records[1], keys[1], now.Add(time.Second))
+			defineVersion(records[3], keys[1], now)
+
+			// Every key is active
+			flagged, err := dbClient.ListRecordsOnInactiveKeys(ctx)
+			assert.Nil(err)
+			assert.Empty(flagged)
+
+			// Only the records whose newest version is on the inactive key are flagged
+			assert.Nil(dbClient.MarkEncryptionKeyInactive(ctx, keys[1].ID))
+			flagged, err = dbClient.ListRecordsOnInactiveKeys(ctx)
+			assert.Nil(err)
+			assert.Len(flagged, 2)
+			if len(flagged) == 2 {
+				assert.Equal(records[1].ID, flagged[0].ID)
+				assert.Equal(records[3].ID, flagged[1].ID)
+			}
+
+			// Retired keys are not active either
+			assert.Nil(dbClient.MarkEncryptionKeyRetired(ctx, keys[1].ID))
+			flagged, err = dbClient.ListRecordsOnInactiveKeys(ctx)
+			assert.Nil(err)
+			assert.Len(flagged, 2)
+
+			// Re-encrypting the newest value under an active key clears the flag
+			defineVersion(records[1], keys[0], now.Add(2*time.Second))
+			flagged, err = dbClient.ListRecordsOnInactiveKeys(ctx)
+			assert.Nil(err)
+			assert.Len(flagged, 1)
+			if len(flagged) == 1 {
+				assert.Equal(records[3].ID, flagged[0].ID)
+			}
+			return nil
+		},
+	))
+}
+
+func TestDBRecordVersionBase64StorageEncoding(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// Create a unique temporary DB file for this test
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnectionWithConfig(db.ConnectionConfig{
+		Dialector:       db.GetSqliteDialector(testDB),
+		LogLevel:        logger.Error,
+		StorageEncoding: db.StorageEncodingBase64,
+	})
+	assert.Nil(err)
+
+	// Create database tables
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	// Values holding every byte value
+	value := make([]byte, 256)
+	for idx := range value {
+		value[idx] = byte(idx)
+	}
+	nonce := []byte{0x00, 0xff, 0x10, 0x80, 0x7f}
+
+	var version models.RecordVersion
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			key, err := dbClient.RecordEncryptionKey(
+				ctx, []byte(uuid.NewString()), "", "", models.AEADTypeXChaCha20Poly1305,
+			)
+			assert.Nil(err)
+			record, err := dbClient.DefineNewRecord(ctx, "", uuid.NewString())
+			assert.Nil(err)
+			version, err = dbClient.DefineNewVersionForRecord(
+				ctx, record, key, value, nonce, time.Now().UTC(),
+				db.NewVersionOptions{},
+			)
+			assert.Nil(err)
+			assert.Equal(value, version.EncValue)
+			assert.Equal(nonce, version.EncNonce)
+			return nil
+		},
+	))
+
+	// The columns hold base64 text
+	assert.Nil(uut.RunSQLInTransaction(utCtx, func(ctx context.Context, tx *gorm.DB) error {
+		var stored struct {
+			EncValue []byte
+			EncNonce []byte
+		}
+		assert.Nil(tx.Table("record_versions").
+			Select("enc_value, enc_nonce").
+			Where("id = ?", version.ID).
+			Scan(&stored).Error)
+		assert.Equal(base64.RawStdEncoding.EncodeToString(value), string(stored.EncValue))
+		assert.Equal(base64.RawStdEncoding.EncodeToString(nonce), string(stored.EncNonce))
+		return nil
+	}))
+
+	// Reading back recovers the exact bytes
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		readBack, err := dbClient.GetRecordVersion(ctx, version.ID)
+		assert.Nil(err)
+		assert.Equal(value, readBack.EncValue)
+		assert.Equal(nonce, readBack.EncNonce)
+
+		listed, err := dbClient.ListAllRecordVersions(ctx, db.RecordVersionQueryFilter{})
+		assert.Nil(err)
+		assert.Len(listed, 1)
+		assert.Equal(value, listed[0].EncValue)
+		assert.Equal(nonce, listed[0].EncNonce)
+
+		size, err := dbClient.SumRecordVersionSizes(ctx, version.RecordID)
+		assert.Nil(err)
+		assert.Equal(int64(len(value)), size)
+		return nil
+	}))
+
+	// Unknown storage encodings are rejected
+	_, err = db.NewConnectionWithConfig(db.ConnectionConfig{
+		Dialector:       db.GetSqliteDialector(testDB),
+		LogLevel:        logger.Error,
+		StorageEncoding: "hex",
+	})
+	assert.Error(err)
+}
+
+func TestDBGetNthVersionOfRecord(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// Create a unique temporary DB file for this test
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	// Create database tables
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	// Versions created one minute apart, along with the versions of another record
+	baseTime := time.Now().UTC().Add(-time.Hour)
+	var record models.Record
+	versions := []models.RecordVersion{}
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			key, err := dbClient.RecordEncryptionKey(
+				ctx, []byte(uuid.NewString()), "", "", models.AEADTypeXChaCha20Poly1305,
+			)
+			assert.Nil(err)
+			other, err := dbClient.DefineNewRecord(ctx, "", uuid.NewString())
+			assert.Nil(err)
+			record, err = dbClient.DefineNewRecord(ctx, "", uuid.NewString())
+			assert.Nil(err)
+			for idx := 0; idx < 5; idx++ {
+				timestamp := baseTime.Add(time.Duration(idx) * time.Minute)
+				version, err := dbClient.DefineNewVersionForRecord(
+					ctx,
+					record,
+					key,
+					[]byte(uuid.NewString()),
+					[]byte(uuid.NewString()),
+					timestamp,
+					db.NewVersionOptions{},
+				)
+				assert.Nil(err)
+				versions = append(versions, version)
+				_, err = dbClient.DefineNewVersionForRecord(
+					ctx,
+					other,
+					key,
+					[]byte(uuid.NewString()),
+					[]byte(uuid.NewString()),
+					timestamp,
+					db.NewVersionOptions{},
+				)
+				assert.Nil(err)
+			}
+			return nil
+		},
+	))
+
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		for idx, expected := range versions {
+			version, err := dbClient.GetNthVersionOfRecord(
+				ctx, record, idx, db.RecordVersionQueryFilter{},
+			)
+			assert.Nil(err)
+			assert.Equal(expected.ID, version.ID)
+
+			version, err = dbClient.GetNthVersionOfRecord(
+				ctx, record, idx-len(versions), db.RecordVersionQueryFilter{},
+			)
+			assert.Nil(err)
+			assert.Equal(expected.ID, version.ID)
+		}
+
+		// Out of range
+		for _, index := range []int{5, -6} {
+			_, err := dbClient.GetNthVersionOfRecord(ctx, record, index, db.RecordVersionQueryFilter{})
+			assert.ErrorIs(err, db.ErrVersionNotFound)
+		}
+
+		// The index applies to the versions matching the filter
+		after := baseTime.Add(2 * time.Minute)
+		version, err := dbClient.GetNthVersionOfRecord(
+			ctx, record, 0, db.RecordVersionQueryFilter{CreatedAfter: &after},
+		)
+		assert.Nil(err)
+		assert.Equal(versions[2].ID, version.ID)
+		return nil
+	}))
+}
+
+func TestDBContextCancellation(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	uut, err := db.NewConnection(db.GetInMemorySqliteDialector(), logger.Error)
+	assert.Nil(err)
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			record, err := dbClient.DefineNewRecord(ctx, "", uuid.NewString())
+			assert.Nil(err)
+			key, err := dbClient.RecordEncryptionKey(
+				ctx, []byte(uuid.NewString()), "", "", models.AEADTypeXChaCha20Poly1305,
+			)
+			assert.Nil(err)
+			for itr := 0; itr < 500; itr++ {
+				_, err := dbClient.DefineNewVersionForRecord(
+					ctx,
+					record,
+					key,
+					[]byte(uuid.NewString()),
+					[]byte(uuid.NewString()),
+					time.Now().UTC(),
+					db.NewVersionOptions{},
+				)
+				assert.Nil(err)
+			}
+			return nil
+		},
+	))
+
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		cancelCtx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		start := time.Now()
+		_, err := dbClient.ListAllRecordVersions(cancelCtx, db.RecordVersionQueryFilter{})
+		assert.ErrorIs(err, context.Canceled)
+		assert.Less(time.Since(start), time.Second)
+
+		_, err = dbClient.CountRecords(cancelCtx, db.RecordQueryFilter{})
+		assert.ErrorIs(err, context.Canceled)
+
+		// The handle remains usable with a live context
+		versions, err := dbClient.ListAllRecordVersions(ctx, db.RecordVersionQueryFilter{})
+		assert.Nil(err)
+		assert.Len(versions, 500)
+		return nil
+	}))
+}
+
+// auditFailurePlugin GORM plugin which fails audit event inserts while enabled
+type auditFailurePlugin struct {
+	enabled bool
+}
+
+func (p *auditFailurePlugin) Name() string {
+	return "audit-failure"
+}
+
+func (p *auditFailurePlugin) Initialize(gormDB *gorm.DB) error {
+	return gormDB.Callback().Create().Before("gorm:create").Register(
+		"audit-failure:before_create", func(tx *gorm.DB) {
+			if p.enabled && tx.Statement.Table == (db.SystemEventAuditDBEntry{}).TableName() {
+				_ = tx.AddError(fmt.Errorf("forced audit event failure"))
+			}
+		},
+	)
+}
+
+func TestDBVersionCreationEventConsistency(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	plugin := &auditFailurePlugin{}
+	uut, err := db.NewConnectionWithConfig(db.ConnectionConfig{
+		Dialector:   db.GetSqliteDialector(testDB),
+		LogLevel:    logger.Error,
+		GORMPlugins: []gorm.Plugin{plugin},
+	})
+	assert.Nil(err)
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	var record models.Record
+	var key models.EncryptionKey
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			record, err = dbClient.DefineNewRecord(ctx, "", uuid.NewString())
+			assert.Nil(err)
+			key, err = dbClient.RecordEncryptionKey(
+				ctx, []byte(uuid.NewString()), "", "", models.AEADTypeXChaCha20Poly1305,
+			)
+			assert.Nil(err)
+			return nil
+		},
+	))
+
+	defineVersion := func(ctx context.Context, dbClient db.Database) (models.RecordVersion, error) {
+		return dbClient.DefineNewVersionForRecord(
+			ctx,
+			record,
+			key,
+			[]byte(uuid.NewString()),
+			[]byte(uuid.NewString()),
+			time.Now().UTC(),
+			db.NewVersionOptions{},
+		)
+	}
+
+	// A failed audit event rolls back the version, outside of a transaction
+	plugin.enabled = true
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		_, err := defineVersion(ctx, dbClient)
+		assert.Error(err)
+		return nil
+	}))
+
+	// Within a transaction, only the version is rolled back
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			_, err := defineVersion(ctx, dbClient)
+			assert.Error(err)
+			return nil
+		},
+	))
+	plugin.enabled = false
+
+	var created models.RecordVersion
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		versions, err := dbClient.ListVersionsOfOneRecord(ctx, record, db.RecordVersionQueryFilter{})
+		assert.Nil(err)
+		assert.Empty(versions)
+
+		created, err = defineVersion(ctx, dbClient)
+		assert.Nil(err)
+
+		missing, err := dbClient.FindVersionsWithoutCreationEvent(ctx)
+		assert.Nil(err)
+		assert.Empty(missing)
+		return nil
+	}))
+
+	// A restored version has no creation event
+	restored := created
+	restored.ID = ulid.Make().String()
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		assert.Nil(dbClient.RestoreRecordVersion(ctx, restored))
+
+		missing, err := dbClient.FindVersionsWithoutCreationEvent(ctx)
+		assert.Nil(err)
+		assert.Equal([]string{restored.ID}, missing)
+		return nil
+	}))
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/alwitt/haven/models"
+	"gorm.io/gorm/clause"
 )
 
 // GlobalSystemParamEntryID ID of the singleton system parameter entry
@@ -13,9 +14,9 @@ const GlobalSystemParamEntryID = "system-parameters"
 // getSystemParamEntry fetch the system param entry
 //
 // If the entry does not exist, initialize a new one.
-func (d *databaseImpl) getSystemParamEntry() (SystemParamsDBEntry, error) {
+func (d *databaseImpl) getSystemParamEntry(ctx context.Context) (SystemParamsDBEntry, error) {
 	var entries []SystemParamsDBEntry
-	dbErr := d.db.Where("id = ?", GlobalSystemParamEntryID).Find(&entries).Error
+	dbErr := d.session(ctx).Where("id = ?", GlobalSystemParamEntryID).Find(&entries).Error
 	if dbErr != nil {
 		return SystemParamsDBEntry{}, fmt.Errorf("failed to read system params table [%w]", dbErr)
 	}
@@ -27,7 +28,7 @@ func (d *databaseImpl) getSystemParamEntry() (SystemParamsDBEntry, error) {
 				State: models.SystemStatePreInit,
 			},
 		}
-		if dbErr = d.db.Create(&newEntry).Error; dbErr != nil {
+		if dbErr = d.session(ctx).Create(&newEntry).Error; dbErr != nil {
 			return SystemParamsDBEntry{}, fmt.Errorf(
 				"failed to setup singleton system params table [%w]", dbErr,
 			)
@@ -43,8 +44,8 @@ GetSystemParamEntry fetch the global singleton system parameter entry
 	@param ctx context.Context - execution context
 	@returns the entry
 */
-func (d *databaseImpl) GetSystemParamEntry(_ context.Context) (models.SystemParams, error) {
-	entry, err := d.getSystemParamEntry()
+func (d *databaseImpl) GetSystemParamEntry(ctx context.Context) (models.SystemParams, error) {
+	entry, err := d.getSystemParamEntry(ctx)
 	if err != nil {
 		return entry.SystemParams, fmt.Errorf("unable to fetch system parameter entry [%w]", err)
 	}
@@ -52,8 +53,10 @@ func (d *databaseImpl) GetSystemParamEntry(_ context.Context) (models.SystemPara
 }
 
 // updateSystemParamState update the system parameter entry with new state
-func (d *databaseImpl) updateSystemParamState(newState models.SystemStateENUMType) error {
-	entry, err := d.getSystemParamEntry()
+func (d *databaseImpl) updateSystemParamState(
+	ctx context.Context, newState models.SystemStateENUMType,
+) error {
+	entry, err := d.getSystemParamEntry(ctx)
 	if err != nil {
 		return fmt.Errorf("unable to fetch system parameter entry [%w]", err)
 	}
@@ -69,21 +72,21 @@ func (d *databaseImpl) updateSystemParamState(newState models.SystemStateENUMTyp
 
 	oldState := entry.State
 	entry.State = newState
-	if tmp := d.db.Updates(&entry); tmp.Error != nil {
+	if tmp := d.session(ctx).Updates(&entry); tmp.Error != nil {
 		return fmt.Errorf("system state change update failed [%w]", err)
 	}
 
 	// record this event
 	switch newState {
 	case models.SystemStateInit:
-		_, err = d.defineNewSystemEvent(models.SystemEventTypeInitializing, nil)
+		_, err = d.defineNewSystemEvent(ctx, models.SystemEventTypeInitializing, nil)
 		if err != nil {
 			return fmt.Errorf("failed to log system state change audit event [%w]", err)
 		}
 
 	case models.SystemStateRunning:
 		if oldState == models.SystemStateInit {
-			_, err = d.defineNewSystemEvent(models.SystemEventTypeInitialized, nil)
+			_, err = d.defineNewSystemEvent(ctx, models.SystemEventTypeInitialized, nil)
 			if err != nil {
 				return fmt.Errorf("failed to log system state change audit event [%w]", err)
 			}
@@ -93,13 +96,47 @@ func (d *databaseImpl) updateSystemParamState(newState models.SystemStateENUMTyp
 	return nil
 }
 
+/*
+LockSystemParams lock the global singleton system parameter entry until the transaction
+ends, defining the entry if it does not exist. Transactions which lock the entry run one
+at a time.
+
+On Sqlite, which does not lock rows, the whole database is locked for writing instead. To
+hold the lock before any concurrent writer, call this before anything else in the
+transaction.
+
+	@param ctx context.Context - execution context
+*/
+func (d *databaseImpl) LockSystemParams(ctx context.Context) error {
+	newEntry := SystemParamsDBEntry{
+		SystemParams: models.SystemParams{
+			ID:    GlobalSystemParamEntryID,
+			State: models.SystemStatePreInit,
+		},
+	}
+	if tmp := d.session(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&newEntry); tmp.Error != nil {
+		return fmt.Errorf("failed to setup singleton system params table [%w]", tmp.Error)
+	}
+
+	var entry SystemParamsDBEntry
+	if tmp := d.session(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", GlobalSystemParamEntryID).
+		First(&entry); tmp.Error != nil {
+		return fmt.Errorf("failed to lock system parameter entry [%w]", tmp.Error)
+	}
+	return nil
+}
+
 /*
 MarkSystemInitializing mark system is initializing
 
 	@param ctx context.Context - execution context
 */
-func (d *databaseImpl) MarkSystemInitializing(_ context.Context) error {
-	return d.updateSystemParamState(models.SystemStateInit)
+func (d *databaseImpl) MarkSystemInitializing(ctx context.Context) error {
+	return d.updateSystemParamState(ctx, models.SystemStateInit)
 }
 
 /*
@@ -107,6 +144,61 @@ MarkSystemInitializing mark system fully initialized
 
 	@param ctx context.Context - execution context
 */
-func (d *databaseImpl) MarkSystemInitialized(_ context.Context) error {
-	return d.updateSystemParamState(models.SystemStateRunning)
+func (d *databaseImpl) MarkSystemInitialized(ctx context.Context) error {
+	return d.updateSystemParamState(ctx, models.SystemStateRunning)
+}
+
+/*
+RestoreSystemParams replace the system parameter entry with one from a backup
+
+	@param ctx context.Context - execution context
+	@param entry models.SystemParams - the backed up entry
+*/
+func (d *databaseImpl) RestoreSystemParams(ctx context.Context, entry models.SystemParams) error {
+	newEntry := SystemParamsDBEntry{SystemParams: entry}
+	if err := d.validator.Struct(&newEntry); err != nil {
+		return fmt.Errorf("backed up system parameter entry is invalid [%w]", err)
+	}
+
+	if tmp := d.session(ctx).Save(&newEntry); tmp.Error != nil {
+		return fmt.Errorf("system parameter entry restore failed [%w]", tmp.Error)
+	}
+	return nil
+}
+
+// systemStateByEventType the state the system enters with each state transition event
+var systemStateByEventType = map[models.SystemEventTypeENUMType]models.SystemStateENUMType{
+	models.SystemEventTypeInitializing: models.SystemStateInit,
+	models.SystemEventTypeInitialized:  models.SystemStateRunning,
+}
+
+/*
+GetSystemStateHistory fetch the system state transitions, oldest first
+
+	@param ctx context.Context - execution context
+	@returns the state transitions
+*/
+func (d *databaseImpl) GetSystemStateHistory(
+	ctx context.Context,
+) ([]models.SystemStateEvent, error) {
+	eventTypes := []models.SystemEventTypeENUMType{}
+	for eventType := range systemStateByEventType {
+		eventTypes = append(eventTypes, eventType)
+	}
+
+	events, err := d.ListSystemEvents(ctx, SystemEventQueryFilter{EventTypes: eventTypes})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list system state events [%w]", err)
+	}
+
+	result := []models.SystemStateEvent{}
+	for _, event := range events {
+		result = append(result, models.SystemStateEvent{
+			EventID:   event.ID,
+			State:     systemStateByEventType[event.EventType],
+			Actor:     event.Actor,
+			Timestamp: event.CreatedAt,
+		})
+	}
+	return result, nil
 }
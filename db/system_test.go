@@ -8,6 +8,7 @@ import (
 	"github.com/alwitt/haven/db"
 	"github.com/alwitt/haven/models"
 	"github.com/apex/log"
+	"github.com/google/uuid"
 	"github.com/oklog/ulid/v2"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/gorm/logger"
@@ -54,6 +55,45 @@ func TestDBSystemParameterInit(t *testing.T) {
 	)
 }
 
+// TestDBLockSystemParams verifies `Database.LockSystemParams` defines the system
+// parameter entry when missing, and leaves an existing entry unchanged.
+func TestDBLockSystemParams(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	// Lock the missing entry
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			assert.Nil(dbClient.LockSystemParams(ctx))
+			params, err := dbClient.GetSystemParamEntry(ctx)
+			assert.Nil(err)
+			assert.Equal(models.SystemStatePreInit, params.State)
+			return dbClient.MarkSystemInitializing(ctx)
+		},
+	))
+
+	// Lock the existing entry
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			assert.Nil(dbClient.LockSystemParams(ctx))
+			params, err := dbClient.GetSystemParamEntry(ctx)
+			assert.Nil(err)
+			assert.Equal(models.SystemStateInit, params.State)
+			return err
+		},
+	))
+}
+
 // TestDBSystemParameterTestStateChange verifies the state transition behaviour
 // of the system parameters (pre‑init → initializing → running) and the
 // corresponding audit events.
@@ -172,3 +212,140 @@ func TestDBSystemParameterTestStateChange(t *testing.T) {
 	assert.True(hasInitializing, "expected initializing event")
 	assert.True(hasInitialized, "expected initialized event")
 }
+
+// TestDBListSystemEventsAfterID verifies paging through audit events with `AfterID`.
+func TestDBListSystemEventsAfterID(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	// Generate audit events
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			assert.Nil(dbClient.MarkSystemInitializing(ctx))
+			assert.Nil(dbClient.MarkSystemInitialized(ctx))
+			return nil
+		},
+	))
+
+	var events []models.SystemEventAudit
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		events, err = dbClient.ListSystemEvents(ctx, db.SystemEventQueryFilter{})
+		return err
+	}))
+	assert.Len(events, 2)
+
+	// Events are listed oldest first, so the page after the first event is the second event
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		page, err := dbClient.ListSystemEvents(ctx, db.SystemEventQueryFilter{
+			CommonListEntryQueryFilter: db.CommonListEntryQueryFilter{AfterID: &events[0].ID},
+		})
+		assert.Nil(err)
+		assert.Len(page, 1)
+		assert.Equal(events[1].ID, page[0].ID)
+		return err
+	}))
+}
+
+func TestDBSystemEventActor(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	// One event without an actor, and one with
+	testActor := uuid.NewString()
+	assert.Nil(uut.UseDatabaseInTransaction(
+		utCtx, func(ctx context.Context, dbClient db.Database) error {
+			assert.Nil(dbClient.MarkSystemInitializing(ctx))
+			assert.Nil(dbClient.MarkSystemInitialized(db.WithActor(ctx, testActor)))
+			return nil
+		},
+	))
+
+	assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		events, err := dbClient.ListSystemEvents(ctx, db.SystemEventQueryFilter{})
+		assert.Nil(err)
+		assert.Len(events, 2)
+		assert.Equal(db.DefaultActor, events[0].Actor)
+		assert.Equal(models.SystemEventTypeInitializing, events[0].EventType)
+		assert.Equal(testActor, events[1].Actor)
+		assert.Equal(models.SystemEventTypeInitialized, events[1].EventType)
+
+		// Filter by actor
+		events, err = dbClient.ListSystemEvents(ctx, db.SystemEventQueryFilter{
+			Actors: []string{testActor},
+		})
+		assert.Nil(err)
+		assert.Len(events, 1)
+		assert.Equal(testActor, events[0].Actor)
+		return err
+	}))
+}
+
+// TestDBSystemStateHistory verifies the system state transitions are reported as a
+// timeline, oldest first.
+func TestDBSystemStateHistory(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	uut, err := db.NewConnection(db.GetInMemorySqliteDialector(), logger.Error)
+	assert.Nil(err)
+
+	// Create tables
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+
+	history := func() []models.SystemStateEvent {
+		var result []models.SystemStateEvent
+		assert.Nil(uut.UseDatabase(utCtx, func(ctx context.Context, dbClient db.Database) error {
+			result, err = dbClient.GetSystemStateHistory(ctx)
+			return err
+		}))
+		return result
+	}
+
+	// No transitions before initialization
+	assert.Empty(history())
+
+	// Drive the system to RUNNING, with unrelated events in between
+	assert.Nil(uut.UseDatabaseInTransaction(
+		db.WithActor(utCtx, "operator"), func(ctx context.Context, dbClient db.Database) error {
+			return dbClient.MarkSystemInitializing(ctx)
+		},
+	))
+	assert.Nil(uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		_, err := dbClient.DefineNewRecord(ctx, "", uuid.NewString())
+		return err
+	}))
+	assert.Nil(uut.UseDatabaseInTransaction(utCtx, func(ctx context.Context, dbClient db.Database) error {
+		return dbClient.MarkSystemInitialized(ctx)
+	}))
+
+	timeline := history()
+	assert.Len(timeline, 2)
+	assert.Equal(models.SystemStateInit, timeline[0].State)
+	assert.Equal("operator", timeline[0].Actor)
+	assert.NotEmpty(timeline[0].EventID)
+	assert.Equal(models.SystemStateRunning, timeline[1].State)
+	assert.Equal(db.DefaultActor, timeline[1].Actor)
+	assert.False(timeline[1].Timestamp.Before(timeline[0].Timestamp))
+}
@@ -2,6 +2,8 @@ package db
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"github.com/alwitt/haven/models"
 	"gorm.io/gorm"
@@ -33,6 +35,23 @@ func (SystemParamsDBEntry) TableName() string {
 	return "system_params"
 }
 
+// --------------------------------------------------------------------------------------
+// Sequences
+
+// SequenceDBEntry counter handing out increasing values in the order the transactions
+// taking them commit
+type SequenceDBEntry struct {
+	// Name sequence name
+	Name string `gorm:"column:name;primaryKey"`
+	// Value the last value handed out
+	Value int64 `gorm:"column:value;not null;default:0"`
+}
+
+// TableName hard code table name
+func (SequenceDBEntry) TableName() string {
+	return "sequences"
+}
+
 // --------------------------------------------------------------------------------------
 // Encryption keys
 
@@ -74,14 +93,158 @@ func (RecordVersionDBEntry) TableName() string {
 // --------------------------------------------------------------------------------------
 // Utility
 
-// DefineTables helper function meant to be used for unit-testing to prepare a
-// database with tables
-func DefineTables(_ context.Context, db *gorm.DB) error {
-	return db.AutoMigrate(
+// schemaModels the DB entries making up the schema, in the order their tables are created
+func schemaModels() []interface{} {
+	return []interface{}{
 		SystemEventAuditDBEntry{},
 		SystemParamsDBEntry{},
+		SequenceDBEntry{},
 		EncryptionKeyDBEntry{},
 		RecordDBEntry{},
 		RecordVersionDBEntry{},
-	)
+	}
+}
+
+// DefineTables helper function meant to be used for unit-testing to prepare a
+// database with tables
+func DefineTables(ctx context.Context, db *gorm.DB) error {
+	if err := db.AutoMigrate(schemaModels()...); err != nil {
+		return err
+	}
+	return VerifyForeignKeys(ctx, db)
+}
+
+// SchemaDiffENUMType kind of difference between the live schema and the expected one
+type SchemaDiffENUMType string
+
+const (
+	// SchemaDiffMissingTable the table does not exist
+	SchemaDiffMissingTable SchemaDiffENUMType = "MISSING_TABLE"
+	// SchemaDiffMissingColumn the column does not exist
+	SchemaDiffMissingColumn SchemaDiffENUMType = "MISSING_COLUMN"
+	// SchemaDiffTypeMismatch the column exists with a different type
+	SchemaDiffTypeMismatch SchemaDiffENUMType = "TYPE_MISMATCH"
+)
+
+// SchemaDiff one difference between the live schema and the expected one
+type SchemaDiff struct {
+	// Kind the kind of difference
+	Kind SchemaDiffENUMType `json:"kind"`
+	// Table the table
+	Table string `json:"table"`
+	// Column the column. Empty for table level differences.
+	Column string `json:"column,omitempty"`
+	// Expected the expected column type. Only set for type mismatches.
+	Expected string `json:"expected,omitempty"`
+	// Actual the live column type. Only set for type mismatches.
+	Actual string `json:"actual,omitempty"`
+}
+
+/*
+ValidateSchema compare the live schema against the one DefineTables would define,
+without changing the database. Operators can review the returned differences before
+migrating.
+
+	@param ctx context.Context - execution context
+	@param db *gorm.DB - the database connection
+	@return the pending changes. Empty if the schema is up to date.
+*/
+func ValidateSchema(ctx context.Context, db *gorm.DB) ([]SchemaDiff, error) {
+	db = db.WithContext(ctx)
+	migrator := db.Migrator()
+
+	diffs := []SchemaDiff{}
+	for _, model := range schemaModels() {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, fmt.Errorf("failed to parse model %T [%w]", model, err)
+		}
+		table := stmt.Schema.Table
+
+		if !migrator.HasTable(model) {
+			diffs = append(diffs, SchemaDiff{Kind: SchemaDiffMissingTable, Table: table})
+			continue
+		}
+
+		columnTypes, err := migrator.ColumnTypes(model)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read table '%s' columns [%w]", table, err)
+		}
+		liveColumns := map[string]gorm.ColumnType{}
+		for _, columnType := range columnTypes {
+			liveColumns[columnType.Name()] = columnType
+		}
+
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" || field.IgnoreMigration {
+				continue
+			}
+			columnType, ok := liveColumns[field.DBName]
+			if !ok {
+				diffs = append(diffs, SchemaDiff{
+					Kind: SchemaDiffMissingColumn, Table: table, Column: field.DBName,
+				})
+				continue
+			}
+
+			expected := db.Dialector.DataTypeOf(field)
+			actual := columnType.DatabaseTypeName()
+			if !columnTypeMatches(migrator, expected, actual) {
+				diffs = append(diffs, SchemaDiff{
+					Kind:     SchemaDiffTypeMismatch,
+					Table:    table,
+					Column:   field.DBName,
+					Expected: expected,
+					Actual:   actual,
+				})
+			}
+		}
+	}
+
+	return diffs, nil
+}
+
+// columnTypeMatches whether a live column type satisfies the expected type. The live type
+// omits the size of sized types, and may be reported under an alias of the expected type.
+func columnTypeMatches(migrator gorm.Migrator, expected string, actual string) bool {
+	expected = strings.ToLower(expected)
+	actual = strings.ToLower(actual)
+	if expected == actual || strings.HasPrefix(expected, actual+"(") {
+		return true
+	}
+	for _, alias := range migrator.GetTypeAliases(actual) {
+		if strings.EqualFold(alias, expected) {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyForeignKeys verify the foreign keys which cascade the deletion of data records and
+// encryption keys to their data record versions are defined and enforced. For Sqlite, this
+// requires the connection to enable foreign key enforcement.
+func VerifyForeignKeys(ctx context.Context, db *gorm.DB) error {
+	db = db.WithContext(ctx)
+	migrator := db.Migrator()
+	for _, constraint := range []string{"Record", "EncKey"} {
+		if !migrator.HasConstraint(&RecordVersionDBEntry{}, constraint) {
+			return fmt.Errorf(
+				"table '%s' is missing the '%s' foreign key",
+				RecordVersionDBEntry{}.TableName(),
+				constraint,
+			)
+		}
+	}
+
+	if db.Dialector.Name() == "sqlite" {
+		var enabled int
+		if err := db.Raw("PRAGMA foreign_keys").Scan(&enabled).Error; err != nil {
+			return fmt.Errorf("failed to read Sqlite foreign key enforcement setting [%w]", err)
+		}
+		if enabled != 1 {
+			return fmt.Errorf("sqlite foreign key enforcement is disabled")
+		}
+	}
+
+	return nil
 }
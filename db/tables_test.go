@@ -0,0 +1,121 @@
+package db_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/alwitt/haven/db"
+	"github.com/apex/log"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestDBVerifyForeignKeys(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// Foreign keys enabled
+	{
+		testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+		uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+		assert.Nil(err)
+		assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+		assert.Nil(uut.RunSQLInTransaction(utCtx, db.VerifyForeignKeys))
+
+		// The checks run within the context
+		cancelledCtx, cancel := context.WithCancel(utCtx)
+		cancel()
+		assert.Error(db.VerifyForeignKeys(cancelledCtx, uut.RawDB()))
+		assert.Nil(db.VerifyForeignKeys(utCtx, uut.RawDB()))
+	}
+
+	// Foreign key enforcement disabled
+	{
+		testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+		uut, err := db.NewConnection(sqlite.Open(testDB), logger.Error)
+		assert.Nil(err)
+		assert.Error(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+	}
+
+	// Schema without foreign keys
+	{
+		testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+		uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+		assert.Nil(err)
+		assert.Nil(uut.RunSQLInTransaction(utCtx, func(_ context.Context, tx *gorm.DB) error {
+			return tx.Exec(
+				"CREATE TABLE record_versions (id text PRIMARY KEY, record_id text, enc_key_id text)",
+			).Error
+		}))
+		assert.Error(uut.RunSQLInTransaction(utCtx, db.VerifyForeignKeys))
+	}
+}
+
+func TestDBValidateSchema(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	uut, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(err)
+
+	validate := func() []db.SchemaDiff {
+		var diffs []db.SchemaDiff
+		assert.Nil(uut.RunSQLInTransaction(utCtx, func(ctx context.Context, tx *gorm.DB) error {
+			var err error
+			diffs, err = db.ValidateSchema(ctx, tx)
+			return err
+		}))
+		return diffs
+	}
+
+	// Fresh database is missing every table
+	diffs := validate()
+	assert.Len(diffs, 6)
+	missingTables := []string{}
+	for _, diff := range diffs {
+		assert.Equal(db.SchemaDiffMissingTable, diff.Kind)
+		missingTables = append(missingTables, diff.Table)
+	}
+	assert.ElementsMatch(
+		[]string{
+			db.SystemEventAuditDBEntry{}.TableName(),
+			db.SystemParamsDBEntry{}.TableName(),
+			db.SequenceDBEntry{}.TableName(),
+			db.EncryptionKeyDBEntry{}.TableName(),
+			db.RecordDBEntry{}.TableName(),
+			db.RecordVersionDBEntry{}.TableName(),
+		},
+		missingTables,
+	)
+
+	// Validation does not change the database
+	assert.Len(validate(), 6)
+
+	// Fully migrated database has no differences
+	assert.Nil(uut.RunSQLInTransaction(utCtx, db.DefineTables))
+	assert.Empty(validate())
+
+	// Dropped column is reported
+	assert.Nil(uut.RunSQLInTransaction(utCtx, func(_ context.Context, tx *gorm.DB) error {
+		return tx.Migrator().DropColumn(&db.RecordDBEntry{}, "last_read_at")
+	}))
+	assert.Equal(
+		[]db.SchemaDiff{
+			{
+				Kind:   db.SchemaDiffMissingColumn,
+				Table:  db.RecordDBEntry{}.TableName(),
+				Column: "last_read_at",
+			},
+		},
+		validate(),
+	)
+}
@@ -0,0 +1,114 @@
+package encryption
+
+import (
+	"container/list"
+	"time"
+)
+
+// keyCacheSlot one entry of the key cache
+type keyCacheSlot struct {
+	entry encKeyCacheEntry
+	// cachedAt when the entry was cached
+	cachedAt time.Time
+}
+
+/*
+keyCache the decrypted symmetric key cache
+
+The cache is a LRU. Once it holds maxKeys entries, caching another key evicts the least
+recently used entry. Entries older than ttl are evicted when next read. Evicted entries have
+their key material zeroed.
+
+The cache is not thread safe; the engine guards it with keyCacheLock.
+*/
+type keyCache struct {
+	// maxKeys max number of cached keys. Zero for no limit.
+	maxKeys int
+	// ttl how long a key stays cached. Zero for no limit.
+	ttl time.Duration
+
+	entries map[string]*list.Element
+	// recency the cache slots, from most to least recently used
+	recency *list.List
+}
+
+// newKeyCache define a new key cache
+func newKeyCache(maxKeys int, ttl time.Duration) *keyCache {
+	return &keyCache{
+		maxKeys: maxKeys, ttl: ttl, entries: map[string]*list.Element{}, recency: list.New(),
+	}
+}
+
+// len number of cached keys
+func (c *keyCache) len() int {
+	return len(c.entries)
+}
+
+// states list the state of the cached keys, from most to least recently used
+func (c *keyCache) states() []CachedKeyState {
+	states := make([]CachedKeyState, 0, len(c.entries))
+	for elem := c.recency.Front(); elem != nil; elem = elem.Next() {
+		slot := elem.Value.(*keyCacheSlot)
+		states = append(states, CachedKeyState{
+			KeyID: slot.entry.ID, HasKeyMaterial: len(slot.entry.plainTextKey) > 0,
+		})
+	}
+	return states
+}
+
+// get read a key, marking it as most recently used. Returns whether the key was cached,
+// and whether it was evicted for exceeding its TTL.
+func (c *keyCache) get(keyID string) (encKeyCacheEntry, bool, bool) {
+	elem, ok := c.entries[keyID]
+	if !ok {
+		return encKeyCacheEntry{}, false, false
+	}
+	slot := elem.Value.(*keyCacheSlot)
+	if c.ttl > 0 && time.Since(slot.cachedAt) > c.ttl {
+		c.removeElement(elem)
+		return encKeyCacheEntry{}, false, true
+	}
+	c.recency.MoveToFront(elem)
+	return slot.entry, true, false
+}
+
+// put cache a key as the most recently used. If the key is already cached, the existing
+// entry is kept. Returns the cached entry, and number of entries evicted to make room.
+func (c *keyCache) put(entry encKeyCacheEntry) (encKeyCacheEntry, int) {
+	if elem, ok := c.entries[entry.ID]; ok {
+		c.recency.MoveToFront(elem)
+		return elem.Value.(*keyCacheSlot).entry, 0
+	}
+
+	evicted := 0
+	for c.maxKeys > 0 && len(c.entries) >= c.maxKeys {
+		c.removeElement(c.recency.Back())
+		evicted++
+	}
+	c.entries[entry.ID] = c.recency.PushFront(&keyCacheSlot{entry: entry, cachedAt: time.Now()})
+	return entry, evicted
+}
+
+// remove drop a key from cache, zeroing its key material. Returns whether the key was
+// cached.
+func (c *keyCache) remove(keyID string) bool {
+	elem, ok := c.entries[keyID]
+	if ok {
+		c.removeElement(elem)
+	}
+	return ok
+}
+
+// clear drop every key from cache, zeroing their key material
+func (c *keyCache) clear() {
+	for _, elem := range c.entries {
+		c.removeElement(elem)
+	}
+}
+
+// removeElement drop a cache slot, zeroing its key material
+func (c *keyCache) removeElement(elem *list.Element) {
+	slot := c.recency.Remove(elem).(*keyCacheSlot)
+	zeroKeyMaterial(slot.entry.plainTextKey)
+	delete(c.entries, slot.entry.ID)
+}
@@ -3,9 +3,12 @@ package encryption
 
 import (
 	"context"
-	"crypto/rsa"
+	"errors"
 	"fmt"
+	"runtime"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	cgoCrypto "github.com/alwitt/cgoutils/crypto"
 	"github.com/alwitt/goutils"
@@ -13,14 +16,76 @@ import (
 	"github.com/alwitt/haven/models"
 	"github.com/apex/log"
 	"github.com/go-playground/validator/v10"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+// ErrRSAKeyFingerprintMismatch the symmetric key was encrypted under a RSA key which is
+// neither the primary nor a secondary RSA key of the engine
+var ErrRSAKeyFingerprintMismatch = errors.New("symmetric key wrapped under a different RSA key")
+
+// ErrKeyTooLargeToWrap the symmetric key is larger than RSA OAEP can wrap under the primary
+// RSA key. Use a larger RSA key.
+var ErrKeyTooLargeToWrap = errors.New("symmetric key too large to wrap with the RSA key")
+
+// ErrKeyNotActive the encryption key is not active, so it can not encrypt data
+var ErrKeyNotActive = errors.New("encryption key is not active")
+
+// ErrKeyRetired the encryption key is retired, so it can not encrypt new data. The same
+// error as db.ErrEncryptionKeyRetired.
+var ErrKeyRetired = db.ErrEncryptionKeyRetired
+
+// ErrKeyRevoked the encryption key was cached, but has since been deleted from the database
+var ErrKeyRevoked = errors.New("encryption key revoked")
+
+// ErrAuthenticationFailed the cipher text, or its associated data, failed AEAD
+// authentication, so it was altered or is decrypted with the wrong key
+var ErrAuthenticationFailed = errors.New("cipher text failed authentication")
+
 // EncryptedData helper function to group encryption data together
 type EncryptedData struct {
-	// CipherText the cipher text
+	// CipherText the cipher text, prefixed with the envelope header
 	CipherText []byte
 	// Nonce the nonce
 	Nonce []byte
+	// AAD the associated additional data the cipher text is bound to. Empty if unbound.
+	AAD []byte
+	// SubkeyInfo the HKDF-SHA256 info the AEAD key was derived from the encryption key with.
+	// Empty if the encryption key was used directly.
+	SubkeyInfo []byte
+}
+
+// KeySummary overview of the encryption keys in the system
+type KeySummary struct {
+	// Counts number of keys in each state
+	Counts map[models.EncryptionKeyStateENUMType]int64
+	// Keys metadata of the newest keys, up to keySummaryMaxKeys entries. The encrypted key
+	// material is omitted.
+	Keys []models.EncryptionKey
+	// Truncated whether Keys does not include every key
+	Truncated bool
+}
+
+// CachedKeyState the state of one key in the decrypted symmetric key cache
+type CachedKeyState struct {
+	// KeyID the encryption key ID
+	KeyID string
+	// HasKeyMaterial whether the decrypted key material is present
+	HasKeyMaterial bool
+}
+
+// KeyCacheStats snapshot of the decrypted symmetric key cache statistics
+type KeyCacheStats struct {
+	// CachedKeyCount number of keys currently cached
+	CachedKeyCount int
+	// Keys the cached keys, from most to least recently used
+	Keys []CachedKeyState
+	// Hits number of key lookups served from cache
+	Hits uint64
+	// Misses number of key lookups which had to decrypt the key material
+	Misses uint64
+	// Evictions number of keys dropped from cache for exceeding the cache size limit, or
+	// the cached key TTL
+	Evictions uint64
 }
 
 /*
@@ -102,6 +167,27 @@ type CryptographyEngine interface {
 	*/
 	DeleteEncryptionKey(ctx context.Context, keyID string, activeDBClient db.Database) error
 
+	/*
+		SummarizeKeys count the encryption keys in each state, and list the metadata of the
+		newest keys. No key material is decrypted.
+
+			@param ctx context.Context - execution context
+			@param activeDBClient Database - existing database transaction
+			@return the key summary
+	*/
+	SummarizeKeys(ctx context.Context, activeDBClient db.Database) (KeySummary, error)
+
+	/*
+		RewrapEncryptionKeys re-encrypt the key material of every active encryption key with
+		the primary RSA public key. Keys already encrypted with the primary RSA key pair
+		are skipped.
+
+			@param ctx context.Context - execution context
+			@param activeDBClient Database - existing database transaction
+			@return number of keys re-encrypted
+	*/
+	RewrapEncryptionKeys(ctx context.Context, activeDBClient db.Database) (int, error)
+
 	// ------------------------------------------------------------------------------------
 	// Data encryption
 
@@ -111,15 +197,27 @@ type CryptographyEngine interface {
 			@param ctx context.Context - execution context
 			@param keyID string - the encryption key ID
 			@param plainText []byte - the plain text to encrypt
+			@param aad []byte - associated additional data to bind the cipher text to. The same
+			    data must be provided to decrypt. Empty to not bind the cipher text.
+			@param subkeyInfo []byte - HKDF-SHA256 info to derive the AEAD key from the encryption
+			    key with. The same info must be provided to decrypt. Empty to use the encryption
+			    key directly.
 			@param activeDBClient Database - existing database transaction
 			@return key entry for the encryption, and the cipher text
 	*/
 	EncryptData(
-		ctx context.Context, keyID string, plainText []byte, activeDBClient db.Database,
+		ctx context.Context,
+		keyID string,
+		plainText []byte,
+		aad []byte,
+		subkeyInfo []byte,
+		activeDBClient db.Database,
 	) (models.EncryptionKey, EncryptedData, error)
 
 	/*
-		DecryptData decrypt cipher text
+		DecryptData decrypt cipher text. Decryption fails if the associated additional data
+		does not match the data used during encryption. Keys which are no longer active can
+		still decrypt, though their material is decrypted on every call instead of cached.
 
 			@param ctx context.Context - execution context
 			@param keyID string - the encryption key ID
@@ -130,6 +228,89 @@ type CryptographyEngine interface {
 	DecryptData(
 		ctx context.Context, keyID string, encrypted EncryptedData, activeDBClient db.Database,
 	) (models.EncryptionKey, []byte, error)
+
+	/*
+		RedistributeVersions re-encrypt the data record versions encrypted with the source
+		encryption key onto the target encryption keys, assigning the versions to the target
+		keys round-robin. Re-encrypted versions no longer reference the source key, so
+		repeating the call only handles the versions which remain.
+
+			@param ctx context.Context - execution context
+			@param sourceKeyID string - the encryption key to move the versions off of
+			@param targetKeyIDs []string - the encryption keys to move the versions onto
+			@param activeDBClient Database - existing database transaction
+			@return number of versions re-encrypted
+	*/
+	RedistributeVersions(
+		ctx context.Context,
+		sourceKeyID string,
+		targetKeyIDs []string,
+		activeDBClient db.Database,
+	) (int, error)
+
+	/*
+		VerifyKeyVersionsDecryptable attempt to decrypt every data record version encrypted
+		with an encryption key, reporting the versions which fail. The decrypted values are
+		discarded.
+
+			@param ctx context.Context - execution context
+			@param keyID string - the encryption key ID
+			@param activeDBClient Database - existing database transaction
+			@return whether every version decrypted, and the IDs of the versions which did not
+	*/
+	VerifyKeyVersionsDecryptable(
+		ctx context.Context, keyID string, activeDBClient db.Database,
+	) (bool, []string, error)
+
+	// ------------------------------------------------------------------------------------
+	// Secret wrapping
+
+	/*
+		WrapSecret encrypt a small secret with the primary RSA public key. The secret can be
+		at most the RSA key size in bytes, less 130 bytes of RSA OAEP padding.
+
+			@param ctx context.Context - execution context
+			@param plaintext []byte - the secret to wrap
+			@return the wrapped secret
+	*/
+	WrapSecret(ctx context.Context, plaintext []byte) ([]byte, error)
+
+	/*
+		UnwrapSecret decrypt a secret wrapped by WrapSecret. The primary RSA key is tried
+		first, followed by each of the secondary RSA keys.
+
+			@param ctx context.Context - execution context
+			@param ciphertext []byte - the wrapped secret
+			@return the secret
+	*/
+	UnwrapSecret(ctx context.Context, ciphertext []byte) ([]byte, error)
+
+	// ------------------------------------------------------------------------------------
+	// Lifecycle
+
+	/*
+		ReloadRSAKeyPair replace the primary RSA key pair with one read from file. The previous
+		primary RSA private key is retained as a secondary RSA key, so symmetric keys it
+		encrypted remain usable until they are re-encrypted with RewrapEncryptionKeys. An
+		encrypted private key is decrypted with the engine's PrimaryRSAKeyPassphrase.
+
+			@param ctx context.Context - execution context
+			@param certFile string - file path to the new primary RSA certificate PEM
+			@param keyFile string - file path to the new primary RSA certificate private key PEM
+	*/
+	ReloadRSAKeyPair(ctx context.Context, certFile string, keyFile string) error
+
+	/*
+		CacheStats snapshot the decrypted symmetric key cache statistics
+
+			@return the cache statistics
+	*/
+	CacheStats() KeyCacheStats
+
+	/*
+		Shutdown zero and drop every decrypted symmetric key held in the key cache
+	*/
+	Shutdown()
 }
 
 // cryptoEngine implements CryptographyEngine
@@ -141,11 +322,42 @@ type cryptoEngine struct {
 
 	crypto cgoCrypto.Engine
 
-	rsaKey    *rsa.PrivateKey
-	rsaPubKey *rsa.PublicKey
+	// aeadType the AEAD algorithm of new encryption keys
+	aeadType models.AEADTypeENUMType
+
+	// strictKeyConsistency whether cached keys missing from the database are revoked
+	strictKeyConsistency bool
+
+	// insecurePlaintextKeys whether symmetric keys are stored unwrapped, without RSA keys
+	insecurePlaintextKeys bool
+
+	// keyWrapper encrypts and decrypts symmetric keys in place of the RSA keys. Nil if the
+	// RSA keys are used.
+	keyWrapper KeyWrapper
+
+	// rsaKeys the RSA keys for encrypting and decrypting symmetric keys. Access through
+	// getRSAKeys. Nil in insecure plaintext keys mode, or with a key wrapper.
+	rsaKeys     *rsaKeySet
+	rsaKeysLock *sync.RWMutex
+
+	// rsaKeyPassphrase passphrase protecting the primary RSA private key. Empty if the key
+	// is not encrypted.
+	rsaKeyPassphrase []byte
 
 	keyCacheLock *sync.RWMutex
-	encKeys      map[string]encKeyCacheEntry
+	encKeys      *keyCache
+
+	// cacheHits, cacheMisses and cacheEvictions the key cache statistics
+	cacheHits      atomic.Uint64
+	cacheMisses    atomic.Uint64
+	cacheEvictions atomic.Uint64
+
+	// decryptSlots bounds the number of concurrent decryptions, as each one holds secure
+	// memory buffers
+	decryptSlots chan struct{}
+
+	// metrics the engine metrics trackers. Nil if metrics are not tracked.
+	metrics *engineMetrics
 }
 
 // encKeyCacheEntry system encryption key cache entry
@@ -155,16 +367,66 @@ type encKeyCacheEntry struct {
 	plainTextKey []byte
 }
 
+// privateCopy copy the entry, including the decrypted key material
+func (c encKeyCacheEntry) privateCopy() encKeyCacheEntry {
+	return encKeyCacheEntry{
+		EncryptionKey: c.EncryptionKey, plainTextKey: append([]byte{}, c.plainTextKey...),
+	}
+}
+
 // CryptographyEngineParams cryptography engine init parameters
 //
-// The primary RSA key pair is used to encrypt and decrypt symmetric encryption keys
+// The primary RSA key pair is used to encrypt and decrypt symmetric encryption keys.
+// The secondary RSA private keys are only used to decrypt symmetric encryption keys
+// which were encrypted under a previous primary RSA key pair.
+//
+// The primary RSA key pair is read either from files, or from PEM held in memory.
 type CryptographyEngineParams struct {
 	// Persistence persistence layer client
 	Persistence db.Client `validate:"-"`
 	// PrimaryRSACertFile file path to the primary RSA certificate PEM
-	PrimaryRSACertFile string `validate:"required,file"`
+	PrimaryRSACertFile string `validate:"required_without_all=InsecurePlaintextKeys KeyWrapper PrimaryRSACertPEM,omitempty,file"`
 	// PrimaryRSAKeyFile file path to the primary RSA certificate private key PEM
-	PrimaryRSAKeyFile string `validate:"required,file"`
+	PrimaryRSAKeyFile string `validate:"required_without_all=InsecurePlaintextKeys KeyWrapper PrimaryRSAKeyPEM,omitempty,file"`
+	// PrimaryRSACertPEM the primary RSA certificate PEM, in place of PrimaryRSACertFile
+	PrimaryRSACertPEM []byte `validate:"required_with=PrimaryRSAKeyPEM,excluded_with=PrimaryRSACertFile"`
+	// PrimaryRSAKeyPEM the primary RSA certificate private key PEM, in place of
+	// PrimaryRSAKeyFile
+	PrimaryRSAKeyPEM []byte `validate:"required_with=PrimaryRSACertPEM,excluded_with=PrimaryRSAKeyFile"`
+	// PrimaryRSAKeyPassphrase passphrase protecting the primary RSA private key, either a
+	// PKCS#8 key encrypted with PBES2 or a PEM block with legacy OpenSSL encryption. Also
+	// used for key pairs loaded by ReloadRSAKeyPair. Empty if the key is not encrypted.
+	PrimaryRSAKeyPassphrase []byte `validate:"-"`
+	// SecondaryRSAKeyFiles file paths to additional RSA private key PEMs
+	SecondaryRSAKeyFiles []string `validate:"omitempty,dive,file"`
+	// AEADType the AEAD algorithm new encryption keys are used with. Defaults to
+	// XChaCha20-Poly1305. Existing keys keep using the algorithm they were defined with.
+	AEADType models.AEADTypeENUMType `validate:"omitempty,aead_type"`
+	// MaxConcurrentDecryptions max number of decryptions performed at once. Defaults to
+	// GOMAXPROCS.
+	MaxConcurrentDecryptions int `validate:"gte=0"`
+	// MaxCachedKeys max number of decrypted symmetric keys cached. Once reached, the least
+	// recently used key is evicted. Zero for no limit.
+	MaxCachedKeys int `validate:"gte=0"`
+	// CachedKeyTTL how long a decrypted symmetric key stays cached before it must be
+	// decrypted again. Zero for no limit.
+	CachedKeyTTL time.Duration `validate:"gte=0"`
+	// Metrics registry to register the engine's Prometheus metrics with. Nil to not track
+	// metrics.
+	Metrics prometheus.Registerer `validate:"-"`
+	// StrictKeyConsistency whether a cached encryption key which is no longer in the
+	// database is dropped from cache, with its use failing with ErrKeyRevoked. Otherwise,
+	// its use fails with db.ErrEncryptionKeyNotFound, and the key stays cached.
+	StrictKeyConsistency bool
+	// InsecurePlaintextKeys INSECURE, FOR TESTS ONLY. Store symmetric keys unwrapped in the
+	// database, without any RSA keys. Anyone with access to the database can decrypt every
+	// value. The RSA key files are ignored, and the operations needing them fail with
+	// ErrNoRSAKeys. Refused with ErrInsecureModeRefused in builds with the production tag.
+	InsecurePlaintextKeys bool
+	// KeyWrapper encrypts and decrypts symmetric keys in place of the RSA key pairs, such
+	// as one backed by an external KMS. The RSA key files are ignored, and the operations
+	// needing them fail with ErrNoRSAKeys. Nil to use the RSA key pairs.
+	KeyWrapper KeyWrapper `validate:"-"`
 }
 
 /*
@@ -190,6 +452,16 @@ func NewCryptographyEngine(
 		"package": "haven", "module": "encryption", "component": "crypto-engine",
 	}
 
+	aeadType := params.AEADType
+	if aeadType == "" {
+		aeadType = models.AEADTypeXChaCha20Poly1305
+	}
+
+	maxDecryptions := params.MaxConcurrentDecryptions
+	if maxDecryptions <= 0 {
+		maxDecryptions = runtime.GOMAXPROCS(0)
+	}
+
 	instance := &cryptoEngine{
 		Component: goutils.Component{
 			LogTags: logTags,
@@ -200,21 +472,38 @@ func NewCryptographyEngine(
 		persistence:  params.Persistence,
 		validator:    validator.New(),
 		crypto:       engine,
+		aeadType:     aeadType,
+		rsaKeysLock:  &sync.RWMutex{},
 		keyCacheLock: &sync.RWMutex{},
-		encKeys:      make(map[string]encKeyCacheEntry),
+		encKeys:      newKeyCache(params.MaxCachedKeys, params.CachedKeyTTL),
+		decryptSlots: make(chan struct{}, maxDecryptions),
+
+		strictKeyConsistency: params.StrictKeyConsistency,
+		rsaKeyPassphrase:     append([]byte{}, params.PrimaryRSAKeyPassphrase...),
 	}
 	if err := models.RegisterWithValidator(instance.validator); err != nil {
 		return nil, fmt.Errorf("failed to install custom validation macros [%w]", err)
 	}
 
-	// Load the primary RSA certificate and private key
 	if err := instance.validator.Struct(&params); err != nil {
 		return nil, fmt.Errorf("invalid engine init parameters [%w]", err)
 	}
-	if err := instance.loadRSAKeyPair(
-		ctx, params.PrimaryRSACertFile, params.PrimaryRSAKeyFile,
-	); err != nil {
-		return nil, fmt.Errorf("failed to load primary RSA key pair [%w]", err)
+
+	if params.InsecurePlaintextKeys {
+		if !insecurePlaintextKeysAllowed {
+			return nil, ErrInsecureModeRefused
+		}
+		log.WithFields(logTags).
+			Warn("INSECURE: symmetric keys are stored unwrapped. Never use outside of tests!")
+		instance.insecurePlaintextKeys = true
+	} else if params.KeyWrapper != nil {
+		instance.keyWrapper = params.KeyWrapper
+	} else if instance.rsaKeys, err = instance.loadRSAKeySet(ctx, params); err != nil {
+		return nil, err
+	}
+
+	if instance.metrics, err = newEngineMetrics(params.Metrics); err != nil {
+		return nil, err
 	}
 
 	return instance, nil
@@ -2,18 +2,51 @@ package encryption
 
 import (
 	"context"
+	"crypto/hkdf"
+	"crypto/sha256"
 	"fmt"
 
 	cgoCrypto "github.com/alwitt/cgoutils/crypto"
 	"github.com/alwitt/haven/db"
 	"github.com/alwitt/haven/models"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// keyAEADType the AEAD algorithm of an encryption key
+//
+// Keys recorded before the algorithm was tracked are XChaCha20-Poly1305 keys.
+func keyAEADType(keyEntry models.EncryptionKey) cgoCrypto.AEADTypeEnum {
+	if keyEntry.AEADType == "" {
+		return cgoCrypto.AEADTypeXChaCha20Poly1305
+	}
+	return cgoCrypto.AEADTypeEnum(keyEntry.AEADType)
+}
+
+/*
+aeadKeyMaterial the AEAD key to use with an encryption key
+
+	@param keyMaterial []byte - the encryption key material
+	@param subkeyInfo []byte - HKDF-SHA256 info to derive the AEAD key with. Empty to use
+	    the encryption key directly.
+	@return the AEAD key. The key material itself if no subkey is derived.
+*/
+func aeadKeyMaterial(keyMaterial []byte, subkeyInfo []byte) ([]byte, error) {
+	if len(subkeyInfo) == 0 {
+		return keyMaterial, nil
+	}
+	subkey, err := hkdf.Key(sha256.New, keyMaterial, nil, string(subkeyInfo), len(keyMaterial))
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive subkey [%w]", err)
+	}
+	return subkey, nil
+}
+
 // setupAEAD prepare AEAD
 func (e *cryptoEngine) setupAEAD(
-	ctx context.Context, key []byte, nonce []byte,
+	ctx context.Context, aeadType cgoCrypto.AEADTypeEnum, key []byte, nonce []byte,
 ) (cgoCrypto.AEAD, error) {
-	aead, err := e.crypto.GetAEAD(ctx, cgoCrypto.AEADTypeXChaCha20Poly1305)
+	aead, err := e.crypto.GetAEAD(ctx, aeadType)
 	if err != nil {
 		return nil, fmt.Errorf("unable to define AEAD client [%w]", err)
 	}
@@ -74,16 +107,52 @@ func (e *cryptoEngine) setupAEAD(
 }
 
 /*
-EncryptData encrypt plain text
+EncryptData encrypt plain text. The returned cipher text is prefixed with an envelope header
+recording the envelope format and AEAD algorithm.
 
 	@param ctx context.Context - execution context
 	@param keyID string - the encryption key ID
 	@param plainText []byte - the plain text to encrypt
+	@param aad []byte - associated additional data to bind the cipher text to. The same
+	    data must be provided to decrypt. Empty to not bind the cipher text.
+	@param subkeyInfo []byte - HKDF-SHA256 info to derive the AEAD key from the encryption
+	    key with. The same info must be provided to decrypt. Empty to use the encryption key
+	    directly.
 	@param activeDBClient Database - existing database transaction
 	@return key entry for the encryption, and the cipher text
 */
 func (e *cryptoEngine) EncryptData(
-	ctx context.Context, keyID string, plainText []byte, activeDBClient db.Database,
+	ctx context.Context,
+	keyID string,
+	plainText []byte,
+	aad []byte,
+	subkeyInfo []byte,
+	activeDBClient db.Database,
+) (models.EncryptionKey, EncryptedData, error) {
+	ctx, span := tracer.Start(
+		ctx, "encryption.EncryptData", trace.WithAttributes(attrEncryptionKeyID.String(keyID)),
+	)
+	defer span.End()
+
+	keyEntry, encrypted, err := e.encryptData(
+		ctx, keyID, plainText, aad, subkeyInfo, activeDBClient,
+	)
+	e.metrics.recordOperation("encrypt", err)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "encryption failed")
+	}
+	return keyEntry, encrypted, err
+}
+
+// encryptData core function for encrypting plain text
+func (e *cryptoEngine) encryptData(
+	ctx context.Context,
+	keyID string,
+	plainText []byte,
+	aad []byte,
+	subkeyInfo []byte,
+	activeDBClient db.Database,
 ) (models.EncryptionKey, EncryptedData, error) {
 	keyEntry, err := e.getEncryptionKey(ctx, keyID, activeDBClient)
 	if err != nil {
@@ -92,13 +161,27 @@ func (e *cryptoEngine) EncryptData(
 			fmt.Errorf("failed to get encryption key %s from cached [%w]", keyID, err)
 	}
 
+	if keyEntry.State == models.EncryptionKeyStateRetired {
+		return models.EncryptionKey{},
+			EncryptedData{},
+			fmt.Errorf("encryption key %s can not encrypt new data [%w]", keyID, ErrKeyRetired)
+	}
+
 	if len(keyEntry.plainTextKey) == 0 || keyEntry.State != models.EncryptionKeyStateActive {
 		return models.EncryptionKey{},
 			EncryptedData{},
-			fmt.Errorf("failed to encryption key %s is not active or not decrypted [%w]", keyID, err)
+			fmt.Errorf("encryption key %s is not active or not decrypted [%w]", keyID, ErrKeyNotActive)
 	}
 
-	aead, err := e.setupAEAD(ctx, keyEntry.plainTextKey, nil)
+	aeadType := keyAEADType(keyEntry.EncryptionKey)
+	aeadKey, err := aeadKeyMaterial(keyEntry.plainTextKey, subkeyInfo)
+	if err != nil {
+		zeroKeyMaterial(keyEntry.plainTextKey)
+		return models.EncryptionKey{}, EncryptedData{}, err
+	}
+	aead, err := e.setupAEAD(ctx, aeadType, aeadKey, nil)
+	zeroKeyMaterial(aeadKey)
+	zeroKeyMaterial(keyEntry.plainTextKey)
 	if err != nil {
 		return models.EncryptionKey{},
 			EncryptedData{},
@@ -119,17 +202,30 @@ func (e *cryptoEngine) EncryptData(
 
 	// Encrypt the plain text
 	cipherText := make([]byte, aead.ExpectedCipherLen(int64(len(plainText))))
-	if err := aead.Seal(ctx, 0, plainText, nil, cipherText); err != nil {
+	if err := aead.Seal(ctx, 0, plainText, aad, cipherText); err != nil {
 		return models.EncryptionKey{},
 			EncryptedData{},
 			fmt.Errorf("failed to encrypt plain text [%w]", err)
 	}
 
-	return keyEntry.EncryptionKey, EncryptedData{CipherText: cipherText, Nonce: nonceCopy}, nil
+	wrapped, err := wrapEnvelope(aeadType, cipherText)
+	if err != nil {
+		return models.EncryptionKey{},
+			EncryptedData{},
+			fmt.Errorf("failed to wrap cipher text in envelope [%w]", err)
+	}
+
+	return keyEntry.EncryptionKey,
+		EncryptedData{CipherText: wrapped, Nonce: nonceCopy, AAD: aad, SubkeyInfo: subkeyInfo},
+		nil
 }
 
 /*
-DecryptData decrypt cipher text
+DecryptData decrypt cipher text. Decryption fails if the associated additional data
+does not match the data used during encryption. Cipher text without an envelope header is
+treated as format 0 and decrypted with the key's AEAD algorithm. Keys which are no longer
+active can still decrypt, though their material is decrypted on every call instead of
+cached.
 
 	@param ctx context.Context - execution context
 	@param keyID string - the encryption key ID
@@ -140,29 +236,219 @@ DecryptData decrypt cipher text
 func (e *cryptoEngine) DecryptData(
 	ctx context.Context, keyID string, encrypted EncryptedData, activeDBClient db.Database,
 ) (models.EncryptionKey, []byte, error) {
-	keyEntry, err := e.getEncryptionKey(ctx, keyID, activeDBClient)
+	keyEntry, plainText, err := e.decryptData(ctx, keyID, encrypted, activeDBClient)
+	e.metrics.recordOperation("decrypt", err)
+	return keyEntry, plainText, err
+}
+
+// decryptData core function for decrypting cipher text
+func (e *cryptoEngine) decryptData(
+	ctx context.Context, keyID string, encrypted EncryptedData, activeDBClient db.Database,
+) (models.EncryptionKey, []byte, error) {
+	envelope, err := unwrapEnvelope(encrypted.CipherText)
+	if err != nil {
+		return models.EncryptionKey{}, nil, fmt.Errorf("failed to parse cipher text [%w]", err)
+	}
+
+	keyEntry, err := e.getDecryptionKey(ctx, keyID, activeDBClient)
 	if err != nil {
 		return models.EncryptionKey{}, nil, fmt.Errorf(
 			"failed to get encryption key %s from cached [%w]", keyID, err,
 		)
 	}
 
-	if len(keyEntry.plainTextKey) == 0 || keyEntry.State != models.EncryptionKeyStateActive {
+	// Format 0 cipher text does not record its AEAD algorithm, so the key's is assumed
+	aeadType := keyAEADType(keyEntry.EncryptionKey)
+	if envelope.format != envelopeFormatLegacy && envelope.aeadType != aeadType {
+		zeroKeyMaterial(keyEntry.plainTextKey)
+		return models.EncryptionKey{}, nil, fmt.Errorf(
+			"cipher text AEAD algorithm %s does not match key %s algorithm %s",
+			envelope.aeadType, keyID, aeadType,
+		)
+	}
+
+	// Limit the number of concurrent decryptions
+	select {
+	case e.decryptSlots <- struct{}{}:
+	case <-ctx.Done():
+		zeroKeyMaterial(keyEntry.plainTextKey)
 		return models.EncryptionKey{}, nil, fmt.Errorf(
-			"failed to encryption key %s is not active or not decrypted [%w]", keyID, err,
+			"cancelled waiting to decrypt with key %s [%w]", keyID, ctx.Err(),
 		)
 	}
+	defer func() { <-e.decryptSlots }()
 
-	aead, err := e.setupAEAD(ctx, keyEntry.plainTextKey, encrypted.Nonce)
+	aeadKey, err := aeadKeyMaterial(keyEntry.plainTextKey, encrypted.SubkeyInfo)
+	if err != nil {
+		zeroKeyMaterial(keyEntry.plainTextKey)
+		return models.EncryptionKey{}, nil, err
+	}
+	aead, err := e.setupAEAD(ctx, aeadType, aeadKey, encrypted.Nonce)
+	zeroKeyMaterial(aeadKey)
+	zeroKeyMaterial(keyEntry.plainTextKey)
 	if err != nil {
 		return models.EncryptionKey{}, nil, fmt.Errorf("failed to setup AEAD client [%w]", err)
 	}
 
 	// Decrypt the cipher text
-	plainText := make([]byte, aead.ExpectedPlainTextLen(int64(len(encrypted.CipherText))))
-	if err := aead.Unseal(ctx, 0, encrypted.CipherText, nil, plainText); err != nil {
-		return models.EncryptionKey{}, nil, fmt.Errorf("failed to decrypt cipher text [%w]", err)
+	plainText := make([]byte, aead.ExpectedPlainTextLen(int64(len(envelope.cipherText))))
+	if err := aead.Unseal(ctx, 0, envelope.cipherText, encrypted.AAD, plainText); err != nil {
+		return models.EncryptionKey{}, nil, fmt.Errorf(
+			"failed to decrypt cipher text [%w] [%w]", ErrAuthenticationFailed, err,
+		)
 	}
 
 	return keyEntry.EncryptionKey, plainText, nil
 }
+
+/*
+RedistributeVersions re-encrypt the data record versions encrypted with the source
+encryption key onto the target encryption keys, assigning the versions to the target
+keys round-robin. Re-encrypted versions no longer reference the source key, so
+repeating the call only handles the versions which remain.
+
+	@param ctx context.Context - execution context
+	@param sourceKeyID string - the encryption key to move the versions off of
+	@param targetKeyIDs []string - the encryption keys to move the versions onto
+	@param activeDBClient Database - existing database transaction
+	@return number of versions re-encrypted
+*/
+func (e *cryptoEngine) RedistributeVersions(
+	ctx context.Context,
+	sourceKeyID string,
+	targetKeyIDs []string,
+	activeDBClient db.Database,
+) (int, error) {
+	if len(targetKeyIDs) == 0 {
+		return 0, fmt.Errorf("no target encryption keys given")
+	}
+	for _, targetKeyID := range targetKeyIDs {
+		if targetKeyID == sourceKeyID {
+			return 0, fmt.Errorf("source encryption key %s is also a target", sourceKeyID)
+		}
+	}
+
+	moved := 0
+	if dbErr := db.ActiveSessionWrapper(
+		ctx, activeDBClient, e.persistence, func(dbCtx context.Context, dbClient db.Database) error {
+			versions, err := dbClient.ListVersionsEncryptedByKey(
+				dbCtx,
+				models.EncryptionKey{ID: sourceKeyID},
+				db.RecordVersionQueryFilter{SortAscending: true},
+			)
+			if err != nil {
+				return fmt.Errorf("failed to list versions of encryption key %s [%w]", sourceKeyID, err)
+			}
+
+			for idx, version := range versions {
+				targetKeyID := targetKeyIDs[idx%len(targetKeyIDs)]
+
+				_, plainText, err := e.DecryptData(
+					dbCtx,
+					sourceKeyID,
+					EncryptedData{
+						CipherText: version.EncValue,
+						Nonce:      version.EncNonce,
+						AAD:        version.AAD(),
+						SubkeyInfo: version.SubkeyInfo(),
+					},
+					dbClient,
+				)
+				if err != nil {
+					return fmt.Errorf("failed to decrypt record version %s [%w]", version.ID, err)
+				}
+
+				targetKey, encrypted, err := e.EncryptData(
+					dbCtx, targetKeyID, plainText, version.AAD(), version.SubkeyInfo(), dbClient,
+				)
+				zeroKeyMaterial(plainText)
+				if err != nil {
+					return fmt.Errorf(
+						"failed to re-encrypt record version %s with key %s [%w]",
+						version.ID,
+						targetKeyID,
+						err,
+					)
+				}
+
+				if _, err := dbClient.ReencryptRecordVersion(
+					dbCtx,
+					version.ID,
+					targetKey,
+					encrypted.CipherText,
+					encrypted.Nonce,
+					version.KeyDerivation,
+				); err != nil {
+					return fmt.Errorf("failed to update record version %s [%w]", version.ID, err)
+				}
+				moved++
+			}
+
+			return nil
+		},
+	); dbErr != nil {
+		return 0, fmt.Errorf(
+			"failed to redistribute versions of encryption key %s [%w]", sourceKeyID, dbErr,
+		)
+	}
+
+	return moved, nil
+}
+
+/*
+VerifyKeyVersionsDecryptable attempt to decrypt every data record version encrypted with an
+encryption key, reporting the versions which fail. The decrypted values are discarded.
+
+	@param ctx context.Context - execution context
+	@param keyID string - the encryption key ID
+	@param activeDBClient Database - existing database transaction
+	@return whether every version decrypted, and the IDs of the versions which did not
+*/
+func (e *cryptoEngine) VerifyKeyVersionsDecryptable(
+	ctx context.Context, keyID string, activeDBClient db.Database,
+) (bool, []string, error) {
+	failedVersionIDs := []string{}
+	if dbErr := db.ActiveSessionWrapper(
+		ctx, activeDBClient, e.persistence, func(dbCtx context.Context, dbClient db.Database) error {
+			if _, err := dbClient.GetEncryptionKey(dbCtx, keyID); err != nil {
+				return err
+			}
+
+			versions, err := dbClient.ListVersionsEncryptedByKey(
+				dbCtx,
+				models.EncryptionKey{ID: keyID},
+				db.RecordVersionQueryFilter{SortAscending: true},
+			)
+			if err != nil {
+				return fmt.Errorf("failed to list versions of encryption key %s [%w]", keyID, err)
+			}
+
+			for _, version := range versions {
+				_, plainText, err := e.DecryptData(
+					dbCtx,
+					keyID,
+					EncryptedData{
+						CipherText: version.EncValue,
+						Nonce:      version.EncNonce,
+						AAD:        version.AAD(),
+						SubkeyInfo: version.SubkeyInfo(),
+					},
+					dbClient,
+				)
+				if err != nil {
+					failedVersionIDs = append(failedVersionIDs, version.ID)
+					continue
+				}
+				zeroKeyMaterial(plainText)
+			}
+
+			return nil
+		},
+	); dbErr != nil {
+		return false, nil, fmt.Errorf(
+			"failed to verify versions of encryption key %s [%w]", keyID, dbErr,
+		)
+	}
+
+	return len(failedVersionIDs) == 0, failedVersionIDs, nil
+}
@@ -0,0 +1,124 @@
+package encryption
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cgoCrypto "github.com/alwitt/cgoutils/crypto"
+	mockdb "github.com/alwitt/haven/mocks/db"
+	"github.com/alwitt/haven/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// concurrencyTrackingCrypto core crypto engine which tracks the number of secure buffer
+// allocations in progress at once
+type concurrencyTrackingCrypto struct {
+	cgoCrypto.Engine
+	active  atomic.Int32
+	maxSeen atomic.Int32
+}
+
+func (c *concurrencyTrackingCrypto) AllocateSecureCSlice(
+	length int,
+) (cgoCrypto.SecureCSlice, error) {
+	active := c.active.Add(1)
+	defer c.active.Add(-1)
+	for {
+		maxSeen := c.maxSeen.Load()
+		if active <= maxSeen || c.maxSeen.CompareAndSwap(maxSeen, active) {
+			break
+		}
+	}
+	// Widen the window for overlapping calls
+	time.Sleep(5 * time.Millisecond)
+	return c.Engine.AllocateSecureCSlice(length)
+}
+
+func TestCryptoEngineDecryptConcurrencyLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	utCtx := context.Background()
+
+	testCertFile, err := filepath.Abs("../test/ut_rsa.crt")
+	assert.Nil(err)
+	testKeyFile, err := filepath.Abs("../test/ut_rsa.key")
+	assert.Nil(err)
+
+	mockDBClient := mockdb.NewClient(t)
+	mockDatabase := mockdb.NewDatabase(t)
+
+	maxDecryptions := 2
+	engine, err := NewCryptographyEngine(utCtx, CryptographyEngineParams{
+		Persistence:              mockDBClient,
+		PrimaryRSACertFile:       testCertFile,
+		PrimaryRSAKeyFile:        testKeyFile,
+		MaxConcurrentDecryptions: maxDecryptions,
+	})
+	assert.Nil(err)
+	uut, ok := engine.(*cryptoEngine)
+	assert.True(ok)
+
+	testKey := models.EncryptionKey{ID: uuid.NewString(), State: models.EncryptionKeyStateActive}
+	mockDatabase.On(
+		"RecordEncryptionKey",
+		mock.AnythingOfType("context.backgroundCtx"),
+		mock.AnythingOfType("[]uint8"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("models.AEADTypeENUMType"),
+	).Run(func(args mock.Arguments) {
+		encKey, ok := args.Get(1).([]byte)
+		assert.True(ok)
+		testKey.EncKeyMaterial = encKey
+	}).Return(func(
+		context.Context, []byte, string, string, models.AEADTypeENUMType,
+	) (models.EncryptionKey, error) {
+		return testKey, nil
+	}).Once()
+	_, err = uut.NewEncryptionKey(utCtx, mockDatabase)
+	assert.Nil(err)
+
+	mockDatabase.On(
+		"GetEncryptionKey", mock.Anything, testKey.ID,
+	).Return(func(context.Context, string) (models.EncryptionKey, error) {
+		return testKey, nil
+	})
+
+	plainText := []byte(uuid.NewString())
+	_, cipherText, err := uut.EncryptData(utCtx, testKey.ID, plainText, nil, nil, mockDatabase)
+	assert.Nil(err)
+
+	// Instrument the AEAD setup
+	tracker := &concurrencyTrackingCrypto{Engine: uut.crypto}
+	uut.crypto = tracker
+
+	wg := sync.WaitGroup{}
+	for itr := 0; itr < maxDecryptions*4; itr++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, decrypted, err := uut.DecryptData(utCtx, testKey.ID, cipherText, mockDatabase)
+			assert.Nil(err)
+			assert.Equal(plainText, decrypted)
+		}()
+	}
+	wg.Wait()
+
+	assert.Greater(tracker.maxSeen.Load(), int32(0))
+	assert.LessOrEqual(tracker.maxSeen.Load(), int32(maxDecryptions))
+
+	// Waiting for a slot respects the context
+	for itr := 0; itr < maxDecryptions; itr++ {
+		uut.decryptSlots <- struct{}{}
+	}
+	cancelCtx, cancel := context.WithCancel(utCtx)
+	cancel()
+	_, _, err = uut.DecryptData(cancelCtx, testKey.ID, cipherText, mockDatabase)
+	assert.ErrorIs(err, context.Canceled)
+}
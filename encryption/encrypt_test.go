@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	cgoCrypto "github.com/alwitt/cgoutils/crypto"
+	"github.com/alwitt/haven/db"
 	"github.com/alwitt/haven/encryption"
 	mockdb "github.com/alwitt/haven/mocks/db"
 	"github.com/alwitt/haven/models"
@@ -47,6 +48,9 @@ func TestCryptoEngineEncryptData(t *testing.T) {
 		"RecordEncryptionKey",
 		mock.AnythingOfType("context.backgroundCtx"),
 		mock.AnythingOfType("[]uint8"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("models.AEADTypeENUMType"),
 	).Run(func(args mock.Arguments) {
 		encKey, ok := args.Get(1).([]byte)
 		assert.True(ok)
@@ -72,10 +76,10 @@ func TestCryptoEngineEncryptData(t *testing.T) {
 	// Perform encryption
 	mockDatabase.On(
 		"GetEncryptionKey",
-		mock.AnythingOfType("context.backgroundCtx"),
+		mock.Anything,
 		testKey1.ID,
 	).Return(testKey1, nil).Times(2)
-	encKey, cipherText, err := uut1.EncryptData(utCtx, testKey1.ID, plainText, mockDatabase)
+	encKey, cipherText, err := uut1.EncryptData(utCtx, testKey1.ID, plainText, nil, nil, mockDatabase)
 	assert.Nil(err)
 	assert.Equal(testKey1.ID, encKey.ID)
 
@@ -83,4 +87,142 @@ func TestCryptoEngineEncryptData(t *testing.T) {
 	encKey, decrypted, err := uut1.DecryptData(utCtx, testKey1.ID, cipherText, mockDatabase)
 	assert.Nil(err)
 	assert.Equal(plainText, decrypted)
+
+	// Bind the cipher text to associated data
+	mockDatabase.On(
+		"GetEncryptionKey",
+		mock.Anything,
+		testKey1.ID,
+	).Return(testKey1, nil).Times(4)
+	aad := []byte(uuid.NewString())
+	_, boundCipherText, err := uut1.EncryptData(utCtx, testKey1.ID, plainText, aad, nil, mockDatabase)
+	assert.Nil(err)
+	assert.Equal(aad, boundCipherText.AAD)
+
+	_, decrypted, err = uut1.DecryptData(utCtx, testKey1.ID, boundCipherText, mockDatabase)
+	assert.Nil(err)
+	assert.Equal(plainText, decrypted)
+
+	// Decryption fails with mismatched associated data
+	mismatched := boundCipherText
+	mismatched.AAD = []byte(uuid.NewString())
+	_, _, err = uut1.DecryptData(utCtx, testKey1.ID, mismatched, mockDatabase)
+	assert.ErrorIs(err, encryption.ErrAuthenticationFailed)
+
+	mismatched.AAD = nil
+	_, _, err = uut1.DecryptData(utCtx, testKey1.ID, mismatched, mockDatabase)
+	assert.Error(err)
+
+	// Encrypt with subkeys derived for two different records
+	mockDatabase.On(
+		"GetEncryptionKey",
+		mock.Anything,
+		testKey1.ID,
+	).Return(testKey1, nil).Times(6)
+	info1 := models.RecordSubkeyInfo(uuid.NewString())
+	info2 := models.RecordSubkeyInfo(uuid.NewString())
+	_, subkeyCipherText1, err := uut1.EncryptData(
+		utCtx, testKey1.ID, plainText, nil, info1, mockDatabase,
+	)
+	assert.Nil(err)
+	assert.Equal(info1, subkeyCipherText1.SubkeyInfo)
+	_, subkeyCipherText2, err := uut1.EncryptData(
+		utCtx, testKey1.ID, plainText, nil, info2, mockDatabase,
+	)
+	assert.Nil(err)
+
+	// Both decrypt under the same encryption key
+	_, decrypted, err = uut1.DecryptData(utCtx, testKey1.ID, subkeyCipherText1, mockDatabase)
+	assert.Nil(err)
+	assert.Equal(plainText, decrypted)
+	_, decrypted, err = uut1.DecryptData(utCtx, testKey1.ID, subkeyCipherText2, mockDatabase)
+	assert.Nil(err)
+	assert.Equal(plainText, decrypted)
+
+	// The subkeys are distinct, and differ from the encryption key
+	mismatched = subkeyCipherText1
+	mismatched.SubkeyInfo = info2
+	_, _, err = uut1.DecryptData(utCtx, testKey1.ID, mismatched, mockDatabase)
+	assert.Error(err)
+
+	mismatched.SubkeyInfo = nil
+	_, _, err = uut1.DecryptData(utCtx, testKey1.ID, mismatched, mockDatabase)
+	assert.Error(err)
+
+	// Cipher text is wrapped in an envelope
+	assert.Equal([]byte("HVNE"), cipherText.CipherText[:4])
+
+	// Cipher text without an envelope is decrypted as format 0
+	mockDatabase.On(
+		"GetEncryptionKey",
+		mock.Anything,
+		testKey1.ID,
+	).Return(testKey1, nil).Once()
+	legacy := cipherText
+	legacy.CipherText = cipherText.CipherText[6:]
+	_, decrypted, err = uut1.DecryptData(utCtx, testKey1.ID, legacy, mockDatabase)
+	assert.Nil(err)
+	assert.Equal(plainText, decrypted)
+
+	// Malformed envelope headers are rejected
+	malformed := cipherText
+	malformed.CipherText = append([]byte{}, cipherText.CipherText...)
+	malformed.CipherText[4] = 9
+	_, _, err = uut1.DecryptData(utCtx, testKey1.ID, malformed, mockDatabase)
+	assert.ErrorIs(err, encryption.ErrMalformedEnvelope)
+
+	malformed.CipherText = []byte("HVNE")
+	_, _, err = uut1.DecryptData(utCtx, testKey1.ID, malformed, mockDatabase)
+	assert.ErrorIs(err, encryption.ErrMalformedEnvelope)
+
+	// Truncated cipher text is rejected
+	for _, truncated := range [][]byte{
+		cipherText.CipherText[:6],
+		cipherText.CipherText[:6+15],
+		cipherText.CipherText[6 : 6+15],
+	} {
+		malformed.CipherText = truncated
+		_, _, err = uut1.DecryptData(utCtx, testKey1.ID, malformed, mockDatabase)
+		assert.ErrorIs(err, encryption.ErrMalformedEnvelope)
+	}
+}
+
+func TestCryptoEngineEncryptDataRetiredKey(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// RSA cert files
+	testCertFile, err := filepath.Abs("../test/ut_rsa.crt")
+	assert.Nil(err)
+	testKeyFile, err := filepath.Abs("../test/ut_rsa.key")
+	assert.Nil(err)
+
+	mockDBClient := mockdb.NewClient(t)
+	mockDatabase := mockdb.NewDatabase(t)
+
+	uut, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+		Persistence:        mockDBClient,
+		PrimaryRSACertFile: testCertFile,
+		PrimaryRSAKeyFile:  testKeyFile,
+	})
+	assert.Nil(err)
+
+	// A retired key can not encrypt new data
+	retiredKey := models.EncryptionKey{
+		ID:             uuid.NewString(),
+		EncKeyMaterial: []byte(uuid.NewString()),
+		State:          models.EncryptionKeyStateRetired,
+	}
+	mockDatabase.On(
+		"GetEncryptionKey",
+		mock.Anything,
+		retiredKey.ID,
+	).Return(retiredKey, nil).Once()
+	_, _, err = uut.EncryptData(
+		utCtx, retiredKey.ID, []byte(uuid.NewString()), nil, nil, mockDatabase,
+	)
+	assert.ErrorIs(err, encryption.ErrKeyRetired)
+	assert.ErrorIs(err, db.ErrEncryptionKeyRetired)
 }
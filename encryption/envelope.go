@@ -0,0 +1,121 @@
+package encryption
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	cgoCrypto "github.com/alwitt/cgoutils/crypto"
+)
+
+// ErrMalformedEnvelope the cipher text envelope header could not be parsed, or the cipher
+// text is too short to hold the AEAD tag
+var ErrMalformedEnvelope = errors.New("malformed cipher text envelope")
+
+/*
+Cipher text envelope
+
+Cipher text produced by EncryptData is prefixed with a header describing how it was
+produced:
+
+	| magic (4 bytes) | format version (1 byte) | AEAD algorithm ID (1 byte) | cipher text |
+
+Cipher text without the magic prefix is treated as format 0: raw cipher text produced
+before the envelope was introduced.
+*/
+
+// envelopeMagic marks cipher text wrapped in an envelope
+var envelopeMagic = []byte{'H', 'V', 'N', 'E'}
+
+const (
+	// envelopeFormatLegacy raw cipher text without envelope
+	envelopeFormatLegacy byte = 0
+	// envelopeFormatV1 the current envelope format
+	envelopeFormatV1 byte = 1
+)
+
+// envelopeHeaderLen length of the envelope header
+var envelopeHeaderLen = len(envelopeMagic) + 2
+
+// aeadTagLen length of the authentication tag every supported AEAD algorithm appends to
+// the cipher text
+const aeadTagLen = 16
+
+// envelopeAEADIDs the envelope algorithm IDs of the AEAD algorithms
+var envelopeAEADIDs = map[cgoCrypto.AEADTypeEnum]byte{
+	cgoCrypto.AEADTypeXChaCha20Poly1305: 1,
+	cgoCrypto.AEADTypeAes256gcm:         2,
+}
+
+// cipherEnvelope parsed cipher text envelope
+type cipherEnvelope struct {
+	// format the envelope format version
+	format byte
+	// aeadType the AEAD algorithm. Empty for format 0, which does not record it.
+	aeadType cgoCrypto.AEADTypeEnum
+	// cipherText the cipher text without header
+	cipherText []byte
+}
+
+// wrapEnvelope prefix cipher text with the envelope header
+func wrapEnvelope(aeadType cgoCrypto.AEADTypeEnum, cipherText []byte) ([]byte, error) {
+	aeadID, ok := envelopeAEADIDs[aeadType]
+	if !ok {
+		return nil, fmt.Errorf("AEAD type '%s' has no envelope algorithm ID", aeadType)
+	}
+	wrapped := make([]byte, 0, envelopeHeaderLen+len(cipherText))
+	wrapped = append(wrapped, envelopeMagic...)
+	wrapped = append(wrapped, envelopeFormatV1, aeadID)
+	return append(wrapped, cipherText...), nil
+}
+
+// unwrapEnvelope parse the envelope header of cipher text
+//
+// Cipher text shorter than the AEAD tag is rejected, as no AEAD algorithm produces it.
+func unwrapEnvelope(data []byte) (cipherEnvelope, error) {
+	if !bytes.HasPrefix(data, envelopeMagic) {
+		if len(data) < aeadTagLen {
+			return cipherEnvelope{}, fmt.Errorf(
+				"cipher text of %d bytes is shorter than the AEAD tag [%w]",
+				len(data),
+				ErrMalformedEnvelope,
+			)
+		}
+		return cipherEnvelope{format: envelopeFormatLegacy, cipherText: data}, nil
+	}
+
+	if len(data) < envelopeHeaderLen {
+		return cipherEnvelope{}, fmt.Errorf("truncated header [%w]", ErrMalformedEnvelope)
+	}
+
+	format := data[len(envelopeMagic)]
+	if format != envelopeFormatV1 {
+		return cipherEnvelope{}, fmt.Errorf(
+			"unsupported format version %d [%w]", format, ErrMalformedEnvelope,
+		)
+	}
+
+	aeadID := data[len(envelopeMagic)+1]
+	var aeadType cgoCrypto.AEADTypeEnum
+	for knownType, knownID := range envelopeAEADIDs {
+		if knownID == aeadID {
+			aeadType = knownType
+		}
+	}
+	if aeadType == "" {
+		return cipherEnvelope{}, fmt.Errorf(
+			"unknown AEAD algorithm ID %d [%w]", aeadID, ErrMalformedEnvelope,
+		)
+	}
+
+	cipherText := data[envelopeHeaderLen:]
+	if len(cipherText) < aeadTagLen {
+		return cipherEnvelope{}, fmt.Errorf(
+			"cipher text of %d bytes is shorter than the AEAD tag [%w]",
+			len(cipherText),
+			ErrMalformedEnvelope,
+		)
+	}
+
+	return cipherEnvelope{format: format, aeadType: aeadType, cipherText: cipherText}, nil
+}
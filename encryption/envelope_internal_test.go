@@ -0,0 +1,88 @@
+package encryption
+
+import (
+	"testing"
+
+	cgoCrypto "github.com/alwitt/cgoutils/crypto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCipherTextEnvelope(t *testing.T) {
+	assert := assert.New(t)
+
+	cipherText := []byte(uuid.NewString())
+
+	// Round trip each supported algorithm
+	for _, aeadType := range []cgoCrypto.AEADTypeEnum{
+		cgoCrypto.AEADTypeXChaCha20Poly1305, cgoCrypto.AEADTypeAes256gcm,
+	} {
+		wrapped, err := wrapEnvelope(aeadType, cipherText)
+		assert.Nil(err)
+		assert.Len(wrapped, envelopeHeaderLen+len(cipherText))
+
+		envelope, err := unwrapEnvelope(wrapped)
+		assert.Nil(err)
+		assert.Equal(envelopeFormatV1, envelope.format)
+		assert.Equal(aeadType, envelope.aeadType)
+		assert.Equal(cipherText, envelope.cipherText)
+	}
+
+	// Unknown algorithm can not be wrapped
+	_, err := wrapEnvelope(cgoCrypto.AEADTypeEnum("unknown"), cipherText)
+	assert.Error(err)
+
+	// Un-prefixed cipher text is format 0
+	envelope, err := unwrapEnvelope(cipherText)
+	assert.Nil(err)
+	assert.Equal(envelopeFormatLegacy, envelope.format)
+	assert.Empty(envelope.aeadType)
+	assert.Equal(cipherText, envelope.cipherText)
+
+	// Malformed headers
+	for _, malformed := range [][]byte{
+		append([]byte{}, envelopeMagic...),
+		append(append([]byte{}, envelopeMagic...), envelopeFormatV1),
+		append(append([]byte{}, envelopeMagic...), 2, 1),
+		append(append([]byte{}, envelopeMagic...), envelopeFormatV1, 0),
+		append(append([]byte{}, envelopeMagic...), envelopeFormatV1, 255),
+	} {
+		_, err := unwrapEnvelope(malformed)
+		assert.ErrorIs(err, ErrMalformedEnvelope)
+	}
+}
+
+func TestCipherTextEnvelopeTruncated(t *testing.T) {
+	assert := assert.New(t)
+
+	header, err := wrapEnvelope(cgoCrypto.AEADTypeXChaCha20Poly1305, nil)
+	assert.Nil(err)
+
+	testCases := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{name: "header only", data: header, err: ErrMalformedEnvelope},
+		{
+			name: "tag minus one",
+			data: append(append([]byte{}, header...), make([]byte, aeadTagLen-1)...),
+			err:  ErrMalformedEnvelope,
+		},
+		{
+			name: "tag only",
+			data: append(append([]byte{}, header...), make([]byte, aeadTagLen)...),
+		},
+		{name: "empty legacy", data: []byte{}, err: ErrMalformedEnvelope},
+		{name: "legacy tag minus one", data: make([]byte, aeadTagLen-1), err: ErrMalformedEnvelope},
+		{name: "legacy tag only", data: make([]byte, aeadTagLen)},
+	}
+	for _, testCase := range testCases {
+		_, err := unwrapEnvelope(testCase.data)
+		if testCase.err != nil {
+			assert.ErrorIs(err, testCase.err, testCase.name)
+		} else {
+			assert.Nil(err, testCase.name)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package encryption_test
 
 import (
 	"context"
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -36,4 +37,128 @@ func TestCryptoEngineInit(t *testing.T) {
 		})
 		assert.Nil(err)
 	}
+
+	testCertPEM, err := os.ReadFile(testCertFile)
+	assert.Nil(err)
+	testKeyPEM, err := os.ReadFile(testKeyFile)
+	assert.Nil(err)
+
+	// Case 2: with in-memory RSA PEM
+	{
+		uut, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+			PrimaryRSACertPEM: testCertPEM,
+			PrimaryRSAKeyPEM:  testKeyPEM,
+		})
+		assert.Nil(err)
+
+		// The key pair is the same as the one read from file
+		fromFile, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+			PrimaryRSACertFile: testCertFile,
+			PrimaryRSAKeyFile:  testKeyFile,
+		})
+		assert.Nil(err)
+		secret := []byte("in-memory PEM secret")
+		wrapped, err := uut.WrapSecret(utCtx, secret)
+		assert.Nil(err)
+		unwrapped, err := fromFile.UnwrapSecret(utCtx, wrapped)
+		assert.Nil(err)
+		assert.Equal(secret, unwrapped)
+	}
+
+	// Case 3: both RSA cert file and PEM
+	{
+		_, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+			PrimaryRSACertFile: testCertFile,
+			PrimaryRSAKeyFile:  testKeyFile,
+			PrimaryRSACertPEM:  testCertPEM,
+			PrimaryRSAKeyPEM:   testKeyPEM,
+		})
+		assert.Error(err)
+	}
+
+	// Case 4: RSA cert PEM without the private key
+	{
+		_, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+			PrimaryRSACertPEM: testCertPEM,
+		})
+		assert.Error(err)
+	}
+
+	// Case 5: malformed RSA PEM
+	{
+		_, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+			PrimaryRSACertPEM: testCertPEM,
+			PrimaryRSAKeyPEM:  []byte("not a PEM"),
+		})
+		assert.Error(err)
+	}
+}
+
+func TestCryptoEngineInitEncryptedRSAKey(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// RSA cert files. The encrypted keys hold the same key as ut_rsa.key.
+	testCertFile, err := filepath.Abs("../test/ut_rsa.crt")
+	assert.Nil(err)
+	testKeyFile, err := filepath.Abs("../test/ut_rsa.key")
+	assert.Nil(err)
+	encryptedKeyFiles := map[string]string{}
+	for scheme, keyFile := range map[string]string{
+		"pkcs8":  "../test/ut_rsa_encrypted.key",
+		"legacy": "../test/ut_rsa_encrypted_legacy.key",
+	} {
+		encryptedKeyFiles[scheme], err = filepath.Abs(keyFile)
+		assert.Nil(err)
+	}
+	passphrase := []byte("haven-ut-passphrase")
+
+	plainKey, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+		PrimaryRSACertFile: testCertFile,
+		PrimaryRSAKeyFile:  testKeyFile,
+	})
+	assert.Nil(err)
+
+	for scheme, keyFile := range encryptedKeyFiles {
+		// Correct passphrase
+		uut, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+			PrimaryRSACertFile:      testCertFile,
+			PrimaryRSAKeyFile:       keyFile,
+			PrimaryRSAKeyPassphrase: passphrase,
+		})
+		assert.Nil(err, scheme)
+		if err == nil {
+			secret := []byte("encrypted RSA key secret")
+			wrapped, err := uut.WrapSecret(utCtx, secret)
+			assert.Nil(err, scheme)
+			unwrapped, err := plainKey.UnwrapSecret(utCtx, wrapped)
+			assert.Nil(err, scheme)
+			assert.Equal(secret, unwrapped, scheme)
+		}
+
+		// Incorrect passphrase
+		_, err = encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+			PrimaryRSACertFile:      testCertFile,
+			PrimaryRSAKeyFile:       keyFile,
+			PrimaryRSAKeyPassphrase: []byte("not-the-passphrase"),
+		})
+		assert.ErrorIs(err, encryption.ErrIncorrectRSAKeyPassphrase, scheme)
+
+		// No passphrase
+		_, err = encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+			PrimaryRSACertFile: testCertFile,
+			PrimaryRSAKeyFile:  keyFile,
+		})
+		assert.Error(err, scheme)
+	}
+
+	// A passphrase for a key which is not encrypted
+	_, err = encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+		PrimaryRSACertFile:      testCertFile,
+		PrimaryRSAKeyFile:       testKeyFile,
+		PrimaryRSAKeyPassphrase: passphrase,
+	})
+	assert.Error(err)
 }
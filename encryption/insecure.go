@@ -0,0 +1,31 @@
+package encryption
+
+import (
+	"errors"
+)
+
+// insecurePlaintextFingerprint the RSA fingerprint recorded for symmetric key material
+// stored unwrapped. No RSA key has this fingerprint, so engines with RSA keys refuse to
+// use such keys.
+const insecurePlaintextFingerprint = "INSECURE-PLAINTEXT"
+
+// ErrNoRSAKeys the operation needs RSA keys, which an engine in insecure plaintext keys
+// mode, or with a KeyWrapper, does not have
+var ErrNoRSAKeys = errors.New("engine has no RSA keys")
+
+// ErrInsecureModeRefused insecure plaintext keys mode was requested in a production build
+var ErrInsecureModeRefused = errors.New("insecure plaintext keys mode refused in production builds")
+
+// plaintextKeyWrapper KeyWrapper of the insecure plaintext keys mode, which stores
+// symmetric keys unwrapped
+type plaintextKeyWrapper struct{}
+
+// WrapKey return a copy of the key material as is
+func (plaintextKeyWrapper) WrapKey(plainKey []byte) ([]byte, error) {
+	return append([]byte{}, plainKey...), nil
+}
+
+// UnwrapKey return a copy of the key material as is
+func (plaintextKeyWrapper) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	return append([]byte{}, wrappedKey...), nil
+}
@@ -0,0 +1,6 @@
+//go:build production
+
+package encryption
+
+// insecurePlaintextKeysAllowed whether the build allows insecure plaintext keys mode
+const insecurePlaintextKeysAllowed = false
@@ -2,11 +2,16 @@ package encryption
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 
 	"github.com/alwitt/cgoutils/crypto"
 	"github.com/alwitt/haven/db"
 	"github.com/alwitt/haven/models"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 /*
@@ -22,7 +27,7 @@ func (e *cryptoEngine) NewEncryptionKey(
 	// RNG for generating the key
 	rng := e.crypto.GetRNGReader()
 
-	aead, err := e.crypto.GetAEAD(ctx, crypto.AEADTypeXChaCha20Poly1305)
+	aead, err := e.crypto.GetAEAD(ctx, crypto.AEADTypeEnum(e.aeadType))
 	if err != nil {
 		return models.EncryptionKey{}, fmt.Errorf("unable to define AEAD client [%w]", err)
 	}
@@ -37,7 +42,7 @@ func (e *cryptoEngine) NewEncryptionKey(
 	}
 
 	// Encrypt the key for storage
-	newKeyEnc, err := e.crypto.RSAEncrypt(ctx, newKey, e.rsaPubKey, nil)
+	newKeyEnc, rsaFingerprint, err := e.wrapKeyMaterial(ctx, newKey)
 	if err != nil {
 		return models.EncryptionKey{}, fmt.Errorf("failed to encrypt symmetric enc key [%w]", err)
 	}
@@ -46,7 +51,13 @@ func (e *cryptoEngine) NewEncryptionKey(
 	var keyEntry models.EncryptionKey
 	if dbErr := db.ActiveSessionWrapper(
 		ctx, activeDBClient, e.persistence, func(dbCtx context.Context, dbClient db.Database) error {
-			keyEntry, err = dbClient.RecordEncryptionKey(dbCtx, newKeyEnc)
+			keyEntry, err = dbClient.RecordEncryptionKey(
+				dbCtx,
+				newKeyEnc,
+				rsaFingerprint,
+				keyMaterialFingerprint(newKey),
+				e.aeadType,
+			)
 			return err
 		},
 	); dbErr != nil {
@@ -54,24 +65,59 @@ func (e *cryptoEngine) NewEncryptionKey(
 	}
 
 	// Cache the key and its DB entry
-	e.writeKeyToCache(keyEntry, newKey)
+	zeroKeyMaterial(e.writeKeyToCache(keyEntry, newKey).plainTextKey)
+	zeroKeyMaterial(newKey)
 
 	return keyEntry, nil
 }
 
+// keyMaterialFingerprint compute the SHA256 fingerprint of plain text key material
+func keyMaterialFingerprint(plainKey []byte) string {
+	digest := sha256.Sum256(plainKey)
+	return hex.EncodeToString(digest[:])
+}
+
+// zeroKeyMaterial overwrite decrypted key material with zeros
+func zeroKeyMaterial(plainKey []byte) {
+	for idx := range plainKey {
+		plainKey[idx] = 0
+	}
+}
+
 // writeKeyToCache write key into cache for use
-func (e *cryptoEngine) writeKeyToCache(keyEntry models.EncryptionKey, plainKey []byte) {
+//
+// The cache holds its own copy of the key material, so the copy can be zeroed when the
+// key is removed from cache. If the key is already cached, the existing entry is kept.
+// The returned entry holds a private copy of the key material, which the caller should
+// zero once done.
+func (e *cryptoEngine) writeKeyToCache(
+	keyEntry models.EncryptionKey, plainKey []byte,
+) encKeyCacheEntry {
 	e.keyCacheLock.Lock()
 	defer e.keyCacheLock.Unlock()
-	e.encKeys[keyEntry.ID] = encKeyCacheEntry{EncryptionKey: keyEntry, plainTextKey: plainKey}
+	entry, evicted := e.encKeys.put(encKeyCacheEntry{
+		EncryptionKey: keyEntry, plainTextKey: append([]byte{}, plainKey...),
+	})
+	e.cacheEvictions.Add(uint64(evicted))
+	return entry.privateCopy()
 }
 
 // getCachedKey helper function to read a key from cache
+//
+// The returned entry holds a private copy of the key material, which the caller should
+// zero once done.
 func (e *cryptoEngine) getCachedKey(keyID string) (encKeyCacheEntry, bool) {
-	e.keyCacheLock.RLock()
-	defer e.keyCacheLock.RUnlock()
-	entry, ok := e.encKeys[keyID]
-	return entry, ok
+	// Reading updates the LRU order, so the write lock is needed
+	e.keyCacheLock.Lock()
+	defer e.keyCacheLock.Unlock()
+	entry, ok, expired := e.encKeys.get(keyID)
+	if expired {
+		e.cacheEvictions.Add(1)
+	}
+	if !ok {
+		return encKeyCacheEntry{}, false
+	}
+	return entry.privateCopy(), true
 }
 
 func (e *cryptoEngine) cacheKey(
@@ -83,7 +129,17 @@ func (e *cryptoEngine) cacheKey(
 	}
 
 	// Decrypt the key
-	key, err := e.crypto.RSADecrypt(ctx, keyEntry.EncKeyMaterial, e.rsaKey, nil)
+	unwrapCtx, span := tracer.Start(
+		ctx,
+		"encryption.unwrapKeyMaterial",
+		trace.WithAttributes(attrEncryptionKeyID.String(keyEntry.ID)),
+	)
+	key, err := e.unwrapKeyMaterial(unwrapCtx, keyEntry)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "key material decryption failed")
+	}
+	span.End()
 	if err != nil {
 		return encKeyCacheEntry{EncryptionKey: keyEntry}, fmt.Errorf(
 			"failed to decrypt symmetric key %s [%w]", keyEntry.ID, err,
@@ -91,20 +147,126 @@ func (e *cryptoEngine) cacheKey(
 	}
 
 	// Cache the key and its DB entry
-	e.writeKeyToCache(keyEntry, key)
+	entry := e.writeKeyToCache(keyEntry, key)
+	zeroKeyMaterial(key)
+
+	return entry, nil
+}
+
+// wrapKeyMaterial encrypt a symmetric key with the KeyWrapper of the engine
+//
+// Returns the encrypted key, and the fingerprint of the RSA key which encrypted it.
+func (e *cryptoEngine) wrapKeyMaterial(
+	ctx context.Context, plainKey []byte,
+) ([]byte, string, error) {
+	wrapper, rsaFingerprint := e.keyWrapperForNewKeys(ctx)
+	encKey, err := wrapper.WrapKey(plainKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("key wrapper failed to encrypt symmetric key [%w]", err)
+	}
+	return encKey, rsaFingerprint, nil
+}
 
-	return encKeyCacheEntry{EncryptionKey: keyEntry, plainTextKey: key}, nil
+// unwrapKeyMaterial decrypt an encrypted symmetric key with the KeyWrapper of the engine
+func (e *cryptoEngine) unwrapKeyMaterial(
+	ctx context.Context, keyEntry models.EncryptionKey,
+) ([]byte, error) {
+	wrapper, err := e.keyWrapperForKey(ctx, keyEntry)
+	if err != nil {
+		return nil, err
+	}
+	key, err := wrapper.UnwrapKey(keyEntry.EncKeyMaterial)
+	if err != nil {
+		return nil, fmt.Errorf("key wrapper failed to decrypt symmetric key [%w]", err)
+	}
+	return key, nil
 }
 
-// uncacheKey remove a key from cache
-func (e *cryptoEngine) uncacheKey(keyID string) {
+// keyWrapperForNewKeys the KeyWrapper which encrypts new symmetric keys, and the RSA
+// fingerprint recorded for the keys it encrypts
+//
+// This is the supplied KeyWrapper if the engine has one, which records no fingerprint.
+// Otherwise, the primary RSA public key encrypts the keys.
+func (e *cryptoEngine) keyWrapperForNewKeys(ctx context.Context) (KeyWrapper, string) {
+	if e.insecurePlaintextKeys {
+		return plaintextKeyWrapper{}, insecurePlaintextFingerprint
+	}
+	if e.keyWrapper != nil {
+		return e.keyWrapper, ""
+	}
+	rsaKeys := e.getRSAKeys()
+	return &rsaKeyWrapper{ctx: ctx, crypto: e.crypto, keys: rsaKeys}, rsaKeys.primaryFingerprint
+}
+
+// keyWrapperForKey the KeyWrapper which decrypts the key material of a key entry
+//
+// In insecure plaintext keys mode, only keys stored unwrapped are usable; with a supplied
+// KeyWrapper, only keys recorded without a RSA fingerprint are.
+func (e *cryptoEngine) keyWrapperForKey(
+	ctx context.Context, keyEntry models.EncryptionKey,
+) (KeyWrapper, error) {
+	if e.insecurePlaintextKeys {
+		if keyEntry.RSAFingerprint != insecurePlaintextFingerprint {
+			return nil, fmt.Errorf(
+				"symmetric key %s is wrapped with a RSA key [%w]", keyEntry.ID, ErrNoRSAKeys,
+			)
+		}
+		return plaintextKeyWrapper{}, nil
+	}
+	if e.keyWrapper != nil {
+		if keyEntry.RSAFingerprint != "" {
+			return nil, fmt.Errorf(
+				"symmetric key %s is not encrypted by the key wrapper [%w]",
+				keyEntry.ID,
+				ErrRSAKeyFingerprintMismatch,
+			)
+		}
+		return e.keyWrapper, nil
+	}
+	return &rsaKeyWrapper{
+		ctx: ctx, crypto: e.crypto, keys: e.getRSAKeys(), fingerprint: keyEntry.RSAFingerprint,
+	}, nil
+}
+
+// uncacheKey zero the key material and remove the key from cache. Returns whether the key
+// was cached.
+func (e *cryptoEngine) uncacheKey(keyID string) bool {
 	// Delete the key from cache
 	e.keyCacheLock.Lock()
 	defer e.keyCacheLock.Unlock()
-	delete(e.encKeys, keyID)
+	return e.encKeys.remove(keyID)
+}
+
+/*
+CacheStats snapshot the decrypted symmetric key cache statistics
+
+	@return the cache statistics
+*/
+func (e *cryptoEngine) CacheStats() KeyCacheStats {
+	e.keyCacheLock.RLock()
+	defer e.keyCacheLock.RUnlock()
+	return KeyCacheStats{
+		CachedKeyCount: e.encKeys.len(),
+		Keys:           e.encKeys.states(),
+		Hits:           e.cacheHits.Load(),
+		Misses:         e.cacheMisses.Load(),
+		Evictions:      e.cacheEvictions.Load(),
+	}
+}
+
+/*
+Shutdown zero and drop every decrypted symmetric key held in the key cache
+*/
+func (e *cryptoEngine) Shutdown() {
+	e.keyCacheLock.Lock()
+	defer e.keyCacheLock.Unlock()
+	e.encKeys.clear()
 }
 
 // getEncryptionKey core function for fetching on encryption key
+//
+// The returned entry holds a private copy of the key material, which the caller should
+// zero once done.
 func (e *cryptoEngine) getEncryptionKey(
 	ctx context.Context, keyID string, activeDBClient db.Database,
 ) (encKeyCacheEntry, error) {
@@ -116,6 +278,13 @@ func (e *cryptoEngine) getEncryptionKey(
 			return err
 		},
 	); dbErr != nil {
+		if e.strictKeyConsistency && errors.Is(dbErr, db.ErrEncryptionKeyNotFound) &&
+			e.uncacheKey(keyID) {
+			// The key was deleted from the database by another engine
+			return encKeyCacheEntry{}, fmt.Errorf(
+				"cached encryption key %s no longer in the database [%w]", keyID, ErrKeyRevoked,
+			)
+		}
 		return encKeyCacheEntry{}, fmt.Errorf("encryption key %s unknown [%w]", keyID, dbErr)
 	}
 
@@ -129,7 +298,12 @@ func (e *cryptoEngine) getEncryptionKey(
 	var err error
 
 	// Check key has been cached already
-	if plainKey, cached = e.getCachedKey(keyID); !cached {
+	plainKey, cached = e.getCachedKey(keyID)
+	e.metrics.recordCacheLookup(cached)
+	if cached {
+		e.cacheHits.Add(1)
+	} else {
+		e.cacheMisses.Add(1)
 		if plainKey, err = e.cacheKey(ctx, keyEntry); err != nil {
 			return encKeyCacheEntry{}, fmt.Errorf(
 				"unable to cache encryption key %s [%w]", keyID, err,
@@ -139,6 +313,39 @@ func (e *cryptoEngine) getEncryptionKey(
 	return plainKey, nil
 }
 
+// getDecryptionKey fetch an encryption key to decrypt with
+//
+// Only active keys are cached. The material of a key which is no longer active is decrypted
+// for this call alone, so values encrypted before the key was deactivated remain readable.
+// The returned entry holds a private copy of the key material, which the caller should
+// zero once done.
+func (e *cryptoEngine) getDecryptionKey(
+	ctx context.Context, keyID string, activeDBClient db.Database,
+) (encKeyCacheEntry, error) {
+	keyEntry, err := e.getEncryptionKey(ctx, keyID, activeDBClient)
+	if err != nil || len(keyEntry.plainTextKey) > 0 {
+		return keyEntry, err
+	}
+
+	unwrapCtx, span := tracer.Start(
+		ctx,
+		"encryption.unwrapKeyMaterial",
+		trace.WithAttributes(attrEncryptionKeyID.String(keyID)),
+	)
+	keyEntry.plainTextKey, err = e.unwrapKeyMaterial(unwrapCtx, keyEntry.EncryptionKey)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "key material decryption failed")
+	}
+	span.End()
+	if err != nil {
+		return encKeyCacheEntry{}, fmt.Errorf(
+			"failed to decrypt symmetric key %s [%w]", keyID, err,
+		)
+	}
+	return keyEntry, nil
+}
+
 /*
 GetEncryptionKey fetch one encryption key
 
@@ -151,11 +358,34 @@ func (e *cryptoEngine) GetEncryptionKey(
 	ctx context.Context, keyID string, activeDBClient db.Database,
 ) (models.EncryptionKey, error) {
 	keyEntry, err := e.getEncryptionKey(ctx, keyID, activeDBClient)
+	zeroKeyMaterial(keyEntry.plainTextKey)
 	return keyEntry.EncryptionKey, err
 }
 
+// wrappedUnderPrimaryRSAKey whether the key material is encrypted with the primary RSA
+// key. Keys without a recorded RSA key fingerprint are checked by trial decryption.
+func (e *cryptoEngine) wrappedUnderPrimaryRSAKey(
+	ctx context.Context, keyEntry models.EncryptionKey, rsaKeys *rsaKeySet,
+) bool {
+	if rsaKeys == nil {
+		// Insecure plaintext keys mode, or with a supplied key wrapper
+		_, rsaFingerprint := e.keyWrapperForNewKeys(ctx)
+		return keyEntry.RSAFingerprint == rsaFingerprint
+	}
+	if keyEntry.RSAFingerprint != "" {
+		return keyEntry.RSAFingerprint == rsaKeys.primaryFingerprint
+	}
+	plainKey, err := e.crypto.RSADecrypt(ctx, keyEntry.EncKeyMaterial, rsaKeys.primaryKey, nil)
+	if err != nil {
+		return false
+	}
+	zeroKeyMaterial(plainKey)
+	return true
+}
+
 /*
-ListEncryptionKeys list encryption keys
+ListEncryptionKeys list encryption keys. Keys whose material is not encrypted with the
+primary RSA key are marked with NeedsRewrap.
 
 	@param ctx context.Context - execution context
 	@param filters EncryptionKeyQueryFilter - entry listing filter
@@ -176,16 +406,25 @@ func (e *cryptoEngine) ListEncryptionKeys(
 		return nil, fmt.Errorf("failed to list encryption keys [%w]", dbErr)
 	}
 
+	// Flag keys not encrypted with the primary RSA key
+	rsaKeys := e.getRSAKeys()
+	for idx, entry := range keyEntries {
+		keyEntries[idx].NeedsRewrap = !e.wrappedUnderPrimaryRSAKey(ctx, entry, rsaKeys)
+	}
+
 	// Check keys have been cached already
 	for _, entry := range keyEntries {
 		if entry.State == models.EncryptionKeyStateActive {
-			if _, cached := e.getCachedKey(entry.ID); !cached {
-				if _, err := e.cacheKey(ctx, entry); err != nil {
+			cachedEntry, cached := e.getCachedKey(entry.ID)
+			if !cached {
+				var err error
+				if cachedEntry, err = e.cacheKey(ctx, entry); err != nil {
 					return nil, fmt.Errorf(
 						"unable to cache encryption key %s [%w]", entry.ID, err,
 					)
 				}
 			}
+			zeroKeyMaterial(cachedEntry.plainTextKey)
 		} else {
 			e.uncacheKey(entry.ID)
 		}
@@ -194,6 +433,51 @@ func (e *cryptoEngine) ListEncryptionKeys(
 	return keyEntries, nil
 }
 
+// keySummaryMaxKeys max number of key metadata entries returned by SummarizeKeys
+const keySummaryMaxKeys = 100
+
+/*
+SummarizeKeys count the encryption keys in each state, and list the metadata of the
+newest keys. No key material is decrypted.
+
+	@param ctx context.Context - execution context
+	@param activeDBClient Database - existing database transaction
+	@return the key summary
+*/
+func (e *cryptoEngine) SummarizeKeys(
+	ctx context.Context, activeDBClient db.Database,
+) (KeySummary, error) {
+	var summary KeySummary
+	if dbErr := db.ActiveSessionWrapper(
+		ctx, activeDBClient, e.persistence, func(dbCtx context.Context, dbClient db.Database) error {
+			var err error
+			summary.Counts, err = dbClient.CountEncryptionKeysByState(dbCtx)
+			if err != nil {
+				return err
+			}
+
+			// Fetch one more than the limit to detect truncation
+			limit := keySummaryMaxKeys + 1
+			summary.Keys, err = dbClient.ListEncryptionKeys(dbCtx, db.EncryptionKeyQueryFilter{
+				CommonListEntryQueryFilter: db.CommonListEntryQueryFilter{Limit: &limit},
+			})
+			return err
+		},
+	); dbErr != nil {
+		return KeySummary{}, fmt.Errorf("failed to summarize encryption keys [%w]", dbErr)
+	}
+
+	if len(summary.Keys) > keySummaryMaxKeys {
+		summary.Keys = summary.Keys[:keySummaryMaxKeys]
+		summary.Truncated = true
+	}
+	for idx := range summary.Keys {
+		summary.Keys[idx].EncKeyMaterial = nil
+	}
+
+	return summary, nil
+}
+
 /*
 MarkEncryptionKeyActive mark encryption key is active
 
@@ -217,11 +501,13 @@ func (e *cryptoEngine) MarkEncryptionKeyActive(
 				return fmt.Errorf("failed to fetch encryption key %s [%w]", keyID, err)
 			}
 			// Update the entry in cache
-			if _, err := e.cacheKey(ctx, keyEntry); err != nil {
+			cachedEntry, err := e.cacheKey(ctx, keyEntry)
+			if err != nil {
 				return fmt.Errorf(
 					"unable to cache encryption key %s [%w]", keyEntry.ID, err,
 				)
 			}
+			zeroKeyMaterial(cachedEntry.plainTextKey)
 			return nil
 		},
 	); dbErr != nil {
@@ -288,9 +574,74 @@ func (e *cryptoEngine) DeleteEncryptionKey(
 	}
 
 	// Delete the key from cache
-	e.keyCacheLock.Lock()
-	defer e.keyCacheLock.Unlock()
-	delete(e.encKeys, keyID)
+	e.uncacheKey(keyID)
 
 	return nil
 }
+
+/*
+RewrapEncryptionKeys re-encrypt the key material of every active encryption key with
+the primary RSA public key. Keys already encrypted with the primary RSA key pair
+are skipped.
+
+	@param ctx context.Context - execution context
+	@param activeDBClient Database - existing database transaction
+	@return number of keys re-encrypted
+*/
+func (e *cryptoEngine) RewrapEncryptionKeys(
+	ctx context.Context, activeDBClient db.Database,
+) (int, error) {
+	rewrapped := []string{}
+	rsaKeys, err := e.requireRSAKeys()
+	if err != nil {
+		return 0, err
+	}
+	if dbErr := db.ActiveSessionWrapper(
+		ctx, activeDBClient, e.persistence, func(dbCtx context.Context, dbClient db.Database) error {
+			activeKeys, err := dbClient.ListEncryptionKeys(dbCtx, db.EncryptionKeyQueryFilter{
+				TargetState: []models.EncryptionKeyStateENUMType{models.EncryptionKeyStateActive},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to list active encryption keys [%w]", err)
+			}
+
+			for _, keyEntry := range activeKeys {
+				// Skip keys already encrypted with the primary RSA key
+				if e.wrappedUnderPrimaryRSAKey(ctx, keyEntry, rsaKeys) {
+					continue
+				}
+
+				plainKey, err := e.unwrapKeyMaterial(ctx, keyEntry)
+				if err != nil {
+					return fmt.Errorf("failed to decrypt symmetric key %s [%w]", keyEntry.ID, err)
+				}
+
+				wrapper := &rsaKeyWrapper{ctx: ctx, crypto: e.crypto, keys: rsaKeys}
+				newKeyEnc, err := wrapper.WrapKey(plainKey)
+				zeroKeyMaterial(plainKey)
+				if err != nil {
+					return fmt.Errorf("failed to encrypt symmetric key %s [%w]", keyEntry.ID, err)
+				}
+
+				if err := dbClient.UpdateEncryptionKeyMaterial(
+					dbCtx, keyEntry.ID, newKeyEnc, rsaKeys.primaryFingerprint,
+				); err != nil {
+					return fmt.Errorf("failed to update encryption key %s [%w]", keyEntry.ID, err)
+				}
+
+				rewrapped = append(rewrapped, keyEntry.ID)
+			}
+
+			return nil
+		},
+	); dbErr != nil {
+		return 0, fmt.Errorf("failed to re-encrypt encryption keys [%w]", dbErr)
+	}
+
+	// Cached entries hold the previous key material
+	for _, keyID := range rewrapped {
+		e.uncacheKey(keyID)
+	}
+
+	return len(rewrapped), nil
+}
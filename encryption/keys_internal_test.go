@@ -0,0 +1,179 @@
+package encryption
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	cgoCrypto "github.com/alwitt/cgoutils/crypto"
+	mockdb "github.com/alwitt/haven/mocks/db"
+	"github.com/alwitt/haven/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// shortReadRNG RNG which returns at most limit bytes per read, and then err
+type shortReadRNG struct {
+	limit int
+	err   error
+}
+
+func (r shortReadRNG) Read(p []byte) (int, error) {
+	return min(len(p), r.limit), r.err
+}
+
+// fixedRNGCrypto core crypto engine whose RNG is replaced
+type fixedRNGCrypto struct {
+	cgoCrypto.Engine
+	rng io.Reader
+}
+
+func (c *fixedRNGCrypto) GetRNGReader() io.Reader {
+	return c.rng
+}
+
+func TestCryptoEngineUncacheZeroesKey(t *testing.T) {
+	assert := assert.New(t)
+
+	uut := &cryptoEngine{
+		keyCacheLock: &sync.RWMutex{},
+		encKeys:      newKeyCache(0, 0),
+	}
+
+	testKey := models.EncryptionKey{ID: uuid.NewString(), State: models.EncryptionKeyStateActive}
+	plainKey := []byte(uuid.NewString())
+
+	cached := uut.writeKeyToCache(testKey, plainKey)
+	assert.Equal(plainKey, cached.plainTextKey)
+
+	// The cache holds its own copy of the key
+	zeroKeyMaterial(plainKey)
+	entry, ok := uut.getCachedKey(testKey.ID)
+	assert.True(ok)
+	assert.NotEqual(plainKey, entry.plainTextKey)
+	stored := uut.encKeys.entries[testKey.ID].Value.(*keyCacheSlot).entry.plainTextKey
+
+	uut.uncacheKey(testKey.ID)
+	_, ok = uut.getCachedKey(testKey.ID)
+	assert.False(ok)
+	assert.Equal(make([]byte, len(stored)), stored)
+
+	// Copies handed out before the uncache are left untouched
+	assert.Equal(cached.plainTextKey, entry.plainTextKey)
+	assert.NotEqual(make([]byte, len(entry.plainTextKey)), entry.plainTextKey)
+}
+
+func TestKeyCacheEviction(t *testing.T) {
+	assert := assert.New(t)
+
+	uut := &cryptoEngine{
+		keyCacheLock: &sync.RWMutex{},
+		encKeys:      newKeyCache(2, 0),
+	}
+
+	testKeys := []models.EncryptionKey{}
+	storedKeys := [][]byte{}
+	for idx := 0; idx < 3; idx++ {
+		testKeys = append(testKeys, models.EncryptionKey{
+			ID: uuid.NewString(), State: models.EncryptionKeyStateActive,
+		})
+	}
+	for _, testKey := range testKeys[:2] {
+		uut.writeKeyToCache(testKey, []byte(uuid.NewString()))
+		storedKeys = append(
+			storedKeys,
+			uut.encKeys.entries[testKey.ID].Value.(*keyCacheSlot).entry.plainTextKey,
+		)
+	}
+
+	// Reading key 0 makes key 1 the least recently used
+	_, ok := uut.getCachedKey(testKeys[0].ID)
+	assert.True(ok)
+	uut.writeKeyToCache(testKeys[2], []byte(uuid.NewString()))
+	assert.Equal(2, uut.encKeys.len())
+	_, ok = uut.getCachedKey(testKeys[1].ID)
+	assert.False(ok)
+	assert.Equal(make([]byte, len(storedKeys[1])), storedKeys[1])
+	assert.NotEqual(make([]byte, len(storedKeys[0])), storedKeys[0])
+	assert.Equal(uint64(1), uut.CacheStats().Evictions)
+
+	// Key 0 is now the least recently used
+	uut.writeKeyToCache(testKeys[1], []byte(uuid.NewString()))
+	_, ok = uut.getCachedKey(testKeys[0].ID)
+	assert.False(ok)
+	assert.Equal(make([]byte, len(storedKeys[0])), storedKeys[0])
+	_, ok = uut.getCachedKey(testKeys[2].ID)
+	assert.True(ok)
+	assert.Equal(uint64(2), uut.CacheStats().Evictions)
+}
+
+func TestKeyCacheTTL(t *testing.T) {
+	assert := assert.New(t)
+
+	uut := &cryptoEngine{
+		keyCacheLock: &sync.RWMutex{},
+		encKeys:      newKeyCache(0, 20*time.Millisecond),
+	}
+
+	testKey := models.EncryptionKey{ID: uuid.NewString(), State: models.EncryptionKeyStateActive}
+	uut.writeKeyToCache(testKey, []byte(uuid.NewString()))
+	stored := uut.encKeys.entries[testKey.ID].Value.(*keyCacheSlot).entry.plainTextKey
+
+	_, ok := uut.getCachedKey(testKey.ID)
+	assert.True(ok)
+
+	// Reading the key does not extend its TTL
+	time.Sleep(40 * time.Millisecond)
+	_, ok = uut.getCachedKey(testKey.ID)
+	assert.False(ok)
+	assert.Equal(make([]byte, len(stored)), stored)
+	assert.Equal(0, uut.encKeys.len())
+	assert.Equal(uint64(1), uut.CacheStats().Evictions)
+}
+
+func TestCryptoEngineNewEncryptionKeyShortRNGRead(t *testing.T) {
+	assert := assert.New(t)
+
+	utCtx := context.Background()
+
+	testCertFile, err := filepath.Abs("../test/ut_rsa.crt")
+	assert.Nil(err)
+	testKeyFile, err := filepath.Abs("../test/ut_rsa.key")
+	assert.Nil(err)
+
+	// No key may be recorded, so the database expects no calls
+	mockDBClient := mockdb.NewClient(t)
+	mockDatabase := mockdb.NewDatabase(t)
+
+	engine, err := NewCryptographyEngine(utCtx, CryptographyEngineParams{
+		Persistence:        mockDBClient,
+		PrimaryRSACertFile: testCertFile,
+		PrimaryRSAKeyFile:  testKeyFile,
+	})
+	assert.Nil(err)
+	uut, ok := engine.(*cryptoEngine)
+	assert.True(ok)
+
+	aead, err := uut.crypto.GetAEAD(utCtx, cgoCrypto.AEADTypeEnum(uut.aeadType))
+	assert.Nil(err)
+	keyLen := aead.ExpectedKeyLen()
+
+	// The RNG returns fewer bytes than the key needs
+	uut.crypto = &fixedRNGCrypto{Engine: uut.crypto, rng: shortReadRNG{limit: keyLen - 1}}
+	_, err = uut.NewEncryptionKey(utCtx, mockDatabase)
+	assert.ErrorContains(err, "did not get")
+
+	// The RNG fails
+	rngErr := errors.New("rng failure")
+	uut.crypto = &fixedRNGCrypto{
+		Engine: uut.crypto, rng: shortReadRNG{limit: keyLen, err: rngErr},
+	}
+	_, err = uut.NewEncryptionKey(utCtx, mockDatabase)
+	assert.ErrorIs(err, rngErr)
+
+	assert.Equal(0, uut.CacheStats().CachedKeyCount)
+}
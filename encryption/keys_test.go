@@ -2,7 +2,12 @@ package encryption_test
 
 import (
 	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
 	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/alwitt/haven/db"
@@ -47,15 +52,21 @@ func TestCryptoEngineNewKey(t *testing.T) {
 		"RecordEncryptionKey",
 		mock.AnythingOfType("context.backgroundCtx"),
 		mock.AnythingOfType("[]uint8"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("models.AEADTypeENUMType"),
 	).Run(func(args mock.Arguments) {
 		encKey, ok := args.Get(1).([]byte)
 		assert.True(ok)
 		testKey1.EncKeyMaterial = encKey
+		testKey1.MaterialFingerprint = args.String(3)
 	}).Return(testKey1, nil).Once()
 	// Record "new" key
 	newKey, err := uut1.NewEncryptionKey(utCtx, mockDatabase)
 	assert.Nil(err)
 	assert.Equal(testKey1.ID, newKey.ID)
+	// The fingerprint is the hex SHA256 digest of the plain text key material
+	assert.Len(testKey1.MaterialFingerprint, 64)
 
 	// Read test key 1 back using different instance
 	uut2, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
@@ -106,6 +117,9 @@ func TestCryptoEngineListKeys(t *testing.T) {
 		"RecordEncryptionKey",
 		mock.AnythingOfType("context.backgroundCtx"),
 		mock.AnythingOfType("[]uint8"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("models.AEADTypeENUMType"),
 	).Run(func(args mock.Arguments) {
 		encKey, ok := args.Get(1).([]byte)
 		assert.True(ok)
@@ -126,6 +140,9 @@ func TestCryptoEngineListKeys(t *testing.T) {
 		"RecordEncryptionKey",
 		mock.AnythingOfType("context.backgroundCtx"),
 		mock.AnythingOfType("[]uint8"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("models.AEADTypeENUMType"),
 	).Run(func(args mock.Arguments) {
 		encKey, ok := args.Get(1).([]byte)
 		assert.True(ok)
@@ -181,6 +198,9 @@ func TestCryptoEngineChangeKeyState(t *testing.T) {
 		"RecordEncryptionKey",
 		mock.AnythingOfType("context.backgroundCtx"),
 		mock.AnythingOfType("[]uint8"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("models.AEADTypeENUMType"),
 	).Run(func(args mock.Arguments) {
 		encKey, ok := args.Get(1).([]byte)
 		assert.True(ok)
@@ -263,6 +283,9 @@ func TestCryptoEngineDeleteKey(t *testing.T) {
 		"RecordEncryptionKey",
 		mock.AnythingOfType("context.backgroundCtx"),
 		mock.AnythingOfType("[]uint8"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("models.AEADTypeENUMType"),
 	).Run(func(args mock.Arguments) {
 		encKey, ok := args.Get(1).([]byte)
 		assert.True(ok)
@@ -273,6 +296,13 @@ func TestCryptoEngineDeleteKey(t *testing.T) {
 	assert.Nil(err)
 	assert.Equal(testKey1.ID, newKey.ID)
 
+	// The new key is cached with its decrypted key material
+	stats := uut1.CacheStats()
+	assert.Equal(1, stats.CachedKeyCount)
+	assert.Equal(
+		[]encryption.CachedKeyState{{KeyID: testKey1.ID, HasKeyMaterial: true}}, stats.Keys,
+	)
+
 	// Delete key
 	mockDatabase.On(
 		"DeleteEncryptionKey",
@@ -280,4 +310,1020 @@ func TestCryptoEngineDeleteKey(t *testing.T) {
 		testKey1.ID,
 	).Return(nil).Once()
 	assert.Nil(uut1.DeleteEncryptionKey(utCtx, testKey1.ID, mockDatabase))
+
+	// The deleted key is no longer cached
+	stats = uut1.CacheStats()
+	assert.Equal(0, stats.CachedKeyCount)
+	assert.Empty(stats.Keys)
+}
+
+func TestCryptoEngineNewKeyRSAKeyTooSmall(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// A 1024 bit RSA key can not wrap a 32 byte symmetric key with RSA OAEP SHA512
+	testCertFile, err := filepath.Abs("../test/ut_rsa_small.crt")
+	assert.Nil(err)
+	testKeyFile, err := filepath.Abs("../test/ut_rsa_small.key")
+	assert.Nil(err)
+
+	mockDBClient := mockdb.NewClient(t)
+	mockDatabase := mockdb.NewDatabase(t)
+
+	uut, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+		Persistence:        mockDBClient,
+		PrimaryRSACertFile: testCertFile,
+		PrimaryRSAKeyFile:  testKeyFile,
+	})
+	assert.Nil(err)
+
+	// The key is refused before it is recorded
+	_, err = uut.NewEncryptionKey(utCtx, mockDatabase)
+	assert.ErrorIs(err, encryption.ErrKeyTooLargeToWrap)
+	assert.ErrorContains(err, "use a RSA key of at least 1296 bits")
+}
+
+func TestCryptoEngineSecondaryRSAKey(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// RSA cert files
+	testCertFileA, err := filepath.Abs("../test/ut_rsa.crt")
+	assert.Nil(err)
+	testKeyFileA, err := filepath.Abs("../test/ut_rsa.key")
+	assert.Nil(err)
+	testCertFileB, err := filepath.Abs("../test/ut_rsa_2.crt")
+	assert.Nil(err)
+	testKeyFileB, err := filepath.Abs("../test/ut_rsa_2.key")
+	assert.Nil(err)
+
+	mockDBClient := mockdb.NewClient(t)
+	mockDatabase := mockdb.NewDatabase(t)
+
+	// Wrap a key with RSA key pair A
+	uutA, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+		Persistence:        mockDBClient,
+		PrimaryRSACertFile: testCertFileA,
+		PrimaryRSAKeyFile:  testKeyFileA,
+	})
+	assert.Nil(err)
+
+	testKey1 := models.EncryptionKey{
+		ID:    uuid.NewString(),
+		State: models.EncryptionKeyStateActive,
+	}
+	mockDatabase.On(
+		"RecordEncryptionKey",
+		mock.AnythingOfType("context.backgroundCtx"),
+		mock.AnythingOfType("[]uint8"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("models.AEADTypeENUMType"),
+	).Run(func(args mock.Arguments) {
+		encKey, ok := args.Get(1).([]byte)
+		assert.True(ok)
+		testKey1.EncKeyMaterial = encKey
+	}).Return(testKey1, nil).Once()
+	_, err = uutA.NewEncryptionKey(utCtx, mockDatabase)
+	assert.Nil(err)
+
+	mockDatabase.On(
+		"GetEncryptionKey",
+		mock.Anything,
+		testKey1.ID,
+	).Return(testKey1, nil)
+
+	// Case 0: RSA key pair B only can not unwrap the key
+	{
+		uut, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+			Persistence:        mockDBClient,
+			PrimaryRSACertFile: testCertFileB,
+			PrimaryRSAKeyFile:  testKeyFileB,
+		})
+		assert.Nil(err)
+		_, err = uut.GetEncryptionKey(utCtx, testKey1.ID, mockDatabase)
+		assert.Error(err)
+	}
+
+	// Case 1: RSA key pair B, with A as secondary, can unwrap the key
+	{
+		uut, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+			Persistence:          mockDBClient,
+			PrimaryRSACertFile:   testCertFileB,
+			PrimaryRSAKeyFile:    testKeyFileB,
+			SecondaryRSAKeyFiles: []string{testKeyFileA},
+		})
+		assert.Nil(err)
+		readKey, err := uut.GetEncryptionKey(utCtx, testKey1.ID, mockDatabase)
+		assert.Nil(err)
+		assert.Equal(testKey1.ID, readKey.ID)
+
+		// The unwrapped key is usable
+		plainText := []byte(uuid.NewString())
+		_, cipherText, err := uutA.EncryptData(utCtx, testKey1.ID, plainText, nil, nil, mockDatabase)
+		assert.Nil(err)
+		_, decrypted, err := uut.DecryptData(utCtx, testKey1.ID, cipherText, mockDatabase)
+		assert.Nil(err)
+		assert.Equal(plainText, decrypted)
+
+		// New keys are wrapped with the primary RSA key pair B
+		testKey2 := models.EncryptionKey{
+			ID:    uuid.NewString(),
+			State: models.EncryptionKeyStateActive,
+		}
+		mockDatabase.On(
+			"RecordEncryptionKey",
+			mock.AnythingOfType("context.backgroundCtx"),
+			mock.AnythingOfType("[]uint8"),
+			mock.AnythingOfType("string"),
+			mock.AnythingOfType("string"),
+			mock.AnythingOfType("models.AEADTypeENUMType"),
+		).Run(func(args mock.Arguments) {
+			encKey, ok := args.Get(1).([]byte)
+			assert.True(ok)
+			testKey2.EncKeyMaterial = encKey
+		}).Return(testKey2, nil).Once()
+		_, err = uut.NewEncryptionKey(utCtx, mockDatabase)
+		assert.Nil(err)
+		mockDatabase.On(
+			"GetEncryptionKey",
+			mock.Anything,
+			testKey2.ID,
+		).Return(testKey2, nil).Once()
+		_, err = uutA.GetEncryptionKey(utCtx, testKey2.ID, mockDatabase)
+		assert.Error(err)
+	}
+
+	// Case 2: secondary RSA key file must exist
+	{
+		_, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+			Persistence:          mockDBClient,
+			PrimaryRSACertFile:   testCertFileB,
+			PrimaryRSAKeyFile:    testKeyFileB,
+			SecondaryRSAKeyFiles: []string{"/tmp/" + uuid.NewString()},
+		})
+		assert.Error(err)
+	}
+}
+
+func TestCryptoEngineRewrapKeys(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// RSA cert files
+	testCertFileA, err := filepath.Abs("../test/ut_rsa.crt")
+	assert.Nil(err)
+	testKeyFileA, err := filepath.Abs("../test/ut_rsa.key")
+	assert.Nil(err)
+	testCertFileB, err := filepath.Abs("../test/ut_rsa_2.crt")
+	assert.Nil(err)
+	testKeyFileB, err := filepath.Abs("../test/ut_rsa_2.key")
+	assert.Nil(err)
+
+	mockDBClient := mockdb.NewClient(t)
+	mockDatabase := mockdb.NewDatabase(t)
+
+	// Wrap a key with RSA key pair A
+	uutA, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+		Persistence:        mockDBClient,
+		PrimaryRSACertFile: testCertFileA,
+		PrimaryRSAKeyFile:  testKeyFileA,
+	})
+	assert.Nil(err)
+
+	testKey1 := models.EncryptionKey{
+		ID:    uuid.NewString(),
+		State: models.EncryptionKeyStateActive,
+	}
+	mockDatabase.On(
+		"RecordEncryptionKey",
+		mock.AnythingOfType("context.backgroundCtx"),
+		mock.AnythingOfType("[]uint8"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("models.AEADTypeENUMType"),
+	).Run(func(args mock.Arguments) {
+		encKey, ok := args.Get(1).([]byte)
+		assert.True(ok)
+		testKey1.EncKeyMaterial = encKey
+		testKey1.RSAFingerprint = args.String(2)
+	}).Return(testKey1, nil).Once()
+	_, err = uutA.NewEncryptionKey(utCtx, mockDatabase)
+	assert.Nil(err)
+
+	// Rotate to RSA key pair B
+	uutB, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+		Persistence:          mockDBClient,
+		PrimaryRSACertFile:   testCertFileB,
+		PrimaryRSAKeyFile:    testKeyFileB,
+		SecondaryRSAKeyFiles: []string{testKeyFileA},
+	})
+	assert.Nil(err)
+
+	activeKeyFilter := db.EncryptionKeyQueryFilter{
+		TargetState: []models.EncryptionKeyStateENUMType{models.EncryptionKeyStateActive},
+	}
+
+	// Re-encrypt the key with RSA key pair B
+	rewrappedKey1 := testKey1
+	mockDatabase.On(
+		"ListEncryptionKeys", mock.AnythingOfType("context.backgroundCtx"), activeKeyFilter,
+	).Return([]models.EncryptionKey{testKey1}, nil).Once()
+	mockDatabase.On(
+		"UpdateEncryptionKeyMaterial",
+		mock.AnythingOfType("context.backgroundCtx"),
+		testKey1.ID,
+		mock.AnythingOfType("[]uint8"),
+		mock.AnythingOfType("string"),
+	).Run(func(args mock.Arguments) {
+		encKey, ok := args.Get(2).([]byte)
+		assert.True(ok)
+		rewrappedKey1.EncKeyMaterial = encKey
+		rewrappedKey1.RSAFingerprint = args.String(3)
+	}).Return(nil).Once()
+	rewrapped, err := uutB.RewrapEncryptionKeys(utCtx, mockDatabase)
+	assert.Nil(err)
+	assert.Equal(1, rewrapped)
+	assert.NotEqual(testKey1.EncKeyMaterial, rewrappedKey1.EncKeyMaterial)
+	assert.NotEqual(testKey1.RSAFingerprint, rewrappedKey1.RSAFingerprint)
+
+	// Repeat is a NOOP
+	mockDatabase.On(
+		"ListEncryptionKeys", mock.AnythingOfType("context.backgroundCtx"), activeKeyFilter,
+	).Return([]models.EncryptionKey{rewrappedKey1}, nil).Once()
+	rewrapped, err = uutB.RewrapEncryptionKeys(utCtx, mockDatabase)
+	assert.Nil(err)
+	assert.Equal(0, rewrapped)
+
+	// RSA key pair B alone can now unwrap the key
+	uutBOnly, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+		Persistence:        mockDBClient,
+		PrimaryRSACertFile: testCertFileB,
+		PrimaryRSAKeyFile:  testKeyFileB,
+	})
+	assert.Nil(err)
+	mockDatabase.On(
+		"GetEncryptionKey", mock.AnythingOfType("context.backgroundCtx"), testKey1.ID,
+	).Return(rewrappedKey1, nil).Once()
+	_, err = uutBOnly.GetEncryptionKey(utCtx, testKey1.ID, mockDatabase)
+	assert.Nil(err)
+}
+
+func TestCryptoEngineListKeysNeedsRewrap(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// RSA cert files
+	testCertFileA, err := filepath.Abs("../test/ut_rsa.crt")
+	assert.Nil(err)
+	testKeyFileA, err := filepath.Abs("../test/ut_rsa.key")
+	assert.Nil(err)
+	testCertFileB, err := filepath.Abs("../test/ut_rsa_2.crt")
+	assert.Nil(err)
+	testKeyFileB, err := filepath.Abs("../test/ut_rsa_2.key")
+	assert.Nil(err)
+
+	mockDBClient := mockdb.NewClient(t)
+	mockDatabase := mockdb.NewDatabase(t)
+
+	uutA, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+		Persistence:        mockDBClient,
+		PrimaryRSACertFile: testCertFileA,
+		PrimaryRSAKeyFile:  testKeyFileA,
+	})
+	assert.Nil(err)
+	uutB, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+		Persistence:          mockDBClient,
+		PrimaryRSACertFile:   testCertFileB,
+		PrimaryRSAKeyFile:    testKeyFileB,
+		SecondaryRSAKeyFiles: []string{testKeyFileA},
+	})
+	assert.Nil(err)
+
+	// Key 1 wrapped with RSA key pair A, key 2 wrapped with RSA key pair B
+	testKeys := []models.EncryptionKey{}
+	for _, uut := range []encryption.CryptographyEngine{uutA, uutB} {
+		testKey := models.EncryptionKey{
+			ID:    uuid.NewString(),
+			State: models.EncryptionKeyStateActive,
+		}
+		mockDatabase.On(
+			"RecordEncryptionKey",
+			mock.AnythingOfType("context.backgroundCtx"),
+			mock.AnythingOfType("[]uint8"),
+			mock.AnythingOfType("string"),
+			mock.AnythingOfType("string"),
+			mock.AnythingOfType("models.AEADTypeENUMType"),
+		).Run(func(args mock.Arguments) {
+			encKey, ok := args.Get(1).([]byte)
+			assert.True(ok)
+			testKey.EncKeyMaterial = encKey
+			testKey.RSAFingerprint = args.String(2)
+		}).Return(testKey, nil).Once()
+		_, err = uut.NewEncryptionKey(utCtx, mockDatabase)
+		assert.Nil(err)
+		testKeys = append(testKeys, testKey)
+	}
+
+	// Only the key wrapped with the secondary RSA key needs rewrapping
+	mockDatabase.On(
+		"ListEncryptionKeys",
+		mock.AnythingOfType("context.backgroundCtx"),
+		mock.AnythingOfType("db.EncryptionKeyQueryFilter"),
+	).Return(testKeys, nil).Once()
+	knownKeys, err := uutB.ListEncryptionKeys(utCtx, db.EncryptionKeyQueryFilter{}, mockDatabase)
+	assert.Nil(err)
+	assert.Len(knownKeys, 2)
+	assert.Equal(testKeys[0].ID, knownKeys[0].ID)
+	assert.True(knownKeys[0].NeedsRewrap)
+	assert.Equal(testKeys[1].ID, knownKeys[1].ID)
+	assert.False(knownKeys[1].NeedsRewrap)
+}
+
+func TestCryptoEngineShutdown(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// RSA cert files
+	testCertFile, err := filepath.Abs("../test/ut_rsa.crt")
+	assert.Nil(err)
+	testKeyFile, err := filepath.Abs("../test/ut_rsa.key")
+	assert.Nil(err)
+
+	mockDBClient := mockdb.NewClient(t)
+	mockDatabase := mockdb.NewDatabase(t)
+
+	uut, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+		Persistence:        mockDBClient,
+		PrimaryRSACertFile: testCertFile,
+		PrimaryRSAKeyFile:  testKeyFile,
+	})
+	assert.Nil(err)
+
+	// Define test key; it is cached on creation
+	testKey1 := models.EncryptionKey{
+		ID:    uuid.NewString(),
+		State: models.EncryptionKeyStateActive,
+	}
+	mockDatabase.On(
+		"RecordEncryptionKey",
+		mock.AnythingOfType("context.backgroundCtx"),
+		mock.AnythingOfType("[]uint8"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("models.AEADTypeENUMType"),
+	).Run(func(args mock.Arguments) {
+		encKey, ok := args.Get(1).([]byte)
+		assert.True(ok)
+		testKey1.EncKeyMaterial = encKey
+	}).Return(testKey1, nil).Once()
+	_, err = uut.NewEncryptionKey(utCtx, mockDatabase)
+	assert.Nil(err)
+
+	plainText := []byte(uuid.NewString())
+	mockDatabase.On(
+		"GetEncryptionKey",
+		mock.Anything,
+		testKey1.ID,
+	).Return(testKey1, nil).Times(2)
+	_, cipherText, err := uut.EncryptData(utCtx, testKey1.ID, plainText, nil, nil, mockDatabase)
+	assert.Nil(err)
+
+	uut.Shutdown()
+
+	// The key is recovered from its persisted material after shutdown
+	_, decrypted, err := uut.DecryptData(utCtx, testKey1.ID, cipherText, mockDatabase)
+	assert.Nil(err)
+	assert.Equal(plainText, decrypted)
+}
+
+func TestCryptoEngineCacheStats(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// RSA cert files
+	testCertFile, err := filepath.Abs("../test/ut_rsa.crt")
+	assert.Nil(err)
+	testKeyFile, err := filepath.Abs("../test/ut_rsa.key")
+	assert.Nil(err)
+
+	mockDBClient := mockdb.NewClient(t)
+	mockDatabase := mockdb.NewDatabase(t)
+
+	uut1, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+		Persistence:        mockDBClient,
+		PrimaryRSACertFile: testCertFile,
+		PrimaryRSAKeyFile:  testKeyFile,
+	})
+	assert.Nil(err)
+
+	// Define two test keys; creating them does not count as a lookup
+	testKeys := []models.EncryptionKey{}
+	for range 2 {
+		testKey := models.EncryptionKey{
+			ID:    uuid.NewString(),
+			State: models.EncryptionKeyStateActive,
+		}
+		mockDatabase.On(
+			"RecordEncryptionKey",
+			mock.AnythingOfType("context.backgroundCtx"),
+			mock.AnythingOfType("[]uint8"),
+			mock.AnythingOfType("string"),
+			mock.AnythingOfType("string"),
+			mock.AnythingOfType("models.AEADTypeENUMType"),
+		).Run(func(args mock.Arguments) {
+			encKey, ok := args.Get(1).([]byte)
+			assert.True(ok)
+			testKey.EncKeyMaterial = encKey
+		}).Return(testKey, nil).Once()
+		_, err = uut1.NewEncryptionKey(utCtx, mockDatabase)
+		assert.Nil(err)
+		testKeys = append(testKeys, testKey)
+	}
+	stats := uut1.CacheStats()
+	assert.Equal(2, stats.CachedKeyCount)
+	assert.Zero(stats.Hits)
+	assert.Zero(stats.Misses)
+
+	// Read the keys using a different instance, which starts with an empty cache
+	uut2, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+		Persistence:        mockDBClient,
+		PrimaryRSACertFile: testCertFile,
+		PrimaryRSAKeyFile:  testKeyFile,
+	})
+	assert.Nil(err)
+	assert.Equal(encryption.KeyCacheStats{Keys: []encryption.CachedKeyState{}}, uut2.CacheStats())
+
+	mockDatabase.On(
+		"GetEncryptionKey",
+		mock.AnythingOfType("context.backgroundCtx"),
+		testKeys[0].ID,
+	).Return(testKeys[0], nil).Times(3)
+	mockDatabase.On(
+		"GetEncryptionKey",
+		mock.AnythingOfType("context.backgroundCtx"),
+		testKeys[1].ID,
+	).Return(testKeys[1], nil).Once()
+
+	// Key 1 misses once, then hits; key 2 misses once
+	for range 3 {
+		_, err = uut2.GetEncryptionKey(utCtx, testKeys[0].ID, mockDatabase)
+		assert.Nil(err)
+	}
+	_, err = uut2.GetEncryptionKey(utCtx, testKeys[1].ID, mockDatabase)
+	assert.Nil(err)
+	stats = uut2.CacheStats()
+	assert.Equal(2, stats.CachedKeyCount)
+	assert.Equal(uint64(2), stats.Hits)
+	assert.Equal(uint64(2), stats.Misses)
+
+	// Dropping the cache keeps the counters
+	uut2.Shutdown()
+	stats = uut2.CacheStats()
+	assert.Equal(0, stats.CachedKeyCount)
+	assert.Equal(uint64(2), stats.Hits)
+	assert.Equal(uint64(2), stats.Misses)
+}
+
+func TestCryptoEngineStrictKeyConsistency(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// RSA cert files
+	testCertFile, err := filepath.Abs("../test/ut_rsa.crt")
+	assert.Nil(err)
+	testKeyFile, err := filepath.Abs("../test/ut_rsa.key")
+	assert.Nil(err)
+
+	mockDBClient := mockdb.NewClient(t)
+	mockDatabase := mockdb.NewDatabase(t)
+
+	for _, strict := range []bool{false, true} {
+		uut, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+			Persistence:          mockDBClient,
+			PrimaryRSACertFile:   testCertFile,
+			PrimaryRSAKeyFile:    testKeyFile,
+			StrictKeyConsistency: strict,
+		})
+		assert.Nil(err)
+
+		// Define test key; it is cached on creation
+		testKey := models.EncryptionKey{
+			ID:    uuid.NewString(),
+			State: models.EncryptionKeyStateActive,
+		}
+		mockDatabase.On(
+			"RecordEncryptionKey",
+			mock.AnythingOfType("context.backgroundCtx"),
+			mock.AnythingOfType("[]uint8"),
+			mock.AnythingOfType("string"),
+			mock.AnythingOfType("string"),
+			mock.AnythingOfType("models.AEADTypeENUMType"),
+		).Return(testKey, nil).Once()
+		_, err = uut.NewEncryptionKey(utCtx, mockDatabase)
+		assert.Nil(err)
+		assert.Equal(1, uut.CacheStats().CachedKeyCount)
+
+		// The key is deleted from the database by another engine
+		mockDatabase.On(
+			"GetEncryptionKey", mock.Anything, testKey.ID,
+		).Return(models.EncryptionKey{}, db.ErrEncryptionKeyNotFound).Twice()
+		_, _, err = uut.EncryptData(utCtx, testKey.ID, []byte(uuid.NewString()), nil, nil, mockDatabase)
+		if strict {
+			assert.ErrorIs(err, encryption.ErrKeyRevoked)
+			assert.Equal(0, uut.CacheStats().CachedKeyCount)
+		} else {
+			assert.ErrorIs(err, db.ErrEncryptionKeyNotFound)
+			assert.Equal(1, uut.CacheStats().CachedKeyCount)
+		}
+
+		// The key is only reported revoked once
+		_, _, err = uut.EncryptData(utCtx, testKey.ID, []byte(uuid.NewString()), nil, nil, mockDatabase)
+		assert.ErrorIs(err, db.ErrEncryptionKeyNotFound)
+		assert.NotErrorIs(err, encryption.ErrKeyRevoked)
+	}
+}
+
+func TestCryptoEngineSummarizeKeys(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// RSA cert files
+	testCertFile, err := filepath.Abs("../test/ut_rsa.crt")
+	assert.Nil(err)
+	testKeyFile, err := filepath.Abs("../test/ut_rsa.key")
+	assert.Nil(err)
+
+	mockDBClient := mockdb.NewClient(t)
+	mockDatabase := mockdb.NewDatabase(t)
+
+	uut, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+		Persistence:        mockDBClient,
+		PrimaryRSACertFile: testCertFile,
+		PrimaryRSAKeyFile:  testKeyFile,
+	})
+	assert.Nil(err)
+
+	testKey1 := models.EncryptionKey{
+		ID:             uuid.NewString(),
+		EncKeyMaterial: []byte(uuid.NewString()),
+		State:          models.EncryptionKeyStateActive,
+	}
+	testKey2 := models.EncryptionKey{
+		ID:             uuid.NewString(),
+		EncKeyMaterial: []byte(uuid.NewString()),
+		State:          models.EncryptionKeyStateInactive,
+	}
+	testCounts := map[models.EncryptionKeyStateENUMType]int64{
+		models.EncryptionKeyStateActive:   1,
+		models.EncryptionKeyStateInactive: 1,
+	}
+
+	mockDatabase.On(
+		"CountEncryptionKeysByState", mock.AnythingOfType("context.backgroundCtx"),
+	).Return(testCounts, nil).Once()
+	mockDatabase.On(
+		"ListEncryptionKeys",
+		mock.AnythingOfType("context.backgroundCtx"),
+		mock.MatchedBy(func(filter db.EncryptionKeyQueryFilter) bool {
+			return filter.Limit != nil && len(filter.TargetState) == 0
+		}),
+	).Return([]models.EncryptionKey{testKey1, testKey2}, nil).Once()
+
+	summary, err := uut.SummarizeKeys(utCtx, mockDatabase)
+	assert.Nil(err)
+	assert.Equal(testCounts, summary.Counts)
+	assert.False(summary.Truncated)
+	assert.Len(summary.Keys, 2)
+	assert.Equal(testKey1.ID, summary.Keys[0].ID)
+	assert.Equal(testKey2.ID, summary.Keys[1].ID)
+	for _, key := range summary.Keys {
+		assert.Empty(key.EncKeyMaterial)
+	}
+}
+
+func TestCryptoEngineRSAFingerprintMismatch(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// RSA cert files
+	testCertFileA, err := filepath.Abs("../test/ut_rsa.crt")
+	assert.Nil(err)
+	testKeyFileA, err := filepath.Abs("../test/ut_rsa.key")
+	assert.Nil(err)
+	testCertFileB, err := filepath.Abs("../test/ut_rsa_2.crt")
+	assert.Nil(err)
+	testKeyFileB, err := filepath.Abs("../test/ut_rsa_2.key")
+	assert.Nil(err)
+
+	mockDBClient := mockdb.NewClient(t)
+	mockDatabase := mockdb.NewDatabase(t)
+
+	// Wrap a key with RSA key pair A
+	uutA, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+		Persistence:        mockDBClient,
+		PrimaryRSACertFile: testCertFileA,
+		PrimaryRSAKeyFile:  testKeyFileA,
+	})
+	assert.Nil(err)
+
+	testKey1 := models.EncryptionKey{
+		ID:    uuid.NewString(),
+		State: models.EncryptionKeyStateActive,
+	}
+	mockDatabase.On(
+		"RecordEncryptionKey",
+		mock.AnythingOfType("context.backgroundCtx"),
+		mock.AnythingOfType("[]uint8"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("models.AEADTypeENUMType"),
+	).Run(func(args mock.Arguments) {
+		encKey, ok := args.Get(1).([]byte)
+		assert.True(ok)
+		testKey1.EncKeyMaterial = encKey
+		testKey1.RSAFingerprint = args.String(2)
+	}).Return(testKey1, nil).Once()
+	_, err = uutA.NewEncryptionKey(utCtx, mockDatabase)
+	assert.Nil(err)
+	assert.NotEmpty(testKey1.RSAFingerprint)
+
+	// Unwrap with RSA key pair B
+	uutB, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+		Persistence:        mockDBClient,
+		PrimaryRSACertFile: testCertFileB,
+		PrimaryRSAKeyFile:  testKeyFileB,
+	})
+	assert.Nil(err)
+	mockDatabase.On(
+		"GetEncryptionKey", mock.AnythingOfType("context.backgroundCtx"), testKey1.ID,
+	).Return(testKey1, nil).Once()
+	_, err = uutB.GetEncryptionKey(utCtx, testKey1.ID, mockDatabase)
+	assert.ErrorIs(err, encryption.ErrRSAKeyFingerprintMismatch)
+}
+
+func TestCryptoEngineReloadRSAKeyPair(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.InfoLevel)
+
+	utCtx := context.Background()
+
+	// RSA cert files
+	testCertFileA, err := filepath.Abs("../test/ut_rsa.crt")
+	assert.Nil(err)
+	testKeyFileA, err := filepath.Abs("../test/ut_rsa.key")
+	assert.Nil(err)
+	testCertFileB, err := filepath.Abs("../test/ut_rsa_2.crt")
+	assert.Nil(err)
+	testKeyFileB, err := filepath.Abs("../test/ut_rsa_2.key")
+	assert.Nil(err)
+
+	mockDBClient := mockdb.NewClient(t)
+	mockDatabase := mockdb.NewDatabase(t)
+
+	uut, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+		Persistence:        mockDBClient,
+		PrimaryRSACertFile: testCertFileA,
+		PrimaryRSAKeyFile:  testKeyFileA,
+	})
+	assert.Nil(err)
+
+	// Define a key wrapped with RSA key pair A
+	testKey1 := models.EncryptionKey{
+		ID:    uuid.NewString(),
+		State: models.EncryptionKeyStateActive,
+	}
+	mockDatabase.On(
+		"RecordEncryptionKey",
+		mock.AnythingOfType("context.backgroundCtx"),
+		mock.AnythingOfType("[]uint8"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("models.AEADTypeENUMType"),
+	).Run(func(args mock.Arguments) {
+		encKey, ok := args.Get(1).([]byte)
+		assert.True(ok)
+		testKey1.EncKeyMaterial = encKey
+		testKey1.RSAFingerprint = args.String(2)
+	}).Return(testKey1, nil).Once()
+	_, err = uut.NewEncryptionKey(utCtx, mockDatabase)
+	assert.Nil(err)
+	mockDatabase.On(
+		"GetEncryptionKey", mock.Anything, testKey1.ID,
+	).Return(testKey1, nil).Maybe()
+
+	// Encrypt and decrypt while the RSA key pair is swapped
+	wg := sync.WaitGroup{}
+	for worker := 0; worker < 4; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for itr := 0; itr < 20; itr++ {
+				plainText := []byte(uuid.NewString())
+				_, cipherText, err := uut.EncryptData(utCtx, testKey1.ID, plainText, nil, nil, mockDatabase)
+				assert.Nil(err)
+				// Force the key to be unwrapped again
+				uut.Shutdown()
+				_, decrypted, err := uut.DecryptData(utCtx, testKey1.ID, cipherText, mockDatabase)
+				assert.Nil(err)
+				assert.Equal(plainText, decrypted)
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for itr := 0; itr < 5; itr++ {
+			assert.Nil(uut.ReloadRSAKeyPair(utCtx, testCertFileB, testKeyFileB))
+			assert.Nil(uut.ReloadRSAKeyPair(utCtx, testCertFileA, testKeyFileA))
+		}
+		assert.Nil(uut.ReloadRSAKeyPair(utCtx, testCertFileB, testKeyFileB))
+	}()
+	wg.Wait()
+
+	// New keys are wrapped with RSA key pair B
+	testKey2 := models.EncryptionKey{
+		ID:    uuid.NewString(),
+		State: models.EncryptionKeyStateActive,
+	}
+	mockDatabase.On(
+		"RecordEncryptionKey",
+		mock.AnythingOfType("context.backgroundCtx"),
+		mock.AnythingOfType("[]uint8"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("models.AEADTypeENUMType"),
+	).Run(func(args mock.Arguments) {
+		encKey, ok := args.Get(1).([]byte)
+		assert.True(ok)
+		testKey2.EncKeyMaterial = encKey
+		testKey2.RSAFingerprint = args.String(2)
+	}).Return(testKey2, nil).Once()
+	_, err = uut.NewEncryptionKey(utCtx, mockDatabase)
+	assert.Nil(err)
+
+	uutB, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+		Persistence:        mockDBClient,
+		PrimaryRSACertFile: testCertFileB,
+		PrimaryRSAKeyFile:  testKeyFileB,
+	})
+	assert.Nil(err)
+	mockDatabase.On(
+		"GetEncryptionKey", mock.Anything, testKey2.ID,
+	).Return(testKey2, nil).Once()
+	_, err = uutB.GetEncryptionKey(utCtx, testKey2.ID, mockDatabase)
+	assert.Nil(err)
+
+	// Invalid key files
+	assert.Error(uut.ReloadRSAKeyPair(utCtx, testCertFileA, testCertFileA))
+}
+
+func TestCryptoEngineConcurrentKeyOperations(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.InfoLevel)
+
+	utCtx := context.Background()
+
+	// RSA cert files
+	testCertFileA, err := filepath.Abs("../test/ut_rsa.crt")
+	assert.Nil(err)
+	testKeyFileA, err := filepath.Abs("../test/ut_rsa.key")
+	assert.Nil(err)
+	testCertFileB, err := filepath.Abs("../test/ut_rsa_2.crt")
+	assert.Nil(err)
+	testKeyFileB, err := filepath.Abs("../test/ut_rsa_2.key")
+	assert.Nil(err)
+
+	mockDBClient := mockdb.NewClient(t)
+	mockDatabase := mockdb.NewDatabase(t)
+
+	uut, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+		Persistence:        mockDBClient,
+		PrimaryRSACertFile: testCertFileA,
+		PrimaryRSAKeyFile:  testKeyFileA,
+	})
+	assert.Nil(err)
+
+	// In memory key storage
+	storedKeys := sync.Map{}
+	mockDatabase.On(
+		"RecordEncryptionKey",
+		mock.AnythingOfType("context.backgroundCtx"),
+		mock.AnythingOfType("[]uint8"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("models.AEADTypeENUMType"),
+	).Return(
+		func(
+			_ context.Context,
+			encKey []byte,
+			fingerprint string,
+			materialFingerprint string,
+			aeadType models.AEADTypeENUMType,
+		) (models.EncryptionKey, error) {
+			entry := models.EncryptionKey{
+				ID:                  uuid.NewString(),
+				EncKeyMaterial:      encKey,
+				RSAFingerprint:      fingerprint,
+				MaterialFingerprint: materialFingerprint,
+				AEADType:            aeadType,
+				State:               models.EncryptionKeyStateActive,
+			}
+			storedKeys.Store(entry.ID, entry)
+			return entry, nil
+		},
+	).Maybe()
+	mockDatabase.On(
+		"GetEncryptionKey", mock.Anything, mock.AnythingOfType("string"),
+	).Return(func(_ context.Context, keyID string) (models.EncryptionKey, error) {
+		entry, ok := storedKeys.Load(keyID)
+		assert.True(ok)
+		return entry.(models.EncryptionKey), nil
+	}).Maybe()
+
+	// Create keys, and use them, while the key cache is flushed and the RSA key pair swapped
+	wg := sync.WaitGroup{}
+	for worker := 0; worker < 4; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for itr := 0; itr < 10; itr++ {
+				newKey, err := uut.NewEncryptionKey(utCtx, mockDatabase)
+				assert.Nil(err)
+				plainText := []byte(uuid.NewString())
+				_, cipherText, err := uut.EncryptData(utCtx, newKey.ID, plainText, nil, nil, mockDatabase)
+				assert.Nil(err)
+				_, decrypted, err := uut.DecryptData(utCtx, newKey.ID, cipherText, mockDatabase)
+				assert.Nil(err)
+				assert.Equal(plainText, decrypted)
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for itr := 0; itr < 10; itr++ {
+			uut.Shutdown()
+		}
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for itr := 0; itr < 3; itr++ {
+			assert.Nil(uut.ReloadRSAKeyPair(utCtx, testCertFileB, testKeyFileB))
+			assert.Nil(uut.ReloadRSAKeyPair(utCtx, testCertFileA, testKeyFileA))
+		}
+	}()
+	wg.Wait()
+
+	// Every key can still be unwrapped
+	uut.Shutdown()
+	storedKeys.Range(func(keyID, _ any) bool {
+		_, err := uut.GetEncryptionKey(utCtx, keyID.(string), mockDatabase)
+		assert.Nil(err)
+		return true
+	})
+}
+
+// fakeKeyWrapper in-memory KeyWrapper encrypting with AES-GCM
+type fakeKeyWrapper struct {
+	aead    cipher.AEAD
+	wrapped int
+}
+
+func newFakeKeyWrapper(t *testing.T) *fakeKeyWrapper {
+	kek := make([]byte, 32)
+	_, err := rand.Read(kek)
+	assert.Nil(t, err)
+	block, err := aes.NewCipher(kek)
+	assert.Nil(t, err)
+	aead, err := cipher.NewGCM(block)
+	assert.Nil(t, err)
+	return &fakeKeyWrapper{aead: aead}
+}
+
+func (w *fakeKeyWrapper) WrapKey(plainKey []byte) ([]byte, error) {
+	w.wrapped++
+	nonce := make([]byte, w.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return w.aead.Seal(nonce, nonce, plainKey, nil), nil
+}
+
+func (w *fakeKeyWrapper) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	nonceSize := w.aead.NonceSize()
+	if len(wrappedKey) < nonceSize {
+		return nil, fmt.Errorf("wrapped key too short")
+	}
+	return w.aead.Open(nil, wrappedKey[:nonceSize], wrappedKey[nonceSize:], nil)
+}
+
+func TestCryptoEngineKeyWrapper(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	mockDBClient := mockdb.NewClient(t)
+	mockDatabase := mockdb.NewDatabase(t)
+
+	// No RSA key files are needed with a key wrapper
+	wrapper := newFakeKeyWrapper(t)
+	uut1, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+		Persistence: mockDBClient, KeyWrapper: wrapper,
+	})
+	assert.Nil(err)
+
+	testKey1 := models.EncryptionKey{
+		ID:    uuid.NewString(),
+		State: models.EncryptionKeyStateActive,
+	}
+	mockDatabase.On(
+		"RecordEncryptionKey",
+		mock.AnythingOfType("context.backgroundCtx"),
+		mock.AnythingOfType("[]uint8"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("models.AEADTypeENUMType"),
+	).Run(func(args mock.Arguments) {
+		encKey, ok := args.Get(1).([]byte)
+		assert.True(ok)
+		testKey1.EncKeyMaterial = encKey
+		testKey1.RSAFingerprint = args.String(2)
+	}).Return(testKey1, nil).Once()
+	_, err = uut1.NewEncryptionKey(utCtx, mockDatabase)
+	assert.Nil(err)
+	assert.Equal(1, wrapper.wrapped)
+	assert.Empty(testKey1.RSAFingerprint)
+
+	// Another engine with the same wrapper decrypts the key
+	uut2, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+		Persistence: mockDBClient, KeyWrapper: wrapper,
+	})
+	assert.Nil(err)
+	mockDatabase.On(
+		"GetEncryptionKey", mock.Anything, testKey1.ID,
+	).Return(testKey1, nil).Times(3)
+	plainText := []byte(uuid.NewString())
+	_, cipherText, err := uut1.EncryptData(utCtx, testKey1.ID, plainText, nil, nil, mockDatabase)
+	assert.Nil(err)
+	_, decrypted, err := uut2.DecryptData(utCtx, testKey1.ID, cipherText, mockDatabase)
+	assert.Nil(err)
+	assert.Equal(plainText, decrypted)
+
+	// The operations needing RSA keys are unavailable
+	_, err = uut1.WrapSecret(utCtx, []byte(uuid.NewString()))
+	assert.ErrorIs(err, encryption.ErrNoRSAKeys)
+	_, err = uut1.RewrapEncryptionKeys(utCtx, mockDatabase)
+	assert.ErrorIs(err, encryption.ErrNoRSAKeys)
+
+	testCertFile, err := filepath.Abs("../test/ut_rsa.crt")
+	assert.Nil(err)
+	testKeyFile, err := filepath.Abs("../test/ut_rsa.key")
+	assert.Nil(err)
+	assert.ErrorIs(
+		uut1.ReloadRSAKeyPair(utCtx, testCertFile, testKeyFile), encryption.ErrNoRSAKeys,
+	)
+
+	// An engine with RSA keys refuses the wrapped key
+	uut3, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+		Persistence:        mockDBClient,
+		PrimaryRSACertFile: testCertFile,
+		PrimaryRSAKeyFile:  testKeyFile,
+	})
+	assert.Nil(err)
+	_, err = uut3.GetEncryptionKey(utCtx, testKey1.ID, mockDatabase)
+	assert.Error(err)
+
+	// The key wrapper refuses a key wrapped with a RSA key
+	testKey2 := models.EncryptionKey{
+		ID:             uuid.NewString(),
+		State:          models.EncryptionKeyStateActive,
+		EncKeyMaterial: []byte(uuid.NewString()),
+		RSAFingerprint: uuid.NewString(),
+	}
+	mockDatabase.On(
+		"GetEncryptionKey", mock.Anything, testKey2.ID,
+	).Return(testKey2, nil).Once()
+	_, err = uut2.GetEncryptionKey(utCtx, testKey2.ID, mockDatabase)
+	assert.ErrorIs(err, encryption.ErrRSAKeyFingerprintMismatch)
 }
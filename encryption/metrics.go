@@ -0,0 +1,86 @@
+package encryption
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Cryptography engine metrics
+const (
+	// metricsNameCryptoOperation cryptographic operation tracking. Additional parameters
+	// are attached via labels
+	//
+	// - operation: [encrypt, decrypt]
+	//
+	// - success
+	metricsNameCryptoOperation = "haven_crypto_operation_total"
+
+	// metricsNameKeyCacheLookup encryption key cache lookup tracking. Additional parameters
+	// are attached via labels
+	//
+	// - result: [hit, miss]
+	metricsNameKeyCacheLookup = "haven_crypto_key_cache_lookup_total"
+)
+
+// engineMetrics the cryptography engine metrics trackers. A nil instance records nothing.
+type engineMetrics struct {
+	operations   *prometheus.CounterVec
+	cacheLookups *prometheus.CounterVec
+}
+
+/*
+newEngineMetrics define and register the cryptography engine metrics trackers
+
+	@param registerer prometheus.Registerer - registry to register the trackers with. Nil
+	    to not track metrics.
+	@returns the trackers, or nil if no registry is given
+*/
+func newEngineMetrics(registerer prometheus.Registerer) (*engineMetrics, error) {
+	if registerer == nil {
+		return nil, nil
+	}
+
+	metrics := &engineMetrics{
+		operations: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: metricsNameCryptoOperation,
+				Help: "Cryptographic operations performed",
+			},
+			[]string{"operation", "success"},
+		),
+		cacheLookups: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: metricsNameKeyCacheLookup,
+				Help: "Encryption key cache lookups",
+			},
+			[]string{"result"},
+		),
+	}
+	for _, collector := range []prometheus.Collector{metrics.operations, metrics.cacheLookups} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, fmt.Errorf("failed to register cryptography engine metrics [%w]", err)
+		}
+	}
+	return metrics, nil
+}
+
+// recordOperation count one cryptographic operation
+func (m *engineMetrics) recordOperation(operation string, err error) {
+	if m == nil {
+		return
+	}
+	m.operations.WithLabelValues(operation, fmt.Sprintf("%t", err == nil)).Inc()
+}
+
+// recordCacheLookup count one encryption key cache lookup
+func (m *engineMetrics) recordCacheLookup(hit bool) {
+	if m == nil {
+		return
+	}
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.cacheLookups.WithLabelValues(result).Inc()
+}
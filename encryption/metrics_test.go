@@ -0,0 +1,130 @@
+package encryption_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/alwitt/haven/encryption"
+	mockdb "github.com/alwitt/haven/mocks/db"
+	"github.com/alwitt/haven/models"
+	"github.com/apex/log"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// counterValue read a counter from the registry. Zero if it was never incremented.
+func counterValue(
+	t *testing.T, registry *prometheus.Registry, name string, labels map[string]string,
+) float64 {
+	families, err := registry.Gather()
+	assert.Nil(t, err)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			matched := 0
+			for _, label := range metric.GetLabel() {
+				if labels[label.GetName()] == label.GetValue() {
+					matched++
+				}
+			}
+			if matched == len(labels) {
+				return metric.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+func TestCryptoEngineMetrics(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// RSA cert files
+	testCertFile, err := filepath.Abs("../test/ut_rsa.crt")
+	assert.Nil(err)
+	testKeyFile, err := filepath.Abs("../test/ut_rsa.key")
+	assert.Nil(err)
+
+	mockDBClient := mockdb.NewClient(t)
+	mockDatabase := mockdb.NewDatabase(t)
+
+	registry := prometheus.NewRegistry()
+	uut, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+		Persistence:        mockDBClient,
+		PrimaryRSACertFile: testCertFile,
+		PrimaryRSAKeyFile:  testKeyFile,
+		Metrics:            registry,
+	})
+	assert.Nil(err)
+
+	// The metrics can only be registered once
+	_, err = encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+		Persistence:        mockDBClient,
+		PrimaryRSACertFile: testCertFile,
+		PrimaryRSAKeyFile:  testKeyFile,
+		Metrics:            registry,
+	})
+	assert.Error(err)
+
+	testKey := models.EncryptionKey{
+		ID:    uuid.NewString(),
+		State: models.EncryptionKeyStateActive,
+	}
+	mockDatabase.On(
+		"RecordEncryptionKey",
+		mock.AnythingOfType("context.backgroundCtx"),
+		mock.AnythingOfType("[]uint8"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("models.AEADTypeENUMType"),
+	).Run(func(args mock.Arguments) {
+		encKey, ok := args.Get(1).([]byte)
+		assert.True(ok)
+		testKey.EncKeyMaterial = encKey
+	}).Return(testKey, nil).Once()
+	_, err = uut.NewEncryptionKey(utCtx, mockDatabase)
+	assert.Nil(err)
+
+	cacheHits := map[string]string{"result": "hit"}
+	cacheMisses := map[string]string{"result": "miss"}
+
+	// Drop the cached key, so the first read misses and the second read hits
+	uut.Shutdown()
+	mockDatabase.On(
+		"GetEncryptionKey",
+		mock.Anything,
+		testKey.ID,
+	).Return(testKey, nil).Times(4)
+	_, err = uut.GetEncryptionKey(utCtx, testKey.ID, mockDatabase)
+	assert.Nil(err)
+	assert.Equal(1.0, counterValue(t, registry, "haven_crypto_key_cache_lookup_total", cacheMisses))
+	assert.Equal(0.0, counterValue(t, registry, "haven_crypto_key_cache_lookup_total", cacheHits))
+	_, err = uut.GetEncryptionKey(utCtx, testKey.ID, mockDatabase)
+	assert.Nil(err)
+	assert.Equal(1.0, counterValue(t, registry, "haven_crypto_key_cache_lookup_total", cacheMisses))
+	assert.Equal(1.0, counterValue(t, registry, "haven_crypto_key_cache_lookup_total", cacheHits))
+
+	// Cryptographic operations are counted by outcome
+	_, encrypted, err := uut.EncryptData(
+		utCtx, testKey.ID, []byte(uuid.NewString()), nil, nil, mockDatabase,
+	)
+	assert.Nil(err)
+	assert.Equal(1.0, counterValue(
+		t, registry, "haven_crypto_operation_total",
+		map[string]string{"operation": "encrypt", "success": "true"},
+	))
+	encrypted.CipherText[len(encrypted.CipherText)-1] ^= 0xff
+	_, _, err = uut.DecryptData(utCtx, testKey.ID, encrypted, mockDatabase)
+	assert.Error(err)
+	assert.Equal(1.0, counterValue(
+		t, registry, "haven_crypto_operation_total",
+		map[string]string{"operation": "decrypt", "success": "false"},
+	))
+}
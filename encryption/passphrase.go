@@ -0,0 +1,205 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+// ErrIncorrectRSAKeyPassphrase the passphrase failed to decrypt the RSA private key
+var ErrIncorrectRSAKeyPassphrase = errors.New("incorrect RSA private key passphrase")
+
+// pemTypeEncryptedPKCS8 PEM block type of an encrypted PKCS#8 private key
+const pemTypeEncryptedPKCS8 = "ENCRYPTED PRIVATE KEY"
+
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// encryptedPrivateKeyInfo PKCS#8 EncryptedPrivateKeyInfo
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// pbes2Params PKCS#5 PBES2-params
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+// pbkdf2Params PKCS#5 PBKDF2-params
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+/*
+decryptRSAKeyPEM decrypt a passphrase protected private key PEM, returning the private key
+as unencrypted PEM. Both PKCS#8 keys encrypted with PBES2, and PEM blocks encrypted with
+the legacy OpenSSL scheme are supported.
+
+	@param keyPEM []byte - the encrypted private key PEM
+	@param passphrase []byte - the passphrase
+	@return the unencrypted private key PEM
+*/
+func decryptRSAKeyPEM(keyPEM []byte, passphrase []byte) ([]byte, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("failed to parse out a PEM block from input")
+	}
+
+	switch {
+	case block.Type == pemTypeEncryptedPKCS8:
+		der, err := decryptPKCS8(block.Bytes, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+
+	// The legacy scheme is deprecated, but still written by OpenSSL's -traditional
+	case x509.IsEncryptedPEMBlock(block):
+		der, err := x509.DecryptPEMBlock(block, passphrase)
+		if errors.Is(err, x509.IncorrectPasswordError) {
+			return nil, ErrIncorrectRSAKeyPassphrase
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to decrypt PEM block [%w]", err)
+		}
+		if _, err := x509.ParsePKCS1PrivateKey(der); err != nil {
+			if _, err := x509.ParsePKCS8PrivateKey(der); err != nil {
+				return nil, ErrIncorrectRSAKeyPassphrase
+			}
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der}), nil
+
+	default:
+		return nil, fmt.Errorf("passphrase given, but the private key is not encrypted")
+	}
+}
+
+// isEncryptedRSAKeyPEM whether a private key PEM is passphrase protected
+func isEncryptedRSAKeyPEM(keyPEM []byte) bool {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return false
+	}
+	return block.Type == pemTypeEncryptedPKCS8 || x509.IsEncryptedPEMBlock(block)
+}
+
+// decryptPKCS8 decrypt the DER of a PKCS#8 EncryptedPrivateKeyInfo encrypted with PBES2,
+// using PBKDF2 and AES-CBC
+func decryptPKCS8(der []byte, passphrase []byte) ([]byte, error) {
+	var keyInfo encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &keyInfo); err != nil {
+		return nil, fmt.Errorf("failed to parse encrypted PKCS#8 private key [%w]", err)
+	}
+	if !keyInfo.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf(
+			"unsupported PKCS#8 encryption algorithm %s", keyInfo.Algorithm.Algorithm,
+		)
+	}
+
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(keyInfo.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("failed to parse PBES2 parameters [%w]", err)
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf(
+			"unsupported PBES2 key derivation function %s", params.KeyDerivationFunc.Algorithm,
+		)
+	}
+	var kdfParams pbkdf2Params
+	if _, err := asn1.Unmarshal(
+		params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams,
+	); err != nil {
+		return nil, fmt.Errorf("failed to parse PBKDF2 parameters [%w]", err)
+	}
+
+	var prf func() hash.Hash
+	switch {
+	case len(kdfParams.PRF.Algorithm) == 0, kdfParams.PRF.Algorithm.Equal(oidHMACWithSHA1):
+		prf = sha1.New
+	case kdfParams.PRF.Algorithm.Equal(oidHMACWithSHA256):
+		prf = sha256.New
+	case kdfParams.PRF.Algorithm.Equal(oidHMACWithSHA512):
+		prf = sha512.New
+	default:
+		return nil, fmt.Errorf("unsupported PBKDF2 PRF %s", kdfParams.PRF.Algorithm)
+	}
+
+	var keyLen int
+	switch {
+	case params.EncryptionScheme.Algorithm.Equal(oidAES128CBC):
+		keyLen = 16
+	case params.EncryptionScheme.Algorithm.Equal(oidAES192CBC):
+		keyLen = 24
+	case params.EncryptionScheme.Algorithm.Equal(oidAES256CBC):
+		keyLen = 32
+	default:
+		return nil, fmt.Errorf(
+			"unsupported PBES2 encryption scheme %s", params.EncryptionScheme.Algorithm,
+		)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, fmt.Errorf("failed to parse PBES2 encryption scheme IV [%w]", err)
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("PBES2 encryption scheme IV is %d bytes", len(iv))
+	}
+
+	key, err := pbkdf2.Key(
+		prf, string(passphrase), kdfParams.Salt, kdfParams.IterationCount, keyLen,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key from passphrase [%w]", err)
+	}
+	defer zeroKeyMaterial(key)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare AES cipher [%w]", err)
+	}
+
+	encrypted := keyInfo.EncryptedData
+	if len(encrypted) == 0 || len(encrypted)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("encrypted PKCS#8 private key is not a whole number of blocks")
+	}
+	plain := make([]byte, len(encrypted))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, encrypted)
+
+	// A wrong passphrase shows as invalid padding, or as a malformed private key
+	padding := int(plain[len(plain)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, ErrIncorrectRSAKeyPassphrase
+	}
+	for _, b := range plain[len(plain)-padding:] {
+		if int(b) != padding {
+			return nil, ErrIncorrectRSAKeyPassphrase
+		}
+	}
+	plain = plain[:len(plain)-padding]
+	if _, err := x509.ParsePKCS8PrivateKey(plain); err != nil {
+		return nil, ErrIncorrectRSAKeyPassphrase
+	}
+
+	return plain, nil
+}
@@ -0,0 +1,80 @@
+package encryption
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+)
+
+// ErrSecretTooLarge the secret is larger than the primary RSA key can wrap
+var ErrSecretTooLarge = errors.New("secret too large to wrap with the RSA key")
+
+// secretWrapLabel the RSA OAEP label of wrapped secrets. It separates wrapped secrets from
+// wrapped symmetric keys, so UnwrapSecret can not be used to decrypt key material.
+var secretWrapLabel = []byte("haven-wrapped-secret")
+
+// maxWrapSize the largest plain text RSA OAEP, with SHA512, can wrap under the public key
+func maxWrapSize(pubKey *rsa.PublicKey) int {
+	return pubKey.Size() - 2*sha512.Size - 2
+}
+
+/*
+WrapSecret encrypt a small secret with the primary RSA public key
+
+	@param ctx context.Context - execution context
+	@param plaintext []byte - the secret to wrap
+	@return the wrapped secret
+*/
+func (e *cryptoEngine) WrapSecret(ctx context.Context, plaintext []byte) ([]byte, error) {
+	rsaKeys, err := e.requireRSAKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	if limit := maxWrapSize(rsaKeys.primaryPubKey); len(plaintext) > limit {
+		return nil, fmt.Errorf(
+			"secret is %d bytes, the RSA key wraps at most %d [%w]",
+			len(plaintext),
+			limit,
+			ErrSecretTooLarge,
+		)
+	}
+
+	wrapped, err := e.crypto.RSAEncrypt(ctx, plaintext, rsaKeys.primaryPubKey, secretWrapLabel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap secret [%w]", err)
+	}
+	return wrapped, nil
+}
+
+/*
+UnwrapSecret decrypt a secret wrapped by WrapSecret. The primary RSA key is tried first,
+followed by each of the secondary RSA keys.
+
+	@param ctx context.Context - execution context
+	@param ciphertext []byte - the wrapped secret
+	@return the secret
+*/
+func (e *cryptoEngine) UnwrapSecret(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	rsaKeys, err := e.requireRSAKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := e.crypto.RSADecrypt(ctx, ciphertext, rsaKeys.primaryKey, secretWrapLabel)
+	if err == nil {
+		return plaintext, nil
+	}
+
+	for _, secondaryKey := range rsaKeys.secondaryKeys {
+		if plaintext, secondaryErr := e.crypto.RSADecrypt(
+			ctx, ciphertext, secondaryKey, secretWrapLabel,
+		); secondaryErr == nil {
+			return plaintext, nil
+		}
+	}
+
+	return nil, fmt.Errorf("failed to unwrap secret [%w]", err)
+}
@@ -0,0 +1,80 @@
+package encryption_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/alwitt/haven/encryption"
+	mockdb "github.com/alwitt/haven/mocks/db"
+	"github.com/alwitt/haven/models"
+	"github.com/apex/log"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCryptoEngineWrapSecret(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	// RSA cert files
+	testCertFile, err := filepath.Abs("../test/ut_rsa.crt")
+	assert.Nil(err)
+	testKeyFile, err := filepath.Abs("../test/ut_rsa.key")
+	assert.Nil(err)
+	testCertFile2, err := filepath.Abs("../test/ut_rsa_2.crt")
+	assert.Nil(err)
+	testKeyFile2, err := filepath.Abs("../test/ut_rsa_2.key")
+	assert.Nil(err)
+
+	mockDBClient := mockdb.NewClient(t)
+	mockDatabase := mockdb.NewDatabase(t)
+
+	uut, err := encryption.NewCryptographyEngine(utCtx, encryption.CryptographyEngineParams{
+		Persistence:        mockDBClient,
+		PrimaryRSACertFile: testCertFile,
+		PrimaryRSAKeyFile:  testKeyFile,
+	})
+	assert.Nil(err)
+
+	// Round trip a secret
+	secret := []byte(uuid.NewString())
+	wrapped, err := uut.WrapSecret(utCtx, secret)
+	assert.Nil(err)
+	assert.NotEqual(secret, wrapped)
+	unwrapped, err := uut.UnwrapSecret(utCtx, wrapped)
+	assert.Nil(err)
+	assert.Equal(secret, unwrapped)
+
+	// Secret too large for the RSA key
+	_, err = uut.WrapSecret(utCtx, make([]byte, 4096))
+	assert.ErrorIs(err, encryption.ErrSecretTooLarge)
+
+	// Secrets wrapped by the previous RSA key pair remain readable
+	assert.Nil(uut.ReloadRSAKeyPair(utCtx, testCertFile2, testKeyFile2))
+	unwrapped, err = uut.UnwrapSecret(utCtx, wrapped)
+	assert.Nil(err)
+	assert.Equal(secret, unwrapped)
+
+	// Wrapped symmetric key material can not be unwrapped as a secret
+	var keyMaterial []byte
+	mockDatabase.On(
+		"RecordEncryptionKey",
+		mock.AnythingOfType("context.backgroundCtx"),
+		mock.AnythingOfType("[]uint8"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("models.AEADTypeENUMType"),
+	).Run(func(args mock.Arguments) {
+		var ok bool
+		keyMaterial, ok = args.Get(1).([]byte)
+		assert.True(ok)
+	}).Return(models.EncryptionKey{ID: uuid.NewString()}, nil).Once()
+	_, err = uut.NewEncryptionKey(utCtx, mockDatabase)
+	assert.Nil(err)
+	_, err = uut.UnwrapSecret(utCtx, keyMaterial)
+	assert.Error(err)
+}
@@ -2,54 +2,253 @@ package encryption
 
 import (
 	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
+
+	"github.com/apex/log"
 )
 
-// loadRSAKeyPair load the primary RSA key pair for encrypting and decrypting symmetric keys
-func (e *cryptoEngine) loadRSAKeyPair(
-	ctx context.Context, certFilePath string, keyFilePath string,
+// rsaKeySet the RSA keys for encrypting and decrypting symmetric keys
+//
+// A key set is not modified once defined; reloading the RSA keys replaces the whole set.
+type rsaKeySet struct {
+	primaryKey    *rsa.PrivateKey
+	primaryPubKey *rsa.PublicKey
+	// primaryFingerprint fingerprint of the primary RSA public key
+	primaryFingerprint string
+
+	// secondaryKeys additional RSA private keys only used to decrypt symmetric keys
+	secondaryKeys []*rsa.PrivateKey
+
+	// keysByFingerprint the primary and secondary RSA private keys by the fingerprint
+	// of their public keys
+	keysByFingerprint map[string]*rsa.PrivateKey
+}
+
+// newRSAKeySet define a new RSA key set
+func newRSAKeySet(
+	primaryKey *rsa.PrivateKey, primaryPubKey *rsa.PublicKey, secondaryKeys []*rsa.PrivateKey,
+) (*rsaKeySet, error) {
+	primaryFingerprint, err := rsaPublicKeyFingerprint(primaryPubKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute primary RSA public key fingerprint [%w]", err)
+	}
+
+	keySet := &rsaKeySet{
+		primaryKey:         primaryKey,
+		primaryPubKey:      primaryPubKey,
+		primaryFingerprint: primaryFingerprint,
+		secondaryKeys:      []*rsa.PrivateKey{},
+		keysByFingerprint:  map[string]*rsa.PrivateKey{primaryFingerprint: primaryKey},
+	}
+
+	// Index the secondary RSA keys by fingerprint
+	for _, secondaryKey := range secondaryKeys {
+		fingerprint, err := rsaPublicKeyFingerprint(&secondaryKey.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute RSA public key fingerprint [%w]", err)
+		}
+		if _, ok := keySet.keysByFingerprint[fingerprint]; ok {
+			continue
+		}
+		keySet.keysByFingerprint[fingerprint] = secondaryKey
+		keySet.secondaryKeys = append(keySet.secondaryKeys, secondaryKey)
+	}
+
+	return keySet, nil
+}
+
+// loadRSAKeySet load the primary RSA key pair and the secondary RSA private keys
+func (e *cryptoEngine) loadRSAKeySet(
+	ctx context.Context, params CryptographyEngineParams,
+) (*rsaKeySet, error) {
+	var primaryKey *rsa.PrivateKey
+	var primaryPubKey *rsa.PublicKey
+	var err error
+	if params.PrimaryRSACertFile == "" && params.PrimaryRSAKeyFile == "" {
+		primaryKey, primaryPubKey, err = e.parseRSAKeyPair(
+			ctx, params.PrimaryRSACertPEM, params.PrimaryRSAKeyPEM, "in-memory PEM",
+		)
+	} else {
+		primaryKey, primaryPubKey, err = e.loadRSAKeyPair(
+			ctx, params.PrimaryRSACertFile, params.PrimaryRSAKeyFile,
+		)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load primary RSA key pair [%w]", err)
+	}
+	secondaryKeys := []*rsa.PrivateKey{}
+	for _, keyFile := range params.SecondaryRSAKeyFiles {
+		secondaryKey, err := e.loadRSAPrivateKey(ctx, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load secondary RSA private key [%w]", err)
+		}
+		secondaryKeys = append(secondaryKeys, secondaryKey)
+	}
+	keySet, err := newRSAKeySet(primaryKey, primaryPubKey, secondaryKeys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare RSA key set [%w]", err)
+	}
+	return keySet, nil
+}
+
+// getRSAKeys fetch the current RSA key set. Nil in insecure plaintext keys mode, or with a
+// key wrapper.
+func (e *cryptoEngine) getRSAKeys() *rsaKeySet {
+	e.rsaKeysLock.RLock()
+	defer e.rsaKeysLock.RUnlock()
+	return e.rsaKeys
+}
+
+// requireRSAKeys fetch the current RSA key set, failing with ErrNoRSAKeys in insecure
+// plaintext keys mode, or with a key wrapper
+func (e *cryptoEngine) requireRSAKeys() (*rsaKeySet, error) {
+	if rsaKeys := e.getRSAKeys(); rsaKeys != nil {
+		return rsaKeys, nil
+	}
+	return nil, ErrNoRSAKeys
+}
+
+/*
+ReloadRSAKeyPair replace the primary RSA key pair with one read from file. The previous
+primary RSA private key is retained as a secondary RSA key, so symmetric keys it
+encrypted remain usable until they are re-encrypted with RewrapEncryptionKeys. An
+encrypted private key is decrypted with the engine's PrimaryRSAKeyPassphrase.
+
+	@param ctx context.Context - execution context
+	@param certFile string - file path to the new primary RSA certificate PEM
+	@param keyFile string - file path to the new primary RSA certificate private key PEM
+*/
+func (e *cryptoEngine) ReloadRSAKeyPair(
+	ctx context.Context, certFile string, keyFile string,
 ) error {
-	certFile, err := os.Open(certFilePath)
+	if _, err := e.requireRSAKeys(); err != nil {
+		return err
+	}
+
+	primaryKey, primaryPubKey, err := e.loadRSAKeyPair(ctx, certFile, keyFile)
 	if err != nil {
-		return fmt.Errorf("failed to open %s [%w]", certFilePath, err)
+		return fmt.Errorf("failed to load primary RSA key pair [%w]", err)
 	}
 
-	keyFile, err := os.Open(keyFilePath)
+	e.rsaKeysLock.Lock()
+	defer e.rsaKeysLock.Unlock()
+
+	secondaryKeys := append([]*rsa.PrivateKey{e.rsaKeys.primaryKey}, e.rsaKeys.secondaryKeys...)
+	newKeys, err := newRSAKeySet(primaryKey, primaryPubKey, secondaryKeys)
 	if err != nil {
-		return fmt.Errorf("failed to open %s [%w]", keyFilePath, err)
+		return fmt.Errorf("failed to prepare RSA key set [%w]", err)
+	}
+	e.rsaKeys = newKeys
+
+	log.WithFields(e.LogTags).
+		WithField("fingerprint", newKeys.primaryFingerprint).
+		Info("Reloaded primary RSA key pair")
+
+	return nil
+}
+
+// loadRSAKeyPair load a RSA key pair for encrypting and decrypting symmetric keys
+func (e *cryptoEngine) loadRSAKeyPair(
+	ctx context.Context, certFilePath string, keyFilePath string,
+) (*rsa.PrivateKey, *rsa.PublicKey, error) {
+	certFile, err := os.Open(certFilePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open %s [%w]", certFilePath, err)
 	}
 
 	certContent, err := io.ReadAll(certFile)
 	if err != nil {
-		return fmt.Errorf("%s read error [%w]", certFilePath, err)
+		return nil, nil, fmt.Errorf("%s read error [%w]", certFilePath, err)
 	}
 
-	keyContent, err := io.ReadAll(keyFile)
+	keyContent, err := readRSAPrivateKeyFile(keyFilePath)
 	if err != nil {
-		return fmt.Errorf("%s read error [%w]", keyFilePath, err)
+		return nil, nil, err
 	}
 
-	parsedCert, err := e.crypto.ParseCertificateFromPEM(ctx, string(certContent))
+	return e.parseRSAKeyPair(ctx, certContent, keyContent, certFilePath)
+}
+
+// parseRSAKeyPair parse a RSA key pair from PEM, decrypting the private key with the
+// engine's passphrase if it is set. The source names where the PEM was read from, for
+// error reporting.
+func (e *cryptoEngine) parseRSAKeyPair(
+	ctx context.Context, certPEM []byte, keyPEM []byte, source string,
+) (*rsa.PrivateKey, *rsa.PublicKey, error) {
+	parsedCert, err := e.crypto.ParseCertificateFromPEM(ctx, string(certPEM))
 	if err != nil {
-		return fmt.Errorf("failed to parse x509 certificate in %s [%w]", certFilePath, err)
+		return nil, nil, fmt.Errorf("failed to parse x509 certificate in %s [%w]", source, err)
 	}
 
-	parsedKey, err := e.crypto.ParseRSAPrivateKeyFromPEM(ctx, string(keyContent))
+	if len(e.rsaKeyPassphrase) > 0 {
+		if keyPEM, err = decryptRSAKeyPEM(keyPEM, e.rsaKeyPassphrase); err != nil {
+			return nil, nil, fmt.Errorf("failed to decrypt RSA private key in %s [%w]", source, err)
+		}
+	} else if isEncryptedRSAKeyPEM(keyPEM) {
+		return nil, nil, fmt.Errorf(
+			"RSA private key in %s is encrypted, but no passphrase was given", source,
+		)
+	}
+
+	parsedKey, err := e.crypto.ParseRSAPrivateKeyFromPEM(ctx, string(keyPEM))
 	if err != nil {
-		return fmt.Errorf("failed to parse RSA private key in %s [%w]", keyFilePath, err)
+		return nil, nil, fmt.Errorf("failed to parse RSA private key in %s [%w]", source, err)
 	}
 
 	parsedPubKey, err := e.crypto.ReadRSAPublicKeyFromCert(ctx, parsedCert)
 	if err != nil {
-		return fmt.Errorf(
-			"failed to pull RSA public key from x509 certificate in %s [%w]", certFilePath, err,
+		return nil, nil, fmt.Errorf(
+			"failed to pull RSA public key from x509 certificate in %s [%w]", source, err,
 		)
 	}
 
-	e.rsaKey = parsedKey
-	e.rsaPubKey = parsedPubKey
+	return parsedKey, parsedPubKey, nil
+}
 
-	return nil
+// readRSAPrivateKeyFile read the PEM of a RSA private key file
+func readRSAPrivateKeyFile(keyFilePath string) ([]byte, error) {
+	keyFile, err := os.Open(keyFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s [%w]", keyFilePath, err)
+	}
+
+	keyContent, err := io.ReadAll(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s read error [%w]", keyFilePath, err)
+	}
+
+	return keyContent, nil
+}
+
+// loadRSAPrivateKey load a RSA private key
+func (e *cryptoEngine) loadRSAPrivateKey(
+	ctx context.Context, keyFilePath string,
+) (*rsa.PrivateKey, error) {
+	keyContent, err := readRSAPrivateKeyFile(keyFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedKey, err := e.crypto.ParseRSAPrivateKeyFromPEM(ctx, string(keyContent))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse RSA private key in %s [%w]", keyFilePath, err)
+	}
+
+	return parsedKey, nil
+}
+
+// rsaPublicKeyFingerprint compute the SHA256 fingerprint of a RSA public key
+func rsaPublicKeyFingerprint(pubKey *rsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pubKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize RSA public key [%w]", err)
+	}
+	digest := sha256.Sum256(der)
+	return hex.EncodeToString(digest[:]), nil
 }
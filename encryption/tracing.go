@@ -0,0 +1,13 @@
+package encryption
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// tracer the cryptography engine's tracer. Spans are only recorded once a tracer provider
+// is installed with otel.SetTracerProvider.
+var tracer = otel.Tracer("github.com/alwitt/haven/encryption")
+
+// attrEncryptionKeyID span attribute recording the encryption key ID
+const attrEncryptionKeyID = attribute.Key("haven.encryption_key_id")
@@ -0,0 +1,108 @@
+package encryption
+
+import (
+	"context"
+	"fmt"
+
+	cgoCrypto "github.com/alwitt/cgoutils/crypto"
+)
+
+/*
+KeyWrapper encrypts and decrypts symmetric key material on behalf of the engine. Unless one
+is supplied, the engine's RSA key pairs do so. Supplying one allows the key encrypting keys
+to live outside of the process, such as in AWS KMS or Vault. Key material a supplied
+KeyWrapper encrypts is recorded without a RSA fingerprint.
+*/
+type KeyWrapper interface {
+	/*
+		WrapKey encrypt symmetric key material
+
+			@param plainKey []byte - the plain text key material
+			@return the encrypted key material
+	*/
+	WrapKey(plainKey []byte) ([]byte, error)
+
+	/*
+		UnwrapKey decrypt symmetric key material encrypted by WrapKey
+
+			@param wrappedKey []byte - the encrypted key material
+			@return the plain text key material
+	*/
+	UnwrapKey(wrappedKey []byte) ([]byte, error)
+}
+
+// rsaKeyWrapper KeyWrapper encrypting symmetric keys with the primary RSA public key of a
+// RSA key set. The engine uses it unless a KeyWrapper is supplied.
+//
+// A rsaKeyWrapper serves a single operation. It holds the context of the operation, and
+// the RSA key set at the start of the operation, so reloading the RSA keys midway does not
+// change which RSA key the recorded fingerprint names.
+type rsaKeyWrapper struct {
+	ctx    context.Context
+	crypto cgoCrypto.Engine
+	keys   *rsaKeySet
+
+	// fingerprint fingerprint of the RSA public key which encrypted the key material to
+	// decrypt. If empty, the primary RSA key is tried first, followed by each of the
+	// secondary RSA keys.
+	fingerprint string
+}
+
+/*
+WrapKey encrypt symmetric key material with the primary RSA public key, first verifying
+the key fits within what RSA OAEP can wrap under that RSA key
+
+	@param plainKey []byte - the plain text key material
+	@return the encrypted key material
+*/
+func (w *rsaKeyWrapper) WrapKey(plainKey []byte) ([]byte, error) {
+	pubKey := w.keys.primaryPubKey
+	if limit := maxWrapSize(pubKey); len(plainKey) > limit {
+		return nil, fmt.Errorf(
+			"symmetric key is %d bytes, but the %d bit RSA key wraps at most %d; "+
+				"use a RSA key of at least %d bits [%w]",
+			len(plainKey),
+			pubKey.N.BitLen(),
+			max(limit, 0),
+			(pubKey.Size()-limit+len(plainKey))*8,
+			ErrKeyTooLargeToWrap,
+		)
+	}
+	return w.crypto.RSAEncrypt(w.ctx, plainKey, pubKey, nil)
+}
+
+/*
+UnwrapKey decrypt symmetric key material encrypted by WrapKey. If the fingerprint of the
+RSA key which encrypted it is known, only that RSA key is used.
+
+	@param wrappedKey []byte - the encrypted key material
+	@return the plain text key material
+*/
+func (w *rsaKeyWrapper) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	if w.fingerprint != "" {
+		rsaKey, ok := w.keys.keysByFingerprint[w.fingerprint]
+		if !ok {
+			return nil, fmt.Errorf(
+				"RSA key with fingerprint %s not loaded [%w]",
+				w.fingerprint,
+				ErrRSAKeyFingerprintMismatch,
+			)
+		}
+		return w.crypto.RSADecrypt(w.ctx, wrappedKey, rsaKey, nil)
+	}
+
+	key, err := w.crypto.RSADecrypt(w.ctx, wrappedKey, w.keys.primaryKey, nil)
+	if err == nil {
+		return key, nil
+	}
+
+	for _, secondaryKey := range w.keys.secondaryKeys {
+		if key, secondaryErr := w.crypto.RSADecrypt(
+			w.ctx, wrappedKey, secondaryKey, nil,
+		); secondaryErr == nil {
+			return key, nil
+		}
+	}
+
+	return nil, err
+}
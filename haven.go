@@ -23,6 +23,7 @@ essentially copies of each other.
 	@param dbLogLevel logger.LogLevel - SQL log level
 	@param primaryRSACertFile string - file path to the primary RSA certificate PEM
 	@param primaryRSAKeyFile string - file path to the primary RSA certificate private key PEM
+	@param storeOptions store.ProtectedKVStoreOptions - store behavior options
 	@returns new store instance
 */
 func NewProtectedKVStore(
@@ -31,6 +32,7 @@ func NewProtectedKVStore(
 	dbLogLevel logger.LogLevel,
 	primaryRSACertFile string,
 	primaryRSAKeyFile string,
+	storeOptions store.ProtectedKVStoreOptions,
 ) (store.ProtectedKVStore, error) {
 	// Prepare persistence
 	persistence, err := db.NewConnection(dbDialector, dbLogLevel)
@@ -38,17 +40,57 @@ func NewProtectedKVStore(
 		return nil, fmt.Errorf("failed to initialized persistence client [%w]", err)
 	}
 
+	return newProtectedKVStore(
+		ctx, persistence, primaryRSACertFile, primaryRSAKeyFile, storeOptions,
+	)
+}
+
+/*
+NewInMemoryProtectedKVStore initialize a protected KV store instance backed by a new
+in-memory SQL database. The data is lost once the process exits.
+
+	@param ctx context.Context - execution context
+	@param primaryRSACertFile string - file path to the primary RSA certificate PEM
+	@param primaryRSAKeyFile string - file path to the primary RSA certificate private key PEM
+	@returns new store instance
+*/
+func NewInMemoryProtectedKVStore(
+	ctx context.Context, primaryRSACertFile string, primaryRSAKeyFile string,
+) (store.ProtectedKVStore, error) {
+	// Prepare persistence
+	persistence, err := db.NewConnection(db.GetInMemorySqliteDialector(), logger.Error)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialized persistence client [%w]", err)
+	}
+	if err := persistence.RunSQLInTransaction(ctx, db.DefineTables); err != nil {
+		return nil, fmt.Errorf("failed to define tables [%w]", err)
+	}
+
+	return newProtectedKVStore(
+		ctx, persistence, primaryRSACertFile, primaryRSAKeyFile, store.ProtectedKVStoreOptions{},
+	)
+}
+
+// newProtectedKVStore initialize a protected KV store instance using a persistence client
+func newProtectedKVStore(
+	ctx context.Context,
+	persistence db.Client,
+	primaryRSACertFile string,
+	primaryRSAKeyFile string,
+	storeOptions store.ProtectedKVStoreOptions,
+) (store.ProtectedKVStore, error) {
 	// Prepare cryptography engine
 	cryptoEngine, err := encryption.NewCryptographyEngine(ctx, encryption.CryptographyEngineParams{
 		Persistence:        persistence,
 		PrimaryRSACertFile: primaryRSACertFile,
 		PrimaryRSAKeyFile:  primaryRSAKeyFile,
+		Metrics:            storeOptions.Metrics,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialized cryptography engine [%w]", err)
 	}
 
-	store, err := store.NewProtectedKVStore(ctx, persistence, cryptoEngine)
+	store, err := store.NewProtectedKVStore(ctx, persistence, cryptoEngine, storeOptions)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialized protected KV store [%w]", err)
 	}
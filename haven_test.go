@@ -1,21 +1,99 @@
 package haven_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/alwitt/haven"
 	"github.com/alwitt/haven/db"
+	"github.com/alwitt/haven/encryption"
+	"github.com/alwitt/haven/models"
+	"github.com/alwitt/haven/store"
 	"github.com/apex/log"
 	"github.com/google/uuid"
 	"github.com/oklog/ulid/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm/logger"
 )
 
+/*
+testRSAFiles the unit test primary RSA key pair
+
+	@param t *testing.T - the test
+	@returns the absolute paths of the certificate and the private key PEM
+*/
+func testRSAFiles(t *testing.T) (string, string) {
+	certFile, err := filepath.Abs("./test/ut_rsa.crt")
+	assert.Nil(t, err)
+	keyFile, err := filepath.Abs("./test/ut_rsa.key")
+	assert.Nil(t, err)
+	return certFile, keyFile
+}
+
+/*
+newTestDB define a new temporary SQLite DB, with its tables created
+
+	@param t *testing.T - the test
+	@returns the DB file path, and a client connected to it
+*/
+func newTestDB(t *testing.T) (string, db.Client) {
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	dbClient, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
+	assert.Nil(t, err)
+	assert.Nil(t, dbClient.RunSQLInTransaction(context.Background(), db.DefineTables))
+	return testDB, dbClient
+}
+
+/*
+newTestStore define a new store on a new temporary SQLite DB
+
+The engine uses the new DB, and unless the parameters select another source of key
+encryption, the unit test primary RSA key pair.
+
+	@param t *testing.T - the test
+	@param engineParams encryption.CryptographyEngineParams - cryptography engine parameters
+	@param storeOpts store.ProtectedKVStoreOptions - store options
+	@returns the store, with the DB client and the cryptography engine it uses
+*/
+func newTestStore(
+	t *testing.T,
+	engineParams encryption.CryptographyEngineParams,
+	storeOpts store.ProtectedKVStoreOptions,
+) (store.ProtectedKVStore, db.Client, encryption.CryptographyEngine) {
+	ctx := context.Background()
+	_, dbClient := newTestDB(t)
+
+	engineParams.Persistence = dbClient
+	if !engineParams.InsecurePlaintextKeys &&
+		engineParams.KeyWrapper == nil &&
+		engineParams.PrimaryRSACertPEM == nil &&
+		engineParams.PrimaryRSACertFile == "" {
+		engineParams.PrimaryRSACertFile, engineParams.PrimaryRSAKeyFile = testRSAFiles(t)
+	}
+	engine, err := encryption.NewCryptographyEngine(ctx, engineParams)
+	assert.Nil(t, err)
+
+	uut, err := store.NewProtectedKVStore(ctx, dbClient, engine, storeOpts)
+	assert.Nil(t, err)
+	return uut, dbClient, engine
+}
+
 // TestProtectedKVStoreEndToEnd performs a full end‑to‑end test of the
 // ProtectedKVStore.  The flow closely mirrors the integration tests for the
 // encryption key APIs – a temporary SQLite database is created, the
@@ -30,26 +108,23 @@ func TestProtectedKVStoreEndToEnd(t *testing.T) {
 	// ------------------------------------------------------------------
 	ctx := context.Background()
 
-	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
-	dbClient, err := db.NewConnection(db.GetSqliteDialector(testDB), logger.Error)
-	assert.Nil(err)
-
-	// Create tables
-	assert.Nil(dbClient.RunSQLInTransaction(ctx, db.DefineTables))
+	testDB, _ := newTestDB(t)
 
 	// ------------------------------------------------------------------
 	// 2. Load RSA key files
 	// ------------------------------------------------------------------
-	certFile, err := filepath.Abs("./test/ut_rsa.crt")
-	assert.Nil(err)
-	keyFile, err := filepath.Abs("./test/ut_rsa.key")
-	assert.Nil(err)
+	certFile, keyFile := testRSAFiles(t)
 
 	// ------------------------------------------------------------------
 	// 3. Create the protected KV store
 	// ------------------------------------------------------------------
 	store, err := haven.NewProtectedKVStore(
-		ctx, db.GetSqliteDialector(testDB), logger.Error, certFile, keyFile,
+		ctx,
+		db.GetSqliteDialector(testDB),
+		logger.Error,
+		certFile,
+		keyFile,
+		store.ProtectedKVStoreOptions{},
 	)
 	assert.Nil(err)
 
@@ -102,6 +177,11 @@ func TestProtectedKVStoreEndToEnd(t *testing.T) {
 	assert.True(ids[ver1.ID])
 	assert.True(ids[ver2.ID])
 
+	// Still only one key
+	keyCount, err := store.CountKeys(ctx, nil)
+	assert.Nil(err)
+	assert.Equal(int64(1), keyCount)
+
 	// ------------------------------------------------------------------
 	// 9. Fetch the second value using the RecordVersion object
 	// ------------------------------------------------------------------
@@ -119,4 +199,2615 @@ func TestProtectedKVStoreEndToEnd(t *testing.T) {
 	// ------------------------------------------------------------------
 	_, _, err = store.ListKeyVersions(ctx, keyName, nil)
 	assert.Error(err)
+
+	keyCount, err = store.CountKeys(ctx, nil)
+	assert.Nil(err)
+	assert.Equal(int64(0), keyCount)
+}
+
+// TestProtectedKVStoreAutoInitSystemState verifies that a store constructed with
+// `AutoInitSystemState`, which it defaults to, drives the system state to RUNNING.
+func TestProtectedKVStoreAutoInitSystemState(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	testDB, dbClient := newTestDB(t)
+
+	certFile, keyFile := testRSAFiles(t)
+
+	systemState := func() models.SystemStateENUMType {
+		var state models.SystemStateENUMType
+		assert.Nil(dbClient.UseDatabase(ctx, func(ctx context.Context, dbClient db.Database) error {
+			params, err := dbClient.GetSystemParamEntry(ctx)
+			state = params.State
+			return err
+		}))
+		return state
+	}
+
+	// Without the option, the system state is left as is
+	autoInit := false
+	_, err := haven.NewProtectedKVStore(
+		ctx,
+		db.GetSqliteDialector(testDB),
+		logger.Error,
+		certFile,
+		keyFile,
+		store.ProtectedKVStoreOptions{AutoInitSystemState: &autoInit},
+	)
+	assert.Nil(err)
+	assert.Equal(models.SystemStatePreInit, systemState())
+
+	// Construct the store twice; the second construction must leave the state as is
+	autoInit = true
+	for _, autoInitOpt := range []*bool{nil, &autoInit} {
+		_, err := haven.NewProtectedKVStore(
+			ctx,
+			db.GetSqliteDialector(testDB),
+			logger.Error,
+			certFile,
+			keyFile,
+			store.ProtectedKVStoreOptions{AutoInitSystemState: autoInitOpt},
+		)
+		assert.Nil(err)
+		assert.Equal(models.SystemStateRunning, systemState())
+	}
+
+	// The state transitions are only recorded once
+	assert.Nil(dbClient.UseDatabase(ctx, func(ctx context.Context, dbClient db.Database) error {
+		events, err := dbClient.ListSystemEvents(ctx, db.SystemEventQueryFilter{
+			EventTypes: []models.SystemEventTypeENUMType{
+				models.SystemEventTypeInitializing, models.SystemEventTypeInitialized,
+			},
+		})
+		assert.Nil(err)
+		assert.Len(events, 2)
+		return err
+	}))
+}
+
+// TestProtectedKVStoreObjectValues verifies that structured values round trip through
+// `RecordKeyObject` and `GetKeyObject` using the default codec.
+func TestProtectedKVStoreObjectValues(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	uut, _, _ := newTestStore(
+		t, encryption.CryptographyEngineParams{}, store.ProtectedKVStoreOptions{},
+	)
+
+	type testConfig struct {
+		Host    string            `json:"host"`
+		Port    int               `json:"port"`
+		Enabled bool              `json:"enabled"`
+		Labels  map[string]string `json:"labels"`
+	}
+
+	value := testConfig{
+		Host:    uuid.NewString(),
+		Port:    8443,
+		Enabled: true,
+		Labels:  map[string]string{"env": uuid.NewString()},
+	}
+
+	_, version, err := uut.RecordKeyObject(ctx, "testconfig", value, time.Now(), nil)
+	assert.Nil(err)
+
+	var retrieved testConfig
+	assert.Nil(uut.GetKeyObject(ctx, version.ID, &retrieved, nil))
+	assert.Equal(value, retrieved)
+
+	// The raw value is the JSON encoding
+	raw, err := uut.GetValueOfKeyAtVersionID(ctx, version.ID, nil)
+	assert.Nil(err)
+	expected, err := store.JSONValueCodec{}.Encode(value)
+	assert.Nil(err)
+	assert.Equal(expected, raw)
+}
+
+// TestProtectedKVStoreWatch verifies that changes to watched keys are delivered to the
+// subscriber.
+func TestProtectedKVStoreWatch(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	uut, dbClient, _ := newTestStore(
+		t, encryption.CryptographyEngineParams{}, store.ProtectedKVStoreOptions{},
+	)
+
+	watchCtx, watchCancel := context.WithCancel(ctx)
+	events, err := uut.Watch(watchCtx, "config/")
+	assert.Nil(err)
+
+	readEvent := func() (store.WatchEvent, bool) {
+		select {
+		case event, ok := <-events:
+			return event, ok
+		case <-time.After(time.Second):
+			return store.WatchEvent{}, false
+		}
+	}
+
+	// Key outside the prefix is not delivered
+	_, _, err = uut.RecordKeyValue(ctx, "other/key", []byte(uuid.NewString()), time.Now(), nil)
+	assert.Nil(err)
+
+	// Create
+	rec, ver1, err := uut.RecordKeyValue(
+		ctx, "config/key", []byte(uuid.NewString()), time.Now(), nil,
+	)
+	assert.Nil(err)
+	event, ok := readEvent()
+	assert.True(ok)
+	assert.Equal(store.WatchEventTypeCreated, event.Type)
+	assert.Equal("config/key", event.Key)
+	assert.Equal(rec.ID, event.RecordID)
+	assert.Equal(ver1.ID, event.VersionID)
+
+	// Update
+	_, ver2, err := uut.RecordKeyValue(
+		ctx, "config/key", []byte(uuid.NewString()), time.Now(), nil,
+	)
+	assert.Nil(err)
+	event, ok = readEvent()
+	assert.True(ok)
+	assert.Equal(store.WatchEventTypeUpdated, event.Type)
+	assert.Equal(ver2.ID, event.VersionID)
+
+	// Delete
+	assert.Nil(uut.DeleteKey(ctx, "config/key", nil))
+	event, ok = readEvent()
+	assert.True(ok)
+	assert.Equal(store.WatchEventTypeDeleted, event.Type)
+	assert.Equal(rec.ID, event.RecordID)
+
+	noEventYet := func() bool {
+		select {
+		case <-events:
+			return false
+		case <-time.After(time.Millisecond * 50):
+			return true
+		}
+	}
+
+	// A change in a caller transaction which rolls back is never delivered
+	errRollback := fmt.Errorf("roll back")
+	assert.ErrorIs(dbClient.UseDatabaseInTransaction(
+		ctx, func(ctx context.Context, tx db.Database) error {
+			_, _, err := uut.RecordKeyValue(
+				ctx, "config/tx", []byte(uuid.NewString()), time.Now(), tx,
+			)
+			assert.Nil(err)
+			return errRollback
+		},
+	), errRollback)
+	assert.True(noEventYet())
+
+	// A change in a caller transaction is delivered once it commits
+	assert.Nil(dbClient.UseDatabaseInTransaction(
+		ctx, func(ctx context.Context, tx db.Database) error {
+			_, _, err := uut.RecordKeyValue(
+				ctx, "config/tx", []byte(uuid.NewString()), time.Now(), tx,
+			)
+			assert.Nil(err)
+			assert.True(noEventYet())
+			return nil
+		},
+	))
+	event, ok = readEvent()
+	assert.True(ok)
+	assert.Equal(store.WatchEventTypeCreated, event.Type)
+	assert.Equal("config/tx", event.Key)
+
+	// Cancelling the context closes the channel
+	watchCancel()
+	_, ok = readEvent()
+	assert.False(ok)
+}
+
+// TestProtectedKVStoreWatchLagged verifies that a subscriber which falls behind is told
+// events were dropped.
+func TestProtectedKVStoreWatchLagged(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	uut, _, _ := newTestStore(
+		t, encryption.CryptographyEngineParams{}, store.ProtectedKVStoreOptions{},
+	)
+
+	watchCtx, watchCancel := context.WithCancel(ctx)
+	defer watchCancel()
+	events, err := uut.Watch(watchCtx, "")
+	assert.Nil(err)
+
+	readEvents := func() []store.WatchEvent {
+		read := []store.WatchEvent{}
+		for {
+			select {
+			case event := <-events:
+				read = append(read, event)
+			case <-time.After(time.Millisecond * 50):
+				return read
+			}
+		}
+	}
+
+	// Write more changes than the subscription buffers, without reading them
+	versionIDs := []string{}
+	for idx := 0; idx < 40; idx++ {
+		_, version, err := uut.RecordKeyValue(
+			ctx, fmt.Sprintf("testkey%d", idx), []byte(uuid.NewString()), time.Now(), nil,
+		)
+		assert.Nil(err)
+		versionIDs = append(versionIDs, version.ID)
+	}
+
+	// The buffered events are followed by a single lagged event
+	read := readEvents()
+	if assert.Len(read, 33) {
+		for idx, event := range read[:32] {
+			assert.Equal(store.WatchEventTypeCreated, event.Type)
+			assert.Equal(versionIDs[idx], event.VersionID)
+		}
+		assert.Equal(store.WatchEventTypeLagged, read[32].Type)
+		assert.Empty(read[32].Key)
+	}
+
+	// Once caught up, events are delivered again
+	_, version, err := uut.RecordKeyValue(ctx, "testkey0", []byte(uuid.NewString()), time.Now(), nil)
+	assert.Nil(err)
+	read = readEvents()
+	if assert.Len(read, 1) {
+		assert.Equal(store.WatchEventTypeUpdated, read[0].Type)
+		assert.Equal(version.ID, read[0].VersionID)
+	}
+}
+
+// TestProtectedKVStoreVersionTTL verifies that expired versions can not be read.
+func TestProtectedKVStoreVersionTTL(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	uut, dbClient, _ := newTestStore(
+		t, encryption.CryptographyEngineParams{}, store.ProtectedKVStoreOptions{},
+	)
+
+	keyName := "credential"
+
+	// Version which has not expired
+	value1 := []byte(uuid.NewString())
+	_, ver1, err := uut.RecordKeyValueWithTTL(
+		ctx, keyName, value1, time.Now().Add(-2*time.Hour), 3*time.Hour, nil,
+	)
+	assert.Nil(err)
+	assert.NotNil(ver1.ExpiresAt)
+
+	latest, retrieved, err := uut.GetLatestValue(ctx, keyName, nil)
+	assert.Nil(err)
+	assert.Equal(ver1.ID, latest.ID)
+	assert.Equal(value1, retrieved)
+
+	// TTL must be positive
+	_, _, err = uut.RecordKeyValueWithTTL(
+		ctx, keyName, []byte(uuid.NewString()), time.Now(), -time.Second, nil,
+	)
+	assert.Error(err)
+
+	// Version which has expired
+	_, ver2, err := uut.RecordKeyValueWithTTL(
+		ctx, keyName, []byte(uuid.NewString()), time.Now().Add(-time.Minute), time.Second, nil,
+	)
+	assert.Nil(err)
+
+	_, _, err = uut.GetLatestValue(ctx, keyName, nil)
+	assert.ErrorIs(err, store.ErrVersionExpired)
+	_, err = uut.GetValueOfKeyAtVersionID(ctx, ver2.ID, nil)
+	assert.ErrorIs(err, store.ErrVersionExpired)
+	_, err = uut.GetValueOfKeyAtVersion(ctx, ver2, nil)
+	assert.ErrorIs(err, store.ErrVersionExpired)
+
+	// The newest version is the one without expiry
+	value3 := []byte(uuid.NewString())
+	_, ver3, err := uut.RecordKeyValue(ctx, keyName, value3, time.Now().Add(time.Minute), nil)
+	assert.Nil(err)
+	latest, retrieved, err = uut.GetLatestValue(ctx, keyName, nil)
+	assert.Nil(err)
+	assert.Equal(ver3.ID, latest.ID)
+	assert.Equal(value3, retrieved)
+
+	// Purge the expired version
+	assert.Nil(dbClient.UseDatabaseInTransaction(
+		ctx, func(ctx context.Context, dbClient db.Database) error {
+			purged, err := dbClient.PurgeExpiredVersions(ctx, time.Now())
+			assert.Nil(err)
+			assert.Equal(1, purged)
+			return err
+		},
+	))
+	_, versions, err := uut.ListKeyVersions(ctx, keyName, nil)
+	assert.Nil(err)
+	assert.Len(versions, 2)
+}
+
+// TestProtectedKVStoreExportKeyHistory verifies `ExportKeyHistory` writes every version of
+// a key, and only includes the decrypted values when requested.
+func TestProtectedKVStoreExportKeyHistory(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	uut, _, _ := newTestStore(
+		t, encryption.CryptographyEngineParams{}, store.ProtectedKVStoreOptions{},
+	)
+
+	keyName := "testkey1"
+	value1 := []byte(uuid.NewString())
+	value2 := []byte(uuid.NewString())
+	rec, ver1, err := uut.RecordKeyValue(ctx, keyName, value1, time.Now().Add(-time.Second), nil)
+	assert.Nil(err)
+	_, ver2, err := uut.RecordKeyValue(ctx, keyName, value2, time.Now(), nil)
+	assert.Nil(err)
+
+	// Export with plain text
+	var output bytes.Buffer
+	assert.Nil(uut.ExportKeyHistory(
+		ctx, keyName, &output, store.ExportOptions{ConfirmPlaintext: true}, nil,
+	))
+	var exported store.KeyHistoryExport
+	assert.Nil(json.Unmarshal(output.Bytes(), &exported))
+	assert.Equal(rec.ID, exported.Record.ID)
+	assert.Len(exported.Versions, 2)
+	assert.Equal(ver1.ID, exported.Versions[0].VersionID)
+	assert.Equal(value1, exported.Versions[0].Value)
+	assert.Equal(ver1.EncKeyID, exported.Versions[0].EncKeyID)
+	assert.Equal(ver2.ID, exported.Versions[1].VersionID)
+	assert.Equal(value2, exported.Versions[1].Value)
+
+	// Export without plain text
+	output.Reset()
+	assert.Nil(uut.ExportKeyHistory(ctx, keyName, &output, store.ExportOptions{}, nil))
+	exported = store.KeyHistoryExport{}
+	assert.Nil(json.Unmarshal(output.Bytes(), &exported))
+	assert.Len(exported.Versions, 2)
+	for _, version := range exported.Versions {
+		assert.Empty(version.Value)
+	}
+
+	// Unknown key
+	assert.Error(uut.ExportKeyHistory(ctx, uuid.NewString(), &output, store.ExportOptions{}, nil))
+}
+
+// TestProtectedKVStoreRecordBinding verifies that an encrypted value is bound to its
+// record, so a version replayed under a different record fails to decrypt.
+func TestProtectedKVStoreRecordBinding(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	uut, _, _ := newTestStore(
+		t, encryption.CryptographyEngineParams{}, store.ProtectedKVStoreOptions{},
+	)
+
+	value := []byte(uuid.NewString())
+	_, version1, err := uut.RecordKeyValue(ctx, "testkey1", value, time.Now(), nil)
+	assert.Nil(err)
+	assert.True(version1.AADBound)
+	record2, _, err := uut.RecordKeyValue(ctx, "testkey2", []byte(uuid.NewString()), time.Now(), nil)
+	assert.Nil(err)
+
+	retrieved, err := uut.GetValueOfKeyAtVersion(ctx, version1, nil)
+	assert.Nil(err)
+	assert.Equal(value, retrieved)
+
+	// Replay version 1 under record 2
+	replayed := version1
+	replayed.RecordID = record2.ID
+	_, err = uut.GetValueOfKeyAtVersion(ctx, replayed, nil)
+	assert.Error(err)
+}
+
+// TestProtectedKVStoreContextDeadline verifies that the deadline of the caller's context
+// is honored by the database operations of the store.
+func TestProtectedKVStoreContextDeadline(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	uut, _, _ := newTestStore(
+		t, encryption.CryptographyEngineParams{}, store.ProtectedKVStoreOptions{},
+	)
+
+	// The deadline passes before the operation starts
+	deadlineCtx, cancel := context.WithTimeout(ctx, time.Millisecond)
+	defer cancel()
+	<-deadlineCtx.Done()
+
+	_, _, err := uut.RecordKeyValue(deadlineCtx, "testkey1", []byte(uuid.NewString()), time.Now(), nil)
+	assert.ErrorIs(err, context.DeadlineExceeded)
+
+	// Nothing was recorded
+	keyCount, err := uut.CountKeys(ctx, nil)
+	assert.Nil(err)
+	assert.Equal(int64(0), keyCount)
+}
+
+// TestProtectedKVStoreMixedAEADTypes verifies that a store holding values encrypted with
+// keys of different AEAD algorithms decrypts each value with its key's algorithm.
+func TestProtectedKVStoreMixedAEADTypes(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	// Write a value with a XChaCha20-Poly1305 key
+	store1, dbClient, _ := newTestStore(
+		t, encryption.CryptographyEngineParams{}, store.ProtectedKVStoreOptions{},
+	)
+	certFile, keyFile := testRSAFiles(t)
+	value1 := []byte(uuid.NewString())
+	_, version1, err := store1.RecordKeyValue(ctx, "testkey1", value1, time.Now(), nil)
+	assert.Nil(err)
+
+	// Switch to AES256-GCM, and write a value with a new key
+	engine2, err := encryption.NewCryptographyEngine(ctx, encryption.CryptographyEngineParams{
+		Persistence:        dbClient,
+		PrimaryRSACertFile: certFile,
+		PrimaryRSAKeyFile:  keyFile,
+		AEADType:           models.AEADTypeAES256GCM,
+	})
+	assert.Nil(err)
+	aesKey, err := engine2.NewEncryptionKey(ctx, nil)
+	if err != nil {
+		t.Skipf("AES256-GCM not available on this platform: %s", err.Error())
+	}
+	assert.Equal(models.AEADTypeAES256GCM, aesKey.AEADType)
+	store2, err := store.NewProtectedKVStore(
+		ctx, dbClient, engine2, store.ProtectedKVStoreOptions{},
+	)
+	assert.Nil(err)
+	value2 := []byte(uuid.NewString())
+	_, version2, err := store2.RecordKeyValue(ctx, "testkey2", value2, time.Now(), nil)
+	assert.Nil(err)
+	assert.Equal(aesKey.ID, version2.EncKeyID)
+	assert.NotEqual(version1.EncKeyID, version2.EncKeyID)
+
+	// Both values are readable through one store
+	retrieved, err := store2.GetValueOfKeyAtVersionID(ctx, version1.ID, nil)
+	assert.Nil(err)
+	assert.Equal(value1, retrieved)
+	retrieved, err = store2.GetValueOfKeyAtVersionID(ctx, version2.ID, nil)
+	assert.Nil(err)
+	assert.Equal(value2, retrieved)
+
+	// The stored key records its algorithm
+	key1, err := engine2.GetEncryptionKey(ctx, version1.EncKeyID, nil)
+	assert.Nil(err)
+	assert.Equal(models.AEADTypeXChaCha20Poly1305, key1.AEADType)
+}
+
+// TestProtectedKVStoreRedistributeVersions verifies that versions encrypted with one key
+// are spread evenly across several keys, and remain readable.
+func TestProtectedKVStoreRedistributeVersions(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	uut, dbClient, engine := newTestStore(
+		t, encryption.CryptographyEngineParams{}, store.ProtectedKVStoreOptions{},
+	)
+
+	// Write four versions with the working key
+	values := map[string][]byte{}
+	sourceKeyID := ""
+	for _, key := range []string{"testkey1", "testkey1", "testkey2", "testkey2"} {
+		value := []byte(uuid.NewString())
+		_, version, err := uut.RecordKeyValue(ctx, key, value, time.Now(), nil)
+		assert.Nil(err)
+		values[version.ID] = value
+		sourceKeyID = version.EncKeyID
+	}
+
+	targetKey1, err := engine.NewEncryptionKey(ctx, nil)
+	assert.Nil(err)
+	targetKey2, err := engine.NewEncryptionKey(ctx, nil)
+	assert.Nil(err)
+
+	moved, err := engine.RedistributeVersions(
+		ctx, sourceKeyID, []string{targetKey1.ID, targetKey2.ID}, nil,
+	)
+	assert.Nil(err)
+	assert.Equal(4, moved)
+
+	// The versions are split evenly between the target keys
+	countVersions := func(keyID string) int64 {
+		var count int64
+		assert.Nil(dbClient.UseDatabase(ctx, func(ctx context.Context, dbClient db.Database) error {
+			var err error
+			count, err = dbClient.CountRecordVersions(
+				ctx, db.RecordVersionQueryFilter{TargetEncKeyID: &keyID},
+			)
+			return err
+		}))
+		return count
+	}
+	assert.Equal(int64(0), countVersions(sourceKeyID))
+	assert.Equal(int64(2), countVersions(targetKey1.ID))
+	assert.Equal(int64(2), countVersions(targetKey2.ID))
+
+	// All values are still readable
+	for versionID, value := range values {
+		retrieved, err := uut.GetValueOfKeyAtVersionID(ctx, versionID, nil)
+		assert.Nil(err)
+		assert.Equal(value, retrieved)
+	}
+
+	// Repeat is a NOOP
+	moved, err = engine.RedistributeVersions(
+		ctx, sourceKeyID, []string{targetKey1.ID, targetKey2.ID}, nil,
+	)
+	assert.Nil(err)
+	assert.Equal(0, moved)
+}
+
+// TestCryptoEngineVerifyKeyVersionsDecryptable verifies the versions of a key which fail to
+// decrypt are reported.
+func TestCryptoEngineVerifyKeyVersionsDecryptable(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	uut, dbClient, engine := newTestStore(
+		t, encryption.CryptographyEngineParams{}, store.ProtectedKVStoreOptions{},
+	)
+
+	versions := []models.RecordVersion{}
+	for _, key := range []string{"testkey1", "testkey1", "testkey2", "testkey3"} {
+		_, version, err := uut.RecordKeyValue(ctx, key, []byte(uuid.NewString()), time.Now(), nil)
+		assert.Nil(err)
+		versions = append(versions, version)
+	}
+	keyID := versions[0].EncKeyID
+
+	ok, failed, err := engine.VerifyKeyVersionsDecryptable(ctx, keyID, nil)
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Empty(failed)
+
+	// Corrupt one version's encrypted value
+	corrupted := append([]byte{}, versions[2].EncValue...)
+	corrupted[len(corrupted)-1] ^= 0xff
+	assert.Nil(dbClient.RawDB().WithContext(ctx).
+		Model(&db.RecordVersionDBEntry{}).
+		Where("id = ?", versions[2].ID).
+		Update("enc_value", corrupted).Error)
+
+	ok, failed, err = engine.VerifyKeyVersionsDecryptable(ctx, keyID, nil)
+	assert.Nil(err)
+	assert.False(ok)
+	assert.Equal([]string{versions[2].ID}, failed)
+
+	// A key with no versions has nothing to fail
+	emptyKey, err := engine.NewEncryptionKey(ctx, nil)
+	assert.Nil(err)
+	ok, failed, err = engine.VerifyKeyVersionsDecryptable(ctx, emptyKey.ID, nil)
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Empty(failed)
+
+	_, _, err = engine.VerifyKeyVersionsDecryptable(ctx, uuid.NewString(), nil)
+	assert.ErrorIs(err, db.ErrEncryptionKeyNotFound)
+}
+
+// TestProtectedKVStoreInMemory verifies that in-memory stores are usable, and are
+// separate from each other.
+func TestProtectedKVStoreInMemory(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	certFile, keyFile := testRSAFiles(t)
+
+	store1, err := haven.NewInMemoryProtectedKVStore(ctx, certFile, keyFile)
+	assert.Nil(err)
+	store2, err := haven.NewInMemoryProtectedKVStore(ctx, certFile, keyFile)
+	assert.Nil(err)
+
+	value := []byte(uuid.NewString())
+	_, _, err = store1.RecordKeyValue(ctx, "testkey1", value, time.Now(), nil)
+	assert.Nil(err)
+
+	_, retrieved, err := store1.GetLatestValue(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.Equal(value, retrieved)
+
+	// The other store does not see the value
+	count, err := store2.CountKeys(ctx, nil)
+	assert.Nil(err)
+	assert.Equal(int64(0), count)
+
+	assert.Nil(store1.Close())
+	assert.Nil(store2.Close())
+}
+
+// TestProtectedKVStoreEncryptedBackup verifies that an encrypted backup of one store can be
+// restored into another store using the same RSA key pair.
+func TestProtectedKVStoreEncryptedBackup(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	certFile, keyFile := testRSAFiles(t)
+
+	storeA, err := haven.NewInMemoryProtectedKVStore(ctx, certFile, keyFile)
+	assert.Nil(err)
+
+	values := map[string][]byte{}
+	for _, key := range []string{"testkey1", "testkey1", "testkey2"} {
+		value := []byte(uuid.NewString())
+		_, version, err := storeA.RecordKeyValue(ctx, key, value, time.Now(), nil)
+		assert.Nil(err)
+		values[version.ID] = value
+	}
+
+	var backup bytes.Buffer
+	assert.Nil(storeA.ExportEncrypted(ctx, &backup, nil))
+
+	// The backup holds no plain text values
+	for _, value := range values {
+		assert.NotContains(backup.String(), string(value))
+	}
+
+	storeB, err := haven.NewInMemoryProtectedKVStore(ctx, certFile, keyFile)
+	assert.Nil(err)
+	assert.Nil(storeB.ImportEncrypted(ctx, bytes.NewReader(backup.Bytes()), nil))
+
+	count, err := storeB.CountKeys(ctx, nil)
+	assert.Nil(err)
+	assert.Equal(int64(2), count)
+	_, versions, err := storeB.ListKeyVersions(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.Len(versions, 2)
+	for versionID, value := range values {
+		retrieved, err := storeB.GetValueOfKeyAtVersionID(ctx, versionID, nil)
+		assert.Nil(err)
+		assert.Equal(value, retrieved)
+	}
+
+	// A store which already holds records can not be restored into
+	err = storeB.ImportEncrypted(ctx, bytes.NewReader(backup.Bytes()), nil)
+	assert.ErrorIs(err, store.ErrBackupTargetNotEmpty)
+
+	assert.Nil(storeA.Close())
+	assert.Nil(storeB.Close())
+}
+
+// TestProtectedKVStorePlaintextImportExport verifies plaintext values can be imported, and
+// exported again once decrypted.
+func TestProtectedKVStorePlaintextImportExport(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	uut, dbClient, engine := newTestStore(
+		t, encryption.CryptographyEngineParams{}, store.ProtectedKVStoreOptions{},
+	)
+
+	entries := []store.PlaintextEntry{
+		{Key: "app/key1", Value: []byte(uuid.NewString())},
+		{Key: "app/key2", Value: []byte(uuid.NewString())},
+		{Key: "other/key1", Value: []byte(uuid.NewString())},
+	}
+	var input bytes.Buffer
+	encoder := json.NewEncoder(&input)
+	for _, entry := range entries {
+		assert.Nil(encoder.Encode(entry))
+	}
+
+	imported, err := uut.ImportPlaintext(ctx, &input, nil)
+	assert.Nil(err)
+	assert.Equal(3, imported)
+
+	readExport := func(output *bytes.Buffer) []store.PlaintextEntry {
+		exported := []store.PlaintextEntry{}
+		decoder := json.NewDecoder(output)
+		for decoder.More() {
+			var entry store.PlaintextEntry
+			assert.Nil(decoder.Decode(&entry))
+			exported = append(exported, entry)
+		}
+		return exported
+	}
+
+	// Export all keys
+	var output bytes.Buffer
+	assert.Nil(uut.ExportPlaintext(ctx, &output, "", nil))
+	assert.Equal(entries, readExport(&output))
+
+	// Export keys with a prefix
+	output.Reset()
+	assert.Nil(uut.ExportPlaintext(ctx, &output, "app/", nil))
+	assert.Equal(entries[:2], readExport(&output))
+
+	// Malformed input is rejected
+	imported, err = uut.ImportPlaintext(ctx, bytes.NewBufferString(`{"key": ""}`), nil)
+	assert.Error(err)
+	assert.Equal(0, imported)
+
+	// Values encrypted with a key since deactivated are exported
+	version, _, err := uut.GetLatestValue(ctx, "app/key1", nil)
+	assert.Nil(err)
+	_, err = engine.NewEncryptionKey(ctx, nil)
+	assert.Nil(err)
+	_, err = engine.MarkEncryptionKeyInactive(ctx, version.EncKeyID, nil)
+	assert.Nil(err)
+	entries[1].Value = []byte(uuid.NewString())
+	_, rotated, err := uut.RecordKeyValue(ctx, entries[1].Key, entries[1].Value, time.Now(), nil)
+	assert.Nil(err)
+	assert.NotEqual(version.EncKeyID, rotated.EncKeyID)
+	output.Reset()
+	assert.Nil(uut.ExportPlaintext(ctx, &output, "", nil))
+	assert.Equal(entries, readExport(&output))
+
+	// Nothing is written if a value can not be decrypted
+	corrupted := append([]byte{}, version.EncValue...)
+	corrupted[len(corrupted)-1] ^= 0xff
+	assert.Nil(dbClient.RawDB().WithContext(ctx).
+		Model(&db.RecordVersionDBEntry{}).
+		Where("id = ?", version.ID).
+		Update("enc_value", corrupted).Error)
+	output.Reset()
+	assert.Error(uut.ExportPlaintext(ctx, &output, "", nil))
+	assert.Zero(output.Len())
+}
+
+// TestProtectedKVStoreTrackReads verifies that reading a value updates the last read time
+// of its record.
+func TestProtectedKVStoreTrackReads(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	uut, dbClient, _ := newTestStore(
+		t, encryption.CryptographyEngineParams{}, store.ProtectedKVStoreOptions{TrackReads: true},
+	)
+
+	record1, _, err := uut.RecordKeyValue(ctx, "testkey1", []byte(uuid.NewString()), time.Now(), nil)
+	assert.Nil(err)
+	assert.Nil(record1.LastReadAt)
+	record2, _, err := uut.RecordKeyValue(ctx, "testkey2", []byte(uuid.NewString()), time.Now(), nil)
+	assert.Nil(err)
+
+	cutoff := time.Now().UTC()
+
+	_, _, err = uut.GetLatestValue(ctx, "testkey1", nil)
+	assert.Nil(err)
+
+	record1, _, err = uut.ListKeyVersions(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.NotNil(record1.LastReadAt)
+	if record1.LastReadAt != nil {
+		assert.False(record1.LastReadAt.Before(cutoff))
+	}
+
+	// Only the unread record is listed
+	assert.Nil(dbClient.UseDatabase(ctx, func(ctx context.Context, dbClient db.Database) error {
+		records, err := dbClient.ListRecordsNotReadSince(ctx, cutoff)
+		assert.Nil(err)
+		assert.Len(records, 1)
+		if len(records) == 1 {
+			assert.Equal(record2.ID, records[0].ID)
+		}
+		return err
+	}))
+}
+
+// TestProtectedKVStoreValueAtTimestamp verifies point-in-time reads of a key.
+func TestProtectedKVStoreValueAtTimestamp(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	certFile, keyFile := testRSAFiles(t)
+
+	uut, err := haven.NewInMemoryProtectedKVStore(ctx, certFile, keyFile)
+	assert.Nil(err)
+
+	baseTime := time.Now().UTC().Add(-time.Hour)
+	values := [][]byte{}
+	versions := []models.RecordVersion{}
+	for idx := 0; idx < 3; idx++ {
+		value := []byte(uuid.NewString())
+		_, version, err := uut.RecordKeyValue(
+			ctx, "testkey1", value, baseTime.Add(time.Duration(idx)*time.Minute), nil,
+		)
+		assert.Nil(err)
+		values = append(values, value)
+		versions = append(versions, version)
+	}
+
+	// No version before the first
+	_, _, err = uut.GetValueOfKeyAtTimestamp(ctx, "testkey1", baseTime.Add(-time.Second), nil)
+	assert.ErrorIs(err, store.ErrVersionNotFound)
+
+	testCases := []struct {
+		at       time.Time
+		expected int
+	}{
+		{at: baseTime, expected: 0},
+		{at: baseTime.Add(time.Minute + time.Second), expected: 1},
+		{at: baseTime.Add(2 * time.Minute), expected: 2},
+		{at: time.Now(), expected: 2},
+	}
+	for _, testCase := range testCases {
+		value, version, err := uut.GetValueOfKeyAtTimestamp(ctx, "testkey1", testCase.at, nil)
+		assert.Nil(err)
+		assert.Equal(values[testCase.expected], value)
+		assert.Equal(versions[testCase.expected].ID, version.ID)
+	}
+
+	// Unknown key
+	_, _, err = uut.GetValueOfKeyAtTimestamp(ctx, "testkey2", time.Now(), nil)
+	assert.ErrorIs(err, db.ErrRecordNotFound)
+}
+
+func TestProtectedKVStoreRecordBudget(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	defineStore := func(options store.ProtectedKVStoreOptions) store.ProtectedKVStore {
+		uut, _, _ := newTestStore(t, encryption.CryptographyEngineParams{}, options)
+		return uut
+	}
+
+	// Values of the same size encrypt to the same size
+	baseTime := time.Now().UTC().Add(-time.Hour)
+	fillKey := func(uut store.ProtectedKVStore) []models.RecordVersion {
+		versions := []models.RecordVersion{}
+		for idx := 0; idx < 3; idx++ {
+			_, version, err := uut.RecordKeyValue(
+				ctx,
+				"testkey1",
+				[]byte(uuid.NewString()),
+				baseTime.Add(time.Duration(idx)*time.Minute),
+				nil,
+			)
+			assert.Nil(err)
+			versions = append(versions, version)
+		}
+		return versions
+	}
+	probe := defineStore(store.ProtectedKVStoreOptions{})
+	_, probeVersion, err := probe.RecordKeyValue(
+		ctx, "probe", []byte(uuid.NewString()), time.Now(), nil,
+	)
+	assert.Nil(err)
+	budget := 3 * int64(len(probeVersion.EncValue))
+
+	// Writes beyond the budget are rejected
+	{
+		uut := defineStore(store.ProtectedKVStoreOptions{MaxCumulativeBytesPerRecord: budget})
+		versions := fillKey(uut)
+		_, _, err := uut.RecordKeyValue(ctx, "testkey1", []byte(uuid.NewString()), time.Now(), nil)
+		assert.ErrorIs(err, store.ErrRecordBudgetExceeded)
+		_, listed, err := uut.ListKeyVersions(ctx, "testkey1", nil)
+		assert.Nil(err)
+		assert.Len(listed, len(versions))
+
+		// Other keys have their own budget
+		_, _, err = uut.RecordKeyValue(ctx, "testkey2", []byte(uuid.NewString()), time.Now(), nil)
+		assert.Nil(err)
+	}
+
+	// Writes beyond the budget prune the oldest versions
+	{
+		uut := defineStore(store.ProtectedKVStoreOptions{
+			MaxCumulativeBytesPerRecord: budget, PruneToFitBudget: true,
+		})
+		versions := fillKey(uut)
+		_, newVersion, err := uut.RecordKeyValue(
+			ctx, "testkey1", []byte(uuid.NewString()), time.Now(), nil,
+		)
+		assert.Nil(err)
+		_, listed, err := uut.ListKeyVersions(ctx, "testkey1", nil)
+		assert.Nil(err)
+		listedIDs := []string{}
+		for _, version := range listed {
+			listedIDs = append(listedIDs, version.ID)
+		}
+		assert.ElementsMatch(
+			[]string{versions[1].ID, versions[2].ID, newVersion.ID}, listedIDs,
+		)
+
+		// A value larger than the whole budget is rejected
+		_, _, err = uut.RecordKeyValue(
+			ctx, "testkey1", make([]byte, budget), time.Now(), nil,
+		)
+		assert.ErrorIs(err, store.ErrRecordBudgetExceeded)
+	}
+}
+
+func TestProtectedKVStoreConcurrentNewKey(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	certFile, keyFile := testRSAFiles(t)
+
+	// Writers wait for the DB lock instead of failing
+	dialector := sqlite.Open(
+		fmt.Sprintf("%s?_foreign_keys=on&_busy_timeout=5000&_txlock=immediate", testDB),
+	)
+	dbClient, err := db.NewConnection(dialector, logger.Error)
+	assert.Nil(err)
+	assert.Nil(dbClient.RunSQLInTransaction(ctx, db.DefineTables))
+
+	uut, err := haven.NewProtectedKVStore(
+		ctx,
+		dialector,
+		logger.Error,
+		certFile,
+		keyFile,
+		store.ProtectedKVStoreOptions{},
+	)
+	assert.Nil(err)
+
+	// Record the same new key concurrently
+	writers := 4
+	recordIDs := make([]string, writers)
+	wg := sync.WaitGroup{}
+	for idx := 0; idx < writers; idx++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			record, _, err := uut.RecordKeyValue(
+				ctx, "testkey1", []byte(uuid.NewString()), time.Now(), nil,
+			)
+			assert.Nil(err)
+			recordIDs[idx] = record.ID
+		}(idx)
+	}
+	wg.Wait()
+
+	for idx := 1; idx < writers; idx++ {
+		assert.Equal(recordIDs[0], recordIDs[idx])
+	}
+	record, versions, err := uut.ListKeyVersions(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.Equal(recordIDs[0], record.ID)
+	assert.Len(versions, writers)
+}
+
+func TestProtectedKVStoreConcurrentStart(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	testDB := fmt.Sprintf("/tmp/haven_ut_%s.db", ulid.Make().String())
+	log.WithField("db", testDB).Debug("Test database")
+
+	certFile, keyFile := testRSAFiles(t)
+
+	// Writers wait for the DB lock instead of failing
+	dialector := sqlite.Open(fmt.Sprintf("%s?_foreign_keys=on&_busy_timeout=5000", testDB))
+	dbClient, err := db.NewConnection(dialector, logger.Error)
+	assert.Nil(err)
+	assert.Nil(dbClient.RunSQLInTransaction(ctx, db.DefineTables))
+
+	// Start several stores against the empty database at once
+	wg := sync.WaitGroup{}
+	for idx := 0; idx < 4; idx++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := haven.NewProtectedKVStore(
+				ctx, dialector, logger.Error, certFile, keyFile, store.ProtectedKVStoreOptions{},
+			)
+			assert.Nil(err)
+		}()
+	}
+	wg.Wait()
+
+	// Only one working key was defined, and it is active
+	assert.Nil(dbClient.UseDatabase(ctx, func(ctx context.Context, dbClient db.Database) error {
+		keys, err := dbClient.ListEncryptionKeys(ctx, db.EncryptionKeyQueryFilter{})
+		assert.Nil(err)
+		assert.Len(keys, 1)
+		activeKeys, err := dbClient.ListEncryptionKeys(ctx, db.EncryptionKeyQueryFilter{
+			TargetState: []models.EncryptionKeyStateENUMType{models.EncryptionKeyStateActive},
+		})
+		assert.Nil(err)
+		assert.Len(activeKeys, 1)
+		return err
+	}))
+}
+
+func TestProtectedKVStoreWorkingKeyPolicy(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	_, dbClient := newTestDB(t)
+
+	certFile, keyFile := testRSAFiles(t)
+
+	engine, err := encryption.NewCryptographyEngine(ctx, encryption.CryptographyEngineParams{
+		Persistence:        dbClient,
+		PrimaryRSACertFile: certFile,
+		PrimaryRSAKeyFile:  keyFile,
+	})
+	assert.Nil(err)
+
+	// workingKeyOf start a store with the policy, and report the key its writes use
+	workingKeyOf := func(policy store.WorkingKeyPolicy) (string, error) {
+		uut, err := store.NewProtectedKVStore(
+			ctx, dbClient, engine, store.ProtectedKVStoreOptions{WorkingKeyPolicy: policy},
+		)
+		if err != nil {
+			return "", err
+		}
+		_, version, err := uut.RecordKeyValue(ctx, "testkey1", []byte(uuid.NewString()), time.Now(), nil)
+		assert.Nil(err)
+		return version.EncKeyID, nil
+	}
+
+	// Newest key, with one defined on the empty database
+	key1, err := workingKeyOf(store.WorkingKeyPolicy{})
+	assert.Nil(err)
+	keyID, err := workingKeyOf(store.UseNewestKey())
+	assert.Nil(err)
+	assert.Equal(key1, keyID)
+
+	// Always define a new key
+	key2, err := workingKeyOf(store.AlwaysCreateNewKey())
+	assert.Nil(err)
+	assert.NotEqual(key1, key2)
+	keyID, err = workingKeyOf(store.UseNewestKey())
+	assert.Nil(err)
+	assert.Equal(key2, keyID)
+
+	// Pin a specific key
+	keyID, err = workingKeyOf(store.UseSpecificKey(key1))
+	assert.Nil(err)
+	assert.Equal(key1, keyID)
+
+	// The pinned key must exist, and be active
+	_, err = workingKeyOf(store.UseSpecificKey(uuid.NewString()))
+	assert.Error(err)
+	_, err = workingKeyOf(store.UseSpecificKey(""))
+	assert.Error(err)
+	_, err = engine.MarkEncryptionKeyInactive(ctx, key1, nil)
+	assert.Nil(err)
+	_, err = workingKeyOf(store.UseSpecificKey(key1))
+	assert.Error(err)
+
+	// Unknown policy
+	_, err = workingKeyOf(store.WorkingKeyPolicy{Mode: "UNKNOWN"})
+	assert.Error(err)
+
+	assert.Nil(dbClient.UseDatabase(ctx, func(ctx context.Context, dbClient db.Database) error {
+		keys, err := dbClient.ListEncryptionKeys(ctx, db.EncryptionKeyQueryFilter{})
+		assert.Nil(err)
+		assert.Len(keys, 2)
+		return err
+	}))
+}
+
+func TestProtectedKVStoreRefreshWorkingKey(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	uut, _, engine := newTestStore(
+		t, encryption.CryptographyEngineParams{}, store.ProtectedKVStoreOptions{},
+	)
+
+	_, version1, err := uut.RecordKeyValue(ctx, "testkey1", []byte(uuid.NewString()), time.Now(), nil)
+	assert.Nil(err)
+
+	// Deactivate the working key behind the store's back; the next write selects a new key
+	_, err = engine.MarkEncryptionKeyInactive(ctx, version1.EncKeyID, nil)
+	assert.Nil(err)
+	value2 := []byte(uuid.NewString())
+	_, version2, err := uut.RecordKeyValue(ctx, "testkey1", value2, time.Now(), nil)
+	assert.Nil(err)
+	assert.NotEqual(version1.EncKeyID, version2.EncKeyID)
+	readVersion, readValue, err := uut.GetLatestValue(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.Equal(version2.ID, readVersion.ID)
+	assert.Equal(value2, readValue)
+
+	// A newer key is only used once the working key is refreshed
+	newKey, err := engine.NewEncryptionKey(ctx, nil)
+	assert.Nil(err)
+	_, version3, err := uut.RecordKeyValue(ctx, "testkey1", []byte(uuid.NewString()), time.Now(), nil)
+	assert.Nil(err)
+	assert.Equal(version2.EncKeyID, version3.EncKeyID)
+	assert.Nil(uut.RefreshWorkingKey(ctx, nil))
+	_, version4, err := uut.RecordKeyValue(ctx, "testkey1", []byte(uuid.NewString()), time.Now(), nil)
+	assert.Nil(err)
+	assert.Equal(newKey.ID, version4.EncKeyID)
+}
+
+func TestProtectedKVStoreMetrics(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	registry := prometheus.NewRegistry()
+	uut, _, _ := newTestStore(
+		t,
+		encryption.CryptographyEngineParams{Metrics: registry},
+		store.ProtectedKVStoreOptions{Metrics: registry},
+	)
+
+	value := []byte(uuid.NewString())
+	_, _, err := uut.RecordKeyValue(ctx, "testkey1", value, time.Now(), nil)
+	assert.Nil(err)
+	_, readValue, err := uut.GetLatestValue(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.Equal(value, readValue)
+	_, _, err = uut.GetLatestValue(ctx, "unknown", nil)
+	assert.Error(err)
+
+	// The working key is cached when it is defined, so both the write and the read hit
+	assert.Nil(testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP haven_crypto_key_cache_lookup_total Encryption key cache lookups
+# TYPE haven_crypto_key_cache_lookup_total counter
+haven_crypto_key_cache_lookup_total{result="hit"} 2
+# HELP haven_store_operation_total Store operations performed
+# TYPE haven_store_operation_total counter
+haven_store_operation_total{operation="get_latest",success="false"} 1
+haven_store_operation_total{operation="get_latest",success="true"} 1
+haven_store_operation_total{operation="record",success="true"} 1
+`), "haven_crypto_key_cache_lookup_total", "haven_store_operation_total"))
+	count, err := testutil.GatherAndCount(registry, "haven_store_transaction_duration_seconds")
+	assert.Nil(err)
+	assert.Equal(2, count)
+}
+
+func TestProtectedKVStoreGetKeyDetail(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	uut, dbClient, _ := newTestStore(
+		t, encryption.CryptographyEngineParams{}, store.ProtectedKVStoreOptions{},
+	)
+
+	var versionIDs []string
+	var value []byte
+	for idx := 0; idx < 3; idx++ {
+		value = []byte(uuid.NewString())
+		_, version, err := uut.RecordKeyValue(ctx, "testkey1", value, time.Now(), nil)
+		assert.Nil(err)
+		versionIDs = append([]string{version.ID}, versionIDs...)
+	}
+
+	detail, err := uut.GetKeyDetail(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.Equal("testkey1", detail.Record.Name)
+	assert.Equal(value, detail.LatestValue)
+	assert.Len(detail.Versions, 3)
+	for idx, version := range detail.Versions {
+		assert.Equal(versionIDs[idx], version.ID)
+		assert.Empty(version.EncValue)
+	}
+
+	// The history remains readable once the newest version expires
+	_, _, err = uut.RecordKeyValueWithTTL(
+		ctx, "testkey1", []byte(uuid.NewString()), time.Now(), time.Millisecond, nil,
+	)
+	assert.Nil(err)
+	time.Sleep(10 * time.Millisecond)
+	detail, err = uut.GetKeyDetail(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.Nil(detail.LatestValue)
+	assert.Len(detail.Versions, 4)
+
+	_, err = uut.GetKeyDetail(ctx, "unknown", nil)
+	assert.ErrorIs(err, db.ErrRecordNotFound)
+
+	// A key without any versions
+	assert.Nil(dbClient.UseDatabase(ctx, func(ctx context.Context, dbClient db.Database) error {
+		_, err := dbClient.DefineNewRecord(ctx, "", "testkey2")
+		return err
+	}))
+	_, err = uut.GetKeyDetail(ctx, "testkey2", nil)
+	assert.ErrorIs(err, store.ErrVersionNotFound)
+	_, _, err = uut.GetLatestValue(ctx, "testkey2", nil)
+	assert.ErrorIs(err, store.ErrVersionNotFound)
+}
+
+func TestProtectedKVStoreTracing(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	uut, _, engine := newTestStore(
+		t, encryption.CryptographyEngineParams{}, store.ProtectedKVStoreOptions{},
+	)
+
+	// Drop the cached working key, so the write has to decrypt it again
+	engine.Shutdown()
+
+	value := uuid.NewString()
+	record, version, err := uut.RecordKeyValue(ctx, "testkey1", []byte(value), time.Now(), nil)
+	assert.Nil(err)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+		// The value is never recorded
+		for _, attr := range span.Attributes() {
+			assert.NotEqual(value, attr.Value.Emit())
+		}
+	}
+	assert.Len(spans, 4)
+
+	attributesOf := func(span sdktrace.ReadOnlySpan) map[string]string {
+		attrs := map[string]string{}
+		for _, attr := range span.Attributes() {
+			attrs[string(attr.Key)] = attr.Value.Emit()
+		}
+		return attrs
+	}
+
+	rootSpan, ok := spans["store.RecordKeyValue"]
+	assert.True(ok)
+	assert.Equal(map[string]string{
+		"haven.record_id": record.ID, "haven.encryption_key_id": version.EncKeyID,
+	}, attributesOf(rootSpan))
+
+	encryptSpan, ok := spans["encryption.EncryptData"]
+	assert.True(ok)
+	assert.Equal(rootSpan.SpanContext().SpanID(), encryptSpan.Parent().SpanID())
+	assert.Equal(
+		map[string]string{"haven.encryption_key_id": version.EncKeyID},
+		attributesOf(encryptSpan),
+	)
+
+	unwrapSpan, ok := spans["encryption.unwrapKeyMaterial"]
+	assert.True(ok)
+	assert.Equal(encryptSpan.SpanContext().SpanID(), unwrapSpan.Parent().SpanID())
+
+	insertSpan, ok := spans["db.DefineNewVersionForRecord"]
+	assert.True(ok)
+	assert.Equal(rootSpan.SpanContext().SpanID(), insertSpan.Parent().SpanID())
+	assert.Equal(map[string]string{
+		"haven.record_id": record.ID, "haven.encryption_key_id": version.EncKeyID,
+	}, attributesOf(insertSpan))
+}
+
+func TestProtectedKVStoreRekeyRecord(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	uut, _, engine := newTestStore(
+		t, encryption.CryptographyEngineParams{}, store.ProtectedKVStoreOptions{},
+	)
+
+	// Write versions of the key under two different encryption keys
+	values := map[string][]byte{}
+	oldKeys := map[string]bool{}
+	for idx := 0; idx < 2; idx++ {
+		if idx > 0 {
+			_, err := engine.NewEncryptionKey(ctx, nil)
+			assert.Nil(err)
+			assert.Nil(uut.RefreshWorkingKey(ctx, nil))
+		}
+		value := []byte(uuid.NewString())
+		_, version, err := uut.RecordKeyValue(ctx, "testkey1", value, time.Now(), nil)
+		assert.Nil(err)
+		values[version.ID] = value
+		oldKeys[version.EncKeyID] = true
+	}
+	assert.Len(oldKeys, 2)
+
+	// Move to a new working key
+	workingKey, err := engine.NewEncryptionKey(ctx, nil)
+	assert.Nil(err)
+	assert.Nil(uut.RefreshWorkingKey(ctx, nil))
+
+	rekeyed, err := uut.RekeyRecord(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.Equal(2, rekeyed)
+
+	_, versions, err := uut.ListKeyVersions(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.Len(versions, 2)
+	for _, version := range versions {
+		assert.Equal(workingKey.ID, version.EncKeyID)
+		value, err := uut.GetValueOfKeyAtVersionID(ctx, version.ID, nil)
+		assert.Nil(err)
+		assert.Equal(values[version.ID], value)
+	}
+
+	// Versions already on the working key are skipped
+	rekeyed, err = uut.RekeyRecord(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.Equal(0, rekeyed)
+
+	_, err = uut.RekeyRecord(ctx, "unknown", nil)
+	assert.ErrorIs(err, db.ErrRecordNotFound)
+}
+
+func TestProtectedKVStoreReencryptRecord(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	uut, _, engine := newTestStore(
+		t, encryption.CryptographyEngineParams{}, store.ProtectedKVStoreOptions{},
+	)
+
+	// Write versions of the key under two different encryption keys
+	values := map[string][]byte{}
+	for idx := 0; idx < 2; idx++ {
+		if idx > 0 {
+			_, err := engine.NewEncryptionKey(ctx, nil)
+			assert.Nil(err)
+			assert.Nil(uut.RefreshWorkingKey(ctx, nil))
+		}
+		value := []byte(uuid.NewString())
+		_, version, err := uut.RecordKeyValue(ctx, "testkey1", value, time.Now(), nil)
+		assert.Nil(err)
+		values[version.ID] = value
+	}
+
+	// Consolidate onto a key which is not the working key
+	targetKey, err := engine.NewEncryptionKey(ctx, nil)
+	assert.Nil(err)
+
+	reencrypted, err := uut.ReencryptRecord(ctx, "testkey1", targetKey.ID, nil)
+	assert.Nil(err)
+	assert.Equal(2, reencrypted)
+
+	_, versions, err := uut.ListKeyVersions(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.Len(versions, 2)
+	for _, version := range versions {
+		assert.Equal(targetKey.ID, version.EncKeyID)
+		value, err := uut.GetValueOfKeyAtVersionID(ctx, version.ID, nil)
+		assert.Nil(err)
+		assert.Equal(values[version.ID], value)
+	}
+
+	// Versions already on the target key are skipped
+	reencrypted, err = uut.ReencryptRecord(ctx, "testkey1", targetKey.ID, nil)
+	assert.Nil(err)
+	assert.Equal(0, reencrypted)
+
+	// The target key must be active
+	_, err = engine.MarkEncryptionKeyInactive(ctx, targetKey.ID, nil)
+	assert.Nil(err)
+	_, err = uut.ReencryptRecord(ctx, "testkey1", targetKey.ID, nil)
+	assert.ErrorIs(err, encryption.ErrKeyNotActive)
+
+	_, err = uut.ReencryptRecord(ctx, "testkey1", uuid.NewString(), nil)
+	assert.Error(err)
+}
+
+func TestProtectedKVStoreNamespaces(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	certFile, keyFile := testRSAFiles(t)
+
+	uut, err := haven.NewInMemoryProtectedKVStore(ctx, certFile, keyFile)
+	assert.Nil(err)
+	defer func() {
+		assert.Nil(uut.Close())
+	}()
+
+	app1Ctx := store.WithNamespace(ctx, "app1")
+	app2Ctx := store.WithNamespace(ctx, "app2")
+
+	app1Events, err := uut.Watch(app1Ctx, "")
+	assert.Nil(err)
+
+	// The same key in different namespaces holds different values
+	values := map[string][]byte{}
+	for _, namespaceCtx := range []context.Context{ctx, app1Ctx, app2Ctx} {
+		value := []byte(uuid.NewString())
+		record, _, err := uut.RecordKeyValue(namespaceCtx, "db/password", value, time.Now(), nil)
+		assert.Nil(err)
+		assert.Equal(store.NamespaceFromContext(namespaceCtx), record.Namespace)
+		values[record.Namespace] = value
+	}
+	_, _, err = uut.RecordKeyValue(app1Ctx, "db/user", []byte("user"), time.Now(), nil)
+	assert.Nil(err)
+
+	for _, namespaceCtx := range []context.Context{ctx, app1Ctx, app2Ctx} {
+		_, value, err := uut.GetLatestValue(namespaceCtx, "db/password", nil)
+		assert.Nil(err)
+		assert.Equal(values[store.NamespaceFromContext(namespaceCtx)], value)
+	}
+
+	// Listing is scoped to the namespace
+	keys, err := uut.ListKeys(app1Ctx, "db/", nil)
+	assert.Nil(err)
+	assert.Len(keys, 2)
+	assert.Equal("db/password", keys[0].Name)
+	assert.Equal("db/user", keys[1].Name)
+	keys, err = uut.ListKeys(app2Ctx, "", nil)
+	assert.Nil(err)
+	assert.Len(keys, 1)
+	count, err := uut.CountKeys(ctx, nil)
+	assert.Nil(err)
+	assert.Equal(int64(1), count)
+
+	// Deleting the key of one namespace leaves the others
+	assert.Nil(uut.DeleteKey(app2Ctx, "db/password", nil))
+	_, _, err = uut.GetLatestValue(app2Ctx, "db/password", nil)
+	assert.ErrorIs(err, db.ErrRecordNotFound)
+	_, value, err := uut.GetLatestValue(app1Ctx, "db/password", nil)
+	assert.Nil(err)
+	assert.Equal(values["app1"], value)
+
+	// Only the changes to the watched namespace were delivered
+	for _, expected := range []string{"db/password", "db/user"} {
+		event := <-app1Events
+		assert.Equal("app1", event.Namespace)
+		assert.Equal(expected, event.Key)
+	}
+	assert.Len(app1Events, 0)
+}
+
+func TestProtectedKVStoreLoadsOlderKeysOnDemand(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	_, dbClient := newTestDB(t)
+
+	certFile, keyFile := testRSAFiles(t)
+
+	newStore := func() (encryption.CryptographyEngine, store.ProtectedKVStore) {
+		engine, err := encryption.NewCryptographyEngine(ctx, encryption.CryptographyEngineParams{
+			Persistence:        dbClient,
+			PrimaryRSACertFile: certFile,
+			PrimaryRSAKeyFile:  keyFile,
+		})
+		assert.Nil(err)
+		uut, err := store.NewProtectedKVStore(
+			ctx, dbClient, engine, store.ProtectedKVStoreOptions{},
+		)
+		assert.Nil(err)
+		return engine, uut
+	}
+
+	// Write each value under a different active encryption key
+	engine, uut := newStore()
+	values := map[string][]byte{}
+	for idx := 0; idx < 3; idx++ {
+		if idx > 0 {
+			_, err := engine.NewEncryptionKey(ctx, nil)
+			assert.Nil(err)
+			assert.Nil(uut.RefreshWorkingKey(ctx, nil))
+		}
+		key := fmt.Sprintf("testkey%d", idx)
+		values[key] = []byte(uuid.NewString())
+		_, _, err := uut.RecordKeyValue(ctx, key, values[key], time.Now(), nil)
+		assert.Nil(err)
+	}
+
+	// A new store only loads the working key
+	engine, uut = newStore()
+	assert.Equal(1, engine.CacheStats().CachedKeyCount)
+
+	// The older keys are loaded when their values are read
+	for key, expected := range values {
+		_, value, err := uut.GetLatestValue(ctx, key, nil)
+		assert.Nil(err)
+		assert.Equal(expected, value)
+	}
+	assert.Equal(3, engine.CacheStats().CachedKeyCount)
+}
+
+func BenchmarkProtectedKVStoreInit(b *testing.B) {
+	log.SetLevel(log.ErrorLevel)
+
+	ctx := context.Background()
+
+	certFile, err := filepath.Abs("./test/ut_rsa.crt")
+	if err != nil {
+		b.Fatal(err)
+	}
+	keyFile, err := filepath.Abs("./test/ut_rsa.key")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, activeKeys := range []int{1, 1000} {
+		b.Run(fmt.Sprintf("active_keys_%d", activeKeys), func(b *testing.B) {
+			dbClient, err := db.NewConnection(db.GetInMemorySqliteDialector(), logger.Error)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := dbClient.RunSQLInTransaction(ctx, db.DefineTables); err != nil {
+				b.Fatal(err)
+			}
+
+			newEngine := func() encryption.CryptographyEngine {
+				engine, err := encryption.NewCryptographyEngine(
+					ctx, encryption.CryptographyEngineParams{
+						Persistence:        dbClient,
+						PrimaryRSACertFile: certFile,
+						PrimaryRSAKeyFile:  keyFile,
+					},
+				)
+				if err != nil {
+					b.Fatal(err)
+				}
+				return engine
+			}
+
+			engine := newEngine()
+			for idx := 0; idx < activeKeys; idx++ {
+				if _, err := engine.NewEncryptionKey(ctx, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+			engine.Shutdown()
+
+			for b.Loop() {
+				engine := newEngine()
+				if _, err := store.NewProtectedKVStore(
+					ctx, dbClient, engine, store.ProtectedKVStoreOptions{},
+				); err != nil {
+					b.Fatal(err)
+				}
+				engine.Shutdown()
+			}
+		})
+	}
+}
+
+func TestProtectedKVStoreValueAtIndex(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	certFile, keyFile := testRSAFiles(t)
+
+	uut, err := haven.NewInMemoryProtectedKVStore(ctx, certFile, keyFile)
+	assert.Nil(err)
+
+	baseTime := time.Now().UTC().Add(-time.Hour)
+	values := [][]byte{}
+	versions := []models.RecordVersion{}
+	for idx := 0; idx < 4; idx++ {
+		value := []byte(uuid.NewString())
+		_, version, err := uut.RecordKeyValue(
+			ctx, "testkey1", value, baseTime.Add(time.Duration(idx)*time.Minute), nil,
+		)
+		assert.Nil(err)
+		values = append(values, value)
+		versions = append(versions, version)
+	}
+
+	testCases := []struct {
+		index    int
+		expected int
+	}{
+		{index: 0, expected: 0},
+		{index: 2, expected: 2},
+		{index: 3, expected: 3},
+		{index: -1, expected: 3},
+		{index: -2, expected: 2},
+		{index: -4, expected: 0},
+	}
+	for _, testCase := range testCases {
+		value, version, err := uut.GetValueOfKeyAtIndex(ctx, "testkey1", testCase.index, nil)
+		assert.Nil(err)
+		assert.Equal(values[testCase.expected], value)
+		assert.Equal(versions[testCase.expected].ID, version.ID)
+	}
+
+	// Out of range
+	for _, index := range []int{4, -5} {
+		_, _, err = uut.GetValueOfKeyAtIndex(ctx, "testkey1", index, nil)
+		assert.ErrorIs(err, store.ErrVersionNotFound)
+	}
+
+	// Unknown key
+	_, _, err = uut.GetValueOfKeyAtIndex(ctx, "testkey2", 0, nil)
+	assert.ErrorIs(err, db.ErrRecordNotFound)
+}
+
+func TestProtectedKVStoreBootstrap(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	autoInit := false
+	uut, dbClient, _ := newTestStore(
+		t,
+		encryption.CryptographyEngineParams{},
+		store.ProtectedKVStoreOptions{AutoInitSystemState: &autoInit},
+	)
+
+	systemState := func() models.SystemStateENUMType {
+		var state models.SystemStateENUMType
+		assert.Nil(dbClient.UseDatabase(ctx, func(ctx context.Context, dbClient db.Database) error {
+			params, err := dbClient.GetSystemParamEntry(ctx)
+			state = params.State
+			return err
+		}))
+		return state
+	}
+	assert.NotEqual(models.SystemStateRunning, systemState())
+
+	// Writes are refused until the store is bootstrapped
+	_, _, err := uut.RecordKeyValue(ctx, "existing", []byte("value"), time.Now(), nil)
+	assert.ErrorIs(err, store.ErrSystemNotReady)
+
+	// A failed bootstrap leaves the system state as is
+	assert.Nil(dbClient.UseDatabase(ctx, func(ctx context.Context, dbClient db.Database) error {
+		_, err := dbClient.DefineNewRecord(ctx, "", "existing")
+		return err
+	}))
+	_, err = uut.Bootstrap(ctx, "existing", []byte("value"), nil)
+	assert.ErrorIs(err, store.ErrKeyExists)
+	assert.NotEqual(models.SystemStateRunning, systemState())
+
+	value := []byte(uuid.NewString())
+	version, err := uut.Bootstrap(ctx, "testkey1", value, nil)
+	assert.Nil(err)
+	assert.Equal(models.SystemStateRunning, systemState())
+
+	latest, readBack, err := uut.GetLatestValue(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.Equal(version.ID, latest.ID)
+	assert.Equal(value, readBack)
+}
+
+func TestProtectedKVStoreRecordSubkeys(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	uut, dbClient, engine := newTestStore(
+		t, encryption.CryptographyEngineParams{}, store.ProtectedKVStoreOptions{DeriveRecordSubkeys: true},
+	)
+
+	// Values of different keys are encrypted with subkeys of the same encryption key
+	value1 := []byte(uuid.NewString())
+	_, version1, err := uut.RecordKeyValue(ctx, "testkey1", value1, time.Now(), nil)
+	assert.Nil(err)
+	value2 := []byte(uuid.NewString())
+	_, version2, err := uut.RecordKeyValue(ctx, "testkey2", value2, time.Now(), nil)
+	assert.Nil(err)
+	assert.Equal(version1.EncKeyID, version2.EncKeyID)
+	assert.Equal(models.KeyDerivationHKDFSHA256, version1.KeyDerivation)
+	assert.Equal(models.KeyDerivationHKDFSHA256, version2.KeyDerivation)
+
+	_, readBack, err := uut.GetLatestValue(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.Equal(value1, readBack)
+	_, readBack, err = uut.GetLatestValue(ctx, "testkey2", nil)
+	assert.Nil(err)
+	assert.Equal(value2, readBack)
+
+	// A store without subkeys still reads the values, and writes without deriving
+	plain, err := store.NewProtectedKVStore(ctx, dbClient, engine, store.ProtectedKVStoreOptions{})
+	assert.Nil(err)
+	_, readBack, err = plain.GetLatestValue(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.Equal(value1, readBack)
+
+	value3 := []byte(uuid.NewString())
+	_, version3, err := plain.RecordKeyValue(ctx, "testkey1", value3, time.Now(), nil)
+	assert.Nil(err)
+	assert.Equal(models.KeyDerivationNone, version3.KeyDerivation)
+	_, readBack, err = uut.GetLatestValue(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.Equal(value3, readBack)
+}
+
+func TestProtectedKVStorePruneRecordVersions(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	uut, dbClient, engine := newTestStore(
+		t, encryption.CryptographyEngineParams{}, store.ProtectedKVStoreOptions{},
+	)
+
+	baseTime := time.Now().UTC().Add(-time.Hour)
+	var record models.Record
+	versions := []models.RecordVersion{}
+	for idx := 0; idx < 5; idx++ {
+		var version models.RecordVersion
+		var err error
+		record, version, err = uut.RecordKeyValue(
+			ctx, "testkey1", []byte(uuid.NewString()), baseTime.Add(time.Duration(idx)*time.Minute), nil,
+		)
+		assert.Nil(err)
+		versions = append(versions, version)
+	}
+
+	// At least one version must be kept
+	_, err := uut.PruneRecordVersions(ctx, record, 0, nil)
+	assert.Error(err)
+
+	pruned, err := uut.PruneRecordVersions(ctx, record, 2, nil)
+	assert.Nil(err)
+	assert.Equal(3, pruned)
+
+	_, remaining, err := uut.ListKeyVersions(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.Len(remaining, 2)
+	remainingIDs := []string{}
+	for _, version := range remaining {
+		remainingIDs = append(remainingIDs, version.ID)
+	}
+	assert.ElementsMatch([]string{versions[3].ID, versions[4].ID}, remainingIDs)
+
+	// Nothing more to prune
+	pruned, err = uut.PruneRecordVersions(ctx, record, 2, nil)
+	assert.Nil(err)
+	assert.Equal(0, pruned)
+
+	// Every pruned version is audited
+	assert.Nil(dbClient.UseDatabase(ctx, func(ctx context.Context, dbClient db.Database) error {
+		events, err := dbClient.ListSystemEvents(ctx, db.SystemEventQueryFilter{
+			EventTypes: []models.SystemEventTypeENUMType{models.SystemEventTypeDeleteRecordVersion},
+		})
+		assert.Nil(err)
+		assert.Len(events, 3)
+		return err
+	}))
+
+	// Writes prune automatically with a retention limit
+	retaining, err := store.NewProtectedKVStore(
+		ctx, dbClient, engine, store.ProtectedKVStoreOptions{MaxVersionsPerKey: 3},
+	)
+	assert.Nil(err)
+	var latest models.RecordVersion
+	latestValue := []byte{}
+	for idx := 0; idx < 4; idx++ {
+		latestValue = []byte(uuid.NewString())
+		_, latest, err = retaining.RecordKeyValue(
+			ctx, "testkey2", latestValue, baseTime.Add(time.Duration(idx)*time.Minute), nil,
+		)
+		assert.Nil(err)
+		_, remaining, err = retaining.ListKeyVersions(ctx, "testkey2", nil)
+		assert.Nil(err)
+		assert.Len(remaining, min(idx+1, 3))
+	}
+
+	version, value, err := retaining.GetLatestValue(ctx, "testkey2", nil)
+	assert.Nil(err)
+	assert.Equal(latest.ID, version.ID)
+	assert.Equal(latestValue, value)
+}
+
+func TestProtectedKVStoreVerifyIntegrity(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	uut, dbClient, _ := newTestStore(
+		t, encryption.CryptographyEngineParams{}, store.ProtectedKVStoreOptions{},
+	)
+
+	versions := []models.RecordVersion{}
+	for idx := 0; idx < 3; idx++ {
+		_, version, err := uut.RecordKeyValue(
+			ctx, fmt.Sprintf("testkey%d", idx), []byte(uuid.NewString()), time.Now(), nil,
+		)
+		assert.Nil(err)
+		versions = append(versions, version)
+	}
+
+	report, err := uut.VerifyIntegrity(ctx, db.RecordVersionQueryFilter{}, nil)
+	assert.Nil(err)
+	assert.Equal(3, report.Checked)
+	assert.Equal(0, report.Failed)
+
+	// Corrupt one byte of the second version's encrypted value
+	corrupted := append([]byte{}, versions[1].EncValue...)
+	corrupted[len(corrupted)-1] ^= 0xff
+	assert.Nil(dbClient.RawDB().WithContext(ctx).
+		Model(&db.RecordVersionDBEntry{}).
+		Where("id = ?", versions[1].ID).
+		Update("enc_value", corrupted).Error)
+
+	report, err = uut.VerifyIntegrity(ctx, db.RecordVersionQueryFilter{}, nil)
+	assert.Nil(err)
+	assert.Equal(3, report.Checked)
+	assert.Equal(1, report.Failed)
+	for _, result := range report.Versions {
+		if result.VersionID == versions[1].ID {
+			assert.False(result.OK)
+			assert.Equal(store.IntegrityFailureAuthentication, result.Failure)
+			assert.NotEmpty(result.Reason)
+		} else {
+			assert.True(result.OK)
+			assert.Empty(result.Failure)
+		}
+	}
+
+	// The filter limits the versions checked
+	report, err = uut.VerifyIntegrity(
+		ctx, db.RecordVersionQueryFilter{TargetRecordID: &versions[0].RecordID}, nil,
+	)
+	assert.Nil(err)
+	assert.Equal(1, report.Checked)
+	assert.Equal(0, report.Failed)
+}
+
+func TestProtectedKVStoreVersionNotes(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	uut, dbClient, _ := newTestStore(
+		t, encryption.CryptographyEngineParams{}, store.ProtectedKVStoreOptions{},
+	)
+
+	baseTime := time.Now().UTC().Add(-time.Hour)
+	record, _, err := uut.RecordKeyValue(ctx, "testkey1", []byte(uuid.NewString()), baseTime, nil)
+	assert.Nil(err)
+	note := "rotate the upstream credential"
+	value := []byte(uuid.NewString())
+	_, noted, err := uut.RecordKeyValueWithNote(
+		ctx, "testkey1", value, baseTime.Add(time.Minute), note, nil,
+	)
+	assert.Nil(err)
+	assert.Equal(note, noted.Note)
+
+	// The note is returned on reads
+	latest, readBack, err := uut.GetLatestValue(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.Equal(value, readBack)
+	assert.Equal(noted.ID, latest.ID)
+	assert.Equal(note, latest.Note)
+
+	// Only the noted version has a note
+	assert.Nil(dbClient.UseDatabase(ctx, func(ctx context.Context, dbClient db.Database) error {
+		versions, err := dbClient.ListVersionsOfOneRecord(
+			ctx, record, db.RecordVersionQueryFilter{HasNote: true},
+		)
+		assert.Nil(err)
+		assert.Len(versions, 1)
+		if len(versions) == 1 {
+			assert.Equal(noted.ID, versions[0].ID)
+			assert.Equal(note, versions[0].Note)
+		}
+
+		versions, err = dbClient.ListVersionsOfOneRecord(ctx, record, db.RecordVersionQueryFilter{})
+		assert.Nil(err)
+		assert.Len(versions, 2)
+		return err
+	}))
+
+	// Overly long notes are rejected
+	_, _, err = uut.RecordKeyValueWithNote(
+		ctx, "testkey1", value, time.Now(), strings.Repeat("x", 1025), nil,
+	)
+	assert.Error(err)
+}
+
+func TestProtectedKVStoreHealthCheck(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	uut, dbClient, engine := newTestStore(
+		t, encryption.CryptographyEngineParams{}, store.ProtectedKVStoreOptions{},
+	)
+
+	assert.Nil(uut.HealthCheck(ctx))
+
+	// Working key deactivated elsewhere
+	_, version, err := uut.RecordKeyValue(ctx, "testkey1", []byte(uuid.NewString()), time.Now(), nil)
+	assert.Nil(err)
+	assert.Nil(dbClient.UseDatabase(ctx, func(ctx context.Context, dbClient db.Database) error {
+		_, err := engine.MarkEncryptionKeyInactive(ctx, version.EncKeyID, dbClient)
+		return err
+	}))
+	err = uut.HealthCheck(ctx)
+	assert.ErrorIs(err, store.ErrWorkingKeyUnavailable)
+	assert.NotErrorIs(err, store.ErrDatabaseUnavailable)
+
+	// Database down
+	assert.Nil(dbClient.Close())
+	err = uut.HealthCheck(ctx)
+	assert.ErrorIs(err, store.ErrDatabaseUnavailable)
+	assert.NotErrorIs(err, store.ErrWorkingKeyUnavailable)
+}
+
+func TestProtectedKVStoreCompareVersions(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	certFile, keyFile := testRSAFiles(t)
+
+	uut, err := haven.NewInMemoryProtectedKVStore(ctx, certFile, keyFile)
+	assert.Nil(err)
+
+	value1 := []byte(uuid.NewString())
+	value2 := []byte(uuid.NewString())
+	_, version1, err := uut.RecordKeyValue(ctx, "testkey1", value1, time.Now(), nil)
+	assert.Nil(err)
+	_, version2, err := uut.RecordKeyValue(ctx, "testkey1", value2, time.Now(), nil)
+	assert.Nil(err)
+	_, version3, err := uut.RecordKeyValue(ctx, "testkey2", value1, time.Now(), nil)
+	assert.Nil(err)
+
+	// Identical values, even across keys
+	equal, a, b, err := uut.CompareVersions(ctx, version1.ID, version3.ID, nil)
+	assert.Nil(err)
+	assert.True(equal)
+	assert.Equal(value1, a)
+	assert.Equal(value1, b)
+
+	// Differing values
+	equal, a, b, err = uut.CompareVersions(ctx, version1.ID, version2.ID, nil)
+	assert.Nil(err)
+	assert.False(equal)
+	assert.Equal(value1, a)
+	assert.Equal(value2, b)
+
+	// Both versions must exist
+	_, _, _, err = uut.CompareVersions(ctx, version1.ID, ulid.Make().String(), nil)
+	assert.ErrorIs(err, store.ErrVersionNotFound)
+	_, _, _, err = uut.CompareVersions(ctx, ulid.Make().String(), version1.ID, nil)
+	assert.ErrorIs(err, store.ErrVersionNotFound)
+}
+
+func TestProtectedKVStoreSystemState(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	autoInit := false
+	uut, _, _ := newTestStore(
+		t,
+		encryption.CryptographyEngineParams{},
+		store.ProtectedKVStoreOptions{AutoInitSystemState: &autoInit},
+	)
+
+	// Writes are refused before the system is RUNNING
+	state, err := uut.SystemState(ctx, nil)
+	assert.Nil(err)
+	assert.Equal(models.SystemStatePreInit, state)
+	_, _, err = uut.RecordKeyValue(ctx, "testkey1", []byte(uuid.NewString()), time.Now(), nil)
+	assert.ErrorIs(err, store.ErrSystemNotReady)
+	_, _, err = uut.CreateKeyWithValue(ctx, "testkey2", []byte(uuid.NewString()), time.Now(), nil)
+	assert.ErrorIs(err, store.ErrSystemNotReady)
+
+	// Nothing was written
+	_, _, err = uut.ListKeyVersions(ctx, "testkey1", nil)
+	assert.ErrorIs(err, db.ErrRecordNotFound)
+	_, _, err = uut.ListKeyVersions(ctx, "testkey2", nil)
+	assert.ErrorIs(err, db.ErrRecordNotFound)
+
+	// Writes are accepted once bootstrapped
+	_, err = uut.Bootstrap(ctx, "testkey1", []byte(uuid.NewString()), nil)
+	assert.Nil(err)
+	state, err = uut.SystemState(ctx, nil)
+	assert.Nil(err)
+	assert.Equal(models.SystemStateRunning, state)
+	_, _, err = uut.RecordKeyValue(ctx, "testkey1", []byte(uuid.NewString()), time.Now(), nil)
+	assert.Nil(err)
+	_, _, err = uut.CreateKeyWithValue(ctx, "testkey2", []byte(uuid.NewString()), time.Now(), nil)
+	assert.Nil(err)
+
+	_, versions, err := uut.ListKeyVersions(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.Len(versions, 2)
+}
+
+func TestProtectedKVStoreValueEquals(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	certFile, keyFile := testRSAFiles(t)
+
+	uut, err := haven.NewInMemoryProtectedKVStore(ctx, certFile, keyFile)
+	assert.Nil(err)
+
+	value1 := []byte(uuid.NewString())
+	value2 := []byte(uuid.NewString())
+	_, _, err = uut.RecordKeyValue(ctx, "testkey1", value1, time.Now(), nil)
+	assert.Nil(err)
+
+	// The newest value
+	equal, err := uut.ValueEquals(ctx, "testkey1", value1, nil)
+	assert.Nil(err)
+	assert.True(equal)
+
+	// A different value
+	equal, err = uut.ValueEquals(ctx, "testkey1", value2, nil)
+	assert.Nil(err)
+	assert.False(equal)
+
+	// Only the newest value is compared
+	_, _, err = uut.RecordKeyValue(ctx, "testkey1", value2, time.Now(), nil)
+	assert.Nil(err)
+	equal, err = uut.ValueEquals(ctx, "testkey1", value1, nil)
+	assert.Nil(err)
+	assert.False(equal)
+	equal, err = uut.ValueEquals(ctx, "testkey1", value2, nil)
+	assert.Nil(err)
+	assert.True(equal)
+
+	// A missing key equals no value
+	equal, err = uut.ValueEquals(ctx, "testkey2", value1, nil)
+	assert.Nil(err)
+	assert.False(equal)
+}
+
+func TestProtectedKVStoreWriteCoalescing(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	window := time.Millisecond * 200
+	uut, _, _ := newTestStore(
+		t,
+		encryption.CryptographyEngineParams{},
+		store.ProtectedKVStoreOptions{WriteCoalescingWindow: window},
+	)
+
+	versionCount := func() int {
+		_, versions, err := uut.ListKeyVersions(ctx, "testkey1", nil)
+		assert.Nil(err)
+		return len(versions)
+	}
+
+	// The same value written twice within the window records one version
+	value1 := []byte(uuid.NewString())
+	_, version1, err := uut.RecordKeyValue(ctx, "testkey1", value1, time.Now(), nil)
+	assert.Nil(err)
+	_, version2, err := uut.RecordKeyValue(ctx, "testkey1", value1, time.Now(), nil)
+	assert.Nil(err)
+	assert.Equal(version1.ID, version2.ID)
+	assert.Equal(1, versionCount())
+
+	// A differing value is recorded
+	value2 := []byte(uuid.NewString())
+	_, version3, err := uut.RecordKeyValue(ctx, "testkey1", value2, time.Now(), nil)
+	assert.Nil(err)
+	assert.NotEqual(version1.ID, version3.ID)
+	assert.Equal(2, versionCount())
+
+	// Once the window passes, the same value is recorded again
+	time.Sleep(window)
+	_, version4, err := uut.RecordKeyValue(ctx, "testkey1", value2, time.Now(), nil)
+	assert.Nil(err)
+	assert.NotEqual(version3.ID, version4.ID)
+	assert.Equal(3, versionCount())
+
+	_, readBack, err := uut.GetLatestValue(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.Equal(value2, readBack)
+
+	// Rekeying the key drops the remembered write
+	value3 := []byte(uuid.NewString())
+	_, version5, err := uut.RecordKeyValue(ctx, "testkey1", value3, time.Now(), nil)
+	assert.Nil(err)
+	_, err = uut.RekeyRecord(ctx, "testkey1", nil)
+	assert.Nil(err)
+	_, version6, err := uut.RecordKeyValue(ctx, "testkey1", value3, time.Now(), nil)
+	assert.Nil(err)
+	assert.NotEqual(version5.ID, version6.ID)
+	assert.Equal(5, versionCount())
+}
+
+// TestProtectedKVStoreWriteCoalescingMultipleStores verifies that a write is not coalesced
+// into a version another store has since superseded.
+func TestProtectedKVStoreWriteCoalescingMultipleStores(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	options := store.ProtectedKVStoreOptions{WriteCoalescingWindow: time.Minute}
+	uutA, dbClient, engine := newTestStore(t, encryption.CryptographyEngineParams{}, options)
+	uutB, err := store.NewProtectedKVStore(ctx, dbClient, engine, options)
+	assert.Nil(err)
+
+	value1 := []byte(uuid.NewString())
+	_, version1, err := uutA.RecordKeyValue(ctx, "testkey1", value1, time.Now(), nil)
+	assert.Nil(err)
+	value2 := []byte(uuid.NewString())
+	_, version2, err := uutB.RecordKeyValue(ctx, "testkey1", value2, time.Now(), nil)
+	assert.Nil(err)
+
+	// The write A remembers is no longer the newest
+	_, version3, err := uutA.RecordKeyValue(ctx, "testkey1", value1, time.Now(), nil)
+	assert.Nil(err)
+	assert.NotEqual(version1.ID, version3.ID)
+	assert.NotEqual(version2.ID, version3.ID)
+
+	_, versions, err := uutA.ListKeyVersions(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.Len(versions, 3)
+	latest, readBack, err := uutB.GetLatestValue(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.Equal(version3.ID, latest.ID)
+	assert.Equal(value1, readBack)
+
+	// While still the newest, the write coalesces
+	_, version4, err := uutA.RecordKeyValue(ctx, "testkey1", value1, time.Now(), nil)
+	assert.Nil(err)
+	assert.Equal(version3.ID, version4.ID)
+}
+
+func TestProtectedKVStoreReadWithInactiveKey(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	uut, _, engine := newTestStore(
+		t, encryption.CryptographyEngineParams{}, store.ProtectedKVStoreOptions{},
+	)
+
+	value := []byte(uuid.NewString())
+	_, version, err := uut.RecordKeyValue(ctx, "testkey1", value, time.Now(), nil)
+	assert.Nil(err)
+
+	_, err = engine.MarkEncryptionKeyInactive(ctx, version.EncKeyID, nil)
+	assert.Nil(err)
+
+	// The value remains readable
+	_, readBack, err := uut.GetLatestValue(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.Equal(value, readBack)
+	readBack, err = uut.GetValueOfKeyAtVersion(ctx, version, nil)
+	assert.Nil(err)
+	assert.Equal(value, readBack)
+
+	// The inactive key is not cached
+	for _, cached := range engine.CacheStats().Keys {
+		assert.NotEqual(version.EncKeyID, cached.KeyID)
+	}
+
+	// The inactive key can not encrypt new values
+	_, _, err = engine.EncryptData(
+		ctx, version.EncKeyID, []byte(uuid.NewString()), nil, nil, nil,
+	)
+	assert.ErrorIs(err, encryption.ErrKeyNotActive)
+}
+
+func TestProtectedKVStoreInsecurePlaintextKeys(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	// No RSA files needed
+	uut, dbClient, engine := newTestStore(
+		t,
+		encryption.CryptographyEngineParams{InsecurePlaintextKeys: true},
+		store.ProtectedKVStoreOptions{},
+	)
+
+	value := []byte(uuid.NewString())
+	_, version, err := uut.RecordKeyValue(ctx, "testkey1", value, time.Now(), nil)
+	assert.Nil(err)
+	_, readBack, err := uut.GetLatestValue(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.Equal(value, readBack)
+
+	// The key material is stored unwrapped
+	assert.Nil(dbClient.UseDatabase(ctx, func(ctx context.Context, dbClient db.Database) error {
+		key, err := dbClient.GetEncryptionKey(ctx, version.EncKeyID)
+		assert.Nil(err)
+		assert.Equal("INSECURE-PLAINTEXT", key.RSAFingerprint)
+		assert.Len(key.EncKeyMaterial, 32)
+		return nil
+	}))
+
+	// Operations needing RSA keys fail
+	_, err = engine.WrapSecret(ctx, value)
+	assert.ErrorIs(err, encryption.ErrNoRSAKeys)
+
+	// An engine with RSA keys can not use the plaintext key
+	certFile, keyFile := testRSAFiles(t)
+	rsaEngine, err := encryption.NewCryptographyEngine(ctx, encryption.CryptographyEngineParams{
+		Persistence:        dbClient,
+		PrimaryRSACertFile: certFile,
+		PrimaryRSAKeyFile:  keyFile,
+	})
+	assert.Nil(err)
+	_, err = store.NewProtectedKVStore(ctx, dbClient, rsaEngine, store.ProtectedKVStoreOptions{})
+	assert.ErrorIs(err, encryption.ErrRSAKeyFingerprintMismatch)
+}
+
+// TestProtectedKVStoreDeleteKeyVersion verifies one version of a key can be deleted, and
+// the guard against deleting the last version.
+func TestProtectedKVStoreDeleteKeyVersion(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	certFile, keyFile := testRSAFiles(t)
+
+	uut, err := haven.NewInMemoryProtectedKVStore(ctx, certFile, keyFile)
+	assert.Nil(err)
+	defer func() {
+		assert.Nil(uut.Close())
+	}()
+
+	value1 := []byte(uuid.NewString())
+	_, leaked, err := uut.RecordKeyValue(ctx, "testkey1", []byte(uuid.NewString()), time.Now(), nil)
+	assert.Nil(err)
+	_, kept, err := uut.RecordKeyValue(ctx, "testkey1", value1, time.Now(), nil)
+	assert.Nil(err)
+	_, other, err := uut.RecordKeyValue(ctx, "testkey2", value1, time.Now(), nil)
+	assert.Nil(err)
+
+	// The version must belong to the named key
+	err = uut.DeleteKeyVersion(ctx, "testkey1", other.ID, false, nil)
+	assert.ErrorIs(err, store.ErrVersionNotFound)
+
+	// Purge the leaked version
+	assert.Nil(uut.DeleteKeyVersion(ctx, "testkey1", leaked.ID, false, nil))
+	_, versions, err := uut.ListKeyVersions(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.Len(versions, 1)
+	assert.Equal(kept.ID, versions[0].ID)
+	_, err = uut.GetValueOfKeyAtVersionID(ctx, leaked.ID, nil)
+	assert.ErrorIs(err, store.ErrVersionNotFound)
+
+	// The last version is only deleted when forced
+	err = uut.DeleteKeyVersion(ctx, "testkey1", kept.ID, false, nil)
+	assert.ErrorIs(err, store.ErrLastVersion)
+	_, readBack, err := uut.GetLatestValue(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.Equal(value1, readBack)
+
+	assert.Nil(uut.DeleteKeyVersion(ctx, "testkey1", kept.ID, true, nil))
+	_, versions, err = uut.ListKeyVersions(ctx, "testkey1", nil)
+	assert.Nil(err)
+	assert.Len(versions, 0)
+
+	err = uut.DeleteKeyVersion(ctx, "unknown", kept.ID, true, nil)
+	assert.ErrorIs(err, db.ErrRecordNotFound)
+}
+
+// TestProtectedKVStoreDeleteKeyVersionWatch verifies that deleting the newest version of a
+// key is delivered to watchers.
+func TestProtectedKVStoreDeleteKeyVersionWatch(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	uut, _, _ := newTestStore(
+		t, encryption.CryptographyEngineParams{}, store.ProtectedKVStoreOptions{},
+	)
+
+	baseTime := time.Now().UTC()
+	versions := []models.RecordVersion{}
+	for idx := 0; idx < 3; idx++ {
+		_, version, err := uut.RecordKeyValue(
+			ctx, "testkey1", []byte(uuid.NewString()), baseTime.Add(time.Duration(idx)*time.Second), nil,
+		)
+		assert.Nil(err)
+		versions = append(versions, version)
+	}
+
+	watchCtx, watchCancel := context.WithCancel(ctx)
+	defer watchCancel()
+	events, err := uut.Watch(watchCtx, "testkey1")
+	assert.Nil(err)
+
+	readEvent := func() (store.WatchEvent, bool) {
+		select {
+		case event, ok := <-events:
+			return event, ok
+		case <-time.After(time.Millisecond * 50):
+			return store.WatchEvent{}, false
+		}
+	}
+
+	// Deleting an older version leaves the value of the key as is
+	assert.Nil(uut.DeleteKeyVersion(ctx, "testkey1", versions[0].ID, false, nil))
+	_, ok := readEvent()
+	assert.False(ok)
+
+	// Deleting the newest version updates the key to the version now newest
+	assert.Nil(uut.DeleteKeyVersion(ctx, "testkey1", versions[2].ID, false, nil))
+	event, ok := readEvent()
+	assert.True(ok)
+	assert.Equal(store.WatchEventTypeUpdated, event.Type)
+	assert.Equal("testkey1", event.Key)
+	assert.Equal(versions[1].RecordID, event.RecordID)
+	assert.Equal(versions[1].ID, event.VersionID)
+
+	// Deleting the last version deletes the key
+	assert.Nil(uut.DeleteKeyVersion(ctx, "testkey1", versions[1].ID, true, nil))
+	event, ok = readEvent()
+	assert.True(ok)
+	assert.Equal(store.WatchEventTypeDeleted, event.Type)
+	assert.Equal(versions[1].RecordID, event.RecordID)
+	assert.Empty(event.VersionID)
+}
+
+// TestProtectedKVStoreGetLatestValues verifies several keys can be read in one batch, with
+// a missing key reported in its own result.
+func TestProtectedKVStoreGetLatestValues(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	certFile, keyFile := testRSAFiles(t)
+
+	uut, err := haven.NewInMemoryProtectedKVStore(ctx, certFile, keyFile)
+	assert.Nil(err)
+	defer func() {
+		assert.Nil(uut.Close())
+	}()
+
+	// Each key has several versions
+	latest := map[string][]byte{}
+	for itr := 0; itr < 3; itr++ {
+		for _, key := range []string{"testkey1", "testkey2", "testkey3"} {
+			value := []byte(uuid.NewString())
+			_, _, err := uut.RecordKeyValue(ctx, key, value, time.Now(), nil)
+			assert.Nil(err)
+			latest[key] = value
+		}
+	}
+
+	results, err := uut.GetLatestValues(
+		ctx, []string{"testkey1", "testkey3", "missing", "testkey2"}, nil,
+	)
+	assert.Nil(err)
+	assert.Len(results, 4)
+	for _, key := range []string{"testkey1", "testkey2", "testkey3"} {
+		assert.Nil(results[key].Err)
+		assert.Equal(latest[key], results[key].Value)
+
+		version, _, err := uut.GetLatestValue(ctx, key, nil)
+		assert.Nil(err)
+		assert.Equal(version.ID, results[key].Version.ID)
+	}
+	assert.ErrorIs(results["missing"].Err, db.ErrRecordNotFound)
+	assert.Nil(results["missing"].Value)
+
+	// Nothing requested, nothing returned
+	results, err = uut.GetLatestValues(ctx, []string{}, nil)
+	assert.Nil(err)
+	assert.Len(results, 0)
+}
+
+// TestProtectedKVStoreRenameKey verifies a renamed key keeps all its versions.
+func TestProtectedKVStoreRenameKey(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	uut, dbClient, _ := newTestStore(
+		t, encryption.CryptographyEngineParams{}, store.ProtectedKVStoreOptions{},
+	)
+
+	values := map[string][]byte{}
+	for itr := 0; itr < 2; itr++ {
+		value := []byte(uuid.NewString())
+		_, version, err := uut.RecordKeyValue(ctx, "db.url", value, time.Now(), nil)
+		assert.Nil(err)
+		values[version.ID] = value
+	}
+	_, _, err := uut.RecordKeyValue(ctx, "other", []byte(uuid.NewString()), time.Now(), nil)
+	assert.Nil(err)
+
+	// The new name must not be in use
+	err = uut.RenameKey(ctx, "db.url", "other", nil)
+	assert.ErrorIs(err, db.ErrDuplicateRecordName)
+
+	assert.Nil(uut.RenameKey(ctx, "db.url", "database.url", nil))
+
+	// Both versions are readable under the new name
+	_, versions, err := uut.ListKeyVersions(ctx, "database.url", nil)
+	assert.Nil(err)
+	assert.Len(versions, 2)
+	for _, version := range versions {
+		value, err := uut.GetValueOfKeyAtVersionID(ctx, version.ID, nil)
+		assert.Nil(err)
+		assert.Equal(values[version.ID], value)
+	}
+
+	// The old name no longer resolves
+	_, _, err = uut.GetLatestValue(ctx, "db.url", nil)
+	assert.ErrorIs(err, db.ErrRecordNotFound)
+	err = uut.RenameKey(ctx, "db.url", "database.url", nil)
+	assert.ErrorIs(err, db.ErrRecordNotFound)
+
+	// The rename is audited
+	assert.Nil(dbClient.UseDatabase(ctx, func(ctx context.Context, dbClient db.Database) error {
+		events, err := dbClient.ListSystemEvents(ctx, db.SystemEventQueryFilter{
+			EventTypes: []models.SystemEventTypeENUMType{models.SystemEventTypeRenameRecord},
+		})
+		assert.Nil(err)
+		assert.Len(events, 1)
+		return err
+	}))
+}
+
+// TestProtectedKVStoreScanAll verifies every key is streamed with its newest value, and a
+// scan stops early when its context is cancelled.
+func TestProtectedKVStoreScanAll(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.InfoLevel)
+
+	ctx := context.Background()
+
+	certFile, keyFile := testRSAFiles(t)
+
+	uut, err := haven.NewInMemoryProtectedKVStore(ctx, certFile, keyFile)
+	assert.Nil(err)
+	defer func() {
+		assert.Nil(uut.Close())
+	}()
+
+	expected := map[string][]byte{}
+	for itr := 0; itr < 300; itr++ {
+		key := fmt.Sprintf("scan/key-%03d", itr)
+		for version := 0; version < 2; version++ {
+			value := []byte(uuid.NewString())
+			_, _, err := uut.RecordKeyValue(ctx, key, value, time.Now(), nil)
+			assert.Nil(err)
+			expected[key] = value
+		}
+	}
+	_, _, err = uut.RecordKeyValue(ctx, "other", []byte(uuid.NewString()), time.Now(), nil)
+	assert.Nil(err)
+
+	// Scan every key under the prefix
+	items, wait, err := uut.ScanAll(ctx, db.RecordQueryFilter{NamePrefix: "scan/"}, nil)
+	assert.Nil(err)
+	scanned := []string{}
+	for item := range items {
+		scanned = append(scanned, item.Record.Name)
+		assert.Equal(expected[item.Record.Name], item.Value)
+	}
+	assert.Nil(wait())
+	assert.Len(scanned, len(expected))
+	assert.IsIncreasing(scanned)
+
+	// Stop the scan early
+	scanCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	items, wait, err = uut.ScanAll(scanCtx, db.RecordQueryFilter{}, nil)
+	assert.Nil(err)
+	received := 0
+	for range items {
+		received++
+		if received == 10 {
+			cancel()
+		}
+	}
+	assert.ErrorIs(wait(), context.Canceled)
+	assert.Less(received, len(expected))
+
+	// The store remains usable
+	_, readBack, err := uut.GetLatestValue(ctx, "scan/key-000", nil)
+	assert.Nil(err)
+	assert.Equal(expected["scan/key-000"], readBack)
+}
+
+// TestProtectedKVStoreListKeysWithMetadata verifies each listed key reports its version
+// count and the timestamp of its newest version.
+func TestProtectedKVStoreListKeysWithMetadata(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	ctx := context.Background()
+
+	certFile, keyFile := testRSAFiles(t)
+
+	uut, err := haven.NewInMemoryProtectedKVStore(ctx, certFile, keyFile)
+	assert.Nil(err)
+	defer func() {
+		assert.Nil(uut.Close())
+	}()
+
+	// Key N has N+1 versions, the newest recorded N hours after now
+	now := time.Now().UTC().Truncate(time.Second)
+	recordIDs := []string{}
+	for itr := 0; itr < 3; itr++ {
+		key := fmt.Sprintf("app/key-%d", itr)
+		var record models.Record
+		for version := 0; version <= itr; version++ {
+			record, _, err = uut.RecordKeyValue(
+				ctx,
+				key,
+				[]byte(uuid.NewString()),
+				now.Add(time.Duration(version)*time.Hour),
+				nil,
+			)
+			assert.Nil(err)
+		}
+		recordIDs = append(recordIDs, record.ID)
+	}
+	_, _, err = uut.RecordKeyValue(ctx, "other", []byte(uuid.NewString()), now, nil)
+	assert.Nil(err)
+
+	keys, err := uut.ListKeysWithMetadata(ctx, db.RecordQueryFilter{
+		NamePrefix: "app/", SortBy: db.SortByName, SortAscending: true,
+	}, nil)
+	assert.Nil(err)
+	assert.Len(keys, 3)
+	for idx, key := range keys {
+		assert.Equal(fmt.Sprintf("app/key-%d", idx), key.Name)
+		assert.Equal(recordIDs[idx], key.RecordID)
+		assert.False(key.CreatedAt.IsZero())
+		assert.Equal(int64(idx+1), key.VersionCount)
+		if assert.NotNil(key.LatestVersionAt) {
+			assert.True(now.Add(time.Duration(idx) * time.Hour).Equal(*key.LatestVersionAt))
+		}
+	}
+
+	// Only keys of the context's namespace are listed
+	keys, err = uut.ListKeysWithMetadata(
+		store.WithNamespace(ctx, "app2"), db.RecordQueryFilter{}, nil,
+	)
+	assert.Nil(err)
+	assert.Len(keys, 0)
 }
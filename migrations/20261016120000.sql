@@ -0,0 +1,2 @@
+-- Modify "encryption_keys" table
+ALTER TABLE "public"."encryption_keys" ADD COLUMN "rsa_fingerprint" text NULL;
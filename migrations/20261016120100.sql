@@ -0,0 +1,2 @@
+-- Modify "record_versions" table
+ALTER TABLE "public"."record_versions" ADD COLUMN "expires_at" timestamptz NULL;
@@ -0,0 +1,2 @@
+-- Modify "records" table
+ALTER TABLE "public"."records" ADD COLUMN "state" text NOT NULL DEFAULT 'ACTIVE';
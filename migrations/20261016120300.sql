@@ -0,0 +1,2 @@
+-- Modify "system_audit_events" table
+ALTER TABLE "public"."system_audit_events" ADD COLUMN "actor" text NOT NULL DEFAULT 'system';
@@ -0,0 +1,2 @@
+-- Modify "encryption_keys" table
+ALTER TABLE "public"."encryption_keys" ADD COLUMN "aead_type" text NOT NULL DEFAULT 'XChaCha20-Poly1305';
@@ -0,0 +1,2 @@
+-- Modify "records" table
+ALTER TABLE "public"."records" ADD COLUMN "last_read_at" timestamptz NULL;
@@ -0,0 +1,4 @@
+-- Modify "encryption_keys" table
+ALTER TABLE "public"."encryption_keys" ADD COLUMN "material_fingerprint" text NULL;
+-- Create index "idx_encryption_keys_material_fingerprint" to table: "encryption_keys"
+CREATE UNIQUE INDEX "idx_encryption_keys_material_fingerprint" ON "public"."encryption_keys" ("material_fingerprint");
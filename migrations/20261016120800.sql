@@ -0,0 +1,4 @@
+-- Modify "records" table
+ALTER TABLE "public"."records" DROP CONSTRAINT "uni_records_name", ADD COLUMN "namespace" text NOT NULL DEFAULT '';
+-- Create index "idx_records_namespace_name" to table: "records"
+CREATE UNIQUE INDEX "idx_records_namespace_name" ON "public"."records" ("namespace", "name");
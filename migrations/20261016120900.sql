@@ -0,0 +1,2 @@
+-- Modify "records" table
+ALTER TABLE "public"."records" ADD COLUMN "metadata" jsonb NULL;
@@ -0,0 +1,19 @@
+-- Modify "record_versions" table
+ALTER TABLE "public"."record_versions" ADD COLUMN "sequence" bigint NOT NULL DEFAULT 0;
+-- Create index "idx_record_versions_sequence" to table: "record_versions"
+CREATE INDEX "idx_record_versions_sequence" ON "public"."record_versions" ("sequence");
+-- Create "sequences" table
+CREATE TABLE "public"."sequences" (
+  "name" text NOT NULL,
+  "value" bigint NOT NULL DEFAULT 0,
+  PRIMARY KEY ("name")
+);
+-- Sequence the existing record versions in ID order
+UPDATE "public"."record_versions" AS "v" SET "sequence" = "n"."sequence"
+FROM (
+  SELECT "id", ROW_NUMBER() OVER (ORDER BY "id") AS "sequence"
+  FROM "public"."record_versions"
+) AS "n"
+WHERE "v"."id" = "n"."id";
+INSERT INTO "public"."sequences" ("name", "value")
+SELECT 'record_versions', COALESCE(MAX("sequence"), 0) FROM "public"."record_versions";
@@ -39,6 +39,198 @@ func (_m *Client) EXPECT() *Client_Expecter {
 	return &Client_Expecter{mock: &_m.Mock}
 }
 
+// Close provides a mock function for the type Client
+func (_mock *Client) Close() error {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Close")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func() error); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// Client_Close_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Close'
+type Client_Close_Call struct {
+	*mock.Call
+}
+
+// Close is a helper method to define mock.On call
+func (_e *Client_Expecter) Close() *Client_Close_Call {
+	return &Client_Close_Call{Call: _e.mock.On("Close")}
+}
+
+func (_c *Client_Close_Call) Run(run func()) *Client_Close_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *Client_Close_Call) Return(err error) *Client_Close_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *Client_Close_Call) RunAndReturn(run func() error) *Client_Close_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Flush provides a mock function for the type Client
+func (_mock *Client) Flush(ctx context.Context) error {
+	ret := _mock.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Flush")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = returnFunc(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// Client_Flush_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Flush'
+type Client_Flush_Call struct {
+	*mock.Call
+}
+
+// Flush is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *Client_Expecter) Flush(ctx interface{}) *Client_Flush_Call {
+	return &Client_Flush_Call{Call: _e.mock.On("Flush", ctx)}
+}
+
+func (_c *Client_Flush_Call) Run(run func(ctx context.Context)) *Client_Flush_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *Client_Flush_Call) Return(err error) *Client_Flush_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *Client_Flush_Call) RunAndReturn(run func(ctx context.Context) error) *Client_Flush_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Ping provides a mock function for the type Client
+func (_mock *Client) Ping(ctx context.Context) error {
+	ret := _mock.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Ping")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = returnFunc(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// Client_Ping_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Ping'
+type Client_Ping_Call struct {
+	*mock.Call
+}
+
+// Ping is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *Client_Expecter) Ping(ctx interface{}) *Client_Ping_Call {
+	return &Client_Ping_Call{Call: _e.mock.On("Ping", ctx)}
+}
+
+func (_c *Client_Ping_Call) Run(run func(ctx context.Context)) *Client_Ping_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *Client_Ping_Call) Return(err error) *Client_Ping_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *Client_Ping_Call) RunAndReturn(run func(ctx context.Context) error) *Client_Ping_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RawDB provides a mock function for the type Client
+func (_mock *Client) RawDB() *gorm.DB {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for RawDB")
+	}
+
+	var r0 *gorm.DB
+	if returnFunc, ok := ret.Get(0).(func() *gorm.DB); ok {
+		r0 = returnFunc()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*gorm.DB)
+		}
+	}
+	return r0
+}
+
+// Client_RawDB_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RawDB'
+type Client_RawDB_Call struct {
+	*mock.Call
+}
+
+// RawDB is a helper method to define mock.On call
+func (_e *Client_Expecter) RawDB() *Client_RawDB_Call {
+	return &Client_RawDB_Call{Call: _e.mock.On("RawDB")}
+}
+
+func (_c *Client_RawDB_Call) Run(run func()) *Client_RawDB_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *Client_RawDB_Call) Return(dB *gorm.DB) *Client_RawDB_Call {
+	_c.Call.Return(dB)
+	return _c
+}
+
+func (_c *Client_RawDB_Call) RunAndReturn(run func() *gorm.DB) *Client_RawDB_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // RunSQLInTransaction provides a mock function for the type Client
 func (_mock *Client) RunSQLInTransaction(ctx context.Context, coreLogic func(ctx context.Context, tx *gorm.DB) error) error {
 	ret := _mock.Called(ctx, coreLogic)
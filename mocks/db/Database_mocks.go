@@ -40,9 +40,243 @@ func (_m *Database) EXPECT() *Database_Expecter {
 	return &Database_Expecter{mock: &_m.Mock}
 }
 
+// AfterCommit provides a mock function for the type Database
+func (_mock *Database) AfterCommit(fn func()) {
+	_mock.Called(fn)
+	return
+}
+
+// Database_AfterCommit_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'AfterCommit'
+type Database_AfterCommit_Call struct {
+	*mock.Call
+}
+
+// AfterCommit is a helper method to define mock.On call
+//   - fn func()
+func (_e *Database_Expecter) AfterCommit(fn interface{}) *Database_AfterCommit_Call {
+	return &Database_AfterCommit_Call{Call: _e.mock.On("AfterCommit", fn)}
+}
+
+func (_c *Database_AfterCommit_Call) Run(run func(fn func())) *Database_AfterCommit_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 func()
+		if args[0] != nil {
+			arg0 = args[0].(func())
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_AfterCommit_Call) Return() *Database_AfterCommit_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *Database_AfterCommit_Call) RunAndReturn(run func(fn func())) *Database_AfterCommit_Call {
+	_c.Run(run)
+	return _c
+}
+
+// CountEncryptionKeysByState provides a mock function for the type Database
+func (_mock *Database) CountEncryptionKeysByState(ctx context.Context) (map[models.EncryptionKeyStateENUMType]int64, error) {
+	ret := _mock.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountEncryptionKeysByState")
+	}
+
+	var r0 map[models.EncryptionKeyStateENUMType]int64
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context) (map[models.EncryptionKeyStateENUMType]int64, error)); ok {
+		return returnFunc(ctx)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context) map[models.EncryptionKeyStateENUMType]int64); ok {
+		r0 = returnFunc(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[models.EncryptionKeyStateENUMType]int64)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = returnFunc(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// Database_CountEncryptionKeysByState_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CountEncryptionKeysByState'
+type Database_CountEncryptionKeysByState_Call struct {
+	*mock.Call
+}
+
+// CountEncryptionKeysByState is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *Database_Expecter) CountEncryptionKeysByState(ctx interface{}) *Database_CountEncryptionKeysByState_Call {
+	return &Database_CountEncryptionKeysByState_Call{Call: _e.mock.On("CountEncryptionKeysByState", ctx)}
+}
+
+func (_c *Database_CountEncryptionKeysByState_Call) Run(run func(ctx context.Context)) *Database_CountEncryptionKeysByState_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_CountEncryptionKeysByState_Call) Return(encryptionKeyStateENUMTypeInt64Map map[models.EncryptionKeyStateENUMType]int64, err error) *Database_CountEncryptionKeysByState_Call {
+	_c.Call.Return(encryptionKeyStateENUMTypeInt64Map, err)
+	return _c
+}
+
+func (_c *Database_CountEncryptionKeysByState_Call) RunAndReturn(run func(ctx context.Context) (map[models.EncryptionKeyStateENUMType]int64, error)) *Database_CountEncryptionKeysByState_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CountRecordVersions provides a mock function for the type Database
+func (_mock *Database) CountRecordVersions(ctx context.Context, filters db.RecordVersionQueryFilter) (int64, error) {
+	ret := _mock.Called(ctx, filters)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountRecordVersions")
+	}
+
+	var r0 int64
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.RecordVersionQueryFilter) (int64, error)); ok {
+		return returnFunc(ctx, filters)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.RecordVersionQueryFilter) int64); ok {
+		r0 = returnFunc(ctx, filters)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, db.RecordVersionQueryFilter) error); ok {
+		r1 = returnFunc(ctx, filters)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// Database_CountRecordVersions_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CountRecordVersions'
+type Database_CountRecordVersions_Call struct {
+	*mock.Call
+}
+
+// CountRecordVersions is a helper method to define mock.On call
+//   - ctx context.Context
+//   - filters db.RecordVersionQueryFilter
+func (_e *Database_Expecter) CountRecordVersions(ctx interface{}, filters interface{}) *Database_CountRecordVersions_Call {
+	return &Database_CountRecordVersions_Call{Call: _e.mock.On("CountRecordVersions", ctx, filters)}
+}
+
+func (_c *Database_CountRecordVersions_Call) Run(run func(ctx context.Context, filters db.RecordVersionQueryFilter)) *Database_CountRecordVersions_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 db.RecordVersionQueryFilter
+		if args[1] != nil {
+			arg1 = args[1].(db.RecordVersionQueryFilter)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_CountRecordVersions_Call) Return(int64 int64, err error) *Database_CountRecordVersions_Call {
+	_c.Call.Return(int64, err)
+	return _c
+}
+
+func (_c *Database_CountRecordVersions_Call) RunAndReturn(run func(ctx context.Context, filters db.RecordVersionQueryFilter) (int64, error)) *Database_CountRecordVersions_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CountRecords provides a mock function for the type Database
+func (_mock *Database) CountRecords(ctx context.Context, filters db.RecordQueryFilter) (int64, error) {
+	ret := _mock.Called(ctx, filters)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountRecords")
+	}
+
+	var r0 int64
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.RecordQueryFilter) (int64, error)); ok {
+		return returnFunc(ctx, filters)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.RecordQueryFilter) int64); ok {
+		r0 = returnFunc(ctx, filters)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, db.RecordQueryFilter) error); ok {
+		r1 = returnFunc(ctx, filters)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// Database_CountRecords_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CountRecords'
+type Database_CountRecords_Call struct {
+	*mock.Call
+}
+
+// CountRecords is a helper method to define mock.On call
+//   - ctx context.Context
+//   - filters db.RecordQueryFilter
+func (_e *Database_Expecter) CountRecords(ctx interface{}, filters interface{}) *Database_CountRecords_Call {
+	return &Database_CountRecords_Call{Call: _e.mock.On("CountRecords", ctx, filters)}
+}
+
+func (_c *Database_CountRecords_Call) Run(run func(ctx context.Context, filters db.RecordQueryFilter)) *Database_CountRecords_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 db.RecordQueryFilter
+		if args[1] != nil {
+			arg1 = args[1].(db.RecordQueryFilter)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_CountRecords_Call) Return(int64 int64, err error) *Database_CountRecords_Call {
+	_c.Call.Return(int64, err)
+	return _c
+}
+
+func (_c *Database_CountRecords_Call) RunAndReturn(run func(ctx context.Context, filters db.RecordQueryFilter) (int64, error)) *Database_CountRecords_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // DefineNewRecord provides a mock function for the type Database
-func (_mock *Database) DefineNewRecord(ctx context.Context, name string) (models.Record, error) {
-	ret := _mock.Called(ctx, name)
+func (_mock *Database) DefineNewRecord(ctx context.Context, namespace string, name string) (models.Record, error) {
+	ret := _mock.Called(ctx, namespace, name)
 
 	if len(ret) == 0 {
 		panic("no return value specified for DefineNewRecord")
@@ -50,16 +284,16 @@ func (_mock *Database) DefineNewRecord(ctx context.Context, name string) (models
 
 	var r0 models.Record
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (models.Record, error)); ok {
-		return returnFunc(ctx, name)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) (models.Record, error)); ok {
+		return returnFunc(ctx, namespace, name)
 	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string) models.Record); ok {
-		r0 = returnFunc(ctx, name)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) models.Record); ok {
+		r0 = returnFunc(ctx, namespace, name)
 	} else {
 		r0 = ret.Get(0).(models.Record)
 	}
-	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = returnFunc(ctx, name)
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = returnFunc(ctx, namespace, name)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -73,12 +307,13 @@ type Database_DefineNewRecord_Call struct {
 
 // DefineNewRecord is a helper method to define mock.On call
 //   - ctx context.Context
+//   - namespace string
 //   - name string
-func (_e *Database_Expecter) DefineNewRecord(ctx interface{}, name interface{}) *Database_DefineNewRecord_Call {
-	return &Database_DefineNewRecord_Call{Call: _e.mock.On("DefineNewRecord", ctx, name)}
+func (_e *Database_Expecter) DefineNewRecord(ctx interface{}, namespace interface{}, name interface{}) *Database_DefineNewRecord_Call {
+	return &Database_DefineNewRecord_Call{Call: _e.mock.On("DefineNewRecord", ctx, namespace, name)}
 }
 
-func (_c *Database_DefineNewRecord_Call) Run(run func(ctx context.Context, name string)) *Database_DefineNewRecord_Call {
+func (_c *Database_DefineNewRecord_Call) Run(run func(ctx context.Context, namespace string, name string)) *Database_DefineNewRecord_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
@@ -88,9 +323,14 @@ func (_c *Database_DefineNewRecord_Call) Run(run func(ctx context.Context, name
 		if args[1] != nil {
 			arg1 = args[1].(string)
 		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
 		run(
 			arg0,
 			arg1,
+			arg2,
 		)
 	})
 	return _c
@@ -101,14 +341,14 @@ func (_c *Database_DefineNewRecord_Call) Return(record models.Record, err error)
 	return _c
 }
 
-func (_c *Database_DefineNewRecord_Call) RunAndReturn(run func(ctx context.Context, name string) (models.Record, error)) *Database_DefineNewRecord_Call {
+func (_c *Database_DefineNewRecord_Call) RunAndReturn(run func(ctx context.Context, namespace string, name string) (models.Record, error)) *Database_DefineNewRecord_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
 // DefineNewVersionForRecord provides a mock function for the type Database
-func (_mock *Database) DefineNewVersionForRecord(ctx context.Context, record models.Record, encKey models.EncryptionKey, value []byte, nonce []byte, timestamp time.Time) (models.RecordVersion, error) {
-	ret := _mock.Called(ctx, record, encKey, value, nonce, timestamp)
+func (_mock *Database) DefineNewVersionForRecord(ctx context.Context, record models.Record, encKey models.EncryptionKey, value []byte, nonce []byte, timestamp time.Time, opts db.NewVersionOptions) (models.RecordVersion, error) {
+	ret := _mock.Called(ctx, record, encKey, value, nonce, timestamp, opts)
 
 	if len(ret) == 0 {
 		panic("no return value specified for DefineNewVersionForRecord")
@@ -116,16 +356,16 @@ func (_mock *Database) DefineNewVersionForRecord(ctx context.Context, record mod
 
 	var r0 models.RecordVersion
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, models.Record, models.EncryptionKey, []byte, []byte, time.Time) (models.RecordVersion, error)); ok {
-		return returnFunc(ctx, record, encKey, value, nonce, timestamp)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, models.Record, models.EncryptionKey, []byte, []byte, time.Time, db.NewVersionOptions) (models.RecordVersion, error)); ok {
+		return returnFunc(ctx, record, encKey, value, nonce, timestamp, opts)
 	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, models.Record, models.EncryptionKey, []byte, []byte, time.Time) models.RecordVersion); ok {
-		r0 = returnFunc(ctx, record, encKey, value, nonce, timestamp)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, models.Record, models.EncryptionKey, []byte, []byte, time.Time, db.NewVersionOptions) models.RecordVersion); ok {
+		r0 = returnFunc(ctx, record, encKey, value, nonce, timestamp, opts)
 	} else {
 		r0 = ret.Get(0).(models.RecordVersion)
 	}
-	if returnFunc, ok := ret.Get(1).(func(context.Context, models.Record, models.EncryptionKey, []byte, []byte, time.Time) error); ok {
-		r1 = returnFunc(ctx, record, encKey, value, nonce, timestamp)
+	if returnFunc, ok := ret.Get(1).(func(context.Context, models.Record, models.EncryptionKey, []byte, []byte, time.Time, db.NewVersionOptions) error); ok {
+		r1 = returnFunc(ctx, record, encKey, value, nonce, timestamp, opts)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -144,11 +384,12 @@ type Database_DefineNewVersionForRecord_Call struct {
 //   - value []byte
 //   - nonce []byte
 //   - timestamp time.Time
-func (_e *Database_Expecter) DefineNewVersionForRecord(ctx interface{}, record interface{}, encKey interface{}, value interface{}, nonce interface{}, timestamp interface{}) *Database_DefineNewVersionForRecord_Call {
-	return &Database_DefineNewVersionForRecord_Call{Call: _e.mock.On("DefineNewVersionForRecord", ctx, record, encKey, value, nonce, timestamp)}
+//   - opts db.NewVersionOptions
+func (_e *Database_Expecter) DefineNewVersionForRecord(ctx interface{}, record interface{}, encKey interface{}, value interface{}, nonce interface{}, timestamp interface{}, opts interface{}) *Database_DefineNewVersionForRecord_Call {
+	return &Database_DefineNewVersionForRecord_Call{Call: _e.mock.On("DefineNewVersionForRecord", ctx, record, encKey, value, nonce, timestamp, opts)}
 }
 
-func (_c *Database_DefineNewVersionForRecord_Call) Run(run func(ctx context.Context, record models.Record, encKey models.EncryptionKey, value []byte, nonce []byte, timestamp time.Time)) *Database_DefineNewVersionForRecord_Call {
+func (_c *Database_DefineNewVersionForRecord_Call) Run(run func(ctx context.Context, record models.Record, encKey models.EncryptionKey, value []byte, nonce []byte, timestamp time.Time, opts db.NewVersionOptions)) *Database_DefineNewVersionForRecord_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
@@ -174,6 +415,10 @@ func (_c *Database_DefineNewVersionForRecord_Call) Run(run func(ctx context.Cont
 		if args[5] != nil {
 			arg5 = args[5].(time.Time)
 		}
+		var arg6 db.NewVersionOptions
+		if args[6] != nil {
+			arg6 = args[6].(db.NewVersionOptions)
+		}
 		run(
 			arg0,
 			arg1,
@@ -181,6 +426,7 @@ func (_c *Database_DefineNewVersionForRecord_Call) Run(run func(ctx context.Cont
 			arg3,
 			arg4,
 			arg5,
+			arg6,
 		)
 	})
 	return _c
@@ -191,7 +437,7 @@ func (_c *Database_DefineNewVersionForRecord_Call) Return(recordVersion models.R
 	return _c
 }
 
-func (_c *Database_DefineNewVersionForRecord_Call) RunAndReturn(run func(ctx context.Context, record models.Record, encKey models.EncryptionKey, value []byte, nonce []byte, timestamp time.Time) (models.RecordVersion, error)) *Database_DefineNewVersionForRecord_Call {
+func (_c *Database_DefineNewVersionForRecord_Call) RunAndReturn(run func(ctx context.Context, record models.Record, encKey models.EncryptionKey, value []byte, nonce []byte, timestamp time.Time, opts db.NewVersionOptions) (models.RecordVersion, error)) *Database_DefineNewVersionForRecord_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -310,45 +556,36 @@ func (_c *Database_DeleteRecord_Call) RunAndReturn(run func(ctx context.Context,
 	return _c
 }
 
-// GetEncryptionKey provides a mock function for the type Database
-func (_mock *Database) GetEncryptionKey(ctx context.Context, keyID string) (models.EncryptionKey, error) {
-	ret := _mock.Called(ctx, keyID)
+// DeleteRecordVersion provides a mock function for the type Database
+func (_mock *Database) DeleteRecordVersion(ctx context.Context, versionID string) error {
+	ret := _mock.Called(ctx, versionID)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetEncryptionKey")
+		panic("no return value specified for DeleteRecordVersion")
 	}
 
-	var r0 models.EncryptionKey
-	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (models.EncryptionKey, error)); ok {
-		return returnFunc(ctx, keyID)
-	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string) models.EncryptionKey); ok {
-		r0 = returnFunc(ctx, keyID)
-	} else {
-		r0 = ret.Get(0).(models.EncryptionKey)
-	}
-	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = returnFunc(ctx, keyID)
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = returnFunc(ctx, versionID)
 	} else {
-		r1 = ret.Error(1)
+		r0 = ret.Error(0)
 	}
-	return r0, r1
+	return r0
 }
 
-// Database_GetEncryptionKey_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetEncryptionKey'
-type Database_GetEncryptionKey_Call struct {
+// Database_DeleteRecordVersion_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteRecordVersion'
+type Database_DeleteRecordVersion_Call struct {
 	*mock.Call
 }
 
-// GetEncryptionKey is a helper method to define mock.On call
+// DeleteRecordVersion is a helper method to define mock.On call
 //   - ctx context.Context
-//   - keyID string
-func (_e *Database_Expecter) GetEncryptionKey(ctx interface{}, keyID interface{}) *Database_GetEncryptionKey_Call {
-	return &Database_GetEncryptionKey_Call{Call: _e.mock.On("GetEncryptionKey", ctx, keyID)}
+//   - versionID string
+func (_e *Database_Expecter) DeleteRecordVersion(ctx interface{}, versionID interface{}) *Database_DeleteRecordVersion_Call {
+	return &Database_DeleteRecordVersion_Call{Call: _e.mock.On("DeleteRecordVersion", ctx, versionID)}
 }
 
-func (_c *Database_GetEncryptionKey_Call) Run(run func(ctx context.Context, keyID string)) *Database_GetEncryptionKey_Call {
+func (_c *Database_DeleteRecordVersion_Call) Run(run func(ctx context.Context, versionID string)) *Database_DeleteRecordVersion_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
@@ -366,121 +603,179 @@ func (_c *Database_GetEncryptionKey_Call) Run(run func(ctx context.Context, keyI
 	return _c
 }
 
-func (_c *Database_GetEncryptionKey_Call) Return(encryptionKey models.EncryptionKey, err error) *Database_GetEncryptionKey_Call {
-	_c.Call.Return(encryptionKey, err)
+func (_c *Database_DeleteRecordVersion_Call) Return(err error) *Database_DeleteRecordVersion_Call {
+	_c.Call.Return(err)
 	return _c
 }
 
-func (_c *Database_GetEncryptionKey_Call) RunAndReturn(run func(ctx context.Context, keyID string) (models.EncryptionKey, error)) *Database_GetEncryptionKey_Call {
+func (_c *Database_DeleteRecordVersion_Call) RunAndReturn(run func(ctx context.Context, versionID string) error) *Database_DeleteRecordVersion_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// GetRecord provides a mock function for the type Database
-func (_mock *Database) GetRecord(ctx context.Context, recordID string) (models.Record, error) {
-	ret := _mock.Called(ctx, recordID)
+// FindDuplicateRecordNames provides a mock function for the type Database
+func (_mock *Database) FindDuplicateRecordNames(ctx context.Context) (map[string][]string, error) {
+	ret := _mock.Called(ctx)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetRecord")
+		panic("no return value specified for FindDuplicateRecordNames")
 	}
 
-	var r0 models.Record
+	var r0 map[string][]string
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (models.Record, error)); ok {
-		return returnFunc(ctx, recordID)
+	if returnFunc, ok := ret.Get(0).(func(context.Context) (map[string][]string, error)); ok {
+		return returnFunc(ctx)
 	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string) models.Record); ok {
-		r0 = returnFunc(ctx, recordID)
+	if returnFunc, ok := ret.Get(0).(func(context.Context) map[string][]string); ok {
+		r0 = returnFunc(ctx)
 	} else {
-		r0 = ret.Get(0).(models.Record)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string][]string)
+		}
 	}
-	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = returnFunc(ctx, recordID)
+	if returnFunc, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = returnFunc(ctx)
 	} else {
 		r1 = ret.Error(1)
 	}
 	return r0, r1
 }
 
-// Database_GetRecord_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetRecord'
-type Database_GetRecord_Call struct {
+// Database_FindDuplicateRecordNames_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'FindDuplicateRecordNames'
+type Database_FindDuplicateRecordNames_Call struct {
 	*mock.Call
 }
 
-// GetRecord is a helper method to define mock.On call
+// FindDuplicateRecordNames is a helper method to define mock.On call
 //   - ctx context.Context
-//   - recordID string
-func (_e *Database_Expecter) GetRecord(ctx interface{}, recordID interface{}) *Database_GetRecord_Call {
-	return &Database_GetRecord_Call{Call: _e.mock.On("GetRecord", ctx, recordID)}
+func (_e *Database_Expecter) FindDuplicateRecordNames(ctx interface{}) *Database_FindDuplicateRecordNames_Call {
+	return &Database_FindDuplicateRecordNames_Call{Call: _e.mock.On("FindDuplicateRecordNames", ctx)}
 }
 
-func (_c *Database_GetRecord_Call) Run(run func(ctx context.Context, recordID string)) *Database_GetRecord_Call {
+func (_c *Database_FindDuplicateRecordNames_Call) Run(run func(ctx context.Context)) *Database_FindDuplicateRecordNames_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
 			arg0 = args[0].(context.Context)
 		}
-		var arg1 string
-		if args[1] != nil {
-			arg1 = args[1].(string)
-		}
 		run(
 			arg0,
-			arg1,
 		)
 	})
 	return _c
 }
 
-func (_c *Database_GetRecord_Call) Return(record models.Record, err error) *Database_GetRecord_Call {
-	_c.Call.Return(record, err)
+func (_c *Database_FindDuplicateRecordNames_Call) Return(stringStringsMap map[string][]string, err error) *Database_FindDuplicateRecordNames_Call {
+	_c.Call.Return(stringStringsMap, err)
 	return _c
 }
 
-func (_c *Database_GetRecord_Call) RunAndReturn(run func(ctx context.Context, recordID string) (models.Record, error)) *Database_GetRecord_Call {
+func (_c *Database_FindDuplicateRecordNames_Call) RunAndReturn(run func(ctx context.Context) (map[string][]string, error)) *Database_FindDuplicateRecordNames_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// GetRecordByName provides a mock function for the type Database
-func (_mock *Database) GetRecordByName(ctx context.Context, recordName string) (models.Record, error) {
-	ret := _mock.Called(ctx, recordName)
+// FindVersionsWithoutCreationEvent provides a mock function for the type Database
+func (_mock *Database) FindVersionsWithoutCreationEvent(ctx context.Context) ([]string, error) {
+	ret := _mock.Called(ctx)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetRecordByName")
+		panic("no return value specified for FindVersionsWithoutCreationEvent")
 	}
 
-	var r0 models.Record
+	var r0 []string
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (models.Record, error)); ok {
-		return returnFunc(ctx, recordName)
+	if returnFunc, ok := ret.Get(0).(func(context.Context) ([]string, error)); ok {
+		return returnFunc(ctx)
 	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string) models.Record); ok {
-		r0 = returnFunc(ctx, recordName)
+	if returnFunc, ok := ret.Get(0).(func(context.Context) []string); ok {
+		r0 = returnFunc(ctx)
 	} else {
-		r0 = ret.Get(0).(models.Record)
-	}
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = returnFunc(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// Database_FindVersionsWithoutCreationEvent_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'FindVersionsWithoutCreationEvent'
+type Database_FindVersionsWithoutCreationEvent_Call struct {
+	*mock.Call
+}
+
+// FindVersionsWithoutCreationEvent is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *Database_Expecter) FindVersionsWithoutCreationEvent(ctx interface{}) *Database_FindVersionsWithoutCreationEvent_Call {
+	return &Database_FindVersionsWithoutCreationEvent_Call{Call: _e.mock.On("FindVersionsWithoutCreationEvent", ctx)}
+}
+
+func (_c *Database_FindVersionsWithoutCreationEvent_Call) Run(run func(ctx context.Context)) *Database_FindVersionsWithoutCreationEvent_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_FindVersionsWithoutCreationEvent_Call) Return(strings []string, err error) *Database_FindVersionsWithoutCreationEvent_Call {
+	_c.Call.Return(strings, err)
+	return _c
+}
+
+func (_c *Database_FindVersionsWithoutCreationEvent_Call) RunAndReturn(run func(ctx context.Context) ([]string, error)) *Database_FindVersionsWithoutCreationEvent_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetEncryptionKey provides a mock function for the type Database
+func (_mock *Database) GetEncryptionKey(ctx context.Context, keyID string) (models.EncryptionKey, error) {
+	ret := _mock.Called(ctx, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetEncryptionKey")
+	}
+
+	var r0 models.EncryptionKey
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (models.EncryptionKey, error)); ok {
+		return returnFunc(ctx, keyID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) models.EncryptionKey); ok {
+		r0 = returnFunc(ctx, keyID)
+	} else {
+		r0 = ret.Get(0).(models.EncryptionKey)
+	}
 	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = returnFunc(ctx, recordName)
+		r1 = returnFunc(ctx, keyID)
 	} else {
 		r1 = ret.Error(1)
 	}
 	return r0, r1
 }
 
-// Database_GetRecordByName_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetRecordByName'
-type Database_GetRecordByName_Call struct {
+// Database_GetEncryptionKey_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetEncryptionKey'
+type Database_GetEncryptionKey_Call struct {
 	*mock.Call
 }
 
-// GetRecordByName is a helper method to define mock.On call
+// GetEncryptionKey is a helper method to define mock.On call
 //   - ctx context.Context
-//   - recordName string
-func (_e *Database_Expecter) GetRecordByName(ctx interface{}, recordName interface{}) *Database_GetRecordByName_Call {
-	return &Database_GetRecordByName_Call{Call: _e.mock.On("GetRecordByName", ctx, recordName)}
+//   - keyID string
+func (_e *Database_Expecter) GetEncryptionKey(ctx interface{}, keyID interface{}) *Database_GetEncryptionKey_Call {
+	return &Database_GetEncryptionKey_Call{Call: _e.mock.On("GetEncryptionKey", ctx, keyID)}
 }
 
-func (_c *Database_GetRecordByName_Call) Run(run func(ctx context.Context, recordName string)) *Database_GetRecordByName_Call {
+func (_c *Database_GetEncryptionKey_Call) Run(run func(ctx context.Context, keyID string)) *Database_GetEncryptionKey_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
@@ -498,55 +793,55 @@ func (_c *Database_GetRecordByName_Call) Run(run func(ctx context.Context, recor
 	return _c
 }
 
-func (_c *Database_GetRecordByName_Call) Return(record models.Record, err error) *Database_GetRecordByName_Call {
-	_c.Call.Return(record, err)
+func (_c *Database_GetEncryptionKey_Call) Return(encryptionKey models.EncryptionKey, err error) *Database_GetEncryptionKey_Call {
+	_c.Call.Return(encryptionKey, err)
 	return _c
 }
 
-func (_c *Database_GetRecordByName_Call) RunAndReturn(run func(ctx context.Context, recordName string) (models.Record, error)) *Database_GetRecordByName_Call {
+func (_c *Database_GetEncryptionKey_Call) RunAndReturn(run func(ctx context.Context, keyID string) (models.EncryptionKey, error)) *Database_GetEncryptionKey_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// GetRecordVersion provides a mock function for the type Database
-func (_mock *Database) GetRecordVersion(ctx context.Context, versionID string) (models.RecordVersion, error) {
-	ret := _mock.Called(ctx, versionID)
+// GetLatestVersionKey provides a mock function for the type Database
+func (_mock *Database) GetLatestVersionKey(ctx context.Context, recordID string) (models.EncryptionKey, error) {
+	ret := _mock.Called(ctx, recordID)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetRecordVersion")
+		panic("no return value specified for GetLatestVersionKey")
 	}
 
-	var r0 models.RecordVersion
+	var r0 models.EncryptionKey
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (models.RecordVersion, error)); ok {
-		return returnFunc(ctx, versionID)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (models.EncryptionKey, error)); ok {
+		return returnFunc(ctx, recordID)
 	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string) models.RecordVersion); ok {
-		r0 = returnFunc(ctx, versionID)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) models.EncryptionKey); ok {
+		r0 = returnFunc(ctx, recordID)
 	} else {
-		r0 = ret.Get(0).(models.RecordVersion)
+		r0 = ret.Get(0).(models.EncryptionKey)
 	}
 	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = returnFunc(ctx, versionID)
+		r1 = returnFunc(ctx, recordID)
 	} else {
 		r1 = ret.Error(1)
 	}
 	return r0, r1
 }
 
-// Database_GetRecordVersion_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetRecordVersion'
-type Database_GetRecordVersion_Call struct {
+// Database_GetLatestVersionKey_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetLatestVersionKey'
+type Database_GetLatestVersionKey_Call struct {
 	*mock.Call
 }
 
-// GetRecordVersion is a helper method to define mock.On call
+// GetLatestVersionKey is a helper method to define mock.On call
 //   - ctx context.Context
-//   - versionID string
-func (_e *Database_Expecter) GetRecordVersion(ctx interface{}, versionID interface{}) *Database_GetRecordVersion_Call {
-	return &Database_GetRecordVersion_Call{Call: _e.mock.On("GetRecordVersion", ctx, versionID)}
+//   - recordID string
+func (_e *Database_Expecter) GetLatestVersionKey(ctx interface{}, recordID interface{}) *Database_GetLatestVersionKey_Call {
+	return &Database_GetLatestVersionKey_Call{Call: _e.mock.On("GetLatestVersionKey", ctx, recordID)}
 }
 
-func (_c *Database_GetRecordVersion_Call) Run(run func(ctx context.Context, versionID string)) *Database_GetRecordVersion_Call {
+func (_c *Database_GetLatestVersionKey_Call) Run(run func(ctx context.Context, recordID string)) *Database_GetLatestVersionKey_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
@@ -564,193 +859,209 @@ func (_c *Database_GetRecordVersion_Call) Run(run func(ctx context.Context, vers
 	return _c
 }
 
-func (_c *Database_GetRecordVersion_Call) Return(recordVersion models.RecordVersion, err error) *Database_GetRecordVersion_Call {
-	_c.Call.Return(recordVersion, err)
+func (_c *Database_GetLatestVersionKey_Call) Return(encryptionKey models.EncryptionKey, err error) *Database_GetLatestVersionKey_Call {
+	_c.Call.Return(encryptionKey, err)
 	return _c
 }
 
-func (_c *Database_GetRecordVersion_Call) RunAndReturn(run func(ctx context.Context, versionID string) (models.RecordVersion, error)) *Database_GetRecordVersion_Call {
+func (_c *Database_GetLatestVersionKey_Call) RunAndReturn(run func(ctx context.Context, recordID string) (models.EncryptionKey, error)) *Database_GetLatestVersionKey_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// GetSystemParamEntry provides a mock function for the type Database
-func (_mock *Database) GetSystemParamEntry(ctx context.Context) (models.SystemParams, error) {
-	ret := _mock.Called(ctx)
+// GetLatestVersionsOfRecords provides a mock function for the type Database
+func (_mock *Database) GetLatestVersionsOfRecords(ctx context.Context, recordIDs []string) (map[string]models.RecordVersion, error) {
+	ret := _mock.Called(ctx, recordIDs)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetSystemParamEntry")
+		panic("no return value specified for GetLatestVersionsOfRecords")
 	}
 
-	var r0 models.SystemParams
+	var r0 map[string]models.RecordVersion
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context) (models.SystemParams, error)); ok {
-		return returnFunc(ctx)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []string) (map[string]models.RecordVersion, error)); ok {
+		return returnFunc(ctx, recordIDs)
 	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context) models.SystemParams); ok {
-		r0 = returnFunc(ctx)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []string) map[string]models.RecordVersion); ok {
+		r0 = returnFunc(ctx, recordIDs)
 	} else {
-		r0 = ret.Get(0).(models.SystemParams)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]models.RecordVersion)
+		}
 	}
-	if returnFunc, ok := ret.Get(1).(func(context.Context) error); ok {
-		r1 = returnFunc(ctx)
+	if returnFunc, ok := ret.Get(1).(func(context.Context, []string) error); ok {
+		r1 = returnFunc(ctx, recordIDs)
 	} else {
 		r1 = ret.Error(1)
 	}
 	return r0, r1
 }
 
-// Database_GetSystemParamEntry_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetSystemParamEntry'
-type Database_GetSystemParamEntry_Call struct {
+// Database_GetLatestVersionsOfRecords_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetLatestVersionsOfRecords'
+type Database_GetLatestVersionsOfRecords_Call struct {
 	*mock.Call
 }
 
-// GetSystemParamEntry is a helper method to define mock.On call
+// GetLatestVersionsOfRecords is a helper method to define mock.On call
 //   - ctx context.Context
-func (_e *Database_Expecter) GetSystemParamEntry(ctx interface{}) *Database_GetSystemParamEntry_Call {
-	return &Database_GetSystemParamEntry_Call{Call: _e.mock.On("GetSystemParamEntry", ctx)}
+//   - recordIDs []string
+func (_e *Database_Expecter) GetLatestVersionsOfRecords(ctx interface{}, recordIDs interface{}) *Database_GetLatestVersionsOfRecords_Call {
+	return &Database_GetLatestVersionsOfRecords_Call{Call: _e.mock.On("GetLatestVersionsOfRecords", ctx, recordIDs)}
 }
 
-func (_c *Database_GetSystemParamEntry_Call) Run(run func(ctx context.Context)) *Database_GetSystemParamEntry_Call {
+func (_c *Database_GetLatestVersionsOfRecords_Call) Run(run func(ctx context.Context, recordIDs []string)) *Database_GetLatestVersionsOfRecords_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
 			arg0 = args[0].(context.Context)
 		}
+		var arg1 []string
+		if args[1] != nil {
+			arg1 = args[1].([]string)
+		}
 		run(
 			arg0,
+			arg1,
 		)
 	})
 	return _c
 }
 
-func (_c *Database_GetSystemParamEntry_Call) Return(systemParams models.SystemParams, err error) *Database_GetSystemParamEntry_Call {
-	_c.Call.Return(systemParams, err)
+func (_c *Database_GetLatestVersionsOfRecords_Call) Return(stringRecordVersionMap map[string]models.RecordVersion, err error) *Database_GetLatestVersionsOfRecords_Call {
+	_c.Call.Return(stringRecordVersionMap, err)
 	return _c
 }
 
-func (_c *Database_GetSystemParamEntry_Call) RunAndReturn(run func(ctx context.Context) (models.SystemParams, error)) *Database_GetSystemParamEntry_Call {
+func (_c *Database_GetLatestVersionsOfRecords_Call) RunAndReturn(run func(ctx context.Context, recordIDs []string) (map[string]models.RecordVersion, error)) *Database_GetLatestVersionsOfRecords_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// ListAllRecordVersions provides a mock function for the type Database
-func (_mock *Database) ListAllRecordVersions(ctx context.Context, filters db.RecordVersionQueryFilter) ([]models.RecordVersion, error) {
-	ret := _mock.Called(ctx, filters)
+// GetNthVersionOfRecord provides a mock function for the type Database
+func (_mock *Database) GetNthVersionOfRecord(ctx context.Context, record models.Record, index int, filters db.RecordVersionQueryFilter) (models.RecordVersion, error) {
+	ret := _mock.Called(ctx, record, index, filters)
 
 	if len(ret) == 0 {
-		panic("no return value specified for ListAllRecordVersions")
+		panic("no return value specified for GetNthVersionOfRecord")
 	}
 
-	var r0 []models.RecordVersion
+	var r0 models.RecordVersion
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, db.RecordVersionQueryFilter) ([]models.RecordVersion, error)); ok {
-		return returnFunc(ctx, filters)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, models.Record, int, db.RecordVersionQueryFilter) (models.RecordVersion, error)); ok {
+		return returnFunc(ctx, record, index, filters)
 	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, db.RecordVersionQueryFilter) []models.RecordVersion); ok {
-		r0 = returnFunc(ctx, filters)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, models.Record, int, db.RecordVersionQueryFilter) models.RecordVersion); ok {
+		r0 = returnFunc(ctx, record, index, filters)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]models.RecordVersion)
-		}
+		r0 = ret.Get(0).(models.RecordVersion)
 	}
-	if returnFunc, ok := ret.Get(1).(func(context.Context, db.RecordVersionQueryFilter) error); ok {
-		r1 = returnFunc(ctx, filters)
+	if returnFunc, ok := ret.Get(1).(func(context.Context, models.Record, int, db.RecordVersionQueryFilter) error); ok {
+		r1 = returnFunc(ctx, record, index, filters)
 	} else {
 		r1 = ret.Error(1)
 	}
 	return r0, r1
 }
 
-// Database_ListAllRecordVersions_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListAllRecordVersions'
-type Database_ListAllRecordVersions_Call struct {
+// Database_GetNthVersionOfRecord_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetNthVersionOfRecord'
+type Database_GetNthVersionOfRecord_Call struct {
 	*mock.Call
 }
 
-// ListAllRecordVersions is a helper method to define mock.On call
+// GetNthVersionOfRecord is a helper method to define mock.On call
 //   - ctx context.Context
+//   - record models.Record
+//   - index int
 //   - filters db.RecordVersionQueryFilter
-func (_e *Database_Expecter) ListAllRecordVersions(ctx interface{}, filters interface{}) *Database_ListAllRecordVersions_Call {
-	return &Database_ListAllRecordVersions_Call{Call: _e.mock.On("ListAllRecordVersions", ctx, filters)}
+func (_e *Database_Expecter) GetNthVersionOfRecord(ctx interface{}, record interface{}, index interface{}, filters interface{}) *Database_GetNthVersionOfRecord_Call {
+	return &Database_GetNthVersionOfRecord_Call{Call: _e.mock.On("GetNthVersionOfRecord", ctx, record, index, filters)}
 }
 
-func (_c *Database_ListAllRecordVersions_Call) Run(run func(ctx context.Context, filters db.RecordVersionQueryFilter)) *Database_ListAllRecordVersions_Call {
+func (_c *Database_GetNthVersionOfRecord_Call) Run(run func(ctx context.Context, record models.Record, index int, filters db.RecordVersionQueryFilter)) *Database_GetNthVersionOfRecord_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
 			arg0 = args[0].(context.Context)
 		}
-		var arg1 db.RecordVersionQueryFilter
+		var arg1 models.Record
 		if args[1] != nil {
-			arg1 = args[1].(db.RecordVersionQueryFilter)
+			arg1 = args[1].(models.Record)
+		}
+		var arg2 int
+		if args[2] != nil {
+			arg2 = args[2].(int)
+		}
+		var arg3 db.RecordVersionQueryFilter
+		if args[3] != nil {
+			arg3 = args[3].(db.RecordVersionQueryFilter)
 		}
 		run(
 			arg0,
 			arg1,
+			arg2,
+			arg3,
 		)
 	})
 	return _c
 }
 
-func (_c *Database_ListAllRecordVersions_Call) Return(recordVersions []models.RecordVersion, err error) *Database_ListAllRecordVersions_Call {
-	_c.Call.Return(recordVersions, err)
+func (_c *Database_GetNthVersionOfRecord_Call) Return(recordVersion models.RecordVersion, err error) *Database_GetNthVersionOfRecord_Call {
+	_c.Call.Return(recordVersion, err)
 	return _c
 }
 
-func (_c *Database_ListAllRecordVersions_Call) RunAndReturn(run func(ctx context.Context, filters db.RecordVersionQueryFilter) ([]models.RecordVersion, error)) *Database_ListAllRecordVersions_Call {
+func (_c *Database_GetNthVersionOfRecord_Call) RunAndReturn(run func(ctx context.Context, record models.Record, index int, filters db.RecordVersionQueryFilter) (models.RecordVersion, error)) *Database_GetNthVersionOfRecord_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// ListEncryptionKeys provides a mock function for the type Database
-func (_mock *Database) ListEncryptionKeys(ctx context.Context, filters db.EncryptionKeyQueryFilter) ([]models.EncryptionKey, error) {
-	ret := _mock.Called(ctx, filters)
+// GetRecord provides a mock function for the type Database
+func (_mock *Database) GetRecord(ctx context.Context, recordID string) (models.Record, error) {
+	ret := _mock.Called(ctx, recordID)
 
 	if len(ret) == 0 {
-		panic("no return value specified for ListEncryptionKeys")
+		panic("no return value specified for GetRecord")
 	}
 
-	var r0 []models.EncryptionKey
+	var r0 models.Record
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, db.EncryptionKeyQueryFilter) ([]models.EncryptionKey, error)); ok {
-		return returnFunc(ctx, filters)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (models.Record, error)); ok {
+		return returnFunc(ctx, recordID)
 	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, db.EncryptionKeyQueryFilter) []models.EncryptionKey); ok {
-		r0 = returnFunc(ctx, filters)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) models.Record); ok {
+		r0 = returnFunc(ctx, recordID)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]models.EncryptionKey)
-		}
+		r0 = ret.Get(0).(models.Record)
 	}
-	if returnFunc, ok := ret.Get(1).(func(context.Context, db.EncryptionKeyQueryFilter) error); ok {
-		r1 = returnFunc(ctx, filters)
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, recordID)
 	} else {
 		r1 = ret.Error(1)
 	}
 	return r0, r1
 }
 
-// Database_ListEncryptionKeys_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListEncryptionKeys'
-type Database_ListEncryptionKeys_Call struct {
+// Database_GetRecord_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetRecord'
+type Database_GetRecord_Call struct {
 	*mock.Call
 }
 
-// ListEncryptionKeys is a helper method to define mock.On call
+// GetRecord is a helper method to define mock.On call
 //   - ctx context.Context
-//   - filters db.EncryptionKeyQueryFilter
-func (_e *Database_Expecter) ListEncryptionKeys(ctx interface{}, filters interface{}) *Database_ListEncryptionKeys_Call {
-	return &Database_ListEncryptionKeys_Call{Call: _e.mock.On("ListEncryptionKeys", ctx, filters)}
+//   - recordID string
+func (_e *Database_Expecter) GetRecord(ctx interface{}, recordID interface{}) *Database_GetRecord_Call {
+	return &Database_GetRecord_Call{Call: _e.mock.On("GetRecord", ctx, recordID)}
 }
 
-func (_c *Database_ListEncryptionKeys_Call) Run(run func(ctx context.Context, filters db.EncryptionKeyQueryFilter)) *Database_ListEncryptionKeys_Call {
+func (_c *Database_GetRecord_Call) Run(run func(ctx context.Context, recordID string)) *Database_GetRecord_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
 			arg0 = args[0].(context.Context)
 		}
-		var arg1 db.EncryptionKeyQueryFilter
+		var arg1 string
 		if args[1] != nil {
-			arg1 = args[1].(db.EncryptionKeyQueryFilter)
+			arg1 = args[1].(string)
 		}
 		run(
 			arg0,
@@ -760,65 +1071,1903 @@ func (_c *Database_ListEncryptionKeys_Call) Run(run func(ctx context.Context, fi
 	return _c
 }
 
-func (_c *Database_ListEncryptionKeys_Call) Return(encryptionKeys []models.EncryptionKey, err error) *Database_ListEncryptionKeys_Call {
-	_c.Call.Return(encryptionKeys, err)
+func (_c *Database_GetRecord_Call) Return(record models.Record, err error) *Database_GetRecord_Call {
+	_c.Call.Return(record, err)
 	return _c
 }
 
-func (_c *Database_ListEncryptionKeys_Call) RunAndReturn(run func(ctx context.Context, filters db.EncryptionKeyQueryFilter) ([]models.EncryptionKey, error)) *Database_ListEncryptionKeys_Call {
+func (_c *Database_GetRecord_Call) RunAndReturn(run func(ctx context.Context, recordID string) (models.Record, error)) *Database_GetRecord_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// ListRecords provides a mock function for the type Database
-func (_mock *Database) ListRecords(ctx context.Context, filters db.RecordQueryFilter) ([]models.Record, error) {
-	ret := _mock.Called(ctx, filters)
+// GetRecordByName provides a mock function for the type Database
+func (_mock *Database) GetRecordByName(ctx context.Context, namespace string, recordName string) (models.Record, error) {
+	ret := _mock.Called(ctx, namespace, recordName)
 
 	if len(ret) == 0 {
-		panic("no return value specified for ListRecords")
+		panic("no return value specified for GetRecordByName")
 	}
 
-	var r0 []models.Record
+	var r0 models.Record
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, db.RecordQueryFilter) ([]models.Record, error)); ok {
-		return returnFunc(ctx, filters)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) (models.Record, error)); ok {
+		return returnFunc(ctx, namespace, recordName)
 	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, db.RecordQueryFilter) []models.Record); ok {
-		r0 = returnFunc(ctx, filters)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) models.Record); ok {
+		r0 = returnFunc(ctx, namespace, recordName)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]models.Record)
-		}
+		r0 = ret.Get(0).(models.Record)
 	}
-	if returnFunc, ok := ret.Get(1).(func(context.Context, db.RecordQueryFilter) error); ok {
-		r1 = returnFunc(ctx, filters)
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = returnFunc(ctx, namespace, recordName)
 	} else {
 		r1 = ret.Error(1)
 	}
 	return r0, r1
 }
 
-// Database_ListRecords_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListRecords'
-type Database_ListRecords_Call struct {
+// Database_GetRecordByName_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetRecordByName'
+type Database_GetRecordByName_Call struct {
 	*mock.Call
 }
 
-// ListRecords is a helper method to define mock.On call
+// GetRecordByName is a helper method to define mock.On call
 //   - ctx context.Context
-//   - filters db.RecordQueryFilter
-func (_e *Database_Expecter) ListRecords(ctx interface{}, filters interface{}) *Database_ListRecords_Call {
-	return &Database_ListRecords_Call{Call: _e.mock.On("ListRecords", ctx, filters)}
+//   - namespace string
+//   - recordName string
+func (_e *Database_Expecter) GetRecordByName(ctx interface{}, namespace interface{}, recordName interface{}) *Database_GetRecordByName_Call {
+	return &Database_GetRecordByName_Call{Call: _e.mock.On("GetRecordByName", ctx, namespace, recordName)}
 }
 
-func (_c *Database_ListRecords_Call) Run(run func(ctx context.Context, filters db.RecordQueryFilter)) *Database_ListRecords_Call {
+func (_c *Database_GetRecordByName_Call) Run(run func(ctx context.Context, namespace string, recordName string)) *Database_GetRecordByName_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
 			arg0 = args[0].(context.Context)
 		}
-		var arg1 db.RecordQueryFilter
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_GetRecordByName_Call) Return(record models.Record, err error) *Database_GetRecordByName_Call {
+	_c.Call.Return(record, err)
+	return _c
+}
+
+func (_c *Database_GetRecordByName_Call) RunAndReturn(run func(ctx context.Context, namespace string, recordName string) (models.Record, error)) *Database_GetRecordByName_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetRecordMetadata provides a mock function for the type Database
+func (_mock *Database) GetRecordMetadata(ctx context.Context, recordID string) (map[string]string, error) {
+	ret := _mock.Called(ctx, recordID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRecordMetadata")
+	}
+
+	var r0 map[string]string
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (map[string]string, error)); ok {
+		return returnFunc(ctx, recordID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) map[string]string); ok {
+		r0 = returnFunc(ctx, recordID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]string)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, recordID)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// Database_GetRecordMetadata_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetRecordMetadata'
+type Database_GetRecordMetadata_Call struct {
+	*mock.Call
+}
+
+// GetRecordMetadata is a helper method to define mock.On call
+//   - ctx context.Context
+//   - recordID string
+func (_e *Database_Expecter) GetRecordMetadata(ctx interface{}, recordID interface{}) *Database_GetRecordMetadata_Call {
+	return &Database_GetRecordMetadata_Call{Call: _e.mock.On("GetRecordMetadata", ctx, recordID)}
+}
+
+func (_c *Database_GetRecordMetadata_Call) Run(run func(ctx context.Context, recordID string)) *Database_GetRecordMetadata_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_GetRecordMetadata_Call) Return(stringStringMap map[string]string, err error) *Database_GetRecordMetadata_Call {
+	_c.Call.Return(stringStringMap, err)
+	return _c
+}
+
+func (_c *Database_GetRecordMetadata_Call) RunAndReturn(run func(ctx context.Context, recordID string) (map[string]string, error)) *Database_GetRecordMetadata_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetRecordVersion provides a mock function for the type Database
+func (_mock *Database) GetRecordVersion(ctx context.Context, versionID string) (models.RecordVersion, error) {
+	ret := _mock.Called(ctx, versionID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRecordVersion")
+	}
+
+	var r0 models.RecordVersion
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (models.RecordVersion, error)); ok {
+		return returnFunc(ctx, versionID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) models.RecordVersion); ok {
+		r0 = returnFunc(ctx, versionID)
+	} else {
+		r0 = ret.Get(0).(models.RecordVersion)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, versionID)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// Database_GetRecordVersion_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetRecordVersion'
+type Database_GetRecordVersion_Call struct {
+	*mock.Call
+}
+
+// GetRecordVersion is a helper method to define mock.On call
+//   - ctx context.Context
+//   - versionID string
+func (_e *Database_Expecter) GetRecordVersion(ctx interface{}, versionID interface{}) *Database_GetRecordVersion_Call {
+	return &Database_GetRecordVersion_Call{Call: _e.mock.On("GetRecordVersion", ctx, versionID)}
+}
+
+func (_c *Database_GetRecordVersion_Call) Run(run func(ctx context.Context, versionID string)) *Database_GetRecordVersion_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_GetRecordVersion_Call) Return(recordVersion models.RecordVersion, err error) *Database_GetRecordVersion_Call {
+	_c.Call.Return(recordVersion, err)
+	return _c
+}
+
+func (_c *Database_GetRecordVersion_Call) RunAndReturn(run func(ctx context.Context, versionID string) (models.RecordVersion, error)) *Database_GetRecordVersion_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetRecordsByName provides a mock function for the type Database
+func (_mock *Database) GetRecordsByName(ctx context.Context, namespace string, recordNames []string) ([]models.Record, error) {
+	ret := _mock.Called(ctx, namespace, recordNames)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRecordsByName")
+	}
+
+	var r0 []models.Record
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []string) ([]models.Record, error)); ok {
+		return returnFunc(ctx, namespace, recordNames)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []string) []models.Record); ok {
+		r0 = returnFunc(ctx, namespace, recordNames)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Record)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, []string) error); ok {
+		r1 = returnFunc(ctx, namespace, recordNames)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// Database_GetRecordsByName_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetRecordsByName'
+type Database_GetRecordsByName_Call struct {
+	*mock.Call
+}
+
+// GetRecordsByName is a helper method to define mock.On call
+//   - ctx context.Context
+//   - namespace string
+//   - recordNames []string
+func (_e *Database_Expecter) GetRecordsByName(ctx interface{}, namespace interface{}, recordNames interface{}) *Database_GetRecordsByName_Call {
+	return &Database_GetRecordsByName_Call{Call: _e.mock.On("GetRecordsByName", ctx, namespace, recordNames)}
+}
+
+func (_c *Database_GetRecordsByName_Call) Run(run func(ctx context.Context, namespace string, recordNames []string)) *Database_GetRecordsByName_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 []string
+		if args[2] != nil {
+			arg2 = args[2].([]string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_GetRecordsByName_Call) Return(records []models.Record, err error) *Database_GetRecordsByName_Call {
+	_c.Call.Return(records, err)
+	return _c
+}
+
+func (_c *Database_GetRecordsByName_Call) RunAndReturn(run func(ctx context.Context, namespace string, recordNames []string) ([]models.Record, error)) *Database_GetRecordsByName_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetSystemParamEntry provides a mock function for the type Database
+func (_mock *Database) GetSystemParamEntry(ctx context.Context) (models.SystemParams, error) {
+	ret := _mock.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetSystemParamEntry")
+	}
+
+	var r0 models.SystemParams
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context) (models.SystemParams, error)); ok {
+		return returnFunc(ctx)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context) models.SystemParams); ok {
+		r0 = returnFunc(ctx)
+	} else {
+		r0 = ret.Get(0).(models.SystemParams)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = returnFunc(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// Database_GetSystemParamEntry_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetSystemParamEntry'
+type Database_GetSystemParamEntry_Call struct {
+	*mock.Call
+}
+
+// GetSystemParamEntry is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *Database_Expecter) GetSystemParamEntry(ctx interface{}) *Database_GetSystemParamEntry_Call {
+	return &Database_GetSystemParamEntry_Call{Call: _e.mock.On("GetSystemParamEntry", ctx)}
+}
+
+func (_c *Database_GetSystemParamEntry_Call) Run(run func(ctx context.Context)) *Database_GetSystemParamEntry_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_GetSystemParamEntry_Call) Return(systemParams models.SystemParams, err error) *Database_GetSystemParamEntry_Call {
+	_c.Call.Return(systemParams, err)
+	return _c
+}
+
+func (_c *Database_GetSystemParamEntry_Call) RunAndReturn(run func(ctx context.Context) (models.SystemParams, error)) *Database_GetSystemParamEntry_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetSystemStateHistory provides a mock function for the type Database
+func (_mock *Database) GetSystemStateHistory(ctx context.Context) ([]models.SystemStateEvent, error) {
+	ret := _mock.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetSystemStateHistory")
+	}
+
+	var r0 []models.SystemStateEvent
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context) ([]models.SystemStateEvent, error)); ok {
+		return returnFunc(ctx)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context) []models.SystemStateEvent); ok {
+		r0 = returnFunc(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.SystemStateEvent)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = returnFunc(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// Database_GetSystemStateHistory_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetSystemStateHistory'
+type Database_GetSystemStateHistory_Call struct {
+	*mock.Call
+}
+
+// GetSystemStateHistory is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *Database_Expecter) GetSystemStateHistory(ctx interface{}) *Database_GetSystemStateHistory_Call {
+	return &Database_GetSystemStateHistory_Call{Call: _e.mock.On("GetSystemStateHistory", ctx)}
+}
+
+func (_c *Database_GetSystemStateHistory_Call) Run(run func(ctx context.Context)) *Database_GetSystemStateHistory_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_GetSystemStateHistory_Call) Return(systemStateEvents []models.SystemStateEvent, err error) *Database_GetSystemStateHistory_Call {
+	_c.Call.Return(systemStateEvents, err)
+	return _c
+}
+
+func (_c *Database_GetSystemStateHistory_Call) RunAndReturn(run func(ctx context.Context) ([]models.SystemStateEvent, error)) *Database_GetSystemStateHistory_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListAllRecordVersions provides a mock function for the type Database
+func (_mock *Database) ListAllRecordVersions(ctx context.Context, filters db.RecordVersionQueryFilter) ([]models.RecordVersion, error) {
+	ret := _mock.Called(ctx, filters)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListAllRecordVersions")
+	}
+
+	var r0 []models.RecordVersion
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.RecordVersionQueryFilter) ([]models.RecordVersion, error)); ok {
+		return returnFunc(ctx, filters)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.RecordVersionQueryFilter) []models.RecordVersion); ok {
+		r0 = returnFunc(ctx, filters)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.RecordVersion)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, db.RecordVersionQueryFilter) error); ok {
+		r1 = returnFunc(ctx, filters)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// Database_ListAllRecordVersions_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListAllRecordVersions'
+type Database_ListAllRecordVersions_Call struct {
+	*mock.Call
+}
+
+// ListAllRecordVersions is a helper method to define mock.On call
+//   - ctx context.Context
+//   - filters db.RecordVersionQueryFilter
+func (_e *Database_Expecter) ListAllRecordVersions(ctx interface{}, filters interface{}) *Database_ListAllRecordVersions_Call {
+	return &Database_ListAllRecordVersions_Call{Call: _e.mock.On("ListAllRecordVersions", ctx, filters)}
+}
+
+func (_c *Database_ListAllRecordVersions_Call) Run(run func(ctx context.Context, filters db.RecordVersionQueryFilter)) *Database_ListAllRecordVersions_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 db.RecordVersionQueryFilter
+		if args[1] != nil {
+			arg1 = args[1].(db.RecordVersionQueryFilter)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_ListAllRecordVersions_Call) Return(recordVersions []models.RecordVersion, err error) *Database_ListAllRecordVersions_Call {
+	_c.Call.Return(recordVersions, err)
+	return _c
+}
+
+func (_c *Database_ListAllRecordVersions_Call) RunAndReturn(run func(ctx context.Context, filters db.RecordVersionQueryFilter) ([]models.RecordVersion, error)) *Database_ListAllRecordVersions_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListEncryptionKeys provides a mock function for the type Database
+func (_mock *Database) ListEncryptionKeys(ctx context.Context, filters db.EncryptionKeyQueryFilter) ([]models.EncryptionKey, error) {
+	ret := _mock.Called(ctx, filters)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListEncryptionKeys")
+	}
+
+	var r0 []models.EncryptionKey
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.EncryptionKeyQueryFilter) ([]models.EncryptionKey, error)); ok {
+		return returnFunc(ctx, filters)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.EncryptionKeyQueryFilter) []models.EncryptionKey); ok {
+		r0 = returnFunc(ctx, filters)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.EncryptionKey)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, db.EncryptionKeyQueryFilter) error); ok {
+		r1 = returnFunc(ctx, filters)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// Database_ListEncryptionKeys_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListEncryptionKeys'
+type Database_ListEncryptionKeys_Call struct {
+	*mock.Call
+}
+
+// ListEncryptionKeys is a helper method to define mock.On call
+//   - ctx context.Context
+//   - filters db.EncryptionKeyQueryFilter
+func (_e *Database_Expecter) ListEncryptionKeys(ctx interface{}, filters interface{}) *Database_ListEncryptionKeys_Call {
+	return &Database_ListEncryptionKeys_Call{Call: _e.mock.On("ListEncryptionKeys", ctx, filters)}
+}
+
+func (_c *Database_ListEncryptionKeys_Call) Run(run func(ctx context.Context, filters db.EncryptionKeyQueryFilter)) *Database_ListEncryptionKeys_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 db.EncryptionKeyQueryFilter
+		if args[1] != nil {
+			arg1 = args[1].(db.EncryptionKeyQueryFilter)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_ListEncryptionKeys_Call) Return(encryptionKeys []models.EncryptionKey, err error) *Database_ListEncryptionKeys_Call {
+	_c.Call.Return(encryptionKeys, err)
+	return _c
+}
+
+func (_c *Database_ListEncryptionKeys_Call) RunAndReturn(run func(ctx context.Context, filters db.EncryptionKeyQueryFilter) ([]models.EncryptionKey, error)) *Database_ListEncryptionKeys_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListGlobalVersionTimeline provides a mock function for the type Database
+func (_mock *Database) ListGlobalVersionTimeline(ctx context.Context, filters db.RecordVersionQueryFilter) ([]db.VersionWithName, error) {
+	ret := _mock.Called(ctx, filters)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListGlobalVersionTimeline")
+	}
+
+	var r0 []db.VersionWithName
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.RecordVersionQueryFilter) ([]db.VersionWithName, error)); ok {
+		return returnFunc(ctx, filters)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.RecordVersionQueryFilter) []db.VersionWithName); ok {
+		r0 = returnFunc(ctx, filters)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]db.VersionWithName)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, db.RecordVersionQueryFilter) error); ok {
+		r1 = returnFunc(ctx, filters)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// Database_ListGlobalVersionTimeline_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListGlobalVersionTimeline'
+type Database_ListGlobalVersionTimeline_Call struct {
+	*mock.Call
+}
+
+// ListGlobalVersionTimeline is a helper method to define mock.On call
+//   - ctx context.Context
+//   - filters db.RecordVersionQueryFilter
+func (_e *Database_Expecter) ListGlobalVersionTimeline(ctx interface{}, filters interface{}) *Database_ListGlobalVersionTimeline_Call {
+	return &Database_ListGlobalVersionTimeline_Call{Call: _e.mock.On("ListGlobalVersionTimeline", ctx, filters)}
+}
+
+func (_c *Database_ListGlobalVersionTimeline_Call) Run(run func(ctx context.Context, filters db.RecordVersionQueryFilter)) *Database_ListGlobalVersionTimeline_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 db.RecordVersionQueryFilter
+		if args[1] != nil {
+			arg1 = args[1].(db.RecordVersionQueryFilter)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_ListGlobalVersionTimeline_Call) Return(versionWithNames []db.VersionWithName, err error) *Database_ListGlobalVersionTimeline_Call {
+	_c.Call.Return(versionWithNames, err)
+	return _c
+}
+
+func (_c *Database_ListGlobalVersionTimeline_Call) RunAndReturn(run func(ctx context.Context, filters db.RecordVersionQueryFilter) ([]db.VersionWithName, error)) *Database_ListGlobalVersionTimeline_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListRecords provides a mock function for the type Database
+func (_mock *Database) ListRecords(ctx context.Context, filters db.RecordQueryFilter) ([]models.Record, error) {
+	ret := _mock.Called(ctx, filters)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListRecords")
+	}
+
+	var r0 []models.Record
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.RecordQueryFilter) ([]models.Record, error)); ok {
+		return returnFunc(ctx, filters)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.RecordQueryFilter) []models.Record); ok {
+		r0 = returnFunc(ctx, filters)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Record)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, db.RecordQueryFilter) error); ok {
+		r1 = returnFunc(ctx, filters)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// Database_ListRecords_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListRecords'
+type Database_ListRecords_Call struct {
+	*mock.Call
+}
+
+// ListRecords is a helper method to define mock.On call
+//   - ctx context.Context
+//   - filters db.RecordQueryFilter
+func (_e *Database_Expecter) ListRecords(ctx interface{}, filters interface{}) *Database_ListRecords_Call {
+	return &Database_ListRecords_Call{Call: _e.mock.On("ListRecords", ctx, filters)}
+}
+
+func (_c *Database_ListRecords_Call) Run(run func(ctx context.Context, filters db.RecordQueryFilter)) *Database_ListRecords_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 db.RecordQueryFilter
+		if args[1] != nil {
+			arg1 = args[1].(db.RecordQueryFilter)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_ListRecords_Call) Return(records []models.Record, err error) *Database_ListRecords_Call {
+	_c.Call.Return(records, err)
+	return _c
+}
+
+func (_c *Database_ListRecords_Call) RunAndReturn(run func(ctx context.Context, filters db.RecordQueryFilter) ([]models.Record, error)) *Database_ListRecords_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListRecordsNotReadSince provides a mock function for the type Database
+func (_mock *Database) ListRecordsNotReadSince(ctx context.Context, cutoff time.Time) ([]models.Record, error) {
+	ret := _mock.Called(ctx, cutoff)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListRecordsNotReadSince")
+	}
+
+	var r0 []models.Record
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, time.Time) ([]models.Record, error)); ok {
+		return returnFunc(ctx, cutoff)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, time.Time) []models.Record); ok {
+		r0 = returnFunc(ctx, cutoff)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Record)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = returnFunc(ctx, cutoff)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// Database_ListRecordsNotReadSince_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListRecordsNotReadSince'
+type Database_ListRecordsNotReadSince_Call struct {
+	*mock.Call
+}
+
+// ListRecordsNotReadSince is a helper method to define mock.On call
+//   - ctx context.Context
+//   - cutoff time.Time
+func (_e *Database_Expecter) ListRecordsNotReadSince(ctx interface{}, cutoff interface{}) *Database_ListRecordsNotReadSince_Call {
+	return &Database_ListRecordsNotReadSince_Call{Call: _e.mock.On("ListRecordsNotReadSince", ctx, cutoff)}
+}
+
+func (_c *Database_ListRecordsNotReadSince_Call) Run(run func(ctx context.Context, cutoff time.Time)) *Database_ListRecordsNotReadSince_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 time.Time
+		if args[1] != nil {
+			arg1 = args[1].(time.Time)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_ListRecordsNotReadSince_Call) Return(records []models.Record, err error) *Database_ListRecordsNotReadSince_Call {
+	_c.Call.Return(records, err)
+	return _c
+}
+
+func (_c *Database_ListRecordsNotReadSince_Call) RunAndReturn(run func(ctx context.Context, cutoff time.Time) ([]models.Record, error)) *Database_ListRecordsNotReadSince_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListRecordsOnInactiveKeys provides a mock function for the type Database
+func (_mock *Database) ListRecordsOnInactiveKeys(ctx context.Context) ([]models.Record, error) {
+	ret := _mock.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListRecordsOnInactiveKeys")
+	}
+
+	var r0 []models.Record
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context) ([]models.Record, error)); ok {
+		return returnFunc(ctx)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context) []models.Record); ok {
+		r0 = returnFunc(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Record)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = returnFunc(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// Database_ListRecordsOnInactiveKeys_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListRecordsOnInactiveKeys'
+type Database_ListRecordsOnInactiveKeys_Call struct {
+	*mock.Call
+}
+
+// ListRecordsOnInactiveKeys is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *Database_Expecter) ListRecordsOnInactiveKeys(ctx interface{}) *Database_ListRecordsOnInactiveKeys_Call {
+	return &Database_ListRecordsOnInactiveKeys_Call{Call: _e.mock.On("ListRecordsOnInactiveKeys", ctx)}
+}
+
+func (_c *Database_ListRecordsOnInactiveKeys_Call) Run(run func(ctx context.Context)) *Database_ListRecordsOnInactiveKeys_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_ListRecordsOnInactiveKeys_Call) Return(records []models.Record, err error) *Database_ListRecordsOnInactiveKeys_Call {
+	_c.Call.Return(records, err)
+	return _c
+}
+
+func (_c *Database_ListRecordsOnInactiveKeys_Call) RunAndReturn(run func(ctx context.Context) ([]models.Record, error)) *Database_ListRecordsOnInactiveKeys_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListRecordsWithNoVersions provides a mock function for the type Database
+func (_mock *Database) ListRecordsWithNoVersions(ctx context.Context) ([]models.Record, error) {
+	ret := _mock.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListRecordsWithNoVersions")
+	}
+
+	var r0 []models.Record
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context) ([]models.Record, error)); ok {
+		return returnFunc(ctx)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context) []models.Record); ok {
+		r0 = returnFunc(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Record)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = returnFunc(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// Database_ListRecordsWithNoVersions_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListRecordsWithNoVersions'
+type Database_ListRecordsWithNoVersions_Call struct {
+	*mock.Call
+}
+
+// ListRecordsWithNoVersions is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *Database_Expecter) ListRecordsWithNoVersions(ctx interface{}) *Database_ListRecordsWithNoVersions_Call {
+	return &Database_ListRecordsWithNoVersions_Call{Call: _e.mock.On("ListRecordsWithNoVersions", ctx)}
+}
+
+func (_c *Database_ListRecordsWithNoVersions_Call) Run(run func(ctx context.Context)) *Database_ListRecordsWithNoVersions_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_ListRecordsWithNoVersions_Call) Return(records []models.Record, err error) *Database_ListRecordsWithNoVersions_Call {
+	_c.Call.Return(records, err)
+	return _c
+}
+
+func (_c *Database_ListRecordsWithNoVersions_Call) RunAndReturn(run func(ctx context.Context) ([]models.Record, error)) *Database_ListRecordsWithNoVersions_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListRecordsWithVersionStats provides a mock function for the type Database
+func (_mock *Database) ListRecordsWithVersionStats(ctx context.Context, filters db.RecordQueryFilter) ([]db.RecordWithVersionStats, error) {
+	ret := _mock.Called(ctx, filters)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListRecordsWithVersionStats")
+	}
+
+	var r0 []db.RecordWithVersionStats
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.RecordQueryFilter) ([]db.RecordWithVersionStats, error)); ok {
+		return returnFunc(ctx, filters)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.RecordQueryFilter) []db.RecordWithVersionStats); ok {
+		r0 = returnFunc(ctx, filters)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]db.RecordWithVersionStats)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, db.RecordQueryFilter) error); ok {
+		r1 = returnFunc(ctx, filters)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// Database_ListRecordsWithVersionStats_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListRecordsWithVersionStats'
+type Database_ListRecordsWithVersionStats_Call struct {
+	*mock.Call
+}
+
+// ListRecordsWithVersionStats is a helper method to define mock.On call
+//   - ctx context.Context
+//   - filters db.RecordQueryFilter
+func (_e *Database_Expecter) ListRecordsWithVersionStats(ctx interface{}, filters interface{}) *Database_ListRecordsWithVersionStats_Call {
+	return &Database_ListRecordsWithVersionStats_Call{Call: _e.mock.On("ListRecordsWithVersionStats", ctx, filters)}
+}
+
+func (_c *Database_ListRecordsWithVersionStats_Call) Run(run func(ctx context.Context, filters db.RecordQueryFilter)) *Database_ListRecordsWithVersionStats_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 db.RecordQueryFilter
+		if args[1] != nil {
+			arg1 = args[1].(db.RecordQueryFilter)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_ListRecordsWithVersionStats_Call) Return(recordWithVersionStatss []db.RecordWithVersionStats, err error) *Database_ListRecordsWithVersionStats_Call {
+	_c.Call.Return(recordWithVersionStatss, err)
+	return _c
+}
+
+func (_c *Database_ListRecordsWithVersionStats_Call) RunAndReturn(run func(ctx context.Context, filters db.RecordQueryFilter) ([]db.RecordWithVersionStats, error)) *Database_ListRecordsWithVersionStats_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListSystemEvents provides a mock function for the type Database
+func (_mock *Database) ListSystemEvents(ctx context.Context, filters db.SystemEventQueryFilter) ([]models.SystemEventAudit, error) {
+	ret := _mock.Called(ctx, filters)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListSystemEvents")
+	}
+
+	var r0 []models.SystemEventAudit
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.SystemEventQueryFilter) ([]models.SystemEventAudit, error)); ok {
+		return returnFunc(ctx, filters)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.SystemEventQueryFilter) []models.SystemEventAudit); ok {
+		r0 = returnFunc(ctx, filters)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.SystemEventAudit)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, db.SystemEventQueryFilter) error); ok {
+		r1 = returnFunc(ctx, filters)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// Database_ListSystemEvents_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListSystemEvents'
+type Database_ListSystemEvents_Call struct {
+	*mock.Call
+}
+
+// ListSystemEvents is a helper method to define mock.On call
+//   - ctx context.Context
+//   - filters db.SystemEventQueryFilter
+func (_e *Database_Expecter) ListSystemEvents(ctx interface{}, filters interface{}) *Database_ListSystemEvents_Call {
+	return &Database_ListSystemEvents_Call{Call: _e.mock.On("ListSystemEvents", ctx, filters)}
+}
+
+func (_c *Database_ListSystemEvents_Call) Run(run func(ctx context.Context, filters db.SystemEventQueryFilter)) *Database_ListSystemEvents_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 db.SystemEventQueryFilter
+		if args[1] != nil {
+			arg1 = args[1].(db.SystemEventQueryFilter)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_ListSystemEvents_Call) Return(systemEventAudits []models.SystemEventAudit, err error) *Database_ListSystemEvents_Call {
+	_c.Call.Return(systemEventAudits, err)
+	return _c
+}
+
+func (_c *Database_ListSystemEvents_Call) RunAndReturn(run func(ctx context.Context, filters db.SystemEventQueryFilter) ([]models.SystemEventAudit, error)) *Database_ListSystemEvents_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListVersionsEncryptedByKey provides a mock function for the type Database
+func (_mock *Database) ListVersionsEncryptedByKey(ctx context.Context, encKey models.EncryptionKey, filters db.RecordVersionQueryFilter) ([]models.RecordVersion, error) {
+	ret := _mock.Called(ctx, encKey, filters)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListVersionsEncryptedByKey")
+	}
+
+	var r0 []models.RecordVersion
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, models.EncryptionKey, db.RecordVersionQueryFilter) ([]models.RecordVersion, error)); ok {
+		return returnFunc(ctx, encKey, filters)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, models.EncryptionKey, db.RecordVersionQueryFilter) []models.RecordVersion); ok {
+		r0 = returnFunc(ctx, encKey, filters)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.RecordVersion)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, models.EncryptionKey, db.RecordVersionQueryFilter) error); ok {
+		r1 = returnFunc(ctx, encKey, filters)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// Database_ListVersionsEncryptedByKey_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListVersionsEncryptedByKey'
+type Database_ListVersionsEncryptedByKey_Call struct {
+	*mock.Call
+}
+
+// ListVersionsEncryptedByKey is a helper method to define mock.On call
+//   - ctx context.Context
+//   - encKey models.EncryptionKey
+//   - filters db.RecordVersionQueryFilter
+func (_e *Database_Expecter) ListVersionsEncryptedByKey(ctx interface{}, encKey interface{}, filters interface{}) *Database_ListVersionsEncryptedByKey_Call {
+	return &Database_ListVersionsEncryptedByKey_Call{Call: _e.mock.On("ListVersionsEncryptedByKey", ctx, encKey, filters)}
+}
+
+func (_c *Database_ListVersionsEncryptedByKey_Call) Run(run func(ctx context.Context, encKey models.EncryptionKey, filters db.RecordVersionQueryFilter)) *Database_ListVersionsEncryptedByKey_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 models.EncryptionKey
+		if args[1] != nil {
+			arg1 = args[1].(models.EncryptionKey)
+		}
+		var arg2 db.RecordVersionQueryFilter
+		if args[2] != nil {
+			arg2 = args[2].(db.RecordVersionQueryFilter)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_ListVersionsEncryptedByKey_Call) Return(recordVersions []models.RecordVersion, err error) *Database_ListVersionsEncryptedByKey_Call {
+	_c.Call.Return(recordVersions, err)
+	return _c
+}
+
+func (_c *Database_ListVersionsEncryptedByKey_Call) RunAndReturn(run func(ctx context.Context, encKey models.EncryptionKey, filters db.RecordVersionQueryFilter) ([]models.RecordVersion, error)) *Database_ListVersionsEncryptedByKey_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListVersionsOfOneRecord provides a mock function for the type Database
+func (_mock *Database) ListVersionsOfOneRecord(ctx context.Context, record models.Record, filters db.RecordVersionQueryFilter) ([]models.RecordVersion, error) {
+	ret := _mock.Called(ctx, record, filters)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListVersionsOfOneRecord")
+	}
+
+	var r0 []models.RecordVersion
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, models.Record, db.RecordVersionQueryFilter) ([]models.RecordVersion, error)); ok {
+		return returnFunc(ctx, record, filters)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, models.Record, db.RecordVersionQueryFilter) []models.RecordVersion); ok {
+		r0 = returnFunc(ctx, record, filters)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.RecordVersion)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, models.Record, db.RecordVersionQueryFilter) error); ok {
+		r1 = returnFunc(ctx, record, filters)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// Database_ListVersionsOfOneRecord_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListVersionsOfOneRecord'
+type Database_ListVersionsOfOneRecord_Call struct {
+	*mock.Call
+}
+
+// ListVersionsOfOneRecord is a helper method to define mock.On call
+//   - ctx context.Context
+//   - record models.Record
+//   - filters db.RecordVersionQueryFilter
+func (_e *Database_Expecter) ListVersionsOfOneRecord(ctx interface{}, record interface{}, filters interface{}) *Database_ListVersionsOfOneRecord_Call {
+	return &Database_ListVersionsOfOneRecord_Call{Call: _e.mock.On("ListVersionsOfOneRecord", ctx, record, filters)}
+}
+
+func (_c *Database_ListVersionsOfOneRecord_Call) Run(run func(ctx context.Context, record models.Record, filters db.RecordVersionQueryFilter)) *Database_ListVersionsOfOneRecord_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 models.Record
+		if args[1] != nil {
+			arg1 = args[1].(models.Record)
+		}
+		var arg2 db.RecordVersionQueryFilter
+		if args[2] != nil {
+			arg2 = args[2].(db.RecordVersionQueryFilter)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_ListVersionsOfOneRecord_Call) Return(recordVersions []models.RecordVersion, err error) *Database_ListVersionsOfOneRecord_Call {
+	_c.Call.Return(recordVersions, err)
+	return _c
+}
+
+func (_c *Database_ListVersionsOfOneRecord_Call) RunAndReturn(run func(ctx context.Context, record models.Record, filters db.RecordVersionQueryFilter) ([]models.RecordVersion, error)) *Database_ListVersionsOfOneRecord_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// LockSystemParams provides a mock function for the type Database
+func (_mock *Database) LockSystemParams(ctx context.Context) error {
+	ret := _mock.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for LockSystemParams")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = returnFunc(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// Database_LockSystemParams_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'LockSystemParams'
+type Database_LockSystemParams_Call struct {
+	*mock.Call
+}
+
+// LockSystemParams is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *Database_Expecter) LockSystemParams(ctx interface{}) *Database_LockSystemParams_Call {
+	return &Database_LockSystemParams_Call{Call: _e.mock.On("LockSystemParams", ctx)}
+}
+
+func (_c *Database_LockSystemParams_Call) Run(run func(ctx context.Context)) *Database_LockSystemParams_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_LockSystemParams_Call) Return(err error) *Database_LockSystemParams_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *Database_LockSystemParams_Call) RunAndReturn(run func(ctx context.Context) error) *Database_LockSystemParams_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// MarkEncryptionKeyActive provides a mock function for the type Database
+func (_mock *Database) MarkEncryptionKeyActive(ctx context.Context, keyID string) error {
+	ret := _mock.Called(ctx, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkEncryptionKeyActive")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = returnFunc(ctx, keyID)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// Database_MarkEncryptionKeyActive_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'MarkEncryptionKeyActive'
+type Database_MarkEncryptionKeyActive_Call struct {
+	*mock.Call
+}
+
+// MarkEncryptionKeyActive is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+func (_e *Database_Expecter) MarkEncryptionKeyActive(ctx interface{}, keyID interface{}) *Database_MarkEncryptionKeyActive_Call {
+	return &Database_MarkEncryptionKeyActive_Call{Call: _e.mock.On("MarkEncryptionKeyActive", ctx, keyID)}
+}
+
+func (_c *Database_MarkEncryptionKeyActive_Call) Run(run func(ctx context.Context, keyID string)) *Database_MarkEncryptionKeyActive_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_MarkEncryptionKeyActive_Call) Return(err error) *Database_MarkEncryptionKeyActive_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *Database_MarkEncryptionKeyActive_Call) RunAndReturn(run func(ctx context.Context, keyID string) error) *Database_MarkEncryptionKeyActive_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// MarkEncryptionKeyInactive provides a mock function for the type Database
+func (_mock *Database) MarkEncryptionKeyInactive(ctx context.Context, keyID string) error {
+	ret := _mock.Called(ctx, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkEncryptionKeyInactive")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = returnFunc(ctx, keyID)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// Database_MarkEncryptionKeyInactive_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'MarkEncryptionKeyInactive'
+type Database_MarkEncryptionKeyInactive_Call struct {
+	*mock.Call
+}
+
+// MarkEncryptionKeyInactive is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+func (_e *Database_Expecter) MarkEncryptionKeyInactive(ctx interface{}, keyID interface{}) *Database_MarkEncryptionKeyInactive_Call {
+	return &Database_MarkEncryptionKeyInactive_Call{Call: _e.mock.On("MarkEncryptionKeyInactive", ctx, keyID)}
+}
+
+func (_c *Database_MarkEncryptionKeyInactive_Call) Run(run func(ctx context.Context, keyID string)) *Database_MarkEncryptionKeyInactive_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_MarkEncryptionKeyInactive_Call) Return(err error) *Database_MarkEncryptionKeyInactive_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *Database_MarkEncryptionKeyInactive_Call) RunAndReturn(run func(ctx context.Context, keyID string) error) *Database_MarkEncryptionKeyInactive_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// MarkEncryptionKeyRetired provides a mock function for the type Database
+func (_mock *Database) MarkEncryptionKeyRetired(ctx context.Context, keyID string) error {
+	ret := _mock.Called(ctx, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkEncryptionKeyRetired")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = returnFunc(ctx, keyID)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// Database_MarkEncryptionKeyRetired_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'MarkEncryptionKeyRetired'
+type Database_MarkEncryptionKeyRetired_Call struct {
+	*mock.Call
+}
+
+// MarkEncryptionKeyRetired is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+func (_e *Database_Expecter) MarkEncryptionKeyRetired(ctx interface{}, keyID interface{}) *Database_MarkEncryptionKeyRetired_Call {
+	return &Database_MarkEncryptionKeyRetired_Call{Call: _e.mock.On("MarkEncryptionKeyRetired", ctx, keyID)}
+}
+
+func (_c *Database_MarkEncryptionKeyRetired_Call) Run(run func(ctx context.Context, keyID string)) *Database_MarkEncryptionKeyRetired_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_MarkEncryptionKeyRetired_Call) Return(err error) *Database_MarkEncryptionKeyRetired_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *Database_MarkEncryptionKeyRetired_Call) RunAndReturn(run func(ctx context.Context, keyID string) error) *Database_MarkEncryptionKeyRetired_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// MarkRecordRead provides a mock function for the type Database
+func (_mock *Database) MarkRecordRead(ctx context.Context, recordID string, readAt time.Time) error {
+	ret := _mock.Called(ctx, recordID, readAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkRecordRead")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, time.Time) error); ok {
+		r0 = returnFunc(ctx, recordID, readAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// Database_MarkRecordRead_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'MarkRecordRead'
+type Database_MarkRecordRead_Call struct {
+	*mock.Call
+}
+
+// MarkRecordRead is a helper method to define mock.On call
+//   - ctx context.Context
+//   - recordID string
+//   - readAt time.Time
+func (_e *Database_Expecter) MarkRecordRead(ctx interface{}, recordID interface{}, readAt interface{}) *Database_MarkRecordRead_Call {
+	return &Database_MarkRecordRead_Call{Call: _e.mock.On("MarkRecordRead", ctx, recordID, readAt)}
+}
+
+func (_c *Database_MarkRecordRead_Call) Run(run func(ctx context.Context, recordID string, readAt time.Time)) *Database_MarkRecordRead_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 time.Time
+		if args[2] != nil {
+			arg2 = args[2].(time.Time)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_MarkRecordRead_Call) Return(err error) *Database_MarkRecordRead_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *Database_MarkRecordRead_Call) RunAndReturn(run func(ctx context.Context, recordID string, readAt time.Time) error) *Database_MarkRecordRead_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// MarkSystemInitialized provides a mock function for the type Database
+func (_mock *Database) MarkSystemInitialized(ctx context.Context) error {
+	ret := _mock.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkSystemInitialized")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = returnFunc(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// Database_MarkSystemInitialized_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'MarkSystemInitialized'
+type Database_MarkSystemInitialized_Call struct {
+	*mock.Call
+}
+
+// MarkSystemInitialized is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *Database_Expecter) MarkSystemInitialized(ctx interface{}) *Database_MarkSystemInitialized_Call {
+	return &Database_MarkSystemInitialized_Call{Call: _e.mock.On("MarkSystemInitialized", ctx)}
+}
+
+func (_c *Database_MarkSystemInitialized_Call) Run(run func(ctx context.Context)) *Database_MarkSystemInitialized_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_MarkSystemInitialized_Call) Return(err error) *Database_MarkSystemInitialized_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *Database_MarkSystemInitialized_Call) RunAndReturn(run func(ctx context.Context) error) *Database_MarkSystemInitialized_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// MarkSystemInitializing provides a mock function for the type Database
+func (_mock *Database) MarkSystemInitializing(ctx context.Context) error {
+	ret := _mock.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkSystemInitializing")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = returnFunc(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// Database_MarkSystemInitializing_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'MarkSystemInitializing'
+type Database_MarkSystemInitializing_Call struct {
+	*mock.Call
+}
+
+// MarkSystemInitializing is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *Database_Expecter) MarkSystemInitializing(ctx interface{}) *Database_MarkSystemInitializing_Call {
+	return &Database_MarkSystemInitializing_Call{Call: _e.mock.On("MarkSystemInitializing", ctx)}
+}
+
+func (_c *Database_MarkSystemInitializing_Call) Run(run func(ctx context.Context)) *Database_MarkSystemInitializing_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_MarkSystemInitializing_Call) Return(err error) *Database_MarkSystemInitializing_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *Database_MarkSystemInitializing_Call) RunAndReturn(run func(ctx context.Context) error) *Database_MarkSystemInitializing_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// PurgeExpiredVersions provides a mock function for the type Database
+func (_mock *Database) PurgeExpiredVersions(ctx context.Context, now time.Time) (int, error) {
+	ret := _mock.Called(ctx, now)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PurgeExpiredVersions")
+	}
+
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, time.Time) (int, error)); ok {
+		return returnFunc(ctx, now)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, time.Time) int); ok {
+		r0 = returnFunc(ctx, now)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = returnFunc(ctx, now)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// Database_PurgeExpiredVersions_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'PurgeExpiredVersions'
+type Database_PurgeExpiredVersions_Call struct {
+	*mock.Call
+}
+
+// PurgeExpiredVersions is a helper method to define mock.On call
+//   - ctx context.Context
+//   - now time.Time
+func (_e *Database_Expecter) PurgeExpiredVersions(ctx interface{}, now interface{}) *Database_PurgeExpiredVersions_Call {
+	return &Database_PurgeExpiredVersions_Call{Call: _e.mock.On("PurgeExpiredVersions", ctx, now)}
+}
+
+func (_c *Database_PurgeExpiredVersions_Call) Run(run func(ctx context.Context, now time.Time)) *Database_PurgeExpiredVersions_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 time.Time
+		if args[1] != nil {
+			arg1 = args[1].(time.Time)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_PurgeExpiredVersions_Call) Return(int int, err error) *Database_PurgeExpiredVersions_Call {
+	_c.Call.Return(int, err)
+	return _c
+}
+
+func (_c *Database_PurgeExpiredVersions_Call) RunAndReturn(run func(ctx context.Context, now time.Time) (int, error)) *Database_PurgeExpiredVersions_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RecordEncryptionKey provides a mock function for the type Database
+func (_mock *Database) RecordEncryptionKey(ctx context.Context, encKeyMaterial []byte, rsaFingerprint string, materialFingerprint string, aeadType models.AEADTypeENUMType) (models.EncryptionKey, error) {
+	ret := _mock.Called(ctx, encKeyMaterial, rsaFingerprint, materialFingerprint, aeadType)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RecordEncryptionKey")
+	}
+
+	var r0 models.EncryptionKey
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []byte, string, string, models.AEADTypeENUMType) (models.EncryptionKey, error)); ok {
+		return returnFunc(ctx, encKeyMaterial, rsaFingerprint, materialFingerprint, aeadType)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []byte, string, string, models.AEADTypeENUMType) models.EncryptionKey); ok {
+		r0 = returnFunc(ctx, encKeyMaterial, rsaFingerprint, materialFingerprint, aeadType)
+	} else {
+		r0 = ret.Get(0).(models.EncryptionKey)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, []byte, string, string, models.AEADTypeENUMType) error); ok {
+		r1 = returnFunc(ctx, encKeyMaterial, rsaFingerprint, materialFingerprint, aeadType)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// Database_RecordEncryptionKey_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RecordEncryptionKey'
+type Database_RecordEncryptionKey_Call struct {
+	*mock.Call
+}
+
+// RecordEncryptionKey is a helper method to define mock.On call
+//   - ctx context.Context
+//   - encKeyMaterial []byte
+//   - rsaFingerprint string
+//   - materialFingerprint string
+//   - aeadType models.AEADTypeENUMType
+func (_e *Database_Expecter) RecordEncryptionKey(ctx interface{}, encKeyMaterial interface{}, rsaFingerprint interface{}, materialFingerprint interface{}, aeadType interface{}) *Database_RecordEncryptionKey_Call {
+	return &Database_RecordEncryptionKey_Call{Call: _e.mock.On("RecordEncryptionKey", ctx, encKeyMaterial, rsaFingerprint, materialFingerprint, aeadType)}
+}
+
+func (_c *Database_RecordEncryptionKey_Call) Run(run func(ctx context.Context, encKeyMaterial []byte, rsaFingerprint string, materialFingerprint string, aeadType models.AEADTypeENUMType)) *Database_RecordEncryptionKey_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 []byte
+		if args[1] != nil {
+			arg1 = args[1].([]byte)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		var arg3 string
+		if args[3] != nil {
+			arg3 = args[3].(string)
+		}
+		var arg4 models.AEADTypeENUMType
+		if args[4] != nil {
+			arg4 = args[4].(models.AEADTypeENUMType)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+			arg4,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_RecordEncryptionKey_Call) Return(encryptionKey models.EncryptionKey, err error) *Database_RecordEncryptionKey_Call {
+	_c.Call.Return(encryptionKey, err)
+	return _c
+}
+
+func (_c *Database_RecordEncryptionKey_Call) RunAndReturn(run func(ctx context.Context, encKeyMaterial []byte, rsaFingerprint string, materialFingerprint string, aeadType models.AEADTypeENUMType) (models.EncryptionKey, error)) *Database_RecordEncryptionKey_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ReencryptRecordVersion provides a mock function for the type Database
+func (_mock *Database) ReencryptRecordVersion(ctx context.Context, versionID string, encKey models.EncryptionKey, value []byte, nonce []byte, keyDerivation models.KeyDerivationENUMType) (models.RecordVersion, error) {
+	ret := _mock.Called(ctx, versionID, encKey, value, nonce, keyDerivation)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReencryptRecordVersion")
+	}
+
+	var r0 models.RecordVersion
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, models.EncryptionKey, []byte, []byte, models.KeyDerivationENUMType) (models.RecordVersion, error)); ok {
+		return returnFunc(ctx, versionID, encKey, value, nonce, keyDerivation)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, models.EncryptionKey, []byte, []byte, models.KeyDerivationENUMType) models.RecordVersion); ok {
+		r0 = returnFunc(ctx, versionID, encKey, value, nonce, keyDerivation)
+	} else {
+		r0 = ret.Get(0).(models.RecordVersion)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, models.EncryptionKey, []byte, []byte, models.KeyDerivationENUMType) error); ok {
+		r1 = returnFunc(ctx, versionID, encKey, value, nonce, keyDerivation)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// Database_ReencryptRecordVersion_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ReencryptRecordVersion'
+type Database_ReencryptRecordVersion_Call struct {
+	*mock.Call
+}
+
+// ReencryptRecordVersion is a helper method to define mock.On call
+//   - ctx context.Context
+//   - versionID string
+//   - encKey models.EncryptionKey
+//   - value []byte
+//   - nonce []byte
+//   - keyDerivation models.KeyDerivationENUMType
+func (_e *Database_Expecter) ReencryptRecordVersion(ctx interface{}, versionID interface{}, encKey interface{}, value interface{}, nonce interface{}, keyDerivation interface{}) *Database_ReencryptRecordVersion_Call {
+	return &Database_ReencryptRecordVersion_Call{Call: _e.mock.On("ReencryptRecordVersion", ctx, versionID, encKey, value, nonce, keyDerivation)}
+}
+
+func (_c *Database_ReencryptRecordVersion_Call) Run(run func(ctx context.Context, versionID string, encKey models.EncryptionKey, value []byte, nonce []byte, keyDerivation models.KeyDerivationENUMType)) *Database_ReencryptRecordVersion_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 models.EncryptionKey
+		if args[2] != nil {
+			arg2 = args[2].(models.EncryptionKey)
+		}
+		var arg3 []byte
+		if args[3] != nil {
+			arg3 = args[3].([]byte)
+		}
+		var arg4 []byte
+		if args[4] != nil {
+			arg4 = args[4].([]byte)
+		}
+		var arg5 models.KeyDerivationENUMType
+		if args[5] != nil {
+			arg5 = args[5].(models.KeyDerivationENUMType)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+			arg4,
+			arg5,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_ReencryptRecordVersion_Call) Return(recordVersion models.RecordVersion, err error) *Database_ReencryptRecordVersion_Call {
+	_c.Call.Return(recordVersion, err)
+	return _c
+}
+
+func (_c *Database_ReencryptRecordVersion_Call) RunAndReturn(run func(ctx context.Context, versionID string, encKey models.EncryptionKey, value []byte, nonce []byte, keyDerivation models.KeyDerivationENUMType) (models.RecordVersion, error)) *Database_ReencryptRecordVersion_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RenameRecord provides a mock function for the type Database
+func (_mock *Database) RenameRecord(ctx context.Context, recordID string, newName string) (models.Record, error) {
+	ret := _mock.Called(ctx, recordID, newName)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RenameRecord")
+	}
+
+	var r0 models.Record
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) (models.Record, error)); ok {
+		return returnFunc(ctx, recordID, newName)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) models.Record); ok {
+		r0 = returnFunc(ctx, recordID, newName)
+	} else {
+		r0 = ret.Get(0).(models.Record)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = returnFunc(ctx, recordID, newName)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// Database_RenameRecord_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RenameRecord'
+type Database_RenameRecord_Call struct {
+	*mock.Call
+}
+
+// RenameRecord is a helper method to define mock.On call
+//   - ctx context.Context
+//   - recordID string
+//   - newName string
+func (_e *Database_Expecter) RenameRecord(ctx interface{}, recordID interface{}, newName interface{}) *Database_RenameRecord_Call {
+	return &Database_RenameRecord_Call{Call: _e.mock.On("RenameRecord", ctx, recordID, newName)}
+}
+
+func (_c *Database_RenameRecord_Call) Run(run func(ctx context.Context, recordID string, newName string)) *Database_RenameRecord_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *Database_RenameRecord_Call) Return(record models.Record, err error) *Database_RenameRecord_Call {
+	_c.Call.Return(record, err)
+	return _c
+}
+
+func (_c *Database_RenameRecord_Call) RunAndReturn(run func(ctx context.Context, recordID string, newName string) (models.Record, error)) *Database_RenameRecord_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RestoreEncryptionKey provides a mock function for the type Database
+func (_mock *Database) RestoreEncryptionKey(ctx context.Context, entry models.EncryptionKey) error {
+	ret := _mock.Called(ctx, entry)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RestoreEncryptionKey")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, models.EncryptionKey) error); ok {
+		r0 = returnFunc(ctx, entry)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// Database_RestoreEncryptionKey_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RestoreEncryptionKey'
+type Database_RestoreEncryptionKey_Call struct {
+	*mock.Call
+}
+
+// RestoreEncryptionKey is a helper method to define mock.On call
+//   - ctx context.Context
+//   - entry models.EncryptionKey
+func (_e *Database_Expecter) RestoreEncryptionKey(ctx interface{}, entry interface{}) *Database_RestoreEncryptionKey_Call {
+	return &Database_RestoreEncryptionKey_Call{Call: _e.mock.On("RestoreEncryptionKey", ctx, entry)}
+}
+
+func (_c *Database_RestoreEncryptionKey_Call) Run(run func(ctx context.Context, entry models.EncryptionKey)) *Database_RestoreEncryptionKey_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 models.EncryptionKey
 		if args[1] != nil {
-			arg1 = args[1].(db.RecordQueryFilter)
+			arg1 = args[1].(models.EncryptionKey)
 		}
 		run(
 			arg0,
@@ -828,65 +2977,54 @@ func (_c *Database_ListRecords_Call) Run(run func(ctx context.Context, filters d
 	return _c
 }
 
-func (_c *Database_ListRecords_Call) Return(records []models.Record, err error) *Database_ListRecords_Call {
-	_c.Call.Return(records, err)
+func (_c *Database_RestoreEncryptionKey_Call) Return(err error) *Database_RestoreEncryptionKey_Call {
+	_c.Call.Return(err)
 	return _c
 }
 
-func (_c *Database_ListRecords_Call) RunAndReturn(run func(ctx context.Context, filters db.RecordQueryFilter) ([]models.Record, error)) *Database_ListRecords_Call {
+func (_c *Database_RestoreEncryptionKey_Call) RunAndReturn(run func(ctx context.Context, entry models.EncryptionKey) error) *Database_RestoreEncryptionKey_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// ListSystemEvents provides a mock function for the type Database
-func (_mock *Database) ListSystemEvents(ctx context.Context, filters db.SystemEventQueryFilter) ([]models.SystemEventAudit, error) {
-	ret := _mock.Called(ctx, filters)
+// RestoreRecord provides a mock function for the type Database
+func (_mock *Database) RestoreRecord(ctx context.Context, entry models.Record) error {
+	ret := _mock.Called(ctx, entry)
 
 	if len(ret) == 0 {
-		panic("no return value specified for ListSystemEvents")
+		panic("no return value specified for RestoreRecord")
 	}
 
-	var r0 []models.SystemEventAudit
-	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, db.SystemEventQueryFilter) ([]models.SystemEventAudit, error)); ok {
-		return returnFunc(ctx, filters)
-	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, db.SystemEventQueryFilter) []models.SystemEventAudit); ok {
-		r0 = returnFunc(ctx, filters)
-	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]models.SystemEventAudit)
-		}
-	}
-	if returnFunc, ok := ret.Get(1).(func(context.Context, db.SystemEventQueryFilter) error); ok {
-		r1 = returnFunc(ctx, filters)
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, models.Record) error); ok {
+		r0 = returnFunc(ctx, entry)
 	} else {
-		r1 = ret.Error(1)
+		r0 = ret.Error(0)
 	}
-	return r0, r1
+	return r0
 }
 
-// Database_ListSystemEvents_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListSystemEvents'
-type Database_ListSystemEvents_Call struct {
+// Database_RestoreRecord_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RestoreRecord'
+type Database_RestoreRecord_Call struct {
 	*mock.Call
 }
 
-// ListSystemEvents is a helper method to define mock.On call
+// RestoreRecord is a helper method to define mock.On call
 //   - ctx context.Context
-//   - filters db.SystemEventQueryFilter
-func (_e *Database_Expecter) ListSystemEvents(ctx interface{}, filters interface{}) *Database_ListSystemEvents_Call {
-	return &Database_ListSystemEvents_Call{Call: _e.mock.On("ListSystemEvents", ctx, filters)}
+//   - entry models.Record
+func (_e *Database_Expecter) RestoreRecord(ctx interface{}, entry interface{}) *Database_RestoreRecord_Call {
+	return &Database_RestoreRecord_Call{Call: _e.mock.On("RestoreRecord", ctx, entry)}
 }
 
-func (_c *Database_ListSystemEvents_Call) Run(run func(ctx context.Context, filters db.SystemEventQueryFilter)) *Database_ListSystemEvents_Call {
+func (_c *Database_RestoreRecord_Call) Run(run func(ctx context.Context, entry models.Record)) *Database_RestoreRecord_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
 			arg0 = args[0].(context.Context)
 		}
-		var arg1 db.SystemEventQueryFilter
+		var arg1 models.Record
 		if args[1] != nil {
-			arg1 = args[1].(db.SystemEventQueryFilter)
+			arg1 = args[1].(models.Record)
 		}
 		run(
 			arg0,
@@ -896,251 +3034,224 @@ func (_c *Database_ListSystemEvents_Call) Run(run func(ctx context.Context, filt
 	return _c
 }
 
-func (_c *Database_ListSystemEvents_Call) Return(systemEventAudits []models.SystemEventAudit, err error) *Database_ListSystemEvents_Call {
-	_c.Call.Return(systemEventAudits, err)
+func (_c *Database_RestoreRecord_Call) Return(err error) *Database_RestoreRecord_Call {
+	_c.Call.Return(err)
 	return _c
 }
 
-func (_c *Database_ListSystemEvents_Call) RunAndReturn(run func(ctx context.Context, filters db.SystemEventQueryFilter) ([]models.SystemEventAudit, error)) *Database_ListSystemEvents_Call {
+func (_c *Database_RestoreRecord_Call) RunAndReturn(run func(ctx context.Context, entry models.Record) error) *Database_RestoreRecord_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// ListVersionsEncryptedByKey provides a mock function for the type Database
-func (_mock *Database) ListVersionsEncryptedByKey(ctx context.Context, encKey models.EncryptionKey, filters db.RecordVersionQueryFilter) ([]models.RecordVersion, error) {
-	ret := _mock.Called(ctx, encKey, filters)
+// RestoreRecordVersion provides a mock function for the type Database
+func (_mock *Database) RestoreRecordVersion(ctx context.Context, entry models.RecordVersion) error {
+	ret := _mock.Called(ctx, entry)
 
 	if len(ret) == 0 {
-		panic("no return value specified for ListVersionsEncryptedByKey")
+		panic("no return value specified for RestoreRecordVersion")
 	}
 
-	var r0 []models.RecordVersion
-	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, models.EncryptionKey, db.RecordVersionQueryFilter) ([]models.RecordVersion, error)); ok {
-		return returnFunc(ctx, encKey, filters)
-	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, models.EncryptionKey, db.RecordVersionQueryFilter) []models.RecordVersion); ok {
-		r0 = returnFunc(ctx, encKey, filters)
-	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]models.RecordVersion)
-		}
-	}
-	if returnFunc, ok := ret.Get(1).(func(context.Context, models.EncryptionKey, db.RecordVersionQueryFilter) error); ok {
-		r1 = returnFunc(ctx, encKey, filters)
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, models.RecordVersion) error); ok {
+		r0 = returnFunc(ctx, entry)
 	} else {
-		r1 = ret.Error(1)
+		r0 = ret.Error(0)
 	}
-	return r0, r1
+	return r0
 }
 
-// Database_ListVersionsEncryptedByKey_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListVersionsEncryptedByKey'
-type Database_ListVersionsEncryptedByKey_Call struct {
+// Database_RestoreRecordVersion_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RestoreRecordVersion'
+type Database_RestoreRecordVersion_Call struct {
 	*mock.Call
 }
 
-// ListVersionsEncryptedByKey is a helper method to define mock.On call
+// RestoreRecordVersion is a helper method to define mock.On call
 //   - ctx context.Context
-//   - encKey models.EncryptionKey
-//   - filters db.RecordVersionQueryFilter
-func (_e *Database_Expecter) ListVersionsEncryptedByKey(ctx interface{}, encKey interface{}, filters interface{}) *Database_ListVersionsEncryptedByKey_Call {
-	return &Database_ListVersionsEncryptedByKey_Call{Call: _e.mock.On("ListVersionsEncryptedByKey", ctx, encKey, filters)}
+//   - entry models.RecordVersion
+func (_e *Database_Expecter) RestoreRecordVersion(ctx interface{}, entry interface{}) *Database_RestoreRecordVersion_Call {
+	return &Database_RestoreRecordVersion_Call{Call: _e.mock.On("RestoreRecordVersion", ctx, entry)}
 }
 
-func (_c *Database_ListVersionsEncryptedByKey_Call) Run(run func(ctx context.Context, encKey models.EncryptionKey, filters db.RecordVersionQueryFilter)) *Database_ListVersionsEncryptedByKey_Call {
+func (_c *Database_RestoreRecordVersion_Call) Run(run func(ctx context.Context, entry models.RecordVersion)) *Database_RestoreRecordVersion_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
 			arg0 = args[0].(context.Context)
 		}
-		var arg1 models.EncryptionKey
+		var arg1 models.RecordVersion
 		if args[1] != nil {
-			arg1 = args[1].(models.EncryptionKey)
-		}
-		var arg2 db.RecordVersionQueryFilter
-		if args[2] != nil {
-			arg2 = args[2].(db.RecordVersionQueryFilter)
+			arg1 = args[1].(models.RecordVersion)
 		}
 		run(
 			arg0,
 			arg1,
-			arg2,
 		)
 	})
 	return _c
 }
 
-func (_c *Database_ListVersionsEncryptedByKey_Call) Return(recordVersions []models.RecordVersion, err error) *Database_ListVersionsEncryptedByKey_Call {
-	_c.Call.Return(recordVersions, err)
+func (_c *Database_RestoreRecordVersion_Call) Return(err error) *Database_RestoreRecordVersion_Call {
+	_c.Call.Return(err)
 	return _c
 }
 
-func (_c *Database_ListVersionsEncryptedByKey_Call) RunAndReturn(run func(ctx context.Context, encKey models.EncryptionKey, filters db.RecordVersionQueryFilter) ([]models.RecordVersion, error)) *Database_ListVersionsEncryptedByKey_Call {
+func (_c *Database_RestoreRecordVersion_Call) RunAndReturn(run func(ctx context.Context, entry models.RecordVersion) error) *Database_RestoreRecordVersion_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// ListVersionsOfOneRecord provides a mock function for the type Database
-func (_mock *Database) ListVersionsOfOneRecord(ctx context.Context, record models.Record, filters db.RecordVersionQueryFilter) ([]models.RecordVersion, error) {
-	ret := _mock.Called(ctx, record, filters)
+// RestoreSystemParams provides a mock function for the type Database
+func (_mock *Database) RestoreSystemParams(ctx context.Context, entry models.SystemParams) error {
+	ret := _mock.Called(ctx, entry)
 
 	if len(ret) == 0 {
-		panic("no return value specified for ListVersionsOfOneRecord")
+		panic("no return value specified for RestoreSystemParams")
 	}
 
-	var r0 []models.RecordVersion
-	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, models.Record, db.RecordVersionQueryFilter) ([]models.RecordVersion, error)); ok {
-		return returnFunc(ctx, record, filters)
-	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, models.Record, db.RecordVersionQueryFilter) []models.RecordVersion); ok {
-		r0 = returnFunc(ctx, record, filters)
-	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]models.RecordVersion)
-		}
-	}
-	if returnFunc, ok := ret.Get(1).(func(context.Context, models.Record, db.RecordVersionQueryFilter) error); ok {
-		r1 = returnFunc(ctx, record, filters)
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, models.SystemParams) error); ok {
+		r0 = returnFunc(ctx, entry)
 	} else {
-		r1 = ret.Error(1)
+		r0 = ret.Error(0)
 	}
-	return r0, r1
+	return r0
 }
 
-// Database_ListVersionsOfOneRecord_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListVersionsOfOneRecord'
-type Database_ListVersionsOfOneRecord_Call struct {
+// Database_RestoreSystemParams_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RestoreSystemParams'
+type Database_RestoreSystemParams_Call struct {
 	*mock.Call
 }
 
-// ListVersionsOfOneRecord is a helper method to define mock.On call
+// RestoreSystemParams is a helper method to define mock.On call
 //   - ctx context.Context
-//   - record models.Record
-//   - filters db.RecordVersionQueryFilter
-func (_e *Database_Expecter) ListVersionsOfOneRecord(ctx interface{}, record interface{}, filters interface{}) *Database_ListVersionsOfOneRecord_Call {
-	return &Database_ListVersionsOfOneRecord_Call{Call: _e.mock.On("ListVersionsOfOneRecord", ctx, record, filters)}
+//   - entry models.SystemParams
+func (_e *Database_Expecter) RestoreSystemParams(ctx interface{}, entry interface{}) *Database_RestoreSystemParams_Call {
+	return &Database_RestoreSystemParams_Call{Call: _e.mock.On("RestoreSystemParams", ctx, entry)}
 }
 
-func (_c *Database_ListVersionsOfOneRecord_Call) Run(run func(ctx context.Context, record models.Record, filters db.RecordVersionQueryFilter)) *Database_ListVersionsOfOneRecord_Call {
+func (_c *Database_RestoreSystemParams_Call) Run(run func(ctx context.Context, entry models.SystemParams)) *Database_RestoreSystemParams_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
 			arg0 = args[0].(context.Context)
 		}
-		var arg1 models.Record
+		var arg1 models.SystemParams
 		if args[1] != nil {
-			arg1 = args[1].(models.Record)
-		}
-		var arg2 db.RecordVersionQueryFilter
-		if args[2] != nil {
-			arg2 = args[2].(db.RecordVersionQueryFilter)
+			arg1 = args[1].(models.SystemParams)
 		}
 		run(
 			arg0,
 			arg1,
-			arg2,
 		)
 	})
 	return _c
 }
 
-func (_c *Database_ListVersionsOfOneRecord_Call) Return(recordVersions []models.RecordVersion, err error) *Database_ListVersionsOfOneRecord_Call {
-	_c.Call.Return(recordVersions, err)
+func (_c *Database_RestoreSystemParams_Call) Return(err error) *Database_RestoreSystemParams_Call {
+	_c.Call.Return(err)
 	return _c
 }
 
-func (_c *Database_ListVersionsOfOneRecord_Call) RunAndReturn(run func(ctx context.Context, record models.Record, filters db.RecordVersionQueryFilter) ([]models.RecordVersion, error)) *Database_ListVersionsOfOneRecord_Call {
+func (_c *Database_RestoreSystemParams_Call) RunAndReturn(run func(ctx context.Context, entry models.SystemParams) error) *Database_RestoreSystemParams_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// MarkEncryptionKeyActive provides a mock function for the type Database
-func (_mock *Database) MarkEncryptionKeyActive(ctx context.Context, keyID string) error {
-	ret := _mock.Called(ctx, keyID)
+// ScanRecords provides a mock function for the type Database
+func (_mock *Database) ScanRecords(ctx context.Context, filters db.RecordQueryFilter, handler func(record models.Record) error) error {
+	ret := _mock.Called(ctx, filters, handler)
 
 	if len(ret) == 0 {
-		panic("no return value specified for MarkEncryptionKeyActive")
+		panic("no return value specified for ScanRecords")
 	}
 
 	var r0 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string) error); ok {
-		r0 = returnFunc(ctx, keyID)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.RecordQueryFilter, func(record models.Record) error) error); ok {
+		r0 = returnFunc(ctx, filters, handler)
 	} else {
 		r0 = ret.Error(0)
 	}
 	return r0
 }
 
-// Database_MarkEncryptionKeyActive_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'MarkEncryptionKeyActive'
-type Database_MarkEncryptionKeyActive_Call struct {
+// Database_ScanRecords_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ScanRecords'
+type Database_ScanRecords_Call struct {
 	*mock.Call
 }
 
-// MarkEncryptionKeyActive is a helper method to define mock.On call
+// ScanRecords is a helper method to define mock.On call
 //   - ctx context.Context
-//   - keyID string
-func (_e *Database_Expecter) MarkEncryptionKeyActive(ctx interface{}, keyID interface{}) *Database_MarkEncryptionKeyActive_Call {
-	return &Database_MarkEncryptionKeyActive_Call{Call: _e.mock.On("MarkEncryptionKeyActive", ctx, keyID)}
+//   - filters db.RecordQueryFilter
+//   - handler func(record models.Record) error
+func (_e *Database_Expecter) ScanRecords(ctx interface{}, filters interface{}, handler interface{}) *Database_ScanRecords_Call {
+	return &Database_ScanRecords_Call{Call: _e.mock.On("ScanRecords", ctx, filters, handler)}
 }
 
-func (_c *Database_MarkEncryptionKeyActive_Call) Run(run func(ctx context.Context, keyID string)) *Database_MarkEncryptionKeyActive_Call {
+func (_c *Database_ScanRecords_Call) Run(run func(ctx context.Context, filters db.RecordQueryFilter, handler func(record models.Record) error)) *Database_ScanRecords_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
 			arg0 = args[0].(context.Context)
 		}
-		var arg1 string
+		var arg1 db.RecordQueryFilter
 		if args[1] != nil {
-			arg1 = args[1].(string)
+			arg1 = args[1].(db.RecordQueryFilter)
+		}
+		var arg2 func(record models.Record) error
+		if args[2] != nil {
+			arg2 = args[2].(func(record models.Record) error)
 		}
 		run(
 			arg0,
 			arg1,
+			arg2,
 		)
 	})
 	return _c
 }
 
-func (_c *Database_MarkEncryptionKeyActive_Call) Return(err error) *Database_MarkEncryptionKeyActive_Call {
+func (_c *Database_ScanRecords_Call) Return(err error) *Database_ScanRecords_Call {
 	_c.Call.Return(err)
 	return _c
 }
 
-func (_c *Database_MarkEncryptionKeyActive_Call) RunAndReturn(run func(ctx context.Context, keyID string) error) *Database_MarkEncryptionKeyActive_Call {
+func (_c *Database_ScanRecords_Call) RunAndReturn(run func(ctx context.Context, filters db.RecordQueryFilter, handler func(record models.Record) error) error) *Database_ScanRecords_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// MarkEncryptionKeyInactive provides a mock function for the type Database
-func (_mock *Database) MarkEncryptionKeyInactive(ctx context.Context, keyID string) error {
-	ret := _mock.Called(ctx, keyID)
+// SetRecordMetadata provides a mock function for the type Database
+func (_mock *Database) SetRecordMetadata(ctx context.Context, recordID string, metadata map[string]string) error {
+	ret := _mock.Called(ctx, recordID, metadata)
 
 	if len(ret) == 0 {
-		panic("no return value specified for MarkEncryptionKeyInactive")
+		panic("no return value specified for SetRecordMetadata")
 	}
 
 	var r0 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string) error); ok {
-		r0 = returnFunc(ctx, keyID)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, map[string]string) error); ok {
+		r0 = returnFunc(ctx, recordID, metadata)
 	} else {
 		r0 = ret.Error(0)
 	}
 	return r0
 }
 
-// Database_MarkEncryptionKeyInactive_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'MarkEncryptionKeyInactive'
-type Database_MarkEncryptionKeyInactive_Call struct {
+// Database_SetRecordMetadata_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetRecordMetadata'
+type Database_SetRecordMetadata_Call struct {
 	*mock.Call
 }
 
-// MarkEncryptionKeyInactive is a helper method to define mock.On call
+// SetRecordMetadata is a helper method to define mock.On call
 //   - ctx context.Context
-//   - keyID string
-func (_e *Database_Expecter) MarkEncryptionKeyInactive(ctx interface{}, keyID interface{}) *Database_MarkEncryptionKeyInactive_Call {
-	return &Database_MarkEncryptionKeyInactive_Call{Call: _e.mock.On("MarkEncryptionKeyInactive", ctx, keyID)}
+//   - recordID string
+//   - metadata map[string]string
+func (_e *Database_Expecter) SetRecordMetadata(ctx interface{}, recordID interface{}, metadata interface{}) *Database_SetRecordMetadata_Call {
+	return &Database_SetRecordMetadata_Call{Call: _e.mock.On("SetRecordMetadata", ctx, recordID, metadata)}
 }
 
-func (_c *Database_MarkEncryptionKeyInactive_Call) Run(run func(ctx context.Context, keyID string)) *Database_MarkEncryptionKeyInactive_Call {
+func (_c *Database_SetRecordMetadata_Call) Run(run func(ctx context.Context, recordID string, metadata map[string]string)) *Database_SetRecordMetadata_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
@@ -1150,188 +3261,223 @@ func (_c *Database_MarkEncryptionKeyInactive_Call) Run(run func(ctx context.Cont
 		if args[1] != nil {
 			arg1 = args[1].(string)
 		}
+		var arg2 map[string]string
+		if args[2] != nil {
+			arg2 = args[2].(map[string]string)
+		}
 		run(
 			arg0,
 			arg1,
+			arg2,
 		)
 	})
 	return _c
 }
 
-func (_c *Database_MarkEncryptionKeyInactive_Call) Return(err error) *Database_MarkEncryptionKeyInactive_Call {
+func (_c *Database_SetRecordMetadata_Call) Return(err error) *Database_SetRecordMetadata_Call {
 	_c.Call.Return(err)
 	return _c
 }
 
-func (_c *Database_MarkEncryptionKeyInactive_Call) RunAndReturn(run func(ctx context.Context, keyID string) error) *Database_MarkEncryptionKeyInactive_Call {
+func (_c *Database_SetRecordMetadata_Call) RunAndReturn(run func(ctx context.Context, recordID string, metadata map[string]string) error) *Database_SetRecordMetadata_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// MarkSystemInitialized provides a mock function for the type Database
-func (_mock *Database) MarkSystemInitialized(ctx context.Context) error {
-	ret := _mock.Called(ctx)
+// SetRecordsState provides a mock function for the type Database
+func (_mock *Database) SetRecordsState(ctx context.Context, recordIDs []string, newState models.RecordStateENUMType) error {
+	ret := _mock.Called(ctx, recordIDs, newState)
 
 	if len(ret) == 0 {
-		panic("no return value specified for MarkSystemInitialized")
+		panic("no return value specified for SetRecordsState")
 	}
 
 	var r0 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context) error); ok {
-		r0 = returnFunc(ctx)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []string, models.RecordStateENUMType) error); ok {
+		r0 = returnFunc(ctx, recordIDs, newState)
 	} else {
 		r0 = ret.Error(0)
 	}
 	return r0
 }
 
-// Database_MarkSystemInitialized_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'MarkSystemInitialized'
-type Database_MarkSystemInitialized_Call struct {
+// Database_SetRecordsState_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetRecordsState'
+type Database_SetRecordsState_Call struct {
 	*mock.Call
 }
 
-// MarkSystemInitialized is a helper method to define mock.On call
+// SetRecordsState is a helper method to define mock.On call
 //   - ctx context.Context
-func (_e *Database_Expecter) MarkSystemInitialized(ctx interface{}) *Database_MarkSystemInitialized_Call {
-	return &Database_MarkSystemInitialized_Call{Call: _e.mock.On("MarkSystemInitialized", ctx)}
+//   - recordIDs []string
+//   - newState models.RecordStateENUMType
+func (_e *Database_Expecter) SetRecordsState(ctx interface{}, recordIDs interface{}, newState interface{}) *Database_SetRecordsState_Call {
+	return &Database_SetRecordsState_Call{Call: _e.mock.On("SetRecordsState", ctx, recordIDs, newState)}
 }
 
-func (_c *Database_MarkSystemInitialized_Call) Run(run func(ctx context.Context)) *Database_MarkSystemInitialized_Call {
+func (_c *Database_SetRecordsState_Call) Run(run func(ctx context.Context, recordIDs []string, newState models.RecordStateENUMType)) *Database_SetRecordsState_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
 			arg0 = args[0].(context.Context)
 		}
+		var arg1 []string
+		if args[1] != nil {
+			arg1 = args[1].([]string)
+		}
+		var arg2 models.RecordStateENUMType
+		if args[2] != nil {
+			arg2 = args[2].(models.RecordStateENUMType)
+		}
 		run(
 			arg0,
+			arg1,
+			arg2,
 		)
 	})
 	return _c
 }
 
-func (_c *Database_MarkSystemInitialized_Call) Return(err error) *Database_MarkSystemInitialized_Call {
+func (_c *Database_SetRecordsState_Call) Return(err error) *Database_SetRecordsState_Call {
 	_c.Call.Return(err)
 	return _c
 }
 
-func (_c *Database_MarkSystemInitialized_Call) RunAndReturn(run func(ctx context.Context) error) *Database_MarkSystemInitialized_Call {
+func (_c *Database_SetRecordsState_Call) RunAndReturn(run func(ctx context.Context, recordIDs []string, newState models.RecordStateENUMType) error) *Database_SetRecordsState_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// MarkSystemInitializing provides a mock function for the type Database
-func (_mock *Database) MarkSystemInitializing(ctx context.Context) error {
-	ret := _mock.Called(ctx)
+// SumRecordVersionSizes provides a mock function for the type Database
+func (_mock *Database) SumRecordVersionSizes(ctx context.Context, recordID string) (int64, error) {
+	ret := _mock.Called(ctx, recordID)
 
 	if len(ret) == 0 {
-		panic("no return value specified for MarkSystemInitializing")
+		panic("no return value specified for SumRecordVersionSizes")
 	}
 
-	var r0 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context) error); ok {
-		r0 = returnFunc(ctx)
+	var r0 int64
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (int64, error)); ok {
+		return returnFunc(ctx, recordID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) int64); ok {
+		r0 = returnFunc(ctx, recordID)
 	} else {
-		r0 = ret.Error(0)
+		r0 = ret.Get(0).(int64)
 	}
-	return r0
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, recordID)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
 }
 
-// Database_MarkSystemInitializing_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'MarkSystemInitializing'
-type Database_MarkSystemInitializing_Call struct {
+// Database_SumRecordVersionSizes_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SumRecordVersionSizes'
+type Database_SumRecordVersionSizes_Call struct {
 	*mock.Call
 }
 
-// MarkSystemInitializing is a helper method to define mock.On call
+// SumRecordVersionSizes is a helper method to define mock.On call
 //   - ctx context.Context
-func (_e *Database_Expecter) MarkSystemInitializing(ctx interface{}) *Database_MarkSystemInitializing_Call {
-	return &Database_MarkSystemInitializing_Call{Call: _e.mock.On("MarkSystemInitializing", ctx)}
+//   - recordID string
+func (_e *Database_Expecter) SumRecordVersionSizes(ctx interface{}, recordID interface{}) *Database_SumRecordVersionSizes_Call {
+	return &Database_SumRecordVersionSizes_Call{Call: _e.mock.On("SumRecordVersionSizes", ctx, recordID)}
 }
 
-func (_c *Database_MarkSystemInitializing_Call) Run(run func(ctx context.Context)) *Database_MarkSystemInitializing_Call {
+func (_c *Database_SumRecordVersionSizes_Call) Run(run func(ctx context.Context, recordID string)) *Database_SumRecordVersionSizes_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
 			arg0 = args[0].(context.Context)
 		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
 		run(
 			arg0,
+			arg1,
 		)
 	})
 	return _c
 }
 
-func (_c *Database_MarkSystemInitializing_Call) Return(err error) *Database_MarkSystemInitializing_Call {
-	_c.Call.Return(err)
+func (_c *Database_SumRecordVersionSizes_Call) Return(int64 int64, err error) *Database_SumRecordVersionSizes_Call {
+	_c.Call.Return(int64, err)
 	return _c
 }
 
-func (_c *Database_MarkSystemInitializing_Call) RunAndReturn(run func(ctx context.Context) error) *Database_MarkSystemInitializing_Call {
+func (_c *Database_SumRecordVersionSizes_Call) RunAndReturn(run func(ctx context.Context, recordID string) (int64, error)) *Database_SumRecordVersionSizes_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// RecordEncryptionKey provides a mock function for the type Database
-func (_mock *Database) RecordEncryptionKey(ctx context.Context, encKeyMaterial []byte) (models.EncryptionKey, error) {
-	ret := _mock.Called(ctx, encKeyMaterial)
+// UpdateEncryptionKeyMaterial provides a mock function for the type Database
+func (_mock *Database) UpdateEncryptionKeyMaterial(ctx context.Context, keyID string, encKeyMaterial []byte, rsaFingerprint string) error {
+	ret := _mock.Called(ctx, keyID, encKeyMaterial, rsaFingerprint)
 
 	if len(ret) == 0 {
-		panic("no return value specified for RecordEncryptionKey")
+		panic("no return value specified for UpdateEncryptionKeyMaterial")
 	}
 
-	var r0 models.EncryptionKey
-	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, []byte) (models.EncryptionKey, error)); ok {
-		return returnFunc(ctx, encKeyMaterial)
-	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, []byte) models.EncryptionKey); ok {
-		r0 = returnFunc(ctx, encKeyMaterial)
-	} else {
-		r0 = ret.Get(0).(models.EncryptionKey)
-	}
-	if returnFunc, ok := ret.Get(1).(func(context.Context, []byte) error); ok {
-		r1 = returnFunc(ctx, encKeyMaterial)
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []byte, string) error); ok {
+		r0 = returnFunc(ctx, keyID, encKeyMaterial, rsaFingerprint)
 	} else {
-		r1 = ret.Error(1)
+		r0 = ret.Error(0)
 	}
-	return r0, r1
+	return r0
 }
 
-// Database_RecordEncryptionKey_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RecordEncryptionKey'
-type Database_RecordEncryptionKey_Call struct {
+// Database_UpdateEncryptionKeyMaterial_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UpdateEncryptionKeyMaterial'
+type Database_UpdateEncryptionKeyMaterial_Call struct {
 	*mock.Call
 }
 
-// RecordEncryptionKey is a helper method to define mock.On call
+// UpdateEncryptionKeyMaterial is a helper method to define mock.On call
 //   - ctx context.Context
+//   - keyID string
 //   - encKeyMaterial []byte
-func (_e *Database_Expecter) RecordEncryptionKey(ctx interface{}, encKeyMaterial interface{}) *Database_RecordEncryptionKey_Call {
-	return &Database_RecordEncryptionKey_Call{Call: _e.mock.On("RecordEncryptionKey", ctx, encKeyMaterial)}
+//   - rsaFingerprint string
+func (_e *Database_Expecter) UpdateEncryptionKeyMaterial(ctx interface{}, keyID interface{}, encKeyMaterial interface{}, rsaFingerprint interface{}) *Database_UpdateEncryptionKeyMaterial_Call {
+	return &Database_UpdateEncryptionKeyMaterial_Call{Call: _e.mock.On("UpdateEncryptionKeyMaterial", ctx, keyID, encKeyMaterial, rsaFingerprint)}
 }
 
-func (_c *Database_RecordEncryptionKey_Call) Run(run func(ctx context.Context, encKeyMaterial []byte)) *Database_RecordEncryptionKey_Call {
+func (_c *Database_UpdateEncryptionKeyMaterial_Call) Run(run func(ctx context.Context, keyID string, encKeyMaterial []byte, rsaFingerprint string)) *Database_UpdateEncryptionKeyMaterial_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
 			arg0 = args[0].(context.Context)
 		}
-		var arg1 []byte
+		var arg1 string
 		if args[1] != nil {
-			arg1 = args[1].([]byte)
+			arg1 = args[1].(string)
+		}
+		var arg2 []byte
+		if args[2] != nil {
+			arg2 = args[2].([]byte)
+		}
+		var arg3 string
+		if args[3] != nil {
+			arg3 = args[3].(string)
 		}
 		run(
 			arg0,
 			arg1,
+			arg2,
+			arg3,
 		)
 	})
 	return _c
 }
 
-func (_c *Database_RecordEncryptionKey_Call) Return(encryptionKey models.EncryptionKey, err error) *Database_RecordEncryptionKey_Call {
-	_c.Call.Return(encryptionKey, err)
+func (_c *Database_UpdateEncryptionKeyMaterial_Call) Return(err error) *Database_UpdateEncryptionKeyMaterial_Call {
+	_c.Call.Return(err)
 	return _c
 }
 
-func (_c *Database_RecordEncryptionKey_Call) RunAndReturn(run func(ctx context.Context, encKeyMaterial []byte) (models.EncryptionKey, error)) *Database_RecordEncryptionKey_Call {
+func (_c *Database_UpdateEncryptionKeyMaterial_Call) RunAndReturn(run func(ctx context.Context, keyID string, encKeyMaterial []byte, rsaFingerprint string) error) *Database_UpdateEncryptionKeyMaterial_Call {
 	_c.Call.Return(run)
 	return _c
 }
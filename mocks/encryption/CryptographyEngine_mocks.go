@@ -40,6 +40,50 @@ func (_m *CryptographyEngine) EXPECT() *CryptographyEngine_Expecter {
 	return &CryptographyEngine_Expecter{mock: &_m.Mock}
 }
 
+// CacheStats provides a mock function for the type CryptographyEngine
+func (_mock *CryptographyEngine) CacheStats() encryption.KeyCacheStats {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for CacheStats")
+	}
+
+	var r0 encryption.KeyCacheStats
+	if returnFunc, ok := ret.Get(0).(func() encryption.KeyCacheStats); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Get(0).(encryption.KeyCacheStats)
+	}
+	return r0
+}
+
+// CryptographyEngine_CacheStats_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CacheStats'
+type CryptographyEngine_CacheStats_Call struct {
+	*mock.Call
+}
+
+// CacheStats is a helper method to define mock.On call
+func (_e *CryptographyEngine_Expecter) CacheStats() *CryptographyEngine_CacheStats_Call {
+	return &CryptographyEngine_CacheStats_Call{Call: _e.mock.On("CacheStats")}
+}
+
+func (_c *CryptographyEngine_CacheStats_Call) Run(run func()) *CryptographyEngine_CacheStats_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *CryptographyEngine_CacheStats_Call) Return(keyCacheStats encryption.KeyCacheStats) *CryptographyEngine_CacheStats_Call {
+	_c.Call.Return(keyCacheStats)
+	return _c
+}
+
+func (_c *CryptographyEngine_CacheStats_Call) RunAndReturn(run func() encryption.KeyCacheStats) *CryptographyEngine_CacheStats_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // DecryptData provides a mock function for the type CryptographyEngine
 func (_mock *CryptographyEngine) DecryptData(ctx context.Context, keyID string, encrypted encryption.EncryptedData, activeDBClient db.Database) (models.EncryptionKey, []byte, error) {
 	ret := _mock.Called(ctx, keyID, encrypted, activeDBClient)
@@ -190,8 +234,8 @@ func (_c *CryptographyEngine_DeleteEncryptionKey_Call) RunAndReturn(run func(ctx
 }
 
 // EncryptData provides a mock function for the type CryptographyEngine
-func (_mock *CryptographyEngine) EncryptData(ctx context.Context, keyID string, plainText []byte, activeDBClient db.Database) (models.EncryptionKey, encryption.EncryptedData, error) {
-	ret := _mock.Called(ctx, keyID, plainText, activeDBClient)
+func (_mock *CryptographyEngine) EncryptData(ctx context.Context, keyID string, plainText []byte, aad []byte, subkeyInfo []byte, activeDBClient db.Database) (models.EncryptionKey, encryption.EncryptedData, error) {
+	ret := _mock.Called(ctx, keyID, plainText, aad, subkeyInfo, activeDBClient)
 
 	if len(ret) == 0 {
 		panic("no return value specified for EncryptData")
@@ -200,21 +244,21 @@ func (_mock *CryptographyEngine) EncryptData(ctx context.Context, keyID string,
 	var r0 models.EncryptionKey
 	var r1 encryption.EncryptedData
 	var r2 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []byte, db.Database) (models.EncryptionKey, encryption.EncryptedData, error)); ok {
-		return returnFunc(ctx, keyID, plainText, activeDBClient)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []byte, []byte, []byte, db.Database) (models.EncryptionKey, encryption.EncryptedData, error)); ok {
+		return returnFunc(ctx, keyID, plainText, aad, subkeyInfo, activeDBClient)
 	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []byte, db.Database) models.EncryptionKey); ok {
-		r0 = returnFunc(ctx, keyID, plainText, activeDBClient)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []byte, []byte, []byte, db.Database) models.EncryptionKey); ok {
+		r0 = returnFunc(ctx, keyID, plainText, aad, subkeyInfo, activeDBClient)
 	} else {
 		r0 = ret.Get(0).(models.EncryptionKey)
 	}
-	if returnFunc, ok := ret.Get(1).(func(context.Context, string, []byte, db.Database) encryption.EncryptedData); ok {
-		r1 = returnFunc(ctx, keyID, plainText, activeDBClient)
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, []byte, []byte, []byte, db.Database) encryption.EncryptedData); ok {
+		r1 = returnFunc(ctx, keyID, plainText, aad, subkeyInfo, activeDBClient)
 	} else {
 		r1 = ret.Get(1).(encryption.EncryptedData)
 	}
-	if returnFunc, ok := ret.Get(2).(func(context.Context, string, []byte, db.Database) error); ok {
-		r2 = returnFunc(ctx, keyID, plainText, activeDBClient)
+	if returnFunc, ok := ret.Get(2).(func(context.Context, string, []byte, []byte, []byte, db.Database) error); ok {
+		r2 = returnFunc(ctx, keyID, plainText, aad, subkeyInfo, activeDBClient)
 	} else {
 		r2 = ret.Error(2)
 	}
@@ -230,12 +274,14 @@ type CryptographyEngine_EncryptData_Call struct {
 //   - ctx context.Context
 //   - keyID string
 //   - plainText []byte
+//   - aad []byte
+//   - subkeyInfo []byte
 //   - activeDBClient db.Database
-func (_e *CryptographyEngine_Expecter) EncryptData(ctx interface{}, keyID interface{}, plainText interface{}, activeDBClient interface{}) *CryptographyEngine_EncryptData_Call {
-	return &CryptographyEngine_EncryptData_Call{Call: _e.mock.On("EncryptData", ctx, keyID, plainText, activeDBClient)}
+func (_e *CryptographyEngine_Expecter) EncryptData(ctx interface{}, keyID interface{}, plainText interface{}, aad interface{}, subkeyInfo interface{}, activeDBClient interface{}) *CryptographyEngine_EncryptData_Call {
+	return &CryptographyEngine_EncryptData_Call{Call: _e.mock.On("EncryptData", ctx, keyID, plainText, aad, subkeyInfo, activeDBClient)}
 }
 
-func (_c *CryptographyEngine_EncryptData_Call) Run(run func(ctx context.Context, keyID string, plainText []byte, activeDBClient db.Database)) *CryptographyEngine_EncryptData_Call {
+func (_c *CryptographyEngine_EncryptData_Call) Run(run func(ctx context.Context, keyID string, plainText []byte, aad []byte, subkeyInfo []byte, activeDBClient db.Database)) *CryptographyEngine_EncryptData_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
@@ -249,15 +295,25 @@ func (_c *CryptographyEngine_EncryptData_Call) Run(run func(ctx context.Context,
 		if args[2] != nil {
 			arg2 = args[2].([]byte)
 		}
-		var arg3 db.Database
+		var arg3 []byte
 		if args[3] != nil {
-			arg3 = args[3].(db.Database)
+			arg3 = args[3].([]byte)
+		}
+		var arg4 []byte
+		if args[4] != nil {
+			arg4 = args[4].([]byte)
+		}
+		var arg5 db.Database
+		if args[5] != nil {
+			arg5 = args[5].(db.Database)
 		}
 		run(
 			arg0,
 			arg1,
 			arg2,
 			arg3,
+			arg4,
+			arg5,
 		)
 	})
 	return _c
@@ -268,7 +324,7 @@ func (_c *CryptographyEngine_EncryptData_Call) Return(encryptionKey models.Encry
 	return _c
 }
 
-func (_c *CryptographyEngine_EncryptData_Call) RunAndReturn(run func(ctx context.Context, keyID string, plainText []byte, activeDBClient db.Database) (models.EncryptionKey, encryption.EncryptedData, error)) *CryptographyEngine_EncryptData_Call {
+func (_c *CryptographyEngine_EncryptData_Call) RunAndReturn(run func(ctx context.Context, keyID string, plainText []byte, aad []byte, subkeyInfo []byte, activeDBClient db.Database) (models.EncryptionKey, encryption.EncryptedData, error)) *CryptographyEngine_EncryptData_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -628,3 +684,525 @@ func (_c *CryptographyEngine_NewEncryptionKey_Call) RunAndReturn(run func(ctx co
 	_c.Call.Return(run)
 	return _c
 }
+
+// RedistributeVersions provides a mock function for the type CryptographyEngine
+func (_mock *CryptographyEngine) RedistributeVersions(ctx context.Context, sourceKeyID string, targetKeyIDs []string, activeDBClient db.Database) (int, error) {
+	ret := _mock.Called(ctx, sourceKeyID, targetKeyIDs, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RedistributeVersions")
+	}
+
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []string, db.Database) (int, error)); ok {
+		return returnFunc(ctx, sourceKeyID, targetKeyIDs, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []string, db.Database) int); ok {
+		r0 = returnFunc(ctx, sourceKeyID, targetKeyIDs, activeDBClient)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, []string, db.Database) error); ok {
+		r1 = returnFunc(ctx, sourceKeyID, targetKeyIDs, activeDBClient)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// CryptographyEngine_RedistributeVersions_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RedistributeVersions'
+type CryptographyEngine_RedistributeVersions_Call struct {
+	*mock.Call
+}
+
+// RedistributeVersions is a helper method to define mock.On call
+//   - ctx context.Context
+//   - sourceKeyID string
+//   - targetKeyIDs []string
+//   - activeDBClient db.Database
+func (_e *CryptographyEngine_Expecter) RedistributeVersions(ctx interface{}, sourceKeyID interface{}, targetKeyIDs interface{}, activeDBClient interface{}) *CryptographyEngine_RedistributeVersions_Call {
+	return &CryptographyEngine_RedistributeVersions_Call{Call: _e.mock.On("RedistributeVersions", ctx, sourceKeyID, targetKeyIDs, activeDBClient)}
+}
+
+func (_c *CryptographyEngine_RedistributeVersions_Call) Run(run func(ctx context.Context, sourceKeyID string, targetKeyIDs []string, activeDBClient db.Database)) *CryptographyEngine_RedistributeVersions_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 []string
+		if args[2] != nil {
+			arg2 = args[2].([]string)
+		}
+		var arg3 db.Database
+		if args[3] != nil {
+			arg3 = args[3].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+		)
+	})
+	return _c
+}
+
+func (_c *CryptographyEngine_RedistributeVersions_Call) Return(int int, err error) *CryptographyEngine_RedistributeVersions_Call {
+	_c.Call.Return(int, err)
+	return _c
+}
+
+func (_c *CryptographyEngine_RedistributeVersions_Call) RunAndReturn(run func(ctx context.Context, sourceKeyID string, targetKeyIDs []string, activeDBClient db.Database) (int, error)) *CryptographyEngine_RedistributeVersions_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ReloadRSAKeyPair provides a mock function for the type CryptographyEngine
+func (_mock *CryptographyEngine) ReloadRSAKeyPair(ctx context.Context, certFile string, keyFile string) error {
+	ret := _mock.Called(ctx, certFile, keyFile)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReloadRSAKeyPair")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = returnFunc(ctx, certFile, keyFile)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// CryptographyEngine_ReloadRSAKeyPair_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ReloadRSAKeyPair'
+type CryptographyEngine_ReloadRSAKeyPair_Call struct {
+	*mock.Call
+}
+
+// ReloadRSAKeyPair is a helper method to define mock.On call
+//   - ctx context.Context
+//   - certFile string
+//   - keyFile string
+func (_e *CryptographyEngine_Expecter) ReloadRSAKeyPair(ctx interface{}, certFile interface{}, keyFile interface{}) *CryptographyEngine_ReloadRSAKeyPair_Call {
+	return &CryptographyEngine_ReloadRSAKeyPair_Call{Call: _e.mock.On("ReloadRSAKeyPair", ctx, certFile, keyFile)}
+}
+
+func (_c *CryptographyEngine_ReloadRSAKeyPair_Call) Run(run func(ctx context.Context, certFile string, keyFile string)) *CryptographyEngine_ReloadRSAKeyPair_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *CryptographyEngine_ReloadRSAKeyPair_Call) Return(err error) *CryptographyEngine_ReloadRSAKeyPair_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *CryptographyEngine_ReloadRSAKeyPair_Call) RunAndReturn(run func(ctx context.Context, certFile string, keyFile string) error) *CryptographyEngine_ReloadRSAKeyPair_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RewrapEncryptionKeys provides a mock function for the type CryptographyEngine
+func (_mock *CryptographyEngine) RewrapEncryptionKeys(ctx context.Context, activeDBClient db.Database) (int, error) {
+	ret := _mock.Called(ctx, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RewrapEncryptionKeys")
+	}
+
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.Database) (int, error)); ok {
+		return returnFunc(ctx, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.Database) int); ok {
+		r0 = returnFunc(ctx, activeDBClient)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, db.Database) error); ok {
+		r1 = returnFunc(ctx, activeDBClient)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// CryptographyEngine_RewrapEncryptionKeys_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RewrapEncryptionKeys'
+type CryptographyEngine_RewrapEncryptionKeys_Call struct {
+	*mock.Call
+}
+
+// RewrapEncryptionKeys is a helper method to define mock.On call
+//   - ctx context.Context
+//   - activeDBClient db.Database
+func (_e *CryptographyEngine_Expecter) RewrapEncryptionKeys(ctx interface{}, activeDBClient interface{}) *CryptographyEngine_RewrapEncryptionKeys_Call {
+	return &CryptographyEngine_RewrapEncryptionKeys_Call{Call: _e.mock.On("RewrapEncryptionKeys", ctx, activeDBClient)}
+}
+
+func (_c *CryptographyEngine_RewrapEncryptionKeys_Call) Run(run func(ctx context.Context, activeDBClient db.Database)) *CryptographyEngine_RewrapEncryptionKeys_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 db.Database
+		if args[1] != nil {
+			arg1 = args[1].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *CryptographyEngine_RewrapEncryptionKeys_Call) Return(int int, err error) *CryptographyEngine_RewrapEncryptionKeys_Call {
+	_c.Call.Return(int, err)
+	return _c
+}
+
+func (_c *CryptographyEngine_RewrapEncryptionKeys_Call) RunAndReturn(run func(ctx context.Context, activeDBClient db.Database) (int, error)) *CryptographyEngine_RewrapEncryptionKeys_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Shutdown provides a mock function for the type CryptographyEngine
+func (_mock *CryptographyEngine) Shutdown() {
+	_mock.Called()
+	return
+}
+
+// CryptographyEngine_Shutdown_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Shutdown'
+type CryptographyEngine_Shutdown_Call struct {
+	*mock.Call
+}
+
+// Shutdown is a helper method to define mock.On call
+func (_e *CryptographyEngine_Expecter) Shutdown() *CryptographyEngine_Shutdown_Call {
+	return &CryptographyEngine_Shutdown_Call{Call: _e.mock.On("Shutdown")}
+}
+
+func (_c *CryptographyEngine_Shutdown_Call) Run(run func()) *CryptographyEngine_Shutdown_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *CryptographyEngine_Shutdown_Call) Return() *CryptographyEngine_Shutdown_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *CryptographyEngine_Shutdown_Call) RunAndReturn(run func()) *CryptographyEngine_Shutdown_Call {
+	_c.Run(run)
+	return _c
+}
+
+// SummarizeKeys provides a mock function for the type CryptographyEngine
+func (_mock *CryptographyEngine) SummarizeKeys(ctx context.Context, activeDBClient db.Database) (encryption.KeySummary, error) {
+	ret := _mock.Called(ctx, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SummarizeKeys")
+	}
+
+	var r0 encryption.KeySummary
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.Database) (encryption.KeySummary, error)); ok {
+		return returnFunc(ctx, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.Database) encryption.KeySummary); ok {
+		r0 = returnFunc(ctx, activeDBClient)
+	} else {
+		r0 = ret.Get(0).(encryption.KeySummary)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, db.Database) error); ok {
+		r1 = returnFunc(ctx, activeDBClient)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// CryptographyEngine_SummarizeKeys_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SummarizeKeys'
+type CryptographyEngine_SummarizeKeys_Call struct {
+	*mock.Call
+}
+
+// SummarizeKeys is a helper method to define mock.On call
+//   - ctx context.Context
+//   - activeDBClient db.Database
+func (_e *CryptographyEngine_Expecter) SummarizeKeys(ctx interface{}, activeDBClient interface{}) *CryptographyEngine_SummarizeKeys_Call {
+	return &CryptographyEngine_SummarizeKeys_Call{Call: _e.mock.On("SummarizeKeys", ctx, activeDBClient)}
+}
+
+func (_c *CryptographyEngine_SummarizeKeys_Call) Run(run func(ctx context.Context, activeDBClient db.Database)) *CryptographyEngine_SummarizeKeys_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 db.Database
+		if args[1] != nil {
+			arg1 = args[1].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *CryptographyEngine_SummarizeKeys_Call) Return(keySummary encryption.KeySummary, err error) *CryptographyEngine_SummarizeKeys_Call {
+	_c.Call.Return(keySummary, err)
+	return _c
+}
+
+func (_c *CryptographyEngine_SummarizeKeys_Call) RunAndReturn(run func(ctx context.Context, activeDBClient db.Database) (encryption.KeySummary, error)) *CryptographyEngine_SummarizeKeys_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// UnwrapSecret provides a mock function for the type CryptographyEngine
+func (_mock *CryptographyEngine) UnwrapSecret(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	ret := _mock.Called(ctx, ciphertext)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UnwrapSecret")
+	}
+
+	var r0 []byte
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []byte) ([]byte, error)); ok {
+		return returnFunc(ctx, ciphertext)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []byte) []byte); ok {
+		r0 = returnFunc(ctx, ciphertext)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, []byte) error); ok {
+		r1 = returnFunc(ctx, ciphertext)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// CryptographyEngine_UnwrapSecret_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UnwrapSecret'
+type CryptographyEngine_UnwrapSecret_Call struct {
+	*mock.Call
+}
+
+// UnwrapSecret is a helper method to define mock.On call
+//   - ctx context.Context
+//   - ciphertext []byte
+func (_e *CryptographyEngine_Expecter) UnwrapSecret(ctx interface{}, ciphertext interface{}) *CryptographyEngine_UnwrapSecret_Call {
+	return &CryptographyEngine_UnwrapSecret_Call{Call: _e.mock.On("UnwrapSecret", ctx, ciphertext)}
+}
+
+func (_c *CryptographyEngine_UnwrapSecret_Call) Run(run func(ctx context.Context, ciphertext []byte)) *CryptographyEngine_UnwrapSecret_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 []byte
+		if args[1] != nil {
+			arg1 = args[1].([]byte)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *CryptographyEngine_UnwrapSecret_Call) Return(bytes []byte, err error) *CryptographyEngine_UnwrapSecret_Call {
+	_c.Call.Return(bytes, err)
+	return _c
+}
+
+func (_c *CryptographyEngine_UnwrapSecret_Call) RunAndReturn(run func(ctx context.Context, ciphertext []byte) ([]byte, error)) *CryptographyEngine_UnwrapSecret_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// VerifyKeyVersionsDecryptable provides a mock function for the type CryptographyEngine
+func (_mock *CryptographyEngine) VerifyKeyVersionsDecryptable(ctx context.Context, keyID string, activeDBClient db.Database) (bool, []string, error) {
+	ret := _mock.Called(ctx, keyID, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for VerifyKeyVersionsDecryptable")
+	}
+
+	var r0 bool
+	var r1 []string
+	var r2 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, db.Database) (bool, []string, error)); ok {
+		return returnFunc(ctx, keyID, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, db.Database) bool); ok {
+		r0 = returnFunc(ctx, keyID, activeDBClient)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, db.Database) []string); ok {
+		r1 = returnFunc(ctx, keyID, activeDBClient)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]string)
+		}
+	}
+	if returnFunc, ok := ret.Get(2).(func(context.Context, string, db.Database) error); ok {
+		r2 = returnFunc(ctx, keyID, activeDBClient)
+	} else {
+		r2 = ret.Error(2)
+	}
+	return r0, r1, r2
+}
+
+// CryptographyEngine_VerifyKeyVersionsDecryptable_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'VerifyKeyVersionsDecryptable'
+type CryptographyEngine_VerifyKeyVersionsDecryptable_Call struct {
+	*mock.Call
+}
+
+// VerifyKeyVersionsDecryptable is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+//   - activeDBClient db.Database
+func (_e *CryptographyEngine_Expecter) VerifyKeyVersionsDecryptable(ctx interface{}, keyID interface{}, activeDBClient interface{}) *CryptographyEngine_VerifyKeyVersionsDecryptable_Call {
+	return &CryptographyEngine_VerifyKeyVersionsDecryptable_Call{Call: _e.mock.On("VerifyKeyVersionsDecryptable", ctx, keyID, activeDBClient)}
+}
+
+func (_c *CryptographyEngine_VerifyKeyVersionsDecryptable_Call) Run(run func(ctx context.Context, keyID string, activeDBClient db.Database)) *CryptographyEngine_VerifyKeyVersionsDecryptable_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 db.Database
+		if args[2] != nil {
+			arg2 = args[2].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *CryptographyEngine_VerifyKeyVersionsDecryptable_Call) Return(bool bool, strings []string, err error) *CryptographyEngine_VerifyKeyVersionsDecryptable_Call {
+	_c.Call.Return(bool, strings, err)
+	return _c
+}
+
+func (_c *CryptographyEngine_VerifyKeyVersionsDecryptable_Call) RunAndReturn(run func(ctx context.Context, keyID string, activeDBClient db.Database) (bool, []string, error)) *CryptographyEngine_VerifyKeyVersionsDecryptable_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// WrapSecret provides a mock function for the type CryptographyEngine
+func (_mock *CryptographyEngine) WrapSecret(ctx context.Context, plaintext []byte) ([]byte, error) {
+	ret := _mock.Called(ctx, plaintext)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WrapSecret")
+	}
+
+	var r0 []byte
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []byte) ([]byte, error)); ok {
+		return returnFunc(ctx, plaintext)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []byte) []byte); ok {
+		r0 = returnFunc(ctx, plaintext)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, []byte) error); ok {
+		r1 = returnFunc(ctx, plaintext)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// CryptographyEngine_WrapSecret_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'WrapSecret'
+type CryptographyEngine_WrapSecret_Call struct {
+	*mock.Call
+}
+
+// WrapSecret is a helper method to define mock.On call
+//   - ctx context.Context
+//   - plaintext []byte
+func (_e *CryptographyEngine_Expecter) WrapSecret(ctx interface{}, plaintext interface{}) *CryptographyEngine_WrapSecret_Call {
+	return &CryptographyEngine_WrapSecret_Call{Call: _e.mock.On("WrapSecret", ctx, plaintext)}
+}
+
+func (_c *CryptographyEngine_WrapSecret_Call) Run(run func(ctx context.Context, plaintext []byte)) *CryptographyEngine_WrapSecret_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 []byte
+		if args[1] != nil {
+			arg1 = args[1].([]byte)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *CryptographyEngine_WrapSecret_Call) Return(bytes []byte, err error) *CryptographyEngine_WrapSecret_Call {
+	_c.Call.Return(bytes, err)
+	return _c
+}
+
+func (_c *CryptographyEngine_WrapSecret_Call) RunAndReturn(run func(ctx context.Context, plaintext []byte) ([]byte, error)) *CryptographyEngine_WrapSecret_Call {
+	_c.Call.Return(run)
+	return _c
+}
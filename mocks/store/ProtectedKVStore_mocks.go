@@ -6,10 +6,12 @@ package mockstore
 
 import (
 	"context"
+	"io"
 	"time"
 
 	"github.com/alwitt/haven/db"
 	"github.com/alwitt/haven/models"
+	"github.com/alwitt/haven/store"
 	mock "github.com/stretchr/testify/mock"
 )
 
@@ -40,37 +42,47 @@ func (_m *ProtectedKVStore) EXPECT() *ProtectedKVStore_Expecter {
 	return &ProtectedKVStore_Expecter{mock: &_m.Mock}
 }
 
-// DeleteKey provides a mock function for the type ProtectedKVStore
-func (_mock *ProtectedKVStore) DeleteKey(ctx context.Context, key string, activeDBClient db.Database) error {
-	ret := _mock.Called(ctx, key, activeDBClient)
+// Bootstrap provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) Bootstrap(ctx context.Context, key string, value []byte, activeDBClient db.Database) (models.RecordVersion, error) {
+	ret := _mock.Called(ctx, key, value, activeDBClient)
 
 	if len(ret) == 0 {
-		panic("no return value specified for DeleteKey")
+		panic("no return value specified for Bootstrap")
 	}
 
-	var r0 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string, db.Database) error); ok {
-		r0 = returnFunc(ctx, key, activeDBClient)
+	var r0 models.RecordVersion
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []byte, db.Database) (models.RecordVersion, error)); ok {
+		return returnFunc(ctx, key, value, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []byte, db.Database) models.RecordVersion); ok {
+		r0 = returnFunc(ctx, key, value, activeDBClient)
 	} else {
-		r0 = ret.Error(0)
+		r0 = ret.Get(0).(models.RecordVersion)
 	}
-	return r0
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, []byte, db.Database) error); ok {
+		r1 = returnFunc(ctx, key, value, activeDBClient)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
 }
 
-// ProtectedKVStore_DeleteKey_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteKey'
-type ProtectedKVStore_DeleteKey_Call struct {
+// ProtectedKVStore_Bootstrap_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Bootstrap'
+type ProtectedKVStore_Bootstrap_Call struct {
 	*mock.Call
 }
 
-// DeleteKey is a helper method to define mock.On call
+// Bootstrap is a helper method to define mock.On call
 //   - ctx context.Context
 //   - key string
+//   - value []byte
 //   - activeDBClient db.Database
-func (_e *ProtectedKVStore_Expecter) DeleteKey(ctx interface{}, key interface{}, activeDBClient interface{}) *ProtectedKVStore_DeleteKey_Call {
-	return &ProtectedKVStore_DeleteKey_Call{Call: _e.mock.On("DeleteKey", ctx, key, activeDBClient)}
+func (_e *ProtectedKVStore_Expecter) Bootstrap(ctx interface{}, key interface{}, value interface{}, activeDBClient interface{}) *ProtectedKVStore_Bootstrap_Call {
+	return &ProtectedKVStore_Bootstrap_Call{Call: _e.mock.On("Bootstrap", ctx, key, value, activeDBClient)}
 }
 
-func (_c *ProtectedKVStore_DeleteKey_Call) Run(run func(ctx context.Context, key string, activeDBClient db.Database)) *ProtectedKVStore_DeleteKey_Call {
+func (_c *ProtectedKVStore_Bootstrap_Call) Run(run func(ctx context.Context, key string, value []byte, activeDBClient db.Database)) *ProtectedKVStore_Bootstrap_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
@@ -80,145 +92,135 @@ func (_c *ProtectedKVStore_DeleteKey_Call) Run(run func(ctx context.Context, key
 		if args[1] != nil {
 			arg1 = args[1].(string)
 		}
-		var arg2 db.Database
+		var arg2 []byte
 		if args[2] != nil {
-			arg2 = args[2].(db.Database)
+			arg2 = args[2].([]byte)
+		}
+		var arg3 db.Database
+		if args[3] != nil {
+			arg3 = args[3].(db.Database)
 		}
 		run(
 			arg0,
 			arg1,
 			arg2,
+			arg3,
 		)
 	})
 	return _c
 }
 
-func (_c *ProtectedKVStore_DeleteKey_Call) Return(err error) *ProtectedKVStore_DeleteKey_Call {
-	_c.Call.Return(err)
+func (_c *ProtectedKVStore_Bootstrap_Call) Return(recordVersion models.RecordVersion, err error) *ProtectedKVStore_Bootstrap_Call {
+	_c.Call.Return(recordVersion, err)
 	return _c
 }
 
-func (_c *ProtectedKVStore_DeleteKey_Call) RunAndReturn(run func(ctx context.Context, key string, activeDBClient db.Database) error) *ProtectedKVStore_DeleteKey_Call {
+func (_c *ProtectedKVStore_Bootstrap_Call) RunAndReturn(run func(ctx context.Context, key string, value []byte, activeDBClient db.Database) (models.RecordVersion, error)) *ProtectedKVStore_Bootstrap_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// GetValueOfKeyAtVersion provides a mock function for the type ProtectedKVStore
-func (_mock *ProtectedKVStore) GetValueOfKeyAtVersion(ctx context.Context, versionEntry models.RecordVersion, activeDBClient db.Database) ([]byte, error) {
-	ret := _mock.Called(ctx, versionEntry, activeDBClient)
+// Close provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) Close() error {
+	ret := _mock.Called()
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetValueOfKeyAtVersion")
+		panic("no return value specified for Close")
 	}
 
-	var r0 []byte
-	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, models.RecordVersion, db.Database) ([]byte, error)); ok {
-		return returnFunc(ctx, versionEntry, activeDBClient)
-	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, models.RecordVersion, db.Database) []byte); ok {
-		r0 = returnFunc(ctx, versionEntry, activeDBClient)
-	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]byte)
-		}
-	}
-	if returnFunc, ok := ret.Get(1).(func(context.Context, models.RecordVersion, db.Database) error); ok {
-		r1 = returnFunc(ctx, versionEntry, activeDBClient)
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func() error); ok {
+		r0 = returnFunc()
 	} else {
-		r1 = ret.Error(1)
+		r0 = ret.Error(0)
 	}
-	return r0, r1
+	return r0
 }
 
-// ProtectedKVStore_GetValueOfKeyAtVersion_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetValueOfKeyAtVersion'
-type ProtectedKVStore_GetValueOfKeyAtVersion_Call struct {
+// ProtectedKVStore_Close_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Close'
+type ProtectedKVStore_Close_Call struct {
 	*mock.Call
 }
 
-// GetValueOfKeyAtVersion is a helper method to define mock.On call
-//   - ctx context.Context
-//   - versionEntry models.RecordVersion
-//   - activeDBClient db.Database
-func (_e *ProtectedKVStore_Expecter) GetValueOfKeyAtVersion(ctx interface{}, versionEntry interface{}, activeDBClient interface{}) *ProtectedKVStore_GetValueOfKeyAtVersion_Call {
-	return &ProtectedKVStore_GetValueOfKeyAtVersion_Call{Call: _e.mock.On("GetValueOfKeyAtVersion", ctx, versionEntry, activeDBClient)}
+// Close is a helper method to define mock.On call
+func (_e *ProtectedKVStore_Expecter) Close() *ProtectedKVStore_Close_Call {
+	return &ProtectedKVStore_Close_Call{Call: _e.mock.On("Close")}
 }
 
-func (_c *ProtectedKVStore_GetValueOfKeyAtVersion_Call) Run(run func(ctx context.Context, versionEntry models.RecordVersion, activeDBClient db.Database)) *ProtectedKVStore_GetValueOfKeyAtVersion_Call {
+func (_c *ProtectedKVStore_Close_Call) Run(run func()) *ProtectedKVStore_Close_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		var arg0 context.Context
-		if args[0] != nil {
-			arg0 = args[0].(context.Context)
-		}
-		var arg1 models.RecordVersion
-		if args[1] != nil {
-			arg1 = args[1].(models.RecordVersion)
-		}
-		var arg2 db.Database
-		if args[2] != nil {
-			arg2 = args[2].(db.Database)
-		}
-		run(
-			arg0,
-			arg1,
-			arg2,
-		)
+		run()
 	})
 	return _c
 }
 
-func (_c *ProtectedKVStore_GetValueOfKeyAtVersion_Call) Return(bytes []byte, err error) *ProtectedKVStore_GetValueOfKeyAtVersion_Call {
-	_c.Call.Return(bytes, err)
+func (_c *ProtectedKVStore_Close_Call) Return(err error) *ProtectedKVStore_Close_Call {
+	_c.Call.Return(err)
 	return _c
 }
 
-func (_c *ProtectedKVStore_GetValueOfKeyAtVersion_Call) RunAndReturn(run func(ctx context.Context, versionEntry models.RecordVersion, activeDBClient db.Database) ([]byte, error)) *ProtectedKVStore_GetValueOfKeyAtVersion_Call {
+func (_c *ProtectedKVStore_Close_Call) RunAndReturn(run func() error) *ProtectedKVStore_Close_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// GetValueOfKeyAtVersionID provides a mock function for the type ProtectedKVStore
-func (_mock *ProtectedKVStore) GetValueOfKeyAtVersionID(ctx context.Context, versionID string, activeDBClient db.Database) ([]byte, error) {
-	ret := _mock.Called(ctx, versionID, activeDBClient)
+// CompareVersions provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) CompareVersions(ctx context.Context, versionIDA string, versionIDB string, activeDBClient db.Database) (bool, []byte, []byte, error) {
+	ret := _mock.Called(ctx, versionIDA, versionIDB, activeDBClient)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetValueOfKeyAtVersionID")
+		panic("no return value specified for CompareVersions")
 	}
 
-	var r0 []byte
-	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string, db.Database) ([]byte, error)); ok {
-		return returnFunc(ctx, versionID, activeDBClient)
+	var r0 bool
+	var r1 []byte
+	var r2 []byte
+	var r3 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string, db.Database) (bool, []byte, []byte, error)); ok {
+		return returnFunc(ctx, versionIDA, versionIDB, activeDBClient)
 	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string, db.Database) []byte); ok {
-		r0 = returnFunc(ctx, versionID, activeDBClient)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string, db.Database) bool); ok {
+		r0 = returnFunc(ctx, versionIDA, versionIDB, activeDBClient)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]byte)
+		r0 = ret.Get(0).(bool)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, string, db.Database) []byte); ok {
+		r1 = returnFunc(ctx, versionIDA, versionIDB, activeDBClient)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]byte)
 		}
 	}
-	if returnFunc, ok := ret.Get(1).(func(context.Context, string, db.Database) error); ok {
-		r1 = returnFunc(ctx, versionID, activeDBClient)
+	if returnFunc, ok := ret.Get(2).(func(context.Context, string, string, db.Database) []byte); ok {
+		r2 = returnFunc(ctx, versionIDA, versionIDB, activeDBClient)
 	} else {
-		r1 = ret.Error(1)
+		if ret.Get(2) != nil {
+			r2 = ret.Get(2).([]byte)
+		}
 	}
-	return r0, r1
+	if returnFunc, ok := ret.Get(3).(func(context.Context, string, string, db.Database) error); ok {
+		r3 = returnFunc(ctx, versionIDA, versionIDB, activeDBClient)
+	} else {
+		r3 = ret.Error(3)
+	}
+	return r0, r1, r2, r3
 }
 
-// ProtectedKVStore_GetValueOfKeyAtVersionID_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetValueOfKeyAtVersionID'
-type ProtectedKVStore_GetValueOfKeyAtVersionID_Call struct {
+// ProtectedKVStore_CompareVersions_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CompareVersions'
+type ProtectedKVStore_CompareVersions_Call struct {
 	*mock.Call
 }
 
-// GetValueOfKeyAtVersionID is a helper method to define mock.On call
+// CompareVersions is a helper method to define mock.On call
 //   - ctx context.Context
-//   - versionID string
+//   - versionIDA string
+//   - versionIDB string
 //   - activeDBClient db.Database
-func (_e *ProtectedKVStore_Expecter) GetValueOfKeyAtVersionID(ctx interface{}, versionID interface{}, activeDBClient interface{}) *ProtectedKVStore_GetValueOfKeyAtVersionID_Call {
-	return &ProtectedKVStore_GetValueOfKeyAtVersionID_Call{Call: _e.mock.On("GetValueOfKeyAtVersionID", ctx, versionID, activeDBClient)}
+func (_e *ProtectedKVStore_Expecter) CompareVersions(ctx interface{}, versionIDA interface{}, versionIDB interface{}, activeDBClient interface{}) *ProtectedKVStore_CompareVersions_Call {
+	return &ProtectedKVStore_CompareVersions_Call{Call: _e.mock.On("CompareVersions", ctx, versionIDA, versionIDB, activeDBClient)}
 }
 
-func (_c *ProtectedKVStore_GetValueOfKeyAtVersionID_Call) Run(run func(ctx context.Context, versionID string, activeDBClient db.Database)) *ProtectedKVStore_GetValueOfKeyAtVersionID_Call {
+func (_c *ProtectedKVStore_CompareVersions_Call) Run(run func(ctx context.Context, versionIDA string, versionIDB string, activeDBClient db.Database)) *ProtectedKVStore_CompareVersions_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
@@ -228,115 +230,106 @@ func (_c *ProtectedKVStore_GetValueOfKeyAtVersionID_Call) Run(run func(ctx conte
 		if args[1] != nil {
 			arg1 = args[1].(string)
 		}
-		var arg2 db.Database
+		var arg2 string
 		if args[2] != nil {
-			arg2 = args[2].(db.Database)
+			arg2 = args[2].(string)
+		}
+		var arg3 db.Database
+		if args[3] != nil {
+			arg3 = args[3].(db.Database)
 		}
 		run(
 			arg0,
 			arg1,
 			arg2,
+			arg3,
 		)
 	})
 	return _c
 }
 
-func (_c *ProtectedKVStore_GetValueOfKeyAtVersionID_Call) Return(bytes []byte, err error) *ProtectedKVStore_GetValueOfKeyAtVersionID_Call {
-	_c.Call.Return(bytes, err)
+func (_c *ProtectedKVStore_CompareVersions_Call) Return(bool bool, bytes []byte, bytes1 []byte, err error) *ProtectedKVStore_CompareVersions_Call {
+	_c.Call.Return(bool, bytes, bytes1, err)
 	return _c
 }
 
-func (_c *ProtectedKVStore_GetValueOfKeyAtVersionID_Call) RunAndReturn(run func(ctx context.Context, versionID string, activeDBClient db.Database) ([]byte, error)) *ProtectedKVStore_GetValueOfKeyAtVersionID_Call {
+func (_c *ProtectedKVStore_CompareVersions_Call) RunAndReturn(run func(ctx context.Context, versionIDA string, versionIDB string, activeDBClient db.Database) (bool, []byte, []byte, error)) *ProtectedKVStore_CompareVersions_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// ListKeyVersions provides a mock function for the type ProtectedKVStore
-func (_mock *ProtectedKVStore) ListKeyVersions(ctx context.Context, key string, activeDBClient db.Database) (models.Record, []models.RecordVersion, error) {
-	ret := _mock.Called(ctx, key, activeDBClient)
+// CountKeys provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) CountKeys(ctx context.Context, activeDBClient db.Database) (int64, error) {
+	ret := _mock.Called(ctx, activeDBClient)
 
 	if len(ret) == 0 {
-		panic("no return value specified for ListKeyVersions")
+		panic("no return value specified for CountKeys")
 	}
 
-	var r0 models.Record
-	var r1 []models.RecordVersion
-	var r2 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string, db.Database) (models.Record, []models.RecordVersion, error)); ok {
-		return returnFunc(ctx, key, activeDBClient)
-	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string, db.Database) models.Record); ok {
-		r0 = returnFunc(ctx, key, activeDBClient)
-	} else {
-		r0 = ret.Get(0).(models.Record)
+	var r0 int64
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.Database) (int64, error)); ok {
+		return returnFunc(ctx, activeDBClient)
 	}
-	if returnFunc, ok := ret.Get(1).(func(context.Context, string, db.Database) []models.RecordVersion); ok {
-		r1 = returnFunc(ctx, key, activeDBClient)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.Database) int64); ok {
+		r0 = returnFunc(ctx, activeDBClient)
 	} else {
-		if ret.Get(1) != nil {
-			r1 = ret.Get(1).([]models.RecordVersion)
-		}
+		r0 = ret.Get(0).(int64)
 	}
-	if returnFunc, ok := ret.Get(2).(func(context.Context, string, db.Database) error); ok {
-		r2 = returnFunc(ctx, key, activeDBClient)
+	if returnFunc, ok := ret.Get(1).(func(context.Context, db.Database) error); ok {
+		r1 = returnFunc(ctx, activeDBClient)
 	} else {
-		r2 = ret.Error(2)
+		r1 = ret.Error(1)
 	}
-	return r0, r1, r2
+	return r0, r1
 }
 
-// ProtectedKVStore_ListKeyVersions_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListKeyVersions'
-type ProtectedKVStore_ListKeyVersions_Call struct {
+// ProtectedKVStore_CountKeys_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CountKeys'
+type ProtectedKVStore_CountKeys_Call struct {
 	*mock.Call
 }
 
-// ListKeyVersions is a helper method to define mock.On call
+// CountKeys is a helper method to define mock.On call
 //   - ctx context.Context
-//   - key string
 //   - activeDBClient db.Database
-func (_e *ProtectedKVStore_Expecter) ListKeyVersions(ctx interface{}, key interface{}, activeDBClient interface{}) *ProtectedKVStore_ListKeyVersions_Call {
-	return &ProtectedKVStore_ListKeyVersions_Call{Call: _e.mock.On("ListKeyVersions", ctx, key, activeDBClient)}
+func (_e *ProtectedKVStore_Expecter) CountKeys(ctx interface{}, activeDBClient interface{}) *ProtectedKVStore_CountKeys_Call {
+	return &ProtectedKVStore_CountKeys_Call{Call: _e.mock.On("CountKeys", ctx, activeDBClient)}
 }
 
-func (_c *ProtectedKVStore_ListKeyVersions_Call) Run(run func(ctx context.Context, key string, activeDBClient db.Database)) *ProtectedKVStore_ListKeyVersions_Call {
+func (_c *ProtectedKVStore_CountKeys_Call) Run(run func(ctx context.Context, activeDBClient db.Database)) *ProtectedKVStore_CountKeys_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
 			arg0 = args[0].(context.Context)
 		}
-		var arg1 string
+		var arg1 db.Database
 		if args[1] != nil {
-			arg1 = args[1].(string)
-		}
-		var arg2 db.Database
-		if args[2] != nil {
-			arg2 = args[2].(db.Database)
+			arg1 = args[1].(db.Database)
 		}
 		run(
 			arg0,
 			arg1,
-			arg2,
 		)
 	})
 	return _c
 }
 
-func (_c *ProtectedKVStore_ListKeyVersions_Call) Return(record models.Record, recordVersions []models.RecordVersion, err error) *ProtectedKVStore_ListKeyVersions_Call {
-	_c.Call.Return(record, recordVersions, err)
+func (_c *ProtectedKVStore_CountKeys_Call) Return(int64 int64, err error) *ProtectedKVStore_CountKeys_Call {
+	_c.Call.Return(int64, err)
 	return _c
 }
 
-func (_c *ProtectedKVStore_ListKeyVersions_Call) RunAndReturn(run func(ctx context.Context, key string, activeDBClient db.Database) (models.Record, []models.RecordVersion, error)) *ProtectedKVStore_ListKeyVersions_Call {
+func (_c *ProtectedKVStore_CountKeys_Call) RunAndReturn(run func(ctx context.Context, activeDBClient db.Database) (int64, error)) *ProtectedKVStore_CountKeys_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// RecordKeyValue provides a mock function for the type ProtectedKVStore
-func (_mock *ProtectedKVStore) RecordKeyValue(ctx context.Context, key string, value []byte, timestamp time.Time, activeDBClient db.Database) (models.Record, models.RecordVersion, error) {
+// CreateKeyWithValue provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) CreateKeyWithValue(ctx context.Context, key string, value []byte, timestamp time.Time, activeDBClient db.Database) (models.Record, models.RecordVersion, error) {
 	ret := _mock.Called(ctx, key, value, timestamp, activeDBClient)
 
 	if len(ret) == 0 {
-		panic("no return value specified for RecordKeyValue")
+		panic("no return value specified for CreateKeyWithValue")
 	}
 
 	var r0 models.Record
@@ -363,22 +356,22 @@ func (_mock *ProtectedKVStore) RecordKeyValue(ctx context.Context, key string, v
 	return r0, r1, r2
 }
 
-// ProtectedKVStore_RecordKeyValue_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RecordKeyValue'
-type ProtectedKVStore_RecordKeyValue_Call struct {
+// ProtectedKVStore_CreateKeyWithValue_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CreateKeyWithValue'
+type ProtectedKVStore_CreateKeyWithValue_Call struct {
 	*mock.Call
 }
 
-// RecordKeyValue is a helper method to define mock.On call
+// CreateKeyWithValue is a helper method to define mock.On call
 //   - ctx context.Context
 //   - key string
 //   - value []byte
 //   - timestamp time.Time
 //   - activeDBClient db.Database
-func (_e *ProtectedKVStore_Expecter) RecordKeyValue(ctx interface{}, key interface{}, value interface{}, timestamp interface{}, activeDBClient interface{}) *ProtectedKVStore_RecordKeyValue_Call {
-	return &ProtectedKVStore_RecordKeyValue_Call{Call: _e.mock.On("RecordKeyValue", ctx, key, value, timestamp, activeDBClient)}
+func (_e *ProtectedKVStore_Expecter) CreateKeyWithValue(ctx interface{}, key interface{}, value interface{}, timestamp interface{}, activeDBClient interface{}) *ProtectedKVStore_CreateKeyWithValue_Call {
+	return &ProtectedKVStore_CreateKeyWithValue_Call{Call: _e.mock.On("CreateKeyWithValue", ctx, key, value, timestamp, activeDBClient)}
 }
 
-func (_c *ProtectedKVStore_RecordKeyValue_Call) Run(run func(ctx context.Context, key string, value []byte, timestamp time.Time, activeDBClient db.Database)) *ProtectedKVStore_RecordKeyValue_Call {
+func (_c *ProtectedKVStore_CreateKeyWithValue_Call) Run(run func(ctx context.Context, key string, value []byte, timestamp time.Time, activeDBClient db.Database)) *ProtectedKVStore_CreateKeyWithValue_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
@@ -411,12 +404,2478 @@ func (_c *ProtectedKVStore_RecordKeyValue_Call) Run(run func(ctx context.Context
 	return _c
 }
 
-func (_c *ProtectedKVStore_RecordKeyValue_Call) Return(record models.Record, recordVersion models.RecordVersion, err error) *ProtectedKVStore_RecordKeyValue_Call {
+func (_c *ProtectedKVStore_CreateKeyWithValue_Call) Return(record models.Record, recordVersion models.RecordVersion, err error) *ProtectedKVStore_CreateKeyWithValue_Call {
 	_c.Call.Return(record, recordVersion, err)
 	return _c
 }
 
-func (_c *ProtectedKVStore_RecordKeyValue_Call) RunAndReturn(run func(ctx context.Context, key string, value []byte, timestamp time.Time, activeDBClient db.Database) (models.Record, models.RecordVersion, error)) *ProtectedKVStore_RecordKeyValue_Call {
+func (_c *ProtectedKVStore_CreateKeyWithValue_Call) RunAndReturn(run func(ctx context.Context, key string, value []byte, timestamp time.Time, activeDBClient db.Database) (models.Record, models.RecordVersion, error)) *ProtectedKVStore_CreateKeyWithValue_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DeleteKey provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) DeleteKey(ctx context.Context, key string, activeDBClient db.Database) error {
+	ret := _mock.Called(ctx, key, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteKey")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, db.Database) error); ok {
+		r0 = returnFunc(ctx, key, activeDBClient)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// ProtectedKVStore_DeleteKey_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteKey'
+type ProtectedKVStore_DeleteKey_Call struct {
+	*mock.Call
+}
+
+// DeleteKey is a helper method to define mock.On call
+//   - ctx context.Context
+//   - key string
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) DeleteKey(ctx interface{}, key interface{}, activeDBClient interface{}) *ProtectedKVStore_DeleteKey_Call {
+	return &ProtectedKVStore_DeleteKey_Call{Call: _e.mock.On("DeleteKey", ctx, key, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_DeleteKey_Call) Run(run func(ctx context.Context, key string, activeDBClient db.Database)) *ProtectedKVStore_DeleteKey_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 db.Database
+		if args[2] != nil {
+			arg2 = args[2].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_DeleteKey_Call) Return(err error) *ProtectedKVStore_DeleteKey_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_DeleteKey_Call) RunAndReturn(run func(ctx context.Context, key string, activeDBClient db.Database) error) *ProtectedKVStore_DeleteKey_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DeleteKeyVersion provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) DeleteKeyVersion(ctx context.Context, key string, versionID string, force bool, activeDBClient db.Database) error {
+	ret := _mock.Called(ctx, key, versionID, force, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteKeyVersion")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string, bool, db.Database) error); ok {
+		r0 = returnFunc(ctx, key, versionID, force, activeDBClient)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// ProtectedKVStore_DeleteKeyVersion_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteKeyVersion'
+type ProtectedKVStore_DeleteKeyVersion_Call struct {
+	*mock.Call
+}
+
+// DeleteKeyVersion is a helper method to define mock.On call
+//   - ctx context.Context
+//   - key string
+//   - versionID string
+//   - force bool
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) DeleteKeyVersion(ctx interface{}, key interface{}, versionID interface{}, force interface{}, activeDBClient interface{}) *ProtectedKVStore_DeleteKeyVersion_Call {
+	return &ProtectedKVStore_DeleteKeyVersion_Call{Call: _e.mock.On("DeleteKeyVersion", ctx, key, versionID, force, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_DeleteKeyVersion_Call) Run(run func(ctx context.Context, key string, versionID string, force bool, activeDBClient db.Database)) *ProtectedKVStore_DeleteKeyVersion_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		var arg3 bool
+		if args[3] != nil {
+			arg3 = args[3].(bool)
+		}
+		var arg4 db.Database
+		if args[4] != nil {
+			arg4 = args[4].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+			arg4,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_DeleteKeyVersion_Call) Return(err error) *ProtectedKVStore_DeleteKeyVersion_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_DeleteKeyVersion_Call) RunAndReturn(run func(ctx context.Context, key string, versionID string, force bool, activeDBClient db.Database) error) *ProtectedKVStore_DeleteKeyVersion_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ExportEncrypted provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) ExportEncrypted(ctx context.Context, w io.Writer, activeDBClient db.Database) error {
+	ret := _mock.Called(ctx, w, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ExportEncrypted")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, io.Writer, db.Database) error); ok {
+		r0 = returnFunc(ctx, w, activeDBClient)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// ProtectedKVStore_ExportEncrypted_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ExportEncrypted'
+type ProtectedKVStore_ExportEncrypted_Call struct {
+	*mock.Call
+}
+
+// ExportEncrypted is a helper method to define mock.On call
+//   - ctx context.Context
+//   - w io.Writer
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) ExportEncrypted(ctx interface{}, w interface{}, activeDBClient interface{}) *ProtectedKVStore_ExportEncrypted_Call {
+	return &ProtectedKVStore_ExportEncrypted_Call{Call: _e.mock.On("ExportEncrypted", ctx, w, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_ExportEncrypted_Call) Run(run func(ctx context.Context, w io.Writer, activeDBClient db.Database)) *ProtectedKVStore_ExportEncrypted_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 io.Writer
+		if args[1] != nil {
+			arg1 = args[1].(io.Writer)
+		}
+		var arg2 db.Database
+		if args[2] != nil {
+			arg2 = args[2].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_ExportEncrypted_Call) Return(err error) *ProtectedKVStore_ExportEncrypted_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_ExportEncrypted_Call) RunAndReturn(run func(ctx context.Context, w io.Writer, activeDBClient db.Database) error) *ProtectedKVStore_ExportEncrypted_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ExportKeyHistory provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) ExportKeyHistory(ctx context.Context, key string, w io.Writer, opts store.ExportOptions, activeDBClient db.Database) error {
+	ret := _mock.Called(ctx, key, w, opts, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ExportKeyHistory")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, io.Writer, store.ExportOptions, db.Database) error); ok {
+		r0 = returnFunc(ctx, key, w, opts, activeDBClient)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// ProtectedKVStore_ExportKeyHistory_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ExportKeyHistory'
+type ProtectedKVStore_ExportKeyHistory_Call struct {
+	*mock.Call
+}
+
+// ExportKeyHistory is a helper method to define mock.On call
+//   - ctx context.Context
+//   - key string
+//   - w io.Writer
+//   - opts store.ExportOptions
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) ExportKeyHistory(ctx interface{}, key interface{}, w interface{}, opts interface{}, activeDBClient interface{}) *ProtectedKVStore_ExportKeyHistory_Call {
+	return &ProtectedKVStore_ExportKeyHistory_Call{Call: _e.mock.On("ExportKeyHistory", ctx, key, w, opts, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_ExportKeyHistory_Call) Run(run func(ctx context.Context, key string, w io.Writer, opts store.ExportOptions, activeDBClient db.Database)) *ProtectedKVStore_ExportKeyHistory_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 io.Writer
+		if args[2] != nil {
+			arg2 = args[2].(io.Writer)
+		}
+		var arg3 store.ExportOptions
+		if args[3] != nil {
+			arg3 = args[3].(store.ExportOptions)
+		}
+		var arg4 db.Database
+		if args[4] != nil {
+			arg4 = args[4].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+			arg4,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_ExportKeyHistory_Call) Return(err error) *ProtectedKVStore_ExportKeyHistory_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_ExportKeyHistory_Call) RunAndReturn(run func(ctx context.Context, key string, w io.Writer, opts store.ExportOptions, activeDBClient db.Database) error) *ProtectedKVStore_ExportKeyHistory_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ExportPlaintext provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) ExportPlaintext(ctx context.Context, w io.Writer, keyPrefix string, activeDBClient db.Database) error {
+	ret := _mock.Called(ctx, w, keyPrefix, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ExportPlaintext")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, io.Writer, string, db.Database) error); ok {
+		r0 = returnFunc(ctx, w, keyPrefix, activeDBClient)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// ProtectedKVStore_ExportPlaintext_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ExportPlaintext'
+type ProtectedKVStore_ExportPlaintext_Call struct {
+	*mock.Call
+}
+
+// ExportPlaintext is a helper method to define mock.On call
+//   - ctx context.Context
+//   - w io.Writer
+//   - keyPrefix string
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) ExportPlaintext(ctx interface{}, w interface{}, keyPrefix interface{}, activeDBClient interface{}) *ProtectedKVStore_ExportPlaintext_Call {
+	return &ProtectedKVStore_ExportPlaintext_Call{Call: _e.mock.On("ExportPlaintext", ctx, w, keyPrefix, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_ExportPlaintext_Call) Run(run func(ctx context.Context, w io.Writer, keyPrefix string, activeDBClient db.Database)) *ProtectedKVStore_ExportPlaintext_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 io.Writer
+		if args[1] != nil {
+			arg1 = args[1].(io.Writer)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		var arg3 db.Database
+		if args[3] != nil {
+			arg3 = args[3].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_ExportPlaintext_Call) Return(err error) *ProtectedKVStore_ExportPlaintext_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_ExportPlaintext_Call) RunAndReturn(run func(ctx context.Context, w io.Writer, keyPrefix string, activeDBClient db.Database) error) *ProtectedKVStore_ExportPlaintext_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetKeyDetail provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) GetKeyDetail(ctx context.Context, key string, activeDBClient db.Database) (store.KeyDetail, error) {
+	ret := _mock.Called(ctx, key, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetKeyDetail")
+	}
+
+	var r0 store.KeyDetail
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, db.Database) (store.KeyDetail, error)); ok {
+		return returnFunc(ctx, key, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, db.Database) store.KeyDetail); ok {
+		r0 = returnFunc(ctx, key, activeDBClient)
+	} else {
+		r0 = ret.Get(0).(store.KeyDetail)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, db.Database) error); ok {
+		r1 = returnFunc(ctx, key, activeDBClient)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// ProtectedKVStore_GetKeyDetail_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetKeyDetail'
+type ProtectedKVStore_GetKeyDetail_Call struct {
+	*mock.Call
+}
+
+// GetKeyDetail is a helper method to define mock.On call
+//   - ctx context.Context
+//   - key string
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) GetKeyDetail(ctx interface{}, key interface{}, activeDBClient interface{}) *ProtectedKVStore_GetKeyDetail_Call {
+	return &ProtectedKVStore_GetKeyDetail_Call{Call: _e.mock.On("GetKeyDetail", ctx, key, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_GetKeyDetail_Call) Run(run func(ctx context.Context, key string, activeDBClient db.Database)) *ProtectedKVStore_GetKeyDetail_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 db.Database
+		if args[2] != nil {
+			arg2 = args[2].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_GetKeyDetail_Call) Return(keyDetail store.KeyDetail, err error) *ProtectedKVStore_GetKeyDetail_Call {
+	_c.Call.Return(keyDetail, err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_GetKeyDetail_Call) RunAndReturn(run func(ctx context.Context, key string, activeDBClient db.Database) (store.KeyDetail, error)) *ProtectedKVStore_GetKeyDetail_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetKeyObject provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) GetKeyObject(ctx context.Context, versionID string, value any, activeDBClient db.Database) error {
+	ret := _mock.Called(ctx, versionID, value, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetKeyObject")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, any, db.Database) error); ok {
+		r0 = returnFunc(ctx, versionID, value, activeDBClient)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// ProtectedKVStore_GetKeyObject_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetKeyObject'
+type ProtectedKVStore_GetKeyObject_Call struct {
+	*mock.Call
+}
+
+// GetKeyObject is a helper method to define mock.On call
+//   - ctx context.Context
+//   - versionID string
+//   - value any
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) GetKeyObject(ctx interface{}, versionID interface{}, value interface{}, activeDBClient interface{}) *ProtectedKVStore_GetKeyObject_Call {
+	return &ProtectedKVStore_GetKeyObject_Call{Call: _e.mock.On("GetKeyObject", ctx, versionID, value, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_GetKeyObject_Call) Run(run func(ctx context.Context, versionID string, value any, activeDBClient db.Database)) *ProtectedKVStore_GetKeyObject_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 any
+		if args[2] != nil {
+			arg2 = args[2].(any)
+		}
+		var arg3 db.Database
+		if args[3] != nil {
+			arg3 = args[3].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_GetKeyObject_Call) Return(err error) *ProtectedKVStore_GetKeyObject_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_GetKeyObject_Call) RunAndReturn(run func(ctx context.Context, versionID string, value any, activeDBClient db.Database) error) *ProtectedKVStore_GetKeyObject_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetLatestValue provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) GetLatestValue(ctx context.Context, key string, activeDBClient db.Database) (models.RecordVersion, []byte, error) {
+	ret := _mock.Called(ctx, key, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetLatestValue")
+	}
+
+	var r0 models.RecordVersion
+	var r1 []byte
+	var r2 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, db.Database) (models.RecordVersion, []byte, error)); ok {
+		return returnFunc(ctx, key, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, db.Database) models.RecordVersion); ok {
+		r0 = returnFunc(ctx, key, activeDBClient)
+	} else {
+		r0 = ret.Get(0).(models.RecordVersion)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, db.Database) []byte); ok {
+		r1 = returnFunc(ctx, key, activeDBClient)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]byte)
+		}
+	}
+	if returnFunc, ok := ret.Get(2).(func(context.Context, string, db.Database) error); ok {
+		r2 = returnFunc(ctx, key, activeDBClient)
+	} else {
+		r2 = ret.Error(2)
+	}
+	return r0, r1, r2
+}
+
+// ProtectedKVStore_GetLatestValue_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetLatestValue'
+type ProtectedKVStore_GetLatestValue_Call struct {
+	*mock.Call
+}
+
+// GetLatestValue is a helper method to define mock.On call
+//   - ctx context.Context
+//   - key string
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) GetLatestValue(ctx interface{}, key interface{}, activeDBClient interface{}) *ProtectedKVStore_GetLatestValue_Call {
+	return &ProtectedKVStore_GetLatestValue_Call{Call: _e.mock.On("GetLatestValue", ctx, key, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_GetLatestValue_Call) Run(run func(ctx context.Context, key string, activeDBClient db.Database)) *ProtectedKVStore_GetLatestValue_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 db.Database
+		if args[2] != nil {
+			arg2 = args[2].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_GetLatestValue_Call) Return(recordVersion models.RecordVersion, bytes []byte, err error) *ProtectedKVStore_GetLatestValue_Call {
+	_c.Call.Return(recordVersion, bytes, err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_GetLatestValue_Call) RunAndReturn(run func(ctx context.Context, key string, activeDBClient db.Database) (models.RecordVersion, []byte, error)) *ProtectedKVStore_GetLatestValue_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetLatestValues provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) GetLatestValues(ctx context.Context, keys []string, activeDBClient db.Database) (map[string]store.ValueResult, error) {
+	ret := _mock.Called(ctx, keys, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetLatestValues")
+	}
+
+	var r0 map[string]store.ValueResult
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []string, db.Database) (map[string]store.ValueResult, error)); ok {
+		return returnFunc(ctx, keys, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []string, db.Database) map[string]store.ValueResult); ok {
+		r0 = returnFunc(ctx, keys, activeDBClient)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]store.ValueResult)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, []string, db.Database) error); ok {
+		r1 = returnFunc(ctx, keys, activeDBClient)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// ProtectedKVStore_GetLatestValues_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetLatestValues'
+type ProtectedKVStore_GetLatestValues_Call struct {
+	*mock.Call
+}
+
+// GetLatestValues is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keys []string
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) GetLatestValues(ctx interface{}, keys interface{}, activeDBClient interface{}) *ProtectedKVStore_GetLatestValues_Call {
+	return &ProtectedKVStore_GetLatestValues_Call{Call: _e.mock.On("GetLatestValues", ctx, keys, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_GetLatestValues_Call) Run(run func(ctx context.Context, keys []string, activeDBClient db.Database)) *ProtectedKVStore_GetLatestValues_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 []string
+		if args[1] != nil {
+			arg1 = args[1].([]string)
+		}
+		var arg2 db.Database
+		if args[2] != nil {
+			arg2 = args[2].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_GetLatestValues_Call) Return(stringValueResultMap map[string]store.ValueResult, err error) *ProtectedKVStore_GetLatestValues_Call {
+	_c.Call.Return(stringValueResultMap, err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_GetLatestValues_Call) RunAndReturn(run func(ctx context.Context, keys []string, activeDBClient db.Database) (map[string]store.ValueResult, error)) *ProtectedKVStore_GetLatestValues_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetValueOfKeyAtIndex provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) GetValueOfKeyAtIndex(ctx context.Context, key string, index int, activeDBClient db.Database) ([]byte, models.RecordVersion, error) {
+	ret := _mock.Called(ctx, key, index, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetValueOfKeyAtIndex")
+	}
+
+	var r0 []byte
+	var r1 models.RecordVersion
+	var r2 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, int, db.Database) ([]byte, models.RecordVersion, error)); ok {
+		return returnFunc(ctx, key, index, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, int, db.Database) []byte); ok {
+		r0 = returnFunc(ctx, key, index, activeDBClient)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, int, db.Database) models.RecordVersion); ok {
+		r1 = returnFunc(ctx, key, index, activeDBClient)
+	} else {
+		r1 = ret.Get(1).(models.RecordVersion)
+	}
+	if returnFunc, ok := ret.Get(2).(func(context.Context, string, int, db.Database) error); ok {
+		r2 = returnFunc(ctx, key, index, activeDBClient)
+	} else {
+		r2 = ret.Error(2)
+	}
+	return r0, r1, r2
+}
+
+// ProtectedKVStore_GetValueOfKeyAtIndex_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetValueOfKeyAtIndex'
+type ProtectedKVStore_GetValueOfKeyAtIndex_Call struct {
+	*mock.Call
+}
+
+// GetValueOfKeyAtIndex is a helper method to define mock.On call
+//   - ctx context.Context
+//   - key string
+//   - index int
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) GetValueOfKeyAtIndex(ctx interface{}, key interface{}, index interface{}, activeDBClient interface{}) *ProtectedKVStore_GetValueOfKeyAtIndex_Call {
+	return &ProtectedKVStore_GetValueOfKeyAtIndex_Call{Call: _e.mock.On("GetValueOfKeyAtIndex", ctx, key, index, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_GetValueOfKeyAtIndex_Call) Run(run func(ctx context.Context, key string, index int, activeDBClient db.Database)) *ProtectedKVStore_GetValueOfKeyAtIndex_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 int
+		if args[2] != nil {
+			arg2 = args[2].(int)
+		}
+		var arg3 db.Database
+		if args[3] != nil {
+			arg3 = args[3].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_GetValueOfKeyAtIndex_Call) Return(bytes []byte, recordVersion models.RecordVersion, err error) *ProtectedKVStore_GetValueOfKeyAtIndex_Call {
+	_c.Call.Return(bytes, recordVersion, err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_GetValueOfKeyAtIndex_Call) RunAndReturn(run func(ctx context.Context, key string, index int, activeDBClient db.Database) ([]byte, models.RecordVersion, error)) *ProtectedKVStore_GetValueOfKeyAtIndex_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetValueOfKeyAtTimestamp provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) GetValueOfKeyAtTimestamp(ctx context.Context, key string, at time.Time, activeDBClient db.Database) ([]byte, models.RecordVersion, error) {
+	ret := _mock.Called(ctx, key, at, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetValueOfKeyAtTimestamp")
+	}
+
+	var r0 []byte
+	var r1 models.RecordVersion
+	var r2 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, time.Time, db.Database) ([]byte, models.RecordVersion, error)); ok {
+		return returnFunc(ctx, key, at, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, time.Time, db.Database) []byte); ok {
+		r0 = returnFunc(ctx, key, at, activeDBClient)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, time.Time, db.Database) models.RecordVersion); ok {
+		r1 = returnFunc(ctx, key, at, activeDBClient)
+	} else {
+		r1 = ret.Get(1).(models.RecordVersion)
+	}
+	if returnFunc, ok := ret.Get(2).(func(context.Context, string, time.Time, db.Database) error); ok {
+		r2 = returnFunc(ctx, key, at, activeDBClient)
+	} else {
+		r2 = ret.Error(2)
+	}
+	return r0, r1, r2
+}
+
+// ProtectedKVStore_GetValueOfKeyAtTimestamp_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetValueOfKeyAtTimestamp'
+type ProtectedKVStore_GetValueOfKeyAtTimestamp_Call struct {
+	*mock.Call
+}
+
+// GetValueOfKeyAtTimestamp is a helper method to define mock.On call
+//   - ctx context.Context
+//   - key string
+//   - at time.Time
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) GetValueOfKeyAtTimestamp(ctx interface{}, key interface{}, at interface{}, activeDBClient interface{}) *ProtectedKVStore_GetValueOfKeyAtTimestamp_Call {
+	return &ProtectedKVStore_GetValueOfKeyAtTimestamp_Call{Call: _e.mock.On("GetValueOfKeyAtTimestamp", ctx, key, at, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_GetValueOfKeyAtTimestamp_Call) Run(run func(ctx context.Context, key string, at time.Time, activeDBClient db.Database)) *ProtectedKVStore_GetValueOfKeyAtTimestamp_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 time.Time
+		if args[2] != nil {
+			arg2 = args[2].(time.Time)
+		}
+		var arg3 db.Database
+		if args[3] != nil {
+			arg3 = args[3].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_GetValueOfKeyAtTimestamp_Call) Return(bytes []byte, recordVersion models.RecordVersion, err error) *ProtectedKVStore_GetValueOfKeyAtTimestamp_Call {
+	_c.Call.Return(bytes, recordVersion, err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_GetValueOfKeyAtTimestamp_Call) RunAndReturn(run func(ctx context.Context, key string, at time.Time, activeDBClient db.Database) ([]byte, models.RecordVersion, error)) *ProtectedKVStore_GetValueOfKeyAtTimestamp_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetValueOfKeyAtVersion provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) GetValueOfKeyAtVersion(ctx context.Context, versionEntry models.RecordVersion, activeDBClient db.Database) ([]byte, error) {
+	ret := _mock.Called(ctx, versionEntry, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetValueOfKeyAtVersion")
+	}
+
+	var r0 []byte
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, models.RecordVersion, db.Database) ([]byte, error)); ok {
+		return returnFunc(ctx, versionEntry, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, models.RecordVersion, db.Database) []byte); ok {
+		r0 = returnFunc(ctx, versionEntry, activeDBClient)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, models.RecordVersion, db.Database) error); ok {
+		r1 = returnFunc(ctx, versionEntry, activeDBClient)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// ProtectedKVStore_GetValueOfKeyAtVersion_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetValueOfKeyAtVersion'
+type ProtectedKVStore_GetValueOfKeyAtVersion_Call struct {
+	*mock.Call
+}
+
+// GetValueOfKeyAtVersion is a helper method to define mock.On call
+//   - ctx context.Context
+//   - versionEntry models.RecordVersion
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) GetValueOfKeyAtVersion(ctx interface{}, versionEntry interface{}, activeDBClient interface{}) *ProtectedKVStore_GetValueOfKeyAtVersion_Call {
+	return &ProtectedKVStore_GetValueOfKeyAtVersion_Call{Call: _e.mock.On("GetValueOfKeyAtVersion", ctx, versionEntry, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_GetValueOfKeyAtVersion_Call) Run(run func(ctx context.Context, versionEntry models.RecordVersion, activeDBClient db.Database)) *ProtectedKVStore_GetValueOfKeyAtVersion_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 models.RecordVersion
+		if args[1] != nil {
+			arg1 = args[1].(models.RecordVersion)
+		}
+		var arg2 db.Database
+		if args[2] != nil {
+			arg2 = args[2].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_GetValueOfKeyAtVersion_Call) Return(bytes []byte, err error) *ProtectedKVStore_GetValueOfKeyAtVersion_Call {
+	_c.Call.Return(bytes, err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_GetValueOfKeyAtVersion_Call) RunAndReturn(run func(ctx context.Context, versionEntry models.RecordVersion, activeDBClient db.Database) ([]byte, error)) *ProtectedKVStore_GetValueOfKeyAtVersion_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetValueOfKeyAtVersionID provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) GetValueOfKeyAtVersionID(ctx context.Context, versionID string, activeDBClient db.Database) ([]byte, error) {
+	ret := _mock.Called(ctx, versionID, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetValueOfKeyAtVersionID")
+	}
+
+	var r0 []byte
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, db.Database) ([]byte, error)); ok {
+		return returnFunc(ctx, versionID, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, db.Database) []byte); ok {
+		r0 = returnFunc(ctx, versionID, activeDBClient)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, db.Database) error); ok {
+		r1 = returnFunc(ctx, versionID, activeDBClient)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// ProtectedKVStore_GetValueOfKeyAtVersionID_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetValueOfKeyAtVersionID'
+type ProtectedKVStore_GetValueOfKeyAtVersionID_Call struct {
+	*mock.Call
+}
+
+// GetValueOfKeyAtVersionID is a helper method to define mock.On call
+//   - ctx context.Context
+//   - versionID string
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) GetValueOfKeyAtVersionID(ctx interface{}, versionID interface{}, activeDBClient interface{}) *ProtectedKVStore_GetValueOfKeyAtVersionID_Call {
+	return &ProtectedKVStore_GetValueOfKeyAtVersionID_Call{Call: _e.mock.On("GetValueOfKeyAtVersionID", ctx, versionID, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_GetValueOfKeyAtVersionID_Call) Run(run func(ctx context.Context, versionID string, activeDBClient db.Database)) *ProtectedKVStore_GetValueOfKeyAtVersionID_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 db.Database
+		if args[2] != nil {
+			arg2 = args[2].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_GetValueOfKeyAtVersionID_Call) Return(bytes []byte, err error) *ProtectedKVStore_GetValueOfKeyAtVersionID_Call {
+	_c.Call.Return(bytes, err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_GetValueOfKeyAtVersionID_Call) RunAndReturn(run func(ctx context.Context, versionID string, activeDBClient db.Database) ([]byte, error)) *ProtectedKVStore_GetValueOfKeyAtVersionID_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// HealthCheck provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) HealthCheck(ctx context.Context) error {
+	ret := _mock.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for HealthCheck")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = returnFunc(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// ProtectedKVStore_HealthCheck_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'HealthCheck'
+type ProtectedKVStore_HealthCheck_Call struct {
+	*mock.Call
+}
+
+// HealthCheck is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *ProtectedKVStore_Expecter) HealthCheck(ctx interface{}) *ProtectedKVStore_HealthCheck_Call {
+	return &ProtectedKVStore_HealthCheck_Call{Call: _e.mock.On("HealthCheck", ctx)}
+}
+
+func (_c *ProtectedKVStore_HealthCheck_Call) Run(run func(ctx context.Context)) *ProtectedKVStore_HealthCheck_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_HealthCheck_Call) Return(err error) *ProtectedKVStore_HealthCheck_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_HealthCheck_Call) RunAndReturn(run func(ctx context.Context) error) *ProtectedKVStore_HealthCheck_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ImportEncrypted provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) ImportEncrypted(ctx context.Context, r io.Reader, activeDBClient db.Database) error {
+	ret := _mock.Called(ctx, r, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ImportEncrypted")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, io.Reader, db.Database) error); ok {
+		r0 = returnFunc(ctx, r, activeDBClient)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// ProtectedKVStore_ImportEncrypted_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ImportEncrypted'
+type ProtectedKVStore_ImportEncrypted_Call struct {
+	*mock.Call
+}
+
+// ImportEncrypted is a helper method to define mock.On call
+//   - ctx context.Context
+//   - r io.Reader
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) ImportEncrypted(ctx interface{}, r interface{}, activeDBClient interface{}) *ProtectedKVStore_ImportEncrypted_Call {
+	return &ProtectedKVStore_ImportEncrypted_Call{Call: _e.mock.On("ImportEncrypted", ctx, r, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_ImportEncrypted_Call) Run(run func(ctx context.Context, r io.Reader, activeDBClient db.Database)) *ProtectedKVStore_ImportEncrypted_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 io.Reader
+		if args[1] != nil {
+			arg1 = args[1].(io.Reader)
+		}
+		var arg2 db.Database
+		if args[2] != nil {
+			arg2 = args[2].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_ImportEncrypted_Call) Return(err error) *ProtectedKVStore_ImportEncrypted_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_ImportEncrypted_Call) RunAndReturn(run func(ctx context.Context, r io.Reader, activeDBClient db.Database) error) *ProtectedKVStore_ImportEncrypted_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ImportPlaintext provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) ImportPlaintext(ctx context.Context, r io.Reader, activeDBClient db.Database) (int, error) {
+	ret := _mock.Called(ctx, r, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ImportPlaintext")
+	}
+
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, io.Reader, db.Database) (int, error)); ok {
+		return returnFunc(ctx, r, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, io.Reader, db.Database) int); ok {
+		r0 = returnFunc(ctx, r, activeDBClient)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, io.Reader, db.Database) error); ok {
+		r1 = returnFunc(ctx, r, activeDBClient)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// ProtectedKVStore_ImportPlaintext_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ImportPlaintext'
+type ProtectedKVStore_ImportPlaintext_Call struct {
+	*mock.Call
+}
+
+// ImportPlaintext is a helper method to define mock.On call
+//   - ctx context.Context
+//   - r io.Reader
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) ImportPlaintext(ctx interface{}, r interface{}, activeDBClient interface{}) *ProtectedKVStore_ImportPlaintext_Call {
+	return &ProtectedKVStore_ImportPlaintext_Call{Call: _e.mock.On("ImportPlaintext", ctx, r, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_ImportPlaintext_Call) Run(run func(ctx context.Context, r io.Reader, activeDBClient db.Database)) *ProtectedKVStore_ImportPlaintext_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 io.Reader
+		if args[1] != nil {
+			arg1 = args[1].(io.Reader)
+		}
+		var arg2 db.Database
+		if args[2] != nil {
+			arg2 = args[2].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_ImportPlaintext_Call) Return(int int, err error) *ProtectedKVStore_ImportPlaintext_Call {
+	_c.Call.Return(int, err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_ImportPlaintext_Call) RunAndReturn(run func(ctx context.Context, r io.Reader, activeDBClient db.Database) (int, error)) *ProtectedKVStore_ImportPlaintext_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListKeyVersions provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) ListKeyVersions(ctx context.Context, key string, activeDBClient db.Database) (models.Record, []models.RecordVersion, error) {
+	ret := _mock.Called(ctx, key, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListKeyVersions")
+	}
+
+	var r0 models.Record
+	var r1 []models.RecordVersion
+	var r2 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, db.Database) (models.Record, []models.RecordVersion, error)); ok {
+		return returnFunc(ctx, key, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, db.Database) models.Record); ok {
+		r0 = returnFunc(ctx, key, activeDBClient)
+	} else {
+		r0 = ret.Get(0).(models.Record)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, db.Database) []models.RecordVersion); ok {
+		r1 = returnFunc(ctx, key, activeDBClient)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]models.RecordVersion)
+		}
+	}
+	if returnFunc, ok := ret.Get(2).(func(context.Context, string, db.Database) error); ok {
+		r2 = returnFunc(ctx, key, activeDBClient)
+	} else {
+		r2 = ret.Error(2)
+	}
+	return r0, r1, r2
+}
+
+// ProtectedKVStore_ListKeyVersions_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListKeyVersions'
+type ProtectedKVStore_ListKeyVersions_Call struct {
+	*mock.Call
+}
+
+// ListKeyVersions is a helper method to define mock.On call
+//   - ctx context.Context
+//   - key string
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) ListKeyVersions(ctx interface{}, key interface{}, activeDBClient interface{}) *ProtectedKVStore_ListKeyVersions_Call {
+	return &ProtectedKVStore_ListKeyVersions_Call{Call: _e.mock.On("ListKeyVersions", ctx, key, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_ListKeyVersions_Call) Run(run func(ctx context.Context, key string, activeDBClient db.Database)) *ProtectedKVStore_ListKeyVersions_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 db.Database
+		if args[2] != nil {
+			arg2 = args[2].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_ListKeyVersions_Call) Return(record models.Record, recordVersions []models.RecordVersion, err error) *ProtectedKVStore_ListKeyVersions_Call {
+	_c.Call.Return(record, recordVersions, err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_ListKeyVersions_Call) RunAndReturn(run func(ctx context.Context, key string, activeDBClient db.Database) (models.Record, []models.RecordVersion, error)) *ProtectedKVStore_ListKeyVersions_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListKeys provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) ListKeys(ctx context.Context, keyPrefix string, activeDBClient db.Database) ([]models.Record, error) {
+	ret := _mock.Called(ctx, keyPrefix, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListKeys")
+	}
+
+	var r0 []models.Record
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, db.Database) ([]models.Record, error)); ok {
+		return returnFunc(ctx, keyPrefix, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, db.Database) []models.Record); ok {
+		r0 = returnFunc(ctx, keyPrefix, activeDBClient)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Record)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, db.Database) error); ok {
+		r1 = returnFunc(ctx, keyPrefix, activeDBClient)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// ProtectedKVStore_ListKeys_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListKeys'
+type ProtectedKVStore_ListKeys_Call struct {
+	*mock.Call
+}
+
+// ListKeys is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyPrefix string
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) ListKeys(ctx interface{}, keyPrefix interface{}, activeDBClient interface{}) *ProtectedKVStore_ListKeys_Call {
+	return &ProtectedKVStore_ListKeys_Call{Call: _e.mock.On("ListKeys", ctx, keyPrefix, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_ListKeys_Call) Run(run func(ctx context.Context, keyPrefix string, activeDBClient db.Database)) *ProtectedKVStore_ListKeys_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 db.Database
+		if args[2] != nil {
+			arg2 = args[2].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_ListKeys_Call) Return(records []models.Record, err error) *ProtectedKVStore_ListKeys_Call {
+	_c.Call.Return(records, err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_ListKeys_Call) RunAndReturn(run func(ctx context.Context, keyPrefix string, activeDBClient db.Database) ([]models.Record, error)) *ProtectedKVStore_ListKeys_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListKeysWithMetadata provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) ListKeysWithMetadata(ctx context.Context, filter db.RecordQueryFilter, activeDBClient db.Database) ([]store.KeyInfo, error) {
+	ret := _mock.Called(ctx, filter, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListKeysWithMetadata")
+	}
+
+	var r0 []store.KeyInfo
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.RecordQueryFilter, db.Database) ([]store.KeyInfo, error)); ok {
+		return returnFunc(ctx, filter, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.RecordQueryFilter, db.Database) []store.KeyInfo); ok {
+		r0 = returnFunc(ctx, filter, activeDBClient)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]store.KeyInfo)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, db.RecordQueryFilter, db.Database) error); ok {
+		r1 = returnFunc(ctx, filter, activeDBClient)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// ProtectedKVStore_ListKeysWithMetadata_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListKeysWithMetadata'
+type ProtectedKVStore_ListKeysWithMetadata_Call struct {
+	*mock.Call
+}
+
+// ListKeysWithMetadata is a helper method to define mock.On call
+//   - ctx context.Context
+//   - filter db.RecordQueryFilter
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) ListKeysWithMetadata(ctx interface{}, filter interface{}, activeDBClient interface{}) *ProtectedKVStore_ListKeysWithMetadata_Call {
+	return &ProtectedKVStore_ListKeysWithMetadata_Call{Call: _e.mock.On("ListKeysWithMetadata", ctx, filter, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_ListKeysWithMetadata_Call) Run(run func(ctx context.Context, filter db.RecordQueryFilter, activeDBClient db.Database)) *ProtectedKVStore_ListKeysWithMetadata_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 db.RecordQueryFilter
+		if args[1] != nil {
+			arg1 = args[1].(db.RecordQueryFilter)
+		}
+		var arg2 db.Database
+		if args[2] != nil {
+			arg2 = args[2].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_ListKeysWithMetadata_Call) Return(keyInfos []store.KeyInfo, err error) *ProtectedKVStore_ListKeysWithMetadata_Call {
+	_c.Call.Return(keyInfos, err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_ListKeysWithMetadata_Call) RunAndReturn(run func(ctx context.Context, filter db.RecordQueryFilter, activeDBClient db.Database) ([]store.KeyInfo, error)) *ProtectedKVStore_ListKeysWithMetadata_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// PruneRecordVersions provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) PruneRecordVersions(ctx context.Context, record models.Record, keepLatest int, activeDBClient db.Database) (int, error) {
+	ret := _mock.Called(ctx, record, keepLatest, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PruneRecordVersions")
+	}
+
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, models.Record, int, db.Database) (int, error)); ok {
+		return returnFunc(ctx, record, keepLatest, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, models.Record, int, db.Database) int); ok {
+		r0 = returnFunc(ctx, record, keepLatest, activeDBClient)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, models.Record, int, db.Database) error); ok {
+		r1 = returnFunc(ctx, record, keepLatest, activeDBClient)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// ProtectedKVStore_PruneRecordVersions_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'PruneRecordVersions'
+type ProtectedKVStore_PruneRecordVersions_Call struct {
+	*mock.Call
+}
+
+// PruneRecordVersions is a helper method to define mock.On call
+//   - ctx context.Context
+//   - record models.Record
+//   - keepLatest int
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) PruneRecordVersions(ctx interface{}, record interface{}, keepLatest interface{}, activeDBClient interface{}) *ProtectedKVStore_PruneRecordVersions_Call {
+	return &ProtectedKVStore_PruneRecordVersions_Call{Call: _e.mock.On("PruneRecordVersions", ctx, record, keepLatest, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_PruneRecordVersions_Call) Run(run func(ctx context.Context, record models.Record, keepLatest int, activeDBClient db.Database)) *ProtectedKVStore_PruneRecordVersions_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 models.Record
+		if args[1] != nil {
+			arg1 = args[1].(models.Record)
+		}
+		var arg2 int
+		if args[2] != nil {
+			arg2 = args[2].(int)
+		}
+		var arg3 db.Database
+		if args[3] != nil {
+			arg3 = args[3].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_PruneRecordVersions_Call) Return(int int, err error) *ProtectedKVStore_PruneRecordVersions_Call {
+	_c.Call.Return(int, err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_PruneRecordVersions_Call) RunAndReturn(run func(ctx context.Context, record models.Record, keepLatest int, activeDBClient db.Database) (int, error)) *ProtectedKVStore_PruneRecordVersions_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RecordKeyObject provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) RecordKeyObject(ctx context.Context, key string, value any, timestamp time.Time, activeDBClient db.Database) (models.Record, models.RecordVersion, error) {
+	ret := _mock.Called(ctx, key, value, timestamp, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RecordKeyObject")
+	}
+
+	var r0 models.Record
+	var r1 models.RecordVersion
+	var r2 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, any, time.Time, db.Database) (models.Record, models.RecordVersion, error)); ok {
+		return returnFunc(ctx, key, value, timestamp, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, any, time.Time, db.Database) models.Record); ok {
+		r0 = returnFunc(ctx, key, value, timestamp, activeDBClient)
+	} else {
+		r0 = ret.Get(0).(models.Record)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, any, time.Time, db.Database) models.RecordVersion); ok {
+		r1 = returnFunc(ctx, key, value, timestamp, activeDBClient)
+	} else {
+		r1 = ret.Get(1).(models.RecordVersion)
+	}
+	if returnFunc, ok := ret.Get(2).(func(context.Context, string, any, time.Time, db.Database) error); ok {
+		r2 = returnFunc(ctx, key, value, timestamp, activeDBClient)
+	} else {
+		r2 = ret.Error(2)
+	}
+	return r0, r1, r2
+}
+
+// ProtectedKVStore_RecordKeyObject_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RecordKeyObject'
+type ProtectedKVStore_RecordKeyObject_Call struct {
+	*mock.Call
+}
+
+// RecordKeyObject is a helper method to define mock.On call
+//   - ctx context.Context
+//   - key string
+//   - value any
+//   - timestamp time.Time
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) RecordKeyObject(ctx interface{}, key interface{}, value interface{}, timestamp interface{}, activeDBClient interface{}) *ProtectedKVStore_RecordKeyObject_Call {
+	return &ProtectedKVStore_RecordKeyObject_Call{Call: _e.mock.On("RecordKeyObject", ctx, key, value, timestamp, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_RecordKeyObject_Call) Run(run func(ctx context.Context, key string, value any, timestamp time.Time, activeDBClient db.Database)) *ProtectedKVStore_RecordKeyObject_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 any
+		if args[2] != nil {
+			arg2 = args[2].(any)
+		}
+		var arg3 time.Time
+		if args[3] != nil {
+			arg3 = args[3].(time.Time)
+		}
+		var arg4 db.Database
+		if args[4] != nil {
+			arg4 = args[4].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+			arg4,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_RecordKeyObject_Call) Return(record models.Record, recordVersion models.RecordVersion, err error) *ProtectedKVStore_RecordKeyObject_Call {
+	_c.Call.Return(record, recordVersion, err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_RecordKeyObject_Call) RunAndReturn(run func(ctx context.Context, key string, value any, timestamp time.Time, activeDBClient db.Database) (models.Record, models.RecordVersion, error)) *ProtectedKVStore_RecordKeyObject_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RecordKeyValue provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) RecordKeyValue(ctx context.Context, key string, value []byte, timestamp time.Time, activeDBClient db.Database) (models.Record, models.RecordVersion, error) {
+	ret := _mock.Called(ctx, key, value, timestamp, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RecordKeyValue")
+	}
+
+	var r0 models.Record
+	var r1 models.RecordVersion
+	var r2 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []byte, time.Time, db.Database) (models.Record, models.RecordVersion, error)); ok {
+		return returnFunc(ctx, key, value, timestamp, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []byte, time.Time, db.Database) models.Record); ok {
+		r0 = returnFunc(ctx, key, value, timestamp, activeDBClient)
+	} else {
+		r0 = ret.Get(0).(models.Record)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, []byte, time.Time, db.Database) models.RecordVersion); ok {
+		r1 = returnFunc(ctx, key, value, timestamp, activeDBClient)
+	} else {
+		r1 = ret.Get(1).(models.RecordVersion)
+	}
+	if returnFunc, ok := ret.Get(2).(func(context.Context, string, []byte, time.Time, db.Database) error); ok {
+		r2 = returnFunc(ctx, key, value, timestamp, activeDBClient)
+	} else {
+		r2 = ret.Error(2)
+	}
+	return r0, r1, r2
+}
+
+// ProtectedKVStore_RecordKeyValue_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RecordKeyValue'
+type ProtectedKVStore_RecordKeyValue_Call struct {
+	*mock.Call
+}
+
+// RecordKeyValue is a helper method to define mock.On call
+//   - ctx context.Context
+//   - key string
+//   - value []byte
+//   - timestamp time.Time
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) RecordKeyValue(ctx interface{}, key interface{}, value interface{}, timestamp interface{}, activeDBClient interface{}) *ProtectedKVStore_RecordKeyValue_Call {
+	return &ProtectedKVStore_RecordKeyValue_Call{Call: _e.mock.On("RecordKeyValue", ctx, key, value, timestamp, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_RecordKeyValue_Call) Run(run func(ctx context.Context, key string, value []byte, timestamp time.Time, activeDBClient db.Database)) *ProtectedKVStore_RecordKeyValue_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 []byte
+		if args[2] != nil {
+			arg2 = args[2].([]byte)
+		}
+		var arg3 time.Time
+		if args[3] != nil {
+			arg3 = args[3].(time.Time)
+		}
+		var arg4 db.Database
+		if args[4] != nil {
+			arg4 = args[4].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+			arg4,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_RecordKeyValue_Call) Return(record models.Record, recordVersion models.RecordVersion, err error) *ProtectedKVStore_RecordKeyValue_Call {
+	_c.Call.Return(record, recordVersion, err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_RecordKeyValue_Call) RunAndReturn(run func(ctx context.Context, key string, value []byte, timestamp time.Time, activeDBClient db.Database) (models.Record, models.RecordVersion, error)) *ProtectedKVStore_RecordKeyValue_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RecordKeyValueWithNote provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) RecordKeyValueWithNote(ctx context.Context, key string, value []byte, timestamp time.Time, note string, activeDBClient db.Database) (models.Record, models.RecordVersion, error) {
+	ret := _mock.Called(ctx, key, value, timestamp, note, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RecordKeyValueWithNote")
+	}
+
+	var r0 models.Record
+	var r1 models.RecordVersion
+	var r2 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []byte, time.Time, string, db.Database) (models.Record, models.RecordVersion, error)); ok {
+		return returnFunc(ctx, key, value, timestamp, note, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []byte, time.Time, string, db.Database) models.Record); ok {
+		r0 = returnFunc(ctx, key, value, timestamp, note, activeDBClient)
+	} else {
+		r0 = ret.Get(0).(models.Record)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, []byte, time.Time, string, db.Database) models.RecordVersion); ok {
+		r1 = returnFunc(ctx, key, value, timestamp, note, activeDBClient)
+	} else {
+		r1 = ret.Get(1).(models.RecordVersion)
+	}
+	if returnFunc, ok := ret.Get(2).(func(context.Context, string, []byte, time.Time, string, db.Database) error); ok {
+		r2 = returnFunc(ctx, key, value, timestamp, note, activeDBClient)
+	} else {
+		r2 = ret.Error(2)
+	}
+	return r0, r1, r2
+}
+
+// ProtectedKVStore_RecordKeyValueWithNote_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RecordKeyValueWithNote'
+type ProtectedKVStore_RecordKeyValueWithNote_Call struct {
+	*mock.Call
+}
+
+// RecordKeyValueWithNote is a helper method to define mock.On call
+//   - ctx context.Context
+//   - key string
+//   - value []byte
+//   - timestamp time.Time
+//   - note string
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) RecordKeyValueWithNote(ctx interface{}, key interface{}, value interface{}, timestamp interface{}, note interface{}, activeDBClient interface{}) *ProtectedKVStore_RecordKeyValueWithNote_Call {
+	return &ProtectedKVStore_RecordKeyValueWithNote_Call{Call: _e.mock.On("RecordKeyValueWithNote", ctx, key, value, timestamp, note, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_RecordKeyValueWithNote_Call) Run(run func(ctx context.Context, key string, value []byte, timestamp time.Time, note string, activeDBClient db.Database)) *ProtectedKVStore_RecordKeyValueWithNote_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 []byte
+		if args[2] != nil {
+			arg2 = args[2].([]byte)
+		}
+		var arg3 time.Time
+		if args[3] != nil {
+			arg3 = args[3].(time.Time)
+		}
+		var arg4 string
+		if args[4] != nil {
+			arg4 = args[4].(string)
+		}
+		var arg5 db.Database
+		if args[5] != nil {
+			arg5 = args[5].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+			arg4,
+			arg5,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_RecordKeyValueWithNote_Call) Return(record models.Record, recordVersion models.RecordVersion, err error) *ProtectedKVStore_RecordKeyValueWithNote_Call {
+	_c.Call.Return(record, recordVersion, err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_RecordKeyValueWithNote_Call) RunAndReturn(run func(ctx context.Context, key string, value []byte, timestamp time.Time, note string, activeDBClient db.Database) (models.Record, models.RecordVersion, error)) *ProtectedKVStore_RecordKeyValueWithNote_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RecordKeyValueWithTTL provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) RecordKeyValueWithTTL(ctx context.Context, key string, value []byte, timestamp time.Time, ttl time.Duration, activeDBClient db.Database) (models.Record, models.RecordVersion, error) {
+	ret := _mock.Called(ctx, key, value, timestamp, ttl, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RecordKeyValueWithTTL")
+	}
+
+	var r0 models.Record
+	var r1 models.RecordVersion
+	var r2 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []byte, time.Time, time.Duration, db.Database) (models.Record, models.RecordVersion, error)); ok {
+		return returnFunc(ctx, key, value, timestamp, ttl, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []byte, time.Time, time.Duration, db.Database) models.Record); ok {
+		r0 = returnFunc(ctx, key, value, timestamp, ttl, activeDBClient)
+	} else {
+		r0 = ret.Get(0).(models.Record)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, []byte, time.Time, time.Duration, db.Database) models.RecordVersion); ok {
+		r1 = returnFunc(ctx, key, value, timestamp, ttl, activeDBClient)
+	} else {
+		r1 = ret.Get(1).(models.RecordVersion)
+	}
+	if returnFunc, ok := ret.Get(2).(func(context.Context, string, []byte, time.Time, time.Duration, db.Database) error); ok {
+		r2 = returnFunc(ctx, key, value, timestamp, ttl, activeDBClient)
+	} else {
+		r2 = ret.Error(2)
+	}
+	return r0, r1, r2
+}
+
+// ProtectedKVStore_RecordKeyValueWithTTL_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RecordKeyValueWithTTL'
+type ProtectedKVStore_RecordKeyValueWithTTL_Call struct {
+	*mock.Call
+}
+
+// RecordKeyValueWithTTL is a helper method to define mock.On call
+//   - ctx context.Context
+//   - key string
+//   - value []byte
+//   - timestamp time.Time
+//   - ttl time.Duration
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) RecordKeyValueWithTTL(ctx interface{}, key interface{}, value interface{}, timestamp interface{}, ttl interface{}, activeDBClient interface{}) *ProtectedKVStore_RecordKeyValueWithTTL_Call {
+	return &ProtectedKVStore_RecordKeyValueWithTTL_Call{Call: _e.mock.On("RecordKeyValueWithTTL", ctx, key, value, timestamp, ttl, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_RecordKeyValueWithTTL_Call) Run(run func(ctx context.Context, key string, value []byte, timestamp time.Time, ttl time.Duration, activeDBClient db.Database)) *ProtectedKVStore_RecordKeyValueWithTTL_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 []byte
+		if args[2] != nil {
+			arg2 = args[2].([]byte)
+		}
+		var arg3 time.Time
+		if args[3] != nil {
+			arg3 = args[3].(time.Time)
+		}
+		var arg4 time.Duration
+		if args[4] != nil {
+			arg4 = args[4].(time.Duration)
+		}
+		var arg5 db.Database
+		if args[5] != nil {
+			arg5 = args[5].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+			arg4,
+			arg5,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_RecordKeyValueWithTTL_Call) Return(record models.Record, recordVersion models.RecordVersion, err error) *ProtectedKVStore_RecordKeyValueWithTTL_Call {
+	_c.Call.Return(record, recordVersion, err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_RecordKeyValueWithTTL_Call) RunAndReturn(run func(ctx context.Context, key string, value []byte, timestamp time.Time, ttl time.Duration, activeDBClient db.Database) (models.Record, models.RecordVersion, error)) *ProtectedKVStore_RecordKeyValueWithTTL_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ReencryptRecord provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) ReencryptRecord(ctx context.Context, key string, targetKeyID string, activeDBClient db.Database) (int, error) {
+	ret := _mock.Called(ctx, key, targetKeyID, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReencryptRecord")
+	}
+
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string, db.Database) (int, error)); ok {
+		return returnFunc(ctx, key, targetKeyID, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string, db.Database) int); ok {
+		r0 = returnFunc(ctx, key, targetKeyID, activeDBClient)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, string, db.Database) error); ok {
+		r1 = returnFunc(ctx, key, targetKeyID, activeDBClient)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// ProtectedKVStore_ReencryptRecord_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ReencryptRecord'
+type ProtectedKVStore_ReencryptRecord_Call struct {
+	*mock.Call
+}
+
+// ReencryptRecord is a helper method to define mock.On call
+//   - ctx context.Context
+//   - key string
+//   - targetKeyID string
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) ReencryptRecord(ctx interface{}, key interface{}, targetKeyID interface{}, activeDBClient interface{}) *ProtectedKVStore_ReencryptRecord_Call {
+	return &ProtectedKVStore_ReencryptRecord_Call{Call: _e.mock.On("ReencryptRecord", ctx, key, targetKeyID, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_ReencryptRecord_Call) Run(run func(ctx context.Context, key string, targetKeyID string, activeDBClient db.Database)) *ProtectedKVStore_ReencryptRecord_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		var arg3 db.Database
+		if args[3] != nil {
+			arg3 = args[3].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_ReencryptRecord_Call) Return(int int, err error) *ProtectedKVStore_ReencryptRecord_Call {
+	_c.Call.Return(int, err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_ReencryptRecord_Call) RunAndReturn(run func(ctx context.Context, key string, targetKeyID string, activeDBClient db.Database) (int, error)) *ProtectedKVStore_ReencryptRecord_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RefreshWorkingKey provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) RefreshWorkingKey(ctx context.Context, activeDBClient db.Database) error {
+	ret := _mock.Called(ctx, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RefreshWorkingKey")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.Database) error); ok {
+		r0 = returnFunc(ctx, activeDBClient)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// ProtectedKVStore_RefreshWorkingKey_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RefreshWorkingKey'
+type ProtectedKVStore_RefreshWorkingKey_Call struct {
+	*mock.Call
+}
+
+// RefreshWorkingKey is a helper method to define mock.On call
+//   - ctx context.Context
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) RefreshWorkingKey(ctx interface{}, activeDBClient interface{}) *ProtectedKVStore_RefreshWorkingKey_Call {
+	return &ProtectedKVStore_RefreshWorkingKey_Call{Call: _e.mock.On("RefreshWorkingKey", ctx, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_RefreshWorkingKey_Call) Run(run func(ctx context.Context, activeDBClient db.Database)) *ProtectedKVStore_RefreshWorkingKey_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 db.Database
+		if args[1] != nil {
+			arg1 = args[1].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_RefreshWorkingKey_Call) Return(err error) *ProtectedKVStore_RefreshWorkingKey_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_RefreshWorkingKey_Call) RunAndReturn(run func(ctx context.Context, activeDBClient db.Database) error) *ProtectedKVStore_RefreshWorkingKey_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RekeyRecord provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) RekeyRecord(ctx context.Context, key string, activeDBClient db.Database) (int, error) {
+	ret := _mock.Called(ctx, key, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RekeyRecord")
+	}
+
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, db.Database) (int, error)); ok {
+		return returnFunc(ctx, key, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, db.Database) int); ok {
+		r0 = returnFunc(ctx, key, activeDBClient)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, db.Database) error); ok {
+		r1 = returnFunc(ctx, key, activeDBClient)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// ProtectedKVStore_RekeyRecord_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RekeyRecord'
+type ProtectedKVStore_RekeyRecord_Call struct {
+	*mock.Call
+}
+
+// RekeyRecord is a helper method to define mock.On call
+//   - ctx context.Context
+//   - key string
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) RekeyRecord(ctx interface{}, key interface{}, activeDBClient interface{}) *ProtectedKVStore_RekeyRecord_Call {
+	return &ProtectedKVStore_RekeyRecord_Call{Call: _e.mock.On("RekeyRecord", ctx, key, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_RekeyRecord_Call) Run(run func(ctx context.Context, key string, activeDBClient db.Database)) *ProtectedKVStore_RekeyRecord_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 db.Database
+		if args[2] != nil {
+			arg2 = args[2].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_RekeyRecord_Call) Return(int int, err error) *ProtectedKVStore_RekeyRecord_Call {
+	_c.Call.Return(int, err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_RekeyRecord_Call) RunAndReturn(run func(ctx context.Context, key string, activeDBClient db.Database) (int, error)) *ProtectedKVStore_RekeyRecord_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RenameKey provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) RenameKey(ctx context.Context, oldKey string, newKey string, activeDBClient db.Database) error {
+	ret := _mock.Called(ctx, oldKey, newKey, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RenameKey")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string, db.Database) error); ok {
+		r0 = returnFunc(ctx, oldKey, newKey, activeDBClient)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// ProtectedKVStore_RenameKey_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RenameKey'
+type ProtectedKVStore_RenameKey_Call struct {
+	*mock.Call
+}
+
+// RenameKey is a helper method to define mock.On call
+//   - ctx context.Context
+//   - oldKey string
+//   - newKey string
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) RenameKey(ctx interface{}, oldKey interface{}, newKey interface{}, activeDBClient interface{}) *ProtectedKVStore_RenameKey_Call {
+	return &ProtectedKVStore_RenameKey_Call{Call: _e.mock.On("RenameKey", ctx, oldKey, newKey, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_RenameKey_Call) Run(run func(ctx context.Context, oldKey string, newKey string, activeDBClient db.Database)) *ProtectedKVStore_RenameKey_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		var arg3 db.Database
+		if args[3] != nil {
+			arg3 = args[3].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_RenameKey_Call) Return(err error) *ProtectedKVStore_RenameKey_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_RenameKey_Call) RunAndReturn(run func(ctx context.Context, oldKey string, newKey string, activeDBClient db.Database) error) *ProtectedKVStore_RenameKey_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ScanAll provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) ScanAll(ctx context.Context, filter db.RecordQueryFilter, activeDBClient db.Database) (<-chan store.ScanItem, func() error, error) {
+	ret := _mock.Called(ctx, filter, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ScanAll")
+	}
+
+	var r0 <-chan store.ScanItem
+	var r1 func() error
+	var r2 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.RecordQueryFilter, db.Database) (<-chan store.ScanItem, func() error, error)); ok {
+		return returnFunc(ctx, filter, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.RecordQueryFilter, db.Database) <-chan store.ScanItem); ok {
+		r0 = returnFunc(ctx, filter, activeDBClient)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan store.ScanItem)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, db.RecordQueryFilter, db.Database) func() error); ok {
+		r1 = returnFunc(ctx, filter, activeDBClient)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(func() error)
+		}
+	}
+	if returnFunc, ok := ret.Get(2).(func(context.Context, db.RecordQueryFilter, db.Database) error); ok {
+		r2 = returnFunc(ctx, filter, activeDBClient)
+	} else {
+		r2 = ret.Error(2)
+	}
+	return r0, r1, r2
+}
+
+// ProtectedKVStore_ScanAll_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ScanAll'
+type ProtectedKVStore_ScanAll_Call struct {
+	*mock.Call
+}
+
+// ScanAll is a helper method to define mock.On call
+//   - ctx context.Context
+//   - filter db.RecordQueryFilter
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) ScanAll(ctx interface{}, filter interface{}, activeDBClient interface{}) *ProtectedKVStore_ScanAll_Call {
+	return &ProtectedKVStore_ScanAll_Call{Call: _e.mock.On("ScanAll", ctx, filter, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_ScanAll_Call) Run(run func(ctx context.Context, filter db.RecordQueryFilter, activeDBClient db.Database)) *ProtectedKVStore_ScanAll_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 db.RecordQueryFilter
+		if args[1] != nil {
+			arg1 = args[1].(db.RecordQueryFilter)
+		}
+		var arg2 db.Database
+		if args[2] != nil {
+			arg2 = args[2].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_ScanAll_Call) Return(scanItemCh <-chan store.ScanItem, v func() error, err error) *ProtectedKVStore_ScanAll_Call {
+	_c.Call.Return(scanItemCh, v, err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_ScanAll_Call) RunAndReturn(run func(ctx context.Context, filter db.RecordQueryFilter, activeDBClient db.Database) (<-chan store.ScanItem, func() error, error)) *ProtectedKVStore_ScanAll_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SystemState provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) SystemState(ctx context.Context, activeDBClient db.Database) (models.SystemStateENUMType, error) {
+	ret := _mock.Called(ctx, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SystemState")
+	}
+
+	var r0 models.SystemStateENUMType
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.Database) (models.SystemStateENUMType, error)); ok {
+		return returnFunc(ctx, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.Database) models.SystemStateENUMType); ok {
+		r0 = returnFunc(ctx, activeDBClient)
+	} else {
+		r0 = ret.Get(0).(models.SystemStateENUMType)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, db.Database) error); ok {
+		r1 = returnFunc(ctx, activeDBClient)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// ProtectedKVStore_SystemState_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SystemState'
+type ProtectedKVStore_SystemState_Call struct {
+	*mock.Call
+}
+
+// SystemState is a helper method to define mock.On call
+//   - ctx context.Context
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) SystemState(ctx interface{}, activeDBClient interface{}) *ProtectedKVStore_SystemState_Call {
+	return &ProtectedKVStore_SystemState_Call{Call: _e.mock.On("SystemState", ctx, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_SystemState_Call) Run(run func(ctx context.Context, activeDBClient db.Database)) *ProtectedKVStore_SystemState_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 db.Database
+		if args[1] != nil {
+			arg1 = args[1].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_SystemState_Call) Return(systemStateENUMType models.SystemStateENUMType, err error) *ProtectedKVStore_SystemState_Call {
+	_c.Call.Return(systemStateENUMType, err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_SystemState_Call) RunAndReturn(run func(ctx context.Context, activeDBClient db.Database) (models.SystemStateENUMType, error)) *ProtectedKVStore_SystemState_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ValueEquals provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) ValueEquals(ctx context.Context, key string, candidate []byte, activeDBClient db.Database) (bool, error) {
+	ret := _mock.Called(ctx, key, candidate, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ValueEquals")
+	}
+
+	var r0 bool
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []byte, db.Database) (bool, error)); ok {
+		return returnFunc(ctx, key, candidate, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []byte, db.Database) bool); ok {
+		r0 = returnFunc(ctx, key, candidate, activeDBClient)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, []byte, db.Database) error); ok {
+		r1 = returnFunc(ctx, key, candidate, activeDBClient)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// ProtectedKVStore_ValueEquals_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ValueEquals'
+type ProtectedKVStore_ValueEquals_Call struct {
+	*mock.Call
+}
+
+// ValueEquals is a helper method to define mock.On call
+//   - ctx context.Context
+//   - key string
+//   - candidate []byte
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) ValueEquals(ctx interface{}, key interface{}, candidate interface{}, activeDBClient interface{}) *ProtectedKVStore_ValueEquals_Call {
+	return &ProtectedKVStore_ValueEquals_Call{Call: _e.mock.On("ValueEquals", ctx, key, candidate, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_ValueEquals_Call) Run(run func(ctx context.Context, key string, candidate []byte, activeDBClient db.Database)) *ProtectedKVStore_ValueEquals_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 []byte
+		if args[2] != nil {
+			arg2 = args[2].([]byte)
+		}
+		var arg3 db.Database
+		if args[3] != nil {
+			arg3 = args[3].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_ValueEquals_Call) Return(bool bool, err error) *ProtectedKVStore_ValueEquals_Call {
+	_c.Call.Return(bool, err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_ValueEquals_Call) RunAndReturn(run func(ctx context.Context, key string, candidate []byte, activeDBClient db.Database) (bool, error)) *ProtectedKVStore_ValueEquals_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// VerifyIntegrity provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) VerifyIntegrity(ctx context.Context, filter db.RecordVersionQueryFilter, activeDBClient db.Database) (store.IntegrityReport, error) {
+	ret := _mock.Called(ctx, filter, activeDBClient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for VerifyIntegrity")
+	}
+
+	var r0 store.IntegrityReport
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.RecordVersionQueryFilter, db.Database) (store.IntegrityReport, error)); ok {
+		return returnFunc(ctx, filter, activeDBClient)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, db.RecordVersionQueryFilter, db.Database) store.IntegrityReport); ok {
+		r0 = returnFunc(ctx, filter, activeDBClient)
+	} else {
+		r0 = ret.Get(0).(store.IntegrityReport)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, db.RecordVersionQueryFilter, db.Database) error); ok {
+		r1 = returnFunc(ctx, filter, activeDBClient)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// ProtectedKVStore_VerifyIntegrity_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'VerifyIntegrity'
+type ProtectedKVStore_VerifyIntegrity_Call struct {
+	*mock.Call
+}
+
+// VerifyIntegrity is a helper method to define mock.On call
+//   - ctx context.Context
+//   - filter db.RecordVersionQueryFilter
+//   - activeDBClient db.Database
+func (_e *ProtectedKVStore_Expecter) VerifyIntegrity(ctx interface{}, filter interface{}, activeDBClient interface{}) *ProtectedKVStore_VerifyIntegrity_Call {
+	return &ProtectedKVStore_VerifyIntegrity_Call{Call: _e.mock.On("VerifyIntegrity", ctx, filter, activeDBClient)}
+}
+
+func (_c *ProtectedKVStore_VerifyIntegrity_Call) Run(run func(ctx context.Context, filter db.RecordVersionQueryFilter, activeDBClient db.Database)) *ProtectedKVStore_VerifyIntegrity_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 db.RecordVersionQueryFilter
+		if args[1] != nil {
+			arg1 = args[1].(db.RecordVersionQueryFilter)
+		}
+		var arg2 db.Database
+		if args[2] != nil {
+			arg2 = args[2].(db.Database)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_VerifyIntegrity_Call) Return(integrityReport store.IntegrityReport, err error) *ProtectedKVStore_VerifyIntegrity_Call {
+	_c.Call.Return(integrityReport, err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_VerifyIntegrity_Call) RunAndReturn(run func(ctx context.Context, filter db.RecordVersionQueryFilter, activeDBClient db.Database) (store.IntegrityReport, error)) *ProtectedKVStore_VerifyIntegrity_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Watch provides a mock function for the type ProtectedKVStore
+func (_mock *ProtectedKVStore) Watch(ctx context.Context, keyPrefix string) (<-chan store.WatchEvent, error) {
+	ret := _mock.Called(ctx, keyPrefix)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Watch")
+	}
+
+	var r0 <-chan store.WatchEvent
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (<-chan store.WatchEvent, error)); ok {
+		return returnFunc(ctx, keyPrefix)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) <-chan store.WatchEvent); ok {
+		r0 = returnFunc(ctx, keyPrefix)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan store.WatchEvent)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, keyPrefix)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// ProtectedKVStore_Watch_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Watch'
+type ProtectedKVStore_Watch_Call struct {
+	*mock.Call
+}
+
+// Watch is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyPrefix string
+func (_e *ProtectedKVStore_Expecter) Watch(ctx interface{}, keyPrefix interface{}) *ProtectedKVStore_Watch_Call {
+	return &ProtectedKVStore_Watch_Call{Call: _e.mock.On("Watch", ctx, keyPrefix)}
+}
+
+func (_c *ProtectedKVStore_Watch_Call) Run(run func(ctx context.Context, keyPrefix string)) *ProtectedKVStore_Watch_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *ProtectedKVStore_Watch_Call) Return(watchEventCh <-chan store.WatchEvent, err error) *ProtectedKVStore_Watch_Call {
+	_c.Call.Return(watchEventCh, err)
+	return _c
+}
+
+func (_c *ProtectedKVStore_Watch_Call) RunAndReturn(run func(ctx context.Context, keyPrefix string) (<-chan store.WatchEvent, error)) *ProtectedKVStore_Watch_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -28,14 +28,42 @@ const (
 	// SystemEventTypeDeactivateEncryptionKey encryption key is being deactivated
 	SystemEventTypeDeactivateEncryptionKey SystemEventTypeENUMType = "DEACTIVATE_ENCRYPTION_KEY"
 
+	// SystemEventTypeRetireEncryptionKey encryption key is being retired
+	SystemEventTypeRetireEncryptionKey SystemEventTypeENUMType = "RETIRE_ENCRYPTION_KEY"
+
 	// SystemEventTypeDeleteEncryptionKey encryption key is deleted
 	SystemEventTypeDeleteEncryptionKey SystemEventTypeENUMType = "DELETE_ENCRYPTION_KEY"
 
+	// SystemEventTypeRewrapEncryptionKey encryption key material is re-encrypted
+	SystemEventTypeRewrapEncryptionKey SystemEventTypeENUMType = "REWRAP_ENCRYPTION_KEY"
+
 	// SystemEventTypeAddNewRecord new data record is being added
 	SystemEventTypeAddNewRecord SystemEventTypeENUMType = "ADD_NEW_RECORD"
 
 	// SystemEventTypeDeleteRecord data record is deleted
 	SystemEventTypeDeleteRecord SystemEventTypeENUMType = "DELETE_RECORD"
+
+	// SystemEventTypeArchiveRecord data record is archived
+	SystemEventTypeArchiveRecord SystemEventTypeENUMType = "ARCHIVE_RECORD"
+
+	// SystemEventTypeActivateRecord data record is activated
+	SystemEventTypeActivateRecord SystemEventTypeENUMType = "ACTIVATE_RECORD"
+
+	// SystemEventTypeUpdateRecordMetadata data record metadata is changed
+	SystemEventTypeUpdateRecordMetadata SystemEventTypeENUMType = "UPDATE_RECORD_METADATA"
+
+	// SystemEventTypeRenameRecord data record is renamed
+	SystemEventTypeRenameRecord SystemEventTypeENUMType = "RENAME_RECORD"
+
+	// SystemEventTypeNewRecordVersion new data record version is being added
+	SystemEventTypeNewRecordVersion SystemEventTypeENUMType = "ADD_NEW_RECORD_VERSION"
+
+	// SystemEventTypeReencryptRecordVersion data record version is re-encrypted with
+	// another encryption key
+	SystemEventTypeReencryptRecordVersion SystemEventTypeENUMType = "REENCRYPT_RECORD_VERSION"
+
+	// SystemEventTypeDeleteRecordVersion data record version is deleted
+	SystemEventTypeDeleteRecordVersion SystemEventTypeENUMType = "DELETE_RECORD_VERSION"
 )
 
 // SystemEventAudit recording of events occurring at the system level
@@ -44,6 +72,8 @@ type SystemEventAudit struct {
 	ID string `json:"id" gorm:"column:id;primaryKey;unique" validate:"required"`
 	// EventType system event type
 	EventType SystemEventTypeENUMType `json:"type" gorm:"column:type;not null" validate:"required,system_event_type"`
+	// Actor who performed the action
+	Actor string `json:"actor" gorm:"column:actor;not null;default:system" validate:"required"`
 	// Metadata a metadata relating to the event
 	Metadata datatypes.JSON `json:"metadata,omitempty" gorm:"column:metadata;default:null"`
 	// CreatedAt entry creation timestamp
@@ -62,7 +92,11 @@ func (a SystemEventAudit) ParseMetadata(validator *validator.Validate) (interfac
 		fallthrough
 	case SystemEventTypeDeactivateEncryptionKey:
 		fallthrough
+	case SystemEventTypeRetireEncryptionKey:
+		fallthrough
 	case SystemEventTypeDeleteEncryptionKey:
+		fallthrough
+	case SystemEventTypeRewrapEncryptionKey:
 		var parsed SystemEventEncKeyRelated
 		if err := json.Unmarshal(a.Metadata, &parsed); err != nil {
 			return nil, fmt.Errorf("system event '%s' metadata parse failed [%w]", a.EventType, err)
@@ -73,11 +107,31 @@ func (a SystemEventAudit) ParseMetadata(validator *validator.Validate) (interfac
 	case SystemEventTypeAddNewRecord:
 		fallthrough
 	case SystemEventTypeDeleteRecord:
+		fallthrough
+	case SystemEventTypeArchiveRecord:
+		fallthrough
+	case SystemEventTypeActivateRecord:
+		fallthrough
+	case SystemEventTypeUpdateRecordMetadata:
+		fallthrough
+	case SystemEventTypeRenameRecord:
 		var parsed SystemEventDataRecordRelated
 		if err := json.Unmarshal(a.Metadata, &parsed); err != nil {
 			return nil, fmt.Errorf("system event '%s' metadata parse failed [%w]", a.EventType, err)
 		}
 		return parsed, validator.Struct(&parsed)
+
+	// Data record version related system audit events
+	case SystemEventTypeNewRecordVersion:
+		fallthrough
+	case SystemEventTypeReencryptRecordVersion:
+		fallthrough
+	case SystemEventTypeDeleteRecordVersion:
+		var parsed SystemEventRecordVersionRelated
+		if err := json.Unmarshal(a.Metadata, &parsed); err != nil {
+			return nil, fmt.Errorf("system event '%s' metadata parse failed [%w]", a.EventType, err)
+		}
+		return parsed, validator.Struct(&parsed)
 	}
 	return nil, nil
 }
@@ -92,6 +146,21 @@ type SystemEventEncKeyRelated struct {
 type SystemEventDataRecordRelated struct {
 	// RecordID the data record ID
 	RecordID string `json:"record_id" validate:"required,uuid_rfc4122"`
+	// RecordNamespace the data record namespace. Empty for the default namespace.
+	RecordNamespace string `json:"record_namespace,omitempty"`
 	// RecordName the data record name
 	RecordName string `json:"record_name" validate:"required"`
+	// PreviousRecordName the name of the data record before it was renamed. Only set for
+	// rename events.
+	PreviousRecordName string `json:"previous_record_name,omitempty"`
+}
+
+// SystemEventRecordVersionRelated system event metadata related to data record version
+type SystemEventRecordVersionRelated struct {
+	// RecordID the data record ID
+	RecordID string `json:"record_id" validate:"required,uuid_rfc4122"`
+	// VersionID the data record version ID
+	VersionID string `json:"version_id" validate:"required"`
+	// EncKeyID the encryption key used to encrypt the version
+	EncKeyID string `json:"enc_key_id" validate:"required,uuid_rfc4122"`
 }
@@ -14,6 +14,19 @@ const (
 	EncryptionKeyStateActive EncryptionKeyStateENUMType = "ACTIVE"
 	// EncryptionKeyStateInactive the encryption key is inactive
 	EncryptionKeyStateInactive EncryptionKeyStateENUMType = "INACTIVE"
+	// EncryptionKeyStateRetired the encryption key is retired. This state is terminal, and a
+	// retired key can not encrypt new data.
+	EncryptionKeyStateRetired EncryptionKeyStateENUMType = "RETIRED"
+)
+
+// AEADTypeENUMType AEAD algorithm enum type
+type AEADTypeENUMType string
+
+const (
+	// AEADTypeXChaCha20Poly1305 XChaCha20-Poly1305 AEAD
+	AEADTypeXChaCha20Poly1305 AEADTypeENUMType = "XChaCha20-Poly1305"
+	// AEADTypeAES256GCM AES256-GCM AEAD
+	AEADTypeAES256GCM AEADTypeENUMType = "AES256-GCM"
 )
 
 // EncryptionKey an encryption key used to encrypt record value
@@ -26,6 +39,23 @@ type EncryptionKey struct {
 	// EncKeyMaterial the encrypted encryption key material
 	EncKeyMaterial []byte `json:"enc_key_material" gorm:"column:enc_key_material;not null" validate:"required"`
 
+	// RSAFingerprint fingerprint of the RSA public key which encrypted the key material.
+	// Empty for keys encrypted by a supplied KeyWrapper, and for keys recorded before
+	// fingerprints were tracked.
+	RSAFingerprint string `json:"rsa_fingerprint,omitempty" gorm:"column:rsa_fingerprint"`
+
+	// MaterialFingerprint SHA256 fingerprint of the plain text key material, computed when
+	// the key is created. Keys sharing key material are rejected. Empty for keys recorded
+	// before fingerprints were tracked.
+	MaterialFingerprint string `json:"material_fingerprint,omitempty" gorm:"column:material_fingerprint;uniqueIndex;default:null"`
+
+	// AEADType the AEAD algorithm the key is used with
+	AEADType AEADTypeENUMType `json:"aead_type" gorm:"column:aead_type;not null;default:XChaCha20-Poly1305" validate:"required,aead_type"`
+
+	// NeedsRewrap whether the key material is encrypted with a RSA key other than the
+	// current primary RSA key. Not persisted; only set when listing keys.
+	NeedsRewrap bool `json:"needs_rewrap,omitempty" gorm:"-"`
+
 	// State the encryption key state
 	State EncryptionKeyStateENUMType `json:"state" gorm:"column:state;not null" validate:"required,enc_key_state"`
 
@@ -45,16 +75,18 @@ func (e *EncryptionKey) ValidateNextState(newState EncryptionKeyStateENUMType) e
 		EncryptionKeyStateInactive: {
 			EncryptionKeyStateInactive: true,
 			EncryptionKeyStateActive:   true,
+			EncryptionKeyStateRetired:  true,
 		},
+		EncryptionKeyStateRetired: {},
 	}
 
 	availableNextStates, ok := statesWithTransitions[e.State]
 	if !ok {
-		return fmt.Errorf("email can't transition out of state '%s'", e.State)
+		return fmt.Errorf("encryption key can't transition out of state '%s'", e.State)
 	}
 
 	if _, ok := availableNextStates[newState]; !ok {
-		return fmt.Errorf("email can't transition from '%s' to '%s'", e.State, newState)
+		return fmt.Errorf("encryption key can't transition from '%s' to '%s'", e.State, newState)
 	}
 
 	return nil
@@ -0,0 +1,49 @@
+package models_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alwitt/haven/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptionKeyValidateNextState(t *testing.T) {
+	assert := assert.New(t)
+
+	type testCase struct {
+		from    models.EncryptionKeyStateENUMType
+		to      models.EncryptionKeyStateENUMType
+		allowed bool
+	}
+	testCases := []testCase{
+		{from: models.EncryptionKeyStateActive, to: models.EncryptionKeyStateActive, allowed: true},
+		{from: models.EncryptionKeyStateActive, to: models.EncryptionKeyStateInactive, allowed: true},
+		{from: models.EncryptionKeyStateActive, to: models.EncryptionKeyStateRetired},
+		{from: models.EncryptionKeyStateInactive, to: models.EncryptionKeyStateActive, allowed: true},
+		{from: models.EncryptionKeyStateInactive, to: models.EncryptionKeyStateInactive, allowed: true},
+		{from: models.EncryptionKeyStateInactive, to: models.EncryptionKeyStateRetired, allowed: true},
+		{from: models.EncryptionKeyStateRetired, to: models.EncryptionKeyStateActive},
+		{from: models.EncryptionKeyStateRetired, to: models.EncryptionKeyStateInactive},
+		{from: models.EncryptionKeyStateRetired, to: models.EncryptionKeyStateRetired},
+	}
+
+	for _, oneTest := range testCases {
+		key := models.EncryptionKey{State: oneTest.from}
+		err := key.ValidateNextState(oneTest.to)
+		if oneTest.allowed {
+			assert.Nil(err, "%s -> %s", oneTest.from, oneTest.to)
+		} else {
+			assert.EqualError(err, fmt.Sprintf(
+				"encryption key can't transition from '%s' to '%s'", oneTest.from, oneTest.to,
+			))
+		}
+	}
+
+	// Unknown states have no transitions
+	key := models.EncryptionKey{State: "UNKNOWN"}
+	assert.EqualError(
+		key.ValidateNextState(models.EncryptionKeyStateActive),
+		"encryption key can't transition out of state 'UNKNOWN'",
+	)
+}
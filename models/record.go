@@ -1,14 +1,44 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// RecordStateENUMType data record state enum type
+type RecordStateENUMType string
+
+const (
+	// RecordStateActive the data record is active
+	RecordStateActive RecordStateENUMType = "ACTIVE"
+	// RecordStateArchived the data record is archived
+	RecordStateArchived RecordStateENUMType = "ARCHIVED"
+)
 
 // Record a key-value record
 type Record struct {
 	// ID record ID
 	ID string `json:"id" gorm:"column:id;primaryKey;unique" validate:"required,uuid_rfc4122"`
 
+	// Namespace the namespace of the record. A record name is unique within its namespace.
+	// Empty for the default namespace.
+	Namespace string `json:"namespace" gorm:"column:namespace;not null;default:'';uniqueIndex:idx_records_namespace_name"`
+
 	// Name record name / key
-	Name string `json:"name" gorm:"column:name;not null;unique" validate:"required"`
+	Name string `json:"name" gorm:"column:name;not null;uniqueIndex:idx_records_namespace_name" validate:"required"`
+
+	// State the data record state
+	State RecordStateENUMType `json:"state" gorm:"column:state;not null;default:ACTIVE" validate:"required,record_state"`
+
+	// Metadata user defined labels of the record, as a JSON object of string values. Nil if
+	// the record has no labels.
+	Metadata datatypes.JSON `json:"metadata,omitempty" gorm:"column:metadata;default:null"`
+
+	// LastReadAt when a value of the record was last read. Only tracked if the store is
+	// configured to track reads; nil if the record has not been read since.
+	LastReadAt *time.Time `json:"last_read_at,omitempty" gorm:"column:last_read_at"`
 
 	// CreatedAt entry creation timestamp
 	CreatedAt time.Time `json:"created_at"`
@@ -16,6 +46,52 @@ type Record struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// QualifiedName the record name prefixed with its namespace, or just the name if the
+// record is in the default namespace
+func (r *Record) QualifiedName() string {
+	if r.Namespace == "" {
+		return r.Name
+	}
+	return r.Namespace + "/" + r.Name
+}
+
+// ValidateNextState verify can transition to new state
+func (r *Record) ValidateNextState(newState RecordStateENUMType) error {
+	statesWithTransitions := map[RecordStateENUMType]map[RecordStateENUMType]bool{
+		RecordStateActive: {
+			RecordStateActive:   true,
+			RecordStateArchived: true,
+		},
+		RecordStateArchived: {
+			RecordStateArchived: true,
+			RecordStateActive:   true,
+		},
+	}
+
+	availableNextStates, ok := statesWithTransitions[r.State]
+	if !ok {
+		return fmt.Errorf("record can't transition out of state '%s'", r.State)
+	}
+
+	if _, ok := availableNextStates[newState]; !ok {
+		return fmt.Errorf("record can't transition from '%s' to '%s'", r.State, newState)
+	}
+
+	return nil
+}
+
+// KeyDerivationENUMType how the AEAD key of a record version is derived from its
+// encryption key
+type KeyDerivationENUMType string
+
+const (
+	// KeyDerivationNone the encryption key is used directly
+	KeyDerivationNone KeyDerivationENUMType = ""
+	// KeyDerivationHKDFSHA256 the AEAD key is derived from the encryption key with
+	// HKDF-SHA256, using the record ID as info, so each record has a distinct AEAD key
+	KeyDerivationHKDFSHA256 KeyDerivationENUMType = "HKDF-SHA256"
+)
+
 // RecordVersion one version of the record value
 type RecordVersion struct {
 	// ID record version ID
@@ -31,9 +107,58 @@ type RecordVersion struct {
 	EncValue []byte `json:"enc_value" gorm:"column:enc_value;not null;" validate:"required"`
 	// EncNonce the encryption nonce used
 	EncNonce []byte `json:"enc_nonce" gorm:"column:enc_nonce;not null;" validate:"required"`
+	// AADBound whether the encrypted value is bound to the record ID as associated data.
+	// Versions written before AAD binding was introduced are not bound.
+	AADBound bool `json:"aad_bound" gorm:"column:aad_bound;not null;default:false"`
+	// KeyDerivation how the AEAD key which encrypted the value is derived from the encryption
+	// key. Empty if the encryption key is used directly.
+	KeyDerivation KeyDerivationENUMType `json:"key_derivation,omitempty" gorm:"column:key_derivation;not null;default:''" validate:"key_derivation"`
+
+	// Note optional plain text reason for the change this version made
+	Note string `json:"note,omitempty" gorm:"column:note;not null;default:''" validate:"max=1024"`
+
+	// ExpiresAt when this version expires. Nil if the version does not expire.
+	ExpiresAt *time.Time `json:"expires_at,omitempty" gorm:"column:expires_at"`
+
+	// Sequence position of the version in the order versions were committed to the
+	// database. A version committed later always has a larger sequence.
+	Sequence int64 `json:"sequence" gorm:"column:sequence;not null;default:0;index"`
 
 	// CreatedAt entry creation timestamp
 	CreatedAt time.Time `json:"created_at"`
 	// UpdatedAt entry update timestamp
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+// RecordAAD the associated additional data binding an encrypted value to its record
+func RecordAAD(recordID string) []byte {
+	return []byte(recordID)
+}
+
+// AAD the associated additional data the version value was encrypted with. Nil if the
+// version is not bound to its record.
+func (v *RecordVersion) AAD() []byte {
+	if !v.AADBound {
+		return nil
+	}
+	return RecordAAD(v.RecordID)
+}
+
+// RecordSubkeyInfo the HKDF info deriving the AEAD key of a record from an encryption key
+func RecordSubkeyInfo(recordID string) []byte {
+	return []byte(recordID)
+}
+
+// SubkeyInfo the HKDF info the AEAD key of the version was derived with. Nil if the
+// encryption key was used directly.
+func (v *RecordVersion) SubkeyInfo() []byte {
+	if v.KeyDerivation != KeyDerivationHKDFSHA256 {
+		return nil
+	}
+	return RecordSubkeyInfo(v.RecordID)
+}
+
+// IsExpired whether the version has expired by the given time
+func (v *RecordVersion) IsExpired(now time.Time) bool {
+	return v.ExpiresAt != nil && !now.Before(*v.ExpiresAt)
+}
@@ -31,6 +31,18 @@ type SystemParams struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// SystemStateEvent one system state transition
+type SystemStateEvent struct {
+	// EventID ID of the system audit event recording the transition
+	EventID string `json:"event_id"`
+	// State the state the system entered
+	State SystemStateENUMType `json:"state"`
+	// Actor who performed the transition
+	Actor string `json:"actor"`
+	// Timestamp when the transition occurred
+	Timestamp time.Time `json:"timestamp"`
+}
+
 // ValidateNextState verify can transition to new state
 func (p *SystemParams) ValidateNextState(newState SystemStateENUMType) error {
 	statesWithTransitions := map[SystemStateENUMType]map[SystemStateENUMType]bool{
@@ -49,11 +61,11 @@ func (p *SystemParams) ValidateNextState(newState SystemStateENUMType) error {
 
 	availableNextStates, ok := statesWithTransitions[p.State]
 	if !ok {
-		return fmt.Errorf("email can't transition out of state '%s'", p.State)
+		return fmt.Errorf("system can't transition out of state '%s'", p.State)
 	}
 
 	if _, ok := availableNextStates[newState]; !ok {
-		return fmt.Errorf("email can't transition from '%s' to '%s'", p.State, newState)
+		return fmt.Errorf("system can't transition from '%s' to '%s'", p.State, newState)
 	}
 
 	return nil
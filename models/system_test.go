@@ -0,0 +1,49 @@
+package models_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alwitt/haven/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSystemParamsValidateNextState(t *testing.T) {
+	assert := assert.New(t)
+
+	type testCase struct {
+		from    models.SystemStateENUMType
+		to      models.SystemStateENUMType
+		allowed bool
+	}
+	testCases := []testCase{
+		{from: models.SystemStatePreInit, to: models.SystemStatePreInit, allowed: true},
+		{from: models.SystemStatePreInit, to: models.SystemStateInit, allowed: true},
+		{from: models.SystemStatePreInit, to: models.SystemStateRunning},
+		{from: models.SystemStateInit, to: models.SystemStatePreInit},
+		{from: models.SystemStateInit, to: models.SystemStateInit, allowed: true},
+		{from: models.SystemStateInit, to: models.SystemStateRunning, allowed: true},
+		{from: models.SystemStateRunning, to: models.SystemStatePreInit},
+		{from: models.SystemStateRunning, to: models.SystemStateInit},
+		{from: models.SystemStateRunning, to: models.SystemStateRunning, allowed: true},
+	}
+
+	for _, oneTest := range testCases {
+		params := models.SystemParams{State: oneTest.from}
+		err := params.ValidateNextState(oneTest.to)
+		if oneTest.allowed {
+			assert.Nil(err, "%s -> %s", oneTest.from, oneTest.to)
+		} else {
+			assert.EqualError(err, fmt.Sprintf(
+				"system can't transition from '%s' to '%s'", oneTest.from, oneTest.to,
+			))
+		}
+	}
+
+	// Unknown states have no transitions
+	params := models.SystemParams{State: "UNKNOWN"}
+	assert.EqualError(
+		params.ValidateNextState(models.SystemStateRunning),
+		"system can't transition out of state 'UNKNOWN'",
+	)
+}
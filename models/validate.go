@@ -19,18 +19,36 @@ func RegisterWithValidator(v *validator.Validate) error {
 		return err
 	}
 
+	if err := v.RegisterValidation(
+		"aead_type", validateAEADType,
+	); err != nil {
+		return err
+	}
+
 	if err := v.RegisterValidation(
 		"system_state", validateSystemStateType,
 	); err != nil {
 		return err
 	}
 
+	if err := v.RegisterValidation(
+		"record_state", validateRecordStateType,
+	); err != nil {
+		return err
+	}
+
 	if err := v.RegisterValidation(
 		"system_event_type", validateSystemEventType,
 	); err != nil {
 		return err
 	}
 
+	if err := v.RegisterValidation(
+		"key_derivation", validateKeyDerivationType,
+	); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -42,6 +60,21 @@ func validateEncKeyStateType(fl validator.FieldLevel) bool {
 	case EncryptionKeyStateActive:
 		fallthrough
 	case EncryptionKeyStateInactive:
+		fallthrough
+	case EncryptionKeyStateRetired:
+		return true
+	}
+	return false
+}
+
+func validateAEADType(fl validator.FieldLevel) bool {
+	if fl.Field().Kind() != reflect.String {
+		return false
+	}
+	switch AEADTypeENUMType(fl.Field().String()) {
+	case AEADTypeXChaCha20Poly1305:
+		fallthrough
+	case AEADTypeAES256GCM:
 		return true
 	}
 	return false
@@ -62,6 +95,32 @@ func validateSystemStateType(fl validator.FieldLevel) bool {
 	return false
 }
 
+func validateRecordStateType(fl validator.FieldLevel) bool {
+	if fl.Field().Kind() != reflect.String {
+		return false
+	}
+	switch RecordStateENUMType(fl.Field().String()) {
+	case RecordStateActive:
+		fallthrough
+	case RecordStateArchived:
+		return true
+	}
+	return false
+}
+
+func validateKeyDerivationType(fl validator.FieldLevel) bool {
+	if fl.Field().Kind() != reflect.String {
+		return false
+	}
+	switch KeyDerivationENUMType(fl.Field().String()) {
+	case KeyDerivationNone:
+		fallthrough
+	case KeyDerivationHKDFSHA256:
+		return true
+	}
+	return false
+}
+
 func validateSystemEventType(fl validator.FieldLevel) bool {
 	if fl.Field().Kind() != reflect.String {
 		return false
@@ -77,11 +136,29 @@ func validateSystemEventType(fl validator.FieldLevel) bool {
 		fallthrough
 	case SystemEventTypeDeactivateEncryptionKey:
 		fallthrough
+	case SystemEventTypeRetireEncryptionKey:
+		fallthrough
 	case SystemEventTypeDeleteEncryptionKey:
 		fallthrough
+	case SystemEventTypeRewrapEncryptionKey:
+		fallthrough
 	case SystemEventTypeAddNewRecord:
 		fallthrough
 	case SystemEventTypeDeleteRecord:
+		fallthrough
+	case SystemEventTypeArchiveRecord:
+		fallthrough
+	case SystemEventTypeActivateRecord:
+		fallthrough
+	case SystemEventTypeUpdateRecordMetadata:
+		fallthrough
+	case SystemEventTypeRenameRecord:
+		fallthrough
+	case SystemEventTypeNewRecordVersion:
+		fallthrough
+	case SystemEventTypeReencryptRecordVersion:
+		fallthrough
+	case SystemEventTypeDeleteRecordVersion:
 		return true
 	}
 	return false
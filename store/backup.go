@@ -0,0 +1,212 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/alwitt/haven/db"
+	"github.com/alwitt/haven/models"
+)
+
+// ErrBackupTargetNotEmpty the database to restore a backup into already holds records
+var ErrBackupTargetNotEmpty = errors.New("backup restore target is not empty")
+
+// backupFormatVersion the current encrypted backup format version
+const backupFormatVersion = 1
+
+// exportPageSize number of entries fetched per page when exporting
+const exportPageSize = 500
+
+/*
+backupLine one line of an encrypted backup
+
+A backup is a sequence of JSON lines. The first line is the header, which carries the
+format version and the system parameters. It is followed by the encryption keys, then the
+data records, then the data record versions; each line carries exactly one entry.
+*/
+type backupLine struct {
+	// Format the backup format version. Only set on the header line.
+	Format int `json:"format,omitempty"`
+	// SystemParams the system parameters. Only set on the header line.
+	SystemParams *models.SystemParams `json:"system_params,omitempty"`
+	// EncryptionKey an encryption key, with its key material still encrypted
+	EncryptionKey *models.EncryptionKey `json:"encryption_key,omitempty"`
+	// Record a data record
+	Record *models.Record `json:"record,omitempty"`
+	// RecordVersion a data record version, with its value still encrypted
+	RecordVersion *models.RecordVersion `json:"record_version,omitempty"`
+}
+
+// allRecordStates the data record states, for listing every record
+var allRecordStates = []models.RecordStateENUMType{
+	models.RecordStateActive, models.RecordStateArchived,
+}
+
+// forEachPage call fetchPage with successive pages until a short page is returned
+func forEachPage(fetchPage func(page db.CommonListEntryQueryFilter) (int, error)) error {
+	limit := exportPageSize
+	for offset := 0; ; offset += limit {
+		pageOffset := offset
+		fetched, err := fetchPage(db.CommonListEntryQueryFilter{Limit: &limit, Offset: &pageOffset})
+		if err != nil {
+			return err
+		}
+		if fetched < limit {
+			return nil
+		}
+	}
+}
+
+/*
+ExportEncrypted write a backup of the whole store. The values and the encryption key
+material remain encrypted, so the backup is as protected as the database.
+
+	@param ctx context.Context - execution context
+	@param w io.Writer - the backup destination
+	@param activeDBClient Database - existing database transaction
+*/
+func (s *protectedKVStore) ExportEncrypted(
+	ctx context.Context, w io.Writer, activeDBClient db.Database,
+) error {
+	encoder := json.NewEncoder(w)
+
+	if dbErr := s.inSession(
+		ctx, "export_encrypted", activeDBClient,
+		func(dbCtx context.Context, dbClient db.Database) error {
+			params, err := dbClient.GetSystemParamEntry(dbCtx)
+			if err != nil {
+				return fmt.Errorf("failed to read system parameters [%w]", err)
+			}
+			if err := encoder.Encode(backupLine{
+				Format: backupFormatVersion, SystemParams: &params,
+			}); err != nil {
+				return fmt.Errorf("failed to write backup header [%w]", err)
+			}
+
+			if err := forEachPage(func(page db.CommonListEntryQueryFilter) (int, error) {
+				keys, err := dbClient.ListEncryptionKeys(dbCtx, db.EncryptionKeyQueryFilter{
+					CommonListEntryQueryFilter: page,
+				})
+				if err != nil {
+					return 0, fmt.Errorf("failed to list encryption keys [%w]", err)
+				}
+				for idx := range keys {
+					if err := encoder.Encode(backupLine{EncryptionKey: &keys[idx]}); err != nil {
+						return 0, fmt.Errorf("failed to write encryption key %s [%w]", keys[idx].ID, err)
+					}
+				}
+				return len(keys), nil
+			}); err != nil {
+				return err
+			}
+
+			if err := forEachPage(func(page db.CommonListEntryQueryFilter) (int, error) {
+				records, err := dbClient.ListRecords(dbCtx, db.RecordQueryFilter{
+					CommonListEntryQueryFilter: page, State: allRecordStates, SortAscending: true,
+				})
+				if err != nil {
+					return 0, fmt.Errorf("failed to list data records [%w]", err)
+				}
+				for idx := range records {
+					if err := encoder.Encode(backupLine{Record: &records[idx]}); err != nil {
+						return 0, fmt.Errorf("failed to write data record %s [%w]", records[idx].ID, err)
+					}
+				}
+				return len(records), nil
+			}); err != nil {
+				return err
+			}
+
+			return forEachPage(func(page db.CommonListEntryQueryFilter) (int, error) {
+				versions, err := dbClient.ListAllRecordVersions(dbCtx, db.RecordVersionQueryFilter{
+					CommonListEntryQueryFilter: page, SortAscending: true,
+				})
+				if err != nil {
+					return 0, fmt.Errorf("failed to list record versions [%w]", err)
+				}
+				for idx := range versions {
+					if err := encoder.Encode(backupLine{RecordVersion: &versions[idx]}); err != nil {
+						return 0, fmt.Errorf(
+							"failed to write record version %s [%w]", versions[idx].ID, err,
+						)
+					}
+				}
+				return len(versions), nil
+			})
+		},
+	); dbErr != nil {
+		return fmt.Errorf("failed to export encrypted backup [%w]", dbErr)
+	}
+
+	return nil
+}
+
+/*
+ImportEncrypted restore a backup written by ExportEncrypted. The database must not hold
+any data records. The encryption keys of the backup can only be used if the store's RSA
+key pairs include the one which encrypted them.
+
+	@param ctx context.Context - execution context
+	@param r io.Reader - the backup source
+	@param activeDBClient Database - existing database transaction
+*/
+func (s *protectedKVStore) ImportEncrypted(
+	ctx context.Context, r io.Reader, activeDBClient db.Database,
+) error {
+	decoder := json.NewDecoder(r)
+
+	var header backupLine
+	if err := decoder.Decode(&header); err != nil {
+		return fmt.Errorf("failed to read backup header [%w]", err)
+	}
+	if header.Format != backupFormatVersion || header.SystemParams == nil {
+		return fmt.Errorf("unsupported backup format %d", header.Format)
+	}
+
+	if dbErr := s.inSession(
+		ctx, "import_encrypted", activeDBClient,
+		func(dbCtx context.Context, dbClient db.Database) error {
+			count, err := dbClient.CountRecords(dbCtx, db.RecordQueryFilter{State: allRecordStates})
+			if err != nil {
+				return fmt.Errorf("failed to count data records [%w]", err)
+			}
+			if count > 0 {
+				return ErrBackupTargetNotEmpty
+			}
+
+			if err := dbClient.RestoreSystemParams(dbCtx, *header.SystemParams); err != nil {
+				return fmt.Errorf("failed to restore system parameters [%w]", err)
+			}
+
+			for {
+				var line backupLine
+				if err := decoder.Decode(&line); err == io.EOF {
+					return nil
+				} else if err != nil {
+					return fmt.Errorf("failed to read backup entry [%w]", err)
+				}
+
+				switch {
+				case line.EncryptionKey != nil:
+					err = dbClient.RestoreEncryptionKey(dbCtx, *line.EncryptionKey)
+				case line.Record != nil:
+					err = dbClient.RestoreRecord(dbCtx, *line.Record)
+				case line.RecordVersion != nil:
+					err = dbClient.RestoreRecordVersion(dbCtx, *line.RecordVersion)
+				default:
+					err = fmt.Errorf("backup entry holds no data")
+				}
+				if err != nil {
+					return fmt.Errorf("failed to restore backup entry [%w]", err)
+				}
+			}
+		},
+	); dbErr != nil {
+		return fmt.Errorf("failed to import encrypted backup [%w]", dbErr)
+	}
+
+	return nil
+}
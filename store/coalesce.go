@@ -0,0 +1,137 @@
+package store
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/alwitt/haven/models"
+)
+
+// coalescedWrite the last write of one key, remembered for the coalescing window
+type coalescedWrite struct {
+	// digest keyed digest of the written value
+	digest []byte
+	// writtenAt when the value was written
+	writtenAt time.Time
+	// record the data record of the key
+	record models.Record
+	// version the version the write recorded
+	version models.RecordVersion
+}
+
+// coalescedWriteKey identifies a key across namespaces
+type coalescedWriteKey struct {
+	namespace string
+	key       string
+}
+
+/*
+writeCoalescer remembers the last write of each key for a short window, so repeated
+writes of the same value to a key within the window do not each record a new version.
+
+The values are not kept; only a digest keyed with a secret of the coalescer is.
+*/
+type writeCoalescer struct {
+	window time.Duration
+
+	lock       sync.Mutex
+	digestKey  []byte
+	lastWrites map[coalescedWriteKey]coalescedWrite
+}
+
+/*
+newWriteCoalescer define a new write coalescer
+
+	@param window time.Duration - how long a write is remembered
+	@returns new coalescer
+*/
+func newWriteCoalescer(window time.Duration) (*writeCoalescer, error) {
+	digestKey := make([]byte, sha256.Size)
+	if _, err := rand.Read(digestKey); err != nil {
+		return nil, err
+	}
+	return &writeCoalescer{
+		window:     window,
+		digestKey:  digestKey,
+		lastWrites: make(map[coalescedWriteKey]coalescedWrite),
+	}, nil
+}
+
+// valueDigest keyed digest of a value
+func (c *writeCoalescer) valueDigest(value []byte) []byte {
+	mac := hmac.New(sha256.New, c.digestKey)
+	mac.Write(value)
+	return mac.Sum(nil)
+}
+
+/*
+lookup fetch the last write of a key, if it wrote the same value within the window
+
+	@param namespace string - the key namespace
+	@param key string - the key
+	@param value []byte - the value about to be written
+	@param now time.Time - the current time
+	@returns the record and version of the last write, and whether the write coalesces
+*/
+func (c *writeCoalescer) lookup(
+	namespace string, key string, value []byte, now time.Time,
+) (models.Record, models.RecordVersion, bool) {
+	digest := c.valueDigest(value)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	lastWrite, ok := c.lastWrites[coalescedWriteKey{namespace: namespace, key: key}]
+	if !ok || now.Sub(lastWrite.writtenAt) >= c.window || !hmac.Equal(lastWrite.digest, digest) {
+		return models.Record{}, models.RecordVersion{}, false
+	}
+	return lastWrite.record, lastWrite.version, true
+}
+
+/*
+remember record the last write of a key. Writes which have left the window are dropped.
+
+	@param namespace string - the key namespace
+	@param key string - the key
+	@param value []byte - the written value
+	@param now time.Time - when the value was written
+	@param record models.Record - the data record of the key
+	@param version models.RecordVersion - the version the write recorded
+*/
+func (c *writeCoalescer) remember(
+	namespace string,
+	key string,
+	value []byte,
+	now time.Time,
+	record models.Record,
+	version models.RecordVersion,
+) {
+	digest := c.valueDigest(value)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for writeKey, lastWrite := range c.lastWrites {
+		if now.Sub(lastWrite.writtenAt) >= c.window {
+			delete(c.lastWrites, writeKey)
+		}
+	}
+	c.lastWrites[coalescedWriteKey{namespace: namespace, key: key}] = coalescedWrite{
+		digest: digest, writtenAt: now, record: record, version: version,
+	}
+}
+
+/*
+forget drop the last write of a key
+
+	@param namespace string - the key namespace
+	@param key string - the key
+*/
+func (c *writeCoalescer) forget(namespace string, key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.lastWrites, coalescedWriteKey{namespace: namespace, key: key})
+}
@@ -0,0 +1,45 @@
+package store
+
+import "encoding/json"
+
+// ValueCodec serializes structured values before they are stored
+type ValueCodec interface {
+	/*
+		Encode serialize a value
+
+			@param value any - the value to serialize
+			@returns the serialized value
+	*/
+	Encode(value any) ([]byte, error)
+
+	/*
+		Decode deserialize a value
+
+			@param data []byte - the serialized value
+			@param value any - pointer to the object to populate
+	*/
+	Decode(data []byte, value any) error
+}
+
+// JSONValueCodec ValueCodec which serializes values as JSON
+type JSONValueCodec struct{}
+
+/*
+Encode serialize a value
+
+	@param value any - the value to serialize
+	@returns the serialized value
+*/
+func (JSONValueCodec) Encode(value any) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+/*
+Decode deserialize a value
+
+	@param data []byte - the serialized value
+	@param value any - pointer to the object to populate
+*/
+func (JSONValueCodec) Decode(data []byte, value any) error {
+	return json.Unmarshal(data, value)
+}
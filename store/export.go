@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/alwitt/haven/db"
+	"github.com/alwitt/haven/models"
+)
+
+// ExportOptions key history export options
+type ExportOptions struct {
+	// ConfirmPlaintext whether to include the decrypted values in the export. The caller
+	// must explicitly opt in, as the output is no longer protected.
+	ConfirmPlaintext bool
+}
+
+// KeyHistoryExport exported version history of one key
+type KeyHistoryExport struct {
+	// Record the data record of the key
+	Record models.Record `json:"record"`
+	// Versions the versions of the key, oldest first
+	Versions []KeyVersionExport `json:"versions"`
+}
+
+// KeyVersionExport one exported version of a key
+type KeyVersionExport struct {
+	// VersionID the version ID
+	VersionID string `json:"version_id"`
+	// Timestamp the version record timestamp
+	Timestamp time.Time `json:"timestamp"`
+	// EncKeyID the encryption key which encrypted the value
+	EncKeyID string `json:"enc_key_id"`
+	// ExpiresAt when the version expires, if ever
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Expired whether the version has expired. Expired versions are exported without value.
+	Expired bool `json:"expired"`
+	// Value the decrypted value. Only set if ExportOptions.ConfirmPlaintext.
+	Value []byte `json:"value,omitempty"`
+}
+
+/*
+ExportKeyHistory write the record and every version of a key as JSON
+
+	@param ctx context.Context - execution context
+	@param key string - key
+	@param w io.Writer - the export destination
+	@param opts ExportOptions - export options
+	@param activeDBClient Database - existing database transaction
+*/
+func (s *protectedKVStore) ExportKeyHistory(
+	ctx context.Context,
+	key string,
+	w io.Writer,
+	opts ExportOptions,
+	activeDBClient db.Database,
+) error {
+	var export KeyHistoryExport
+
+	if dbErr := s.inSession(
+		ctx, "export_history", activeDBClient,
+		func(dbCtx context.Context, dbClient db.Database) error {
+			var err error
+			export.Record, err = dbClient.GetRecordByName(dbCtx, NamespaceFromContext(dbCtx), key)
+			if err != nil {
+				return fmt.Errorf("failed to find key '%s' [%w]", key, err)
+			}
+
+			versionEntries, err := dbClient.ListVersionsOfOneRecord(
+				dbCtx, export.Record, db.RecordVersionQueryFilter{SortAscending: true},
+			)
+			if err != nil {
+				return fmt.Errorf("failed to list key %s versions [%w]", export.Record.ID, err)
+			}
+
+			export.Versions = []KeyVersionExport{}
+			for _, versionEntry := range versionEntries {
+				entry := KeyVersionExport{
+					VersionID: versionEntry.ID,
+					Timestamp: versionEntry.CreatedAt,
+					EncKeyID:  versionEntry.EncKeyID,
+					ExpiresAt: versionEntry.ExpiresAt,
+				}
+				if opts.ConfirmPlaintext {
+					entry.Value, err = s.GetValueOfKeyAtVersion(dbCtx, versionEntry, dbClient)
+					if errors.Is(err, ErrVersionExpired) {
+						entry.Expired = true
+					} else if err != nil {
+						return err
+					}
+				} else {
+					entry.Expired = versionEntry.IsExpired(time.Now())
+				}
+				export.Versions = append(export.Versions, entry)
+			}
+
+			return nil
+		},
+	); dbErr != nil {
+		return fmt.Errorf("failed to export key '%s' history [%w]", key, dbErr)
+	}
+
+	if err := json.NewEncoder(w).Encode(&export); err != nil {
+		return fmt.Errorf("failed to write key '%s' history [%w]", key, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/alwitt/haven/db"
+	"github.com/alwitt/haven/encryption"
+	"github.com/alwitt/haven/models"
+)
+
+// IntegrityFailureENUMType why a record version failed the integrity check
+type IntegrityFailureENUMType string
+
+const (
+	// IntegrityFailureMissingKey the encryption key of the version does not exist
+	IntegrityFailureMissingKey IntegrityFailureENUMType = "MISSING_KEY"
+	// IntegrityFailureInactiveKey the encryption key of the version is not active, or could
+	// not be decrypted
+	IntegrityFailureInactiveKey IntegrityFailureENUMType = "INACTIVE_KEY"
+	// IntegrityFailureAuthentication the encrypted value failed AEAD authentication
+	IntegrityFailureAuthentication IntegrityFailureENUMType = "AUTHENTICATION_FAILED"
+	// IntegrityFailureMalformed the encrypted value is not a valid cipher text envelope
+	IntegrityFailureMalformed IntegrityFailureENUMType = "MALFORMED"
+	// IntegrityFailureOther the decryption failed for another reason
+	IntegrityFailureOther IntegrityFailureENUMType = "OTHER"
+)
+
+// VersionIntegrity integrity check result of one record version
+type VersionIntegrity struct {
+	// RecordID the data record of the version
+	RecordID string `json:"record_id"`
+	// VersionID the record version
+	VersionID string `json:"version_id"`
+	// EncKeyID the encryption key of the version
+	EncKeyID string `json:"enc_key_id"`
+	// OK whether the version decrypted
+	OK bool `json:"ok"`
+	// Failure why the version failed to decrypt. Empty if it decrypted.
+	Failure IntegrityFailureENUMType `json:"failure,omitempty"`
+	// Reason the decryption error. Empty if it decrypted.
+	Reason string `json:"reason,omitempty"`
+}
+
+// IntegrityReport integrity check results
+type IntegrityReport struct {
+	// Checked number of record versions checked
+	Checked int `json:"checked"`
+	// Failed number of record versions which failed to decrypt
+	Failed int `json:"failed"`
+	// Versions the result of each record version checked, in the order they were recorded
+	Versions []VersionIntegrity `json:"versions"`
+}
+
+// classifyIntegrityFailure why a record version failed to decrypt
+func classifyIntegrityFailure(err error) IntegrityFailureENUMType {
+	switch {
+	case errors.Is(err, db.ErrEncryptionKeyNotFound), errors.Is(err, encryption.ErrKeyRevoked):
+		return IntegrityFailureMissingKey
+	case errors.Is(err, encryption.ErrKeyNotActive):
+		return IntegrityFailureInactiveKey
+	case errors.Is(err, encryption.ErrAuthenticationFailed):
+		return IntegrityFailureAuthentication
+	case errors.Is(err, encryption.ErrMalformedEnvelope):
+		return IntegrityFailureMalformed
+	default:
+		return IntegrityFailureOther
+	}
+}
+
+/*
+VerifyIntegrity attempt to decrypt every record version matching the filter, reporting
+which ones fail and why. A failure does not stop the check. The decrypted values are
+discarded. The pagination parameters of the filter are ignored.
+
+Expired versions are checked as well, as their values remain stored.
+
+	@param ctx context.Context - execution context
+	@param filter db.RecordVersionQueryFilter - the record versions to check
+	@param activeDBClient Database - existing database transaction
+	@returns the check results
+*/
+func (s *protectedKVStore) VerifyIntegrity(
+	ctx context.Context, filter db.RecordVersionQueryFilter, activeDBClient db.Database,
+) (IntegrityReport, error) {
+	report := IntegrityReport{Versions: []VersionIntegrity{}}
+
+	if dbErr := s.inSession(
+		ctx, "verify_integrity", activeDBClient,
+		func(dbCtx context.Context, dbClient db.Database) error {
+			return forEachPage(func(page db.CommonListEntryQueryFilter) (int, error) {
+				pageFilter := filter
+				pageFilter.CommonListEntryQueryFilter = page
+				pageFilter.AfterVersionID = nil
+				pageFilter.SortBy = ""
+				pageFilter.SortAscending = true
+				versions, err := dbClient.ListAllRecordVersions(dbCtx, pageFilter)
+				if err != nil {
+					return 0, fmt.Errorf("failed to list record versions [%w]", err)
+				}
+				for _, version := range versions {
+					report.Versions = append(report.Versions, s.verifyVersion(dbCtx, version, dbClient))
+				}
+				return len(versions), nil
+			})
+		},
+	); dbErr != nil {
+		return IntegrityReport{}, fmt.Errorf("failed to verify record versions [%w]", dbErr)
+	}
+
+	report.Checked = len(report.Versions)
+	for _, result := range report.Versions {
+		if !result.OK {
+			report.Failed++
+		}
+	}
+	return report, nil
+}
+
+// verifyVersion attempt to decrypt one record version
+func (s *protectedKVStore) verifyVersion(
+	ctx context.Context, version models.RecordVersion, dbClient db.Database,
+) VersionIntegrity {
+	result := VersionIntegrity{
+		RecordID: version.RecordID, VersionID: version.ID, EncKeyID: version.EncKeyID,
+	}
+
+	_, plainText, err := s.cryptoEngine.DecryptData(
+		ctx,
+		version.EncKeyID,
+		encryption.EncryptedData{
+			CipherText: version.EncValue,
+			Nonce:      version.EncNonce,
+			AAD:        version.AAD(),
+			SubkeyInfo: version.SubkeyInfo(),
+		},
+		dbClient,
+	)
+	clear(plainText)
+	if err != nil {
+		result.Failure = classifyIntegrityFailure(err)
+		result.Reason = err.Error()
+		return result
+	}
+
+	result.OK = true
+	return result
+}
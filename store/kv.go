@@ -2,8 +2,13 @@
 package store
 
 import (
+	"bytes"
 	"context"
+	"crypto/subtle"
+	"errors"
 	"fmt"
+	"io"
+	"sync"
 	"time"
 
 	"github.com/alwitt/goutils"
@@ -11,9 +16,41 @@ import (
 	"github.com/alwitt/haven/encryption"
 	"github.com/alwitt/haven/models"
 	"github.com/apex/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// ErrVersionExpired the record version has passed its expiry
+var ErrVersionExpired = errors.New("record version expired")
+
+// ErrKeyExists the key already exists
+var ErrKeyExists = errors.New("key already exists")
+
+// ErrRecordBudgetExceeded the key's versions would hold more encrypted data than
+// ProtectedKVStoreOptions.MaxCumulativeBytesPerRecord allows
+var ErrRecordBudgetExceeded = errors.New("key cumulative size budget exceeded")
+
+// ErrDatabaseUnavailable the health check could not reach the database
+var ErrDatabaseUnavailable = errors.New("database unavailable")
+
+// ErrWorkingKeyUnavailable the health check could not use the working encryption key
+var ErrWorkingKeyUnavailable = errors.New("working encryption key unavailable")
+
+// ErrSystemNotReady the system has not finished initializing, so writes are refused
+var ErrSystemNotReady = errors.New("system is not ready")
+
+// ErrVersionNotFound no version of the key matches the request. The same error as
+// db.ErrVersionNotFound.
+var ErrVersionNotFound = db.ErrVersionNotFound
+
+// ErrLastVersion the version is the only one left of its key, so deleting it needs force
+var ErrLastVersion = errors.New("version is the last version of the key")
+
 // ProtectedKVStore protected key store record KVs after encrypting value
+//
+// Keys are scoped to the namespace carried by the context, see WithNamespace. Without one,
+// keys belong to the default namespace.
 type ProtectedKVStore interface {
 	/*
 		RecordKeyValue record a key value pair
@@ -29,6 +66,62 @@ type ProtectedKVStore interface {
 		ctx context.Context, key string, value []byte, timestamp time.Time, activeDBClient db.Database,
 	) (models.Record, models.RecordVersion, error)
 
+	/*
+		RecordKeyValueWithTTL record a key value pair which expires after a duration
+
+			@param ctx context.Context - execution context
+			@param key string - key
+			@param value []byte - value
+			@param timestamp time.Time - record timestamp
+			@param ttl time.Duration - the version expires at timestamp + ttl
+			@param activeDBClient Database - existing database transaction
+			@returns the record and record version entry
+	*/
+	RecordKeyValueWithTTL(
+		ctx context.Context,
+		key string,
+		value []byte,
+		timestamp time.Time,
+		ttl time.Duration,
+		activeDBClient db.Database,
+	) (models.Record, models.RecordVersion, error)
+
+	/*
+		RecordKeyValueWithNote record a key value pair, noting the reason for the change on
+		the new version
+
+			@param ctx context.Context - execution context
+			@param key string - key
+			@param value []byte - value
+			@param timestamp time.Time - record timestamp
+			@param note string - plain text reason for the change
+			@param activeDBClient Database - existing database transaction
+			@returns the record and record version entry
+	*/
+	RecordKeyValueWithNote(
+		ctx context.Context,
+		key string,
+		value []byte,
+		timestamp time.Time,
+		note string,
+		activeDBClient db.Database,
+	) (models.Record, models.RecordVersion, error)
+
+	/*
+		CreateKeyWithValue record a new key with its first value. Unlike RecordKeyValue,
+		ErrKeyExists is returned if the key already exists.
+
+			@param ctx context.Context - execution context
+			@param key string - key
+			@param value []byte - value
+			@param timestamp time.Time - record timestamp
+			@param activeDBClient Database - existing database transaction
+			@returns the record and record version entry
+	*/
+	CreateKeyWithValue(
+		ctx context.Context, key string, value []byte, timestamp time.Time, activeDBClient db.Database,
+	) (models.Record, models.RecordVersion, error)
+
 	/*
 		ListKeyVersions list the versions of a key
 
@@ -41,287 +134,2316 @@ type ProtectedKVStore interface {
 		ctx context.Context, key string, activeDBClient db.Database,
 	) (models.Record, []models.RecordVersion, error)
 
-	/*
-		GetValueOfKeyAtVersionID get the value of a key at a particular version by ID
+	/*
+		GetLatestValue get the value of the newest version of a key
+
+		ErrVersionExpired is returned if the newest version has expired.
+
+			@param ctx context.Context - execution context
+			@param key string - key
+			@param activeDBClient Database - existing database transaction
+			@return the newest version, and its decrypted value
+	*/
+	GetLatestValue(
+		ctx context.Context, key string, activeDBClient db.Database,
+	) (models.RecordVersion, []byte, error)
+
+	/*
+		GetLatestValues get the values of the newest versions of several keys, all read in one
+		transaction. A key which can not be read does not fail the others; its error is
+		reported in its result instead.
+
+			@param ctx context.Context - execution context
+			@param keys []string - keys
+			@param activeDBClient Database - existing database transaction
+			@return the result of each key, by key
+	*/
+	GetLatestValues(
+		ctx context.Context, keys []string, activeDBClient db.Database,
+	) (map[string]ValueResult, error)
+
+	/*
+		GetKeyDetail get a key, the metadata of its versions, and its current value, all read
+		in one transaction
+
+			@param ctx context.Context - execution context
+			@param key string - key
+			@param activeDBClient Database - existing database transaction
+			@return the key detail
+	*/
+	GetKeyDetail(ctx context.Context, key string, activeDBClient db.Database) (KeyDetail, error)
+
+	/*
+		GetValueOfKeyAtTimestamp get the value of a key as of a point in time, which is the
+		value of the newest version created at or before that time
+
+		ErrVersionNotFound is returned if no version was created by then, and
+		ErrVersionExpired if that version has expired.
+
+			@param ctx context.Context - execution context
+			@param key string - key
+			@param at time.Time - the point in time
+			@param activeDBClient Database - existing database transaction
+			@return decrypted value of that version, and the version
+	*/
+	GetValueOfKeyAtTimestamp(
+		ctx context.Context, key string, at time.Time, activeDBClient db.Database,
+	) ([]byte, models.RecordVersion, error)
+
+	/*
+		GetValueOfKeyAtIndex get the value of a key at a version picked by its position in
+		the order the versions were recorded
+
+		ErrVersionNotFound is returned if the index is out of range, and ErrVersionExpired if
+		that version has expired.
+
+			@param ctx context.Context - execution context
+			@param key string - key
+			@param index int - position of the version, starting from 0 for the oldest version.
+			    A negative index counts from the newest version, which is -1.
+			@param activeDBClient Database - existing database transaction
+			@return decrypted value of that version, and the version
+	*/
+	GetValueOfKeyAtIndex(
+		ctx context.Context, key string, index int, activeDBClient db.Database,
+	) ([]byte, models.RecordVersion, error)
+
+	/*
+		GetValueOfKeyAtVersionID get the value of a key at a particular version by ID
+
+		ErrVersionExpired is returned if the version has expired.
+
+			@param ctx context.Context - execution context
+			@param versionID string - the version ID
+			@param activeDBClient Database - existing database transaction
+			@return decrypted value of that version
+	*/
+	GetValueOfKeyAtVersionID(
+		ctx context.Context, versionID string, activeDBClient db.Database,
+	) ([]byte, error)
+
+	/*
+		CompareVersions decrypt two versions by ID, and compare their values
+
+		ErrVersionNotFound is returned if either version does not exist, and
+		ErrVersionExpired if either has expired.
+
+			@param ctx context.Context - execution context
+			@param versionIDA string - the first version ID
+			@param versionIDB string - the second version ID
+			@param activeDBClient Database - existing database transaction
+			@return whether the values are equal, and the decrypted values of both versions
+	*/
+	CompareVersions(
+		ctx context.Context, versionIDA string, versionIDB string, activeDBClient db.Database,
+	) (bool, []byte, []byte, error)
+
+	/*
+		ValueEquals check whether the newest value of a key equals the candidate. The values
+		are compared in constant time. A key which does not exist equals no value.
+
+		ErrVersionExpired is returned if the newest version has expired.
+
+			@param ctx context.Context - execution context
+			@param key string - key
+			@param candidate []byte - the value to compare with
+			@param activeDBClient Database - existing database transaction
+			@return whether the newest value equals the candidate
+	*/
+	ValueEquals(
+		ctx context.Context, key string, candidate []byte, activeDBClient db.Database,
+	) (bool, error)
+
+	/*
+		GetValueOfKeyAtVersion get the value of a key at particular version
+
+		ErrVersionExpired is returned if the version has expired.
+
+			@param ctx context.Context - execution context
+			@param versionEntry models.RecordVersion - the version
+			@param activeDBClient Database - existing database transaction
+			@return decrypted value of that version
+	*/
+	GetValueOfKeyAtVersion(
+		ctx context.Context, versionEntry models.RecordVersion, activeDBClient db.Database,
+	) ([]byte, error)
+
+	/*
+		RecordKeyObject record a key with a structured value
+
+		The value is serialized with the store's ValueCodec before encryption.
+
+			@param ctx context.Context - execution context
+			@param key string - key
+			@param value any - value
+			@param timestamp time.Time - record timestamp
+			@param activeDBClient Database - existing database transaction
+			@returns the record and record version entry
+	*/
+	RecordKeyObject(
+		ctx context.Context, key string, value any, timestamp time.Time, activeDBClient db.Database,
+	) (models.Record, models.RecordVersion, error)
+
+	/*
+		GetKeyObject get the structured value of a key at a particular version by ID
+
+		The decrypted value is deserialized with the store's ValueCodec.
+
+			@param ctx context.Context - execution context
+			@param versionID string - the version ID
+			@param value any - pointer to the object to populate
+			@param activeDBClient Database - existing database transaction
+	*/
+	GetKeyObject(
+		ctx context.Context, versionID string, value any, activeDBClient db.Database,
+	) error
+
+	/*
+		ExportKeyHistory write the record and every version of a key as JSON
+
+			@param ctx context.Context - execution context
+			@param key string - key
+			@param w io.Writer - the export destination
+			@param opts ExportOptions - export options
+			@param activeDBClient Database - existing database transaction
+	*/
+	ExportKeyHistory(
+		ctx context.Context,
+		key string,
+		w io.Writer,
+		opts ExportOptions,
+		activeDBClient db.Database,
+	) error
+
+	/*
+		ExportEncrypted write a backup of the whole store. The values and the encryption key
+		material remain encrypted, so the backup is as protected as the database.
+
+			@param ctx context.Context - execution context
+			@param w io.Writer - the backup destination
+			@param activeDBClient Database - existing database transaction
+	*/
+	ExportEncrypted(ctx context.Context, w io.Writer, activeDBClient db.Database) error
+
+	/*
+		ImportEncrypted restore a backup written by ExportEncrypted. The database must not
+		hold any data records. The encryption keys of the backup can only be used if the
+		store's RSA key pairs include the one which encrypted them.
+
+			@param ctx context.Context - execution context
+			@param r io.Reader - the backup source
+			@param activeDBClient Database - existing database transaction
+	*/
+	ImportEncrypted(ctx context.Context, r io.Reader, activeDBClient db.Database) error
+
+	/*
+		ImportPlaintext record the key value pairs read from JSON lines of PlaintextEntry.
+		Each pair is recorded as a new version of its key.
+
+			@param ctx context.Context - execution context
+			@param r io.Reader - the import source
+			@param activeDBClient Database - existing database transaction
+			@returns number of key value pairs recorded
+	*/
+	ImportPlaintext(ctx context.Context, r io.Reader, activeDBClient db.Database) (int, error)
+
+	/*
+		ExportPlaintext write the decrypted newest value of each key of the context's
+		namespace as JSON lines of PlaintextEntry. Keys whose newest version has expired are
+		skipped.
+
+		Before writing anything, the export verifies that every value is encrypted with an
+		active encryption key the engine can use. A value which still fails to decrypt aborts
+		the export, leaving the output incomplete.
+
+			@param ctx context.Context - execution context
+			@param w io.Writer - the export destination
+			@param keyPrefix string - export only keys starting with this prefix. Empty for all.
+			@param activeDBClient Database - existing database transaction
+	*/
+	ExportPlaintext(
+		ctx context.Context, w io.Writer, keyPrefix string, activeDBClient db.Database,
+	) error
+
+	/*
+		VerifyIntegrity attempt to decrypt every record version matching the filter, reporting
+		which ones fail and why. A failure does not stop the check. The decrypted values are
+		discarded. The pagination parameters of the filter are ignored.
+
+			@param ctx context.Context - execution context
+			@param filter db.RecordVersionQueryFilter - the record versions to check
+			@param activeDBClient Database - existing database transaction
+			@returns the check results
+	*/
+	VerifyIntegrity(
+		ctx context.Context, filter db.RecordVersionQueryFilter, activeDBClient db.Database,
+	) (IntegrityReport, error)
+
+	/*
+		RekeyRecord re-encrypt every version of a key with the working encryption key.
+		Versions already encrypted with the working key are skipped.
+
+			@param ctx context.Context - execution context
+			@param key string - key
+			@param activeDBClient Database - existing database transaction
+			@returns number of versions re-encrypted
+	*/
+	RekeyRecord(ctx context.Context, key string, activeDBClient db.Database) (int, error)
+
+	/*
+		ReencryptRecord re-encrypt every version of a key with a specific encryption key,
+		which must be active. Versions already encrypted with that key are skipped.
+
+			@param ctx context.Context - execution context
+			@param key string - key
+			@param targetKeyID string - the encryption key to re-encrypt with
+			@param activeDBClient Database - existing database transaction
+			@returns number of versions re-encrypted
+	*/
+	ReencryptRecord(
+		ctx context.Context, key string, targetKeyID string, activeDBClient db.Database,
+	) (int, error)
+
+	/*
+		PruneRecordVersions delete all but the newest versions of a data record
+
+			@param ctx context.Context - execution context
+			@param record models.Record - the data record
+			@param keepLatest int - number of newest versions to keep. Must be at least 1.
+			@param activeDBClient Database - existing database transaction
+			@returns number of versions deleted
+	*/
+	PruneRecordVersions(
+		ctx context.Context, record models.Record, keepLatest int, activeDBClient db.Database,
+	) (int, error)
+
+	/*
+		CountKeys count the keys of the context's namespace in storage
+
+			@param ctx context.Context - execution context
+			@param activeDBClient Database - existing database transaction
+			@returns number of keys
+	*/
+	CountKeys(ctx context.Context, activeDBClient db.Database) (int64, error)
+
+	/*
+		ListKeys list the keys of the context's namespace, ordered by name
+
+			@param ctx context.Context - execution context
+			@param keyPrefix string - list only keys starting with this prefix. Empty for all.
+			@param activeDBClient Database - existing database transaction
+			@returns the data records of the keys
+	*/
+	ListKeys(
+		ctx context.Context, keyPrefix string, activeDBClient db.Database,
+	) ([]models.Record, error)
+
+	/*
+		ListKeysWithMetadata list the keys of the context's namespace matching the filter,
+		each with when its newest version was recorded and how many versions it has. The
+		namespace of the filter is ignored.
+
+			@param ctx context.Context - execution context
+			@param filter db.RecordQueryFilter - the keys to list
+			@param activeDBClient Database - existing database transaction
+			@returns the keys
+	*/
+	ListKeysWithMetadata(
+		ctx context.Context, filter db.RecordQueryFilter, activeDBClient db.Database,
+	) ([]KeyInfo, error)
+
+	/*
+		DeleteKey delete a key from storage
+
+			@param ctx context.Context - execution context
+			@param key string - key
+			@param activeDBClient Database - existing database transaction
+	*/
+	DeleteKey(ctx context.Context, key string, activeDBClient db.Database) error
+
+	/*
+		RenameKey rename a key. Every version of the key is kept, and remains readable under
+		the new name.
+
+			@param ctx context.Context - execution context
+			@param oldKey string - current key
+			@param newKey string - new key. It must not already exist.
+			@param activeDBClient Database - existing database transaction
+	*/
+	RenameKey(ctx context.Context, oldKey string, newKey string, activeDBClient db.Database) error
+
+	/*
+		ScanAll stream each key of the context's namespace matching the filter, with its
+		newest decrypted value. Keys are read in pages of bounded size, so memory use does
+		not grow with the size of the store. Keys without versions, or whose newest version
+		has expired, are skipped. The namespace, pagination, and ordering parameters of the
+		filter are ignored; keys are delivered in name order.
+
+		The channel is closed when the scan ends, after which the returned function reports
+		the error which ended it, if any. The caller must either drain the channel or cancel
+		the context; the scan holds its database session open until it ends. A caller
+		provided transaction must not be used by the caller until the scan ends.
+
+			@param ctx context.Context - execution context. Cancelling it stops the scan.
+			@param filter db.RecordQueryFilter - the keys to scan
+			@param activeDBClient Database - existing database transaction
+			@returns the item channel, and a function reporting the error which ended the scan
+	*/
+	ScanAll(
+		ctx context.Context, filter db.RecordQueryFilter, activeDBClient db.Database,
+	) (<-chan ScanItem, func() error, error)
+
+	/*
+		DeleteKeyVersion delete one version of a key. The only remaining version of a key is
+		only deleted when forced; the key itself remains. Watchers see the deletion of the
+		newest version as an update to the version now newest, or as the deletion of the key
+		once no versions remain.
+
+			@param ctx context.Context - execution context
+			@param key string - key
+			@param versionID string - the version to delete. It must belong to the key.
+			@param force bool - whether to delete the only remaining version of the key
+			@param activeDBClient Database - existing database transaction
+	*/
+	DeleteKeyVersion(
+		ctx context.Context, key string, versionID string, force bool, activeDBClient db.Database,
+	) error
+
+	/*
+		Watch subscribe to changes of keys matching a prefix
+
+		An event is delivered whenever a matching key of the context's namespace is created,
+		updated, or deleted through this store instance, once the change commits. A change
+		made within a caller provided transaction is delivered once that transaction commits,
+		and never if it rolls back. Events come from this store instance alone; changes made
+		through other store instances, even on the same database, are not delivered. Should
+		the subscriber fall behind, events are dropped, and a WatchEventTypeLagged event
+		marks the gap; re-read the watched keys to catch up. The subscription ends, and the
+		channel is closed, when the context is cancelled.
+
+			@param ctx context.Context - subscription context
+			@param keyPrefix string - key prefix to watch. Empty to watch all keys.
+			@returns the event channel
+	*/
+	Watch(ctx context.Context, keyPrefix string) (<-chan WatchEvent, error)
+
+	/*
+		RefreshWorkingKey select the working encryption key again, following the store's
+		working key policy. Use after the encryption keys are rotated elsewhere.
+
+			@param ctx context.Context - execution context
+			@param activeDBClient Database - existing database transaction
+	*/
+	RefreshWorkingKey(ctx context.Context, activeDBClient db.Database) error
+
+	/*
+		Bootstrap record the first value of a new store, and mark the system RUNNING, in one
+		transaction. ErrKeyExists is returned if the key already exists.
+
+			@param ctx context.Context - execution context
+			@param key string - key
+			@param value []byte - value
+			@param activeDBClient Database - existing database transaction
+			@returns the record version entry
+	*/
+	Bootstrap(
+		ctx context.Context, key string, value []byte, activeDBClient db.Database,
+	) (models.RecordVersion, error)
+
+	/*
+		HealthCheck verify the store can serve requests: the database is reachable, and the
+		working encryption key can still encrypt and decrypt. Fails with
+		ErrDatabaseUnavailable or ErrWorkingKeyUnavailable respectively.
+
+			@param ctx context.Context - execution context
+	*/
+	HealthCheck(ctx context.Context) error
+
+	/*
+		SystemState fetch the system operating state. Keys can only be written while the
+		system is RUNNING.
+
+			@param ctx context.Context - execution context
+			@param activeDBClient Database - existing database transaction
+			@returns the system state
+	*/
+	SystemState(
+		ctx context.Context, activeDBClient db.Database,
+	) (models.SystemStateENUMType, error)
+
+	/*
+		Close release the store's resources. The decrypted encryption keys are zeroed, and the
+		database connection is closed.
+	*/
+	Close() error
+}
+
+// KeyDetail a key, the metadata of its versions, and its current value
+type KeyDetail struct {
+	// Record the key's data record
+	Record models.Record
+	// Versions metadata of every version of the key, newest first. The encrypted values are
+	// omitted.
+	Versions []models.RecordVersion
+	// LatestValue the decrypted value of the newest version. Nil if the newest version has
+	// expired.
+	LatestValue []byte
+}
+
+// KeyInfo a key, with statistics of its versions
+type KeyInfo struct {
+	// Name the key
+	Name string
+	// RecordID the ID of the key's data record
+	RecordID string
+	// CreatedAt when the key was created
+	CreatedAt time.Time
+	// LatestVersionAt when the newest version of the key was recorded. Nil if the key has
+	// no versions.
+	LatestVersionAt *time.Time
+	// VersionCount number of versions of the key
+	VersionCount int64
+}
+
+// ValueResult the result of reading the newest value of one key of a batch
+type ValueResult struct {
+	// Version the newest version of the key
+	Version models.RecordVersion
+	// Value the decrypted value of the newest version
+	Value []byte
+	// Err why the key could not be read. Nil on success.
+	Err error
+}
+
+// protectedKVStore implements ProtectedKVStore
+type protectedKVStore struct {
+	goutils.Component
+
+	persistence db.Client
+
+	cryptoEngine encryption.CryptographyEngine
+
+	options ProtectedKVStoreOptions
+
+	// workingKey the encryption key new values are encrypted with. Access through
+	// getWorkingKey.
+	workingKey     models.EncryptionKey
+	workingKeyLock sync.RWMutex
+
+	watchers *watchHub
+
+	// coalescer remembers recent writes to coalesce repeated ones. Nil if writes are not
+	// coalesced.
+	coalescer *writeCoalescer
+
+	// metrics the store metrics trackers. Nil if metrics are not tracked.
+	metrics *storeMetrics
+}
+
+// WorkingKeyModeENUMType working encryption key selection mode ENUM
+type WorkingKeyModeENUMType string
+
+const (
+	// WorkingKeyModeUseNewest use the newest active encryption key, defining a new key if
+	// there are none
+	WorkingKeyModeUseNewest WorkingKeyModeENUMType = "USE_NEWEST"
+	// WorkingKeyModeUseSpecific use a specific active encryption key
+	WorkingKeyModeUseSpecific WorkingKeyModeENUMType = "USE_SPECIFIC"
+	// WorkingKeyModeAlwaysCreateNew define a new encryption key every time the store starts
+	WorkingKeyModeAlwaysCreateNew WorkingKeyModeENUMType = "ALWAYS_CREATE_NEW"
+)
+
+// WorkingKeyPolicy how the store selects the working encryption key, which new values are
+// encrypted with
+type WorkingKeyPolicy struct {
+	// Mode the selection mode. Defaults to WorkingKeyModeUseNewest.
+	Mode WorkingKeyModeENUMType
+	// KeyID the encryption key to use with WorkingKeyModeUseSpecific
+	KeyID string
+}
+
+// UseNewestKey policy selecting the newest active encryption key
+func UseNewestKey() WorkingKeyPolicy {
+	return WorkingKeyPolicy{Mode: WorkingKeyModeUseNewest}
+}
+
+// UseSpecificKey policy selecting a specific encryption key, which must be active
+func UseSpecificKey(keyID string) WorkingKeyPolicy {
+	return WorkingKeyPolicy{Mode: WorkingKeyModeUseSpecific, KeyID: keyID}
+}
+
+// AlwaysCreateNewKey policy defining a new encryption key every time the store starts
+func AlwaysCreateNewKey() WorkingKeyPolicy {
+	return WorkingKeyPolicy{Mode: WorkingKeyModeAlwaysCreateNew}
+}
+
+// ProtectedKVStoreOptions protected KV store behavior options
+type ProtectedKVStoreOptions struct {
+	// WorkingKeyPolicy how the working encryption key is selected. Defaults to
+	// UseNewestKey.
+	WorkingKeyPolicy WorkingKeyPolicy
+
+	// AutoInitSystemState whether to drive the system state to RUNNING once the store
+	// finishes provisioning its working encryption key. Nil defaults to true. When false,
+	// writes are refused with ErrSystemNotReady until Bootstrap is called.
+	AutoInitSystemState *bool
+
+	// Codec serializes the values passed to RecordKeyObject / GetKeyObject. Defaults to
+	// JSONValueCodec.
+	Codec ValueCodec
+
+	// TrackReads whether reading a value updates the LastReadAt of its record. This costs a
+	// write per read.
+	TrackReads bool
+
+	// MaxCumulativeBytesPerRecord the most encrypted data, in bytes, the versions of one key
+	// may hold together. Zero for no limit.
+	MaxCumulativeBytesPerRecord int64
+
+	// PruneToFitBudget whether a write which would exceed MaxCumulativeBytesPerRecord
+	// deletes the oldest versions of the key to make room, instead of being rejected
+	PruneToFitBudget bool
+
+	// MaxVersionsPerKey the most versions one key keeps. After each write, the oldest
+	// versions beyond this are deleted. Zero for no limit.
+	MaxVersionsPerKey int
+
+	// DeriveRecordSubkeys whether new values are encrypted with a subkey derived, through
+	// HKDF-SHA256, from the working encryption key and the data record ID. Values written
+	// before the option was enabled remain readable.
+	DeriveRecordSubkeys bool
+
+	// WriteCoalescingWindow writes of the same value to a key within this window of the
+	// previous write are coalesced into it: no new version is recorded, and the previous
+	// version is returned. Only plain writes, without a TTL or note, through this store
+	// instance and outside of a caller provided transaction are remembered, and a write is
+	// only coalesced while the remembered version is still the newest of the key. Zero to
+	// record every write.
+	WriteCoalescingWindow time.Duration
+
+	// Metrics registry to register the store's Prometheus metrics with. Nil to not track
+	// metrics. Stores made through the haven package register their cryptography engine's
+	// metrics with it as well.
+	Metrics prometheus.Registerer
+}
+
+/*
+NewProtectedKVStore define new protected KV store
+
+Once the working encryption key is prepared, a system still being set up is driven
+through initialization to RUNNING within the same transaction, unless
+AutoInitSystemState is false.
+
+	@param ctx context.Context - execution context
+	@param persistence db.Client - persistence layer client
+	@param cryptoEngine encryption.CryptographyEngine - cryptography engine
+	@param options ProtectedKVStoreOptions - store behavior options
+	@returns store instance
+*/
+func NewProtectedKVStore(
+	ctx context.Context,
+	persistence db.Client,
+	cryptoEngine encryption.CryptographyEngine,
+	options ProtectedKVStoreOptions,
+) (ProtectedKVStore, error) {
+	logTags := log.Fields{"package": "haven", "module": "store", "component": "protected-kv-store"}
+
+	if options.Codec == nil {
+		options.Codec = JSONValueCodec{}
+	}
+
+	instance := &protectedKVStore{
+		Component: goutils.Component{
+			LogTags: logTags,
+			LogTagModifiers: []goutils.LogMetadataModifier{
+				goutils.ModifyLogMetadataByRestRequestParam,
+			},
+		},
+		persistence:  persistence,
+		cryptoEngine: cryptoEngine,
+		options:      options,
+		watchers:     &watchHub{},
+	}
+
+	var err error
+	if instance.metrics, err = newStoreMetrics(options.Metrics); err != nil {
+		return nil, err
+	}
+
+	if options.WriteCoalescingWindow > 0 {
+		if instance.coalescer, err = newWriteCoalescer(options.WriteCoalescingWindow); err != nil {
+			return nil, fmt.Errorf("failed to prepare write coalescing [%w]", err)
+		}
+	}
+
+	// Prepare the working encryption key
+	if dbErr := persistence.UseDatabaseInTransaction(
+		ctx, func(dbCtx context.Context, dbClient db.Database) error {
+			// Only one store at a time may select the working key, so concurrently started
+			// stores do not each define a new key
+			if err := dbClient.LockSystemParams(dbCtx); err != nil {
+				return fmt.Errorf("failed to lock system parameters [%w]", err)
+			}
+
+			var err error
+			instance.workingKey, err = selectWorkingKey(
+				dbCtx, cryptoEngine, options.WorkingKeyPolicy, dbClient,
+			)
+			if err != nil {
+				return err
+			}
+
+			if options.AutoInitSystemState == nil || *options.AutoInitSystemState {
+				if err := markSystemRunning(dbCtx, dbClient); err != nil {
+					return fmt.Errorf("failed to initialize system state [%w]", err)
+				}
+			}
+
+			return nil
+		},
+	); dbErr != nil {
+		return nil, fmt.Errorf("failed to prepare working encryption key [%w]", dbErr)
+	}
+
+	return instance, nil
+}
+
+// getWorkingKey fetch the current working encryption key
+func (s *protectedKVStore) getWorkingKey() models.EncryptionKey {
+	s.workingKeyLock.RLock()
+	defer s.workingKeyLock.RUnlock()
+	return s.workingKey
+}
+
+/*
+RefreshWorkingKey select the working encryption key again, following the store's working
+key policy. Use after the encryption keys are rotated elsewhere.
+
+	@param ctx context.Context - execution context
+	@param activeDBClient Database - existing database transaction
+*/
+func (s *protectedKVStore) RefreshWorkingKey(
+	ctx context.Context, activeDBClient db.Database,
+) error {
+	if dbErr := s.inSession(
+		ctx, "refresh_working_key", activeDBClient,
+		func(dbCtx context.Context, dbClient db.Database) error {
+			// Serialize with other stores selecting their working key
+			if err := dbClient.LockSystemParams(dbCtx); err != nil {
+				return fmt.Errorf("failed to lock system parameters [%w]", err)
+			}
+
+			workingKey, err := selectWorkingKey(
+				dbCtx, s.cryptoEngine, s.options.WorkingKeyPolicy, dbClient,
+			)
+			if err != nil {
+				return err
+			}
+
+			s.workingKeyLock.Lock()
+			defer s.workingKeyLock.Unlock()
+			s.workingKey = workingKey
+			return nil
+		},
+	); dbErr != nil {
+		return fmt.Errorf("failed to refresh working encryption key [%w]", dbErr)
+	}
+	return nil
+}
+
+// selectWorkingKey select the working encryption key according to the policy
+func selectWorkingKey(
+	ctx context.Context,
+	cryptoEngine encryption.CryptographyEngine,
+	policy WorkingKeyPolicy,
+	dbClient db.Database,
+) (models.EncryptionKey, error) {
+	switch policy.Mode {
+	case "", WorkingKeyModeUseNewest:
+		// Only fetch the newest active key. Listing loads the listed keys into the engine,
+		// which costs an RSA decryption per key; the other active keys are loaded on demand
+		// when a value encrypted with them is read.
+		newestOnly := 1
+		activeKeys, err := cryptoEngine.ListEncryptionKeys(
+			ctx,
+			db.EncryptionKeyQueryFilter{
+				CommonListEntryQueryFilter: db.CommonListEntryQueryFilter{Limit: &newestOnly},
+				TargetState:                []models.EncryptionKeyStateENUMType{models.EncryptionKeyStateActive},
+			},
+			dbClient,
+		)
+		if err != nil {
+			return models.EncryptionKey{}, fmt.Errorf(
+				"failed to list active encryption keys [%w]", err,
+			)
+		}
+		if len(activeKeys) > 0 {
+			return activeKeys[0], nil
+		}
+		// No active keys, so make a new one
+
+	case WorkingKeyModeUseSpecific:
+		if policy.KeyID == "" {
+			return models.EncryptionKey{}, fmt.Errorf("working key policy names no encryption key")
+		}
+		key, err := cryptoEngine.GetEncryptionKey(ctx, policy.KeyID, dbClient)
+		if err != nil {
+			return models.EncryptionKey{}, fmt.Errorf(
+				"failed to fetch working encryption key %s [%w]", policy.KeyID, err,
+			)
+		}
+		if key.State != models.EncryptionKeyStateActive {
+			return models.EncryptionKey{}, fmt.Errorf(
+				"working encryption key %s is %s", policy.KeyID, key.State,
+			)
+		}
+		return key, nil
+
+	case WorkingKeyModeAlwaysCreateNew:
+		// Make a new key
+
+	default:
+		return models.EncryptionKey{}, fmt.Errorf("unknown working key policy '%s'", policy.Mode)
+	}
+
+	key, err := cryptoEngine.NewEncryptionKey(ctx, dbClient)
+	if err != nil {
+		return models.EncryptionKey{}, fmt.Errorf("failed to define new encryption key [%w]", err)
+	}
+	return key, nil
+}
+
+/*
+Bootstrap record the first value of a new store, and mark the system RUNNING, in one
+transaction. ErrKeyExists is returned if the key already exists.
+
+	@param ctx context.Context - execution context
+	@param key string - key
+	@param value []byte - value
+	@param activeDBClient Database - existing database transaction
+	@returns the record version entry
+*/
+func (s *protectedKVStore) Bootstrap(
+	ctx context.Context, key string, value []byte, activeDBClient db.Database,
+) (models.RecordVersion, error) {
+	var versionEntry models.RecordVersion
+	if dbErr := s.inSession(
+		ctx, "bootstrap", activeDBClient, func(dbCtx context.Context, dbClient db.Database) error {
+			// Serialize with stores selecting their working key. Should the working key have
+			// been deactivated since, recording the value selects a new one.
+			if err := dbClient.LockSystemParams(dbCtx); err != nil {
+				return fmt.Errorf("failed to lock system parameters [%w]", err)
+			}
+
+			if err := markSystemRunning(dbCtx, dbClient); err != nil {
+				return fmt.Errorf("failed to initialize system state [%w]", err)
+			}
+
+			var err error
+			_, versionEntry, err = s.recordKeyValue(
+				dbCtx, key, value, time.Now().UTC(), nil, true, "", dbClient,
+			)
+			return err
+		},
+	); dbErr != nil {
+		return models.RecordVersion{}, fmt.Errorf("failed to bootstrap store [%w]", dbErr)
+	}
+
+	return versionEntry, nil
+}
+
+// requireSystemRunning return ErrSystemNotReady unless the system is RUNNING
+func requireSystemRunning(ctx context.Context, dbClient db.Database) error {
+	params, err := dbClient.GetSystemParamEntry(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read system parameters [%w]", err)
+	}
+	if params.State != models.SystemStateRunning {
+		return fmt.Errorf("system is %s [%w]", params.State, ErrSystemNotReady)
+	}
+	return nil
+}
+
+// markSystemRunning drive the system state through initialization to RUNNING
+func markSystemRunning(ctx context.Context, dbClient db.Database) error {
+	params, err := dbClient.GetSystemParamEntry(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read system parameters [%w]", err)
+	}
+
+	if params.State == models.SystemStateRunning {
+		// NOOP
+		return nil
+	}
+
+	if err := dbClient.MarkSystemInitializing(ctx); err != nil {
+		return fmt.Errorf("failed to mark system initializing [%w]", err)
+	}
+
+	if err := dbClient.MarkSystemInitialized(ctx); err != nil {
+		return fmt.Errorf("failed to mark system initialized [%w]", err)
+	}
+
+	return nil
+}
+
+/*
+RecordKeyValue record a key value pair
+
+	@param ctx context.Context - execution context
+	@param key string - key
+	@param value []byte - value
+	@param timestamp time.Time - record timestamp
+	@param activeDBClient Database - existing database transaction
+	@returns the record and record version entry
+*/
+func (s *protectedKVStore) RecordKeyValue(
+	ctx context.Context, key string, value []byte, timestamp time.Time, activeDBClient db.Database,
+) (models.Record, models.RecordVersion, error) {
+	return s.recordKeyValue(ctx, key, value, timestamp, nil, false, "", activeDBClient)
+}
+
+/*
+CreateKeyWithValue record a new key with its first value. Unlike RecordKeyValue,
+ErrKeyExists is returned if the key already exists.
+
+	@param ctx context.Context - execution context
+	@param key string - key
+	@param value []byte - value
+	@param timestamp time.Time - record timestamp
+	@param activeDBClient Database - existing database transaction
+	@returns the record and record version entry
+*/
+func (s *protectedKVStore) CreateKeyWithValue(
+	ctx context.Context, key string, value []byte, timestamp time.Time, activeDBClient db.Database,
+) (models.Record, models.RecordVersion, error) {
+	return s.recordKeyValue(ctx, key, value, timestamp, nil, true, "", activeDBClient)
+}
+
+/*
+RecordKeyValueWithTTL record a key value pair which expires after a duration
+
+	@param ctx context.Context - execution context
+	@param key string - key
+	@param value []byte - value
+	@param timestamp time.Time - record timestamp
+	@param ttl time.Duration - the version expires at timestamp + ttl
+	@param activeDBClient Database - existing database transaction
+	@returns the record and record version entry
+*/
+func (s *protectedKVStore) RecordKeyValueWithTTL(
+	ctx context.Context,
+	key string,
+	value []byte,
+	timestamp time.Time,
+	ttl time.Duration,
+	activeDBClient db.Database,
+) (models.Record, models.RecordVersion, error) {
+	if ttl <= 0 {
+		return models.Record{},
+			models.RecordVersion{},
+			fmt.Errorf("key '%s' TTL must be positive, got %s", key, ttl)
+	}
+	expiresAt := timestamp.Add(ttl)
+	return s.recordKeyValue(ctx, key, value, timestamp, &expiresAt, false, "", activeDBClient)
+}
+
+/*
+RecordKeyValueWithNote record a key value pair, noting the reason for the change on the
+new version
+
+	@param ctx context.Context - execution context
+	@param key string - key
+	@param value []byte - value
+	@param timestamp time.Time - record timestamp
+	@param note string - plain text reason for the change
+	@param activeDBClient Database - existing database transaction
+	@returns the record and record version entry
+*/
+func (s *protectedKVStore) RecordKeyValueWithNote(
+	ctx context.Context,
+	key string,
+	value []byte,
+	timestamp time.Time,
+	note string,
+	activeDBClient db.Database,
+) (models.Record, models.RecordVersion, error) {
+	return s.recordKeyValue(ctx, key, value, timestamp, nil, false, note, activeDBClient)
+}
+
+// recordKeyValue core function for recording a key value pair. If createOnly, the key
+// must not already exist.
+func (s *protectedKVStore) recordKeyValue(
+	ctx context.Context,
+	key string,
+	value []byte,
+	timestamp time.Time,
+	expiresAt *time.Time,
+	createOnly bool,
+	note string,
+	activeDBClient db.Database,
+) (models.Record, models.RecordVersion, error) {
+	var recordEntry models.Record
+	var versionEntry models.RecordVersion
+	eventType := WatchEventTypeUpdated
+	namespace := NamespaceFromContext(ctx)
+
+	// Only plain writes are coalesced
+	coalesce := s.coalescer != nil && expiresAt == nil && !createOnly && note == ""
+	coalesced := false
+
+	ctx, span := tracer.Start(ctx, "store.RecordKeyValue")
+	defer span.End()
+
+	if dbErr := s.inSession(
+		ctx, "record", activeDBClient, func(dbCtx context.Context, dbClient db.Database) error {
+			if err := requireSystemRunning(dbCtx, dbClient); err != nil {
+				return err
+			}
+
+			var err error
+
+			// Prepare data record
+			recordEntry, err = dbClient.GetRecordByName(dbCtx, namespace, key)
+			if err == nil && createOnly {
+				return ErrKeyExists
+			}
+			if err != nil && !errors.Is(err, db.ErrRecordNotFound) {
+				return fmt.Errorf("failed to find key '%s' [%w]", key, err)
+			}
+			if err == nil && coalesce {
+				record, version, ok := s.coalescer.lookup(namespace, key, value, time.Now())
+				if ok && record.ID == recordEntry.ID {
+					// Other stores may have written the key since this store last did
+					latest, err := dbClient.GetLatestVersionsOfRecords(dbCtx, []string{record.ID})
+					if err != nil {
+						return fmt.Errorf("failed to find key '%s' latest version [%w]", key, err)
+					}
+					if latestVersion, ok := latest[record.ID]; ok && latestVersion.ID == version.ID {
+						versionEntry, coalesced = version, true
+						return nil
+					}
+				}
+			}
+			if err != nil {
+				// Make a new record
+				recordEntry, err = dbClient.DefineNewRecord(dbCtx, namespace, key)
+				switch {
+				case errors.Is(err, db.ErrDuplicateRecordName) && createOnly:
+					return ErrKeyExists
+				case errors.Is(err, db.ErrDuplicateRecordName):
+					// Another writer created the key after it was looked up
+					recordEntry, err = dbClient.GetRecordByName(dbCtx, namespace, key)
+					if err != nil {
+						return fmt.Errorf("failed to find key '%s' [%w]", key, err)
+					}
+				case err != nil:
+					return fmt.Errorf("failed to define new data record [%w]", err)
+				default:
+					eventType = WatchEventTypeCreated
+				}
+			}
+			span.SetAttributes(attrRecordID.String(recordEntry.ID))
+
+			// Encrypt the data, binding it to the record
+			subkeyInfo, keyDerivation := s.recordKeyDerivation(recordEntry.ID)
+			theKey, encrypted, err := s.encryptWithWorkingKey(
+				dbCtx, value, models.RecordAAD(recordEntry.ID), subkeyInfo, dbClient,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to encryption record value [%w]", err)
+			}
+
+			if err := s.enforceRecordBudget(
+				dbCtx, recordEntry, int64(len(encrypted.CipherText)), dbClient,
+			); err != nil {
+				return err
+			}
+
+			span.SetAttributes(attrEncryptionKeyID.String(theKey.ID))
+
+			// Prepare new version
+			insertCtx, insertSpan := tracer.Start(
+				dbCtx,
+				"db.DefineNewVersionForRecord",
+				trace.WithAttributes(
+					attrRecordID.String(recordEntry.ID), attrEncryptionKeyID.String(theKey.ID),
+				),
+			)
+			versionEntry, err = dbClient.DefineNewVersionForRecord(
+				insertCtx,
+				recordEntry,
+				theKey,
+				encrypted.CipherText,
+				encrypted.Nonce,
+				timestamp,
+				db.NewVersionOptions{
+					ExpiresAt:     expiresAt,
+					AADBound:      true,
+					KeyDerivation: keyDerivation,
+					Note:          note,
+				},
+			)
+			if err != nil {
+				insertSpan.RecordError(err)
+				insertSpan.SetStatus(codes.Error, "record version insert failed")
+			}
+			insertSpan.End()
+			if err != nil {
+				return fmt.Errorf("failed to insert new record version [%w]", err)
+			}
+
+			if s.options.MaxVersionsPerKey > 0 {
+				if _, err := s.pruneRecordVersions(
+					dbCtx, recordEntry, s.options.MaxVersionsPerKey, dbClient,
+				); err != nil {
+					return err
+				}
+			}
+
+			// A caller provided transaction may still roll back the write
+			recordID, versionID := recordEntry.ID, versionEntry.ID
+			dbClient.AfterCommit(func() {
+				s.watchers.publish(WatchEvent{
+					Type:      eventType,
+					Namespace: namespace,
+					Key:       key,
+					RecordID:  recordID,
+					VersionID: versionID,
+					Timestamp: time.Now().UTC(),
+				}, s.LogTags)
+			})
+
+			return nil
+		},
+	); dbErr != nil {
+		span.RecordError(dbErr)
+		span.SetStatus(codes.Error, "recording key value failed")
+		return models.Record{},
+			models.RecordVersion{},
+			fmt.Errorf("failed to record key '%s' [%w]", key, dbErr)
+	}
+
+	if coalesced {
+		return recordEntry, versionEntry, nil
+	}
+
+	if s.coalescer != nil {
+		// A caller provided transaction may still roll back the write
+		if coalesce && activeDBClient == nil {
+			s.coalescer.remember(namespace, key, value, time.Now(), recordEntry, versionEntry)
+		} else {
+			s.coalescer.forget(namespace, key)
+		}
+	}
+
+	return recordEntry, versionEntry, nil
+}
+
+// recordKeyDerivation the subkey info and key derivation scheme to encrypt new values of
+// a data record with
+func (s *protectedKVStore) recordKeyDerivation(
+	recordID string,
+) ([]byte, models.KeyDerivationENUMType) {
+	if !s.options.DeriveRecordSubkeys {
+		return nil, models.KeyDerivationNone
+	}
+	return models.RecordSubkeyInfo(recordID), models.KeyDerivationHKDFSHA256
+}
+
+// encryptWithWorkingKey encrypt a value with the working encryption key. If the working
+// key was deactivated elsewhere, a new working key is selected, and the encryption retried.
+func (s *protectedKVStore) encryptWithWorkingKey(
+	ctx context.Context, value []byte, aad []byte, subkeyInfo []byte, dbClient db.Database,
+) (models.EncryptionKey, encryption.EncryptedData, error) {
+	theKey, encrypted, err := s.cryptoEngine.EncryptData(
+		ctx, s.getWorkingKey().ID, value, aad, subkeyInfo, dbClient,
+	)
+	if !errors.Is(err, encryption.ErrKeyNotActive) {
+		return theKey, encrypted, err
+	}
+
+	if err := s.RefreshWorkingKey(ctx, dbClient); err != nil {
+		return models.EncryptionKey{}, encryption.EncryptedData{}, err
+	}
+	return s.cryptoEngine.EncryptData(
+		ctx, s.getWorkingKey().ID, value, aad, subkeyInfo, dbClient,
+	)
+}
+
+// enforceRecordBudget verify the data record has room for a new version holding newSize
+// bytes of encrypted data, deleting its oldest versions if the options allow
+func (s *protectedKVStore) enforceRecordBudget(
+	ctx context.Context, record models.Record, newSize int64, dbClient db.Database,
+) error {
+	budget := s.options.MaxCumulativeBytesPerRecord
+	if budget <= 0 {
+		return nil
+	}
+	if newSize > budget {
+		return fmt.Errorf(
+			"key '%s' value needs %d bytes, over the budget of %d [%w]",
+			record.Name,
+			newSize,
+			budget,
+			ErrRecordBudgetExceeded,
+		)
+	}
+
+	used, err := dbClient.SumRecordVersionSizes(ctx, record.ID)
+	if err != nil {
+		return fmt.Errorf("failed to size key '%s' versions [%w]", record.Name, err)
+	}
+	if used+newSize <= budget {
+		return nil
+	}
+	if !s.options.PruneToFitBudget {
+		return fmt.Errorf(
+			"key '%s' holds %d bytes, adding %d bytes is over the budget of %d [%w]",
+			record.Name,
+			used,
+			newSize,
+			budget,
+			ErrRecordBudgetExceeded,
+		)
+	}
+
+	// Delete the oldest versions until the new version fits
+	versions, err := dbClient.ListVersionsOfOneRecord(
+		ctx, record, db.RecordVersionQueryFilter{SortAscending: true},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to list key %s versions [%w]", record.ID, err)
+	}
+	for _, version := range versions {
+		if used+newSize <= budget {
+			break
+		}
+		if err := dbClient.DeleteRecordVersion(ctx, version.ID); err != nil {
+			return fmt.Errorf("failed to prune key '%s' version [%w]", record.Name, err)
+		}
+		used -= int64(len(version.EncValue))
+		log.WithFields(s.LogTags).
+			WithField("key", record.Name).
+			WithField("version", version.ID).
+			Debug("Pruned key version to fit the size budget")
+	}
+	return nil
+}
+
+/*
+ListKeyVersions list the versions of a key
+
+	@param ctx context.Context - execution context
+	@param key string - key
+	@param activeDBClient Database - existing database transaction
+	@returns the record and its associated versions
+*/
+func (s *protectedKVStore) ListKeyVersions(
+	ctx context.Context, key string, activeDBClient db.Database,
+) (models.Record, []models.RecordVersion, error) {
+	var recordEntry models.Record
+	var versionEntries []models.RecordVersion
+
+	if dbErr := s.inSession(
+		ctx, "list_versions", activeDBClient,
+		func(dbCtx context.Context, dbClient db.Database) error {
+			var err error
+
+			// Prepare data record
+			recordEntry, err = dbClient.GetRecordByName(dbCtx, NamespaceFromContext(dbCtx), key)
+			if err != nil {
+				return fmt.Errorf("failed to find key '%s' [%w]", key, err)
+			}
+
+			versionEntries, err = dbClient.ListVersionsOfOneRecord(
+				dbCtx, recordEntry, db.RecordVersionQueryFilter{},
+			)
+			if err != nil {
+				return fmt.Errorf("failed to list key %s versions [%w]", recordEntry.ID, err)
+			}
+
+			return nil
+		},
+	); dbErr != nil {
+		return models.Record{}, nil, fmt.Errorf("failed to list key '%s' versions [%w]", key, dbErr)
+	}
+
+	return recordEntry, versionEntries, nil
+}
+
+/*
+GetLatestValue get the value of the newest version of a key
+
+ErrVersionExpired is returned if the newest version has expired.
+
+	@param ctx context.Context - execution context
+	@param key string - key
+	@param activeDBClient Database - existing database transaction
+	@return the newest version, and its decrypted value
+*/
+func (s *protectedKVStore) GetLatestValue(
+	ctx context.Context, key string, activeDBClient db.Database,
+) (models.RecordVersion, []byte, error) {
+	var versionEntry models.RecordVersion
+	var plainText []byte
+
+	if dbErr := s.inSession(
+		ctx, "get_latest", activeDBClient, func(dbCtx context.Context, dbClient db.Database) error {
+			recordEntry, err := dbClient.GetRecordByName(dbCtx, NamespaceFromContext(dbCtx), key)
+			if err != nil {
+				return fmt.Errorf("failed to find key '%s' [%w]", key, err)
+			}
+
+			limit := 1
+			versionEntries, err := dbClient.ListVersionsOfOneRecord(
+				dbCtx,
+				recordEntry,
+				db.RecordVersionQueryFilter{
+					CommonListEntryQueryFilter: db.CommonListEntryQueryFilter{Limit: &limit},
+				},
+			)
+			if err != nil {
+				return fmt.Errorf("failed to list key %s versions [%w]", recordEntry.ID, err)
+			}
+			if len(versionEntries) == 0 {
+				return fmt.Errorf("key '%s' has no versions [%w]", key, ErrVersionNotFound)
+			}
+			versionEntry = versionEntries[0]
+
+			plainText, err = s.GetValueOfKeyAtVersion(dbCtx, versionEntry, dbClient)
+			return err
+		},
+	); dbErr != nil {
+		return models.RecordVersion{}, nil, fmt.Errorf(
+			"failed to read latest value of key '%s' [%w]", key, dbErr,
+		)
+	}
+
+	return versionEntry, plainText, nil
+}
+
+/*
+GetLatestValues get the values of the newest versions of several keys, all read in one
+transaction. A key which can not be read does not fail the others; its error is reported
+in its result instead.
+
+	@param ctx context.Context - execution context
+	@param keys []string - keys
+	@param activeDBClient Database - existing database transaction
+	@return the result of each key, by key
+*/
+func (s *protectedKVStore) GetLatestValues(
+	ctx context.Context, keys []string, activeDBClient db.Database,
+) (map[string]ValueResult, error) {
+	results := map[string]ValueResult{}
+
+	if dbErr := s.inSession(
+		ctx, "get_latest_values", activeDBClient,
+		func(dbCtx context.Context, dbClient db.Database) error {
+			recordEntries, err := dbClient.GetRecordsByName(
+				dbCtx, NamespaceFromContext(dbCtx), keys,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to find keys [%w]", err)
+			}
+			recordIDs := []string{}
+			recordByName := map[string]models.Record{}
+			for _, recordEntry := range recordEntries {
+				recordIDs = append(recordIDs, recordEntry.ID)
+				recordByName[recordEntry.Name] = recordEntry
+			}
+
+			latestVersions, err := dbClient.GetLatestVersionsOfRecords(dbCtx, recordIDs)
+			if err != nil {
+				return fmt.Errorf("failed to fetch newest key versions [%w]", err)
+			}
+
+			for _, key := range keys {
+				recordEntry, ok := recordByName[key]
+				if !ok {
+					results[key] = ValueResult{
+						Err: fmt.Errorf("failed to find key '%s' [%w]", key, db.ErrRecordNotFound),
+					}
+					continue
+				}
+				versionEntry, ok := latestVersions[recordEntry.ID]
+				if !ok {
+					results[key] = ValueResult{
+						Err: fmt.Errorf("key '%s' has no versions [%w]", key, ErrVersionNotFound),
+					}
+					continue
+				}
+
+				plainText, err := s.GetValueOfKeyAtVersion(dbCtx, versionEntry, dbClient)
+				results[key] = ValueResult{Version: versionEntry, Value: plainText, Err: err}
+			}
+			return nil
+		},
+	); dbErr != nil {
+		return nil, fmt.Errorf("failed to read latest values [%w]", dbErr)
+	}
+
+	return results, nil
+}
+
+/*
+GetKeyDetail get a key, the metadata of its versions, and its current value, all read in one
+transaction
+
+	@param ctx context.Context - execution context
+	@param key string - key
+	@param activeDBClient Database - existing database transaction
+	@return the key detail
+*/
+func (s *protectedKVStore) GetKeyDetail(
+	ctx context.Context, key string, activeDBClient db.Database,
+) (KeyDetail, error) {
+	var detail KeyDetail
+
+	if dbErr := s.inSession(
+		ctx, "get_detail", activeDBClient, func(dbCtx context.Context, dbClient db.Database) error {
+			var err error
+
+			detail.Record, err = dbClient.GetRecordByName(dbCtx, NamespaceFromContext(dbCtx), key)
+			if err != nil {
+				return fmt.Errorf("failed to find key '%s' [%w]", key, err)
+			}
+
+			versionEntries, err := dbClient.ListVersionsOfOneRecord(
+				dbCtx, detail.Record, db.RecordVersionQueryFilter{},
+			)
+			if err != nil {
+				return fmt.Errorf("failed to list key %s versions [%w]", detail.Record.ID, err)
+			}
+			if len(versionEntries) == 0 {
+				return fmt.Errorf("key '%s' has no versions [%w]", key, ErrVersionNotFound)
+			}
+
+			detail.LatestValue, err = s.GetValueOfKeyAtVersion(dbCtx, versionEntries[0], dbClient)
+			if err != nil && !errors.Is(err, ErrVersionExpired) {
+				return err
+			}
+
+			for _, version := range versionEntries {
+				version.EncValue = nil
+				version.EncNonce = nil
+				detail.Versions = append(detail.Versions, version)
+			}
+			return nil
+		},
+	); dbErr != nil {
+		return KeyDetail{}, fmt.Errorf("failed to read key '%s' detail [%w]", key, dbErr)
+	}
+
+	return detail, nil
+}
+
+/*
+GetValueOfKeyAtTimestamp get the value of a key as of a point in time, which is the
+value of the newest version created at or before that time
+
+ErrVersionNotFound is returned if no version was created by then, and
+ErrVersionExpired if that version has expired.
+
+	@param ctx context.Context - execution context
+	@param key string - key
+	@param at time.Time - the point in time
+	@param activeDBClient Database - existing database transaction
+	@return decrypted value of that version, and the version
+*/
+func (s *protectedKVStore) GetValueOfKeyAtTimestamp(
+	ctx context.Context, key string, at time.Time, activeDBClient db.Database,
+) ([]byte, models.RecordVersion, error) {
+	var versionEntry models.RecordVersion
+	var plainText []byte
+
+	if dbErr := s.inSession(
+		ctx, "get_at_timestamp", activeDBClient,
+		func(dbCtx context.Context, dbClient db.Database) error {
+			recordEntry, err := dbClient.GetRecordByName(dbCtx, NamespaceFromContext(dbCtx), key)
+			if err != nil {
+				return fmt.Errorf("failed to find key '%s' [%w]", key, err)
+			}
+
+			limit := 1
+			versionEntries, err := dbClient.ListVersionsOfOneRecord(
+				dbCtx,
+				recordEntry,
+				db.RecordVersionQueryFilter{
+					CommonListEntryQueryFilter: db.CommonListEntryQueryFilter{Limit: &limit},
+					CreatedBefore:              &at,
+				},
+			)
+			if err != nil {
+				return fmt.Errorf("failed to list key %s versions [%w]", recordEntry.ID, err)
+			}
+			if len(versionEntries) == 0 {
+				return fmt.Errorf("key '%s' has no version at %s [%w]", key, at, ErrVersionNotFound)
+			}
+			versionEntry = versionEntries[0]
+
+			plainText, err = s.GetValueOfKeyAtVersion(dbCtx, versionEntry, dbClient)
+			return err
+		},
+	); dbErr != nil {
+		return nil, models.RecordVersion{}, fmt.Errorf(
+			"failed to read value of key '%s' at %s [%w]", key, at, dbErr,
+		)
+	}
+
+	return plainText, versionEntry, nil
+}
+
+/*
+GetValueOfKeyAtIndex get the value of a key at a version picked by its position in the
+order the versions were recorded
+
+ErrVersionNotFound is returned if the index is out of range, and ErrVersionExpired if that
+version has expired.
+
+	@param ctx context.Context - execution context
+	@param key string - key
+	@param index int - position of the version, starting from 0 for the oldest version.
+	    A negative index counts from the newest version, which is -1.
+	@param activeDBClient Database - existing database transaction
+	@return decrypted value of that version, and the version
+*/
+func (s *protectedKVStore) GetValueOfKeyAtIndex(
+	ctx context.Context, key string, index int, activeDBClient db.Database,
+) ([]byte, models.RecordVersion, error) {
+	var versionEntry models.RecordVersion
+	var plainText []byte
+
+	if dbErr := s.inSession(
+		ctx, "get_at_index", activeDBClient,
+		func(dbCtx context.Context, dbClient db.Database) error {
+			recordEntry, err := dbClient.GetRecordByName(dbCtx, NamespaceFromContext(dbCtx), key)
+			if err != nil {
+				return fmt.Errorf("failed to find key '%s' [%w]", key, err)
+			}
+
+			versionEntry, err = dbClient.GetNthVersionOfRecord(
+				dbCtx, recordEntry, index, db.RecordVersionQueryFilter{},
+			)
+			if err != nil {
+				return fmt.Errorf("failed to find key '%s' version %d [%w]", key, index, err)
+			}
+
+			plainText, err = s.GetValueOfKeyAtVersion(dbCtx, versionEntry, dbClient)
+			return err
+		},
+	); dbErr != nil {
+		return nil, models.RecordVersion{}, fmt.Errorf(
+			"failed to read value of key '%s' at index %d [%w]", key, index, dbErr,
+		)
+	}
+
+	return plainText, versionEntry, nil
+}
+
+/*
+GetValueOfKeyAtVersionID get the value of a key at a particular version by ID
+
+ErrVersionExpired is returned if the version has expired.
+
+	@param ctx context.Context - execution context
+	@param versionID string - the version ID
+	@param activeDBClient Database - existing database transaction
+	@return decrypted value of that version
+*/
+func (s *protectedKVStore) GetValueOfKeyAtVersionID(
+	ctx context.Context, versionID string, activeDBClient db.Database,
+) ([]byte, error) {
+	var versionEntry models.RecordVersion
+
+	if dbErr := s.inSession(
+		ctx, "get_version", activeDBClient,
+		func(dbCtx context.Context, dbClient db.Database) error {
+			var err error
+			versionEntry, err = dbClient.GetRecordVersion(dbCtx, versionID)
+			return err
+		},
+	); dbErr != nil {
+		return nil, fmt.Errorf("failed to find key version %s [%w]", versionID, dbErr)
+	}
+
+	return s.GetValueOfKeyAtVersion(ctx, versionEntry, activeDBClient)
+}
+
+/*
+CompareVersions decrypt two versions by ID, and compare their values
+
+ErrVersionNotFound is returned if either version does not exist, and ErrVersionExpired if
+either has expired.
+
+	@param ctx context.Context - execution context
+	@param versionIDA string - the first version ID
+	@param versionIDB string - the second version ID
+	@param activeDBClient Database - existing database transaction
+	@return whether the values are equal, and the decrypted values of both versions
+*/
+func (s *protectedKVStore) CompareVersions(
+	ctx context.Context, versionIDA string, versionIDB string, activeDBClient db.Database,
+) (bool, []byte, []byte, error) {
+	var valueA, valueB []byte
+
+	if dbErr := s.inSession(
+		ctx, "compare_versions", activeDBClient,
+		func(dbCtx context.Context, dbClient db.Database) error {
+			versionA, err := dbClient.GetRecordVersion(dbCtx, versionIDA)
+			if err != nil {
+				return fmt.Errorf("failed to find key version %s [%w]", versionIDA, err)
+			}
+			versionB, err := dbClient.GetRecordVersion(dbCtx, versionIDB)
+			if err != nil {
+				return fmt.Errorf("failed to find key version %s [%w]", versionIDB, err)
+			}
+
+			if valueA, err = s.GetValueOfKeyAtVersion(dbCtx, versionA, dbClient); err != nil {
+				return err
+			}
+			valueB, err = s.GetValueOfKeyAtVersion(dbCtx, versionB, dbClient)
+			return err
+		},
+	); dbErr != nil {
+		clear(valueA)
+		return false, nil, nil, fmt.Errorf(
+			"failed to compare key versions %s and %s [%w]", versionIDA, versionIDB, dbErr,
+		)
+	}
+
+	return subtle.ConstantTimeCompare(valueA, valueB) == 1, valueA, valueB, nil
+}
+
+/*
+ValueEquals check whether the newest value of a key equals the candidate. The values are
+compared in constant time. A key which does not exist equals no value.
+
+ErrVersionExpired is returned if the newest version has expired.
+
+	@param ctx context.Context - execution context
+	@param key string - key
+	@param candidate []byte - the value to compare with
+	@param activeDBClient Database - existing database transaction
+	@return whether the newest value equals the candidate
+*/
+func (s *protectedKVStore) ValueEquals(
+	ctx context.Context, key string, candidate []byte, activeDBClient db.Database,
+) (bool, error) {
+	_, plainText, err := s.GetLatestValue(ctx, key, activeDBClient)
+	if errors.Is(err, db.ErrRecordNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer clear(plainText)
+
+	return subtle.ConstantTimeCompare(plainText, candidate) == 1, nil
+}
+
+/*
+GetValueOfKeyAtVersion get the value of a key at particular version
+
+ErrVersionExpired is returned if the version has expired.
+
+	@param ctx context.Context - execution context
+	@param versionEntry models.RecordVersion - the version
+	@param activeDBClient Database - existing database transaction
+	@return decrypted value of that version
+*/
+func (s *protectedKVStore) GetValueOfKeyAtVersion(
+	ctx context.Context, versionEntry models.RecordVersion, activeDBClient db.Database,
+) ([]byte, error) {
+	if versionEntry.IsExpired(time.Now()) {
+		return nil, fmt.Errorf("key version %s [%w]", versionEntry.ID, ErrVersionExpired)
+	}
+
+	// Decrypt the value
+	encrypted := encryption.EncryptedData{
+		CipherText: versionEntry.EncValue,
+		Nonce:      versionEntry.EncNonce,
+		AAD:        versionEntry.AAD(),
+		SubkeyInfo: versionEntry.SubkeyInfo(),
+	}
+	_, plainText, err := s.cryptoEngine.DecryptData(
+		ctx, versionEntry.EncKeyID, encrypted, activeDBClient,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt key version %s [%w]", versionEntry.ID, err)
+	}
+
+	if s.options.TrackReads {
+		if dbErr := s.inSession(
+			ctx, "track_read", activeDBClient,
+			func(dbCtx context.Context, dbClient db.Database) error {
+				return dbClient.MarkRecordRead(dbCtx, versionEntry.RecordID, time.Now().UTC())
+			},
+		); dbErr != nil {
+			return nil, fmt.Errorf("failed to track read of key version %s [%w]", versionEntry.ID, dbErr)
+		}
+	}
+
+	return plainText, nil
+}
+
+/*
+RecordKeyObject record a key with a structured value
+
+The value is serialized with the store's ValueCodec before encryption.
+
+	@param ctx context.Context - execution context
+	@param key string - key
+	@param value any - value
+	@param timestamp time.Time - record timestamp
+	@param activeDBClient Database - existing database transaction
+	@returns the record and record version entry
+*/
+func (s *protectedKVStore) RecordKeyObject(
+	ctx context.Context, key string, value any, timestamp time.Time, activeDBClient db.Database,
+) (models.Record, models.RecordVersion, error) {
+	encoded, err := s.options.Codec.Encode(value)
+	if err != nil {
+		return models.Record{},
+			models.RecordVersion{},
+			fmt.Errorf("failed to serialize value of key '%s' [%w]", key, err)
+	}
+
+	return s.RecordKeyValue(ctx, key, encoded, timestamp, activeDBClient)
+}
+
+/*
+GetKeyObject get the structured value of a key at a particular version by ID
+
+The decrypted value is deserialized with the store's ValueCodec.
+
+	@param ctx context.Context - execution context
+	@param versionID string - the version ID
+	@param value any - pointer to the object to populate
+	@param activeDBClient Database - existing database transaction
+*/
+func (s *protectedKVStore) GetKeyObject(
+	ctx context.Context, versionID string, value any, activeDBClient db.Database,
+) error {
+	plainText, err := s.GetValueOfKeyAtVersionID(ctx, versionID, activeDBClient)
+	if err != nil {
+		return err
+	}
+
+	if err := s.options.Codec.Decode(plainText, value); err != nil {
+		return fmt.Errorf("failed to deserialize key version %s [%w]", versionID, err)
+	}
+
+	return nil
+}
+
+/*
+RekeyRecord re-encrypt every version of a key with the working encryption key. Versions
+already encrypted with the working key are skipped.
+
+	@param ctx context.Context - execution context
+	@param key string - key
+	@param activeDBClient Database - existing database transaction
+	@returns number of versions re-encrypted
+*/
+func (s *protectedKVStore) RekeyRecord(
+	ctx context.Context, key string, activeDBClient db.Database,
+) (int, error) {
+	rekeyed := 0
+
+	if dbErr := s.inSession(
+		ctx, "rekey_record", activeDBClient, func(dbCtx context.Context, dbClient db.Database) error {
+			var err error
+			rekeyed, err = s.reencryptRecordVersions(
+				dbCtx,
+				key,
+				func() string { return s.getWorkingKey().ID },
+				s.encryptWithWorkingKey,
+				dbClient,
+			)
+			return err
+		},
+	); dbErr != nil {
+		return 0, fmt.Errorf("failed to rekey key '%s' [%w]", key, dbErr)
+	}
+
+	if s.coalescer != nil {
+		s.coalescer.forget(NamespaceFromContext(ctx), key)
+	}
+
+	return rekeyed, nil
+}
+
+/*
+ReencryptRecord re-encrypt every version of a key with a specific encryption key, which
+must be active. Versions already encrypted with that key are skipped.
+
+	@param ctx context.Context - execution context
+	@param key string - key
+	@param targetKeyID string - the encryption key to re-encrypt with
+	@param activeDBClient Database - existing database transaction
+	@returns number of versions re-encrypted
+*/
+func (s *protectedKVStore) ReencryptRecord(
+	ctx context.Context, key string, targetKeyID string, activeDBClient db.Database,
+) (int, error) {
+	reencrypted := 0
+
+	if dbErr := s.inSession(
+		ctx, "reencrypt_record", activeDBClient,
+		func(dbCtx context.Context, dbClient db.Database) error {
+			targetKey, err := s.cryptoEngine.GetEncryptionKey(dbCtx, targetKeyID, dbClient)
+			if err != nil {
+				return fmt.Errorf("failed to find encryption key %s [%w]", targetKeyID, err)
+			}
+			if targetKey.State != models.EncryptionKeyStateActive {
+				return fmt.Errorf("encryption key %s [%w]", targetKeyID, encryption.ErrKeyNotActive)
+			}
+
+			reencrypted, err = s.reencryptRecordVersions(
+				dbCtx,
+				key,
+				func() string { return targetKeyID },
+				func(
+					ctx context.Context,
+					value []byte,
+					aad []byte,
+					subkeyInfo []byte,
+					dbClient db.Database,
+				) (models.EncryptionKey, encryption.EncryptedData, error) {
+					return s.cryptoEngine.EncryptData(
+						ctx, targetKeyID, value, aad, subkeyInfo, dbClient,
+					)
+				},
+				dbClient,
+			)
+			return err
+		},
+	); dbErr != nil {
+		return 0, fmt.Errorf("failed to re-encrypt key '%s' [%w]", key, dbErr)
+	}
+
+	if s.coalescer != nil {
+		s.coalescer.forget(NamespaceFromContext(ctx), key)
+	}
+
+	return reencrypted, nil
+}
+
+// reencryptRecordVersions decrypt every version of a key not already encrypted with the
+// target encryption key, and store it again encrypted with encrypt
+func (s *protectedKVStore) reencryptRecordVersions(
+	ctx context.Context,
+	key string,
+	targetKeyID func() string,
+	encrypt func(
+		ctx context.Context, value []byte, aad []byte, subkeyInfo []byte, dbClient db.Database,
+	) (models.EncryptionKey, encryption.EncryptedData, error),
+	dbClient db.Database,
+) (int, error) {
+	recordEntry, err := dbClient.GetRecordByName(ctx, NamespaceFromContext(ctx), key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find key '%s' [%w]", key, err)
+	}
+
+	versionEntries, err := dbClient.ListVersionsOfOneRecord(
+		ctx, recordEntry, db.RecordVersionQueryFilter{SortAscending: true},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list key %s versions [%w]", recordEntry.ID, err)
+	}
 
-			@param ctx context.Context - execution context
-			@param versionID string - the version ID
-			@param activeDBClient Database - existing database transaction
-			@return decrypted value of that version
-	*/
-	GetValueOfKeyAtVersionID(
-		ctx context.Context, versionID string, activeDBClient db.Database,
-	) ([]byte, error)
+	reencrypted := 0
+	for _, version := range versionEntries {
+		if version.EncKeyID == targetKeyID() {
+			continue
+		}
 
-	/*
-		GetValueOfKeyAtVersion get the value of a key at particular version
+		_, plainText, err := s.cryptoEngine.DecryptData(
+			ctx,
+			version.EncKeyID,
+			encryption.EncryptedData{
+				CipherText: version.EncValue,
+				Nonce:      version.EncNonce,
+				AAD:        version.AAD(),
+				SubkeyInfo: version.SubkeyInfo(),
+			},
+			dbClient,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to decrypt key version %s [%w]", version.ID, err)
+		}
 
-			@param ctx context.Context - execution context
-			@param versionEntry models.RecordVersion - the version
-			@param activeDBClient Database - existing database transaction
-			@return decrypted value of that version
-	*/
-	GetValueOfKeyAtVersion(
-		ctx context.Context, versionEntry models.RecordVersion, activeDBClient db.Database,
-	) ([]byte, error)
+		subkeyInfo, keyDerivation := s.recordKeyDerivation(version.RecordID)
+		theKey, encrypted, err := encrypt(ctx, plainText, version.AAD(), subkeyInfo, dbClient)
+		clear(plainText)
+		if err != nil {
+			return 0, fmt.Errorf("failed to re-encrypt key version %s [%w]", version.ID, err)
+		}
 
-	/*
-		DeleteKey delete a key from storage
+		if _, err := dbClient.ReencryptRecordVersion(
+			ctx, version.ID, theKey, encrypted.CipherText, encrypted.Nonce, keyDerivation,
+		); err != nil {
+			return 0, fmt.Errorf("failed to update key version %s [%w]", version.ID, err)
+		}
+		reencrypted++
+	}
 
-			@param ctx context.Context - execution context
-			@param key string - key
-			@param activeDBClient Database - existing database transaction
-	*/
-	DeleteKey(ctx context.Context, key string, activeDBClient db.Database) error
+	return reencrypted, nil
 }
 
-// protectedKVStore implements ProtectedKVStore
-type protectedKVStore struct {
-	goutils.Component
+/*
+CountKeys count the keys of the context's namespace in storage
 
-	persistence db.Client
+	@param ctx context.Context - execution context
+	@param activeDBClient Database - existing database transaction
+	@returns number of keys
+*/
+func (s *protectedKVStore) CountKeys(
+	ctx context.Context, activeDBClient db.Database,
+) (int64, error) {
+	namespace := NamespaceFromContext(ctx)
+	var count int64
+	if dbErr := s.inSession(
+		ctx, "count_keys", activeDBClient, func(dbCtx context.Context, dbClient db.Database) error {
+			var err error
+			count, err = dbClient.CountRecords(dbCtx, db.RecordQueryFilter{Namespace: &namespace})
+			return err
+		},
+	); dbErr != nil {
+		return 0, fmt.Errorf("failed to count keys [%w]", dbErr)
+	}
+	return count, nil
+}
 
-	cryptoEngine encryption.CryptographyEngine
+/*
+ListKeys list the keys of the context's namespace, ordered by name
 
-	workingKey models.EncryptionKey
+	@param ctx context.Context - execution context
+	@param keyPrefix string - list only keys starting with this prefix. Empty for all.
+	@param activeDBClient Database - existing database transaction
+	@returns the data records of the keys
+*/
+func (s *protectedKVStore) ListKeys(
+	ctx context.Context, keyPrefix string, activeDBClient db.Database,
+) ([]models.Record, error) {
+	namespace := NamespaceFromContext(ctx)
+	keys := []models.Record{}
+	if dbErr := s.inSession(
+		ctx, "list_keys", activeDBClient, func(dbCtx context.Context, dbClient db.Database) error {
+			return forEachPage(func(page db.CommonListEntryQueryFilter) (int, error) {
+				records, err := dbClient.ListRecords(dbCtx, db.RecordQueryFilter{
+					CommonListEntryQueryFilter: page,
+					Namespace:                  &namespace,
+					NamePrefix:                 keyPrefix,
+					SortBy:                     db.SortByName,
+					SortAscending:              true,
+				})
+				if err != nil {
+					return 0, err
+				}
+				keys = append(keys, records...)
+				return len(records), nil
+			})
+		},
+	); dbErr != nil {
+		return nil, fmt.Errorf("failed to list keys [%w]", dbErr)
+	}
+	return keys, nil
 }
 
 /*
-NewProtectedKVStore define new protected KV store
+ListKeysWithMetadata list the keys of the context's namespace matching the filter, each
+with when its newest version was recorded and how many versions it has. The namespace of
+the filter is ignored.
 
 	@param ctx context.Context - execution context
-	@param persistence db.Client - persistence layer client
-	@param cryptoEngine encryption.CryptographyEngine - cryptography engine
-	@returns store instance
+	@param filter db.RecordQueryFilter - the keys to list
+	@param activeDBClient Database - existing database transaction
+	@returns the keys
 */
-func NewProtectedKVStore(
-	ctx context.Context, persistence db.Client, cryptoEngine encryption.CryptographyEngine,
-) (ProtectedKVStore, error) {
-	logTags := log.Fields{"package": "haven", "module": "store", "component": "protected-kv-store"}
+func (s *protectedKVStore) ListKeysWithMetadata(
+	ctx context.Context, filter db.RecordQueryFilter, activeDBClient db.Database,
+) ([]KeyInfo, error) {
+	namespace := NamespaceFromContext(ctx)
+	filter.Namespace = &namespace
 
-	instance := &protectedKVStore{
-		Component: goutils.Component{
-			LogTags: logTags,
-			LogTagModifiers: []goutils.LogMetadataModifier{
-				goutils.ModifyLogMetadataByRestRequestParam,
-			},
+	keys := []KeyInfo{}
+	if dbErr := s.inSession(
+		ctx, "list_keys_with_metadata", activeDBClient,
+		func(dbCtx context.Context, dbClient db.Database) error {
+			records, err := dbClient.ListRecordsWithVersionStats(dbCtx, filter)
+			if err != nil {
+				return err
+			}
+			for _, record := range records {
+				keys = append(keys, KeyInfo{
+					Name:            record.Name,
+					RecordID:        record.ID,
+					CreatedAt:       record.CreatedAt,
+					LatestVersionAt: record.LatestVersionAt,
+					VersionCount:    record.VersionCount,
+				})
+			}
+			return nil
 		},
-		persistence:  persistence,
-		cryptoEngine: cryptoEngine,
+	); dbErr != nil {
+		return nil, fmt.Errorf("failed to list keys with metadata [%w]", dbErr)
 	}
+	return keys, nil
+}
 
-	// Prepare the working encryption key
-	if dbErr := persistence.UseDatabaseInTransaction(
-		ctx, func(dbCtx context.Context, dbClient db.Database) error {
-			activeKeys, err := cryptoEngine.ListEncryptionKeys(
-				dbCtx,
-				db.EncryptionKeyQueryFilter{
-					TargetState: []models.EncryptionKeyStateENUMType{models.EncryptionKeyStateActive},
-				},
-				dbClient,
-			)
-			if err != nil {
-				return fmt.Errorf("failed to list active encryption keys [%w]", err)
-			}
+/*
+PruneRecordVersions delete all but the newest versions of a data record
 
-			if len(activeKeys) == 0 {
-				// Make a new key
-				instance.workingKey, err = cryptoEngine.NewEncryptionKey(dbCtx, dbClient)
-				if err != nil {
-					return fmt.Errorf("failed to define new encryption key [%w]", err)
-				}
-			} else {
-				// Use the newest key
-				instance.workingKey = activeKeys[0]
-			}
+	@param ctx context.Context - execution context
+	@param record models.Record - the data record
+	@param keepLatest int - number of newest versions to keep. Must be at least 1.
+	@param activeDBClient Database - existing database transaction
+	@returns number of versions deleted
+*/
+func (s *protectedKVStore) PruneRecordVersions(
+	ctx context.Context, record models.Record, keepLatest int, activeDBClient db.Database,
+) (int, error) {
+	if keepLatest < 1 {
+		return 0, fmt.Errorf("must keep at least one version, not %d", keepLatest)
+	}
 
-			return nil
+	pruned := 0
+	if dbErr := s.inSession(
+		ctx, "prune_versions", activeDBClient,
+		func(dbCtx context.Context, dbClient db.Database) error {
+			var err error
+			pruned, err = s.pruneRecordVersions(dbCtx, record, keepLatest, dbClient)
+			return err
 		},
 	); dbErr != nil {
-		return nil, fmt.Errorf("failed to prepare working encryption key [%w]", dbErr)
+		return 0, fmt.Errorf("failed to prune key '%s' versions [%w]", record.Name, dbErr)
 	}
 
-	return instance, nil
+	return pruned, nil
+}
+
+// pruneRecordVersions delete the versions of a data record older than its newest
+// keepLatest versions
+func (s *protectedKVStore) pruneRecordVersions(
+	ctx context.Context, record models.Record, keepLatest int, dbClient db.Database,
+) (int, error) {
+	versions, err := dbClient.ListVersionsOfOneRecord(
+		ctx, record, db.RecordVersionQueryFilter{
+			CommonListEntryQueryFilter: db.CommonListEntryQueryFilter{Offset: &keepLatest},
+		},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list key %s versions [%w]", record.ID, err)
+	}
+
+	for _, version := range versions {
+		if err := dbClient.DeleteRecordVersion(ctx, version.ID); err != nil {
+			return 0, fmt.Errorf("failed to prune key '%s' version [%w]", record.Name, err)
+		}
+		log.WithFields(s.LogTags).
+			WithField("key", record.Name).
+			WithField("version", version.ID).
+			Debug("Pruned key version beyond the retention limit")
+	}
+	return len(versions), nil
 }
 
 /*
-RecordKeyValue record a key value pair
+DeleteKey delete a key from storage
 
 	@param ctx context.Context - execution context
 	@param key string - key
-	@param value []byte - value
-	@param timestamp time.Time - record timestamp
 	@param activeDBClient Database - existing database transaction
-	@returns the record and record version entry
 */
-func (s *protectedKVStore) RecordKeyValue(
-	ctx context.Context, key string, value []byte, timestamp time.Time, activeDBClient db.Database,
-) (models.Record, models.RecordVersion, error) {
+func (s *protectedKVStore) DeleteKey(
+	ctx context.Context, key string, activeDBClient db.Database,
+) error {
 	var recordEntry models.Record
-	var versionEntry models.RecordVersion
-
-	if dbErr := db.ActiveSessionWrapper(
-		ctx, activeDBClient, s.persistence, func(dbCtx context.Context, dbClient db.Database) error {
+	if dbErr := s.inSession(
+		ctx, "delete_key", activeDBClient, func(dbCtx context.Context, dbClient db.Database) error {
 			var err error
-
 			// Prepare data record
-			recordEntry, err = dbClient.GetRecordByName(dbCtx, key)
-			if err != nil {
-				// Make a new record
-				recordEntry, err = dbClient.DefineNewRecord(dbCtx, key)
-				if err != nil {
-					return fmt.Errorf("failed to define new data record [%w]", err)
-				}
-			}
-
-			// Encrypt the data
-			theKey, encrypted, err := s.cryptoEngine.EncryptData(dbCtx, s.workingKey.ID, value, dbClient)
+			recordEntry, err = dbClient.GetRecordByName(dbCtx, NamespaceFromContext(dbCtx), key)
 			if err != nil {
-				return fmt.Errorf("failed to encryption record value [%w]", err)
+				return fmt.Errorf("failed to find key '%s' [%w]", key, err)
 			}
 
-			// Prepare new version
-			versionEntry, err = dbClient.DefineNewVersionForRecord(
-				dbCtx, recordEntry, theKey, encrypted.CipherText, encrypted.Nonce, timestamp,
-			)
-			if err != nil {
-				return fmt.Errorf("failed to insert new record version [%w]", err)
+			if err := dbClient.DeleteRecord(dbCtx, recordEntry.ID); err != nil {
+				return err
 			}
 
+			dbClient.AfterCommit(func() {
+				s.watchers.publish(WatchEvent{
+					Type:      WatchEventTypeDeleted,
+					Namespace: NamespaceFromContext(ctx),
+					Key:       key,
+					RecordID:  recordEntry.ID,
+					Timestamp: time.Now().UTC(),
+				}, s.LogTags)
+			})
 			return nil
 		},
 	); dbErr != nil {
-		return models.Record{},
-			models.RecordVersion{},
-			fmt.Errorf("failed to record key '%s' [%w]", key, dbErr)
+		return fmt.Errorf("failed to delete key '%s' versions [%w]", key, dbErr)
 	}
 
-	return recordEntry, versionEntry, nil
+	if s.coalescer != nil {
+		s.coalescer.forget(NamespaceFromContext(ctx), key)
+	}
+
+	return nil
 }
 
 /*
-ListKeyVersions list the versions of a key
+RenameKey rename a key. Every version of the key is kept, and remains readable under the new
+name.
 
 	@param ctx context.Context - execution context
-	@param key string - key
+	@param oldKey string - current key
+	@param newKey string - new key. It must not already exist.
 	@param activeDBClient Database - existing database transaction
-	@returns the record and its associated versions
 */
-func (s *protectedKVStore) ListKeyVersions(
-	ctx context.Context, key string, activeDBClient db.Database,
-) (models.Record, []models.RecordVersion, error) {
+func (s *protectedKVStore) RenameKey(
+	ctx context.Context, oldKey string, newKey string, activeDBClient db.Database,
+) error {
 	var recordEntry models.Record
-	var versionEntries []models.RecordVersion
-
-	if dbErr := db.ActiveSessionWrapper(
-		ctx, activeDBClient, s.persistence, func(dbCtx context.Context, dbClient db.Database) error {
+	namespace := NamespaceFromContext(ctx)
+	if dbErr := s.inSession(
+		ctx, "rename_key", activeDBClient, func(dbCtx context.Context, dbClient db.Database) error {
 			var err error
-
-			// Prepare data record
-			recordEntry, err = dbClient.GetRecordByName(dbCtx, key)
+			recordEntry, err = dbClient.GetRecordByName(dbCtx, NamespaceFromContext(dbCtx), oldKey)
 			if err != nil {
-				return fmt.Errorf("failed to find key '%s' [%w]", key, err)
+				return fmt.Errorf("failed to find key '%s' [%w]", oldKey, err)
 			}
 
-			versionEntries, err = dbClient.ListVersionsOfOneRecord(
-				dbCtx, recordEntry, db.RecordVersionQueryFilter{},
-			)
+			recordEntry, err = dbClient.RenameRecord(dbCtx, recordEntry.ID, newKey)
 			if err != nil {
-				return fmt.Errorf("failed to list key %s versions [%w]", recordEntry.ID, err)
+				return err
 			}
 
+			// To watchers, the old key is gone and the new key appears
+			dbClient.AfterCommit(func() {
+				now := time.Now().UTC()
+				s.watchers.publish(WatchEvent{
+					Type:      WatchEventTypeDeleted,
+					Namespace: namespace,
+					Key:       oldKey,
+					RecordID:  recordEntry.ID,
+					Timestamp: now,
+				}, s.LogTags)
+				s.watchers.publish(WatchEvent{
+					Type:      WatchEventTypeCreated,
+					Namespace: namespace,
+					Key:       newKey,
+					RecordID:  recordEntry.ID,
+					Timestamp: now,
+				}, s.LogTags)
+			})
 			return nil
 		},
 	); dbErr != nil {
-		return models.Record{}, nil, fmt.Errorf("failed to list key '%s' versions [%w]", key, dbErr)
+		return fmt.Errorf("failed to rename key '%s' to '%s' [%w]", oldKey, newKey, dbErr)
 	}
 
-	return recordEntry, versionEntries, nil
+	if s.coalescer != nil {
+		s.coalescer.forget(namespace, oldKey)
+	}
+
+	return nil
 }
 
 /*
-GetValueOfKeyAtVersionID get the value of a key at a particular version by ID
+DeleteKeyVersion delete one version of a key. The only remaining version of a key is only
+deleted when forced; the key itself remains. Watchers see the deletion of the newest
+version as an update to the version now newest, or as the deletion of the key once no
+versions remain.
 
 	@param ctx context.Context - execution context
-	@param versionID string - the version ID
+	@param key string - key
+	@param versionID string - the version to delete. It must belong to the key.
+	@param force bool - whether to delete the only remaining version of the key
 	@param activeDBClient Database - existing database transaction
-	@return decrypted value of that version
 */
-func (s *protectedKVStore) GetValueOfKeyAtVersionID(
-	ctx context.Context, versionID string, activeDBClient db.Database,
-) ([]byte, error) {
-	var versionEntry models.RecordVersion
+func (s *protectedKVStore) DeleteKeyVersion(
+	ctx context.Context, key string, versionID string, force bool, activeDBClient db.Database,
+) error {
+	if dbErr := s.inSession(
+		ctx, "delete_key_version", activeDBClient,
+		func(dbCtx context.Context, dbClient db.Database) error {
+			recordEntry, err := dbClient.GetRecordByName(dbCtx, NamespaceFromContext(dbCtx), key)
+			if err != nil {
+				return fmt.Errorf("failed to find key '%s' [%w]", key, err)
+			}
 
-	if dbErr := db.ActiveSessionWrapper(
-		ctx, activeDBClient, s.persistence, func(dbCtx context.Context, dbClient db.Database) error {
-			var err error
-			versionEntry, err = dbClient.GetRecordVersion(dbCtx, versionID)
-			return err
+			versionEntry, err := dbClient.GetRecordVersion(dbCtx, versionID)
+			if err != nil {
+				return fmt.Errorf("failed to find key version %s [%w]", versionID, err)
+			}
+			if versionEntry.RecordID != recordEntry.ID {
+				return fmt.Errorf(
+					"version %s does not belong to key '%s' [%w]", versionID, key, ErrVersionNotFound,
+				)
+			}
+
+			if !force {
+				count, err := dbClient.CountRecordVersions(dbCtx, db.RecordVersionQueryFilter{
+					TargetRecordID: &recordEntry.ID,
+				})
+				if err != nil {
+					return fmt.Errorf("failed to count key %s versions [%w]", recordEntry.ID, err)
+				}
+				if count <= 1 {
+					return fmt.Errorf("key '%s' version %s [%w]", key, versionID, ErrLastVersion)
+				}
+			}
+
+			// Deleting the newest version changes the value of the key
+			latest, err := dbClient.GetLatestVersionsOfRecords(dbCtx, []string{recordEntry.ID})
+			if err != nil {
+				return fmt.Errorf("failed to find key '%s' latest version [%w]", key, err)
+			}
+			wasLatest := latest[recordEntry.ID].ID == versionID
+
+			if err := dbClient.DeleteRecordVersion(dbCtx, versionID); err != nil {
+				return err
+			}
+
+			if !wasLatest {
+				return nil
+			}
+			latest, err = dbClient.GetLatestVersionsOfRecords(dbCtx, []string{recordEntry.ID})
+			if err != nil {
+				return fmt.Errorf("failed to find key '%s' latest version [%w]", key, err)
+			}
+			event := WatchEvent{
+				Type:      WatchEventTypeDeleted,
+				Namespace: NamespaceFromContext(dbCtx),
+				Key:       key,
+				RecordID:  recordEntry.ID,
+			}
+			if newLatest, ok := latest[recordEntry.ID]; ok {
+				event.Type = WatchEventTypeUpdated
+				event.VersionID = newLatest.ID
+			}
+			dbClient.AfterCommit(func() {
+				event.Timestamp = time.Now().UTC()
+				s.watchers.publish(event, s.LogTags)
+			})
+			return nil
 		},
 	); dbErr != nil {
-		return nil, fmt.Errorf("failed to find key version %s [%w]", versionID, dbErr)
+		return fmt.Errorf("failed to delete key '%s' version %s [%w]", key, versionID, dbErr)
 	}
 
-	// Decrypt the value
-	_, plainText, err := s.cryptoEngine.DecryptData(
-		ctx, versionEntry.EncKeyID, encryption.EncryptedData{
-			CipherText: versionEntry.EncValue, Nonce: versionEntry.EncNonce,
-		}, activeDBClient,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt key version %s [%w]", versionID, err)
+	// The remembered write may be the deleted version
+	if s.coalescer != nil {
+		s.coalescer.forget(NamespaceFromContext(ctx), key)
 	}
 
-	return plainText, nil
+	return nil
 }
 
 /*
-GetValueOfKeyAtVersion get the value of a key at particular version
+Watch subscribe to changes of keys matching a prefix
+
+An event is delivered whenever a matching key of the context's namespace is created,
+updated, or deleted through this store instance, once the change commits. A change
+made within a caller provided transaction is delivered once that transaction commits,
+and never if it rolls back. Events come from this store instance alone; changes made
+through other store instances, even on the same database, are not delivered. Should
+the subscriber fall behind, events are dropped, and a WatchEventTypeLagged event
+marks the gap; re-read the watched keys to catch up. The subscription ends, and the
+channel is closed, when the context is cancelled.
+
+	@param ctx context.Context - subscription context
+	@param keyPrefix string - key prefix to watch. Empty to watch all keys.
+	@returns the event channel
+*/
+func (s *protectedKVStore) Watch(ctx context.Context, keyPrefix string) (<-chan WatchEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("watch context already closed [%w]", err)
+	}
+	return s.watchers.subscribe(ctx, NamespaceFromContext(ctx), keyPrefix), nil
+}
+
+// healthCheckProbe the value encrypted and decrypted to verify the working key is usable
+var healthCheckProbe = []byte("haven-health-check")
+
+/*
+HealthCheck verify the store can serve requests: the database is reachable, and the
+working encryption key can still encrypt and decrypt. Fails with ErrDatabaseUnavailable or
+ErrWorkingKeyUnavailable respectively.
 
 	@param ctx context.Context - execution context
-	@param versionEntry models.RecordVersion - the version
-	@param activeDBClient Database - existing database transaction
-	@return decrypted value of that version
 */
-func (s *protectedKVStore) GetValueOfKeyAtVersion(
-	ctx context.Context, versionEntry models.RecordVersion, activeDBClient db.Database,
-) ([]byte, error) {
-	// Decrypt the value
-	_, plainText, err := s.cryptoEngine.DecryptData(
-		ctx, versionEntry.EncKeyID, encryption.EncryptedData{
-			CipherText: versionEntry.EncValue, Nonce: versionEntry.EncNonce,
-		}, activeDBClient,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt key version %s [%w]", versionEntry.ID, err)
+func (s *protectedKVStore) HealthCheck(ctx context.Context) error {
+	if err := s.persistence.Ping(ctx); err != nil {
+		return fmt.Errorf("health check failed [%w] [%w]", ErrDatabaseUnavailable, err)
 	}
 
-	return plainText, nil
+	keyID := s.getWorkingKey().ID
+	if dbErr := s.inSession(
+		ctx, "health_check", nil, func(dbCtx context.Context, dbClient db.Database) error {
+			_, encrypted, err := s.cryptoEngine.EncryptData(
+				dbCtx, keyID, healthCheckProbe, nil, nil, dbClient,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to encrypt with working key %s [%w]", keyID, err)
+			}
+			_, plainText, err := s.cryptoEngine.DecryptData(dbCtx, keyID, encrypted, dbClient)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt with working key %s [%w]", keyID, err)
+			}
+			if !bytes.Equal(plainText, healthCheckProbe) {
+				return fmt.Errorf("working key %s did not round trip the probe value", keyID)
+			}
+			return nil
+		},
+	); dbErr != nil {
+		return fmt.Errorf("health check failed [%w] [%w]", ErrWorkingKeyUnavailable, dbErr)
+	}
+
+	return nil
 }
 
 /*
-DeleteKey delete a key from storage
+SystemState fetch the system operating state. Keys can only be written while the system is
+RUNNING.
 
 	@param ctx context.Context - execution context
-	@param key string - key
 	@param activeDBClient Database - existing database transaction
+	@returns the system state
 */
-func (s *protectedKVStore) DeleteKey(
-	ctx context.Context, key string, activeDBClient db.Database,
-) error {
-	if dbErr := db.ActiveSessionWrapper(
-		ctx, activeDBClient, s.persistence, func(dbCtx context.Context, dbClient db.Database) error {
-			// Prepare data record
-			recordEntry, err := dbClient.GetRecordByName(dbCtx, key)
+func (s *protectedKVStore) SystemState(
+	ctx context.Context, activeDBClient db.Database,
+) (models.SystemStateENUMType, error) {
+	var state models.SystemStateENUMType
+	if dbErr := s.inSession(
+		ctx, "system_state", activeDBClient, func(dbCtx context.Context, dbClient db.Database) error {
+			params, err := dbClient.GetSystemParamEntry(dbCtx)
 			if err != nil {
-				return fmt.Errorf("failed to find key '%s' [%w]", key, err)
+				return fmt.Errorf("failed to read system parameters [%w]", err)
 			}
-
-			return dbClient.DeleteRecord(dbCtx, recordEntry.ID)
+			state = params.State
+			return nil
 		},
 	); dbErr != nil {
-		return fmt.Errorf("failed to delete key '%s' versions [%w]", key, dbErr)
+		return "", fmt.Errorf("failed to read system state [%w]", dbErr)
 	}
 
+	return state, nil
+}
+
+/*
+Close release the store's resources. The decrypted encryption keys are zeroed, and the
+database connection is closed.
+*/
+func (s *protectedKVStore) Close() error {
+	s.cryptoEngine.Shutdown()
+	if err := s.persistence.Close(); err != nil {
+		return fmt.Errorf("failed to close persistence client [%w]", err)
+	}
 	return nil
 }
@@ -17,6 +17,15 @@ import (
 	"github.com/stretchr/testify/mock"
 )
 
+// newestActiveKeyFilter the filter the store lists its working encryption key with
+func newestActiveKeyFilter() db.EncryptionKeyQueryFilter {
+	newestOnly := 1
+	return db.EncryptionKeyQueryFilter{
+		CommonListEntryQueryFilter: db.CommonListEntryQueryFilter{Limit: &newestOnly},
+		TargetState:                []models.EncryptionKeyStateENUMType{models.EncryptionKeyStateActive},
+	}
+}
+
 func TestKVStoreInit(t *testing.T) {
 	assert := assert.New(t)
 	log.SetLevel(log.DebugLevel)
@@ -42,9 +51,7 @@ func TestKVStoreInit(t *testing.T) {
 	mockCrypto.On(
 		"ListEncryptionKeys",
 		mock.AnythingOfType("context.backgroundCtx"),
-		db.EncryptionKeyQueryFilter{
-			TargetState: []models.EncryptionKeyStateENUMType{models.EncryptionKeyStateActive},
-		},
+		newestActiveKeyFilter(),
 		mockDatabase,
 	).Return(nil, nil).Once()
 	mockCrypto.On(
@@ -52,7 +59,22 @@ func TestKVStoreInit(t *testing.T) {
 		mock.AnythingOfType("context.backgroundCtx"),
 		mockDatabase,
 	).Return(testEncKey, nil)
-	_, err := store.NewProtectedKVStore(utCtx, mockDBClient, mockCrypto)
+	mockDatabase.On(
+		"LockSystemParams", mock.AnythingOfType("context.backgroundCtx"),
+	).Return(nil).Once()
+	// A fresh system is driven to RUNNING
+	mockDatabase.On(
+		"GetSystemParamEntry", mock.AnythingOfType("context.backgroundCtx"),
+	).Return(models.SystemParams{State: models.SystemStatePreInit}, nil).Once()
+	mockDatabase.On(
+		"MarkSystemInitializing", mock.AnythingOfType("context.backgroundCtx"),
+	).Return(nil).Once()
+	mockDatabase.On(
+		"MarkSystemInitialized", mock.AnythingOfType("context.backgroundCtx"),
+	).Return(nil).Once()
+	_, err := store.NewProtectedKVStore(
+		utCtx, mockDBClient, mockCrypto, store.ProtectedKVStoreOptions{},
+	)
 	assert.Nil(err)
 }
 
@@ -81,9 +103,7 @@ func TestKVStoreRecordNewKey(t *testing.T) {
 	mockCrypto.On(
 		"ListEncryptionKeys",
 		mock.AnythingOfType("context.backgroundCtx"),
-		db.EncryptionKeyQueryFilter{
-			TargetState: []models.EncryptionKeyStateENUMType{models.EncryptionKeyStateActive},
-		},
+		newestActiveKeyFilter(),
 		mockDatabase,
 	).Return(nil, nil).Once()
 	mockCrypto.On(
@@ -91,8 +111,20 @@ func TestKVStoreRecordNewKey(t *testing.T) {
 		mock.AnythingOfType("context.backgroundCtx"),
 		mockDatabase,
 	).Return(testEncKey, nil)
-	uut, err := store.NewProtectedKVStore(utCtx, mockDBClient, mockCrypto)
+	mockDatabase.On(
+		"LockSystemParams", mock.AnythingOfType("context.backgroundCtx"),
+	).Return(nil).Once()
+	mockDatabase.On(
+		"GetSystemParamEntry", mock.Anything,
+	).Return(models.SystemParams{State: models.SystemStateRunning}, nil)
+	uut, err := store.NewProtectedKVStore(
+		utCtx, mockDBClient, mockCrypto, store.ProtectedKVStoreOptions{},
+	)
 	assert.Nil(err)
+	// Changes are published once committed
+	mockDatabase.On("AfterCommit", mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(0).(func())()
+	})
 
 	testKey := uuid.NewString()
 	testValue := uuid.NewString()
@@ -105,28 +137,255 @@ func TestKVStoreRecordNewKey(t *testing.T) {
 	testVersion := models.RecordVersion{ID: uuid.NewString()}
 	mockDatabase.On(
 		"GetRecordByName",
-		mock.AnythingOfType("context.backgroundCtx"),
+		mock.AnythingOfType("*context.valueCtx"),
+		"",
 		testKey,
 	).Return(testRecord, nil).Once()
 	mockCrypto.On(
 		"EncryptData",
+		mock.AnythingOfType("*context.valueCtx"),
+		testEncKey.ID,
+		[]byte(testValue),
+		[]byte(testRecord.ID),
+		[]byte(nil),
+		mockDatabase,
+	).Return(testEncKey, encryption.EncryptedData{
+		CipherText: []byte(testEncValue), Nonce: []byte(testNonce), AAD: []byte(testRecord.ID),
+	}, nil).Once()
+	mockDatabase.On(
+		"DefineNewVersionForRecord",
+		mock.AnythingOfType("*context.valueCtx"),
+		testRecord,
+		testEncKey,
+		[]byte(testEncValue),
+		[]byte(testNonce),
+		timestamp,
+		db.NewVersionOptions{AADBound: true},
+	).Return(testVersion, nil).Once()
+	theRecord, theVersion, err := uut.RecordKeyValue(
+		utCtx, testKey, []byte(testValue), timestamp, mockDatabase,
+	)
+	assert.Nil(err)
+	assert.Equal(testRecord, theRecord)
+	assert.Equal(testVersion, theVersion)
+}
+
+func TestKVStoreRecordNewKeyCreateRace(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	mockDBClient := mockdb.NewClient(t)
+	mockDatabase := mockdb.NewDatabase(t)
+	mockCrypto := mockencryption.NewCryptographyEngine(t)
+
+	testEncKey := models.EncryptionKey{ID: uuid.NewString()}
+
+	mockDBClient.On(
+		"UseDatabaseInTransaction",
+		mock.AnythingOfType("context.backgroundCtx"),
+		mock.Anything,
+	).Run(func(args mock.Arguments) {
+		callBack, ok := args.Get(1).(func(ctx context.Context, dbClient db.Database) error)
+		assert.True(ok)
+		assert.Nil(callBack(utCtx, mockDatabase))
+	}).Return(nil).Once()
+	mockCrypto.On(
+		"ListEncryptionKeys",
 		mock.AnythingOfType("context.backgroundCtx"),
+		newestActiveKeyFilter(),
+		mockDatabase,
+	).Return([]models.EncryptionKey{testEncKey}, nil).Once()
+	mockDatabase.On(
+		"LockSystemParams", mock.AnythingOfType("context.backgroundCtx"),
+	).Return(nil).Once()
+	mockDatabase.On(
+		"GetSystemParamEntry", mock.Anything,
+	).Return(models.SystemParams{State: models.SystemStateRunning}, nil)
+	uut, err := store.NewProtectedKVStore(
+		utCtx, mockDBClient, mockCrypto, store.ProtectedKVStoreOptions{},
+	)
+	assert.Nil(err)
+	// Changes are published once committed
+	mockDatabase.On("AfterCommit", mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(0).(func())()
+	})
+
+	testKey := uuid.NewString()
+	testValue := uuid.NewString()
+	timestamp := time.Now().UTC()
+
+	// The key is created by another writer between the lookup and the insert
+	testRecord := models.Record{ID: uuid.NewString(), Name: testKey}
+	testVersion := models.RecordVersion{ID: uuid.NewString()}
+	mockDatabase.On(
+		"GetRecordByName",
+		mock.AnythingOfType("*context.valueCtx"),
+		"",
+		testKey,
+	).Return(models.Record{}, db.ErrRecordNotFound).Once()
+	mockDatabase.On(
+		"DefineNewRecord",
+		mock.AnythingOfType("*context.valueCtx"),
+		"",
+		testKey,
+	).Return(models.Record{}, db.ErrDuplicateRecordName).Once()
+	mockDatabase.On(
+		"GetRecordByName",
+		mock.AnythingOfType("*context.valueCtx"),
+		"",
+		testKey,
+	).Return(testRecord, nil).Once()
+	mockCrypto.On(
+		"EncryptData",
+		mock.AnythingOfType("*context.valueCtx"),
 		testEncKey.ID,
 		[]byte(testValue),
+		[]byte(testRecord.ID),
+		[]byte(nil),
 		mockDatabase,
 	).Return(testEncKey, encryption.EncryptedData{
-		CipherText: []byte(testEncValue), Nonce: []byte(testNonce),
+		CipherText: []byte(uuid.NewString()), Nonce: []byte(uuid.NewString()),
 	}, nil).Once()
 	mockDatabase.On(
 		"DefineNewVersionForRecord",
+		mock.AnythingOfType("*context.valueCtx"),
+		testRecord,
+		testEncKey,
+		mock.AnythingOfType("[]uint8"),
+		mock.AnythingOfType("[]uint8"),
+		timestamp,
+		db.NewVersionOptions{AADBound: true},
+	).Return(testVersion, nil).Once()
+	theRecord, theVersion, err := uut.RecordKeyValue(
+		utCtx, testKey, []byte(testValue), timestamp, mockDatabase,
+	)
+	assert.Nil(err)
+	assert.Equal(testRecord, theRecord)
+	assert.Equal(testVersion, theVersion)
+
+	// Creating the key must fail instead
+	mockDatabase.On(
+		"GetRecordByName",
+		mock.AnythingOfType("*context.valueCtx"),
+		"",
+		testKey,
+	).Return(models.Record{}, db.ErrRecordNotFound).Once()
+	mockDatabase.On(
+		"DefineNewRecord",
+		mock.AnythingOfType("*context.valueCtx"),
+		"",
+		testKey,
+	).Return(models.Record{}, db.ErrDuplicateRecordName).Once()
+	_, _, err = uut.CreateKeyWithValue(
+		utCtx, testKey, []byte(testValue), timestamp, mockDatabase,
+	)
+	assert.ErrorIs(err, store.ErrKeyExists)
+}
+
+func TestKVStoreCreateKeyWithValue(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	mockDBClient := mockdb.NewClient(t)
+	mockDatabase := mockdb.NewDatabase(t)
+	mockCrypto := mockencryption.NewCryptographyEngine(t)
+
+	testEncKey := models.EncryptionKey{ID: uuid.NewString()}
+
+	mockCrypto.On(
+		"ListEncryptionKeys",
 		mock.AnythingOfType("context.backgroundCtx"),
+		newestActiveKeyFilter(),
+		mockDatabase,
+	).Return(nil, nil).Once()
+	mockCrypto.On(
+		"NewEncryptionKey",
+		mock.AnythingOfType("context.backgroundCtx"),
+		mockDatabase,
+	).Return(testEncKey, nil)
+	mockDBClient.On(
+		"UseDatabaseInTransaction",
+		mock.AnythingOfType("context.backgroundCtx"),
+		mock.Anything,
+	).Run(func(args mock.Arguments) {
+		callBack, ok := args.Get(1).(func(ctx context.Context, dbClient db.Database) error)
+		assert.True(ok)
+		assert.Nil(callBack(utCtx, mockDatabase))
+	}).Return(nil).Once()
+	mockDatabase.On(
+		"LockSystemParams", mock.AnythingOfType("context.backgroundCtx"),
+	).Return(nil).Once()
+	mockDatabase.On(
+		"GetSystemParamEntry", mock.Anything,
+	).Return(models.SystemParams{State: models.SystemStateRunning}, nil)
+	uut, err := store.NewProtectedKVStore(
+		utCtx, mockDBClient, mockCrypto, store.ProtectedKVStoreOptions{},
+	)
+	assert.Nil(err)
+	// Changes are published once committed
+	mockDatabase.On("AfterCommit", mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(0).(func())()
+	})
+
+	testKey := uuid.NewString()
+	testValue := uuid.NewString()
+	testEncValue := uuid.NewString()
+	testNonce := uuid.NewString()
+	timestamp := time.Now().UTC()
+
+	// Existing key is rejected
+	testRecord := models.Record{ID: uuid.NewString()}
+	mockDatabase.On(
+		"GetRecordByName",
+		mock.AnythingOfType("*context.valueCtx"),
+		"",
+		testKey,
+	).Return(testRecord, nil).Once()
+	_, _, err = uut.CreateKeyWithValue(
+		utCtx, testKey, []byte(testValue), timestamp, mockDatabase,
+	)
+	assert.ErrorIs(err, store.ErrKeyExists)
+
+	// New key is created
+	testVersion := models.RecordVersion{ID: uuid.NewString()}
+	mockDatabase.On(
+		"GetRecordByName",
+		mock.AnythingOfType("*context.valueCtx"),
+		"",
+		testKey,
+	).Return(models.Record{}, db.ErrRecordNotFound).Once()
+	mockDatabase.On(
+		"DefineNewRecord",
+		mock.AnythingOfType("*context.valueCtx"),
+		"",
+		testKey,
+	).Return(testRecord, nil).Once()
+	mockCrypto.On(
+		"EncryptData",
+		mock.AnythingOfType("*context.valueCtx"),
+		testEncKey.ID,
+		[]byte(testValue),
+		[]byte(testRecord.ID),
+		[]byte(nil),
+		mockDatabase,
+	).Return(testEncKey, encryption.EncryptedData{
+		CipherText: []byte(testEncValue), Nonce: []byte(testNonce), AAD: []byte(testRecord.ID),
+	}, nil).Once()
+	mockDatabase.On(
+		"DefineNewVersionForRecord",
+		mock.AnythingOfType("*context.valueCtx"),
 		testRecord,
 		testEncKey,
 		[]byte(testEncValue),
 		[]byte(testNonce),
 		timestamp,
+		db.NewVersionOptions{AADBound: true},
 	).Return(testVersion, nil).Once()
-	theRecord, theVersion, err := uut.RecordKeyValue(
+	theRecord, theVersion, err := uut.CreateKeyWithValue(
 		utCtx, testKey, []byte(testValue), timestamp, mockDatabase,
 	)
 	assert.Nil(err)
@@ -159,9 +418,7 @@ func TestKVStoreListVersions(t *testing.T) {
 	mockCrypto.On(
 		"ListEncryptionKeys",
 		mock.AnythingOfType("context.backgroundCtx"),
-		db.EncryptionKeyQueryFilter{
-			TargetState: []models.EncryptionKeyStateENUMType{models.EncryptionKeyStateActive},
-		},
+		newestActiveKeyFilter(),
 		mockDatabase,
 	).Return(nil, nil).Once()
 	mockCrypto.On(
@@ -169,7 +426,15 @@ func TestKVStoreListVersions(t *testing.T) {
 		mock.AnythingOfType("context.backgroundCtx"),
 		mockDatabase,
 	).Return(testEncKey, nil)
-	uut, err := store.NewProtectedKVStore(utCtx, mockDBClient, mockCrypto)
+	mockDatabase.On(
+		"LockSystemParams", mock.AnythingOfType("context.backgroundCtx"),
+	).Return(nil).Once()
+	mockDatabase.On(
+		"GetSystemParamEntry", mock.Anything,
+	).Return(models.SystemParams{State: models.SystemStateRunning}, nil)
+	uut, err := store.NewProtectedKVStore(
+		utCtx, mockDBClient, mockCrypto, store.ProtectedKVStoreOptions{},
+	)
 	assert.Nil(err)
 
 	testKey := uuid.NewString()
@@ -181,6 +446,7 @@ func TestKVStoreListVersions(t *testing.T) {
 	mockDatabase.On(
 		"GetRecordByName",
 		mock.AnythingOfType("context.backgroundCtx"),
+		"",
 		testKey,
 	).Return(testRecord, nil).Once()
 	mockDatabase.On(
@@ -220,9 +486,7 @@ func TestKVStoreGetValueOfVersion(t *testing.T) {
 	mockCrypto.On(
 		"ListEncryptionKeys",
 		mock.AnythingOfType("context.backgroundCtx"),
-		db.EncryptionKeyQueryFilter{
-			TargetState: []models.EncryptionKeyStateENUMType{models.EncryptionKeyStateActive},
-		},
+		newestActiveKeyFilter(),
 		mockDatabase,
 	).Return(nil, nil).Once()
 	mockCrypto.On(
@@ -230,7 +494,15 @@ func TestKVStoreGetValueOfVersion(t *testing.T) {
 		mock.AnythingOfType("context.backgroundCtx"),
 		mockDatabase,
 	).Return(testEncKey, nil)
-	uut, err := store.NewProtectedKVStore(utCtx, mockDBClient, mockCrypto)
+	mockDatabase.On(
+		"LockSystemParams", mock.AnythingOfType("context.backgroundCtx"),
+	).Return(nil).Once()
+	mockDatabase.On(
+		"GetSystemParamEntry", mock.Anything,
+	).Return(models.SystemParams{State: models.SystemStateRunning}, nil)
+	uut, err := store.NewProtectedKVStore(
+		utCtx, mockDBClient, mockCrypto, store.ProtectedKVStoreOptions{},
+	)
 	assert.Nil(err)
 
 	testVersion := models.RecordVersion{
@@ -306,9 +578,7 @@ func TestKVStoreDeleteKey(t *testing.T) {
 	mockCrypto.On(
 		"ListEncryptionKeys",
 		mock.AnythingOfType("context.backgroundCtx"),
-		db.EncryptionKeyQueryFilter{
-			TargetState: []models.EncryptionKeyStateENUMType{models.EncryptionKeyStateActive},
-		},
+		newestActiveKeyFilter(),
 		mockDatabase,
 	).Return(nil, nil).Once()
 	mockCrypto.On(
@@ -316,8 +586,20 @@ func TestKVStoreDeleteKey(t *testing.T) {
 		mock.AnythingOfType("context.backgroundCtx"),
 		mockDatabase,
 	).Return(testEncKey, nil)
-	uut, err := store.NewProtectedKVStore(utCtx, mockDBClient, mockCrypto)
+	mockDatabase.On(
+		"LockSystemParams", mock.AnythingOfType("context.backgroundCtx"),
+	).Return(nil).Once()
+	mockDatabase.On(
+		"GetSystemParamEntry", mock.Anything,
+	).Return(models.SystemParams{State: models.SystemStateRunning}, nil)
+	uut, err := store.NewProtectedKVStore(
+		utCtx, mockDBClient, mockCrypto, store.ProtectedKVStoreOptions{},
+	)
 	assert.Nil(err)
+	// Changes are published once committed
+	mockDatabase.On("AfterCommit", mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(0).(func())()
+	})
 
 	testKey := uuid.NewString()
 	testRecord := models.Record{ID: uuid.NewString()}
@@ -325,6 +607,7 @@ func TestKVStoreDeleteKey(t *testing.T) {
 	mockDatabase.On(
 		"GetRecordByName",
 		mock.AnythingOfType("context.backgroundCtx"),
+		"",
 		testKey,
 	).Return(testRecord, nil).Once()
 	mockDatabase.On(
@@ -335,3 +618,47 @@ func TestKVStoreDeleteKey(t *testing.T) {
 
 	assert.Nil(uut.DeleteKey(utCtx, testKey, mockDatabase))
 }
+
+func TestKVStoreClose(t *testing.T) {
+	assert := assert.New(t)
+	log.SetLevel(log.DebugLevel)
+
+	utCtx := context.Background()
+
+	mockDBClient := mockdb.NewClient(t)
+	mockDatabase := mockdb.NewDatabase(t)
+	mockCrypto := mockencryption.NewCryptographyEngine(t)
+	// Return the mock DB
+	mockDBClient.On(
+		"UseDatabaseInTransaction",
+		mock.AnythingOfType("context.backgroundCtx"),
+		mock.Anything,
+	).Run(func(args mock.Arguments) {
+		callBack, ok := args.Get(1).(func(ctx context.Context, dbClient db.Database) error)
+		assert.True(ok)
+		assert.Nil(callBack(utCtx, mockDatabase))
+	}).Return(nil).Maybe()
+
+	testEncKey := models.EncryptionKey{ID: uuid.NewString()}
+
+	mockCrypto.On(
+		"ListEncryptionKeys",
+		mock.AnythingOfType("context.backgroundCtx"),
+		newestActiveKeyFilter(),
+		mockDatabase,
+	).Return([]models.EncryptionKey{testEncKey}, nil).Once()
+	mockDatabase.On(
+		"LockSystemParams", mock.AnythingOfType("context.backgroundCtx"),
+	).Return(nil).Once()
+	mockDatabase.On(
+		"GetSystemParamEntry", mock.Anything,
+	).Return(models.SystemParams{State: models.SystemStateRunning}, nil)
+	uut, err := store.NewProtectedKVStore(
+		utCtx, mockDBClient, mockCrypto, store.ProtectedKVStoreOptions{},
+	)
+	assert.Nil(err)
+
+	mockCrypto.On("Shutdown").Return().Once()
+	mockDBClient.On("Close").Return(nil).Once()
+	assert.Nil(uut.Close())
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alwitt/haven/db"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Store metrics
+const (
+	// metricsNameStoreOperation store operation tracking. Additional parameters are
+	// attached via labels
+	//
+	// - operation
+	//
+	// - success
+	metricsNameStoreOperation = "haven_store_operation_total"
+
+	// metricsNameStoreTransactionDuration store database transaction duration tracking.
+	// Additional parameters are attached via labels
+	//
+	// - operation
+	metricsNameStoreTransactionDuration = "haven_store_transaction_duration_seconds"
+)
+
+// storeMetrics the store metrics trackers. A nil instance records nothing.
+type storeMetrics struct {
+	operations *prometheus.CounterVec
+	durations  *prometheus.HistogramVec
+}
+
+/*
+newStoreMetrics define and register the store metrics trackers
+
+	@param registerer prometheus.Registerer - registry to register the trackers with. Nil
+	    to not track metrics.
+	@returns the trackers, or nil if no registry is given
+*/
+func newStoreMetrics(registerer prometheus.Registerer) (*storeMetrics, error) {
+	if registerer == nil {
+		return nil, nil
+	}
+
+	metrics := &storeMetrics{
+		operations: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: metricsNameStoreOperation,
+				Help: "Store operations performed",
+			},
+			[]string{"operation", "success"},
+		),
+		durations: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    metricsNameStoreTransactionDuration,
+				Help:    "Duration of the database transaction of each store operation",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"operation"},
+		),
+	}
+	for _, collector := range []prometheus.Collector{metrics.operations, metrics.durations} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, fmt.Errorf("failed to register store metrics [%w]", err)
+		}
+	}
+	return metrics, nil
+}
+
+// recordOperation count one store operation, and its database transaction duration
+func (m *storeMetrics) recordOperation(operation string, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.operations.WithLabelValues(operation, fmt.Sprintf("%t", err == nil)).Inc()
+	m.durations.WithLabelValues(operation).Observe(duration.Seconds())
+}
+
+// inSession run a store operation with db.ActiveSessionWrapper, tracking its metrics
+func (s *protectedKVStore) inSession(
+	ctx context.Context,
+	operation string,
+	activeDBClient db.Database,
+	coreLogic func(ctx context.Context, dbClient db.Database) error,
+) error {
+	start := time.Now()
+	err := db.ActiveSessionWrapper(ctx, activeDBClient, s.persistence, coreLogic)
+	s.metrics.recordOperation(operation, time.Since(start), err)
+	return err
+}
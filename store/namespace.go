@@ -0,0 +1,32 @@
+package store
+
+import "context"
+
+// namespaceContextKey context key for the key namespace
+type namespaceContextKey struct{}
+
+/*
+WithNamespace scope the store operations made with a context to a key namespace. A key
+name is unique within its namespace, so the same key can hold different values in
+different namespaces.
+
+	@param ctx context.Context - parent context
+	@param namespace string - the key namespace. Empty for the default namespace.
+	@returns the new context
+*/
+func WithNamespace(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, namespaceContextKey{}, namespace)
+}
+
+/*
+NamespaceFromContext read the key namespace from a context
+
+	@param ctx context.Context - the context
+	@returns the namespace, or the default namespace if the context carries none
+*/
+func NamespaceFromContext(ctx context.Context) string {
+	if namespace, ok := ctx.Value(namespaceContextKey{}).(string); ok {
+		return namespace
+	}
+	return ""
+}
@@ -0,0 +1,168 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/alwitt/haven/db"
+	"github.com/alwitt/haven/models"
+)
+
+// PlaintextEntry one key value pair of a plaintext import or export
+type PlaintextEntry struct {
+	// Key the key
+	Key string `json:"key"`
+	// Value the plain text value
+	Value []byte `json:"value_base64"`
+}
+
+/*
+ImportPlaintext record the key value pairs read from JSON lines of PlaintextEntry. Each
+pair is recorded as a new version of its key.
+
+	@param ctx context.Context - execution context
+	@param r io.Reader - the import source
+	@param activeDBClient Database - existing database transaction
+	@returns number of key value pairs recorded
+*/
+func (s *protectedKVStore) ImportPlaintext(
+	ctx context.Context, r io.Reader, activeDBClient db.Database,
+) (int, error) {
+	decoder := json.NewDecoder(r)
+	imported := 0
+	for {
+		var entry PlaintextEntry
+		if err := decoder.Decode(&entry); err == io.EOF {
+			return imported, nil
+		} else if err != nil {
+			return imported, fmt.Errorf("failed to read plaintext entry %d [%w]", imported+1, err)
+		}
+		if entry.Key == "" {
+			return imported, fmt.Errorf("plaintext entry %d has no key", imported+1)
+		}
+
+		if _, _, err := s.RecordKeyValue(
+			ctx, entry.Key, entry.Value, time.Now().UTC(), activeDBClient,
+		); err != nil {
+			return imported, fmt.Errorf("failed to import key '%s' [%w]", entry.Key, err)
+		}
+		imported++
+	}
+}
+
+// forEachLatestVersion call handler with each key of the context's namespace matching the
+// prefix, and its newest version. Keys whose newest version has expired are skipped.
+func forEachLatestVersion(
+	ctx context.Context,
+	keyPrefix string,
+	dbClient db.Database,
+	handler func(record models.Record, version models.RecordVersion) error,
+) error {
+	now := time.Now()
+	namespace := NamespaceFromContext(ctx)
+	return forEachPage(func(page db.CommonListEntryQueryFilter) (int, error) {
+		records, err := dbClient.ListRecords(ctx, db.RecordQueryFilter{
+			CommonListEntryQueryFilter: page,
+			Namespace:                  &namespace,
+			NamePrefix:                 keyPrefix,
+			SortBy:                     db.SortByName,
+			SortAscending:              true,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to list data records [%w]", err)
+		}
+
+		limit := 1
+		for _, record := range records {
+			versions, err := dbClient.ListVersionsOfOneRecord(ctx, record, db.RecordVersionQueryFilter{
+				CommonListEntryQueryFilter: db.CommonListEntryQueryFilter{Limit: &limit},
+			})
+			if err != nil {
+				return 0, fmt.Errorf("failed to list key %s versions [%w]", record.ID, err)
+			}
+			if len(versions) == 0 || versions[0].IsExpired(now) {
+				continue
+			}
+			if err := handler(record, versions[0]); err != nil {
+				return 0, err
+			}
+		}
+		return len(records), nil
+	})
+}
+
+/*
+ExportPlaintext write the decrypted newest value of each key of the context's namespace
+as JSON lines of PlaintextEntry. Keys whose newest version has expired are skipped.
+
+Before writing anything, the export verifies that the engine can decrypt with every
+encryption key the values are encrypted with, whatever the state of the key. A value which
+still fails to decrypt aborts the export, leaving the output incomplete.
+
+	@param ctx context.Context - execution context
+	@param w io.Writer - the export destination
+	@param keyPrefix string - export only keys starting with this prefix. Empty for all.
+	@param activeDBClient Database - existing database transaction
+*/
+func (s *protectedKVStore) ExportPlaintext(
+	ctx context.Context, w io.Writer, keyPrefix string, activeDBClient db.Database,
+) error {
+	encoder := json.NewEncoder(w)
+
+	if dbErr := s.inSession(
+		ctx, "export_plaintext", activeDBClient,
+		func(dbCtx context.Context, dbClient db.Database) error {
+			// Values encrypted with a key since deactivated remain readable, so each
+			// encryption key is probed by decrypting the first value encrypted with it
+			probedKeys := map[string]bool{}
+			if err := forEachLatestVersion(
+				dbCtx, keyPrefix, dbClient,
+				func(record models.Record, version models.RecordVersion) error {
+					if probedKeys[version.EncKeyID] {
+						return nil
+					}
+					_, err := s.GetValueOfKeyAtVersion(dbCtx, version, dbClient)
+					if errors.Is(err, ErrVersionExpired) {
+						return nil
+					} else if err != nil {
+						return fmt.Errorf(
+							"key '%s' version %s can not be decrypted with encryption key %s [%w]",
+							record.Name,
+							version.ID,
+							version.EncKeyID,
+							err,
+						)
+					}
+					probedKeys[version.EncKeyID] = true
+					return nil
+				},
+			); err != nil {
+				return err
+			}
+
+			return forEachLatestVersion(
+				dbCtx, keyPrefix, dbClient,
+				func(record models.Record, version models.RecordVersion) error {
+					plainText, err := s.GetValueOfKeyAtVersion(dbCtx, version, dbClient)
+					if errors.Is(err, ErrVersionExpired) {
+						return nil
+					} else if err != nil {
+						return fmt.Errorf("failed to decrypt key '%s' [%w]", record.Name, err)
+					}
+					if err := encoder.Encode(PlaintextEntry{Key: record.Name, Value: plainText}); err != nil {
+						return fmt.Errorf("failed to write key '%s' [%w]", record.Name, err)
+					}
+					return nil
+				},
+			)
+		},
+	); dbErr != nil {
+		return fmt.Errorf("failed to export plaintext values [%w]", dbErr)
+	}
+
+	return nil
+}
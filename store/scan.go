@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alwitt/haven/db"
+	"github.com/alwitt/haven/models"
+)
+
+// ScanItem one key of a scan, with its newest decrypted value
+type ScanItem struct {
+	// Record the key's data record
+	Record models.Record
+	// Version the newest version of the key
+	Version models.RecordVersion
+	// Value the decrypted value of the newest version
+	Value []byte
+}
+
+/*
+ScanAll stream each key of the context's namespace matching the filter, with its newest
+decrypted value. Keys are read in pages of bounded size, so memory use does not grow with
+the size of the store. Keys without versions, or whose newest version has expired, are
+skipped. The namespace, pagination, and ordering parameters of the filter are ignored;
+keys are delivered in name order.
+
+The channel is closed when the scan ends, after which the returned function reports the
+error which ended it, if any. The caller must either drain the channel or cancel the
+context; the scan holds its database session open until it ends. A caller provided
+transaction must not be used by the caller until the scan ends.
+
+	@param ctx context.Context - execution context. Cancelling it stops the scan.
+	@param filter db.RecordQueryFilter - the keys to scan
+	@param activeDBClient Database - existing database transaction
+	@returns the item channel, and a function reporting the error which ended the scan
+*/
+func (s *protectedKVStore) ScanAll(
+	ctx context.Context, filter db.RecordQueryFilter, activeDBClient db.Database,
+) (<-chan ScanItem, func() error, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, fmt.Errorf("scan context already closed [%w]", err)
+	}
+
+	namespace := NamespaceFromContext(ctx)
+	filter.Namespace = &namespace
+
+	items := make(chan ScanItem)
+	var scanErr error
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(items)
+
+		// The session outlives a cancellation so it is closed normally; cancelling a session
+		// mid-query makes the driver discard its connection.
+		if dbErr := s.inSession(
+			context.WithoutCancel(ctx), "scan_all", activeDBClient,
+			func(dbCtx context.Context, dbClient db.Database) error {
+				return dbClient.ScanRecords(dbCtx, filter, func(record models.Record) error {
+					if err := ctx.Err(); err != nil {
+						return err
+					}
+					item, ok, err := s.readScanItem(dbCtx, record, dbClient)
+					if err != nil || !ok {
+						return err
+					}
+					select {
+					case items <- item:
+						return nil
+					case <-ctx.Done():
+						clear(item.Value)
+						return ctx.Err()
+					}
+				})
+			},
+		); dbErr != nil {
+			scanErr = fmt.Errorf("failed to scan keys [%w]", dbErr)
+		}
+	}()
+
+	return items, func() error {
+		wg.Wait()
+		return scanErr
+	}, nil
+}
+
+// readScanItem read the newest version of a key, and decrypt it. Returns false if the key
+// has no version to deliver.
+func (s *protectedKVStore) readScanItem(
+	ctx context.Context, record models.Record, dbClient db.Database,
+) (ScanItem, bool, error) {
+	latestVersions, err := dbClient.GetLatestVersionsOfRecords(ctx, []string{record.ID})
+	if err != nil {
+		return ScanItem{}, false, fmt.Errorf(
+			"failed to fetch key %s newest version [%w]", record.ID, err,
+		)
+	}
+	version, ok := latestVersions[record.ID]
+	if !ok || version.IsExpired(time.Now()) {
+		return ScanItem{}, false, nil
+	}
+
+	value, err := s.GetValueOfKeyAtVersion(ctx, version, dbClient)
+	if errors.Is(err, ErrVersionExpired) {
+		return ScanItem{}, false, nil
+	} else if err != nil {
+		return ScanItem{}, false, fmt.Errorf("failed to decrypt key '%s' [%w]", record.Name, err)
+	}
+	return ScanItem{Record: record, Version: version, Value: value}, true, nil
+}
@@ -0,0 +1,18 @@
+package store
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// tracer the store's tracer. Spans are only recorded once a tracer provider is installed
+// with otel.SetTracerProvider.
+var tracer = otel.Tracer("github.com/alwitt/haven/store")
+
+// Span attributes recorded by the store. Keys and values are never recorded.
+const (
+	// attrRecordID the data record ID
+	attrRecordID = attribute.Key("haven.record_id")
+	// attrEncryptionKeyID the encryption key ID
+	attrEncryptionKeyID = attribute.Key("haven.encryption_key_id")
+)
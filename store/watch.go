@@ -0,0 +1,138 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// WatchEventTypeENUMType key change event type
+type WatchEventTypeENUMType string
+
+const (
+	// WatchEventTypeCreated a new key was recorded
+	WatchEventTypeCreated WatchEventTypeENUMType = "CREATED"
+	// WatchEventTypeUpdated a new version of an existing key was recorded
+	WatchEventTypeUpdated WatchEventTypeENUMType = "UPDATED"
+	// WatchEventTypeDeleted a key was deleted
+	WatchEventTypeDeleted WatchEventTypeENUMType = "DELETED"
+	// WatchEventTypeLagged the subscriber fell behind, and the events which followed the
+	// previous event were dropped. Only the Type and Timestamp are set.
+	WatchEventTypeLagged WatchEventTypeENUMType = "LAGGED"
+)
+
+// watchEventBufferSize number of undelivered events buffered per watcher. The channel has
+// one more slot, reserved for a WatchEventTypeLagged event.
+const watchEventBufferSize = 32
+
+// WatchEvent key change event
+type WatchEvent struct {
+	// Type the change type
+	Type WatchEventTypeENUMType
+	// Namespace the namespace of the key. Empty for the default namespace.
+	Namespace string
+	// Key the key which changed
+	Key string
+	// RecordID the record ID of the key
+	RecordID string
+	// VersionID the new version ID. Empty for deletions.
+	VersionID string
+	// Timestamp when the change occurred
+	Timestamp time.Time
+}
+
+// keyWatcher one key change subscription
+type keyWatcher struct {
+	namespace string
+	keyPrefix string
+	events    chan WatchEvent
+	// lagged whether the last event queued was a WatchEventTypeLagged event
+	lagged bool
+}
+
+/*
+watchHub tracks the key change subscriptions of a store
+
+Events are published by the store instance making the change, so delivery is local to
+the process; changes made through other store instances are not seen.
+*/
+type watchHub struct {
+	lock     sync.Mutex
+	watchers map[*keyWatcher]bool
+}
+
+/*
+subscribe define a new subscription
+
+The subscription is removed, and its channel closed, once the context is cancelled.
+
+	@param ctx context.Context - subscription context
+	@param namespace string - only deliver events for keys of this namespace
+	@param keyPrefix string - only deliver events for keys with this prefix
+	@returns the event channel
+*/
+func (h *watchHub) subscribe(
+	ctx context.Context, namespace string, keyPrefix string,
+) <-chan WatchEvent {
+	watcher := &keyWatcher{
+		namespace: namespace,
+		keyPrefix: keyPrefix,
+		events:    make(chan WatchEvent, watchEventBufferSize+1),
+	}
+
+	h.lock.Lock()
+	if h.watchers == nil {
+		h.watchers = make(map[*keyWatcher]bool)
+	}
+	h.watchers[watcher] = true
+	h.lock.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		h.lock.Lock()
+		defer h.lock.Unlock()
+		delete(h.watchers, watcher)
+		close(watcher.events)
+	}()
+
+	return watcher.events
+}
+
+/*
+publish deliver an event to the matching subscriptions
+
+Delivery does not block. Once a subscription's buffer is full, its events are dropped,
+and a WatchEventTypeLagged event is queued in their place, until the subscriber catches up.
+
+	@param event WatchEvent - the event
+	@param logTags log.Fields - log metadata
+*/
+func (h *watchHub) publish(event WatchEvent, logTags log.Fields) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for watcher := range h.watchers {
+		if event.Namespace != watcher.namespace || !strings.HasPrefix(event.Key, watcher.keyPrefix) {
+			continue
+		}
+		if len(watcher.events) < watchEventBufferSize {
+			watcher.events <- event
+			watcher.lagged = false
+			continue
+		}
+		log.WithFields(logTags).
+			WithField("key", event.Key).
+			Warn("Watch subscription buffer full, dropping event")
+		if watcher.lagged {
+			continue
+		}
+		lagged := WatchEvent{Type: WatchEventTypeLagged, Timestamp: time.Now().UTC()}
+		select {
+		case watcher.events <- lagged:
+			watcher.lagged = true
+		default:
+		}
+	}
+}
@@ -13,6 +13,7 @@ func main() {
 	stmts, err := gormschema.New("postgres").Load(
 		&db.SystemEventAuditDBEntry{},
 		&db.SystemParamsDBEntry{},
+		&db.SequenceDBEntry{},
 		&db.EncryptionKeyDBEntry{},
 		&db.RecordDBEntry{},
 		&db.RecordVersionDBEntry{},